@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/bench"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+	"github.com/s3-access-control-adapter/internal/proxy"
+)
+
+// Credentials of the in-process gateway started by "gateway bench -in-process"
+const (
+	benchAccessKey = "AKIABENCHMARK0000001"
+	benchSecretKey = "bench-secret-key"
+)
+
+// runBench implements "gateway bench": it drives a signed Get/Put/List mix at
+// a gateway, or at an in-process gateway backed by the in-memory backend, and
+// reports throughput and latency percentiles
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	target := fs.String("target", "http://localhost:8080", "Gateway base URL")
+	inProcess := fs.Bool("in-process", false, "Benchmark an in-process gateway backed by the in-memory backend instead of -target")
+	accessKey := fs.String("access-key", os.Getenv("AWS_ACCESS_KEY_ID"), "Access key to sign requests with")
+	secretKey := fs.String("secret-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), "Secret key to sign requests with")
+	region := fs.String("region", "us-east-1", "Region to sign requests for")
+	bucket := fs.String("bucket", "bench", "Bucket to send traffic to")
+	prefix := fs.String("prefix", "bench/", "Prefix of the benchmark's object keys")
+	mix := fs.String("mix", "get=70,put=20,list=10", "Relative weights of get, put and list requests")
+	keys := fs.Int("keys", 100, "Distinct object keys, written before the run")
+	size := fs.Int("size", 4096, "Object size in bytes")
+	concurrency := fs.Int("concurrency", 16, "Concurrent clients")
+	duration := fs.Duration("duration", 30*time.Second, "How long to run")
+	requests := fs.Int("requests", 0, "Stop after this many requests instead of -duration")
+	fs.Parse(args)
+
+	m, err := bench.ParseMix(*mix)
+	if err != nil {
+		return err
+	}
+	if *concurrency < 1 || *keys < 1 || *size < 0 {
+		return fmt.Errorf("-concurrency and -keys must be positive and -size not negative")
+	}
+	if *requests > 0 {
+		*duration = 0
+	}
+
+	if *inProcess {
+		server, err := startBenchGateway(*bucket)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		*target, *accessKey, *secretKey = server.URL, benchAccessKey, benchSecretKey
+	} else if *accessKey == "" || *secretKey == "" {
+		return fmt.Errorf("-access-key and -secret-key are required (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "benchmarking %s: %d clients, mix %s, %d keys of %d bytes\n",
+		*target, *concurrency, *mix, *keys, *size)
+	res, err := bench.Run(ctx, bench.Options{
+		Target:      *target,
+		AccessKey:   *accessKey,
+		SecretKey:   *secretKey,
+		Region:      *region,
+		Bucket:      *bucket,
+		Prefix:      *prefix,
+		Keys:        *keys,
+		ObjectSize:  *size,
+		Mix:         m,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Requests:    *requests,
+	})
+	if err != nil {
+		return err
+	}
+	res.Report(os.Stdout)
+	return nil
+}
+
+// startBenchGateway serves a gateway over the in-memory backend with a single
+// credential allowed everything on bucket, so runs measure the gateway's own
+// overhead: signature checks, policy evaluation and proxying
+func startBenchGateway(bucket string) (*httptest.Server, error) {
+	credStore, err := auth.NewInMemoryCredentialStoreFromConfig(&config.CredentialsConfig{
+		Credentials: []config.Credential{{
+			AccessKey: benchAccessKey,
+			SecretKey: benchSecretKey,
+			ClientID:  "bench",
+			TenantID:  "bench",
+			Policies:  []string{"bench"},
+			Scopes:    []string{bucket},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	policyEngine, err := policy.NewEngineFromConfig(&config.PoliciesConfig{
+		Policies: []config.Policy{{
+			Name: "bench",
+			Statements: []config.Statement{{
+				Effect:    config.EffectAllow,
+				Actions:   []string{"s3:*"},
+				Resources: []string{"arn:aws:s3:::" + bucket, "arn:aws:s3:::" + bucket + "/*"},
+			}},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := proxy.NewGateway(credStore, auth.NewSignatureValidator(), policyEngine,
+		proxy.NewMemoryBackend(bucket), audit.MultiLogger{})
+	return httptest.NewServer(gateway), nil
+}
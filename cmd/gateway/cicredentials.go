@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/pkg/gatewayclient"
+)
+
+// runCICredentials implements "gateway ci-credentials": run in a CI job, it
+// exchanges the job's OIDC token for short-lived gateway credentials and
+// prints them as environment variables or credential_process JSON
+func runCICredentials(args []string) error {
+	fs := flag.NewFlagSet("ci-credentials", flag.ExitOnError)
+	endpoint := fs.String("endpoint", os.Getenv(gatewayclient.EnvEndpoint), "Gateway URL")
+	path := fs.String("path", "/_gateway/ci-credentials", "Path of the exchange endpoint (ciCredentials.path)")
+	role := fs.String("role", "", "CI role to request")
+	tokenEnv := fs.String("token-env", "", "Environment variable holding the OIDC token, e.g. a GitLab id_token; by default the token is requested from GitHub Actions")
+	audience := fs.String("audience", "s3-gateway", "Audience of the GitHub Actions token, the provider's audience")
+	format := fs.String("format", "env", "Output format: env (export lines), dotenv (KEY=value lines, e.g. for $GITHUB_ENV) or json (credential_process)")
+	fs.Parse(args)
+
+	if *endpoint == "" || *role == "" {
+		return fmt.Errorf("-endpoint and -role are required")
+	}
+	if *format != "env" && *format != "dotenv" && *format != "json" {
+		return fmt.Errorf("-format must be env, dotenv or json")
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var token string
+	if *tokenEnv != "" {
+		if token = os.Getenv(*tokenEnv); token == "" {
+			return fmt.Errorf("%s is not set", *tokenEnv)
+		}
+	} else {
+		var err error
+		if token, err = githubActionsToken(client, *audience); err != nil {
+			return err
+		}
+	}
+
+	body, _ := json.Marshal(proxy.CICredentialsRequest{Role: *role, Token: token})
+	resp, err := client.Post(strings.TrimSuffix(*endpoint, "/")+*path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to request credentials: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(data, &e)
+		return fmt.Errorf("gateway refused the exchange (status %d): %s", resp.StatusCode, e.Error)
+	}
+	var creds proxy.CICredentialsResponse
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return fmt.Errorf("failed to parse credentials: %w", err)
+	}
+
+	if *format == "json" {
+		os.Stdout.Write(data)
+		return nil
+	}
+	vars := [][2]string{
+		{gatewayclient.EnvEndpoint, *endpoint},
+		{gatewayclient.EnvAccessKeyID, creds.AccessKeyID},
+		{gatewayclient.EnvSecretAccessKey, creds.SecretAccessKey},
+		{gatewayclient.EnvSessionToken, creds.SessionToken},
+		{"AWS_ACCESS_KEY_ID", creds.AccessKeyID},
+		{"AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", creds.SessionToken},
+		{"S3_GATEWAY_BUCKET", creds.Bucket},
+		{"S3_GATEWAY_PREFIX", creds.Prefix},
+	}
+	for _, v := range vars {
+		if *format == "env" {
+			fmt.Printf("export %s='%s'\n", v[0], strings.ReplaceAll(v[1], "'", `'\''`))
+		} else {
+			fmt.Printf("%s=%s\n", v[0], v[1])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Credentials for s3://%s/%s expire at %s\n", creds.Bucket, creds.Prefix, creds.Expiration.Format(time.RFC3339))
+	return nil
+}
+
+// githubActionsToken requests the job's OIDC token from GitHub Actions,
+// which needs the id-token: write permission
+func githubActionsToken(client *http.Client, audience string) (string, error) {
+	requestURL, requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return "", fmt.Errorf("not running in GitHub Actions with id-token: write; use -token-env for other CI systems")
+	}
+	req, err := http.NewRequest(http.MethodGet, requestURL+"&audience="+url.QueryEscape(audience), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request GitHub Actions OIDC token: %w", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Value string `json:"value"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request GitHub Actions OIDC token: status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.Value == "" {
+		return "", fmt.Errorf("failed to parse GitHub Actions OIDC token")
+	}
+	return out.Value, nil
+}
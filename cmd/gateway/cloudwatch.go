@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/cloudwatch"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+// startCloudWatch starts the CloudWatch Logs audit sink and the EMF exporter
+// as configured. The returned logger is nil when the audit sink is disabled;
+// stop flushes the exporter.
+func startCloudWatch(ctx context.Context, cfg *config.CloudWatchConfig) (sink audit.Logger, stop func(), err error) {
+	stop = func() {}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, stop, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := cloudwatch.NewLogsClient(awsCfg, cfg.Endpoint)
+
+	hostname, _ := os.Hostname()
+	streamName := func(name string) string {
+		if name != "" {
+			return name
+		}
+		return hostname
+	}
+
+	if cfg.Audit.Enabled {
+		w, err := cloudwatch.NewWriter(ctx, client, cfg.Audit.LogGroup, streamName(cfg.Audit.LogStream), cfg.Audit.FlushInterval)
+		if err != nil {
+			return nil, stop, err
+		}
+		sink = cloudwatch.NewAuditSink(w)
+		log.Printf("CloudWatch Logs audit sink enabled, log group: %s", cfg.Audit.LogGroup)
+	}
+
+	if cfg.EMF.Enabled {
+		emit := func(_ time.Time, doc string) error {
+			_, err := fmt.Fprintln(os.Stdout, doc)
+			return err
+		}
+		var w *cloudwatch.Writer
+		if cfg.EMF.LogGroup != "" {
+			w, err = cloudwatch.NewWriter(ctx, client, cfg.EMF.LogGroup, streamName(cfg.EMF.LogStream), cfg.EMF.Interval)
+			if err != nil {
+				if sink != nil {
+					sink.Close()
+				}
+				return nil, stop, err
+			}
+			emit = w.Add
+		}
+
+		exporter := cloudwatch.NewEMFExporter(metrics.Default, cfg.EMF.Namespace, emit)
+		exporter.Start(cfg.EMF.Interval)
+		stop = func() {
+			exporter.Stop()
+			if w != nil {
+				w.Close()
+			}
+		}
+		log.Printf("CloudWatch EMF metrics enabled, namespace: %s", cfg.EMF.Namespace)
+	}
+	return sink, stop, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// runEncrypt implements "gateway encrypt": it encrypts a credentials file
+// with the key from GATEWAY_ENCRYPTION_KEY, or prints a new key
+func runEncrypt(args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	generateKey := fs.Bool("generate-key", false, "Print a new random encryption key and exit")
+	in := fs.String("in", "", "Plaintext file to encrypt")
+	out := fs.String("out", "", "Path to write the encrypted file (default: <in>.enc)")
+	fs.Parse(args)
+
+	if *generateKey {
+		key, err := config.GenerateEncryptionKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	}
+
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+	if *out == "" {
+		*out = *in + ".enc"
+	}
+
+	key, err := config.EncryptionKey()
+	if err != nil {
+		return err
+	}
+	plaintext, err := os.ReadFile(*in)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *in, err)
+	}
+	if config.IsEncrypted(plaintext) {
+		return fmt.Errorf("%s is already encrypted", *in)
+	}
+	encrypted, err := config.Encrypt(plaintext, key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *out, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *out)
+	return nil
+}
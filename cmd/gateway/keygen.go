@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// runKeygen implements "gateway keygen": it prints a new credential for a
+// tenant with an access key following auth.accessKeys and not used by any
+// configured credential
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	configPath := fs.String("config", "configs/gateway.yaml", "Gateway configuration whose access key policy and credentials apply")
+	tenant := fs.String("tenant", "", "Tenant ID of the new credential")
+	clientID := fs.String("client-id", "", "Client ID of the new credential")
+	fs.Parse(args)
+
+	if *tenant == "" || *clientID == "" {
+		return fmt.Errorf("-tenant and -client-id are required")
+	}
+
+	cfg, err := config.LoadGatewayConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	credentials := cfg.Credentials
+	if cfg.CredentialsFile != "" {
+		creds, err := config.LoadCredentials(cfg.CredentialsFile)
+		if err != nil {
+			return fmt.Errorf("failed to load credentials: %w", err)
+		}
+		credentials = creds.Credentials
+	}
+	used := make(map[string]bool, len(credentials))
+	for _, cred := range credentials {
+		used[cred.AccessKey] = true
+	}
+
+	accessKey, err := auth.GenerateAccessKey(cfg.Auth.AccessKeys.PrefixFor(*tenant),
+		func(key string) bool { return used[key] })
+	if err != nil {
+		return err
+	}
+	secretKey, err := auth.GenerateSecretKey()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Add to the credentials of %s:\n", *configPath)
+	fmt.Printf("- accessKey: %s\n  secretKey: %s\n  clientId: %s\n  tenantId: %s\n", accessKey, secretKey, *clientID, *tenant)
+	return nil
+}
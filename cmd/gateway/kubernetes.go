@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/k8s"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// initialSyncTimeout bounds how long startup waits for the first list of
+// custom resources; serving before then would deny every request
+const initialSyncTimeout = 30 * time.Second
+
+// startKubernetesController starts watching custom resources and returns the
+// store and engine they are loaded into, once the initial sync completes
+func startKubernetesController(ctx context.Context, cfg *config.KubernetesConfig) (*auth.InMemoryCredentialStore, *policy.DefaultEngine, error) {
+	client, err := k8s.NewInClusterClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	credStore, err := auth.NewInMemoryCredentialStoreFromConfig(&config.CredentialsConfig{})
+	if err != nil {
+		return nil, nil, err
+	}
+	policyEngine, err := policy.NewEngineFromConfig(&config.PoliciesConfig{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		log.Printf("Watching %s custom resources in all namespaces", k8s.Group)
+	} else {
+		log.Printf("Watching %s custom resources in namespace %s", k8s.Group, namespace)
+	}
+
+	controller := k8s.NewController(client, cfg, policyEngine, credStore)
+	go controller.Run(ctx)
+
+	select {
+	case <-controller.Synced():
+		return credStore, policyEngine, nil
+	case <-time.After(initialSyncTimeout):
+		return nil, nil, fmt.Errorf("timed out waiting for the initial sync of custom resources")
+	}
+}
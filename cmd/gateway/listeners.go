@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// listener is one configured address bound to its own http.Server
+type listener struct {
+	cfg    config.ListenerConfig
+	ln     net.Listener
+	server *http.Server
+}
+
+// openListeners binds every configured listener, all serving handler
+func openListeners(cfgs []config.ListenerConfig, handler http.Handler) ([]*listener, error) {
+	var listeners []*listener
+	for _, cfg := range cfgs {
+		ln, err := listen(cfg)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, &listener{
+			cfg: cfg,
+			ln:  ln,
+			server: &http.Server{
+				Handler:           handler,
+				ReadTimeout:       cfg.ReadTimeout,
+				WriteTimeout:      cfg.WriteTimeout,
+				IdleTimeout:       cfg.IdleTimeout,
+				ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+				MaxHeaderBytes:    cfg.MaxHeaderBytes,
+			},
+		})
+	}
+	return listeners, nil
+}
+
+func listen(cfg config.ListenerConfig) (net.Listener, error) {
+	key := listenerKey(cfg.Network, cfg.Address)
+	if ln, ok := inherited[key]; ok {
+		delete(inherited, key)
+		return ln, nil
+	}
+	if cfg.Network == "unix" {
+		// Remove a stale socket left by an unclean exit
+		if err := os.Remove(cfg.Address); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", cfg.Address, err)
+		}
+	}
+	ln, err := net.Listen(cfg.Network, cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s %s: %w", cfg.Network, cfg.Address, err)
+	}
+	return ln, nil
+}
+
+// serve runs the listener's server until it is shut down
+func (l *listener) serve() {
+	var err error
+	if l.cfg.TLS.CertFile != "" {
+		log.Printf("Server listening on %s %s (TLS)", l.cfg.Network, l.cfg.Address)
+		err = l.server.ServeTLS(l.ln, l.cfg.TLS.CertFile, l.cfg.TLS.KeyFile)
+	} else {
+		log.Printf("Server listening on %s %s", l.cfg.Network, l.cfg.Address)
+		err = l.server.Serve(l.ln)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Server error on %s: %v", l.cfg.Address, err)
+	}
+}
+
+// shutdownListeners gracefully shuts down all servers concurrently
+func shutdownListeners(ctx context.Context, listeners []*listener) {
+	var wg sync.WaitGroup
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			if err := l.server.Shutdown(ctx); err != nil {
+				log.Printf("Server shutdown error on %s: %v", l.cfg.Address, err)
+			}
+		}(l)
+	}
+	wg.Wait()
+}
+
+func closeListeners(listeners []*listener) {
+	for _, l := range listeners {
+		l.ln.Close()
+	}
+}
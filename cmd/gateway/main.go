@@ -2,109 +2,615 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/s3-access-control-adapter/internal/admin"
+	"github.com/s3-access-control-adapter/internal/alert"
 	"github.com/s3-access-control-adapter/internal/audit"
 	"github.com/s3-access-control-adapter/internal/auth"
 	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/dlp"
+	"github.com/s3-access-control-adapter/internal/geoip"
+	"github.com/s3-access-control-adapter/internal/grant"
+	"github.com/s3-access-control-adapter/internal/metrics"
 	"github.com/s3-access-control-adapter/internal/policy"
 	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/reaper"
+	"github.com/s3-access-control-adapter/internal/redact"
+	"github.com/s3-access-control-adapter/internal/redisstate"
+	"github.com/s3-access-control-adapter/internal/secrets"
+	"github.com/s3-access-control-adapter/internal/slo"
+	"github.com/s3-access-control-adapter/internal/tenant"
+	"github.com/s3-access-control-adapter/internal/uploadlink"
 )
 
+// subcommands are run instead of the gateway when named as the first argument
+var subcommands = map[string]func(args []string) error{
+	"audit":          runAudit,
+	"bench":          runBench,
+	"ci-credentials": runCICredentials,
+	"encrypt":        runEncrypt,
+	"isolation-test": runIsolationTest,
+	"keygen":         runKeygen,
+	"policy":         runPolicy,
+	"validate":       runValidate,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "gateway %s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	configPath := flag.String("config", "configs/gateway.yaml", "Path to gateway configuration file")
+	strictEnv := flag.Bool("strict-env", true, "Fail when config files reference unset environment variables")
 	flag.Parse()
+	config.StrictEnv = *strictEnv
 
-	// Load configuration
+	// Load configuration, resolving ${aws-secrets:...} and ${ssm:...} references
+	secrets.Register()
 	cfg, err := config.LoadGatewayConfig(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	log.Printf("Starting S3 Access Control Adapter Gateway on port %d", cfg.Server.Port)
-
-	// Initialize credential store
-	credStore, err := auth.NewInMemoryCredentialStore(cfg.CredentialsFile)
-	if err != nil {
-		log.Fatalf("Failed to initialize credential store: %v", err)
+	log.Printf("Starting S3 Access Control Adapter Gateway")
+	if err := loadInheritedListeners(); err != nil {
+		log.Fatalf("Failed to start listeners: %v", err)
 	}
-	log.Printf("Loaded credentials from %s", cfg.CredentialsFile)
 
-	// Initialize signature validator
-	sigValidator := auth.NewSignatureValidator()
+	ctx := context.Background()
 
-	// Initialize policy engine
-	policyEngine, err := policy.NewEngine(cfg.PoliciesFile)
-	if err != nil {
-		log.Fatalf("Failed to initialize policy engine: %v", err)
+	// Initialize credential store and policy engine, from files, inline
+	// config or Kubernetes custom resources in controller mode
+	var credStore *auth.InMemoryCredentialStore
+	var policyEngine *policy.DefaultEngine
+	if cfg.Kubernetes.Enabled {
+		credStore, policyEngine, err = startKubernetesController(ctx, &cfg.Kubernetes)
+		if err != nil {
+			log.Fatalf("Failed to start Kubernetes controller: %v", err)
+		}
+	} else {
+		credStore, policyEngine, err = loadCredentialsAndPolicies(ctx, cfg)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := checkPolicyReferences(credStore, policyEngine, cfg); err != nil {
+			if cfg.Validation.PolicyReferences == "fail" {
+				log.Fatalf("Credentials, aliases or CI roles reference unknown policies (set validation.policyReferences: warn to start anyway): %v", err)
+			}
+			log.Printf("Credentials, aliases or CI roles reference unknown policies; their requests will be denied: %v", err)
+		}
 	}
-	log.Printf("Loaded policies from %s", cfg.PoliciesFile)
 
-	// Initialize S3 client
-	ctx := context.Background()
-	s3Client, err := proxy.NewS3Client(ctx, &cfg.AWS)
+	// Initialize signature validator
+	sigValidator := auth.NewSignatureValidatorFromConfig(&cfg.Auth)
+
+	// Initialize storage backend
+	backend, err := newBackend(ctx, cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize S3 client: %v", err)
-	}
-	if cfg.AWS.Endpoint != "" {
-		log.Printf("Connected to S3 endpoint: %s", cfg.AWS.Endpoint)
-	} else {
-		log.Printf("Connected to AWS S3 in region: %s", cfg.AWS.Region)
+		log.Fatalf("Failed to initialize storage backend: %v", err)
 	}
 
 	// Initialize audit logger
-	auditLogger, err := audit.NewLogger(&cfg.Audit)
+	jsonLogger, err := audit.NewLogger(&cfg.Audit)
 	if err != nil {
 		log.Fatalf("Failed to initialize audit logger: %v", err)
 	}
+	var auditLogger audit.Logger = jsonLogger
+	var auditStore *audit.SQLStore
+	if cfg.Audit.Enabled && cfg.Audit.Database.Enabled {
+		auditStore, err = audit.OpenSQLStore(ctx, &cfg.Audit.Database)
+		if err != nil {
+			log.Fatalf("Failed to initialize audit database: %v", err)
+		}
+		auditLogger = audit.MultiLogger{jsonLogger, auditStore}
+	}
+	if cfg.CloudWatch.Audit.Enabled || cfg.CloudWatch.EMF.Enabled {
+		cwSink, stopCloudWatch, err := startCloudWatch(ctx, &cfg.CloudWatch)
+		if err != nil {
+			log.Fatalf("Failed to initialize CloudWatch: %v", err)
+		}
+		defer stopCloudWatch()
+		if cwSink != nil {
+			auditLogger = audit.MultiLogger{auditLogger, cwSink}
+		}
+	}
+	if cfg.Alerts.DenySpike.Enabled {
+		auditLogger = audit.MultiLogger{auditLogger, alert.NewDenySpikeDetector(&cfg.Alerts.DenySpike)}
+		log.Printf("Deny spike alerts enabled (%d denials within %s)",
+			cfg.Alerts.DenySpike.Threshold, cfg.Alerts.DenySpike.Window)
+	}
+	if cfg.Audit.Sampling.AllowRate < 1 || len(cfg.Audit.ExcludeActions) > 0 {
+		auditLogger = audit.NewFilteringLogger(auditLogger, &cfg.Audit)
+	}
+	// Sensitive keys are redacted before any sink sees them
+	redactor := redact.New(&cfg.Redaction)
+	if redactor != nil {
+		auditLogger = audit.NewRedactingLogger(auditLogger, redactor)
+		log.Printf("Redacting object keys matching %d pattern(s) in audit entries and logs", len(cfg.Redaction.Keys))
+	}
 	defer auditLogger.Close()
 	if cfg.Audit.Enabled {
 		log.Printf("Audit logging enabled, output: %s", cfg.Audit.Output)
 	}
+	if retention := &cfg.Audit.Retention; cfg.Audit.Enabled && retention.Enabled() {
+		var archive audit.ArchiveFunc
+		if retention.Archive.Bucket != "" {
+			archive = archiveTo(backend, retention.Archive.Bucket, retention.Archive.Prefix)
+		}
+		go audit.NewPurger(retention, jsonLogger, auditStore, archive).Run(retention.Interval, nil)
+		log.Printf("Purging audit entries beyond the retention limits every %s", retention.Interval)
+	}
+
+	trustedProxies, err := cfg.Server.TrustedProxyPrefixes()
+	if err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
+	gatewayOpts := []proxy.Option{
+		proxy.WithTrustedProxies(trustedProxies),
+		proxy.WithLoadShedding(cfg.Server.LoadShedding.MaxInFlight, cfg.Server.LoadShedding.MaxInFlightPerTenant,
+			cfg.Server.LoadShedding.TenantQueueTimeout),
+		proxy.WithAuditFailClosed(cfg.Audit.Enabled && cfg.Audit.FailureMode == "closed"),
+		proxy.WithVerboseDenyMessages(cfg.Server.VerboseDenyMessages),
+		proxy.WithDenyReasonHeader(cfg.Server.DenyReasonHeader),
+		proxy.WithMetricLabels(&cfg.Metrics),
+		proxy.WithBucketNaming(&cfg.Buckets),
+		proxy.WithKeyRedaction(redactor),
+	}
+	if tagger, ok := backend.(proxy.BucketTagger); ok {
+		gatewayOpts = append(gatewayOpts, proxy.WithBucketTags(tagger, cfg.Buckets.TagCacheTTL))
+	}
+	if tagger, ok := backend.(proxy.ObjectTagger); ok {
+		gatewayOpts = append(gatewayOpts, proxy.WithObjectTags(tagger, cfg.Buckets.ObjectTagCacheTTL))
+	}
+	if len(cfg.Hooks) > 0 {
+		hooks, err := proxy.NewConfigHooks(cfg.Hooks)
+		if err != nil {
+			log.Fatalf("Failed to configure hooks: %v", err)
+		}
+		gatewayOpts = append(gatewayOpts, proxy.WithHooks(hooks...))
+	}
+	if cfg.Chaos.Enabled {
+		gatewayOpts = append(gatewayOpts, proxy.WithChaos(&cfg.Chaos))
+		log.Printf("WARNING: chaos mode enabled, injecting faults per %d rule(s)", len(cfg.Chaos.Rules))
+	}
+	geoResolver, err := geoip.New(&cfg.GeoIP)
+	if err != nil {
+		log.Fatalf("Failed to initialize GeoIP: %v", err)
+	}
+	if geoResolver != nil {
+		gatewayOpts = append(gatewayOpts, proxy.WithGeoIP(geoResolver))
+		log.Printf("GeoIP enrichment enabled")
+	}
+
+	if cfg.SLO.Enabled {
+		tracker := slo.NewTracker(&cfg.SLO)
+		go tracker.Run(15*time.Second, nil)
+		gatewayOpts = append(gatewayOpts, proxy.WithSLO(tracker))
+		log.Printf("SLO tracking enabled (availability %v, latency %v within %s)",
+			cfg.SLO.AvailabilityObjective, cfg.SLO.LatencyObjective, cfg.SLO.LatencyThreshold)
+	}
+
+	// Tenant lifecycle changes are made through the admin API
+	var tenants *tenant.Registry
+	if cfg.Admin.Enabled {
+		tenants, err = tenant.NewRegistry(cfg.Admin.TenantStateFile)
+		if err != nil {
+			log.Fatalf("Failed to load tenant state: %v", err)
+		}
+		gatewayOpts = append(gatewayOpts, proxy.WithTenantStates(tenants))
+	}
+
+	// Break-glass grants are made through the admin API and expire on their own
+	var grants *grant.Store
+	if cfg.Admin.Enabled {
+		grants, err = grant.NewStore(cfg.Admin.GrantStateFile, auditLogger)
+		if err != nil {
+			log.Fatalf("Failed to load grant state: %v", err)
+		}
+		go grants.Run(time.Minute, nil)
+		gatewayOpts = append(gatewayOpts, proxy.WithElevatedAccess(grants))
+	}
+
+	// Upload links are created through the admin API and used without
+	// credentials
+	var uploadLinks *uploadlink.Store
+	if cfg.UploadLinks.Enabled {
+		uploadLinks, err = uploadlink.NewStore(cfg.UploadLinks.StateFile, auditLogger)
+		if err != nil {
+			log.Fatalf("Failed to load upload link state: %v", err)
+		}
+		go uploadLinks.Run(time.Hour, nil)
+		gatewayOpts = append(gatewayOpts, proxy.WithUploadLinks(uploadLinks))
+	}
+
+	// Abandoned multipart uploads are aborted in the background
+	if cfg.UploadReaper.MaxAge > 0 {
+		store, ok := backend.(reaper.Store)
+		if !ok {
+			log.Fatalf("uploadReaper requires the S3 backend")
+		}
+		r := reaper.New(store, bucketTenant(credStore), cfg.UploadReaper.MaxAge, auditLogger)
+		go r.Run(cfg.UploadReaper.Interval, nil)
+		log.Printf("Aborting multipart uploads older than %s every %s", cfg.UploadReaper.MaxAge, cfg.UploadReaper.Interval)
+	}
+
+	// Cluster-wide rate limits when replicas share a Redis
+	if cfg.Redis.Address != "" {
+		redisClient := redisstate.NewClient(&cfg.Redis)
+		defer redisClient.Close()
+		gatewayOpts = append(gatewayOpts, proxy.WithSharedRateLimits(redisstate.NewRateLimiter(redisClient, cfg.Redis.KeyPrefix)))
+		log.Printf("Sharing rate limits through Redis at %s", cfg.Redis.Address)
+	}
+
+	// A sample of allowed requests is replayed against a staging endpoint
+	if cfg.Mirror.Endpoint != "" {
+		stagingCfg := config.AWSConfig{
+			Region:          cfg.Mirror.Region,
+			Endpoint:        cfg.Mirror.Endpoint,
+			AccessKeyID:     cfg.Mirror.AccessKeyID,
+			SecretAccessKey: cfg.Mirror.SecretAccessKey,
+			UsePathStyle:    cfg.Mirror.UsePathStyle,
+			HTTPClient:      cfg.AWS.HTTPClient,
+			Timeouts:        cfg.AWS.Timeouts,
+			Redirects:       "passthrough",
+		}
+		staging, err := proxy.NewS3Client(ctx, &stagingCfg)
+		if err != nil {
+			log.Fatalf("Failed to create mirror client: %v", err)
+		}
+		gatewayOpts = append(gatewayOpts, proxy.WithMirror(staging, &cfg.Mirror))
+		log.Printf("Mirroring %v of allowed requests to %s", cfg.Mirror.SampleRate, cfg.Mirror.Endpoint)
+	}
+	if len(cfg.Operations) > 0 {
+		if err := proxy.CheckOperations(cfg.Operations); err != nil {
+			log.Fatalf("Invalid operations: %v", err)
+		}
+		gatewayOpts = append(gatewayOpts, proxy.WithOperations(cfg.Operations))
+	}
+	if cfg.Multipart.Enabled() {
+		gatewayOpts = append(gatewayOpts, proxy.WithMultipartLimits(&cfg.Multipart))
+	}
+	if len(cfg.Aliases) > 0 {
+		gatewayOpts = append(gatewayOpts, proxy.WithAliases(cfg.Aliases))
+	}
+	if cfg.Server.Compression.Enabled {
+		gatewayOpts = append(gatewayOpts, proxy.WithCompression(&cfg.Server.Compression))
+	}
+	if cfg.CICredentials.Enabled() {
+		gatewayOpts = append(gatewayOpts, proxy.WithCICredentials(&cfg.CICredentials))
+		log.Printf("CI credential exchange enabled at %s for %d roles", cfg.CICredentials.Path, len(cfg.CICredentials.Roles))
+	}
+	if cfg.Server.VerifyETags {
+		gatewayOpts = append(gatewayOpts, proxy.WithETagVerification())
+	}
+	if cfg.ObjectTagging.Enabled {
+		gatewayOpts = append(gatewayOpts, proxy.WithObjectTagging(&cfg.ObjectTagging))
+	}
+	if cfg.DLP.Enabled() {
+		var scanners dlp.Scanners
+		if len(cfg.DLP.Detectors) > 0 {
+			patterns, err := dlp.NewPatterns(cfg.DLP.Detectors)
+			if err != nil {
+				log.Fatalf("Failed to compile DLP detectors: %v", err)
+			}
+			scanners = append(scanners, patterns)
+		}
+		if cfg.DLP.ServiceURL != "" {
+			scanners = append(scanners, dlp.NewService(cfg.DLP.ServiceURL, cfg.DLP.ServiceTimeout))
+		}
+		gatewayOpts = append(gatewayOpts, proxy.WithDLP(&cfg.DLP, scanners))
+		log.Printf("DLP scanning enabled: %d rules", len(cfg.DLP.Rules))
+	}
+
+	// A canary policy set is evaluated alongside the primary one
+	var gatewayEngine policy.Engine = policyEngine
+	if cfg.PolicyCanary.PoliciesFile != "" {
+		canaryEngine, err := policy.NewEngine(cfg.PolicyCanary.PoliciesFile)
+		if err != nil {
+			log.Fatalf("Failed to load canary policies: %v", err)
+		}
+		canary := policy.NewCanaryEngine(policyEngine, canaryEngine, cfg.PolicyCanary.Percent)
+		if redactor != nil {
+			canary.OnDiscrepancy(func(d policy.Discrepancy) {
+				ctx := *d.Ctx
+				ctx.Resource = redactor.Resource(ctx.Resource)
+				d.Ctx = &ctx
+				policy.LogDiscrepancy(d)
+			})
+		}
+		gatewayEngine = canary
+		log.Printf("Policy canary %s decides %v%% of requests", cfg.PolicyCanary.PoliciesFile, cfg.PolicyCanary.Percent)
+	}
 
 	// Create gateway handler
-	gateway := proxy.NewGateway(credStore, sigValidator, policyEngine, s3Client, auditLogger)
+	gateway := proxy.NewGateway(credStore, sigValidator, gatewayEngine, backend, auditLogger, gatewayOpts...)
 
-	// Create HTTP server
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler:      gateway,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
+	// Serve metrics alongside the gateway when enabled
+	var handler http.Handler = gateway
+	if cfg.Metrics.Enabled {
+		metrics.NewInfoFunc("gateway_config_info", "Fingerprints of the loaded policies and credentials",
+			[]string{"policies", "credentials", "fingerprint"}, func() []string {
+				v := gateway.ConfigVersions()
+				return []string{v.Policies, v.Credentials, v.Fingerprint}
+			})
+		metricsHandler := metrics.Default.Handler()
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == cfg.Metrics.Path {
+				metricsHandler.ServeHTTP(w, r)
+				return
+			}
+			gateway.ServeHTTP(w, r)
+		})
+		log.Printf("Metrics enabled at %s", cfg.Metrics.Path)
 	}
 
-	// Start server in goroutine
-	go func() {
-		log.Printf("Server listening on %s", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+	// Bind every listener before serving so a bad address fails startup
+	listeners, err := openListeners(cfg.Server.Listeners, handler)
+	if err != nil {
+		log.Fatalf("Failed to start listeners: %v", err)
+	}
+	for _, l := range listeners {
+		go l.serve()
+	}
+
+	if cfg.Admin.Enabled {
+		adminServer := admin.NewServer(cfg.Admin.Token)
+		adminServer.RegisterMaintenance(gateway)
+		adminServer.RegisterInspection(cfg, policyEngine, credStore)
+		adminServer.RegisterTenants(tenants, credStore, func(ctx context.Context, bucket string) (int, error) {
+			return proxy.EmptyBucket(ctx, backend, bucket)
+		})
+		adminServer.RegisterGrants(grants, policyEngine, credStore, cfg.Admin.MaxGrantDuration)
+		if uploadLinks != nil {
+			adminServer.RegisterUploadLinks(uploadLinks, cfg.UploadLinks.BaseURL, proxy.UploadLinkPath,
+				cfg.UploadLinks.MaxDuration, cfg.UploadLinks.MaxSize)
 		}
-	}()
+		if auditStore != nil {
+			adminServer.RegisterAuditQuery(auditStore)
+		}
+		if cfg.Admin.Debug.Enabled {
+			adminServer.RegisterDebug(cfg.Admin.Debug.DumpDir)
+			log.Printf("Admin debug endpoints enabled")
+		}
+		adminListeners, err := openListeners([]config.ListenerConfig{{Network: "tcp", Address: cfg.Admin.Address}}, adminServer)
+		if err != nil {
+			log.Fatalf("Failed to start admin listener: %v", err)
+		}
+		go adminListeners[0].serve()
+		listeners = append(listeners, adminListeners...)
+	}
+	closeUnclaimedListeners()
+	notifyUpgradeReady()
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, or an upgrade signal handing the listeners
+	// to a new process
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM}, upgradeSignals...)...)
+	upgraded := false
+	for !upgraded {
+		if sig := <-quit; sig == syscall.SIGINT || sig == syscall.SIGTERM {
+			break
+		}
+		log.Println("Upgrading in place...")
+		if err := upgrade(listeners); err != nil {
+			log.Printf("Upgrade failed, still serving: %v", err)
+			continue
+		}
+		upgraded = true
+	}
 
 	log.Println("Shutting down server...")
 
-	// Graceful shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
-	defer cancel()
+	// Reject new requests and fail health checks so load balancers move
+	// traffic away before the listeners close. After an upgrade the new
+	// process already serves on the same listeners.
+	if cfg.Server.DrainTimeout > 0 && !upgraded {
+		log.Printf("Draining for %s", cfg.Server.DrainTimeout)
+		gateway.SetMaintenance(true)
+		time.Sleep(cfg.Server.DrainTimeout)
+	}
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+	// Graceful shutdown; after an upgrade long-running transfers get longer
+	// to finish
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+	if upgraded {
+		shutdownTimeout = cfg.Server.UpgradeTimeout
 	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	// Wait a bit for pending requests
-	time.Sleep(100 * time.Millisecond)
+	shutdownListeners(shutdownCtx, listeners)
 
 	log.Println("Server stopped")
 }
+
+// newBackend creates the storage backend selected in the configuration
+func newBackend(ctx context.Context, cfg *config.GatewayConfig) (proxy.Forwarder, error) {
+	switch cfg.Backend.Type {
+	case "filesystem":
+		backend, err := proxy.NewFilesystemBackend(cfg.Backend.Filesystem.Root)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using filesystem backend at %s", cfg.Backend.Filesystem.Root)
+		return backend, nil
+	case "azure":
+		httpClient, err := proxy.NewHTTPClient(&cfg.AWS.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+		backend, err := proxy.NewAzureBlobBackend(&cfg.Backend.Azure, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using Azure Blob Storage backend for account %s", cfg.Backend.Azure.AccountName)
+		return backend, nil
+	case "memory":
+		log.Printf("Using in-memory backend with buckets %v (data is not persisted)", cfg.Backend.Memory.Buckets)
+		return proxy.NewMemoryBackend(cfg.Backend.Memory.Buckets...), nil
+	default:
+		s3Client, err := proxy.NewS3Client(ctx, &cfg.AWS)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.AWS.Endpoint != "" {
+			log.Printf("Connected to S3 endpoint: %s", cfg.AWS.Endpoint)
+		} else {
+			log.Printf("Connected to AWS S3 in region: %s", cfg.AWS.Region)
+		}
+		return s3Client, nil
+	}
+}
+
+// loadCredentialsAndPolicies loads credentials and policies from their files
+// or from the inline sections of the gateway config
+func loadCredentialsAndPolicies(ctx context.Context, cfg *config.GatewayConfig) (*auth.InMemoryCredentialStore, *policy.DefaultEngine, error) {
+	var credStore *auth.InMemoryCredentialStore
+	var err error
+	if cfg.CredentialsFile != "" {
+		credStore, err = auth.NewInMemoryCredentialStore(cfg.CredentialsFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize credential store: %w", err)
+		}
+		log.Printf("Loaded credentials from %s", cfg.CredentialsFile)
+
+		// Inline credentials are checked when the config is loaded
+		var errs []error
+		for _, cred := range credStore.Credentials() {
+			if err := cfg.Auth.AccessKeys.CheckKey(cred.AccessKey, cred.TenantID); err != nil {
+				errs = append(errs, fmt.Errorf("client %q: %w", cred.ClientID, err))
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return nil, nil, fmt.Errorf("credentials violate auth.accessKeys: %w", err)
+		}
+	} else {
+		credStore, err = auth.NewInMemoryCredentialStoreFromConfig(&config.CredentialsConfig{
+			Credentials: cfg.Credentials,
+			Groups:      cfg.CredentialGroups,
+			Tenants:     cfg.Tenants,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize credential store: %w", err)
+		}
+		log.Printf("Loaded %d inline credentials", len(cfg.Credentials))
+	}
+
+	var policyEngine *policy.DefaultEngine
+	if config.IsRemote(cfg.PoliciesFile) {
+		policyEngine, err = startPolicyPoller(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if cfg.PoliciesFile != "" {
+		policyEngine, err = policy.NewEngine(cfg.PoliciesFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize policy engine: %w", err)
+		}
+		log.Printf("Loaded policies from %s", cfg.PoliciesFile)
+	} else {
+		policyEngine, err = policy.NewEngineFromConfig(&config.PoliciesConfig{
+			Policies:       cfg.Policies,
+			BucketPolicies: cfg.BucketPolicies,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize policy engine: %w", err)
+		}
+		log.Printf("Loaded %d inline policies", len(cfg.Policies))
+	}
+
+	return credStore, policyEngine, nil
+}
+
+// checkPolicyReferences reports credentials, aliases and CI roles naming
+// policies that are not loaded, which would otherwise silently deny every request they make
+func checkPolicyReferences(credStore *auth.InMemoryCredentialStore, policyEngine *policy.DefaultEngine, cfg *config.GatewayConfig) error {
+	known := make(map[string]bool)
+	for _, p := range policyEngine.Policies() {
+		known[p.Name] = true
+	}
+
+	var errs []error
+	for _, cred := range credStore.Credentials() {
+		for _, name := range cred.Policies {
+			if !known[name] {
+				errs = append(errs, fmt.Errorf("client %q (access key %s) references unknown policy %q",
+					cred.ClientID, cred.AccessKey, name))
+			}
+		}
+	}
+	for _, alias := range cfg.Aliases {
+		for _, name := range alias.Policies {
+			if !known[name] {
+				errs = append(errs, fmt.Errorf("alias %q references unknown policy %q", alias.Name, name))
+			}
+		}
+	}
+	for _, role := range cfg.CICredentials.Roles {
+		for _, name := range role.Policies {
+			if !known[name] {
+				errs = append(errs, fmt.Errorf("CI role %q references unknown policy %q", role.Name, name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// archiveTo uploads purged audit entries through the storage backend to
+// bucket, under prefix
+func archiveTo(backend proxy.Forwarder, bucket, prefix string) audit.ArchiveFunc {
+	return func(ctx context.Context, name string, body io.Reader, size int64) error {
+		resp, err := backend.Forward(ctx, &proxy.S3Request{
+			Bucket:        bucket,
+			Key:           prefix + name,
+			Action:        "s3:PutObject",
+			Operation:     "PutObject",
+			HTTPMethod:    http.MethodPut,
+			Headers:       http.Header{"Content-Type": {"application/x-ndjson"}},
+			QueryParams:   url.Values{},
+			Body:          io.NopCloser(body),
+			ContentLength: size,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("archive upload to %s failed with status %d", bucket, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// bucketTenant attributes a bucket to the tenant of the first credential
+// whose scopes include it, following credential reloads
+func bucketTenant(credStore *auth.InMemoryCredentialStore) reaper.TenantOf {
+	return func(bucket string) (string, bool) {
+		for _, cred := range credStore.Credentials() {
+			if cred.TenantID != "" && policy.MatchScope(bucket, cred.Scopes) {
+				return cred.TenantID, true
+			}
+		}
+		return "", false
+	}
+}
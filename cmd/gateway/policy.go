@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+	"github.com/s3-access-control-adapter/internal/secrets"
+)
+
+// runPolicy implements "gateway policy <command>"
+func runPolicy(args []string) error {
+	if len(args) == 0 || args[0] != "lint" {
+		return fmt.Errorf("usage: gateway policy lint [-config FILE]")
+	}
+	return runPolicyLint(args[1:])
+}
+
+// runPolicyLint loads the credentials and policies the gateway would load and
+// prints the problems policy.Lint finds, failing when any of them is an error
+func runPolicyLint(args []string) error {
+	fs := flag.NewFlagSet("policy lint", flag.ExitOnError)
+	configPath := fs.String("config", "configs/gateway.yaml", "Path to gateway configuration file")
+	strictEnv := fs.Bool("strict-env", true, "Fail when config files reference unset environment variables")
+	fs.Parse(args)
+	config.StrictEnv = *strictEnv
+
+	secrets.Register()
+	cfg, err := config.LoadGatewayConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if cfg.Kubernetes.Enabled {
+		return fmt.Errorf("policies are loaded from Kubernetes; use GET /admin/policies/lint on a running gateway")
+	}
+	credStore, policyEngine, err := loadCredentialsAndPolicies(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	findings := policy.Lint(policyEngine.Policies(), policyEngine.BucketPolicies(), principals(credStore))
+
+	errCount := 0
+	for _, f := range findings {
+		fmt.Println(f)
+		if !f.Warning {
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		return fmt.Errorf("%d error(s) found", errCount)
+	}
+	fmt.Fprintf(os.Stderr, "%s: no policy errors (%d warning(s))\n", *configPath, len(findings))
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+	"github.com/s3-access-control-adapter/internal/proxy"
+)
+
+// startPolicyPoller fetches policies from the policiesFile URL and keeps
+// polling it in the background. Startup fails if the first fetch does.
+func startPolicyPoller(ctx context.Context, cfg *config.GatewayConfig) (*policy.DefaultEngine, error) {
+	httpClient, err := proxy.NewHTTPClient(&cfg.AWS.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	var s3Client *s3.Client
+	if strings.HasPrefix(cfg.PoliciesFile, "s3://") {
+		if s3Client, err = proxy.NewSDKClient(ctx, &cfg.AWS); err != nil {
+			return nil, err
+		}
+	}
+	fetcher, err := policy.NewFetcher(cfg.PoliciesFile, s3Client, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := policy.NewEngineFromConfig(&config.PoliciesConfig{})
+	if err != nil {
+		return nil, err
+	}
+	poller := policy.NewPoller(cfg.PoliciesFile, fetcher, engine, cfg.PoliciesPollInterval)
+	if _, err := poller.Poll(ctx); err != nil {
+		return nil, fmt.Errorf("failed to initialize policy engine: %w", err)
+	}
+	go poller.Run(ctx)
+
+	log.Printf("Loaded policies from %s, polling every %s", cfg.PoliciesFile, cfg.PoliciesPollInterval)
+	return engine, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment passed to the process started by an in-place upgrade
+const (
+	// envInheritedListeners lists the network|address of each inherited
+	// listener, comma separated, in file descriptor order from 3
+	envInheritedListeners = "GATEWAY_INHERITED_LISTENERS"
+	// envUpgradeReadyFD is the pipe the new process writes to once it
+	// serves, telling the old one to stop accepting
+	envUpgradeReadyFD = "GATEWAY_UPGRADE_READY_FD"
+)
+
+// upgradeReadyTimeout is how long the old process waits for the new one to
+// start serving before giving up on the upgrade
+const upgradeReadyTimeout = time.Minute
+
+// inherited holds listeners passed by the process this one replaced, keyed
+// by network|address, until openListeners claims them
+var inherited = map[string]net.Listener{}
+
+// loadInheritedListeners takes over the listeners named in the environment
+func loadInheritedListeners() error {
+	v := os.Getenv(envInheritedListeners)
+	if v == "" {
+		return nil
+	}
+	os.Unsetenv(envInheritedListeners)
+	for i, key := range strings.Split(v, ",") {
+		f := os.NewFile(uintptr(3+i), key)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to inherit listener %s: %w", key, err)
+		}
+		inherited[key] = ln
+	}
+	log.Printf("Inherited %d listener(s) from the previous process", len(inherited))
+	return nil
+}
+
+// closeUnclaimedListeners closes inherited listeners the configuration no
+// longer uses
+func closeUnclaimedListeners() {
+	for key, ln := range inherited {
+		log.Printf("Closing inherited listener %s, no longer configured", key)
+		ln.Close()
+		delete(inherited, key)
+	}
+}
+
+// notifyUpgradeReady tells the process that started this one that it is
+// serving
+func notifyUpgradeReady() {
+	v := os.Getenv(envUpgradeReadyFD)
+	if v == "" {
+		return
+	}
+	os.Unsetenv(envUpgradeReadyFD)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+func listenerKey(network, address string) string {
+	return network + "|" + address
+}
+
+// upgrade starts the current executable with the same arguments, passing
+// it every listener, and returns once it serves. The caller then stops
+// accepting and finishes its in-flight requests while the new process
+// takes new connections, so none are refused or dropped.
+func upgrade(listeners []*listener) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	var files []*os.File
+	var keys []string
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, l := range listeners {
+		filer, ok := l.ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s cannot be passed on", l.cfg.Address)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return fmt.Errorf("failed to pass on listener %s: %w", l.cfg.Address, err)
+		}
+		files = append(files, f)
+		keys = append(keys, listenerKey(l.cfg.Network, l.cfg.Address))
+	}
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create upgrade pipe: %w", err)
+	}
+	defer ready.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		envInheritedListeners+"="+strings.Join(keys, ","),
+		fmt.Sprintf("%s=%d", envUpgradeReadyFD, 3+len(files)))
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", exe, err)
+	}
+	go cmd.Wait() // reap the process should it exit before we do
+
+	// The new process writes a byte once it serves; the pipe reaches EOF
+	// without one if it exits first
+	started := make(chan bool, 1)
+	go func() {
+		n, _ := ready.Read(make([]byte, 1))
+		started <- n == 1
+	}()
+	select {
+	case ok := <-started:
+		if !ok {
+			return errors.New("new process exited during startup")
+		}
+	case <-time.After(upgradeReadyTimeout):
+		cmd.Process.Kill()
+		return errors.New("new process did not start serving in time")
+	}
+
+	// Leave unix sockets in place for the new process
+	for _, l := range listeners {
+		if ul, ok := l.ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	log.Printf("Upgrade started process %d", cmd.Process.Pid)
+	return nil
+}
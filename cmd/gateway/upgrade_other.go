@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// upgradeSignals start an in-place upgrade; listeners cannot be passed on
+// here
+var upgradeSignals []os.Signal
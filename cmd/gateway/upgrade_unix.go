@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals start an in-place upgrade
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
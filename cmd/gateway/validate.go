@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/secrets"
+)
+
+// runValidate implements "gateway validate": it checks the gateway config and
+// the files it references, printing every problem found, and fails when any
+// of them is an error
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "configs/gateway.yaml", "Path to gateway configuration file")
+	strictEnv := fs.Bool("strict-env", true, "Fail when config files reference unset environment variables")
+	fs.Parse(args)
+	config.StrictEnv = *strictEnv
+
+	secrets.Register()
+	problems := config.Check(*configPath)
+	// Operation names are known to the proxy package only
+	if cfg, err := config.LoadGatewayConfig(*configPath); err == nil {
+		if err := proxy.CheckOperations(cfg.Operations); err != nil {
+			for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+				problems = append(problems, config.Problem{File: *configPath, Message: err.Error()})
+			}
+		}
+	}
+
+	errCount := 0
+	for _, p := range problems {
+		fmt.Println(p)
+		if !p.Warning {
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		return fmt.Errorf("%d error(s) found", errCount)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: configuration is valid (%d warning(s))\n", *configPath, len(problems))
+	return nil
+}
@@ -0,0 +1,44 @@
+// Package admin serves operational endpoints (maintenance mode, runtime
+// configuration, diagnostics) on a listener separate from S3 traffic.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Server routes admin requests after checking the bearer token
+type Server struct {
+	token string
+	mux   *http.ServeMux
+}
+
+// NewServer creates an admin server that requires token on every request
+func NewServer(token string) *Server {
+	return &Server{token: token, mux: http.NewServeMux()}
+}
+
+// Handle registers a handler for a ServeMux pattern, e.g. "PUT /admin/maintenance"
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP authenticates the request and dispatches it
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gateway-admin"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeTarget struct{ enabled bool }
+
+func (f *fakeTarget) SetMaintenance(enabled bool) { f.enabled = enabled }
+func (f *fakeTarget) InMaintenance() bool         { return f.enabled }
+
+func do(t *testing.T, h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestServer_RequiresToken(t *testing.T) {
+	s := NewServer("secret")
+	s.RegisterMaintenance(&fakeTarget{})
+
+	for _, token := range []string{"", "wrong"} {
+		if w := do(t, s, "GET", "/admin/maintenance", token, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, w.Code)
+		}
+	}
+	if w := do(t, s, "GET", "/admin/maintenance", "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
+
+func TestServer_Maintenance(t *testing.T) {
+	target := &fakeTarget{}
+	s := NewServer("secret")
+	s.RegisterMaintenance(target)
+
+	w := do(t, s, "PUT", "/admin/maintenance", "secret", `{"enabled": true}`)
+	if w.Code != http.StatusOK || !target.enabled {
+		t.Fatalf("status = %d, enabled = %v", w.Code, target.enabled)
+	}
+	w = do(t, s, "GET", "/admin/maintenance", "secret", "")
+	if !strings.Contains(w.Body.String(), `"enabled":true`) {
+		t.Errorf("body = %s", w.Body.String())
+	}
+	if w := do(t, s, "PUT", "/admin/maintenance", "secret", `not json`); w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+)
+
+// AuditQuerier answers audit log queries
+type AuditQuerier interface {
+	Query(ctx context.Context, q audit.Query) ([]*audit.Entry, error)
+}
+
+// RegisterAuditQuery adds GET /admin/audit, returning recorded entries newest
+// first. Parameters: clientId, tenantId, decision, action, bucket, from and
+// to (RFC 3339), and limit.
+func (s *Server) RegisterAuditQuery(store AuditQuerier) {
+	s.Handle("GET /admin/audit", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseAuditQuery(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		entries, err := store.Query(r.Context(), q)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"entries": entries})
+	}))
+}
+
+func parseAuditQuery(r *http.Request) (audit.Query, error) {
+	params := r.URL.Query()
+	q := audit.Query{
+		ClientID: params.Get("clientId"),
+		TenantID: params.Get("tenantId"),
+		Decision: params.Get("decision"),
+		Action:   params.Get("action"),
+		Bucket:   params.Get("bucket"),
+	}
+	if q.Decision != "" && q.Decision != "allow" && q.Decision != "deny" {
+		return q, fmt.Errorf("decision must be allow or deny")
+	}
+
+	var err error
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if v := params.Get(p.name); v != "" {
+			if *p.dst, err = time.Parse(time.RFC3339, v); err != nil {
+				return q, fmt.Errorf("%s must be an RFC 3339 time", p.name)
+			}
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 || q.Limit > audit.MaxQueryLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", audit.MaxQueryLimit)
+		}
+	}
+	return q, nil
+}
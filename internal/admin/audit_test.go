@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+)
+
+type fakeQuerier struct {
+	got audit.Query
+}
+
+func (f *fakeQuerier) Query(ctx context.Context, q audit.Query) ([]*audit.Entry, error) {
+	f.got = q
+	return []*audit.Entry{{RequestID: "req-1", ClientID: q.ClientID, Decision: "deny"}}, nil
+}
+
+func TestAuditQuery(t *testing.T) {
+	store := &fakeQuerier{}
+	s := NewServer("secret")
+	s.RegisterAuditQuery(store)
+
+	w := do(t, s, "GET", "/admin/audit?clientId=app&decision=deny&from=2024-05-07T00:00:00Z&to=2024-05-08T00:00:00Z&limit=10", "secret", "")
+	if w.Code != 200 {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"requestId":"req-1"`) {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+
+	want := audit.Query{
+		ClientID: "app",
+		Decision: "deny",
+		From:     time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC),
+		Limit:    10,
+	}
+	if store.got != want {
+		t.Errorf("query = %+v, want %+v", store.got, want)
+	}
+}
+
+func TestAuditQuery_InvalidParams(t *testing.T) {
+	s := NewServer("secret")
+	s.RegisterAuditQuery(&fakeQuerier{})
+
+	for _, query := range []string{"decision=maybe", "from=yesterday", "limit=0", "limit=5000"} {
+		w := do(t, s, "GET", "/admin/audit?"+query, "secret", "")
+		if w.Code != 400 {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+)
+
+// RegisterDebug adds runtime debugging endpoints: net/http/pprof under
+// /admin/debug/pprof/, expvar at /admin/debug/vars, and POST
+// /admin/debug/dump, which writes goroutine stacks and a heap profile into
+// dumpDir for later analysis
+func (s *Server) RegisterDebug(dumpDir string) {
+	// pprof's index resolves profile names relative to /debug/pprof/
+	strip := func(h http.HandlerFunc) http.Handler { return http.StripPrefix("/admin", h) }
+	s.Handle("GET /admin/debug/pprof/", strip(pprof.Index))
+	s.Handle("GET /admin/debug/pprof/cmdline", strip(pprof.Cmdline))
+	s.Handle("GET /admin/debug/pprof/profile", strip(pprof.Profile))
+	s.Handle("GET /admin/debug/pprof/symbol", strip(pprof.Symbol))
+	s.Handle("POST /admin/debug/pprof/symbol", strip(pprof.Symbol))
+	s.Handle("GET /admin/debug/pprof/trace", strip(pprof.Trace))
+	s.Handle("GET /admin/debug/vars", expvar.Handler())
+
+	s.Handle("POST /admin/debug/dump", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		files, err := writeDumps(dumpDir, time.Now())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"files": files})
+	}))
+}
+
+// writeDumps writes goroutine stacks and a heap profile named after t
+func writeDumps(dir string, t time.Time) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create dump directory: %w", err)
+	}
+	stamp := t.UTC().Format("20060102T150405Z")
+
+	runtime.GC() // the heap profile reflects the last collection
+	var files []string
+	for _, d := range []struct {
+		profile, name string
+		debug         int
+	}{
+		{"goroutine", "goroutines-" + stamp + ".txt", 2},
+		{"heap", "heap-" + stamp + ".pb.gz", 0},
+	} {
+		path := filepath.Join(dir, d.name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return files, fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		err = runtimepprof.Lookup(d.profile).WriteTo(f, d.debug)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return files, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDebug_Endpoints(t *testing.T) {
+	s := NewServer("secret")
+	s.RegisterDebug(t.TempDir())
+
+	if w := do(t, s, "GET", "/admin/debug/pprof/", "", ""); w.Code != 401 {
+		t.Errorf("unauthenticated pprof: status = %d, want 401", w.Code)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/admin/debug/pprof/", "goroutine"},
+		{"/admin/debug/pprof/goroutine?debug=1", "goroutine profile:"},
+		{"/admin/debug/vars", `"memstats"`},
+	}
+	for _, tt := range tests {
+		w := do(t, s, "GET", tt.path, "secret", "")
+		if w.Code != 200 || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: status = %d, body missing %q", tt.path, w.Code, tt.want)
+		}
+	}
+}
+
+func TestDebug_Dump(t *testing.T) {
+	s := NewServer("secret")
+	s.RegisterDebug(t.TempDir())
+
+	w := do(t, s, "POST", "/admin/debug/dump", "secret", "")
+	if w.Code != 200 {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Files []string `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 2 {
+		t.Fatalf("expected 2 files, got %v", resp.Files)
+	}
+	for _, path := range resp.Files {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("dump %s missing or empty: %v", path, err)
+		}
+	}
+}
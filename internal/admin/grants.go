@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/grant"
+)
+
+type createGrantRequest struct {
+	ClientID      string   `json:"clientId"`
+	Policy        string   `json:"policy"`
+	Scopes        []string `json:"scopes"`
+	Duration      string   `json:"duration"`
+	Justification string   `json:"justification"`
+	GrantedBy     string   `json:"grantedBy"`
+}
+
+// RegisterGrants adds break-glass endpoints: GET and POST /admin/grants, and
+// GET and DELETE /admin/grants/{id}. A grant names a loaded policy to attach
+// to a client for a duration of at most maxDuration, and must be justified.
+func (s *Server) RegisterGrants(store *grant.Store, policies PolicyLister, credentials CredentialLister, maxDuration time.Duration) {
+	s.Handle("GET /admin/grants", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"grants": store.List()})
+	}))
+	s.Handle("POST /admin/grants", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req createGrantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		g, ttl, err := checkGrantRequest(&req, policies, credentials, maxDuration)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		created, err := store.Create(g, ttl)
+		if err != nil {
+			writeGrantError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}))
+	s.Handle("GET /admin/grants/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g, ok := store.Get(r.PathValue("id"))
+		if !ok {
+			writeGrantError(w, grant.ErrNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, g)
+	}))
+	s.Handle("DELETE /admin/grants/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g, err := store.Revoke(r.PathValue("id"))
+		if err != nil {
+			writeGrantError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, g)
+	}))
+}
+
+// checkGrantRequest validates a grant request against the loaded policies
+// and credentials
+func checkGrantRequest(req *createGrantRequest, policies PolicyLister, credentials CredentialLister, maxDuration time.Duration) (grant.Grant, time.Duration, error) {
+	if req.Justification == "" {
+		return grant.Grant{}, 0, fmt.Errorf("justification is required")
+	}
+	ttl, err := time.ParseDuration(req.Duration)
+	if err != nil || ttl <= 0 {
+		return grant.Grant{}, 0, fmt.Errorf("duration must be a positive duration such as 1h")
+	}
+	if ttl > maxDuration {
+		return grant.Grant{}, 0, fmt.Errorf("duration must be at most %s", maxDuration)
+	}
+
+	tenantID, found := "", false
+	for _, c := range credentials.Credentials() {
+		if c.ClientID == req.ClientID {
+			tenantID, found = c.TenantID, true
+			break
+		}
+	}
+	if !found {
+		return grant.Grant{}, 0, fmt.Errorf("unknown client %q", req.ClientID)
+	}
+	found = false
+	for _, p := range policies.Policies() {
+		if p.Name == req.Policy {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return grant.Grant{}, 0, fmt.Errorf("unknown policy %q", req.Policy)
+	}
+
+	return grant.Grant{
+		ClientID:      req.ClientID,
+		TenantID:      tenantID,
+		Policy:        req.Policy,
+		Scopes:        req.Scopes,
+		Justification: req.Justification,
+		GrantedBy:     req.GrantedBy,
+	}, ttl, nil
+}
+
+func writeGrantError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, grant.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, grant.ErrEnded):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/grant"
+)
+
+func TestGrants_Lifecycle(t *testing.T) {
+	store, _ := grant.NewStore("", nil)
+	policies := staticPolicies{{Name: "prod-read"}}
+	creds := staticCredentials{{ClientID: "oncall", TenantID: "ops"}}
+	s := NewServer("secret")
+	s.RegisterGrants(store, policies, creds, 4*time.Hour)
+
+	for _, body := range []string{
+		`{"clientId":"oncall","policy":"prod-read","duration":"1h"}`,
+		`{"clientId":"oncall","policy":"prod-read","duration":"5h","justification":"INC-42"}`,
+		`{"clientId":"oncall","policy":"prod-read","duration":"-1h","justification":"INC-42"}`,
+		`{"clientId":"nobody","policy":"prod-read","duration":"1h","justification":"INC-42"}`,
+		`{"clientId":"oncall","policy":"prod-write","duration":"1h","justification":"INC-42"}`,
+	} {
+		if w := do(t, s, "POST", "/admin/grants", "secret", body); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: status = %d, want 400: %s", body, w.Code, w.Body)
+		}
+	}
+
+	w := do(t, s, "POST", "/admin/grants", "secret",
+		`{"clientId":"oncall","policy":"prod-read","duration":"1h","justification":"INC-42","grantedBy":"alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body)
+	}
+	var created grant.Grant
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.TenantID != "ops" || created.ExpiresAt.Sub(created.CreatedAt) != time.Hour {
+		t.Errorf("created = %+v", created)
+	}
+
+	if w := do(t, s, "GET", "/admin/grants/"+created.ID, "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("get: status = %d", w.Code)
+	}
+	if w := do(t, s, "DELETE", "/admin/grants/"+created.ID, "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("revoke: status = %d: %s", w.Code, w.Body)
+	}
+	if w := do(t, s, "DELETE", "/admin/grants/"+created.ID, "secret", ""); w.Code != http.StatusConflict {
+		t.Errorf("revoke twice: status = %d, want 409", w.Code)
+	}
+	if w := do(t, s, "GET", "/admin/grants/missing", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get missing: status = %d, want 404", w.Code)
+	}
+}
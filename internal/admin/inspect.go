@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyLister exposes the loaded policies
+type PolicyLister interface {
+	Policies() []*policy.Policy
+}
+
+// BucketPolicyLister is implemented by policy listers that also load bucket
+// policies
+type BucketPolicyLister interface {
+	BucketPolicies() []*policy.Policy
+}
+
+// CredentialLister exposes the loaded credentials
+type CredentialLister interface {
+	Credentials() []*auth.Credential
+}
+
+type policyView struct {
+	Name       string          `json:"name"`
+	Version    string          `json:"version,omitempty"`
+	Statements []statementView `json:"statements"`
+}
+
+type statementView struct {
+	Sid        string                                       `json:"sid,omitempty"`
+	Effect     string                                       `json:"effect"`
+	Actions    []string                                     `json:"actions"`
+	Resources  []string                                     `json:"resources"`
+	Conditions map[string]map[string]config.ConditionValues `json:"conditions,omitempty"`
+	RateLimit  *config.RateLimit                            `json:"rateLimit,omitempty"`
+}
+
+// credentialView is credential metadata; the secret key is never exposed
+type credentialView struct {
+	AccessKey   string   `json:"accessKey"`
+	ClientID    string   `json:"clientId"`
+	TenantID    string   `json:"tenantId"`
+	Description string   `json:"description,omitempty"`
+	Policies    []string `json:"policies"`
+	Scopes      []string `json:"scopes"`
+	Groups      []string `json:"groups,omitempty"`
+
+	AllowedActions []string `json:"allowedActions,omitempty"`
+}
+
+// RegisterInspection adds read-only endpoints showing what the running
+// instance has loaded: GET /admin/config, /admin/policies, /admin/credentials,
+// and /admin/policies/lint reporting problems found in the loaded policies
+func (s *Server) RegisterInspection(cfg *config.GatewayConfig, policies PolicyLister, credentials CredentialLister) {
+	s.Handle("GET /admin/config", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		view, err := configView(cfg.Redacted())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, view)
+	}))
+
+	s.Handle("GET /admin/policies", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		views := []policyView{}
+		for _, p := range policies.Policies() {
+			view := policyView{Name: p.Name, Version: p.Version, Statements: []statementView{}}
+			for _, stmt := range p.Statements {
+				view.Statements = append(view.Statements, statementView{
+					Sid:        stmt.Sid,
+					Effect:     string(stmt.Effect),
+					Actions:    stmt.Actions,
+					Resources:  stmt.Resources,
+					Conditions: stmt.Conditions,
+					RateLimit:  stmt.RateLimit,
+				})
+			}
+			views = append(views, view)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"policies": views})
+	}))
+
+	s.Handle("GET /admin/policies/lint", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var bucketPolicies []*policy.Policy
+		if b, ok := policies.(BucketPolicyLister); ok {
+			bucketPolicies = b.BucketPolicies()
+		}
+		var principals []policy.Principal
+		for _, c := range credentials.Credentials() {
+			principals = append(principals, policy.Principal{ClientID: c.ClientID, TenantID: c.TenantID,
+				Policies: c.Policies, Scopes: c.Scopes, AllowedActions: c.AllowedActions})
+		}
+		findings := policy.Lint(policies.Policies(), bucketPolicies, principals)
+		if findings == nil {
+			findings = []policy.Finding{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"findings": findings})
+	}))
+
+	s.Handle("GET /admin/credentials", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		views := []credentialView{}
+		for _, c := range credentials.Credentials() {
+			views = append(views, credentialView{
+				AccessKey:   c.AccessKey,
+				ClientID:    c.ClientID,
+				TenantID:    c.TenantID,
+				Description: c.Description,
+				Policies:    c.Policies,
+				Scopes:      c.Scopes,
+				Groups:      c.Groups,
+
+				AllowedActions: c.AllowedActions,
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"credentials": views})
+	}))
+}
+
+// configView converts the configuration to a JSON-friendly value keyed like
+// the YAML file, so the output can be compared with the config on disk
+func configView(cfg *config.GatewayConfig) (interface{}, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var view map[string]interface{}
+	if err := yaml.Unmarshal(data, &view); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
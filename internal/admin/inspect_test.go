@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+type staticPolicies []*policy.Policy
+
+func (p staticPolicies) Policies() []*policy.Policy { return p }
+
+type staticCredentials []*auth.Credential
+
+func (c staticCredentials) Credentials() []*auth.Credential { return c }
+
+func newInspectionServer() *Server {
+	cfg := &config.GatewayConfig{
+		Server: config.ServerConfig{Port: 8080, ReadTimeout: 30 * time.Second},
+		AWS:    config.AWSConfig{Region: "us-east-1", AccessKeyID: "AKIAUPSTREAM", SecretAccessKey: "upstream-secret"},
+		Admin:  config.AdminConfig{Enabled: true, Token: "secret"},
+	}
+	policies := staticPolicies{{
+		Name: "read",
+		Statements: []policy.Statement{{
+			Sid: "AllowRead", Effect: policy.EffectAllow,
+			Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::data/*"},
+		}},
+	}}
+	creds := staticCredentials{{
+		AccessKey: "AKIACLIENT", SecretKey: "client-secret", ClientID: "app", TenantID: "t1",
+		Policies: []string{"read"}, Scopes: []string{"data"},
+	}}
+
+	s := NewServer("secret")
+	s.RegisterInspection(cfg, policies, creds)
+	return s
+}
+
+func TestInspection_ConfigIsRedacted(t *testing.T) {
+	w := do(t, newInspectionServer(), "GET", "/admin/config", "secret", "")
+	body := w.Body.String()
+
+	if w.Code != 200 {
+		t.Fatalf("status = %d, body = %s", w.Code, body)
+	}
+	for _, secret := range []string{"upstream-secret", `"token":"secret"`} {
+		if strings.Contains(body, secret) {
+			t.Errorf("config output leaks %q: %s", secret, body)
+		}
+	}
+	for _, want := range []string{`"secretAccessKey":"REDACTED"`, `"readTimeout":"30s"`, `"accessKeyId":"AKIAUPSTREAM"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("config output missing %s: %s", want, body)
+		}
+	}
+}
+
+func TestInspection_PoliciesAndCredentials(t *testing.T) {
+	s := newInspectionServer()
+
+	body := do(t, s, "GET", "/admin/policies", "secret", "").Body.String()
+	if !strings.Contains(body, `"name":"read"`) || !strings.Contains(body, `"sid":"AllowRead"`) {
+		t.Errorf("policies = %s", body)
+	}
+
+	body = do(t, s, "GET", "/admin/credentials", "secret", "").Body.String()
+	if !strings.Contains(body, `"clientId":"app"`) {
+		t.Errorf("credentials = %s", body)
+	}
+	if strings.Contains(body, "client-secret") {
+		t.Errorf("credentials output leaks the secret key: %s", body)
+	}
+}
+
+func TestInspection_PolicyLint(t *testing.T) {
+	body := do(t, newInspectionServer(), "GET", "/admin/policies/lint", "secret", "").Body.String()
+	if body != `{"findings":[]}`+"\n" {
+		t.Errorf("lint of clean policies = %s", body)
+	}
+
+	policies := staticPolicies{{
+		Name: "read",
+		Statements: []policy.Statement{{
+			Effect: policy.EffectAllow, Actions: []string{"s3:Fetch"}, Resources: []string{"arn:aws:s3:::data/*"},
+		}},
+	}}
+	s := NewServer("secret")
+	s.RegisterInspection(&config.GatewayConfig{}, policies, staticCredentials{{ClientID: "app", Policies: []string{"read"}, Scopes: []string{"data"}}})
+	body = do(t, s, "GET", "/admin/policies/lint", "secret", "").Body.String()
+	if !strings.Contains(body, `"check":"unknown-action"`) || !strings.Contains(body, `"warning":false`) {
+		t.Errorf("lint = %s", body)
+	}
+}
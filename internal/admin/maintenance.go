@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Maintainable is a component that can be put into maintenance mode
+type Maintainable interface {
+	SetMaintenance(enabled bool)
+	InMaintenance() bool
+}
+
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// RegisterMaintenance adds GET/PUT /admin/maintenance for toggling maintenance mode
+func (s *Server) RegisterMaintenance(target Maintainable) {
+	s.Handle("GET /admin/maintenance", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, maintenanceState{Enabled: target.InMaintenance()})
+	}))
+	s.Handle("PUT /admin/maintenance", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var state maintenanceState
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		target.SetMaintenance(state.Enabled)
+		writeJSON(w, http.StatusOK, state)
+	}))
+}
@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/policy"
+	"github.com/s3-access-control-adapter/internal/tenant"
+)
+
+type createTenantRequest struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+type deleteTenantRequest struct {
+	// CleanupBuckets are emptied in the background after deletion
+	CleanupBuckets []string `json:"cleanupBuckets"`
+}
+
+// RegisterTenants adds tenant lifecycle endpoints: GET and POST
+// /admin/tenants, GET and DELETE /admin/tenants/{id}, and POST
+// /admin/tenants/{id}/suspend and /resume. Deletion may name buckets within
+// the tenant's credential scopes for clean to empty.
+func (s *Server) RegisterTenants(registry *tenant.Registry, credentials CredentialLister, clean tenant.BucketCleaner) {
+	s.Handle("GET /admin/tenants", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"tenants": registry.List()})
+	}))
+	s.Handle("POST /admin/tenants", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req createTenantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "body must be JSON with an id"})
+			return
+		}
+		t, err := registry.Create(req.ID, req.Description)
+		if err != nil {
+			writeTenantError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, t)
+	}))
+	s.Handle("GET /admin/tenants/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := registry.Get(r.PathValue("id"))
+		if !ok {
+			writeTenantError(w, tenant.ErrNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	}))
+	s.Handle("POST /admin/tenants/{id}/suspend", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := registry.Suspend(r.PathValue("id"))
+		if err != nil {
+			writeTenantError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	}))
+	s.Handle("POST /admin/tenants/{id}/resume", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := registry.Resume(r.PathValue("id"))
+		if err != nil {
+			writeTenantError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	}))
+	s.Handle("DELETE /admin/tenants/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		var req deleteTenantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := checkCleanupBuckets(id, req.CleanupBuckets, credentials); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		t, err := registry.Delete(id, req.CleanupBuckets, clean)
+		if err != nil {
+			writeTenantError(w, err)
+			return
+		}
+		status := http.StatusOK
+		if t.Cleanup != nil {
+			status = http.StatusAccepted
+		}
+		writeJSON(w, status, t)
+	}))
+}
+
+// checkCleanupBuckets refuses to empty buckets outside the tenant's scopes,
+// so a mistyped name cannot destroy another tenant's data
+func checkCleanupBuckets(tenantID string, buckets []string, credentials CredentialLister) error {
+	var scopes []string
+	for _, c := range credentials.Credentials() {
+		if c.TenantID == tenantID {
+			scopes = append(scopes, c.Scopes...)
+		}
+	}
+	for _, bucket := range buckets {
+		if bucket == "" || strings.ContainsAny(bucket, "*?/") {
+			return fmt.Errorf("invalid cleanup bucket %q", bucket)
+		}
+		if !policy.MatchScope(bucket, scopes) {
+			return fmt.Errorf("bucket %q is outside the scopes of tenant %q", bucket, tenantID)
+		}
+	}
+	return nil
+}
+
+func writeTenantError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, tenant.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, tenant.ErrExists), errors.Is(err, tenant.ErrDeleted):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/tenant"
+)
+
+func TestTenants_Lifecycle(t *testing.T) {
+	registry, _ := tenant.NewRegistry("")
+	creds := staticCredentials{{ClientID: "app", TenantID: "t1", Scopes: []string{"t1-*"}}}
+	var cleaned []string
+	s := NewServer("secret")
+	s.RegisterTenants(registry, creds, func(ctx context.Context, bucket string) (int, error) {
+		cleaned = append(cleaned, bucket)
+		return 0, nil
+	})
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		state              tenant.State
+	}{
+		{"POST", "/admin/tenants", `{"id":"t1","description":"team one"}`, http.StatusCreated, tenant.Active},
+		{"POST", "/admin/tenants", `{"id":"t1"}`, http.StatusConflict, ""},
+		{"POST", "/admin/tenants", `{}`, http.StatusBadRequest, ""},
+		{"GET", "/admin/tenants/t1", "", http.StatusOK, tenant.Active},
+		{"GET", "/admin/tenants/missing", "", http.StatusNotFound, ""},
+		{"POST", "/admin/tenants/t1/suspend", "", http.StatusOK, tenant.Suspended},
+		{"POST", "/admin/tenants/t1/resume", "", http.StatusOK, tenant.Active},
+		{"DELETE", "/admin/tenants/t1", `{"cleanupBuckets":["t2-data"]}`, http.StatusBadRequest, ""},
+		{"DELETE", "/admin/tenants/t1", `{"cleanupBuckets":["t1-*"]}`, http.StatusBadRequest, ""},
+		{"DELETE", "/admin/tenants/t1", `{"cleanupBuckets":["t1-data"]}`, http.StatusAccepted, tenant.Deleted},
+		{"POST", "/admin/tenants/t1/suspend", "", http.StatusConflict, ""},
+		{"DELETE", "/admin/tenants/t9", "", http.StatusOK, tenant.Deleted},
+	}
+	for _, tt := range tests {
+		w := do(t, s, tt.method, tt.path, "secret", tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d: %s", tt.method, tt.path, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.state == "" {
+			continue
+		}
+		var got tenant.Tenant
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.State != tt.state {
+			t.Errorf("%s %s: state = %q (%v), want %q", tt.method, tt.path, got.State, err, tt.state)
+		}
+	}
+
+	registry.Wait()
+	if len(cleaned) != 1 || cleaned[0] != "t1-data" {
+		t.Errorf("cleaned %v, want [t1-data]", cleaned)
+	}
+	w := do(t, s, "GET", "/admin/tenants", "secret", "")
+	var list struct{ Tenants []tenant.Tenant }
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Tenants) != 2 {
+		t.Errorf("list = %s", w.Body)
+	}
+}
@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/uploadlink"
+)
+
+type createUploadLinkRequest struct {
+	TenantID    string `json:"tenantId"`
+	Bucket      string `json:"bucket"`
+	Key         string `json:"key"`
+	MaxSize     int64  `json:"maxSize"`
+	ContentType string `json:"contentType"`
+	Duration    string `json:"duration"`
+	Note        string `json:"note"`
+	CreatedBy   string `json:"createdBy"`
+}
+
+// uploadLinkResponse is a link with the URL to hand to the uploader
+type uploadLinkResponse struct {
+	uploadlink.Link
+	URL string `json:"url"`
+}
+
+// RegisterUploadLinks adds single-use upload link endpoints: GET and POST
+// /admin/upload-links, and GET and DELETE /admin/upload-links/{id}. Links
+// are made from baseURL, last at most maxDuration and allow uploads of at
+// most maxSize bytes.
+func (s *Server) RegisterUploadLinks(store *uploadlink.Store, baseURL, path string, maxDuration time.Duration, maxSize int64) {
+	withURL := func(l uploadlink.Link) uploadLinkResponse {
+		return uploadLinkResponse{Link: l, URL: strings.TrimSuffix(baseURL, "/") + path + l.Token}
+	}
+	s.Handle("GET /admin/upload-links", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		links := []uploadLinkResponse{}
+		for _, l := range store.List() {
+			links = append(links, withURL(l))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"links": links})
+	}))
+	s.Handle("POST /admin/upload-links", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req createUploadLinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		l, ttl, err := checkUploadLinkRequest(&req, maxDuration, maxSize)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		created, err := store.Create(l, ttl)
+		if err != nil {
+			writeUploadLinkError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, withURL(created))
+	}))
+	s.Handle("GET /admin/upload-links/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l, ok := store.Get(r.PathValue("id"))
+		if !ok {
+			writeUploadLinkError(w, uploadlink.ErrNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, withURL(l))
+	}))
+	s.Handle("DELETE /admin/upload-links/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l, err := store.Revoke(r.PathValue("id"))
+		if err != nil {
+			writeUploadLinkError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, withURL(l))
+	}))
+}
+
+// checkUploadLinkRequest validates a link request against the limits
+func checkUploadLinkRequest(req *createUploadLinkRequest, maxDuration time.Duration, maxSize int64) (uploadlink.Link, time.Duration, error) {
+	switch {
+	case req.TenantID == "":
+		return uploadlink.Link{}, 0, fmt.Errorf("tenantId is required")
+	case req.Bucket == "" || strings.ContainsAny(req.Bucket, "/*?"):
+		return uploadlink.Link{}, 0, fmt.Errorf("bucket must be a bucket name")
+	case req.Key == "":
+		return uploadlink.Link{}, 0, fmt.Errorf("key is required")
+	case req.ContentType == "":
+		return uploadlink.Link{}, 0, fmt.Errorf("contentType is required")
+	case req.MaxSize <= 0 || req.MaxSize > maxSize:
+		return uploadlink.Link{}, 0, fmt.Errorf("maxSize must be between 1 and %d bytes", maxSize)
+	}
+	ttl, err := time.ParseDuration(req.Duration)
+	if err != nil || ttl <= 0 {
+		return uploadlink.Link{}, 0, fmt.Errorf("duration must be a positive duration such as 24h")
+	}
+	if ttl > maxDuration {
+		return uploadlink.Link{}, 0, fmt.Errorf("duration must be at most %s", maxDuration)
+	}
+	return uploadlink.Link{
+		TenantID:    req.TenantID,
+		Bucket:      req.Bucket,
+		Key:         req.Key,
+		MaxSize:     req.MaxSize,
+		ContentType: req.ContentType,
+		Note:        req.Note,
+		CreatedBy:   req.CreatedBy,
+	}, ttl, nil
+}
+
+func writeUploadLinkError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, uploadlink.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, uploadlink.ErrEnded):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
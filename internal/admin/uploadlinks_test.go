@@ -0,0 +1,52 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/uploadlink"
+)
+
+func TestUploadLinks_Lifecycle(t *testing.T) {
+	store, _ := uploadlink.NewStore("", nil)
+	s := NewServer("secret")
+	s.RegisterUploadLinks(store, "https://s3.example.com/", "/_gateway/upload/", 24*time.Hour, 1<<20)
+
+	for _, body := range []string{
+		`{"bucket":"inbox","key":"a.pdf","contentType":"application/pdf","maxSize":1024,"duration":"1h"}`,
+		`{"tenantId":"t1","bucket":"inbox","key":"a.pdf","contentType":"application/pdf","maxSize":1024,"duration":"48h"}`,
+		`{"tenantId":"t1","bucket":"inbox","key":"a.pdf","contentType":"application/pdf","maxSize":2097152,"duration":"1h"}`,
+		`{"tenantId":"t1","bucket":"inbox","key":"a.pdf","maxSize":1024,"duration":"1h"}`,
+	} {
+		if w := do(t, s, "POST", "/admin/upload-links", "secret", body); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: status = %d, want 400: %s", body, w.Code, w.Body)
+		}
+	}
+
+	w := do(t, s, "POST", "/admin/upload-links", "secret",
+		`{"tenantId":"t1","bucket":"inbox","key":"a.pdf","contentType":"application/pdf","maxSize":1024,"duration":"1h","createdBy":"alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body)
+	}
+	var created uploadLinkResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if !strings.HasPrefix(created.URL, "https://s3.example.com/_gateway/upload/") || !strings.HasSuffix(created.URL, created.Token) {
+		t.Errorf("url = %q", created.URL)
+	}
+
+	if w := do(t, s, "GET", "/admin/upload-links/"+created.ID, "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("get: status = %d", w.Code)
+	}
+	if w := do(t, s, "DELETE", "/admin/upload-links/"+created.ID, "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("revoke: status = %d: %s", w.Code, w.Body)
+	}
+	if w := do(t, s, "DELETE", "/admin/upload-links/"+created.ID, "secret", ""); w.Code != http.StatusConflict {
+		t.Errorf("revoke twice: status = %d, want 409", w.Code)
+	}
+	if w := do(t, s, "GET", "/admin/upload-links/missing", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get missing: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,194 @@
+// Package alert detects anomalous client behavior from audit entries and
+// notifies operators.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var denySpikeAlerts = metrics.NewCounter("gateway_deny_spike_alerts_total",
+	"Alerts fired for clients exceeding the deny spike threshold")
+
+// webhookTimeout bounds each webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// Alert describes a client that crossed its deny threshold
+type Alert struct {
+	Type        string    `json:"type"`
+	ClientID    string    `json:"clientId,omitempty"`
+	TenantID    string    `json:"tenantId,omitempty"`
+	AccessKeyID string    `json:"accessKeyId,omitempty"`
+	Denies      int       `json:"denies"`
+	Window      string    `json:"window"`
+	LastReason  string    `json:"lastReason"`
+	LastIP      string    `json:"lastSourceIp"`
+	Time        time.Time `json:"time"`
+}
+
+// DenySpikeDetector counts denials per client over a sliding window. It is an
+// audit.Logger, so it sees every recorded decision alongside the audit sinks.
+type DenySpikeDetector struct {
+	cfg    config.DenySpikeConfig
+	now    func() time.Time
+	notify func(Alert)
+	client *http.Client
+
+	mu        sync.Mutex
+	clients   map[string]*clientDenies
+	lastSweep time.Time
+}
+
+// clientDenies holds the times of a client's most recent denials, at most
+// threshold of them, oldest first
+type clientDenies struct {
+	times     []time.Time
+	lastAlert time.Time
+}
+
+// NewDenySpikeDetector creates a detector that logs alerts and posts them to
+// the configured webhook
+func NewDenySpikeDetector(cfg *config.DenySpikeConfig) *DenySpikeDetector {
+	d := &DenySpikeDetector{
+		cfg:     *cfg,
+		now:     time.Now,
+		client:  &http.Client{Timeout: webhookTimeout},
+		clients: make(map[string]*clientDenies),
+	}
+	d.notify = d.send
+	return d
+}
+
+// Log records a denial. Other entries are ignored.
+func (d *DenySpikeDetector) Log(entry *audit.Entry) error {
+	if entry.Decision != "deny" {
+		return nil
+	}
+	// Signature failures have no client yet; the claimed key identifies them
+	key := entry.ClientID
+	if key == "" {
+		if entry.AccessKeyID == "" {
+			return nil
+		}
+		key = "accessKey:" + entry.AccessKeyID
+	}
+	threshold, window := d.limits(entry.TenantID)
+	now := d.now()
+
+	d.mu.Lock()
+	d.sweep(now)
+	c, ok := d.clients[key]
+	if !ok {
+		c = &clientDenies{}
+		d.clients[key] = c
+	}
+	if len(c.times) == threshold {
+		c.times = c.times[1:]
+	}
+	c.times = append(c.times, now)
+
+	fire := len(c.times) == threshold && now.Sub(c.times[0]) <= window &&
+		(c.lastAlert.IsZero() || now.Sub(c.lastAlert) >= d.cfg.Cooldown)
+	if fire {
+		c.lastAlert = now
+	}
+	d.mu.Unlock()
+
+	if fire {
+		denySpikeAlerts.Inc()
+		d.notify(Alert{
+			Type:        "deny_spike",
+			ClientID:    entry.ClientID,
+			TenantID:    entry.TenantID,
+			AccessKeyID: entry.AccessKeyID,
+			Denies:      threshold,
+			Window:      window.String(),
+			LastReason:  entry.DenyReason,
+			LastIP:      entry.SourceIP,
+			Time:        now,
+		})
+	}
+	return nil
+}
+
+// Close implements audit.Logger
+func (d *DenySpikeDetector) Close() error {
+	return nil
+}
+
+// limits returns the threshold and window for a tenant
+func (d *DenySpikeDetector) limits(tenantID string) (int, time.Duration) {
+	threshold, window := d.cfg.Threshold, d.cfg.Window
+	if t, ok := d.cfg.Tenants[tenantID]; ok {
+		if t.Threshold > 0 {
+			threshold = t.Threshold
+		}
+		if t.Window > 0 {
+			window = t.Window
+		}
+	}
+	return threshold, window
+}
+
+// sweep forgets clients with no denials in the longest window, at most once
+// per cooldown. Called with d.mu held.
+func (d *DenySpikeDetector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.cfg.Cooldown {
+		return
+	}
+	d.lastSweep = now
+
+	maxWindow := d.cfg.Window
+	for _, t := range d.cfg.Tenants {
+		maxWindow = max(maxWindow, t.Window)
+	}
+	for key, c := range d.clients {
+		last := c.times[len(c.times)-1]
+		if now.Sub(last) > maxWindow && now.Sub(c.lastAlert) >= d.cfg.Cooldown {
+			delete(d.clients, key)
+		}
+	}
+}
+
+// send logs the alert and delivers it to the webhook in the background, so
+// a slow receiver never delays requests
+func (d *DenySpikeDetector) send(a Alert) {
+	log.Printf("ALERT: deny spike: client=%q tenant=%q accessKey=%q denied %d times within %s, last reason %s from %s",
+		a.ClientID, a.TenantID, a.AccessKeyID, a.Denies, a.Window, a.LastReason, a.LastIP)
+	if d.cfg.WebhookURL == "" {
+		return
+	}
+
+	go func() {
+		body, err := json.Marshal(a)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.WebhookURL, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Failed to create deny spike webhook request: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := d.client.Do(req)
+		if err != nil {
+			log.Printf("Failed to deliver deny spike alert: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Deny spike webhook returned status %d", resp.StatusCode)
+		}
+	}()
+}
@@ -0,0 +1,119 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func newTestDetector(cfg config.DenySpikeConfig) (*DenySpikeDetector, *time.Time, *[]Alert) {
+	d := NewDenySpikeDetector(&cfg)
+	now := time.Date(2024, 5, 7, 12, 0, 0, 0, time.UTC)
+	var alerts []Alert
+	d.now = func() time.Time { return now }
+	d.notify = func(a Alert) { alerts = append(alerts, a) }
+	return d, &now, &alerts
+}
+
+func deny(clientID, tenantID string) *audit.Entry {
+	return &audit.Entry{ClientID: clientID, TenantID: tenantID, Decision: "deny", DenyReason: "POLICY_DENIED"}
+}
+
+func TestDenySpikeDetector(t *testing.T) {
+	d, now, alerts := newTestDetector(config.DenySpikeConfig{
+		Threshold: 3,
+		Window:    time.Minute,
+		Cooldown:  10 * time.Minute,
+		Tenants:   map[string]config.DenySpikeThreshold{"big": {Threshold: 5}},
+	})
+
+	// Allowed requests and denials spread beyond the window do not alert
+	d.Log(&audit.Entry{ClientID: "app", Decision: "allow"})
+	for i := 0; i < 3; i++ {
+		d.Log(deny("app", "t1"))
+		*now = now.Add(40 * time.Second)
+	}
+	if len(*alerts) != 0 {
+		t.Fatalf("expected no alert for slow denials, got %v", *alerts)
+	}
+
+	// Three denials within a minute alert once
+	for i := 0; i < 3; i++ {
+		d.Log(deny("app", "t1"))
+	}
+	if len(*alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(*alerts))
+	}
+	if a := (*alerts)[0]; a.ClientID != "app" || a.Denies != 3 || a.Window != "1m0s" || a.LastReason != "POLICY_DENIED" {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+
+	// Further denials within the cooldown are suppressed
+	for i := 0; i < 5; i++ {
+		d.Log(deny("app", "t1"))
+	}
+	if len(*alerts) != 1 {
+		t.Fatalf("expected cooldown to suppress alerts, got %d", len(*alerts))
+	}
+	*now = now.Add(10 * time.Minute)
+	for i := 0; i < 3; i++ {
+		d.Log(deny("app", "t1"))
+	}
+	if len(*alerts) != 2 {
+		t.Fatalf("expected a second alert after the cooldown, got %d", len(*alerts))
+	}
+
+	// Tenant overrides raise the threshold
+	for i := 0; i < 4; i++ {
+		d.Log(deny("batch", "big"))
+	}
+	if len(*alerts) != 2 {
+		t.Fatalf("expected tenant threshold to apply, got %d alerts", len(*alerts))
+	}
+	d.Log(deny("batch", "big"))
+	if len(*alerts) != 3 || (*alerts)[2].ClientID != "batch" {
+		t.Fatalf("expected alert for batch, got %v", *alerts)
+	}
+
+	// Signature failures are tracked by access key
+	for i := 0; i < 3; i++ {
+		d.Log(&audit.Entry{AccessKeyID: "AKIASTOLEN", Decision: "deny", DenyReason: "AUTH_FAILED"})
+	}
+	if len(*alerts) != 4 || (*alerts)[3].AccessKeyID != "AKIASTOLEN" {
+		t.Fatalf("expected alert for access key, got %v", *alerts)
+	}
+}
+
+func TestDenySpikeDetector_Webhook(t *testing.T) {
+	received := make(chan Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received <- a
+	}))
+	defer server.Close()
+
+	d := NewDenySpikeDetector(&config.DenySpikeConfig{
+		Threshold:  1,
+		Window:     time.Minute,
+		Cooldown:   time.Minute,
+		WebhookURL: server.URL,
+	})
+	d.Log(deny("app", "t1"))
+
+	select {
+	case a := <-received:
+		if a.Type != "deny_spike" || a.ClientID != "app" {
+			t.Errorf("unexpected alert: %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
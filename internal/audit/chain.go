@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxEntrySize bounds a single audit line when reading log files back
+const maxEntrySize = 1 << 20
+
+// chain links entries by hash: each entry records the previous entry's hash
+// in prevHash and its own in hash, computed over the previous hash and the
+// entry's JSON without the hash field. Modifying or removing an entry breaks
+// every later link; removing entries from the end is only detectable against
+// a head hash recorded elsewhere.
+type chain struct {
+	key  []byte // HMAC-SHA256 key; plain SHA-256 when empty
+	last string
+}
+
+// seal sets entry's prevHash, marshals it and appends its hash. It must be
+// called with entries in the order they are written.
+func (c *chain) seal(entry *Entry) ([]byte, error) {
+	entry.PrevHash = c.last
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	entry.Hash = chainHash(c.key, c.last, data)
+	c.last = entry.Hash
+	return appendHash(data, entry.Hash), nil
+}
+
+func chainHash(key []byte, prev string, data []byte) string {
+	h := sha256.New()
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	}
+	h.Write([]byte(prev))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// appendHash adds the hash as the last field of a JSON object
+func appendHash(data []byte, hash string) []byte {
+	out := make([]byte, 0, len(data)+len(hash)+10)
+	out = append(out, data[:len(data)-1]...)
+	out = append(out, `,"hash":"`...)
+	out = append(out, hash...)
+	return append(out, `"}`...)
+}
+
+// splitHash reverses appendHash, returning the hashed JSON and the hash
+func splitHash(line []byte) ([]byte, string, bool) {
+	const suffixLen = len(`,"hash":"`) + sha256.Size*2 + len(`"}`)
+	if len(line) < suffixLen+2 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, "", false
+	}
+	cut := len(line) - suffixLen
+	if !bytes.HasPrefix(line[cut:], []byte(`,"hash":"`)) {
+		return nil, "", false
+	}
+	data := append(line[:cut:cut], '}')
+	return data, string(line[cut+len(`,"hash":"`) : len(line)-2]), true
+}
+
+// lastHash returns the hash of the last entry in an existing log file, so a
+// restarted gateway continues the chain. It is empty for a new file.
+func lastHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if last == nil {
+		return "", nil
+	}
+
+	var entry struct {
+		Hash string `json:"hash"`
+	}
+	if err := json.Unmarshal(last, &entry); err != nil || entry.Hash == "" {
+		return "", fmt.Errorf("last entry of %s is not hash-chained", path)
+	}
+	return entry.Hash, nil
+}
+
+// VerifyResult summarizes a verified audit log
+type VerifyResult struct {
+	Entries     int
+	Head        string // hash of the last entry
+	AnchorFound bool   // whether an entry had the anchor hash passed to Verify
+}
+
+// Verify checks the hash chain of an audit log, returning an error naming the
+// first line that was modified, inserted or follows a removed entry. Anchor is
+// an optional entry hash recorded elsewhere; if no entry has it, entries were
+// removed from the end of the log.
+func Verify(r io.Reader, key []byte, anchor string) (VerifyResult, error) {
+	return VerifyAfter(r, key, "", anchor)
+}
+
+// VerifyAfter is Verify for a log continuing a chain whose head was prev,
+// such as a segment rotated after another, see Segments
+func VerifyAfter(r io.Reader, key []byte, prev, anchor string) (VerifyResult, error) {
+	res := VerifyResult{Head: prev}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		data, hash, ok := splitHash(line)
+		if !ok {
+			return res, fmt.Errorf("line %d: entry has no hash", lineNo)
+		}
+		var entry struct {
+			PrevHash string `json:"prevHash"`
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return res, fmt.Errorf("line %d: invalid entry: %w", lineNo, err)
+		}
+		if entry.PrevHash != res.Head {
+			return res, fmt.Errorf("line %d: chain broken: entry follows %q, expected %q", lineNo, entry.PrevHash, res.Head)
+		}
+		if !hmac.Equal([]byte(chainHash(key, res.Head, data)), []byte(hash)) {
+			return res, fmt.Errorf("line %d: hash mismatch: entry was modified or the key is wrong", lineNo)
+		}
+		res.Entries++
+		res.Head = hash
+		if hash == anchor {
+			res.AnchorFound = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return res, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return res, nil
+}
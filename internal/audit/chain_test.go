@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func writeChainedLog(t *testing.T, path, key string, n int) {
+	t.Helper()
+	logger, err := NewLogger(&config.AuditConfig{
+		Enabled:   true,
+		Output:    "file",
+		FilePath:  path,
+		Integrity: config.AuditIntegrityConfig{Enabled: true, HMACKey: key},
+	})
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < n; i++ {
+		entry := NewAllowEntry("req-"+string(rune('a'+i)), "client", "tenant", "s3:GetObject",
+			"bucket", "key", "127.0.0.1", "test", time.Millisecond, 200)
+		if err := logger.Log(entry); err != nil {
+			t.Fatalf("failed to log entry: %v", err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeChainedLog(t, path, "secret", 3)
+	// A restarted gateway continues the chain
+	writeChainedLog(t, path, "secret", 2)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %d", len(lines))
+	}
+
+	res, err := Verify(bytes.NewReader(data), []byte("secret"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Entries != 5 {
+		t.Errorf("expected 5 entries, got %d", res.Entries)
+	}
+
+	truncated := strings.Join(lines[:3], "")
+	res3, err := Verify(strings.NewReader(truncated), []byte("secret"), res.Head)
+	if err != nil {
+		t.Fatalf("unexpected error for truncated log: %v", err)
+	}
+	if res3.AnchorFound {
+		t.Error("expected anchor not to be found in truncated log")
+	}
+
+	tests := []struct {
+		name    string
+		log     string
+		key     string
+		wantErr string
+	}{
+		{
+			name:    "wrong key",
+			log:     string(data),
+			key:     "other",
+			wantErr: "line 1: hash mismatch",
+		},
+		{
+			name:    "modified entry",
+			log:     strings.Replace(string(data), `"clientId":"client"`, `"clientId":"admin"`, 1),
+			key:     "secret",
+			wantErr: "line 1: hash mismatch",
+		},
+		{
+			name:    "removed entry",
+			log:     lines[0] + lines[2] + lines[3],
+			key:     "secret",
+			wantErr: "line 2: chain broken",
+		},
+		{
+			name:    "reordered entries",
+			log:     lines[1] + lines[0],
+			key:     "secret",
+			wantErr: "line 1: chain broken",
+		},
+		{
+			name:    "unchained entry",
+			log:     lines[0] + `{"requestId":"x"}` + "\n",
+			key:     "secret",
+			wantErr: "line 2: entry has no hash",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(tt.log), []byte(tt.key), "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVerify_SHA256(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeChainedLog(t, path, "", 2)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(bytes.NewReader(data), nil, ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Verify(bytes.NewReader(data), []byte("secret"), ""); err == nil {
+		t.Error("expected keyed verification of an unkeyed chain to fail")
+	}
+}
+
+func TestNewLogger_IntegrityUnchainedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte(`{"requestId":"x"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewLogger(&config.AuditConfig{
+		Enabled:   true,
+		Output:    "file",
+		FilePath:  path,
+		Integrity: config.AuditIntegrityConfig{Enabled: true},
+	})
+	if err == nil {
+		t.Error("expected error continuing a chain from an unchained file")
+	}
+}
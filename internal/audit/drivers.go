@@ -0,0 +1,4 @@
+package audit
+
+// database/sql drivers for the audit database; SQLite needs a cgo build
+import _ "github.com/jackc/pgx/v5/stdlib" // registers "pgx"
@@ -0,0 +1,13 @@
+//go:build cgo
+
+package audit
+
+import (
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3" // registers "sqlite3"
+)
+
+func init() {
+	sql.Register("sqlite", &sqlite3.SQLiteDriver{})
+}
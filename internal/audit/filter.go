@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"hash/fnv"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var droppedEntries = metrics.NewCounter("gateway_audit_entries_dropped_total",
+	"Allowed requests not audited due to sampling or action filters", "reason")
+
+// FilteringLogger samples and filters allowed requests before passing them
+// to another Logger. Denials and failed requests are always recorded.
+type FilteringLogger struct {
+	next           Logger
+	allowRate      float64
+	excludeActions map[string]bool
+}
+
+// NewFilteringLogger wraps next with the sampling and filtering settings in cfg
+func NewFilteringLogger(next Logger, cfg *config.AuditConfig) *FilteringLogger {
+	l := &FilteringLogger{
+		next:           next,
+		allowRate:      cfg.Sampling.AllowRate,
+		excludeActions: make(map[string]bool, len(cfg.ExcludeActions)),
+	}
+	for _, action := range cfg.ExcludeActions {
+		l.excludeActions[action] = true
+	}
+	return l
+}
+
+// Log passes the entry on unless it is an allowed request that is filtered
+// out or not sampled. Break-glass activity is always kept.
+func (l *FilteringLogger) Log(entry *Entry) error {
+	if entry.Decision == "allow" && entry.StatusCode < 400 && entry.GrantID == "" {
+		if l.excludeActions[entry.Action] {
+			droppedEntries.Inc("action")
+			return nil
+		}
+		if !sampled(entry.RequestID, l.allowRate) {
+			droppedEntries.Inc("sampling")
+			return nil
+		}
+	}
+	return l.next.Log(entry)
+}
+
+// Close closes the wrapped logger
+func (l *FilteringLogger) Close() error {
+	return l.next.Close()
+}
+
+// sampleResolution is the granularity of sampling rates
+const sampleResolution = 1000000
+
+// sampled decides by hashing the request ID, so every sink sharing the
+// setting keeps the same requests
+func sampled(requestID string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(requestID))
+	// FNV mixes the low bits best
+	return float64(h.Sum64()%sampleResolution) < rate*sampleResolution
+}
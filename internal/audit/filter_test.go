@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+type countingLogger struct{ entries []*Entry }
+
+func (l *countingLogger) Log(entry *Entry) error {
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *countingLogger) Close() error { return nil }
+
+func TestFilteringLogger(t *testing.T) {
+	next := &countingLogger{}
+	logger := NewFilteringLogger(next, &config.AuditConfig{
+		Sampling:       config.AuditSamplingConfig{AllowRate: 0.1},
+		ExcludeActions: []string{"s3:HeadObject"},
+	})
+
+	allowed := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		logger.Log(&Entry{RequestID: id, Decision: "allow", Action: "s3:GetObject", StatusCode: 200})
+		logger.Log(&Entry{RequestID: id, Decision: "deny", Action: "s3:GetObject"})
+		logger.Log(&Entry{RequestID: id, Decision: "allow", Action: "s3:GetObject", StatusCode: 404})
+		logger.Log(&Entry{RequestID: id, Decision: "deny", Action: "s3:HeadObject"})
+		logger.Log(&Entry{RequestID: id, Decision: "allow", Action: "s3:HeadObject", StatusCode: 200})
+	}
+
+	counts := make(map[string]int)
+	for _, e := range next.entries {
+		counts[fmt.Sprintf("%s %s %d", e.Decision, e.Action, e.StatusCode)]++
+		if e.Decision == "allow" && e.StatusCode == 200 {
+			allowed++
+		}
+	}
+
+	if counts["deny s3:GetObject 0"] != 1000 || counts["deny s3:HeadObject 0"] != 1000 {
+		t.Errorf("denials must always be recorded: %v", counts)
+	}
+	if counts["allow s3:GetObject 404"] != 1000 {
+		t.Errorf("failed requests must always be recorded: %v", counts)
+	}
+	if counts["allow s3:HeadObject 200"] != 0 {
+		t.Errorf("excluded action was recorded: %v", counts)
+	}
+	if allowed < 50 || allowed > 150 {
+		t.Errorf("sampled %d of 1000 allowed requests at rate 0.1", allowed)
+	}
+}
+
+func TestSampled_Deterministic(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		if sampled(id, 0.5) != sampled(id, 0.5) {
+			t.Fatalf("sampling of %s is not deterministic", id)
+		}
+	}
+	if !sampled("any", 1) || sampled("any", 0) {
+		t.Error("rates 1 and 0 must keep all and none")
+	}
+}
@@ -2,6 +2,7 @@ package audit
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/s3-access-control-adapter/internal/config"
+	gwerrors "github.com/s3-access-control-adapter/internal/errors"
 )
 
 // Entry represents an audit log entry
@@ -21,13 +23,50 @@ type Entry struct {
 	Resource   string    `json:"resource"`
 	Bucket     string    `json:"bucket"`
 	Key        string    `json:"key,omitempty"`
-	Decision   string    `json:"decision"` // "allow" or "deny"
+	Alias      string    `json:"alias,omitempty"` // addressed instead of Bucket
+	Decision   string    `json:"decision"`        // "allow" or "deny"
 	DenyReason string    `json:"denyReason,omitempty"`
 	SourceIP   string    `json:"sourceIp"`
 	UserAgent  string    `json:"userAgent,omitempty"`
 	DurationMs int64     `json:"durationMs"`
 	StatusCode int       `json:"statusCode,omitempty"`
 	ErrorMsg   string    `json:"error,omitempty"`
+
+	// DenyCategory groups deny reasons, e.g. "scope" for tenant boundary
+	// denials and "policy" for policy denials
+	DenyCategory string `json:"denyCategory,omitempty"`
+
+	// Client-supplied X-Request-ID (or traceparent trace ID), forwarded
+	// upstream so the request can be traced across systems
+	CorrelationID string `json:"correlationId,omitempty"`
+	TraceParent   string `json:"traceparent,omitempty"`
+
+	// Connection and signature details for investigations
+	AccessKeyID   string   `json:"accessKeyId,omitempty"`
+	SignedHeaders []string `json:"signedHeaders,omitempty"`
+	Protocol      string   `json:"protocol,omitempty"`
+	TLSVersion    string   `json:"tlsVersion,omitempty"`
+	TLSCipher     string   `json:"tlsCipher,omitempty"`
+	BytesIn       int64    `json:"bytesIn"`
+	BytesOut      int64    `json:"bytesOut"`
+
+	// Elevated-access grant that allowed the request, or that the entry
+	// records the lifecycle of
+	GrantID       string `json:"grantId,omitempty"`
+	Justification string `json:"justification,omitempty"`
+	GrantedBy     string `json:"grantedBy,omitempty"`
+
+	// Names of the DLP detectors that matched an upload
+	DLPFindings []string `json:"dlpFindings,omitempty"`
+
+	// Source address location, when GeoIP databases are configured
+	Country string `json:"country,omitempty"`
+	ASN     uint32 `json:"asn,omitempty"`
+	ASOrg   string `json:"asOrg,omitempty"`
+
+	// Set when integrity chaining is enabled
+	PrevHash string `json:"prevHash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
 }
 
 // Logger is the interface for audit logging
@@ -41,7 +80,10 @@ type JSONLogger struct {
 	mu      sync.Mutex
 	writers []io.Writer
 	file    *os.File
+	path    string // of file
 	enabled bool
+	sync    bool   // fsync the file after each entry
+	chain   *chain // nil unless integrity chaining is enabled
 }
 
 // NewLogger creates a new audit logger based on configuration
@@ -49,6 +91,7 @@ func NewLogger(cfg *config.AuditConfig) (*JSONLogger, error) {
 	logger := &JSONLogger{
 		enabled: cfg.Enabled,
 		writers: []io.Writer{},
+		sync:    cfg.FailureMode == "closed",
 	}
 
 	if !cfg.Enabled {
@@ -63,7 +106,7 @@ func NewLogger(cfg *config.AuditConfig) (*JSONLogger, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to open audit log file: %w", err)
 		}
-		logger.file = file
+		logger.file, logger.path = file, cfg.FilePath
 		logger.writers = append(logger.writers, file)
 	case "both":
 		logger.writers = append(logger.writers, os.Stdout)
@@ -71,12 +114,28 @@ func NewLogger(cfg *config.AuditConfig) (*JSONLogger, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to open audit log file: %w", err)
 		}
-		logger.file = file
+		logger.file, logger.path = file, cfg.FilePath
 		logger.writers = append(logger.writers, file)
 	default:
 		logger.writers = append(logger.writers, os.Stdout)
 	}
 
+	if cfg.Integrity.Enabled {
+		logger.chain = &chain{key: []byte(cfg.Integrity.HMACKey)}
+		if logger.file != nil {
+			last, err := lastHash(cfg.FilePath)
+			if err == nil && last == "" {
+				// The file was just rotated; continue from the newest segment
+				last, err = lastSegmentHash(cfg.FilePath)
+			}
+			if err != nil {
+				logger.file.Close()
+				return nil, fmt.Errorf("failed to continue audit hash chain: %w", err)
+			}
+			logger.chain.last = last
+		}
+	}
+
 	return logger, nil
 }
 
@@ -86,22 +145,72 @@ func (l *JSONLogger) Log(entry *Entry) error {
 		return nil
 	}
 
-	data, err := json.Marshal(entry)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Entries are marshalled under the lock so the chain follows write order
+	var data []byte
+	var err error
+	if l.chain != nil {
+		data, err = l.chain.seal(entry)
+	} else {
+		data, err = json.Marshal(entry)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal audit entry: %w", err)
 	}
 	data = append(data, '\n')
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
+	// Every writer gets the entry even if an earlier one fails
+	var errs []error
 	for _, w := range l.writers {
 		if _, err := w.Write(data); err != nil {
-			return fmt.Errorf("failed to write audit entry: %w", err)
+			errs = append(errs, fmt.Errorf("failed to write audit entry: %w", err))
+		}
+	}
+	if l.sync && l.file != nil {
+		if err := l.file.Sync(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync audit log file: %w", err))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// Rotate renames the log file to a segment named after the current time,
+// see Segments, and continues in a new file. Empty files and loggers without
+// a file are left alone. The hash chain continues into the new file.
+func (l *JSONLogger) Rotate(now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	info, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to rotate audit log file: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	segment := l.path + "." + now.UTC().Format(segmentTimeFormat)
+	if err := os.Rename(l.path, segment); err != nil {
+		return fmt.Errorf("failed to rotate audit log file: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		// Entries keep going to the renamed file until the next rotation
+		return fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	for i, w := range l.writers {
+		if w == io.Writer(l.file) {
+			l.writers[i] = file
+		}
+	}
+	old := l.file
+	l.file = file
+	return old.Close()
 }
 
 // Close closes the audit logger
@@ -112,6 +221,31 @@ func (l *JSONLogger) Close() error {
 	return nil
 }
 
+// MultiLogger writes every entry to several loggers
+type MultiLogger []Logger
+
+// Log writes the entry to every logger, even if an earlier one fails
+func (m MultiLogger) Log(entry *Entry) error {
+	var errs []error
+	for _, l := range m {
+		if err := l.Log(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every logger
+func (m MultiLogger) Close() error {
+	var errs []error
+	for _, l := range m {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // NewAllowEntry creates an audit entry for an allowed request
 func NewAllowEntry(requestID, clientID, tenantID, action, bucket, key, sourceIP, userAgent string, duration time.Duration, statusCode int) *Entry {
 	return &Entry{
@@ -134,19 +268,20 @@ func NewAllowEntry(requestID, clientID, tenantID, action, bucket, key, sourceIP,
 // NewDenyEntry creates an audit entry for a denied request
 func NewDenyEntry(requestID, clientID, tenantID, action, bucket, key, sourceIP, userAgent, denyReason string, duration time.Duration) *Entry {
 	return &Entry{
-		Timestamp:  time.Now().UTC(),
-		RequestID:  requestID,
-		ClientID:   clientID,
-		TenantID:   tenantID,
-		Action:     action,
-		Resource:   buildResourceARN(bucket, key),
-		Bucket:     bucket,
-		Key:        key,
-		Decision:   "deny",
-		DenyReason: denyReason,
-		SourceIP:   sourceIP,
-		UserAgent:  userAgent,
-		DurationMs: duration.Milliseconds(),
+		Timestamp:    time.Now().UTC(),
+		RequestID:    requestID,
+		ClientID:     clientID,
+		TenantID:     tenantID,
+		Action:       action,
+		Resource:     buildResourceARN(bucket, key),
+		Bucket:       bucket,
+		Key:          key,
+		Decision:     "deny",
+		DenyReason:   denyReason,
+		DenyCategory: gwerrors.DenyReason(denyReason).Category(),
+		SourceIP:     sourceIP,
+		UserAgent:    userAgent,
+		DurationMs:   duration.Milliseconds(),
 	}
 }
 
@@ -3,6 +3,7 @@ package audit
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -184,6 +185,29 @@ func TestJSONLogger_Log(t *testing.T) {
 	}
 }
 
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestJSONLogger_Log_WriterFailure(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := &JSONLogger{
+		enabled: true,
+		writers: []io.Writer{failingWriter{}, &buf},
+	}
+
+	err := logger.Log(&Entry{RequestID: "req-123"})
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("expected write error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "req-123") {
+		t.Error("expected later writers to receive the entry despite the failure")
+	}
+}
+
 func TestJSONLogger_Log_Disabled(t *testing.T) {
 	var buf bytes.Buffer
 
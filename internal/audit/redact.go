@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/redact"
+)
+
+// RedactingLogger redacts sensitive object keys before passing entries to
+// another Logger, so no sink sees them. Hash chains cover the redacted
+// entries.
+type RedactingLogger struct {
+	next     Logger
+	redactor *redact.Redactor
+}
+
+// NewRedactingLogger wraps next with redactor
+func NewRedactingLogger(next Logger, redactor *redact.Redactor) *RedactingLogger {
+	return &RedactingLogger{next: next, redactor: redactor}
+}
+
+// Log passes on a copy of the entry with its key redacted; the caller's
+// entry is unchanged
+func (l *RedactingLogger) Log(entry *Entry) error {
+	key := l.redactor.Key(entry.Bucket, entry.Key)
+	if key == entry.Key {
+		return l.next.Log(entry)
+	}
+	redacted := *entry
+	redacted.Key = key
+	redacted.Resource = l.redactor.Resource(entry.Resource)
+	// Errors may quote the key
+	redacted.ErrorMsg = strings.ReplaceAll(entry.ErrorMsg, entry.Key, key)
+	return l.next.Log(&redacted)
+}
+
+// Close closes the wrapped logger
+func (l *RedactingLogger) Close() error {
+	return l.next.Close()
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/redact"
+)
+
+func TestRedactingLogger(t *testing.T) {
+	next := &countingLogger{}
+	logger := NewRedactingLogger(next, redact.New(&config.RedactionConfig{
+		Keys: []config.KeyRedactionRule{{Pattern: "*/pii/*", Mode: "hash"}},
+	}))
+
+	entry := NewAllowEntry("req-1", "app", "tenant", "s3:GetObject", "data", "pii/alice.csv", "127.0.0.1", "test", 0, 200)
+	entry.ErrorMsg = "NoSuchKey: pii/alice.csv"
+	logger.Log(entry)
+	logger.Log(NewAllowEntry("req-2", "app", "tenant", "s3:GetObject", "data", "public/logo.png", "127.0.0.1", "test", 0, 200))
+
+	got := next.entries[0]
+	for _, field := range []string{got.Key, got.Resource, got.ErrorMsg} {
+		if strings.Contains(field, "alice") {
+			t.Errorf("key not redacted: %+v", got)
+		}
+	}
+	if entry.Key != "pii/alice.csv" {
+		t.Errorf("caller's entry was changed: %+v", entry)
+	}
+	if next.entries[1].Key != "public/logo.png" {
+		t.Errorf("unmatched key redacted: %+v", next.entries[1])
+	}
+}
@@ -0,0 +1,251 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+// segmentTimeFormat suffixes rotated log files, so that their names sort by
+// time
+const segmentTimeFormat = "20060102T150405Z"
+
+// purgeBatchSize bounds the database entries archived in one object
+const purgeBatchSize = 10000
+
+var (
+	purgedSegments = metrics.NewCounter("gateway_audit_purged_segments_total",
+		"Rotated audit log files deleted by the retention policy")
+	purgedEntries = metrics.NewCounter("gateway_audit_purged_entries_total",
+		"Audit database entries deleted by the retention policy")
+	purgeFailures = metrics.NewCounter("gateway_audit_purge_failures_total",
+		"Audit retention runs that failed to archive or delete expired entries")
+)
+
+// Segment is a rotated audit log file
+type Segment struct {
+	Path      string
+	RotatedAt time.Time // every entry of the segment was written before
+	Size      int64
+}
+
+// Segments returns the segments rotated from the log file at path, oldest
+// first
+func Segments(path string) ([]Segment, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var segments []Segment
+	for _, m := range matches {
+		rotated, err := time.Parse(segmentTimeFormat, strings.TrimPrefix(m, path+"."))
+		if err != nil {
+			continue // not a segment, e.g. an editor backup
+		}
+		info, err := os.Stat(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log segment: %w", err)
+		}
+		segments = append(segments, Segment{Path: m, RotatedAt: rotated, Size: info.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].RotatedAt.Before(segments[j].RotatedAt) })
+	return segments, nil
+}
+
+// lastSegmentHash returns the hash of the last entry of the newest segment
+// rotated from path, or an empty string without segments
+func lastSegmentHash(path string) (string, error) {
+	segments, err := Segments(path)
+	if err != nil || len(segments) == 0 {
+		return "", err
+	}
+	return lastHash(segments[len(segments)-1].Path)
+}
+
+// ArchiveFunc stores expired audit entries, in JSON lines format, as the
+// object name before they are deleted
+type ArchiveFunc func(ctx context.Context, name string, body io.Reader, size int64) error
+
+// Purger enforces the audit retention policy on the log file and database
+type Purger struct {
+	cfg     config.AuditRetentionConfig
+	file    *JSONLogger // nil without a file sink
+	store   *SQLStore   // nil without a database
+	archive ArchiveFunc // nil to delete without archiving
+	now     func() time.Time
+}
+
+// NewPurger creates a purger for the log file of file and the entries of
+// store, either of which may be nil
+func NewPurger(cfg *config.AuditRetentionConfig, file *JSONLogger, store *SQLStore, archive ArchiveFunc) *Purger {
+	if file != nil && file.path == "" {
+		file = nil
+	}
+	return &Purger{cfg: *cfg, file: file, store: store, archive: archive, now: time.Now}
+}
+
+// Purge rotates the log file and removes segments and database entries
+// beyond the retention limits. Entries that cannot be archived are kept for
+// the next run.
+func (p *Purger) Purge(ctx context.Context) error {
+	var errs []error
+	if p.file != nil {
+		if err := p.purgeFile(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.store != nil {
+		if err := p.purgeDatabase(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run purges every interval until stop is closed
+func (p *Purger) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := p.Purge(context.Background()); err != nil {
+			purgeFailures.Inc()
+			log.Printf("Failed to purge audit entries: %v", err)
+		}
+	}
+}
+
+// cutoff returns the time entries must be newer than to be kept by age
+func (p *Purger) cutoff() time.Time {
+	if p.cfg.MaxAgeDays <= 0 {
+		return time.Time{}
+	}
+	return p.now().AddDate(0, 0, -p.cfg.MaxAgeDays)
+}
+
+func (p *Purger) purgeFile(ctx context.Context) error {
+	if err := p.file.Rotate(p.now()); err != nil {
+		return err
+	}
+	segments, err := Segments(p.file.path)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, s := range segments {
+		total += s.Size
+	}
+
+	cutoff := p.cutoff()
+	for _, s := range segments {
+		overSize := p.cfg.MaxSize > 0 && total > p.cfg.MaxSize
+		if !overSize && !s.RotatedAt.Before(cutoff) {
+			break
+		}
+		if err := p.archiveFile(ctx, s); err != nil {
+			return err
+		}
+		if err := os.Remove(s.Path); err != nil {
+			return fmt.Errorf("failed to delete audit log segment: %w", err)
+		}
+		purgedSegments.Inc()
+		total -= s.Size
+		log.Printf("Purged audit log segment %s", s.Path)
+	}
+	return nil
+}
+
+func (p *Purger) archiveFile(ctx context.Context, s Segment) error {
+	if p.archive == nil {
+		return nil
+	}
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return fmt.Errorf("failed to archive audit log segment: %w", err)
+	}
+	defer f.Close()
+	if err := p.archive(ctx, filepath.Base(s.Path), f, s.Size); err != nil {
+		return fmt.Errorf("failed to archive audit log segment %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+func (p *Purger) purgeDatabase(ctx context.Context) error {
+	cutoff := p.cutoff()
+	if p.cfg.MaxEntries > 0 {
+		newest, err := p.store.oldestKept(ctx, p.cfg.MaxEntries)
+		if err != nil {
+			return err
+		}
+		if newest.After(cutoff) {
+			cutoff = newest
+		}
+	}
+	if cutoff.IsZero() {
+		return nil
+	}
+
+	for {
+		n, err := p.purgeBatch(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		if n < purgeBatchSize {
+			return nil
+		}
+	}
+}
+
+// purgeBatch archives and deletes up to purgeBatchSize entries older than
+// cutoff, oldest first, returning how many it read
+func (p *Purger) purgeBatch(ctx context.Context, cutoff time.Time) (int, error) {
+	if p.archive == nil {
+		n, err := p.store.deleteBefore(ctx, cutoff)
+		purgedEntries.Add(float64(n))
+		return 0, err
+	}
+
+	entries, err := p.store.oldest(ctx, cutoff, purgeBatchSize)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	for _, e := range entries {
+		buf.WriteString(e.entry)
+		buf.WriteByte('\n')
+	}
+	first, last := entries[0].ts, entries[len(entries)-1].ts
+	name := fmt.Sprintf("%s-%s.jsonl", p.store.table, time.Unix(0, first).UTC().Format(segmentTimeFormat))
+	if err := p.archive(ctx, name, bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		return 0, fmt.Errorf("failed to archive audit entries: %w", err)
+	}
+
+	// A full batch may stop among entries with the same timestamp, so only
+	// entries older than its last are known to be archived, unless the whole
+	// batch shares one timestamp
+	end := cutoff
+	if len(entries) == purgeBatchSize {
+		end = time.Unix(0, last)
+		if last == first {
+			end = time.Unix(0, last+1)
+		}
+	}
+	n, err := p.store.deleteBefore(ctx, end)
+	purgedEntries.Add(float64(n))
+	return len(entries), err
+}
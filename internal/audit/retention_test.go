@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestPurger_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cfg := &config.AuditConfig{
+		Enabled:   true,
+		Output:    "file",
+		FilePath:  path,
+		Integrity: config.AuditIntegrityConfig{Enabled: true},
+	}
+	logger, err := NewLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	log := func(n int) {
+		for i := 0; i < n; i++ {
+			logger.Log(NewAllowEntry("req", "client", "tenant", "s3:GetObject", "bucket", "key", "127.0.0.1", "test", 0, 200))
+		}
+	}
+
+	archived := map[string]string{}
+	var archiveErr error
+	archive := func(ctx context.Context, name string, body io.Reader, size int64) error {
+		if archiveErr != nil {
+			return archiveErr
+		}
+		data, _ := io.ReadAll(body)
+		if int64(len(data)) != size {
+			t.Errorf("archive %s: size = %d, body has %d bytes", name, size, len(data))
+		}
+		archived[name] = string(data)
+		return nil
+	}
+	p := NewPurger(&config.AuditRetentionConfig{MaxAgeDays: 1}, logger, nil, archive)
+	start := time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC)
+	purgeAt := func(at time.Time) {
+		t.Helper()
+		p.now = func() time.Time { return at }
+		if err := p.Purge(context.Background()); err != nil {
+			t.Fatalf("purge at %s: %v", at, err)
+		}
+	}
+
+	log(2)
+	purgeAt(start)
+	log(1)
+	purgeAt(start.Add(time.Hour))
+	purgeAt(start.Add(2 * time.Hour)) // nothing logged, nothing rotated
+	segments, _ := Segments(path)
+	if len(segments) != 2 {
+		t.Fatalf("segments = %+v, want 2", segments)
+	}
+
+	// The chain continues across segments, and into the file of a restarted
+	// gateway
+	logger.Close()
+	if logger, err = NewLogger(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	p.file = logger
+	log(1)
+	var head string
+	for _, file := range []string{segments[0].Path, segments[1].Path, path} {
+		f, _ := os.Open(file)
+		res, err := VerifyAfter(f, nil, head, "")
+		f.Close()
+		if err != nil {
+			t.Fatalf("verify %s: %v", file, err)
+		}
+		head = res.Head
+	}
+
+	archiveErr = errors.New("unavailable")
+	p.now = func() time.Time { return start.Add(25 * time.Hour) }
+	if err := p.Purge(context.Background()); err == nil {
+		t.Fatal("expected archive failure to fail the purge")
+	}
+	if _, err := os.Stat(segments[0].Path); err != nil {
+		t.Fatalf("segment deleted without being archived: %v", err)
+	}
+
+	archiveErr = nil
+	purgeAt(start.Add(25 * time.Hour))
+	if _, err := os.Stat(segments[0].Path); !os.IsNotExist(err) {
+		t.Errorf("expired segment kept: %v", err)
+	}
+	if _, err := os.Stat(segments[1].Path); err != nil {
+		t.Errorf("current segment deleted: %v", err)
+	}
+	if _, ok := archived[filepath.Base(segments[0].Path)]; !ok || len(archived) != 1 {
+		t.Errorf("archived = %v", archived)
+	}
+
+	p.cfg = config.AuditRetentionConfig{MaxSize: 1}
+	purgeAt(start.Add(26 * time.Hour))
+	if segments, _ := Segments(path); len(segments) != 0 {
+		t.Errorf("segments over maxSize kept: %+v", segments)
+	}
+}
@@ -0,0 +1,255 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// DefaultQueryLimit and MaxQueryLimit bound the entries returned by Query
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// Query selects audit entries; empty fields match everything
+type Query struct {
+	ClientID string
+	TenantID string
+	Decision string
+	Action   string
+	Bucket   string
+	From     time.Time // inclusive
+	To       time.Time // exclusive
+	Limit    int
+}
+
+// SQLStore records audit entries in a database/sql database and answers
+// queries over them. The pgx (PostgreSQL) driver is always linked, and
+// sqlite (also as sqlite3) in cgo builds.
+type SQLStore struct {
+	db       *sql.DB
+	table    string
+	numbered bool // $1 placeholders (PostgreSQL) instead of ?
+}
+
+// OpenSQLStore connects to the database in cfg and creates the table and
+// indexes if they do not exist
+func OpenSQLStore(ctx context.Context, cfg *config.AuditDatabaseConfig) (*SQLStore, error) {
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to audit database: %w", err)
+	}
+
+	s := NewSQLStore(db, cfg.Driver, cfg.Table)
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSQLStore wraps an open database. Table must be a trusted identifier.
+func NewSQLStore(db *sql.DB, driver, table string) *SQLStore {
+	return &SQLStore{
+		db:       db,
+		table:    table,
+		numbered: driver == "postgres" || driver == "pgx",
+	}
+}
+
+func (s *SQLStore) migrate(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+			ts BIGINT NOT NULL,
+			request_id VARCHAR(128) NOT NULL,
+			client_id VARCHAR(255) NOT NULL,
+			tenant_id VARCHAR(255) NOT NULL,
+			action VARCHAR(64) NOT NULL,
+			bucket VARCHAR(255) NOT NULL,
+			decision VARCHAR(8) NOT NULL,
+			status_code INTEGER NOT NULL,
+			entry TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_client_ts ON ` + s.table + ` (client_id, ts)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_ts ON ` + s.table + ` (ts)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create audit table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Log inserts an audit entry
+func (s *SQLStore) Log(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	stmt := `INSERT INTO ` + s.table +
+		` (ts, request_id, client_id, tenant_id, action, bucket, decision, status_code, entry) VALUES (` +
+		s.placeholders(1, 9) + `)`
+	_, err = s.db.Exec(stmt, entry.Timestamp.UnixNano(), entry.RequestID, entry.ClientID, entry.TenantID,
+		entry.Action, entry.Bucket, entry.Decision, entry.StatusCode, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query returns matching entries, newest first
+func (s *SQLStore) Query(ctx context.Context, q Query) ([]*Entry, error) {
+	stmt, args := s.queryStatement(q)
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*Entry{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read audit entry: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse audit entry: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *SQLStore) queryStatement(q Query) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, cond+" "+s.placeholder(len(args)))
+	}
+	for _, f := range []struct{ column, value string }{
+		{"client_id", q.ClientID},
+		{"tenant_id", q.TenantID},
+		{"decision", q.Decision},
+		{"action", q.Action},
+		{"bucket", q.Bucket},
+	} {
+		if f.value != "" {
+			add(f.column+" =", f.value)
+		}
+	}
+	if !q.From.IsZero() {
+		add("ts >=", q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		add("ts <", q.To.UnixNano())
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+
+	stmt := `SELECT entry FROM ` + s.table
+	if len(where) > 0 {
+		stmt += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	stmt += ` ORDER BY ts DESC LIMIT ` + strconv.Itoa(limit)
+	return stmt, args
+}
+
+// storedEntry is a row of the audit table
+type storedEntry struct {
+	ts    int64
+	entry string
+}
+
+// oldestKept returns the timestamp of the oldest of the newest keep
+// entries, or the zero time if there are no more than keep
+func (s *SQLStore) oldestKept(ctx context.Context, keep int64) (time.Time, error) {
+	stmt := `SELECT ts FROM ` + s.table + ` ORDER BY ts DESC LIMIT 1 OFFSET ` + strconv.FormatInt(keep-1, 10)
+	var ts int64
+	err := s.db.QueryRowContext(ctx, stmt).Scan(&ts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+	return time.Unix(0, ts), nil
+}
+
+// oldest returns up to limit entries older than before, oldest first
+func (s *SQLStore) oldest(ctx context.Context, before time.Time, limit int) ([]storedEntry, error) {
+	stmt := `SELECT ts, entry FROM ` + s.table + ` WHERE ts < ` + s.placeholder(1) +
+		` ORDER BY ts LIMIT ` + strconv.Itoa(limit)
+	rows, err := s.db.QueryContext(ctx, stmt, before.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expired audit entries: %w", err)
+	}
+	defer rows.Close()
+	var entries []storedEntry
+	for rows.Next() {
+		var e storedEntry
+		if err := rows.Scan(&e.ts, &e.entry); err != nil {
+			return nil, fmt.Errorf("failed to read expired audit entries: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read expired audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// deleteBefore deletes the entries older than before
+func (s *SQLStore) deleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE ts < `+s.placeholder(1), before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired audit entries: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+func (s *SQLStore) placeholder(n int) string {
+	if s.numbered {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// placeholders returns a comma separated list of placeholders first..last
+func (s *SQLStore) placeholders(first, last int) string {
+	list := make([]string, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		list = append(list, s.placeholder(n))
+	}
+	return strings.Join(list, ", ")
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,97 @@
+//go:build cgo
+
+package audit
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestSQLStore_SQLite(t *testing.T) {
+	ctx := context.Background()
+	store, err := OpenSQLStore(ctx, &config.AuditDatabaseConfig{
+		Enabled: true,
+		Driver:  "sqlite",
+		DSN:     filepath.Join(t.TempDir(), "audit.db"),
+		Table:   "audit_entries",
+	})
+	if err != nil {
+		t.Fatalf("OpenSQLStore: %v", err)
+	}
+	defer store.Close()
+
+	start := time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC)
+	entries := []*Entry{
+		NewAllowEntry("r1", "app", "tenant", "s3:GetObject", "bucket", "a", "127.0.0.1", "test", 0, 200),
+		NewDenyEntry("r2", "app", "tenant", "s3:PutObject", "bucket", "b", "127.0.0.1", "test", "DENY_POLICY", 0),
+		NewAllowEntry("r3", "other", "tenant", "s3:GetObject", "bucket", "c", "127.0.0.1", "test", 0, 200),
+		NewAllowEntry("r4", "app", "tenant", "s3:GetObject", "bucket", "d", "127.0.0.1", "test", 0, 200),
+	}
+	for i, e := range entries {
+		e.Timestamp = start.Add(time.Duration(i) * 24 * time.Hour)
+		if err := store.Log(e); err != nil {
+			t.Fatalf("Log: %v", err)
+		}
+	}
+
+	requestIDs := func(q Query) []string {
+		t.Helper()
+		got, err := store.Query(ctx, q)
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		var ids []string
+		for _, e := range got {
+			ids = append(ids, e.RequestID)
+		}
+		return ids
+	}
+	for _, tt := range []struct {
+		query Query
+		want  string
+	}{
+		{Query{}, "r4,r3,r2,r1"},
+		{Query{ClientID: "app", Decision: "allow"}, "r4,r1"},
+		{Query{From: start.Add(24 * time.Hour), To: start.Add(72 * time.Hour)}, "r3,r2"},
+		{Query{Limit: 1}, "r4"},
+	} {
+		if got := strings.Join(requestIDs(tt.query), ","); got != tt.want {
+			t.Errorf("Query(%+v) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+
+	// Retention keeps the newest two entries, archiving the others
+	var archived string
+	archive := func(ctx context.Context, name string, body io.Reader, size int64) error {
+		data, _ := io.ReadAll(body)
+		archived += string(data)
+		return nil
+	}
+	p := NewPurger(&config.AuditRetentionConfig{MaxEntries: 2}, nil, store, archive)
+	if err := p.Purge(ctx); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if got := strings.Join(requestIDs(Query{}), ","); got != "r4,r3" {
+		t.Errorf("after purge = %s, want r4,r3", got)
+	}
+	if !strings.Contains(archived, `"requestId":"r1"`) || !strings.Contains(archived, `"requestId":"r2"`) ||
+		strings.Contains(archived, `"requestId":"r3"`) {
+		t.Errorf("archived = %s, want r1 and r2", archived)
+	}
+
+	// And by age without an archive
+	p = NewPurger(&config.AuditRetentionConfig{MaxAgeDays: 1}, nil, store, nil)
+	p.now = func() time.Time { return start.Add(4 * 24 * time.Hour) }
+	if err := p.Purge(ctx); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if got := strings.Join(requestIDs(Query{}), ","); got != "r4" {
+		t.Errorf("after purge by age = %s, want r4", got)
+	}
+}
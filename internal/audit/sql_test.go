@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSQLStore_QueryStatement(t *testing.T) {
+	from := time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		driver   string
+		query    Query
+		wantStmt string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "no filters",
+			driver:   "sqlite",
+			wantStmt: "SELECT entry FROM audit ORDER BY ts DESC LIMIT 100",
+		},
+		{
+			name:     "question mark placeholders",
+			driver:   "sqlite",
+			query:    Query{ClientID: "app", Decision: "deny", From: from, Limit: 10},
+			wantStmt: "SELECT entry FROM audit WHERE client_id = ? AND decision = ? AND ts >= ? ORDER BY ts DESC LIMIT 10",
+			wantArgs: []interface{}{"app", "deny", from.UnixNano()},
+		},
+		{
+			name:     "numbered placeholders",
+			driver:   "pgx",
+			query:    Query{TenantID: "t1", Bucket: "data", To: from, Limit: 5000},
+			wantStmt: "SELECT entry FROM audit WHERE tenant_id = $1 AND bucket = $2 AND ts < $3 ORDER BY ts DESC LIMIT 1000",
+			wantArgs: []interface{}{"t1", "data", from.UnixNano()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSQLStore(nil, tt.driver, "audit")
+			stmt, args := s.queryStatement(tt.query)
+			if stmt != tt.wantStmt {
+				t.Errorf("statement = %q, want %q", stmt, tt.wantStmt)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
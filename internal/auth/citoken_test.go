@@ -0,0 +1,43 @@
+package auth
+
+import (
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+func TestCIToken_IssueAndVerify(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	creds := IssueCICredentials(key, CIToken{Role: "deploy", Subject: "repo:myorg/app:ref:refs/heads/main",
+		Bucket: "artifacts", Prefix: "ci/myorg/app/", Expires: now.Add(time.Hour)})
+
+	token, signer, err := VerifyCIToken(key, creds.SessionToken, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Role != "deploy" || token.Bucket != "artifacts" || token.Prefix != "ci/myorg/app/" {
+		t.Errorf("token = %+v", token)
+	}
+	if signer.AccessKey != creds.AccessKeyID || signer.SecretKey != creds.SecretAccessKey {
+		t.Errorf("signing credential = %+v, want the issued keys", signer)
+	}
+
+	tampered := "e30" + creds.SessionToken[3:]
+	for name, check := range map[string]func() error{
+		"expired":   func() error { _, _, err := VerifyCIToken(key, creds.SessionToken, now.Add(time.Hour)); return err },
+		"other key": func() error { _, _, err := VerifyCIToken([]byte("another-key"), creds.SessionToken, now); return err },
+		"tampered":  func() error { _, _, err := VerifyCIToken(key, tampered, now); return err },
+		"not a token": func() error {
+			_, _, err := VerifyCIToken(key, "token", now)
+			return err
+		},
+	} {
+		var denied *errors.AccessDeniedError
+		if err := check(); !stderrors.As(err, &denied) || denied.Reason != errors.DenyInvalidSession {
+			t.Errorf("%s: err = %v, want %s", name, err, errors.DenyInvalidSession)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/s3-access-control-adapter/internal/config"
@@ -14,8 +15,12 @@ type Credential struct {
 	ClientID    string
 	TenantID    string
 	Description string
-	Policies    []string
-	Scopes      []string // Allowed bucket/prefix patterns for tenant boundary check
+	Policies    []string // including those of its groups
+	Scopes      []string // Allowed bucket/prefix patterns for tenant boundary check, including those of its groups
+	Regions     []string // Accepted signing regions; empty uses the validator's
+	Groups      []string
+	// AllowedActions are granted within Scopes before policies are evaluated
+	AllowedActions []string
 }
 
 // CredentialStore provides access to client credentials
@@ -30,6 +35,7 @@ type CredentialStore interface {
 type InMemoryCredentialStore struct {
 	mu          sync.RWMutex
 	credentials map[string]*Credential
+	fingerprint string
 	configPath  string
 }
 
@@ -60,15 +66,55 @@ func (s *InMemoryCredentialStore) GetCredential(accessKey string) (*Credential,
 	return cred, nil
 }
 
+// Credentials returns all loaded credentials sorted by client ID
+func (s *InMemoryCredentialStore) Credentials() []*Credential {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	creds := make([]*Credential, 0, len(s.credentials))
+	for _, c := range s.credentials {
+		creds = append(creds, c)
+	}
+	sort.Slice(creds, func(i, j int) bool {
+		if creds[i].ClientID != creds[j].ClientID {
+			return creds[i].ClientID < creds[j].ClientID
+		}
+		return creds[i].AccessKey < creds[j].AccessKey
+	})
+	return creds
+}
+
+// NewInMemoryCredentialStoreFromConfig creates a store from an in-memory
+// configuration, for credentials that do not come from a file
+func NewInMemoryCredentialStoreFromConfig(cfg *config.CredentialsConfig) (*InMemoryCredentialStore, error) {
+	store := &InMemoryCredentialStore{credentials: make(map[string]*Credential)}
+	if err := store.Load(cfg); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
 // Reload reloads credentials from the configuration file
 func (s *InMemoryCredentialStore) Reload() error {
+	if s.configPath == "" {
+		return nil
+	}
+
 	cfg, err := config.LoadCredentials(s.configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load credentials: %w", err)
 	}
+	return s.Load(cfg)
+}
+
+// Load validates and atomically replaces the loaded credentials
+func (s *InMemoryCredentialStore) Load(cfg *config.CredentialsConfig) error {
+	if err := config.ValidateCredentials(cfg); err != nil {
+		return err
+	}
 
 	newCreds := make(map[string]*Credential, len(cfg.Credentials))
-	for _, c := range cfg.Credentials {
+	for _, c := range config.ResolveCredentials(cfg) {
 		newCreds[c.AccessKey] = &Credential{
 			AccessKey:   c.AccessKey,
 			SecretKey:   c.SecretKey,
@@ -77,12 +123,27 @@ func (s *InMemoryCredentialStore) Reload() error {
 			Description: c.Description,
 			Policies:    c.Policies,
 			Scopes:      c.Scopes,
+			Regions:     c.Regions,
+			Groups:      c.Groups,
+
+			AllowedActions: c.AllowedActions,
 		}
 	}
 
+	fingerprint := config.Fingerprint(cfg)
+
 	s.mu.Lock()
 	s.credentials = newCreds
+	s.fingerprint = fingerprint
 	s.mu.Unlock()
 
 	return nil
 }
+
+// Fingerprint identifies the loaded credentials, secrets included, so a
+// rotated key changes it; it is empty before the first load
+func (s *InMemoryCredentialStore) Fingerprint() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fingerprint
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// accessKeyRandomBytes gives 16 base32 characters (80 bits) after the prefix
+const accessKeyRandomBytes = 10
+
+// maxKeygenAttempts bounds retries when generated keys collide
+const maxKeygenAttempts = 10
+
+// GenerateAccessKey returns a new access key ID made of prefix and random
+// uppercase characters. Keys that exists reports as taken, or that look like
+// AWS key IDs, are regenerated.
+func GenerateAccessKey(prefix string, exists func(string) bool) (string, error) {
+	buf := make([]byte, accessKeyRandomBytes)
+	for i := 0; i < maxKeygenAttempts; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to generate access key: %w", err)
+		}
+		key := prefix + base32.StdEncoding.EncodeToString(buf)
+		if !config.LooksLikeAWSKeyID(key) && (exists == nil || !exists(key)) {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate an unused access key with prefix %q", prefix)
+}
+
+// GenerateSecretKey returns a random 40-character secret key
+func GenerateSecretKey() (string, error) {
+	buf := make([]byte, 30)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
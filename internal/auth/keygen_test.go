@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGenerateAccessKey(t *testing.T) {
+	key, err := GenerateAccessKey("GWTENANT001", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !regexp.MustCompile(`^GWTENANT001[A-Z2-7]{16}$`).MatchString(key) {
+		t.Errorf("key %q does not match the expected format", key)
+	}
+
+	calls := 0
+	key, err = GenerateAccessKey("GW", func(k string) bool {
+		calls++
+		return calls == 1
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("expected a retry after a collision, got key %q, %d calls, %v", key, calls, err)
+	}
+
+	if _, err := GenerateAccessKey("AKIA", nil); err == nil {
+		t.Error("expected error for a prefix producing AWS-shaped keys")
+	}
+
+	secret, err := GenerateSecretKey()
+	if err != nil || len(secret) != 40 {
+		t.Errorf("secret = %q (%d chars), %v; want 40 chars", secret, len(secret), err)
+	}
+}
@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+// Special x-amz-content-sha256 values. Any other value is the hex SHA-256
+// of the body.
+const (
+	UnsignedPayload                 = "UNSIGNED-PAYLOAD"
+	StreamingSignedPayload          = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingSignedPayloadTrailer   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	StreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+// emptySHA256 is the hash of an empty string, part of each chunk's string to sign
+var emptySHA256 = hashSHA256(nil)
+
+// maxChunkLine bounds a chunk header or trailer line
+const maxChunkLine = 4096
+
+// preparePayload replaces the body of an authenticated request so the
+// payload is checked as it is read, following x-amz-content-sha256:
+// hex hashes are verified at the end of the body, aws-chunked bodies are
+// decoded with chunk signatures and trailing checksums verified, and
+// UNSIGNED-PAYLOAD is passed through. Failures surface as read errors, so an
+// upload never completes with a payload that does not match.
+func preparePayload(req *http.Request, signingKey []byte, scope, amzDate, seedSignature string) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	mode := req.Header.Get("X-Amz-Content-Sha256")
+	switch mode {
+	case "", UnsignedPayload:
+		return
+	case StreamingSignedPayload, StreamingSignedPayloadTrailer, StreamingUnsignedPayloadTrailer:
+	default:
+		req.Body = &hashVerifier{body: req.Body, hash: sha256.New(), want: mode}
+		return
+	}
+
+	cr := &chunkedReader{
+		body:    req.Body,
+		br:      bufio.NewReaderSize(req.Body, maxChunkLine),
+		trailer: mode != StreamingSignedPayload,
+	}
+	if mode != StreamingUnsignedPayloadTrailer {
+		cr.signer = &chunkSigner{key: signingKey, scope: scope, amzDate: amzDate, prev: seedSignature, hash: sha256.New()}
+	}
+	if name := strings.ToLower(strings.TrimSpace(req.Header.Get("X-Amz-Trailer"))); name != "" {
+		cr.checksumName, cr.checksum = name, newChecksum(name)
+	}
+	req.Body = cr
+
+	// Backends see the decoded object
+	req.ContentLength = -1
+	if n, err := strconv.ParseInt(req.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64); err == nil && n >= 0 {
+		req.ContentLength = n
+	}
+	var encodings []string
+	for _, e := range strings.Split(req.Header.Get("Content-Encoding"), ",") {
+		if e = strings.TrimSpace(e); e != "" && e != "aws-chunked" {
+			encodings = append(encodings, e)
+		}
+	}
+	if len(encodings) > 0 {
+		req.Header.Set("Content-Encoding", strings.Join(encodings, ","))
+	} else {
+		req.Header.Del("Content-Encoding")
+	}
+}
+
+// newChecksum returns the hash for a trailing x-amz-checksum-* header, or nil
+// for algorithms that are not verified
+func newChecksum(name string) hash.Hash {
+	switch name {
+	case "x-amz-checksum-crc32":
+		return crc32.NewIEEE()
+	case "x-amz-checksum-crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "x-amz-checksum-sha1":
+		return sha1.New()
+	case "x-amz-checksum-sha256":
+		return sha256.New()
+	}
+	return nil
+}
+
+// hashVerifier fails the final read of a body whose SHA-256 differs from
+// the signed x-amz-content-sha256
+type hashVerifier struct {
+	body io.ReadCloser
+	hash hash.Hash
+	want string
+}
+
+func (v *hashVerifier) Read(p []byte) (int, error) {
+	n, err := v.body.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(v.hash.Sum(nil)) != v.want {
+		return n, errors.ErrXAmzContentSHA256Mismatch
+	}
+	return n, err
+}
+
+func (v *hashVerifier) Close() error { return v.body.Close() }
+
+// Unwrap returns the underlying body
+func (v *hashVerifier) Unwrap() io.ReadCloser { return v.body }
+
+// chunkSigner verifies the signature chain of a signed aws-chunked body
+type chunkSigner struct {
+	key     []byte
+	scope   string
+	amzDate string
+	prev    string // previous chunk's signature, starting with the seed
+	hash    hash.Hash
+}
+
+// verify checks the signature of the chunk whose data went into s.hash
+func (s *chunkSigner) verify(signature string) error {
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD", s.amzDate, s.scope, s.prev, emptySHA256, hex.EncodeToString(s.hash.Sum(nil)),
+	}, "\n")
+	return s.check(stringToSign, signature)
+}
+
+// verifyTrailer checks the signature over the canonical trailing headers
+func (s *chunkSigner) verifyTrailer(trailer, signature string) error {
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-TRAILER", s.amzDate, s.scope, s.prev, hashSHA256([]byte(trailer)),
+	}, "\n")
+	return s.check(stringToSign, signature)
+}
+
+func (s *chunkSigner) check(stringToSign, signature string) error {
+	expected := hex.EncodeToString(hmacSHA256(s.key, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.ErrSignatureDoesNotMatch
+	}
+	s.prev = signature
+	s.hash.Reset()
+	return nil
+}
+
+// chunkedReader decodes an aws-chunked body:
+//
+//	<hex size>[;chunk-signature=<sig>]\r\n<data>\r\n ... 0[;chunk-signature=<sig>]\r\n
+//
+// followed by \r\n, or by trailing headers and \r\n in trailer modes
+type chunkedReader struct {
+	body    io.ReadCloser
+	br      *bufio.Reader
+	signer  *chunkSigner // nil for unsigned chunks
+	trailer bool
+
+	checksumName string
+	checksum     hash.Hash // nil when no trailing checksum is verified
+
+	remaining int64 // data left in the current chunk
+	inChunk   bool
+	signature string // current chunk's signature
+	err       error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	for c.remaining == 0 {
+		if err := c.nextChunk(); err != nil {
+			c.err = err
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	c.remaining -= int64(n)
+	if c.signer != nil {
+		c.signer.hash.Write(p[:n])
+	}
+	if c.checksum != nil {
+		c.checksum.Write(p[:n])
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+func (c *chunkedReader) Close() error { return c.body.Close() }
+
+// Unwrap returns the underlying body
+func (c *chunkedReader) Unwrap() io.ReadCloser { return c.body }
+
+// nextChunk finishes the current chunk and reads the next chunk header. It
+// returns io.EOF after the final chunk and trailer have been verified.
+func (c *chunkedReader) nextChunk() error {
+	if c.inChunk {
+		if err := c.expectCRLF(); err != nil {
+			return err
+		}
+		if err := c.verifyChunk(); err != nil {
+			return err
+		}
+		c.inChunk = false
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeHex, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 {
+		return errors.ErrIncompleteBody.WithMessage("Malformed aws-chunked encoding")
+	}
+	if c.signer != nil {
+		sig, ok := strings.CutPrefix(ext, "chunk-signature=")
+		if !ok {
+			return errors.ErrSignatureDoesNotMatch.WithMessage("Chunk signature is missing")
+		}
+		c.signature = sig
+	}
+	if size > 0 {
+		c.remaining, c.inChunk = size, true
+		return nil
+	}
+
+	// The final, empty chunk is signed too
+	if err := c.verifyChunk(); err != nil {
+		return err
+	}
+	if c.trailer {
+		if err := c.readTrailer(); err != nil {
+			return err
+		}
+	} else if err := c.expectCRLF(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func (c *chunkedReader) verifyChunk() error {
+	if c.signer == nil {
+		return nil
+	}
+	return c.signer.verify(c.signature)
+}
+
+// readTrailer reads the trailing headers, verifying the checksum announced in
+// x-amz-trailer and, for signed payloads, the trailer signature
+func (c *chunkedReader) readTrailer() error {
+	var canonical strings.Builder
+	var signature, checksum string
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return errors.ErrIncompleteBody.WithMessage("Malformed aws-chunked trailer")
+		}
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		if name == "x-amz-trailer-signature" {
+			signature = value
+			continue
+		}
+		if name == c.checksumName {
+			checksum = value
+		}
+		canonical.WriteString(name + ":" + value + "\n")
+	}
+
+	if c.signer != nil {
+		if err := c.signer.verifyTrailer(canonical.String(), signature); err != nil {
+			return err
+		}
+	}
+	if c.checksum != nil && checksum != base64.StdEncoding.EncodeToString(c.checksum.Sum(nil)) {
+		return errors.ErrBadDigest.WithMessage("The " + c.checksumName + " you specified did not match the calculated checksum.")
+	}
+	return nil
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.br.ReadSlice('\n')
+	if err == io.EOF {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", errors.ErrIncompleteBody.WithMessage("Malformed aws-chunked encoding")
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+}
+
+func (c *chunkedReader) expectCRLF() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return errors.ErrIncompleteBody.WithMessage("Chunk data is longer than its declared size")
+	}
+	return nil
+}
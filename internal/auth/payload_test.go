@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/pkg/sigv4"
+)
+
+const (
+	testAmzDate = "20240115T120000Z"
+	testScope   = "20240115/us-east-1/s3/aws4_request"
+	testSeed    = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+)
+
+var testKey = sigv4.SigningKey("secret", "20240115", "us-east-1", "s3")
+
+// signedChunks encodes data as signed aws-chunked chunks, optionally with a
+// signed trailer
+func signedChunks(chunks []string, trailer string) string {
+	var b strings.Builder
+	prev := testSeed
+	for _, c := range append(chunks, "") {
+		sts := strings.Join([]string{"AWS4-HMAC-SHA256-PAYLOAD", testAmzDate, testScope, prev, emptySHA256, hashSHA256([]byte(c))}, "\n")
+		prev = hex.EncodeToString(hmacSHA256(testKey, []byte(sts)))
+		fmt.Fprintf(&b, "%x;chunk-signature=%s\r\n", len(c), prev)
+		if c != "" {
+			b.WriteString(c + "\r\n")
+		}
+	}
+	if trailer == "" {
+		b.WriteString("\r\n")
+		return b.String()
+	}
+	sts := strings.Join([]string{"AWS4-HMAC-SHA256-TRAILER", testAmzDate, testScope, prev, hashSHA256([]byte(trailer + "\n"))}, "\n")
+	sig := hex.EncodeToString(hmacSHA256(testKey, []byte(sts)))
+	b.WriteString(trailer + "\r\nx-amz-trailer-signature:" + sig + "\r\n\r\n")
+	return b.String()
+}
+
+func crc32Base64(s string) string {
+	sum := crc32.ChecksumIEEE([]byte(s))
+	return base64.StdEncoding.EncodeToString([]byte{byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)})
+}
+
+func TestPreparePayload(t *testing.T) {
+	data := "hello, world"
+	good := crc32Base64(data)
+
+	tests := []struct {
+		name    string
+		mode    string
+		trailer string
+		body    string
+		wantErr error
+	}{
+		{"unsigned payload", UnsignedPayload, "", data, nil},
+		{"payload hash", hashSHA256([]byte(data)), "", data, nil},
+		{"payload hash mismatch", hashSHA256([]byte("other")), "", data, errors.ErrXAmzContentSHA256Mismatch},
+		{"unsigned trailer", StreamingUnsignedPayloadTrailer, "x-amz-checksum-crc32",
+			"5\r\nhello\r\n7\r\n, world\r\n0\r\nx-amz-checksum-crc32:" + good + "\r\n\r\n", nil},
+		{"unsigned trailer bad checksum", StreamingUnsignedPayloadTrailer, "x-amz-checksum-crc32",
+			"5\r\nhello\r\n7\r\n, world\r\n0\r\nx-amz-checksum-crc32:" + crc32Base64("x") + "\r\n\r\n", errors.ErrBadDigest},
+		{"signed chunks", StreamingSignedPayload, "", signedChunks([]string{"hello", ", world"}, ""), nil},
+		{"tampered chunk", StreamingSignedPayload, "",
+			strings.Replace(signedChunks([]string{"hello", ", world"}, ""), "hello", "jello", 1), errors.ErrSignatureDoesNotMatch},
+		{"signed trailer", StreamingSignedPayloadTrailer, "x-amz-checksum-crc32",
+			signedChunks([]string{"hello", ", world"}, "x-amz-checksum-crc32:"+good), nil},
+		{"oversized chunk", StreamingUnsignedPayloadTrailer, "", "2\r\nhello\r\n0\r\n\r\n", errors.ErrIncompleteBody},
+		{"truncated", StreamingUnsignedPayloadTrailer, "", "5\r\nhel", io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPut, "http://gateway/bucket/key", strings.NewReader(tt.body))
+			req.Header.Set("X-Amz-Content-Sha256", tt.mode)
+			req.Header.Set("X-Amz-Trailer", tt.trailer)
+			req.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(len(data)))
+			req.Header.Set("Content-Encoding", "aws-chunked")
+
+			preparePayload(req, testKey, testScope, testAmzDate, testSeed)
+			got, err := io.ReadAll(req.Body)
+
+			if tt.wantErr != nil {
+				var code errors.ErrorCode
+				if stderrors.As(tt.wantErr, &code) {
+					var gotCode errors.ErrorCode
+					if !stderrors.As(err, &gotCode) || gotCode.Code != code.Code {
+						t.Errorf("error = %v, want %s", err, code.Code)
+					}
+				} else if !stderrors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(got, []byte(data)) {
+				t.Errorf("body = %q, want %q", got, data)
+			}
+			if strings.HasPrefix(tt.mode, "STREAMING-") {
+				if req.ContentLength != int64(len(data)) {
+					t.Errorf("ContentLength = %d, want %d", req.ContentLength, len(data))
+				}
+				if req.Header.Get("Content-Encoding") != "" {
+					t.Errorf("Content-Encoding = %q, want aws-chunked removed", req.Header.Get("Content-Encoding"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+// SessionTokenHeader carries the token of an S3 Express session, signed for
+// with the session's keys instead of the credential's
+const SessionTokenHeader = "X-Amz-S3session-Token"
+
+// SessionTTL is how long S3 Express sessions last, as in S3
+const SessionTTL = 5 * time.Minute
+
+// Session is an S3 Express session created with CreateSession, limited to
+// one directory bucket
+type Session struct {
+	AccessKey string    `json:"k"` // of the credential that created it
+	Bucket    string    `json:"b"`
+	ReadOnly  bool      `json:"r,omitempty"`
+	Expires   time.Time `json:"e"`
+}
+
+// SessionCredentials are returned by CreateSession
+type SessionCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// IssueSession creates the credentials of a session for cred. Tokens are
+// signed with the credential's secret key and the session keys derived from
+// them, so any replica can verify them without shared state, and rotating
+// the secret key ends the sessions issued with it.
+func IssueSession(cred *Credential, s Session) SessionCredentials {
+	s.AccessKey = cred.AccessKey
+	payload, _ := json.Marshal(s) // cannot fail for Session
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	token := encoded + "." + base64.RawURLEncoding.EncodeToString(sessionMAC(cred, encoded))
+	return SessionCredentials{
+		AccessKeyID:     sessionAccessKey(token),
+		SecretAccessKey: sessionSecret(cred, token),
+		SessionToken:    token,
+		Expiration:      s.Expires,
+	}
+}
+
+// SessionCredentialKey returns the access key of the credential a session
+// token claims to be issued to, for looking it up before VerifySession
+func SessionCredentialKey(token string) string {
+	var s Session
+	encoded, _, _ := strings.Cut(token, ".")
+	if payload, err := base64.RawURLEncoding.DecodeString(encoded); err == nil {
+		json.Unmarshal(payload, &s)
+	}
+	return s.AccessKey
+}
+
+// VerifySession checks a session token issued to cred and returns the
+// session and the credential its requests are signed with: cred with the
+// session's access key and secret key
+func VerifySession(cred *Credential, token string, now time.Time) (*Session, *Credential, error) {
+	invalid := errors.NewAccessDeniedError(errors.DenyInvalidSession, "invalid session token", "", "")
+	encoded, sig, ok := strings.Cut(token, ".")
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if !ok || err != nil || !hmac.Equal(mac, sessionMAC(cred, encoded)) {
+		return nil, nil, invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, invalid
+	}
+	var s Session
+	if err := json.Unmarshal(payload, &s); err != nil || s.AccessKey != cred.AccessKey {
+		return nil, nil, invalid
+	}
+	if !now.Before(s.Expires) {
+		return nil, nil, errors.NewAccessDeniedError(errors.DenyInvalidSession, "session token expired", "", "")
+	}
+
+	sessionCred := *cred
+	sessionCred.AccessKey, sessionCred.SecretKey = sessionAccessKey(token), sessionSecret(cred, token)
+	return &s, &sessionCred, nil
+}
+
+func sessionMAC(cred *Credential, payload string) []byte {
+	return hmacSHA256([]byte(cred.SecretKey), []byte("s3express-session:"+payload))
+}
+
+// sessionAccessKey identifies a session. It differs from the credential's
+// access key, as SDKs cache signing keys by access key.
+func sessionAccessKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "S3XS" + strings.ToUpper(hex.EncodeToString(sum[:8]))
+}
+
+func sessionSecret(cred *Credential, token string) string {
+	return base64.RawStdEncoding.EncodeToString(hmacSHA256([]byte(cred.SecretKey), []byte("s3express-secret:"+token)))
+}
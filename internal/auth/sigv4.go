@@ -1,29 +1,23 @@
 package auth
 
 import (
-	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	stderrors "errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
-	"regexp"
-	"sort"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/pkg/sigv4"
 )
 
 // SigV4Components holds the parsed components of an AWS Signature V4 Authorization header
-type SigV4Components struct {
-	AccessKey     string
-	Date          string // YYYYMMDD format
-	Region        string
-	Service       string
-	SignedHeaders []string
-	Signature     string
-}
+type SigV4Components sigv4.Authorization
 
 // AuthContext represents the authenticated request context
 type AuthContext struct {
@@ -34,6 +28,14 @@ type AuthContext struct {
 	Scopes    []string
 	Timestamp time.Time
 	RequestID string
+	// Session is set for requests signed with an S3 Express session
+	Session *Session
+	// KeyPrefix limits object keys and listings, for credentials issued
+	// for one prefix
+	KeyPrefix string
+
+	// AllowedActions are granted without consulting policies
+	AllowedActions []string
 }
 
 // SignatureValidator validates AWS Signature V4 requests
@@ -45,220 +47,81 @@ type SignatureValidator interface {
 }
 
 // DefaultSignatureValidator implements SignatureValidator
-type DefaultSignatureValidator struct{}
+type DefaultSignatureValidator struct {
+	verifier *sigv4.Verifier
+	regions  []string // accepted credential scope regions; empty accepts any
+	services []string // accepted credential scope services; empty accepts any
+}
 
-// NewSignatureValidator creates a new signature validator
+// NewSignatureValidator creates a new signature validator that caches
+// derived signing keys
 func NewSignatureValidator() *DefaultSignatureValidator {
-	return &DefaultSignatureValidator{}
+	return &DefaultSignatureValidator{verifier: &sigv4.Verifier{Keys: sigv4.NewKeyCache()}}
 }
 
-// authHeaderRegex matches AWS4-HMAC-SHA256 Authorization header
-var authHeaderRegex = regexp.MustCompile(
-	`AWS4-HMAC-SHA256\s+` +
-		`Credential=([^/]+)/(\d{8})/([^/]+)/([^/]+)/aws4_request,\s*` +
-		`SignedHeaders=([^,]+),\s*` +
-		`Signature=([a-f0-9]+)`,
-)
+// NewSignatureValidatorFromConfig creates a signature validator that only
+// accepts the configured credential scope regions and services
+func NewSignatureValidatorFromConfig(cfg *config.AuthConfig) *DefaultSignatureValidator {
+	v := NewSignatureValidator()
+	v.regions, v.services = cfg.Regions, cfg.Services
+	return v
+}
 
 // ParseAuthHeader parses the AWS Signature V4 Authorization header
 func (v *DefaultSignatureValidator) ParseAuthHeader(authHeader string) (*SigV4Components, error) {
-	matches := authHeaderRegex.FindStringSubmatch(authHeader)
-	if matches == nil {
-		return nil, fmt.Errorf("invalid Authorization header format")
+	auth, err := sigv4.ParseAuthorization(authHeader)
+	if err != nil {
+		return nil, err
 	}
-
-	return &SigV4Components{
-		AccessKey:     matches[1],
-		Date:          matches[2],
-		Region:        matches[3],
-		Service:       matches[4],
-		SignedHeaders: strings.Split(matches[5], ";"),
-		Signature:     matches[6],
-	}, nil
+	return (*SigV4Components)(auth), nil
 }
 
-// ParseAndValidate parses and validates the signature
+// ParseAndValidate validates the request signature against the credential.
+// Missing headers and clock skew are reported as AccessDeniedErrors with
+// their own deny reasons.
 func (v *DefaultSignatureValidator) ParseAndValidate(req *http.Request, credential *Credential) (*SigV4Components, error) {
-	authHeader := req.Header.Get("Authorization")
-	if authHeader == "" {
-		return nil, fmt.Errorf("missing Authorization header")
-	}
-
-	components, err := v.ParseAuthHeader(authHeader)
-	if err != nil {
+	auth, err := v.verifier.Verify(req, credential.SecretKey)
+	switch {
+	case stderrors.Is(err, sigv4.ErrMissingAuthorization), stderrors.Is(err, sigv4.ErrMissingDate):
+		return nil, errors.NewAccessDeniedError(errors.DenyMissingAuthHeader, err.Error(), "", "")
+	case stderrors.Is(err, sigv4.ErrRequestTimeSkewed):
+		return nil, errors.NewAccessDeniedError(errors.DenyRequestTimeSkewed, err.Error(), "", "")
+	case err != nil:
 		return nil, err
 	}
 
 	// Validate the access key matches
-	if components.AccessKey != credential.AccessKey {
+	if auth.AccessKey != credential.AccessKey {
 		return nil, fmt.Errorf("access key mismatch")
 	}
-
-	// Get the request timestamp
-	amzDate := req.Header.Get("X-Amz-Date")
-	if amzDate == "" {
-		return nil, fmt.Errorf("missing X-Amz-Date header")
-	}
-
-	// Validate timestamp (allow 15 minute clock skew)
-	requestTime, err := time.Parse("20060102T150405Z", amzDate)
-	if err != nil {
-		return nil, fmt.Errorf("invalid X-Amz-Date format: %w", err)
-	}
-
-	now := time.Now().UTC()
-	if requestTime.Before(now.Add(-15*time.Minute)) || requestTime.After(now.Add(15*time.Minute)) {
-		return nil, fmt.Errorf("request timestamp is outside allowed window")
-	}
-
-	// Compute and verify signature
-	expectedSignature, err := v.computeSignature(req, credential.SecretKey, components, amzDate)
-	if err != nil {
-		return nil, fmt.Errorf("failed to compute signature: %w", err)
-	}
-
-	if !hmac.Equal([]byte(expectedSignature), []byte(components.Signature)) {
-		return nil, fmt.Errorf("signature mismatch")
-	}
-
-	return components, nil
-}
-
-// computeSignature computes the AWS Signature V4
-func (v *DefaultSignatureValidator) computeSignature(req *http.Request, secretKey string, components *SigV4Components, amzDate string) (string, error) {
-	// Step 1: Create canonical request
-	canonicalRequest, err := v.createCanonicalRequest(req, components)
-	if err != nil {
-		return "", err
-	}
-
-	// Step 2: Create string to sign
-	stringToSign := v.createStringToSign(amzDate, components, canonicalRequest)
-
-	// Step 3: Calculate signature
-	signature := v.calculateSignature(secretKey, components.Date, components.Region, components.Service, stringToSign)
-
-	return signature, nil
-}
-
-// createCanonicalRequest creates the canonical request string
-func (v *DefaultSignatureValidator) createCanonicalRequest(req *http.Request, components *SigV4Components) (string, error) {
-	// HTTP method
-	method := req.Method
-
-	// Canonical URI (URL-encoded path)
-	canonicalURI := req.URL.Path
-	if canonicalURI == "" {
-		canonicalURI = "/"
-	}
-	canonicalURI = escapePath(canonicalURI)
-
-	// Canonical query string
-	canonicalQueryString := createCanonicalQueryString(req.URL.Query())
-
-	// Canonical headers
-	canonicalHeaders := createCanonicalHeaders(req, components.SignedHeaders)
-
-	// Signed headers
-	signedHeaders := strings.Join(components.SignedHeaders, ";")
-
-	// Payload hash
-	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
-	if payloadHash == "" {
-		// Compute hash of request body
-		var bodyBytes []byte
-		if req.Body != nil {
-			bodyBytes, _ = io.ReadAll(req.Body)
-			req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-		}
-		payloadHash = hashSHA256(bodyBytes)
+	if err := v.checkScope(auth, credential); err != nil {
+		return nil, err
 	}
 
-	canonicalRequest := strings.Join([]string{
-		method,
-		canonicalURI,
-		canonicalQueryString,
-		canonicalHeaders,
-		signedHeaders,
-		payloadHash,
-	}, "\n")
+	key := v.verifier.Keys.SigningKey(credential.SecretKey, auth.Date, auth.Region, auth.Service)
+	preparePayload(req, key, auth.Scope(), req.Header.Get("X-Amz-Date"), auth.Signature)
 
-	return canonicalRequest, nil
+	return (*SigV4Components)(auth), nil
 }
 
-// createStringToSign creates the string to sign
-func (v *DefaultSignatureValidator) createStringToSign(amzDate string, components *SigV4Components, canonicalRequest string) string {
-	scope := fmt.Sprintf("%s/%s/%s/aws4_request", components.Date, components.Region, components.Service)
-
-	return strings.Join([]string{
-		"AWS4-HMAC-SHA256",
-		amzDate,
-		scope,
-		hashSHA256([]byte(canonicalRequest)),
-	}, "\n")
-}
-
-// calculateSignature calculates the final signature
-func (v *DefaultSignatureValidator) calculateSignature(secretKey, date, region, service, stringToSign string) string {
-	kDate := hmacSHA256([]byte("AWS4"+secretKey), []byte(date))
-	kRegion := hmacSHA256(kDate, []byte(region))
-	kService := hmacSHA256(kRegion, []byte(service))
-	kSigning := hmacSHA256(kService, []byte("aws4_request"))
-
-	signature := hmacSHA256(kSigning, []byte(stringToSign))
-	return hex.EncodeToString(signature)
-}
-
-// createCanonicalHeaders creates the canonical headers string
-func createCanonicalHeaders(req *http.Request, signedHeaders []string) string {
-	var headers []string
-	for _, h := range signedHeaders {
-		h = strings.ToLower(h)
-		var value string
-		if h == "host" {
-			value = req.Host
-		} else {
-			value = req.Header.Get(h)
-		}
-		// Trim spaces and collapse multiple spaces
-		value = strings.TrimSpace(value)
-		headers = append(headers, fmt.Sprintf("%s:%s", h, value))
+// checkScope rejects signatures for a region or service this deployment does
+// not accept, so credentials cannot be replayed across environments
+func (v *DefaultSignatureValidator) checkScope(auth *sigv4.Authorization, credential *Credential) error {
+	regions := v.regions
+	if len(credential.Regions) > 0 {
+		regions = credential.Regions
 	}
-	return strings.Join(headers, "\n") + "\n"
-}
-
-// createCanonicalQueryString creates the canonical query string
-func createCanonicalQueryString(values url.Values) string {
-	if len(values) == 0 {
-		return ""
+	if len(regions) > 0 && !slices.Contains(regions, auth.Region) {
+		return errors.NewAccessDeniedError(errors.DenyCredentialScope,
+			fmt.Sprintf("region %q is not accepted, expecting %s", auth.Region, strings.Join(regions, " or ")), "", "")
 	}
-
-	var keys []string
-	for k := range values {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	var pairs []string
-	for _, k := range keys {
-		vs := values[k]
-		sort.Strings(vs)
-		for _, v := range vs {
-			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
-		}
-	}
-
-	return strings.Join(pairs, "&")
-}
-
-// escapePath URI-encodes the path
-func escapePath(path string) string {
-	// Split by "/" and encode each segment
-	segments := strings.Split(path, "/")
-	for i, seg := range segments {
-		segments[i] = url.PathEscape(seg)
+	// s3express is the signing name of S3 Express directory buckets
+	if len(v.services) > 0 && !slices.Contains(v.services, auth.Service) &&
+		!(auth.Service == "s3express" && slices.Contains(v.services, "s3")) {
+		return errors.NewAccessDeniedError(errors.DenyCredentialScope,
+			fmt.Sprintf("service %q is not accepted, expecting %s", auth.Service, strings.Join(v.services, " or ")), "", "")
 	}
-	return strings.Join(segments, "/")
+	return nil
 }
 
 // hashSHA256 computes SHA256 hash and returns hex string
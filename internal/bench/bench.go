@@ -0,0 +1,260 @@
+// Package bench drives signed S3 traffic at a gateway and reports throughput
+// and latency percentiles, for capacity planning and regression testing.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// Operations in a traffic mix
+const (
+	OpGet  = "get"
+	OpPut  = "put"
+	OpList = "list"
+)
+
+// Mix holds the relative weights of each operation
+type Mix struct {
+	Get, Put, List int
+}
+
+// ParseMix parses weights such as "get=70,put=20,list=10"
+func ParseMix(s string) (Mix, error) {
+	var m Mix
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Mix{}, fmt.Errorf("invalid mix entry %q: expected op=weight", part)
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return Mix{}, fmt.Errorf("invalid weight for %s: %q", name, value)
+		}
+		switch name {
+		case OpGet:
+			m.Get = weight
+		case OpPut:
+			m.Put = weight
+		case OpList:
+			m.List = weight
+		default:
+			return Mix{}, fmt.Errorf("unknown operation %q (use get, put or list)", name)
+		}
+	}
+	if m.Get+m.Put+m.List == 0 {
+		return Mix{}, fmt.Errorf("mix has no operations")
+	}
+	return m, nil
+}
+
+// pick chooses an operation for n in [0, total weight)
+func (m Mix) pick(n int) string {
+	switch {
+	case n < m.Get:
+		return OpGet
+	case n < m.Get+m.Put:
+		return OpPut
+	}
+	return OpList
+}
+
+// Options configures a benchmark run
+type Options struct {
+	Target    string // gateway base URL, e.g. http://localhost:8080
+	Client    *http.Client
+	AccessKey string
+	SecretKey string
+	Region    string
+
+	Bucket      string
+	Prefix      string // object keys are <prefix>obj-<n>
+	Keys        int    // distinct keys, seeded before the run so gets succeed
+	ObjectSize  int
+	Mix         Mix
+	Concurrency int
+	Duration    time.Duration
+	Requests    int // stop after this many requests; 0 runs for Duration
+}
+
+// Result holds per-operation statistics of a run
+type Result struct {
+	Elapsed time.Duration
+	Ops     map[string]*OpStats
+}
+
+// OpStats records the outcome of one operation type
+type OpStats struct {
+	Count     int
+	Errors    int
+	Latencies []time.Duration // sorted once the run completes
+}
+
+// Percentile returns the latency below which p (0-100) percent of requests
+// completed
+func (s *OpStats) Percentile(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(s.Latencies))*p/100+0.5) - 1
+	return s.Latencies[min(max(i, 0), len(s.Latencies)-1)]
+}
+
+// Run seeds the keys and then sends the traffic mix until the duration or
+// request count is reached
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	r := &runner{opts: opts, client: opts.Client, payload: bytes.Repeat([]byte("x"), opts.ObjectSize)}
+	if r.client == nil {
+		r.client = &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: opts.Concurrency}}
+	}
+
+	for i := 0; i < opts.Keys; i++ {
+		if err := r.do(ctx, OpPut, i); err != nil {
+			return nil, fmt.Errorf("failed to seed %s: %w", r.key(i), err)
+		}
+	}
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	var issued atomic.Int64
+	stats := make([]map[string]*OpStats, opts.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < opts.Concurrency; w++ {
+		stats[w] = newStats()
+		wg.Add(1)
+		go func(local map[string]*OpStats, rng *rand.Rand) {
+			defer wg.Done()
+			total := opts.Mix.Get + opts.Mix.Put + opts.Mix.List
+			for ctx.Err() == nil {
+				if opts.Requests > 0 && issued.Add(1) > int64(opts.Requests) {
+					return
+				}
+				op := opts.Mix.pick(rng.Intn(total))
+				began := time.Now()
+				err := r.do(ctx, op, rng.Intn(max(opts.Keys, 1)))
+				if err != nil && ctx.Err() != nil {
+					return // cut off by the deadline, not a failure
+				}
+				s := local[op]
+				s.Count++
+				s.Latencies = append(s.Latencies, time.Since(began))
+				if err != nil {
+					s.Errors++
+				}
+			}
+		}(stats[w], rand.New(rand.NewSource(start.UnixNano()+int64(w))))
+	}
+	wg.Wait()
+
+	res := &Result{Elapsed: time.Since(start), Ops: newStats()}
+	for _, local := range stats {
+		for op, s := range local {
+			res.Ops[op].Count += s.Count
+			res.Ops[op].Errors += s.Errors
+			res.Ops[op].Latencies = append(res.Ops[op].Latencies, s.Latencies...)
+		}
+	}
+	for _, s := range res.Ops {
+		sort.Slice(s.Latencies, func(i, j int) bool { return s.Latencies[i] < s.Latencies[j] })
+	}
+	return res, nil
+}
+
+func newStats() map[string]*OpStats {
+	return map[string]*OpStats{OpGet: {}, OpPut: {}, OpList: {}}
+}
+
+// Report writes a table of throughput and latency percentiles
+func (r *Result) Report(w io.Writer) {
+	fmt.Fprintf(w, "%-6s %9s %7s %10s %10s %10s %10s %10s\n", "op", "requests", "errors", "req/s", "p50", "p90", "p99", "max")
+	secs := r.Elapsed.Seconds()
+	var total, errs int
+	for _, op := range []string{OpGet, OpPut, OpList} {
+		s := r.Ops[op]
+		if s.Count == 0 {
+			continue
+		}
+		total += s.Count
+		errs += s.Errors
+		fmt.Fprintf(w, "%-6s %9d %7d %10.1f %10s %10s %10s %10s\n", op, s.Count, s.Errors, float64(s.Count)/secs,
+			round(s.Percentile(50)), round(s.Percentile(90)), round(s.Percentile(99)), round(s.Percentile(100)))
+	}
+	fmt.Fprintf(w, "total: %d requests, %d errors in %s (%.1f req/s)\n", total, errs, round(r.Elapsed), float64(total)/secs)
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+// runner sends individual signed requests
+type runner struct {
+	opts    Options
+	client  *http.Client
+	payload []byte
+}
+
+func (r *runner) key(n int) string {
+	return fmt.Sprintf("%sobj-%d", r.opts.Prefix, n)
+}
+
+// do sends one operation on key n; statuses of 300 and above are errors
+func (r *runner) do(ctx context.Context, op string, n int) error {
+	var method, path string
+	var body []byte
+	switch op {
+	case OpGet:
+		method, path = http.MethodGet, "/"+r.opts.Bucket+"/"+r.key(n)
+	case OpPut:
+		method, path, body = http.MethodPut, "/"+r.opts.Bucket+"/"+r.key(n), r.payload
+	default:
+		method, path = http.MethodGet, "/"+r.opts.Bucket+"?list-type=2&max-keys=100&prefix="+r.opts.Prefix
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(r.opts.Target, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	creds := aws.Credentials{AccessKeyID: r.opts.AccessKey, SecretAccessKey: r.opts.SecretKey}
+	err = v4.NewSigner().SignHTTP(ctx, creds, req, payloadHash, "s3", r.opts.Region, time.Now().UTC(),
+		func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: status %d", method, path, resp.StatusCode)
+	}
+	return nil
+}
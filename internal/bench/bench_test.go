@@ -0,0 +1,122 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+)
+
+func TestParseMix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Mix
+		wantErr bool
+	}{
+		{"get=70,put=20,list=10", Mix{Get: 70, Put: 20, List: 10}, false},
+		{"put=1", Mix{Put: 1}, false},
+		{" get=1 , list=3", Mix{Get: 1, List: 3}, false},
+		{"get=0", Mix{}, true},
+		{"delete=5", Mix{}, true},
+		{"get", Mix{}, true},
+		{"get=-1", Mix{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMix(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMix(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	s := &OpStats{}
+	for i := 1; i <= 100; i++ {
+		s.Latencies = append(s.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := s.Percentile(p); got != want {
+			t.Errorf("Percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+	if got := (&OpStats{}).Percentile(50); got != 0 {
+		t.Errorf("empty Percentile = %v, want 0", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	cred := config.Credential{
+		AccessKey: "AKIABENCH00000000001",
+		SecretKey: "bench-secret",
+		ClientID:  "bench",
+		TenantID:  "bench",
+		Policies:  []string{"bench"},
+		Scopes:    []string{"bench-*"},
+	}
+	policies := []config.Policy{{
+		Name: "bench",
+		Statements: []config.Statement{{
+			Effect:    config.EffectAllow,
+			Actions:   []string{"s3:GetObject", "s3:PutObject", "s3:ListBucket"},
+			Resources: []string{"arn:aws:s3:::bench-data", "arn:aws:s3:::bench-data/*"},
+		}},
+	}}
+	gw := proxytest.NewGateway(t, []config.Credential{cred}, policies, "bench-data")
+
+	res, err := Run(context.Background(), Options{
+		Target:      gw.URL,
+		AccessKey:   cred.AccessKey,
+		SecretKey:   cred.SecretKey,
+		Region:      proxytest.Region,
+		Bucket:      "bench-data",
+		Prefix:      "run/",
+		Keys:        5,
+		ObjectSize:  64,
+		Mix:         Mix{Get: 2, Put: 1, List: 1},
+		Concurrency: 4,
+		Requests:    40,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var total int
+	for op, s := range res.Ops {
+		total += s.Count
+		if s.Errors != 0 {
+			t.Errorf("%s: %d errors", op, s.Errors)
+		}
+		if len(s.Latencies) != s.Count {
+			t.Errorf("%s: %d latencies for %d requests", op, len(s.Latencies), s.Count)
+		}
+	}
+	if total != 40 {
+		t.Errorf("ran %d requests, want 40", total)
+	}
+	for i := 0; i < 5; i++ {
+		if data, ok := gw.Backend.GetObject("bench-data", fmt.Sprintf("run/obj-%d", i)); !ok || len(data) != 64 {
+			t.Errorf("run/obj-%d missing or wrong size", i)
+		}
+	}
+
+	var report strings.Builder
+	res.Report(&report)
+	if !strings.Contains(report.String(), "total: 40 requests, 0 errors") {
+		t.Errorf("unexpected report:\n%s", report.String())
+	}
+}
+
+func TestRun_SeedFailure(t *testing.T) {
+	gw := proxytest.NewGateway(t, nil, nil, "bench-data")
+	_, err := Run(context.Background(), Options{
+		Target: gw.URL, AccessKey: "AKIAUNKNOWN000000000", SecretKey: "x", Region: proxytest.Region,
+		Bucket: "bench-data", Keys: 1, Mix: Mix{Get: 1}, Concurrency: 1, Requests: 1,
+	})
+	if err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("expected seed failure with 403, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package cloudwatch
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+)
+
+// AuditSink is an audit.Logger sending entries to CloudWatch Logs. Delivery
+// is asynchronous, so entries are not durable when Log returns.
+type AuditSink struct {
+	w *Writer
+}
+
+// NewAuditSink sends entries through w
+func NewAuditSink(w *Writer) *AuditSink {
+	return &AuditSink{w: w}
+}
+
+// Log queues an entry
+func (s *AuditSink) Log(entry *audit.Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	return s.w.Add(entry.Timestamp, string(data))
+}
+
+// Close flushes queued entries
+func (s *AuditSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,155 @@
+package cloudwatch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+// fakeLogs records PutLogEvents calls, optionally failing them
+type fakeLogs struct {
+	mu      sync.Mutex
+	streams []string
+	events  []Event
+	fail    bool
+}
+
+func (f *fakeLogs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		LogGroupName  string  `json:"logGroupName"`
+		LogStreamName string  `json:"logStreamName"`
+		LogEvents     []Event `json:"logEvents"`
+	}
+	json.NewDecoder(r.Body).Decode(&in)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Header.Get("X-Amz-Target") {
+	case "Logs_20140328.CreateLogStream":
+		if len(f.streams) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceAlreadyExistsException","message":"exists"}`))
+			return
+		}
+		f.streams = append(f.streams, in.LogGroupName+"/"+in.LogStreamName)
+	case "Logs_20140328.PutLogEvents":
+		if f.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.events = append(f.events, in.LogEvents...)
+	}
+	w.Write([]byte(`{}`))
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *LogsClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cfg := aws.Config{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	}
+	return NewLogsClient(cfg, server.URL)
+}
+
+func TestAuditSink(t *testing.T) {
+	logs := &fakeLogs{}
+	client := newTestClient(t, logs)
+
+	w, err := NewWriter(context.Background(), client, "/gw/audit", "host-1", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Creating the stream again succeeds
+	if _, err := NewWriter(context.Background(), client, "/gw/audit", "host-1", time.Hour); err != nil {
+		t.Fatalf("unexpected error for existing stream: %v", err)
+	}
+
+	sink := NewAuditSink(w)
+	base := time.Date(2024, 5, 7, 12, 0, 0, 0, time.UTC)
+	sink.Log(&audit.Entry{Timestamp: base.Add(time.Second), RequestID: "req-2"})
+	sink.Log(&audit.Entry{Timestamp: base, RequestID: "req-1"})
+
+	// Failed deliveries are retried on the next flush
+	logs.fail = true
+	if err := w.flush(); err == nil {
+		t.Fatal("expected flush to fail")
+	}
+	logs.fail = false
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if logs.streams[0] != "/gw/audit/host-1" {
+		t.Errorf("stream = %q", logs.streams[0])
+	}
+	if len(logs.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(logs.events))
+	}
+	if logs.events[0].Timestamp != base.UnixMilli() || !strings.Contains(logs.events[0].Message, `"requestId":"req-1"`) {
+		t.Errorf("events not in chronological order: %+v", logs.events)
+	}
+}
+
+func TestEMFExporter(t *testing.T) {
+	registry := metrics.NewRegistry()
+	requests := registry.NewCounter("requests_total", "Requests", "action")
+	inflight := registry.NewGauge("inflight", "In flight")
+
+	var docs []string
+	e := NewEMFExporter(registry, "S3Gateway", func(_ time.Time, doc string) error {
+		docs = append(docs, doc)
+		return nil
+	})
+
+	requests.Add(3, "s3:GetObject")
+	inflight.Set(2)
+	e.Export(time.UnixMilli(1000))
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d: %v", len(docs), docs)
+	}
+
+	var doc struct {
+		AWS struct {
+			Timestamp         int64
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []struct{ Name, Unit string }
+			}
+		} `json:"_aws"`
+		Requests float64 `json:"requests_total"`
+		Action   string  `json:"action"`
+	}
+	if err := json.Unmarshal([]byte(docs[1]), &doc); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	m := doc.AWS.CloudWatchMetrics[0]
+	if doc.AWS.Timestamp != 1000 || m.Namespace != "S3Gateway" || m.Dimensions[0][0] != "action" ||
+		m.Metrics[0].Name != "requests_total" || m.Metrics[0].Unit != "Count" || doc.Requests != 3 || doc.Action != "s3:GetObject" {
+		t.Errorf("unexpected document: %s", docs[1])
+	}
+
+	// Counters report the increase and are skipped when unchanged
+	docs = nil
+	e.Export(time.UnixMilli(2000))
+	if len(docs) != 1 || !strings.Contains(docs[0], `"inflight":2`) {
+		t.Errorf("expected only the gauge, got %v", docs)
+	}
+	docs = nil
+	requests.Add(2, "s3:GetObject")
+	e.Export(time.UnixMilli(3000))
+	if len(docs) != 2 || !strings.Contains(docs[1], `"requests_total":2`) {
+		t.Errorf("expected counter delta of 2, got %v", docs)
+	}
+}
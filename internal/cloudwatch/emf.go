@@ -0,0 +1,131 @@
+package cloudwatch
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+// EMFExporter periodically writes the metrics registry as CloudWatch
+// Embedded Metric Format documents, one per series. Counters are reported as
+// the increase since the previous export and skipped when unchanged.
+type EMFExporter struct {
+	registry  *metrics.Registry
+	namespace string
+	emit      func(t time.Time, doc string) error
+
+	last map[string]float64 // counter values at the previous export
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewEMFExporter creates an exporter passing each document to emit, e.g. a
+// Writer's Add or a function printing to stdout for the CloudWatch agent
+func NewEMFExporter(registry *metrics.Registry, namespace string, emit func(t time.Time, doc string) error) *EMFExporter {
+	return &EMFExporter{
+		registry:  registry,
+		namespace: namespace,
+		emit:      emit,
+		last:      make(map[string]float64),
+	}
+}
+
+// Start exports every interval until Stop is called
+func (e *EMFExporter) Start(interval time.Duration) {
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case t := <-ticker.C:
+				e.Export(t)
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the exporter after a final export
+func (e *EMFExporter) Stop() {
+	close(e.stop)
+	<-e.done
+	e.Export(time.Now())
+}
+
+// Export writes the current metrics
+func (e *EMFExporter) Export(t time.Time) {
+	for _, s := range e.registry.Gather() {
+		value := s.Value
+		if s.Kind == metrics.KindCounter {
+			key := seriesKey(s)
+			prev, seen := e.last[key]
+			e.last[key] = s.Value
+			if seen && s.Value == prev {
+				continue
+			}
+			value -= prev
+		}
+
+		doc, err := e.document(t, s, value)
+		if err != nil {
+			log.Printf("Failed to encode EMF document for %s: %v", s.Name, err)
+			continue
+		}
+		if err := e.emit(t, doc); err != nil {
+			log.Printf("Failed to emit EMF metrics: %v", err)
+			return
+		}
+	}
+}
+
+func (e *EMFExporter) document(t time.Time, s metrics.Sample, value float64) (string, error) {
+	dims := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		dims = append(dims, name)
+	}
+	sort.Strings(dims)
+	unit := "None"
+	if s.Kind == metrics.KindCounter {
+		unit = "Count"
+	}
+
+	doc := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": t.UnixMilli(),
+			"CloudWatchMetrics": []interface{}{map[string]interface{}{
+				"Namespace":  e.namespace,
+				"Dimensions": [][]string{dims},
+				"Metrics":    []interface{}{map[string]string{"Name": s.Name, "Unit": unit}},
+			}},
+		},
+		s.Name: value,
+	}
+	for name, v := range s.Labels {
+		doc[name] = v
+	}
+	data, err := json.Marshal(doc)
+	return string(data), err
+}
+
+// seriesKey identifies a series across exports
+func seriesKey(s metrics.Sample) string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString("\xff" + name + "=" + s.Labels[name])
+	}
+	return b.String()
+}
@@ -0,0 +1,205 @@
+// Package cloudwatch sends audit entries to CloudWatch Logs and metrics in
+// CloudWatch Embedded Metric Format, for deployments on ECS or EKS without a
+// Prometheus stack.
+package cloudwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/s3-access-control-adapter/internal/awsjson"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var droppedEvents = metrics.NewCounter("gateway_cloudwatch_events_dropped_total",
+	"Log events dropped because CloudWatch Logs could not keep up or rejected them")
+
+// PutLogEvents limits
+const (
+	maxBatchEvents   = 10000
+	maxBatchBytes    = 1048576
+	eventOverhead    = 26 // bytes counted per event on top of the message
+	maxBufferedBytes = 16 * maxBatchBytes
+	requestTimeout   = 30 * time.Second
+)
+
+// Event is a CloudWatch Logs event
+type Event struct {
+	Timestamp int64  `json:"timestamp"` // milliseconds since the epoch
+	Message   string `json:"message"`
+}
+
+// LogsClient calls the CloudWatch Logs API
+type LogsClient struct {
+	client *awsjson.Client
+}
+
+// NewLogsClient creates a client; an empty endpoint uses the regional one
+func NewLogsClient(cfg aws.Config, endpoint string) *LogsClient {
+	return &LogsClient{client: awsjson.New(cfg, endpoint, requestTimeout)}
+}
+
+// CreateLogStream creates a stream in an existing log group, succeeding if
+// it already exists
+func (c *LogsClient) CreateLogStream(ctx context.Context, group, stream string) error {
+	err := c.client.Call(ctx, "logs", "Logs_20140328.CreateLogStream",
+		map[string]string{"logGroupName": group, "logStreamName": stream}, nil)
+	var apiErr *awsjson.APIError
+	if errors.As(err, &apiErr) && apiErr.Type == "ResourceAlreadyExistsException" {
+		return nil
+	}
+	return err
+}
+
+// PutLogEvents uploads events, which must be in chronological order
+func (c *LogsClient) PutLogEvents(ctx context.Context, group, stream string, events []Event) error {
+	var out struct {
+		RejectedLogEventsInfo *struct {
+			TooNewLogEventStartIndex *int `json:"tooNewLogEventStartIndex"`
+			TooOldLogEventEndIndex   *int `json:"tooOldLogEventEndIndex"`
+			ExpiredLogEventEndIndex  *int `json:"expiredLogEventEndIndex"`
+		} `json:"rejectedLogEventsInfo"`
+	}
+	err := c.client.Call(ctx, "logs", "Logs_20140328.PutLogEvents", map[string]interface{}{
+		"logGroupName":  group,
+		"logStreamName": stream,
+		"logEvents":     events,
+	}, &out)
+	if err != nil {
+		return err
+	}
+	if out.RejectedLogEventsInfo != nil {
+		log.Printf("CloudWatch Logs rejected some events in %s/%s (too old, too new or expired)", group, stream)
+	}
+	return nil
+}
+
+// Writer batches events to a log stream, flushing them periodically in the
+// background. Events that cannot be delivered are retried on the next flush
+// while the buffer has room.
+type Writer struct {
+	client        *LogsClient
+	group, stream string
+
+	mu       sync.Mutex
+	pending  []Event
+	buffered int // bytes in pending, as counted by PutLogEvents
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWriter creates the log stream and starts flushing every interval
+func NewWriter(ctx context.Context, client *LogsClient, group, stream string, interval time.Duration) (*Writer, error) {
+	if err := client.CreateLogStream(ctx, group, stream); err != nil {
+		return nil, fmt.Errorf("failed to create log stream %s/%s: %w", group, stream, err)
+	}
+
+	w := &Writer{
+		client: client,
+		group:  group,
+		stream: stream,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go w.run(interval)
+	return w, nil
+}
+
+// Add queues a message. It fails when the buffer is full.
+func (w *Writer) Add(t time.Time, message string) error {
+	size := len(message) + eventOverhead
+	if size > maxBatchBytes {
+		droppedEvents.Inc()
+		return fmt.Errorf("log event of %d bytes exceeds the CloudWatch Logs limit", size)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buffered+size > maxBufferedBytes {
+		droppedEvents.Inc()
+		return fmt.Errorf("CloudWatch Logs buffer for %s/%s is full", w.group, w.stream)
+	}
+	w.pending = append(w.pending, Event{Timestamp: t.UnixMilli(), Message: message})
+	w.buffered += size
+	return nil
+}
+
+// Close flushes queued events and stops the writer
+func (w *Writer) Close() error {
+	close(w.stop)
+	<-w.done
+	return w.flush()
+}
+
+func (w *Writer) run(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.flush(); err != nil {
+				log.Printf("Failed to send events to CloudWatch Logs: %v", err)
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// flush sends queued events in batches within the PutLogEvents limits
+func (w *Writer) flush() error {
+	w.mu.Lock()
+	events := w.pending
+	w.pending, w.buffered = nil, 0
+	w.mu.Unlock()
+	if len(events) == 0 {
+		return nil
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp < events[j].Timestamp })
+
+	for len(events) > 0 {
+		n, size := 0, 0
+		for n < len(events) && n < maxBatchEvents && size+len(events[n].Message)+eventOverhead <= maxBatchBytes {
+			size += len(events[n].Message) + eventOverhead
+			n++
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		err := w.client.PutLogEvents(ctx, w.group, w.stream, events[:n])
+		cancel()
+		if err != nil {
+			w.requeue(events)
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+// requeue puts undelivered events back ahead of newer ones, dropping what
+// does not fit
+func (w *Writer) requeue(events []Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	size := 0
+	for _, e := range events {
+		size += len(e.Message) + eventOverhead
+	}
+	for len(events) > 0 && size+w.buffered > maxBufferedBytes {
+		size -= len(events[0].Message) + eventOverhead
+		events = events[1:]
+		droppedEvents.Inc()
+	}
+	w.pending = append(events, w.pending...)
+	w.buffered += size
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// aliasNameRegex follows the S3 bucket naming rule, as clients address
+// aliases as buckets
+var aliasNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+func validateAliases(aliases []AliasConfig) error {
+	names := make(map[string]bool, len(aliases))
+	for i, a := range aliases {
+		if !aliasNameRegex.MatchString(a.Name) || strings.Contains(a.Name, "..") {
+			return fmt.Errorf("aliases[%d]: name %q is not a valid bucket name", i, a.Name)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("aliases[%d]: duplicate name %q", i, a.Name)
+		}
+		names[a.Name] = true
+		if a.Bucket == "" {
+			return fmt.Errorf("aliases[%d] (%s): bucket is required", i, a.Name)
+		}
+		if strings.ContainsAny(a.Bucket, "/*?") {
+			return fmt.Errorf("aliases[%d] (%s): bucket must be a bucket name, not a pattern", i, a.Name)
+		}
+		if strings.HasPrefix(a.Prefix, "/") {
+			return fmt.Errorf("aliases[%d] (%s): prefix must not start with /", i, a.Name)
+		}
+	}
+	for _, a := range aliases {
+		if names[a.Bucket] {
+			return fmt.Errorf("alias %q: bucket %q is itself an alias", a.Name, a.Bucket)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NameRegexp returns the naming convention for buckets created by a tenant,
+// or nil when there is none
+func (b *BucketsConfig) NameRegexp(tenantID string) (*regexp.Regexp, error) {
+	if b.NamePattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(strings.ReplaceAll(b.NamePattern, "{tenantId}", regexp.QuoteMeta(tenantID)))
+}
+
+// MaxListKeys returns the largest page a tenant's listings may return, or 0
+// for the S3 limit
+func (b *BucketsConfig) MaxListKeys(tenantID string) int {
+	if n, ok := b.Listing.Tenants[tenantID]; ok {
+		return n
+	}
+	return b.Listing.MaxKeys
+}
+
+func (b *BucketsConfig) validate() error {
+	if _, err := b.NameRegexp("tenant"); err != nil {
+		return fmt.Errorf("buckets.namePattern: %w", err)
+	}
+	if n := b.Listing.MaxKeys; n < 0 || n > maxListKeys {
+		return fmt.Errorf("buckets.listing.maxKeys must be between 0 and %d", maxListKeys)
+	}
+	for tenant, n := range b.Listing.Tenants {
+		if n < 0 || n > maxListKeys {
+			return fmt.Errorf("buckets.listing.tenants[%s] must be between 0 and %d", tenant, maxListKeys)
+		}
+	}
+	return nil
+}
+
+// maxListKeys is the S3 page size limit
+const maxListKeys = 1000
+
+// maxObjectTags is the S3 limit on the tags of an object
+const maxObjectTags = 10
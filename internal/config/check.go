@@ -0,0 +1,281 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a configuration issue found by Check
+type Problem struct {
+	File    string
+	Line    int // 0 when the location is unknown
+	Message string
+	Warning bool // reported, but does not prevent the gateway from starting
+}
+
+func (p Problem) String() string {
+	loc := p.File
+	if p.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	if p.Warning {
+		return loc + ": warning: " + p.Message
+	}
+	return loc + ": " + p.Message
+}
+
+// yamlLineRegex matches the "line N:" prefix of yaml.v3 errors
+var yamlLineRegex = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// fieldPathRegex matches one segment of a field path such as listeners[0]
+var fieldPathRegex = regexp.MustCompile(`^([A-Za-z]+)(?:\[(\d+)\])?$`)
+
+// Check loads the gateway config at path and the credentials and policies it
+// references, returning every problem found instead of stopping at the first.
+// Unlike loading, it also cross-checks the files.
+func Check(path string) []Problem {
+	c := &checker{}
+
+	var cfg GatewayConfig
+	root, ok := c.decode(path, &cfg)
+	if !ok {
+		return c.problems
+	}
+	applyDefaults(&cfg)
+	c.addErrors(path, root, validateGatewayConfig(&cfg))
+
+	credFile, credRoot, credentials, groups, tenants := path, root, cfg.Credentials, cfg.CredentialGroups, cfg.Tenants
+	if cfg.CredentialsFile != "" {
+		var creds CredentialsConfig
+		credFile = cfg.CredentialsFile
+		if credRoot, ok = c.decode(credFile, &creds); ok {
+			c.addErrors(credFile, credRoot, ValidateCredentials(&creds))
+			c.addErrors(credFile, credRoot, CheckAccessKeys(creds.Credentials, &cfg.Auth.AccessKeys))
+		}
+		credentials, groups, tenants = creds.Credentials, creds.Groups, creds.Tenants
+	}
+
+	policies := cfg.Policies
+	if IsRemote(cfg.PoliciesFile) {
+		return c.problems // fetched at startup, references are checked then
+	}
+	if cfg.PoliciesFile != "" {
+		var pols PoliciesConfig
+		policiesRoot, ok := c.decode(cfg.PoliciesFile, &pols)
+		if !ok {
+			return c.problems // references cannot be checked
+		}
+		c.addErrors(cfg.PoliciesFile, policiesRoot, ValidatePolicies(&pols))
+		policies = pols.Policies
+	}
+
+	if cfg.Kubernetes.Enabled && cfg.CredentialsFile == "" && cfg.Credentials == nil {
+		return c.problems
+	}
+	c.addErrors(credFile, credRoot, CheckPolicyReferences(credentials, policies))
+	c.addErrors(credFile, credRoot, CheckGroupPolicyReferences(groups, policies))
+	c.addErrors(credFile, credRoot, CheckTenantPolicyReferences(tenants, policies))
+	resolved := ResolveCredentials(&CredentialsConfig{Credentials: credentials, Groups: groups, Tenants: tenants})
+	for _, err := range unreachableScopes(resolved, policies) {
+		c.add(credFile, credRoot, err.Error(), true)
+	}
+	return c.problems
+}
+
+// CheckPolicyReferences reports credentials naming policies that do not exist,
+// which would otherwise silently deny every request they make
+func CheckPolicyReferences(credentials []Credential, policies []Policy) error {
+	names := make(map[string]bool, len(policies))
+	for _, p := range policies {
+		names[p.Name] = true
+	}
+
+	var errs []error
+	for i, cred := range credentials {
+		for j, name := range cred.Policies {
+			if !names[name] {
+				errs = append(errs, fmt.Errorf("credentials[%d].policies[%d]: policy %q referenced by client %q does not exist",
+					i, j, name, cred.ClientID))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// unreachableScopes reports scopes that overlap no resource allowed by the
+// credential's policies, so no request within them could succeed
+func unreachableScopes(credentials []Credential, policies []Policy) []error {
+	byName := make(map[string]Policy, len(policies))
+	for _, p := range policies {
+		byName[p.Name] = p
+	}
+
+	var errs []error
+	for i, cred := range credentials {
+		var buckets []string
+		for _, name := range cred.Policies {
+			for _, stmt := range byName[name].Statements {
+				if stmt.Effect != EffectAllow {
+					continue
+				}
+				for _, resource := range stmt.Resources {
+					bucket, _, _ := strings.Cut(strings.TrimPrefix(resource, "arn:aws:s3:::"), "/")
+					buckets = append(buckets, bucket)
+				}
+			}
+		}
+		if len(buckets) == 0 {
+			continue
+		}
+
+		for j, scope := range cred.Scopes {
+			scopeBucket, _, _ := strings.Cut(scope, "/")
+			reachable := false
+			for _, bucket := range buckets {
+				if globsOverlap(scopeBucket, bucket) {
+					reachable = true
+					break
+				}
+			}
+			if !reachable {
+				errs = append(errs, fmt.Errorf("credentials[%d].scopes[%d]: scope %q matches no bucket allowed by the policies of client %q",
+					i, j, scope, cred.ClientID))
+			}
+		}
+	}
+	return errs
+}
+
+// globsOverlap reports whether some string matches both patterns, where "*"
+// matches any sequence and "?" any single character
+func globsOverlap(a, b string) bool {
+	switch {
+	case a == "" && b == "":
+		return true
+	case a != "" && a[0] == '*':
+		return globsOverlap(a[1:], b) || (b != "" && globsOverlap(a, b[1:]))
+	case b != "" && b[0] == '*':
+		return globsOverlap(a, b[1:]) || (a != "" && globsOverlap(a[1:], b))
+	case a == "" || b == "":
+		return false
+	case a[0] == '?' || b[0] == '?' || a[0] == b[0]:
+		return globsOverlap(a[1:], b[1:])
+	}
+	return false
+}
+
+type checker struct {
+	problems []Problem
+}
+
+// decode reads a config file and strictly decodes it into v, recording
+// problems. It returns the document's node tree for locating later problems,
+// and false when the file could not be parsed at all.
+func (c *checker) decode(file string, v interface{}) (*yaml.Node, bool) {
+	data, err := readConfigFile(file)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err // the file name is already in the problem
+		}
+		c.add(file, nil, err.Error(), false)
+		return nil, false
+	}
+	data, err = substituteEnvVars(data)
+	if err != nil {
+		c.addErrors(file, nil, err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		c.addYAMLError(file, err.Error())
+		return nil, false
+	}
+
+	if err := unmarshalStrict(data, v); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			c.addYAMLError(file, err.Error())
+			return nil, false
+		}
+		for _, msg := range typeErr.Errors {
+			c.addYAMLError(file, msg)
+		}
+	}
+	return &root, true
+}
+
+// addErrors records each error joined into err
+func (c *checker) addErrors(file string, root *yaml.Node, err error) {
+	if err == nil {
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			c.addErrors(file, root, e)
+		}
+		return
+	}
+	c.add(file, root, err.Error(), false)
+}
+
+// add records a problem, locating it by the field path the message starts with
+func (c *checker) add(file string, root *yaml.Node, message string, warning bool) {
+	c.problems = append(c.problems, Problem{File: file, Line: lineFor(root, message), Message: message, Warning: warning})
+}
+
+// addYAMLError records a yaml.v3 error, taking the line from its prefix
+func (c *checker) addYAMLError(file, message string) {
+	p := Problem{File: file, Message: message}
+	if m := yamlLineRegex.FindStringSubmatch(message); m != nil {
+		p.Line, _ = strconv.Atoi(m[1])
+		p.Message = m[2]
+	}
+	c.problems = append(c.problems, p)
+}
+
+// lineFor returns the line of the deepest node along the field path that
+// starts message, e.g. "server.listeners[1]: ...", or 0 when none matches
+func lineFor(root *yaml.Node, message string) int {
+	if root == nil || len(root.Content) == 0 {
+		return 0
+	}
+	path, _, _ := strings.Cut(message, ":")
+	path, _, _ = strings.Cut(path, " ")
+
+	node, line := root.Content[0], 0
+	for _, segment := range strings.Split(path, ".") {
+		m := fieldPathRegex.FindStringSubmatch(segment)
+		if m == nil || node.Kind != yaml.MappingNode {
+			return line
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == m[1] {
+				next = node.Content[i+1]
+				line = node.Content[i].Line
+				break
+			}
+		}
+		if next == nil {
+			return line
+		}
+		node = next
+
+		if m[2] != "" {
+			idx, _ := strconv.Atoi(m[2])
+			if node.Kind != yaml.SequenceNode || idx >= len(node.Content) {
+				return line
+			}
+			node = node.Content[idx]
+			line = node.Line
+		}
+	}
+	return line
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	credsPath := writeFile(t, dir, "credentials.yaml", `credentials:
+  - accessKey: AKIA1
+    secretKey: secret
+    clientId: app
+    tenantId: tenant
+    policies: [read, raed]
+    scopes: ["tenant-*", "other-bucket"]
+  - accessKey: AKIA2
+    clientId: app2
+    tenantId: tenant
+    scopess: ["x"]
+`)
+	policiesPath := writeFile(t, dir, "policies.yaml", `policies:
+  - name: read
+    statements:
+      - effect: Allow
+        actions: ["s3:GetObject"]
+        resources: ["arn:aws:s3:::tenant-001-*/*"]
+`)
+	gatewayPath := writeFile(t, dir, "gateway.yaml", `server:
+  trustedProxies: ["10.0.0.0/8", "not-a-cidr"]
+backend:
+  type: nope
+credentialsFile: `+credsPath+`
+policiesFile: `+policiesPath+`
+`)
+
+	var got []string
+	for _, p := range Check(gatewayPath) {
+		got = append(got, strings.TrimPrefix(p.String(), dir+"/"))
+	}
+
+	want := []string{
+		`gateway.yaml:2: server.trustedProxies: invalid trusted proxy "not-a-cidr": must be a CIDR or IP address`,
+		`gateway.yaml:4: backend.type must be s3, filesystem, memory or azure, got "nope"`,
+		`credentials.yaml:11: field scopess not found in type config.Credential`,
+		`credentials.yaml:8: credentials[1]: secretKey is required`,
+		`credentials.yaml:6: credentials[0].policies[1]: policy "raed" referenced by client "app" does not exist`,
+		`credentials.yaml:7: warning: credentials[0].scopes[1]: scope "other-bucket" matches no bucket allowed by the policies of client "app"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheck_Valid(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "gateway.yaml", `credentials:
+  - accessKey: AKIA1
+    secretKey: secret
+    clientId: app
+    tenantId: tenant
+    policies: [read]
+    scopes: ["tenant-001-data"]
+policies:
+  - name: read
+    statements:
+      - effect: Allow
+        actions: ["s3:GetObject"]
+        resources: ["arn:aws:s3:::tenant-*/*"]
+`)
+	if problems := Check(path); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestGlobsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"tenant-*", "tenant-001-*", true},
+		{"tenant-001-data", "tenant-*", true},
+		{"*", "anything", true},
+		{"tenant-00?", "tenant-001", true},
+		{"tenant-*", "other-*", false},
+		{"a*c", "ab*", true},
+		{"a*c", "b*", false},
+	}
+	for _, tt := range tests {
+		if got := globsOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("globsOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxCICredentialTTL bounds how long issued CI credentials last
+const maxCICredentialTTL = 12 * time.Hour
+
+// ciPrefixClaim matches the {claim} references of a role prefix
+var ciPrefixClaim = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// Enabled reports whether CI jobs can exchange tokens for credentials
+func (c *CICredentialsConfig) Enabled() bool {
+	return len(c.Roles) > 0
+}
+
+// Role returns the role with the given name
+func (c *CICredentialsConfig) Role(name string) (*CIRole, bool) {
+	for i := range c.Roles {
+		if c.Roles[i].Name == name {
+			return &c.Roles[i], true
+		}
+	}
+	return nil, false
+}
+
+// ExpandPrefix replaces the {claim} references of the role's prefix with the
+// claim values, failing when a claim is missing. Each value must fill a single
+// path segment: values with "/" or ".." are refused, as ref=a/../../b or
+// ref=main/x would reach the prefixes of other jobs. The prefix always ends
+// with "/", so it cannot also cover sibling prefixes, e.g. ci/42-evil/ for
+// ci/42.
+func (r *CIRole) ExpandPrefix(claim func(name string) string) (string, error) {
+	var missing, invalid string
+	prefix := ciPrefixClaim.ReplaceAllStringFunc(r.Prefix, func(ref string) string {
+		name := strings.Trim(ref, "{}")
+		v := claim(name)
+		switch {
+		case v == "" && missing == "":
+			missing = name
+		case (strings.Contains(v, "/") || strings.Contains(v, "..")) && invalid == "":
+			invalid = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("token has no %q claim for the prefix of role %q", missing, r.Name)
+	}
+	if invalid != "" {
+		return "", fmt.Errorf("token claim %q contains \"/\" or \"..\" and cannot be part of the prefix of role %q", invalid, r.Name)
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix, nil
+}
+
+func (c *CICredentialsConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("ciCredentials.path must start with /, got %q", c.Path)
+	}
+	if len(c.SigningKey) < 32 {
+		return fmt.Errorf("ciCredentials.signingKey must be at least 32 characters")
+	}
+	if c.TTL < 0 || c.TTL > maxCICredentialTTL {
+		return fmt.Errorf("ciCredentials.ttl must be at most %s", maxCICredentialTTL)
+	}
+	providers := make(map[string]bool, len(c.Providers))
+	for i, p := range c.Providers {
+		if p.Name == "" || providers[p.Name] {
+			return fmt.Errorf("ciCredentials.providers[%d]: name must be set and unique", i)
+		}
+		providers[p.Name] = true
+		if !isHTTPURL(p.Issuer) {
+			return fmt.Errorf("ciCredentials.providers[%d].issuer must be an http(s) URL, got %q", i, p.Issuer)
+		}
+		if p.JWKSURL != "" && !isHTTPURL(p.JWKSURL) {
+			return fmt.Errorf("ciCredentials.providers[%d].jwksUrl must be an http(s) URL, got %q", i, p.JWKSURL)
+		}
+		if p.Audience == "" {
+			return fmt.Errorf("ciCredentials.providers[%d].audience is required", i)
+		}
+	}
+	roles := make(map[string]bool, len(c.Roles))
+	for i, r := range c.Roles {
+		switch {
+		case r.Name == "" || roles[r.Name]:
+			return fmt.Errorf("ciCredentials.roles[%d]: name must be set and unique", i)
+		case !providers[r.Provider]:
+			return fmt.Errorf("ciCredentials.roles[%d]: unknown provider %q", i, r.Provider)
+		case len(r.Claims) == 0:
+			// Any job of the CI system could otherwise get the role
+			return fmt.Errorf("ciCredentials.roles[%d]: claims are required", i)
+		case r.TenantID == "":
+			return fmt.Errorf("ciCredentials.roles[%d].tenantId is required", i)
+		case r.Bucket == "" || strings.ContainsAny(r.Bucket, "*?/"):
+			return fmt.Errorf("ciCredentials.roles[%d].bucket must be a bucket name, got %q", i, r.Bucket)
+		case r.Prefix == "" || strings.HasPrefix(r.Prefix, "/") || !strings.HasSuffix(r.Prefix, "/"):
+			// Keys are confined by string prefix, so ci/{repository} would
+			// also cover ci/{repository}-evil/
+			return fmt.Errorf("ciCredentials.roles[%d].prefix must be set, not start with / and end with /, got %q", i, r.Prefix)
+		case len(r.Policies) == 0:
+			return fmt.Errorf("ciCredentials.roles[%d].policies are required", i)
+		case r.TTL < 0 || r.TTL > maxCICredentialTTL:
+			return fmt.Errorf("ciCredentials.roles[%d].ttl must be at most %s", i, maxCICredentialTTL)
+		}
+		roles[r.Name] = true
+	}
+	return nil
+}
+
+func isHTTPURL(v string) bool {
+	u, err := url.Parse(v)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
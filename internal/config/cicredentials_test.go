@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCIRole_ExpandPrefix(t *testing.T) {
+	claims := map[string]string{"repository": "org/repo", "repository_id": "17", "run_id": "42", "ref": "..", "env": "x..y"}
+	claim := func(name string) string { return claims[name] }
+
+	for prefix, want := range map[string]string{
+		"ci/{repository_id}/{run_id}/": "ci/17/42/",
+		// Without the trailing slash, ci/17 would also cover ci/17-evil/,
+		// another repository's area
+		"ci/{repository_id}": "ci/17/",
+	} {
+		role := &CIRole{Name: "build", Prefix: prefix}
+		got, err := role.ExpandPrefix(claim)
+		if err != nil || got != want {
+			t.Errorf("ExpandPrefix(%q) = %q, %v; want %q", prefix, got, err, want)
+		}
+	}
+	if _, err := (&CIRole{Name: "build", Prefix: "ci/{sha}/"}).ExpandPrefix(claim); err == nil {
+		t.Error("expected an error for a missing claim")
+	}
+	// Values spanning segments or climbing out of them would reach the
+	// prefixes of other jobs
+	for _, name := range []string{"repository", "ref", "env"} {
+		if got, err := (&CIRole{Name: "build", Prefix: "ci/{" + name + "}/"}).ExpandPrefix(claim); err == nil {
+			t.Errorf("claim %s: ExpandPrefix = %q, want an error", name, got)
+		}
+	}
+}
+
+func TestCICredentialsConfig_ValidatePrefix(t *testing.T) {
+	for prefix, wantErr := range map[string]bool{
+		"ci/{repository}/": false,
+		"ci/{repository}":  true,
+		"/ci/":             true,
+		"":                 true,
+	} {
+		cfg := &CICredentialsConfig{
+			Path:       "/_gateway/ci-credentials",
+			SigningKey: strings.Repeat("k", 32),
+			Providers:  []OIDCProvider{{Name: "github", Issuer: "https://token.actions.githubusercontent.com", Audience: "s3-gateway"}},
+			Roles: []CIRole{{Name: "build", Provider: "github", Claims: map[string]string{"repository": "org/*"},
+				TenantID: "t1", Bucket: "artifacts", Prefix: prefix, Policies: []string{"p"}}},
+		}
+		if err := cfg.validate(); (err != nil) != wantErr {
+			t.Errorf("prefix %q: err = %v, want error %v", prefix, err, wantErr)
+		}
+	}
+}
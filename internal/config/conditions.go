@@ -0,0 +1,68 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy variables in condition values are replaced with the requesting
+// client's IDs when policies are evaluated, not when config is loaded
+const (
+	TenantIDVariable = "${tenant:id}"
+	ClientIDVariable = "${client:id}"
+)
+
+// ConditionValues are the values a condition key is compared against. A
+// single value may be written as a scalar, several as a list; the condition
+// matches when any of them does.
+type ConditionValues []string
+
+// UnmarshalYAML accepts a scalar or a sequence of scalars
+func (v *ConditionValues) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*v = ConditionValues{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		var values []string
+		if err := node.Decode(&values); err != nil {
+			return err
+		}
+		*v = values
+		return nil
+	}
+	return fmt.Errorf("line %d: condition value must be a string or a list of strings", node.Line)
+}
+
+// MarshalYAML writes a single value as a scalar
+func (v ConditionValues) MarshalYAML() (interface{}, error) {
+	if len(v) == 1 {
+		return v[0], nil
+	}
+	return []string(v), nil
+}
+
+// UnmarshalJSON accepts a string or an array of strings
+func (v *ConditionValues) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*v = ConditionValues{value}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("condition value must be a string or an array of strings")
+	}
+	*v = values
+	return nil
+}
+
+// MarshalJSON writes a single value as a string
+func (v ConditionValues) MarshalJSON() ([]byte, error) {
+	if len(v) == 1 {
+		return json.Marshal(v[0])
+	}
+	return json.Marshal([]string(v))
+}
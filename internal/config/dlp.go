@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// Enabled reports whether any upload is scanned
+func (d *DLPConfig) Enabled() bool {
+	return len(d.Rules) > 0
+}
+
+func (d *DLPConfig) validate() error {
+	for name, expr := range d.Detectors {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("dlp.detectors.%s: %w", name, err)
+		}
+	}
+	if d.ServiceURL != "" {
+		if u, err := url.Parse(d.ServiceURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("dlp.serviceUrl must be an http(s) URL, got %q", d.ServiceURL)
+		}
+	}
+	if d.ServiceTimeout < 0 || d.MaxScanSize < 0 {
+		return fmt.Errorf("dlp.serviceTimeout and dlp.maxScanSize must not be negative")
+	}
+	if d.Enabled() && len(d.Detectors) == 0 && d.ServiceURL == "" {
+		return fmt.Errorf("dlp.rules require detectors or a serviceUrl")
+	}
+	for i, rule := range d.Rules {
+		switch rule.Action {
+		case DLPActionBlock, DLPActionTag, DLPActionAudit:
+		default:
+			return fmt.Errorf("dlp.rules[%d].action must be block, tag or audit, got %q", i, rule.Action)
+		}
+	}
+	return nil
+}
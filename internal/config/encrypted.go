@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encryptedHeader starts every encrypted file. The rest of the file is the
+// base64 of nonce || AES-256-GCM ciphertext.
+const encryptedHeader = "s3gw-encrypted-v1\n"
+
+// Environment variables holding the key for encrypted files: the base64 key
+// itself, or the path of a file containing it
+const (
+	EncryptionKeyEnv     = "GATEWAY_ENCRYPTION_KEY"
+	EncryptionKeyFileEnv = "GATEWAY_ENCRYPTION_KEY_FILE"
+)
+
+// IsEncrypted reports whether data is an encrypted config file
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedHeader))
+}
+
+// GenerateEncryptionKey returns a new random key, base64 encoded
+func GenerateEncryptionKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// EncryptionKey reads the key for encrypted files from the environment
+func EncryptionKey() ([]byte, error) {
+	encoded := os.Getenv(EncryptionKeyEnv)
+	if path := os.Getenv(EncryptionKeyFileEnv); encoded == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+		encoded = string(data)
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("file is encrypted but neither %s nor %s is set", EncryptionKeyEnv, EncryptionKeyFileEnv)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key: must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with key into the encrypted file format
+func Encrypt(plaintext, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(encryptedHeader))
+
+	out := []byte(encryptedHeader)
+	out = append(out, base64.StdEncoding.EncodeToString(sealed)...)
+	return append(out, '\n'), nil
+}
+
+// Decrypt opens a file produced by Encrypt
+func Decrypt(data, key []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data[len(encryptedHeader):])))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted file: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(encryptedHeader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file: wrong key or corrupted data")
+	}
+	return plaintext, nil
+}
+
+// readConfigFile reads a config file, decrypting it when encrypted
+func readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+
+	key, err := EncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, key)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	encoded, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EncryptionKeyEnv, encoded)
+	key, err := EncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("credentials:\n  - accessKey: AKIA\n    secretKey: secret\n")
+	encrypted, err := Encrypt(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(string(encrypted), "secret") {
+		t.Fatalf("encrypted output looks like plaintext: %q", encrypted)
+	}
+
+	decrypted, err := Decrypt(encrypted, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("decrypted = %q, want %q", decrypted, plaintext)
+	}
+
+	other, _ := GenerateEncryptionKey()
+	t.Setenv(EncryptionKeyEnv, other)
+	otherKey, _ := EncryptionKey()
+	if _, err := Decrypt(encrypted, otherKey); err == nil {
+		t.Error("expected decryption with the wrong key to fail")
+	}
+}
+
+func TestLoadCredentials_Encrypted(t *testing.T) {
+	encoded, _ := GenerateEncryptionKey()
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyPath, []byte(encoded+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EncryptionKeyEnv, "")
+	t.Setenv(EncryptionKeyFileEnv, keyPath)
+	key, err := EncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte(`credentials:
+  - accessKey: AKIAEXAMPLE
+    secretKey: secret
+    clientId: app
+    tenantId: tenant
+`)
+	encrypted, err := Encrypt(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "credentials.yaml.enc")
+	if err := os.WriteFile(path, encrypted, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	if len(cfg.Credentials) != 1 || cfg.Credentials[0].SecretKey != "secret" {
+		t.Errorf("unexpected credentials: %+v", cfg.Credentials)
+	}
+
+	t.Setenv(EncryptionKeyFileEnv, "")
+	if _, err := LoadCredentials(path); err == nil || !strings.Contains(err.Error(), EncryptionKeyEnv) {
+		t.Errorf("expected missing key error, got %v", err)
+	}
+}
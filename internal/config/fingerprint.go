@@ -0,0 +1,19 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Fingerprint returns a short hash identifying the content of a loaded
+// configuration section, so replicas serving different versions can be told
+// apart. Map keys are hashed in sorted order.
+func Fingerprint(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
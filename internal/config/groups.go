@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// validateGroups checks group names and the groups credentials reference
+func validateGroups(cfg *CredentialsConfig) []error {
+	var errs []error
+	known := make(map[string]bool, len(cfg.Groups))
+	for i, group := range cfg.Groups {
+		if group.Name == "" {
+			errs = append(errs, fmt.Errorf("groups[%d]: name is required", i))
+		}
+		if group.Name != "" && known[group.Name] {
+			errs = append(errs, fmt.Errorf("groups[%d]: duplicate group name %q", i, group.Name))
+		}
+		known[group.Name] = true
+	}
+	for i, cred := range cfg.Credentials {
+		for j, name := range cred.Groups {
+			if !known[name] {
+				errs = append(errs, fmt.Errorf("credentials[%d].groups[%d]: group %q does not exist", i, j, name))
+			}
+		}
+	}
+	return errs
+}
+
+// ResolveCredentials returns the credentials with the policies and scopes of
+// their groups and then of their tenant and its ancestors appended to their
+// own, without duplicates. The configuration must have passed
+// ValidateCredentials.
+func ResolveCredentials(cfg *CredentialsConfig) []Credential {
+	if len(cfg.Groups) == 0 && len(cfg.Tenants) == 0 {
+		return cfg.Credentials
+	}
+	byName := make(map[string]*CredentialGroup, len(cfg.Groups))
+	for i := range cfg.Groups {
+		byName[cfg.Groups[i].Name] = &cfg.Groups[i]
+	}
+	tenants, _ := resolveTenants(cfg.Tenants)
+
+	resolved := make([]Credential, len(cfg.Credentials))
+	for i, cred := range cfg.Credentials {
+		for _, name := range cred.Groups {
+			if group, ok := byName[name]; ok {
+				cred.Policies = appendUnique(cred.Policies, group.Policies)
+				cred.Scopes = appendUnique(cred.Scopes, group.Scopes)
+			}
+		}
+		if tenant, ok := tenants[cred.TenantID]; ok {
+			cred.Policies = appendUnique(cred.Policies, tenant.policies)
+			cred.Scopes = appendUnique(cred.Scopes, tenant.scopes)
+		}
+		resolved[i] = cred
+	}
+	return resolved
+}
+
+// CheckGroupPolicyReferences reports groups naming policies that do not exist
+func CheckGroupPolicyReferences(groups []CredentialGroup, policies []Policy) error {
+	names := make(map[string]bool, len(policies))
+	for _, p := range policies {
+		names[p.Name] = true
+	}
+
+	var errs []error
+	for i, group := range groups {
+		for j, name := range group.Policies {
+			if !names[name] {
+				errs = append(errs, fmt.Errorf("groups[%d].policies[%d]: policy %q referenced by group %q does not exist",
+					i, j, name, group.Name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// appendUnique returns a copy of dst with the values of src it lacks appended
+func appendUnique(dst, src []string) []string {
+	out := append([]string(nil), dst...)
+	for _, v := range src {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveCredentials_Groups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	data := `groups:
+  - name: readers
+    policies: [read-only]
+    scopes: ["shared-*"]
+  - name: auditors
+    policies: [read-only, audit-logs]
+credentials:
+  - accessKey: A
+    secretKey: s
+    clientId: a
+    tenantId: t
+    policies: [own]
+    scopes: ["t-*", "shared-*"]
+    groups: [readers, auditors]
+  - accessKey: B
+    secretKey: s
+    clientId: b
+    tenantId: t
+    scopes: ["t-*"]
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resolved := ResolveCredentials(cfg)
+	if got, want := resolved[0].Policies, []string{"own", "read-only", "audit-logs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("policies = %v, want %v", got, want)
+	}
+	if got, want := resolved[0].Scopes, []string{"t-*", "shared-*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scopes = %v, want %v", got, want)
+	}
+	if got, want := resolved[1].Scopes, []string{"t-*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("credential without groups: scopes = %v, want %v", got, want)
+	}
+	if got := cfg.Credentials[0].Policies; len(got) != 1 {
+		t.Errorf("resolving modified the configuration: policies = %v", got)
+	}
+}
+
+func TestValidateCredentials_Groups(t *testing.T) {
+	cfg := &CredentialsConfig{
+		Groups: []CredentialGroup{{Name: "readers"}, {Name: "readers"}, {}},
+		Credentials: []Credential{
+			{AccessKey: "A", SecretKey: "s", ClientID: "a", TenantID: "t", Groups: []string{"readers", "writers"}},
+		},
+	}
+	err := ValidateCredentials(cfg)
+	for _, want := range []string{
+		`groups[1]: duplicate group name "readers"`,
+		"groups[2]: name is required",
+		`credentials[0].groups[1]: group "writers" does not exist`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q, got %v", want, err)
+		}
+	}
+
+	err = CheckGroupPolicyReferences([]CredentialGroup{{Name: "readers", Policies: []string{"read-only", "missing"}}},
+		[]Policy{{Name: "read-only"}})
+	if err == nil || !strings.Contains(err.Error(), `groups[0].policies[1]: policy "missing" referenced by group "readers"`) {
+		t.Errorf("expected unknown policy error, got %v", err)
+	}
+}
@@ -1,9 +1,17 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -12,6 +20,9 @@ import (
 // envVarRegex matches ${VAR_NAME} patterns
 var envVarRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
 
+// sqlIdentifierRegex matches table names safe to interpolate into SQL
+var sqlIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
 // LoadGatewayConfig loads the main gateway configuration from a YAML file
 func LoadGatewayConfig(path string) (*GatewayConfig, error) {
 	data, err := os.ReadFile(path)
@@ -19,11 +30,14 @@ func LoadGatewayConfig(path string) (*GatewayConfig, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Substitute environment variables
-	data = substituteEnvVars(data)
+	// Substitute environment variables and secret references
+	data, err = substituteEnvVars(data)
+	if err != nil {
+		return nil, err
+	}
 
 	var cfg GatewayConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := unmarshalStrict(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
@@ -38,53 +52,136 @@ func LoadGatewayConfig(path string) (*GatewayConfig, error) {
 	return &cfg, nil
 }
 
-// LoadCredentials loads client credentials from a YAML file
+// LoadCredentials loads client credentials from a YAML file, which may be
+// encrypted (see Encrypt)
 func LoadCredentials(path string) (*CredentialsConfig, error) {
-	data, err := os.ReadFile(path)
+	data, err := readConfigFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read credentials file: %w", err)
 	}
 
+	data, err = substituteEnvVars(data)
+	if err != nil {
+		return nil, err
+	}
+
 	var cfg CredentialsConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := unmarshalStrict(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse credentials file: %w", err)
 	}
 
-	if err := validateCredentials(&cfg); err != nil {
+	if err := ValidateCredentials(&cfg); err != nil {
 		return nil, err
 	}
 
 	return &cfg, nil
 }
 
+// IsRemote reports whether a file location is an s3:// or http(s):// URL
+// fetched over the network rather than a local path
+func IsRemote(location string) bool {
+	for _, scheme := range []string{"s3://", "https://", "http://"} {
+		if strings.HasPrefix(location, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
 // LoadPolicies loads IAM-like policies from a YAML file
 func LoadPolicies(path string) (*PoliciesConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read policies file: %w", err)
 	}
+	return ParsePolicies(data)
+}
 
+// ParsePolicies parses and validates a policies document
+func ParsePolicies(data []byte) (*PoliciesConfig, error) {
 	var cfg PoliciesConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse policies file: %w", err)
+	if err := unmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse policies: %w", err)
 	}
 
-	if err := validatePolicies(&cfg); err != nil {
+	if err := ValidatePolicies(&cfg); err != nil {
 		return nil, err
 	}
 
 	return &cfg, nil
 }
 
-// substituteEnvVars replaces ${VAR_NAME} with environment variable values
-func substituteEnvVars(data []byte) []byte {
-	return envVarRegex.ReplaceAllFunc(data, func(match []byte) []byte {
-		varName := string(envVarRegex.FindSubmatch(match)[1])
-		if value := os.Getenv(varName); value != "" {
-			return []byte(value)
+// unmarshalStrict decodes YAML into v, rejecting fields v does not define so
+// that misspelled keys fail loudly instead of being ignored
+func unmarshalStrict(data []byte, v interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// StrictEnv makes config loading fail when a ${VAR} reference names an unset
+// variable and has no ${VAR:-default}. When false such references are left
+// as literal text.
+var StrictEnv = true
+
+// substituteEnvVars replaces ${VAR_NAME} and ${VAR_NAME:-default} with
+// environment variable values and ${scheme:ref} with values from registered
+// resolvers. Full-line comments and policy variables are left untouched.
+func substituteEnvVars(data []byte) ([]byte, error) {
+	var errs []error
+	var unresolved []string
+	seen := make(map[string]bool)
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i, line := range lines {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			continue
 		}
-		return match // Keep original if env var not set
-	})
+		lines[i] = envVarRegex.ReplaceAllFunc(line, func(match []byte) []byte {
+			varName := string(envVarRegex.FindSubmatch(match)[1])
+			if m := string(match); m == TenantIDVariable || m == ClientIDVariable {
+				return match
+			}
+			if resolve, ref, ok := lookupResolver(varName); ok {
+				value, err := resolve(ref)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to resolve ${%s}: %w", varName, err))
+					return match
+				}
+				return []byte(value)
+			}
+
+			name, def, hasDefault := strings.Cut(varName, ":-")
+			if hasDefault {
+				if value := os.Getenv(name); value != "" {
+					return []byte(value)
+				}
+				return []byte(def)
+			}
+			if StrictEnv {
+				if value, ok := os.LookupEnv(name); ok {
+					return []byte(value)
+				}
+			} else if value := os.Getenv(name); value != "" {
+				return []byte(value)
+			}
+
+			if !seen[name] {
+				seen[name] = true
+				unresolved = append(unresolved, name)
+			}
+			return match // Keep original if env var not set
+		})
+	}
+
+	if StrictEnv && len(unresolved) > 0 {
+		errs = append(errs, fmt.Errorf("unset environment variables referenced: %s (set them or use ${VAR:-default})",
+			strings.Join(unresolved, ", ")))
+	}
+	return bytes.Join(lines, nil), errors.Join(errs...)
 }
 
 func applyDefaults(cfg *GatewayConfig) {
@@ -100,72 +197,669 @@ func applyDefaults(cfg *GatewayConfig) {
 	if cfg.Server.ShutdownTimeout == 0 {
 		cfg.Server.ShutdownTimeout = 10 * time.Second
 	}
+	if cfg.Server.UpgradeTimeout == 0 {
+		cfg.Server.UpgradeTimeout = time.Hour
+	}
+	if cfg.Server.ReadHeaderTimeout == 0 {
+		cfg.Server.ReadHeaderTimeout = 10 * time.Second
+	}
+	if cfg.Server.IdleTimeout == 0 {
+		cfg.Server.IdleTimeout = 2 * time.Minute
+	}
+	if cfg.Server.MaxHeaderBytes == 0 {
+		cfg.Server.MaxHeaderBytes = 64 << 10
+	}
+	if c := &cfg.Server.Compression; c.Enabled {
+		if c.MinSize == 0 {
+			c.MinSize = 1 << 10
+		}
+		if c.MaxObjectSize == 0 {
+			c.MaxObjectSize = 1 << 20
+		}
+		if len(c.ContentTypes) == 0 {
+			c.ContentTypes = []string{"text/*", "application/json", "application/xml", "application/javascript"}
+		}
+	}
+	if cfg.Kubernetes.ResyncInterval == 0 {
+		cfg.Kubernetes.ResyncInterval = 5 * time.Minute
+	}
+	if cfg.Admin.Address == "" {
+		cfg.Admin.Address = "127.0.0.1:9091"
+	}
+	if len(cfg.Auth.Services) == 0 {
+		cfg.Auth.Services = []string{"s3"}
+	}
+	if cfg.PoliciesPollInterval == 0 {
+		cfg.PoliciesPollInterval = time.Minute
+	}
+	if cfg.Buckets.TagCacheTTL == 0 {
+		cfg.Buckets.TagCacheTTL = 5 * time.Minute
+	}
+	if cfg.Buckets.ObjectTagCacheTTL == 0 {
+		cfg.Buckets.ObjectTagCacheTTL = time.Minute
+	}
+	if cfg.Admin.MaxGrantDuration == 0 {
+		cfg.Admin.MaxGrantDuration = 4 * time.Hour
+	}
+	if cfg.Admin.Debug.DumpDir == "" {
+		cfg.Admin.Debug.DumpDir = filepath.Join(os.TempDir(), "s3-gateway-dumps")
+	}
+	if cfg.Audit.FailureMode == "" {
+		cfg.Audit.FailureMode = "open"
+	}
+	if cfg.Audit.Sampling.AllowRate == 0 {
+		cfg.Audit.Sampling.AllowRate = 1
+	}
+	if cfg.Audit.Database.Table == "" {
+		cfg.Audit.Database.Table = "audit_entries"
+	}
+	if cfg.Audit.Retention.Interval == 0 {
+		cfg.Audit.Retention.Interval = 24 * time.Hour
+	}
+	if cfg.Validation.PolicyReferences == "" {
+		cfg.Validation.PolicyReferences = "fail"
+	}
+	if cfg.SLO.AvailabilityObjective == 0 {
+		cfg.SLO.AvailabilityObjective = 0.999
+	}
+	if cfg.SLO.LatencyObjective == 0 {
+		cfg.SLO.LatencyObjective = 0.99
+	}
+	if cfg.SLO.LatencyThreshold == 0 {
+		cfg.SLO.LatencyThreshold = 500 * time.Millisecond
+	}
+	if len(cfg.SLO.Windows) == 0 {
+		// Short and long windows for multiwindow burn rate alerts
+		cfg.SLO.Windows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+	}
+	if cfg.CloudWatch.Region == "" {
+		cfg.CloudWatch.Region = cfg.AWS.Region
+	}
+	if cfg.CloudWatch.Audit.FlushInterval == 0 {
+		cfg.CloudWatch.Audit.FlushInterval = 5 * time.Second
+	}
+	if cfg.CloudWatch.EMF.Namespace == "" {
+		cfg.CloudWatch.EMF.Namespace = "S3Gateway"
+	}
+	if cfg.CloudWatch.EMF.Interval == 0 {
+		cfg.CloudWatch.EMF.Interval = time.Minute
+	}
+	if cfg.Alerts.DenySpike.Threshold == 0 {
+		cfg.Alerts.DenySpike.Threshold = 50
+	}
+	if cfg.Alerts.DenySpike.Window == 0 {
+		cfg.Alerts.DenySpike.Window = 5 * time.Minute
+	}
+	if cfg.Alerts.DenySpike.Cooldown == 0 {
+		cfg.Alerts.DenySpike.Cooldown = 15 * time.Minute
+	}
+	if len(cfg.Server.Listeners) == 0 {
+		cfg.Server.Listeners = []ListenerConfig{{Address: fmt.Sprintf(":%d", cfg.Server.Port)}}
+	}
+	for i := range cfg.Server.Listeners {
+		l := &cfg.Server.Listeners[i]
+		if l.Network == "" {
+			l.Network = "tcp"
+		}
+		if l.ReadTimeout == 0 {
+			l.ReadTimeout = cfg.Server.ReadTimeout
+		}
+		if l.WriteTimeout == 0 {
+			l.WriteTimeout = cfg.Server.WriteTimeout
+		}
+		if l.IdleTimeout == 0 {
+			l.IdleTimeout = cfg.Server.IdleTimeout
+		}
+		if l.ReadHeaderTimeout == 0 {
+			l.ReadHeaderTimeout = cfg.Server.ReadHeaderTimeout
+		}
+		if l.MaxHeaderBytes == 0 {
+			l.MaxHeaderBytes = cfg.Server.MaxHeaderBytes
+		}
+	}
 	if cfg.AWS.Region == "" {
 		cfg.AWS.Region = "us-east-1"
 	}
+	if cfg.Backend.Type == "" {
+		cfg.Backend.Type = "s3"
+	}
+	if cfg.AWS.HTTPClient.MaxIdleConns == 0 {
+		cfg.AWS.HTTPClient.MaxIdleConns = 256
+	}
+	if cfg.AWS.HTTPClient.MaxIdleConnsPerHost == 0 {
+		cfg.AWS.HTTPClient.MaxIdleConnsPerHost = 128
+	}
+	if cfg.AWS.HTTPClient.DialTimeout == 0 {
+		cfg.AWS.HTTPClient.DialTimeout = 30 * time.Second
+	}
+	if cfg.AWS.HTTPClient.TLSHandshakeTimeout == 0 {
+		cfg.AWS.HTTPClient.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if cfg.AWS.HTTPClient.IdleConnTimeout == 0 {
+		cfg.AWS.HTTPClient.IdleConnTimeout = 90 * time.Second
+	}
+	if cfg.AWS.Redirects == "" {
+		cfg.AWS.Redirects = "follow"
+	}
+	if cfg.AWS.Timeouts.Metadata == 0 {
+		cfg.AWS.Timeouts.Metadata = 15 * time.Second
+	}
+	if cfg.AWS.Hedge.Delay == 0 {
+		cfg.AWS.Hedge.Delay = 100 * time.Millisecond
+	}
+	if cfg.AWS.Failover.FailureThreshold == 0 {
+		cfg.AWS.Failover.FailureThreshold = 5
+	}
+	if cfg.AWS.Failover.ProbeInterval == 0 {
+		cfg.AWS.Failover.ProbeInterval = 10 * time.Second
+	}
+	if cfg.AWS.Failover.ProbeBucket == "" {
+		cfg.AWS.Failover.ProbeBucket = "gateway-health-probe"
+	}
+	if cfg.Redis.KeyPrefix == "" {
+		cfg.Redis.KeyPrefix = "gateway:"
+	}
+	if cfg.Redis.Timeout == 0 {
+		cfg.Redis.Timeout = 100 * time.Millisecond
+	}
+	if cfg.Mirror.Region == "" {
+		cfg.Mirror.Region = cfg.AWS.Region
+	}
+	if cfg.Mirror.MaxBodyBytes == 0 {
+		cfg.Mirror.MaxBodyBytes = 1 << 20
+	}
+	if cfg.Mirror.MaxInFlight == 0 {
+		cfg.Mirror.MaxInFlight = 64
+	}
+	if cfg.Metrics.Path == "" {
+		cfg.Metrics.Path = "/metrics"
+	}
+	if cfg.Metrics.Labels == nil {
+		cfg.Metrics.Labels = []string{MetricLabelTenant, MetricLabelAction}
+	}
+	if cfg.Metrics.MaxTenants == 0 {
+		cfg.Metrics.MaxTenants = 100
+	}
+	if cfg.Metrics.MaxBuckets == 0 {
+		cfg.Metrics.MaxBuckets = 100
+	}
 	if cfg.Audit.Format == "" {
 		cfg.Audit.Format = "json"
 	}
+	if cfg.ObjectTagging.Enabled && len(cfg.ObjectTagging.Tags) == 0 {
+		cfg.ObjectTagging.Tags = map[string]string{
+			"tenant":             "{tenantId}",
+			"client":             "{clientId}",
+			"gateway-request-id": "{requestId}",
+		}
+	}
+	if cfg.DLP.ServiceTimeout == 0 {
+		cfg.DLP.ServiceTimeout = 5 * time.Second
+	}
+	if cfg.DLP.MaxScanSize == 0 {
+		cfg.DLP.MaxScanSize = 10 << 20
+	}
+	if cfg.UploadLinks.MaxDuration == 0 {
+		cfg.UploadLinks.MaxDuration = 7 * 24 * time.Hour
+	}
+	if cfg.UploadLinks.MaxSize == 0 {
+		cfg.UploadLinks.MaxSize = 5 << 30
+	}
+	for i := range cfg.Redaction.Keys {
+		if cfg.Redaction.Keys[i].Mode == "" {
+			cfg.Redaction.Keys[i].Mode = "hash"
+		}
+	}
+	if cfg.CICredentials.Path == "" {
+		cfg.CICredentials.Path = "/_gateway/ci-credentials"
+	}
+	if cfg.CICredentials.TTL == 0 {
+		cfg.CICredentials.TTL = time.Hour
+	}
+	if cfg.UploadReaper.Interval == 0 {
+		cfg.UploadReaper.Interval = time.Hour
+	}
 	if cfg.Audit.Output == "" {
 		cfg.Audit.Output = "stdout"
 	}
 }
 
 func validateGatewayConfig(cfg *GatewayConfig) error {
-	if cfg.CredentialsFile == "" {
-		return fmt.Errorf("credentialsFile is required")
+	var errs []error
+
+	// Credentials and policies come from a file or an inline section, or in
+	// Kubernetes controller mode from custom resources
+	if cfg.CredentialsFile != "" && cfg.Credentials != nil {
+		errs = append(errs, fmt.Errorf("credentialsFile and inline credentials are mutually exclusive"))
 	}
-	if cfg.PoliciesFile == "" {
-		return fmt.Errorf("policiesFile is required")
+	if cfg.PoliciesFile != "" && (cfg.Policies != nil || cfg.BucketPolicies != nil) {
+		errs = append(errs, fmt.Errorf("policiesFile and inline policies are mutually exclusive"))
 	}
-	return nil
+	if cfg.CredentialsFile == "" && cfg.Credentials == nil && !cfg.Kubernetes.Enabled {
+		errs = append(errs, fmt.Errorf("credentialsFile or inline credentials are required"))
+	}
+	if cfg.PoliciesFile == "" && cfg.Policies == nil && cfg.BucketPolicies == nil && !cfg.Kubernetes.Enabled {
+		errs = append(errs, fmt.Errorf("policiesFile or inline policies are required"))
+	}
+	if IsRemote(cfg.PoliciesFile) && cfg.PoliciesPollInterval < time.Second {
+		errs = append(errs, fmt.Errorf("policiesPollInterval must be at least 1s"))
+	}
+	if err := cfg.Auth.AccessKeys.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Buckets.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Multipart.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateAliases(cfg.Aliases); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.DLP.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.CICredentials.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if u := cfg.UploadLinks; u.Enabled {
+		if !cfg.Admin.Enabled {
+			errs = append(errs, fmt.Errorf("uploadLinks require admin.enabled, as links are created through the admin API"))
+		}
+		if !isHTTPURL(u.BaseURL) {
+			errs = append(errs, fmt.Errorf("uploadLinks.baseUrl must be an http(s) URL, got %q", u.BaseURL))
+		}
+		if u.MaxDuration < 0 || u.MaxSize < 0 {
+			errs = append(errs, fmt.Errorf("uploadLinks.maxDuration and uploadLinks.maxSize must not be negative"))
+		}
+	}
+	for i, rule := range cfg.Redaction.Keys {
+		if !strings.Contains(rule.Pattern, "/") {
+			errs = append(errs, fmt.Errorf("redaction.keys[%d].pattern must match bucket/key, e.g. \"*/pii/*\", got %q", i, rule.Pattern))
+		}
+		if rule.Mode != "hash" && rule.Mode != "remove" {
+			errs = append(errs, fmt.Errorf("redaction.keys[%d].mode must be hash or remove, got %q", i, rule.Mode))
+		}
+	}
+	if len(cfg.ObjectTagging.Tags) > maxObjectTags {
+		errs = append(errs, fmt.Errorf("objectTagging.tags: at most %d tags, the S3 limit per object", maxObjectTags))
+	}
+	for key := range cfg.ObjectTagging.Tags {
+		if key == "" || len(key) > 128 || strings.HasPrefix(key, "aws:") {
+			errs = append(errs, fmt.Errorf("objectTagging.tags: invalid key %q", key))
+		}
+	}
+	if r := cfg.UploadReaper; r.MaxAge < 0 || (r.MaxAge > 0 && r.MaxAge < time.Hour) {
+		errs = append(errs, fmt.Errorf("uploadReaper.maxAge must be at least 1h, so uploads in progress are not aborted"))
+	}
+	if cfg.UploadReaper.Interval < time.Minute {
+		errs = append(errs, fmt.Errorf("uploadReaper.interval must be at least 1m"))
+	}
+	if cfg.CredentialsFile != "" && cfg.CredentialGroups != nil {
+		errs = append(errs, fmt.Errorf("credentialGroups require inline credentials; define groups in the credentials file"))
+	}
+	if cfg.CredentialsFile != "" && cfg.Tenants != nil {
+		errs = append(errs, fmt.Errorf("tenants require inline credentials; define tenants in the credentials file"))
+	}
+	if cfg.Credentials != nil {
+		if err := ValidateCredentials(&CredentialsConfig{
+			Credentials: cfg.Credentials,
+			Groups:      cfg.CredentialGroups,
+			Tenants:     cfg.Tenants,
+		}); err != nil {
+			errs = append(errs, err)
+		}
+		if err := CheckAccessKeys(cfg.Credentials, &cfg.Auth.AccessKeys); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.Policies != nil || cfg.BucketPolicies != nil {
+		if err := ValidatePolicies(&PoliciesConfig{Policies: cfg.Policies, BucketPolicies: cfg.BucketPolicies}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c := cfg.Server.Compression; c.MinSize < 0 || c.MaxObjectSize < 0 {
+		errs = append(errs, fmt.Errorf("server.compression.minSize and maxObjectSize must not be negative"))
+	}
+	if _, err := cfg.Server.TrustedProxyPrefixes(); err != nil {
+		errs = append(errs, fmt.Errorf("server.trustedProxies: %w", err))
+	}
+	if cfg.Server.LoadShedding.MaxInFlight < 0 || cfg.Server.LoadShedding.MaxInFlightPerTenant < 0 ||
+		cfg.Server.LoadShedding.TenantQueueTimeout < 0 {
+		errs = append(errs, fmt.Errorf("server.loadShedding limits must not be negative"))
+	}
+	if cfg.Redis.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("redis.timeout must not be negative"))
+	}
+	if cfg.PolicyCanary.Percent < 0 || cfg.PolicyCanary.Percent > 100 {
+		errs = append(errs, fmt.Errorf("policyCanary.percent must be between 0 and 100"))
+	}
+	if IsRemote(cfg.PolicyCanary.PoliciesFile) {
+		errs = append(errs, fmt.Errorf("policyCanary.policiesFile must be a local file"))
+	}
+	if cfg.Mirror.Endpoint != "" {
+		if cfg.Mirror.SampleRate <= 0 || cfg.Mirror.SampleRate > 1 {
+			errs = append(errs, fmt.Errorf("mirror.sampleRate must be greater than 0 and at most 1"))
+		}
+		if cfg.Mirror.MaxBodyBytes < 0 || cfg.Mirror.MaxInFlight < 0 {
+			errs = append(errs, fmt.Errorf("mirror.maxBodyBytes and mirror.maxInFlight must not be negative"))
+		}
+	}
+	if cfg.Metrics.MaxTenants < 0 || cfg.Metrics.MaxBuckets < 0 {
+		errs = append(errs, fmt.Errorf("metrics.maxTenants and metrics.maxBuckets must not be negative"))
+	}
+	for _, label := range cfg.Metrics.Labels {
+		switch label {
+		case MetricLabelTenant, MetricLabelClient, MetricLabelBucket, MetricLabelAction:
+		default:
+			errs = append(errs, fmt.Errorf("metrics.labels: unknown label %q (want tenant, client, bucket or action)", label))
+		}
+	}
+	if cfg.Validation.PolicyReferences != "fail" && cfg.Validation.PolicyReferences != "warn" {
+		errs = append(errs, fmt.Errorf("validation.policyReferences must be fail or warn, got %q", cfg.Validation.PolicyReferences))
+	}
+	if cfg.Audit.FailureMode != "open" && cfg.Audit.FailureMode != "closed" {
+		errs = append(errs, fmt.Errorf("audit.failureMode must be open or closed, got %q", cfg.Audit.FailureMode))
+	}
+	if rate := cfg.Audit.Sampling.AllowRate; rate <= 0 || rate > 1 {
+		errs = append(errs, fmt.Errorf("audit.sampling.allowRate must be greater than 0 and at most 1, got %v", rate))
+	}
+	if db := cfg.Audit.Database; db.Enabled {
+		if db.Driver == "" || db.DSN == "" {
+			errs = append(errs, fmt.Errorf("audit.database.driver and audit.database.dsn are required when the audit database is enabled"))
+		}
+		if !sqlIdentifierRegex.MatchString(db.Table) {
+			errs = append(errs, fmt.Errorf("audit.database.table must be a plain SQL identifier, got %q", db.Table))
+		}
+	}
+	if r := cfg.Audit.Retention; r.MaxAgeDays < 0 || r.MaxSize < 0 || r.MaxEntries < 0 || r.Interval < 0 {
+		errs = append(errs, fmt.Errorf("audit.retention limits and interval must not be negative"))
+	} else if r.Enabled() && cfg.Audit.Output != "file" && cfg.Audit.Output != "both" && !cfg.Audit.Database.Enabled {
+		errs = append(errs, fmt.Errorf("audit.retention requires a file output or the audit database"))
+	} else if r.Archive.Prefix != "" && r.Archive.Bucket == "" {
+		errs = append(errs, fmt.Errorf("audit.retention.archive.prefix requires audit.retention.archive.bucket"))
+	}
+	if spike := cfg.Alerts.DenySpike; spike.Enabled {
+		if spike.Threshold < 0 || spike.Window < 0 || spike.Cooldown < 0 {
+			errs = append(errs, fmt.Errorf("alerts.denySpike threshold, window and cooldown must not be negative"))
+		}
+		for tenant, t := range spike.Tenants {
+			if t.Threshold < 0 || t.Window < 0 {
+				errs = append(errs, fmt.Errorf("alerts.denySpike.tenants.%s threshold and window must not be negative", tenant))
+			}
+		}
+		if spike.WebhookURL != "" {
+			if u, err := url.Parse(spike.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("alerts.denySpike.webhookUrl must be an http(s) URL, got %q", spike.WebhookURL))
+			}
+		}
+	}
+	if cfg.SLO.Enabled {
+		for _, objective := range []struct {
+			name  string
+			value float64
+		}{
+			{"availabilityObjective", cfg.SLO.AvailabilityObjective},
+			{"latencyObjective", cfg.SLO.LatencyObjective},
+		} {
+			if objective.value <= 0 || objective.value >= 1 {
+				errs = append(errs, fmt.Errorf("slo.%s must be between 0 and 1 exclusive, got %v", objective.name, objective.value))
+			}
+		}
+		if cfg.SLO.LatencyThreshold < 0 {
+			errs = append(errs, fmt.Errorf("slo.latencyThreshold must not be negative"))
+		}
+		for i, w := range cfg.SLO.Windows {
+			if w < time.Minute || w > 31*24*time.Hour {
+				errs = append(errs, fmt.Errorf("slo.windows[%d] must be between 1m and 744h, got %s", i, w))
+			}
+		}
+	}
+	for i, hook := range cfg.Hooks {
+		switch hook.Type {
+		case "setHeaders":
+			if len(hook.Headers) == 0 {
+				errs = append(errs, fmt.Errorf("hooks[%d]: headers is required for setHeaders", i))
+			}
+			if hook.Stage != "" && hook.Stage != "request" && hook.Stage != "response" {
+				errs = append(errs, fmt.Errorf("hooks[%d].stage must be request or response, got %q", i, hook.Stage))
+			}
+		case "rewriteKey":
+			if hook.From == "" && hook.To == "" {
+				errs = append(errs, fmt.Errorf("hooks[%d]: from or to is required for rewriteKey", i))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("hooks[%d].type must be setHeaders or rewriteKey, got %q", i, hook.Type))
+		}
+	}
+	if cfg.Chaos.Enabled {
+		for i, rule := range cfg.Chaos.Rules {
+			if len(rule.Actions) == 0 {
+				errs = append(errs, fmt.Errorf("chaos.rules[%d]: actions is required", i))
+			}
+			for _, rate := range []struct {
+				name  string
+				value float64
+			}{
+				{"latencyRate", rule.LatencyRate},
+				{"errorRate", rule.ErrorRate},
+				{"resetRate", rule.ResetRate},
+			} {
+				if rate.value < 0 || rate.value > 1 {
+					errs = append(errs, fmt.Errorf("chaos.rules[%d].%s must be between 0 and 1, got %v", i, rate.name, rate.value))
+				}
+			}
+			if rule.ErrorRate+rule.ResetRate > 1 {
+				errs = append(errs, fmt.Errorf("chaos.rules[%d]: errorRate and resetRate must not add up to more than 1", i))
+			}
+			if rule.Latency < 0 {
+				errs = append(errs, fmt.Errorf("chaos.rules[%d].latency must not be negative", i))
+			}
+		}
+	}
+	if cfg.CloudWatch.Audit.Enabled && cfg.CloudWatch.Audit.LogGroup == "" {
+		errs = append(errs, fmt.Errorf("cloudwatch.audit.logGroup is required when the CloudWatch audit sink is enabled"))
+	}
+	if cfg.CloudWatch.Audit.FlushInterval < 0 || cfg.CloudWatch.EMF.Interval < 0 {
+		errs = append(errs, fmt.Errorf("cloudwatch intervals must not be negative"))
+	}
+	if cfg.Admin.Enabled && cfg.Admin.Token == "" {
+		errs = append(errs, fmt.Errorf("admin.token is required when admin is enabled"))
+	}
+	if cfg.Admin.MaxGrantDuration < 0 {
+		errs = append(errs, fmt.Errorf("admin.maxGrantDuration must not be negative"))
+	}
+	for i, l := range cfg.Server.Listeners {
+		if l.Address == "" {
+			errs = append(errs, fmt.Errorf("server.listeners[%d]: address is required", i))
+		}
+		if l.Network != "tcp" && l.Network != "unix" {
+			errs = append(errs, fmt.Errorf("server.listeners[%d]: network must be tcp or unix, got %q", i, l.Network))
+		}
+		if (l.TLS.CertFile == "") != (l.TLS.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("server.listeners[%d]: tls requires both certFile and keyFile", i))
+		}
+		if l.ReadHeaderTimeout < 0 || l.IdleTimeout < 0 || l.MaxHeaderBytes < 0 {
+			errs = append(errs, fmt.Errorf("server.listeners[%d]: readHeaderTimeout, idleTimeout and maxHeaderBytes must not be negative", i))
+		}
+	}
+	switch cfg.Backend.Type {
+	case "s3", "memory":
+	case "filesystem":
+		if cfg.Backend.Filesystem.Root == "" {
+			errs = append(errs, fmt.Errorf("backend.filesystem.root is required"))
+		}
+	case "azure":
+		if cfg.Backend.Azure.AccountName == "" || cfg.Backend.Azure.AccountKey == "" {
+			errs = append(errs, fmt.Errorf("backend.azure: accountName and accountKey are required"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("backend.type must be s3, filesystem, memory or azure, got %q", cfg.Backend.Type))
+	}
+	if cfg.AWS.Redirects != "follow" && cfg.AWS.Redirects != "passthrough" {
+		errs = append(errs, fmt.Errorf("aws.redirects must be follow or passthrough, got %q", cfg.AWS.Redirects))
+	}
+	for i, route := range cfg.AWS.RegionRoutes {
+		if len(route.Buckets) == 0 || route.Region == "" {
+			errs = append(errs, fmt.Errorf("aws.regionRoutes[%d]: buckets and region are required", i))
+		}
+	}
+	if cfg.AWS.Failover.Enabled && cfg.AWS.Failover.Endpoint == "" && cfg.AWS.Failover.Region == "" {
+		errs = append(errs, fmt.Errorf("aws.failover: endpoint or region is required"))
+	}
+	return errors.Join(errs...)
 }
 
-func validateCredentials(cfg *CredentialsConfig) error {
+// ValidateCredentials checks required fields and access key uniqueness
+func ValidateCredentials(cfg *CredentialsConfig) error {
+	var errs []error
 	seen := make(map[string]bool)
 	for i, cred := range cfg.Credentials {
 		if cred.AccessKey == "" {
-			return fmt.Errorf("credentials[%d]: accessKey is required", i)
+			errs = append(errs, fmt.Errorf("credentials[%d]: accessKey is required", i))
 		}
 		if cred.SecretKey == "" {
-			return fmt.Errorf("credentials[%d]: secretKey is required", i)
+			errs = append(errs, fmt.Errorf("credentials[%d]: secretKey is required", i))
 		}
 		if cred.ClientID == "" {
-			return fmt.Errorf("credentials[%d]: clientId is required", i)
+			errs = append(errs, fmt.Errorf("credentials[%d]: clientId is required", i))
 		}
 		if cred.TenantID == "" {
-			return fmt.Errorf("credentials[%d]: tenantId is required", i)
+			errs = append(errs, fmt.Errorf("credentials[%d]: tenantId is required", i))
 		}
-		if seen[cred.AccessKey] {
-			return fmt.Errorf("credentials[%d]: duplicate accessKey %q", i, cred.AccessKey)
+		if cred.AccessKey != "" && seen[cred.AccessKey] {
+			errs = append(errs, fmt.Errorf("credentials[%d]: duplicate accessKey %q", i, cred.AccessKey))
 		}
 		seen[cred.AccessKey] = true
 	}
-	return nil
+	errs = append(errs, validateGroups(cfg)...)
+	_, tenantErrs := resolveTenants(cfg.Tenants)
+	errs = append(errs, tenantErrs...)
+	return errors.Join(errs...)
 }
 
-func validatePolicies(cfg *PoliciesConfig) error {
+// ValidatePolicies checks policy names and statement fields
+func ValidatePolicies(cfg *PoliciesConfig) error {
+	var errs []error
 	seen := make(map[string]bool)
 	for i, policy := range cfg.Policies {
 		if policy.Name == "" {
-			return fmt.Errorf("policies[%d]: name is required", i)
+			errs = append(errs, fmt.Errorf("policies[%d]: name is required", i))
+		}
+		if policy.Name != "" && seen[policy.Name] {
+			errs = append(errs, fmt.Errorf("policies[%d]: duplicate policy name %q", i, policy.Name))
+		}
+		seen[policy.Name] = true
+
+		for j, stmt := range policy.Statements {
+			path := fmt.Sprintf("policies[%d].statements[%d]", i, j)
+			errs = append(errs, validateStatement(path, &stmt)...)
+			if len(stmt.Principals) > 0 {
+				errs = append(errs, fmt.Errorf("%s: principals are only valid in bucketPolicies", path))
+			}
+		}
+	}
+
+	// Names are shared with identity policies, so decisions name one policy
+	for i, policy := range cfg.BucketPolicies {
+		if policy.Name == "" {
+			errs = append(errs, fmt.Errorf("bucketPolicies[%d]: name is required", i))
 		}
-		if seen[policy.Name] {
-			return fmt.Errorf("policies[%d]: duplicate policy name %q", i, policy.Name)
+		if policy.Name != "" && seen[policy.Name] {
+			errs = append(errs, fmt.Errorf("bucketPolicies[%d]: duplicate policy name %q", i, policy.Name))
 		}
 		seen[policy.Name] = true
+		if len(policy.Buckets) == 0 {
+			errs = append(errs, fmt.Errorf("bucketPolicies[%d]: buckets is required", i))
+		}
 
 		for j, stmt := range policy.Statements {
-			if stmt.Effect != EffectAllow && stmt.Effect != EffectDeny {
-				return fmt.Errorf("policies[%d].statements[%d]: effect must be Allow or Deny", i, j)
+			path := fmt.Sprintf("bucketPolicies[%d].statements[%d]", i, j)
+			errs = append(errs, validateStatement(path, &stmt)...)
+			if len(stmt.Principals) == 0 {
+				errs = append(errs, fmt.Errorf("%s: principals is required", path))
 			}
-			if len(stmt.Actions) == 0 {
-				return fmt.Errorf("policies[%d].statements[%d]: actions is required", i, j)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateStatement(path string, stmt *Statement) []error {
+	var errs []error
+	if stmt.Effect != EffectAllow && stmt.Effect != EffectDeny {
+		errs = append(errs, fmt.Errorf("%s: effect must be Allow or Deny", path))
+	}
+	if len(stmt.Actions) == 0 {
+		errs = append(errs, fmt.Errorf("%s: actions is required", path))
+	}
+	if len(stmt.Resources) == 0 {
+		errs = append(errs, fmt.Errorf("%s: resources is required", path))
+	}
+	for op, block := range stmt.Conditions {
+		for key, values := range block {
+			if len(values) == 0 {
+				errs = append(errs, fmt.Errorf("%s.conditions.%s: %s has no values", path, op, key))
 			}
-			if len(stmt.Resources) == 0 {
-				return fmt.Errorf("policies[%d].statements[%d]: resources is required", i, j)
+			for _, v := range values {
+				if err := checkConditionValue(op, v); err != nil {
+					errs = append(errs, fmt.Errorf("%s.conditions.%s: %s: %w", path, op, key, err))
+				}
 			}
 		}
 	}
+	if rl := stmt.RateLimit; rl != nil {
+		if stmt.Effect != EffectAllow {
+			errs = append(errs, fmt.Errorf("%s.rateLimit: only Allow statements can be rate limited", path))
+		}
+		if rl.RequestsPerSecond <= 0 {
+			errs = append(errs, fmt.Errorf("%s.rateLimit: requestsPerSecond must be positive", path))
+		}
+		if rl.Burst < 0 {
+			errs = append(errs, fmt.Errorf("%s.rateLimit: burst must not be negative", path))
+		}
+	}
+	return errs
+}
+
+// checkConditionValue rejects values that numeric and regex operators could
+// never match. Policy variables stand for IDs, which are quoted in regexes.
+func checkConditionValue(op, value string) error {
+	if _, unqualified, ok := strings.Cut(op, ":"); ok {
+		op = unqualified
+	}
+	switch {
+	case strings.HasPrefix(op, "Numeric"):
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case strings.HasSuffix(op, "MatchesRegex"):
+		value = strings.NewReplacer(TenantIDVariable, "id", ClientIDVariable, "id").Replace(value)
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
 	return nil
 }
+
+// TrustedProxyPrefixes parses TrustedProxies; bare IPs become single-host prefixes
+func (c *ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	var errs []error
+	for _, entry := range c.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid trusted proxy %q: must be a CIDR or IP address", entry))
+			continue
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return prefixes, nil
+}
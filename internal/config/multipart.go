@@ -0,0 +1,52 @@
+package config
+
+import "fmt"
+
+// maxUploadParts is the S3 limit on the parts of a multipart upload
+const maxUploadParts = 10000
+
+// Limits returns the multipart limits of a tenant
+func (m *MultipartConfig) Limits(tenantID string) MultipartLimits {
+	if l, ok := m.Tenants[tenantID]; ok {
+		return l
+	}
+	return m.MultipartLimits
+}
+
+// Enabled reports whether any multipart limit is set
+func (m *MultipartConfig) Enabled() bool {
+	if m.MultipartLimits != (MultipartLimits{}) {
+		return true
+	}
+	for _, l := range m.Tenants {
+		if l != (MultipartLimits{}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MultipartConfig) validate() error {
+	if err := m.MultipartLimits.validate("multipart"); err != nil {
+		return err
+	}
+	for tenant, l := range m.Tenants {
+		if err := l.validate(fmt.Sprintf("multipart.tenants[%s]", tenant)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l MultipartLimits) validate(field string) error {
+	if l.MinPartSize < 0 || l.MaxPartSize < 0 || l.MaxUploadSize < 0 {
+		return fmt.Errorf("%s: sizes must not be negative", field)
+	}
+	if l.MaxPartSize > 0 && l.MinPartSize > l.MaxPartSize {
+		return fmt.Errorf("%s.minPartSize must not exceed maxPartSize", field)
+	}
+	if l.MaxParts < 0 || l.MaxParts > maxUploadParts {
+		return fmt.Errorf("%s.maxParts must be between 0 and %d", field, maxUploadParts)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+)
+
+// redacted replaces secret values in inspection output
+const redacted = "REDACTED"
+
+// Configuration fields holding secrets are tagged for Redacted:
+//
+//	secret:"true"      the whole value is replaced
+//	secret:"userinfo"  a URL whose credentials are replaced, keeping the host
+
+// Redacted returns a copy of the configuration with secrets replaced, safe
+// to show to operators
+func (c *GatewayConfig) Redacted() *GatewayConfig {
+	out := *c
+	redactFields(reflect.ValueOf(&out).Elem())
+	return &out
+}
+
+// redactFields replaces the tagged strings under v, which must be settable.
+// Slices, maps and pointers leading to secrets are copied first so the
+// original configuration is not modified.
+func redactFields(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			switch mode := f.Tag.Get("secret"); mode {
+			case "":
+				redactFields(v.Field(i))
+			case "userinfo":
+				v.Field(i).SetString(redactUserinfo(v.Field(i).String()))
+			default:
+				v.Field(i).SetString(redactValue(v.Field(i).String()))
+			}
+		}
+	case reflect.Pointer:
+		if v.IsNil() || !hasSecrets(v.Type().Elem(), nil) {
+			return
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(v.Elem())
+		redactFields(p.Elem())
+		v.Set(p)
+	case reflect.Slice:
+		if v.IsNil() || !hasSecrets(v.Type().Elem(), nil) {
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(s, v)
+		for i := 0; i < s.Len(); i++ {
+			redactFields(s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Map:
+		if v.IsNil() || !hasSecrets(v.Type().Elem(), nil) {
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(iter.Value())
+			redactFields(e)
+			m.SetMapIndex(iter.Key(), e)
+		}
+		v.Set(m)
+	}
+}
+
+// hasSecrets reports whether values of t contain tagged fields
+func hasSecrets(t reflect.Type, seen map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return hasSecrets(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return false
+		}
+		if seen == nil {
+			seen = make(map[reflect.Type]bool)
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.IsExported() && (f.Tag.Get("secret") != "" || hasSecrets(f.Type, seen)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func redactValue(v string) string {
+	if v == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactUserinfo replaces the credentials of a URL, keeping the host
+// visible; a URL that does not parse is replaced entirely
+func redactUserinfo(v string) string {
+	u, err := url.Parse(v)
+	if err != nil {
+		return redactValue(v)
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	return u.String()
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+	"sync"
+)
+
+// Resolver returns the value for a ${scheme:ref} reference in a config file
+type Resolver func(ref string) (string, error)
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = make(map[string]Resolver)
+)
+
+// RegisterResolver makes ${scheme:ref} references resolve through r, e.g.
+// ${aws-secrets:name} or ${ssm:/path}
+func RegisterResolver(scheme string, r Resolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	resolvers[scheme] = r
+}
+
+// lookupResolver splits a reference into a registered scheme's resolver and
+// the scheme-specific part
+func lookupResolver(name string) (Resolver, string, bool) {
+	scheme, ref, found := strings.Cut(name, ":")
+	if !found {
+		return nil, "", false
+	}
+
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	r, ok := resolvers[scheme]
+	return r, ref, ok
+}
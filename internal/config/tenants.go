@@ -0,0 +1,119 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// tenantAccess is the effective policies and scopes of a tenant, including
+// those inherited from its ancestors
+type tenantAccess struct {
+	policies []string
+	scopes   []string
+}
+
+// resolveTenants computes the effective access of every tenant in the
+// hierarchy, reporting unknown parents, cycles and inherited values the
+// parent does not have
+func resolveTenants(tenants []Tenant) (map[string]tenantAccess, []error) {
+	var errs []error
+	index := make(map[string]int, len(tenants))
+	for i, t := range tenants {
+		if t.ID == "" {
+			errs = append(errs, fmt.Errorf("tenants[%d]: id is required", i))
+			continue
+		}
+		if _, ok := index[t.ID]; ok {
+			errs = append(errs, fmt.Errorf("tenants[%d]: duplicate tenant id %q", i, t.ID))
+			continue
+		}
+		index[t.ID] = i
+	}
+
+	// Tenants in failed are reported once and do not resolve, nor do their
+	// descendants
+	resolved := make(map[string]tenantAccess, len(tenants))
+	failed := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var resolve func(id string) (tenantAccess, bool)
+	resolve = func(id string) (tenantAccess, bool) {
+		if access, ok := resolved[id]; ok {
+			return access, true
+		}
+		if failed[id] {
+			return tenantAccess{}, false
+		}
+		i := index[id]
+		t := tenants[i]
+		if visiting[id] {
+			errs = append(errs, fmt.Errorf("tenants[%d].parent: tenant %q is its own ancestor", i, id))
+			failed[id] = true
+			return tenantAccess{}, false
+		}
+
+		access := tenantAccess{policies: t.Policies, scopes: t.Scopes}
+		if t.Parent != "" {
+			if _, ok := index[t.Parent]; !ok {
+				errs = append(errs, fmt.Errorf("tenants[%d].parent: tenant %q does not exist", i, t.Parent))
+				failed[id] = true
+				return tenantAccess{}, false
+			}
+			visiting[id] = true
+			parent, ok := resolve(t.Parent)
+			delete(visiting, id)
+			if !ok {
+				failed[id] = true
+				return tenantAccess{}, false
+			}
+			policies, err := inherit(parent.policies, t.InheritPolicies, fmt.Sprintf("tenants[%d].inheritPolicies", i), "policy", t.Parent)
+			errs = append(errs, err...)
+			scopes, err := inherit(parent.scopes, t.InheritScopes, fmt.Sprintf("tenants[%d].inheritScopes", i), "scope", t.Parent)
+			errs = append(errs, err...)
+			access.policies = appendUnique(access.policies, policies)
+			access.scopes = appendUnique(access.scopes, scopes)
+		}
+		resolved[id] = access
+		return access, true
+	}
+	for _, t := range tenants {
+		if _, ok := index[t.ID]; ok {
+			resolve(t.ID)
+		}
+	}
+	return resolved, errs
+}
+
+// inherit returns the subset of a parent's values a child inherits: all of
+// them when subset is nil, otherwise those listed
+func inherit(parent, subset []string, field, kind, parentID string) ([]string, []error) {
+	if subset == nil {
+		return parent, nil
+	}
+	var errs []error
+	for j, v := range subset {
+		if !slices.Contains(parent, v) {
+			errs = append(errs, fmt.Errorf("%s[%d]: %s %q is not granted to parent tenant %q", field, j, kind, v, parentID))
+		}
+	}
+	return subset, errs
+}
+
+// CheckTenantPolicyReferences reports tenants naming policies that do not exist
+func CheckTenantPolicyReferences(tenants []Tenant, policies []Policy) error {
+	names := make(map[string]bool, len(policies))
+	for _, p := range policies {
+		names[p.Name] = true
+	}
+
+	var errs []error
+	for i, t := range tenants {
+		for j, name := range t.Policies {
+			if !names[name] {
+				errs = append(errs, fmt.Errorf("tenants[%d].policies[%d]: policy %q referenced by tenant %q does not exist",
+					i, j, name, t.ID))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveCredentials_Tenants(t *testing.T) {
+	cfg := &CredentialsConfig{
+		Tenants: []Tenant{
+			{ID: "team-a", Parent: "retail", Scopes: []string{"retail-team-a-*"}},
+			{ID: "retail", Policies: []string{"retail-read"}, Scopes: []string{"retail-shared-*", "retail-archive-*"}},
+			{ID: "checkout", Parent: "team-a", Policies: []string{"checkout-write"},
+				InheritScopes: []string{"retail-shared-*"}},
+			{ID: "isolated", Parent: "retail", InheritPolicies: []string{}, InheritScopes: []string{}},
+		},
+		Credentials: []Credential{
+			{AccessKey: "A", SecretKey: "s", ClientID: "a", TenantID: "checkout", Scopes: []string{"checkout-*"}},
+			{AccessKey: "B", SecretKey: "s", ClientID: "b", TenantID: "team-a"},
+			{AccessKey: "C", SecretKey: "s", ClientID: "c", TenantID: "isolated", Policies: []string{"own"}},
+			{AccessKey: "D", SecretKey: "s", ClientID: "d", TenantID: "unlisted", Policies: []string{"own"}},
+		},
+	}
+	if err := ValidateCredentials(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		client   string
+		policies []string
+		scopes   []string
+	}{
+		{"a", []string{"checkout-write", "retail-read"}, []string{"checkout-*", "retail-shared-*"}},
+		{"b", []string{"retail-read"}, []string{"retail-team-a-*", "retail-shared-*", "retail-archive-*"}},
+		{"c", []string{"own"}, nil},
+		{"d", []string{"own"}, nil},
+	}
+	resolved := ResolveCredentials(cfg)
+	for i, tt := range tests {
+		if got := resolved[i].Policies; !reflect.DeepEqual(got, tt.policies) {
+			t.Errorf("client %s: policies = %v, want %v", tt.client, got, tt.policies)
+		}
+		if got := resolved[i].Scopes; !reflect.DeepEqual(got, tt.scopes) {
+			t.Errorf("client %s: scopes = %v, want %v", tt.client, got, tt.scopes)
+		}
+	}
+}
+
+func TestValidateCredentials_Tenants(t *testing.T) {
+	cfg := &CredentialsConfig{Tenants: []Tenant{
+		{ID: "a", Parent: "b"},
+		{ID: "b", Parent: "a"},
+		{ID: "c", Parent: "missing"},
+		{ID: "d", Parent: "c"},
+		{ID: "e", Scopes: []string{"e-*"}},
+		{ID: "f", Parent: "e", InheritScopes: []string{"e-*", "other-*"}},
+		{ID: "e"},
+		{},
+	}}
+	err := ValidateCredentials(cfg)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{
+		`tenants[0].parent: tenant "a" is its own ancestor`,
+		`tenants[2].parent: tenant "missing" does not exist`,
+		`tenants[5].inheritScopes[1]: scope "other-*" is not granted to parent tenant "e"`,
+		`tenants[6]: duplicate tenant id "e"`,
+		"tenants[7]: id is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q, got %v", want, err)
+		}
+	}
+	if n := strings.Count(err.Error(), `"missing" does not exist`); n != 1 {
+		t.Errorf("unknown parent reported %d times", n)
+	}
+}
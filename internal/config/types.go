@@ -4,11 +4,446 @@ import "time"
 
 // GatewayConfig holds the main configuration for the gateway
 type GatewayConfig struct {
-	Server          ServerConfig `yaml:"server"`
-	AWS             AWSConfig    `yaml:"aws"`
-	CredentialsFile string       `yaml:"credentialsFile"`
-	PoliciesFile    string       `yaml:"policiesFile"`
-	Audit           AuditConfig  `yaml:"audit"`
+	Server          ServerConfig     `yaml:"server"`
+	AWS             AWSConfig        `yaml:"aws"`
+	Backend         BackendConfig    `yaml:"backend"`
+	CredentialsFile string           `yaml:"credentialsFile"`
+	PoliciesFile    string           `yaml:"policiesFile"` // a path, or an s3:// or http(s):// URL
+	Audit           AuditConfig      `yaml:"audit"`
+	Metrics         MetricsConfig    `yaml:"metrics"`
+	Admin           AdminConfig      `yaml:"admin"`
+	Kubernetes      KubernetesConfig `yaml:"kubernetes"`
+	Validation      ValidationConfig `yaml:"validation"`
+	GeoIP           GeoIPConfig      `yaml:"geoip"`
+	Alerts          AlertsConfig     `yaml:"alerts"`
+	CloudWatch      CloudWatchConfig `yaml:"cloudwatch"`
+	SLO             SLOConfig        `yaml:"slo"`
+	Chaos           ChaosConfig      `yaml:"chaos"`
+	Hooks           []HookConfig     `yaml:"hooks"`
+	Auth            AuthConfig       `yaml:"auth"`
+	Buckets         BucketsConfig    `yaml:"buckets"`
+	Redis           RedisConfig      `yaml:"redis"`
+
+	// PolicyCanary evaluates requests against a second policies file too
+	PolicyCanary PolicyCanaryConfig `yaml:"policyCanary"`
+	// Mirror duplicates a sample of allowed requests to a staging endpoint
+	Mirror MirrorConfig `yaml:"mirror"`
+	// Operations lists the S3 API operations served, e.g. [GetObject,
+	// ListObjectsV2]; others are answered with 501 NotImplemented. Defaults
+	// to every operation the backends support.
+	Operations []string `yaml:"operations"`
+	// Multipart limits the parts of multipart uploads
+	Multipart MultipartConfig `yaml:"multipart"`
+	// UploadReaper aborts multipart uploads abandoned in tenant buckets
+	UploadReaper UploadReaperConfig `yaml:"uploadReaper"`
+	// Aliases are names clients address as buckets, each standing for a
+	// prefix of a real bucket
+	Aliases []AliasConfig `yaml:"aliases"`
+	// DLP scans uploads for sensitive data
+	DLP DLPConfig `yaml:"dlp"`
+	// ObjectTagging tags uploads with the identity of the uploader
+	ObjectTagging ObjectTaggingConfig `yaml:"objectTagging"`
+	// CICredentials exchanges CI jobs' OIDC tokens for short-lived credentials
+	CICredentials CICredentialsConfig `yaml:"ciCredentials"`
+	// UploadLinks are single-use upload URLs created through the admin API
+	UploadLinks UploadLinksConfig `yaml:"uploadLinks"`
+	// Redaction hides sensitive object keys in audit entries and logs
+	Redaction RedactionConfig `yaml:"redaction"`
+
+	// PoliciesPollInterval is how often a policiesFile URL is fetched again
+	PoliciesPollInterval time.Duration `yaml:"policiesPollInterval"`
+
+	// Credentials, CredentialGroups, Tenants, Policies and BucketPolicies
+	// embed the sections inline instead of referencing credentialsFile and policiesFile
+	Credentials      []Credential      `yaml:"credentials,omitempty"`
+	CredentialGroups []CredentialGroup `yaml:"credentialGroups,omitempty"`
+	Tenants          []Tenant          `yaml:"tenants,omitempty"`
+	Policies         []Policy          `yaml:"policies,omitempty"`
+	BucketPolicies   []BucketPolicy    `yaml:"bucketPolicies,omitempty"`
+}
+
+// AuthConfig restricts the credential scope of request signatures, so keys
+// issued for one environment are not accepted by another
+type AuthConfig struct {
+	Regions    []string        `yaml:"regions"`  // empty accepts any region; credentials may override
+	Services   []string        `yaml:"services"` // defaults to s3
+	AccessKeys AccessKeyPolicy `yaml:"accessKeys"`
+}
+
+// BucketsConfig governs buckets created through the gateway. Created names
+// must also fall within the creating credential's scopes.
+type BucketsConfig struct {
+	// NamePattern is a regular expression created bucket names must match,
+	// with {tenantId} replaced by the tenant, e.g. "^{tenantId}-[a-z0-9-]+$"
+	NamePattern string `yaml:"namePattern"`
+	// TagCacheTTL is how long bucket tags used by s3:ResourceTag/<key>
+	// conditions are cached
+	TagCacheTTL time.Duration `yaml:"tagCacheTTL"`
+	// ObjectTagCacheTTL is how long object tags used by
+	// s3:ExistingObjectTag/<key> conditions are cached. Writes through the
+	// gateway drop an object's entry.
+	ObjectTagCacheTTL time.Duration `yaml:"objectTagCacheTTL"`
+	// Listing caps the page size of ListBucket requests
+	Listing ListingLimits `yaml:"listing"`
+}
+
+// MultipartConfig limits multipart uploads per tenant. Part sizes and
+// counts are checked at UploadPart and CompleteMultipartUpload.
+type MultipartConfig struct {
+	MultipartLimits `yaml:",inline"`
+	// Tenants override the limits above for individual tenants
+	Tenants map[string]MultipartLimits `yaml:"tenants"`
+}
+
+// MultipartLimits constrain the parts of a multipart upload; zero disables a
+// limit. MinPartSize applies to every part but the last.
+type MultipartLimits struct {
+	MinPartSize   int64 `yaml:"minPartSize"`   // bytes
+	MaxPartSize   int64 `yaml:"maxPartSize"`   // bytes
+	MaxParts      int   `yaml:"maxParts"`      // at most 10000
+	MaxUploadSize int64 `yaml:"maxUploadSize"` // bytes, all parts together
+}
+
+// UploadReaperConfig aborts multipart uploads initiated more than MaxAge ago
+// in buckets within a credential's scopes, checking every Interval. Zero
+// MaxAge disables it; only the S3 backend supports it.
+type UploadReaperConfig struct {
+	MaxAge   time.Duration `yaml:"maxAge"`
+	Interval time.Duration `yaml:"interval"` // defaults to 1h
+}
+
+// AliasConfig is a named view of a bucket, in the manner of S3 Access
+// Points. Requests addressed to Name go to Bucket, may only reach keys under
+// Prefix, and must be allowed by Policies as well as the credential's own.
+type AliasConfig struct {
+	Name     string   `yaml:"name"`
+	Bucket   string   `yaml:"bucket"`
+	Prefix   string   `yaml:"prefix"`   // e.g. "reports/"; empty allows the whole bucket
+	Policies []string `yaml:"policies"` // evaluated in addition to the credential's
+}
+
+// DLPConfig scans PutObject bodies for sensitive data with the detectors
+// and the scanning service. The first rule matching an upload decides what
+// happens when data is found; uploads matching no rule are not scanned.
+type DLPConfig struct {
+	// Detectors are regular expressions by finding name, e.g.
+	// ssn: '\b\d{3}-\d{2}-\d{4}\b'
+	Detectors map[string]string `yaml:"detectors"`
+	// ServiceURL receives upload bodies by POST and answers with the names
+	// of its findings as {"findings": [...]}
+	ServiceURL     string        `yaml:"serviceUrl"`
+	ServiceTimeout time.Duration `yaml:"serviceTimeout"` // defaults to 5s
+	// MaxScanSize is the largest body scanned, in bytes; defaults to 10 MiB.
+	// Larger uploads are rejected by block rules and pass other rules unscanned.
+	MaxScanSize int64     `yaml:"maxScanSize"`
+	Rules       []DLPRule `yaml:"rules"`
+}
+
+// DLP rule actions
+const (
+	DLPActionBlock = "block" // reject the upload
+	DLPActionTag   = "tag"   // store it with a tag naming the findings
+	DLPActionAudit = "audit" // store it, recording the findings in the audit log
+)
+
+// DLPRule selects uploads to scan and what to do with findings. Empty
+// filters match every upload.
+type DLPRule struct {
+	Tenants  []string `yaml:"tenants"`  // tenant IDs
+	Buckets  []string `yaml:"buckets"`  // bucket patterns, e.g. tenant-001-*
+	Prefixes []string `yaml:"prefixes"` // key prefixes
+	Action   string   `yaml:"action"`   // block, tag or audit
+}
+
+// ObjectTaggingConfig adds tags to objects uploaded through the gateway, so
+// they can be attributed in the backing bucket. Tags replace client tags with
+// the same keys.
+type ObjectTaggingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Tags are values by tag key, expanding {clientId}, {tenantId} and
+	// {requestId}. Default to tenant, client and gateway-request-id.
+	Tags map[string]string `yaml:"tags"`
+}
+
+// CICredentialsConfig lets CI jobs exchange the OIDC token their CI system
+// issues them (GitHub Actions, GitLab) for short-lived gateway credentials
+// limited to one bucket prefix, so pipelines need no long-lived secrets.
+// Issued credentials are signed with SigningKey, which every replica must
+// share, and end when their role is removed.
+type CICredentialsConfig struct {
+	// Path of the exchange endpoint on the S3 listeners; defaults to
+	// /_gateway/ci-credentials, which no bucket name can clash with
+	Path       string         `yaml:"path"`
+	SigningKey string         `yaml:"signingKey" secret:"true"`
+	TTL        time.Duration  `yaml:"ttl"` // defaults to 1h, at most 12h
+	Providers  []OIDCProvider `yaml:"providers"`
+	Roles      []CIRole       `yaml:"roles"`
+}
+
+// RedactionConfig replaces object keys matching any of Keys in audit
+// entries and application logs, so log pipelines do not leak sensitive
+// object names
+type RedactionConfig struct {
+	// HMACKey keys the hashes of redacted keys, so they cannot be recovered
+	// by hashing guessed names; plain SHA-256 when empty
+	HMACKey string             `yaml:"hmacKey" secret:"true"`
+	Keys    []KeyRedactionRule `yaml:"keys"`
+}
+
+// KeyRedactionRule redacts the keys of objects whose "bucket/key" matches
+// Pattern, with * and ? wildcards as in policy resources
+type KeyRedactionRule struct {
+	Pattern string `yaml:"pattern"` // e.g. "*/pii/*"
+	// Mode is "hash" (the default), keeping entries about one object
+	// correlatable, or "remove"
+	Mode string `yaml:"mode"`
+}
+
+// UploadLinksConfig enables single-use upload URLs ("dropbox links"), which
+// let external partners upload one object to an exact key without any
+// credential. Links are created through the admin API, which must be enabled.
+type UploadLinksConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// BaseURL is the public URL of the S3 listeners links are made from,
+	// e.g. https://uploads.example.com
+	BaseURL string `yaml:"baseUrl"`
+	// StateFile persists links; empty keeps them in memory until restart.
+	// Links are only served by the replica holding the file.
+	StateFile   string        `yaml:"stateFile"`
+	MaxDuration time.Duration `yaml:"maxDuration"` // longest link validity, defaults to 7 days
+	MaxSize     int64         `yaml:"maxSize"`     // largest upload a link may allow, defaults to 5 GiB
+}
+
+// OIDCProvider is an OpenID Connect issuer whose ID tokens are accepted,
+// e.g. https://token.actions.githubusercontent.com or https://gitlab.com
+type OIDCProvider struct {
+	Name     string `yaml:"name"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"` // required aud claim
+	// JWKSURL of the signing keys; defaults to the jwks_uri of the issuer's
+	// discovery document
+	JWKSURL string `yaml:"jwksUrl"`
+}
+
+// CIRole is what a CI job may request. Tokens of Provider whose claims match
+// every Claims pattern get credentials of TenantID with Policies, limited to
+// Prefix in Bucket.
+type CIRole struct {
+	Name     string `yaml:"name"`
+	Provider string `yaml:"provider"`
+	// Claims are patterns with * wildcards by claim name, e.g.
+	// repository: myorg/*
+	Claims   map[string]string `yaml:"claims"`
+	TenantID string            `yaml:"tenantId"`
+	Bucket   string            `yaml:"bucket"`
+	// Prefix may reference claims as {claim}, e.g. ci/{repository_id}/{run_id}/;
+	// a token whose claim value contains "/" or ".." is refused
+	Prefix   string        `yaml:"prefix"`
+	Policies []string      `yaml:"policies"`
+	TTL      time.Duration `yaml:"ttl"` // overrides ciCredentials.ttl
+}
+
+// ListingLimits clamps the max-keys of listings so a single request cannot
+// pull an enormous listing; clients page through the rest. Zero keeps the S3
+// limit of 1000.
+type ListingLimits struct {
+	MaxKeys int            `yaml:"maxKeys"`
+	Tenants map[string]int `yaml:"tenants"` // per-tenant limits overriding maxKeys
+}
+
+// AccessKeyPolicy constrains client access key IDs so they identify their
+// tenant in logs and cannot be mistaken for real AWS key IDs
+type AccessKeyPolicy struct {
+	// Prefix every tenant's keys must start with; {tenantId} is replaced by
+	// the tenant ID, uppercased with other characters removed
+	Prefix  string            `yaml:"prefix"`  // e.g. GW{tenantId}
+	Tenants map[string]string `yaml:"tenants"` // per-tenant prefixes overriding prefix
+	// RejectAWSKeyIDs refuses keys shaped like AWS access key IDs (AKIA...,
+	// ASIA...)
+	RejectAWSKeyIDs bool `yaml:"rejectAwsKeyIds"`
+}
+
+// KubernetesConfig enables controller mode, where policies and credentials
+// are read from S3AccessPolicy and S3ClientCredential custom resources
+type KubernetesConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Namespace string `yaml:"namespace"` // empty watches all namespaces
+	// ResyncInterval periodically relists resources and Secrets, recovering
+	// from missed watch events
+	ResyncInterval time.Duration `yaml:"resyncInterval"`
+	// TenantNamespaces maps tenant IDs to the namespaces whose
+	// S3ClientCredentials may claim them; when unset any namespace may
+	// claim any tenant, so only cluster admins should write the resources
+	TenantNamespaces map[string][]string `yaml:"tenantNamespaces"`
+}
+
+// ValidationConfig controls how cross-file problems are handled at startup
+type ValidationConfig struct {
+	// PolicyReferences is "fail" or "warn" for credentials naming policies
+	// that do not exist
+	PolicyReferences string `yaml:"policyReferences"`
+}
+
+// GeoIPConfig holds MaxMind DB paths used to enrich audit entries with the
+// client's country and ASN and to evaluate aws:SourceCountry conditions
+type GeoIPConfig struct {
+	CountryDatabase string `yaml:"countryDatabase"` // GeoLite2/GeoIP2 Country or City .mmdb
+	ASNDatabase     string `yaml:"asnDatabase"`     // GeoLite2/GeoIP2 ASN .mmdb
+}
+
+// RedisConfig shares state between gateway replicas, currently statement
+// rate limits, so limits apply to the cluster rather than each instance
+type RedisConfig struct {
+	Address   string        `yaml:"address"` // host:port; empty keeps state per instance
+	Username  string        `yaml:"username"`
+	Password  string        `yaml:"password" secret:"true"`
+	DB        int           `yaml:"db"`
+	TLS       bool          `yaml:"tls"`
+	KeyPrefix string        `yaml:"keyPrefix"`
+	Timeout   time.Duration `yaml:"timeout"` // per command; requests fall back to local state after it
+}
+
+// PolicyCanaryConfig evaluates every request against the policies of
+// PoliciesFile as well as the primary ones, to roll out a new policy set
+// gradually. The canary decides Percent of requests; requests the two
+// decide differently are logged and counted whichever decided.
+type PolicyCanaryConfig struct {
+	PoliciesFile string  `yaml:"policiesFile"` // empty disables the canary
+	Percent      float64 `yaml:"percent"`
+}
+
+// SLOConfig sets the gateway's own availability and latency objectives,
+// exported as rolling SLI ratios and error budget burn rates
+type SLOConfig struct {
+	Enabled               bool            `yaml:"enabled"`
+	AvailabilityObjective float64         `yaml:"availabilityObjective"` // fraction of non-5xx responses
+	LatencyObjective      float64         `yaml:"latencyObjective"`      // fraction of responses within LatencyThreshold
+	LatencyThreshold      time.Duration   `yaml:"latencyThreshold"`      // time to first response byte
+	Windows               []time.Duration `yaml:"windows"`
+}
+
+// HookConfig selects a built-in request hook. Hooks run in order for
+// authenticated requests matching every filter given.
+type HookConfig struct {
+	Name     string   `yaml:"name"`
+	Type     string   `yaml:"type"` // setHeaders or rewriteKey
+	Actions  []string `yaml:"actions"`
+	Buckets  []string `yaml:"buckets"`  // bucket patterns, e.g. tenant-001-*
+	Tenants  []string `yaml:"tenants"`  // tenant IDs
+	Prefixes []string `yaml:"prefixes"` // key prefixes
+
+	// setHeaders: values may contain {clientId}, {tenantId} and {requestId}
+	Stage   string            `yaml:"stage"` // request (default, sent upstream) or response
+	Headers map[string]string `yaml:"headers"`
+	Default bool              `yaml:"default"` // keep values the client sent
+
+	// rewriteKey: replaces the key prefix From with To before authorization
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// MirrorConfig duplicates a sample of allowed requests to a staging S3
+// endpoint for load testing. Copies are sent after the primary backend
+// answers and their responses are discarded.
+type MirrorConfig struct {
+	Endpoint        string  `yaml:"endpoint"` // empty disables mirroring
+	Region          string  `yaml:"region"`   // defaults to aws.region
+	AccessKeyID     string  `yaml:"accessKeyId"`
+	SecretAccessKey string  `yaml:"secretAccessKey" secret:"true"`
+	UsePathStyle    bool    `yaml:"usePathStyle"`
+	SampleRate      float64 `yaml:"sampleRate"`   // fraction of allowed requests, up to 1
+	MaxBodyBytes    int64   `yaml:"maxBodyBytes"` // larger uploads are mirrored without their body
+	MaxInFlight     int     `yaml:"maxInFlight"`  // mirrored requests beyond it are dropped
+}
+
+// ChaosConfig injects faults into authorized requests so clients can test
+// their retry behavior. Never enable it in production.
+type ChaosConfig struct {
+	Enabled bool        `yaml:"enabled"`
+	Rules   []ChaosRule `yaml:"rules"` // the first rule matching the action applies
+}
+
+// ChaosRule sets fault rates for actions matching any of Actions. Rates are
+// fractions of requests; latency is added before an error or reset.
+type ChaosRule struct {
+	Actions     []string      `yaml:"actions"` // e.g. s3:GetObject or s3:*
+	Latency     time.Duration `yaml:"latency"`
+	LatencyRate float64       `yaml:"latencyRate"`
+	ErrorRate   float64       `yaml:"errorRate"` // 500 InternalError
+	ResetRate   float64       `yaml:"resetRate"` // connection closed without a response
+}
+
+// CloudWatchConfig sends audit entries to CloudWatch Logs and metrics in
+// Embedded Metric Format, using the default AWS credential chain
+type CloudWatchConfig struct {
+	Region   string                `yaml:"region"`   // defaults to aws.region
+	Endpoint string                `yaml:"endpoint"` // overrides the regional endpoint, e.g. for LocalStack
+	Audit    CloudWatchAuditConfig `yaml:"audit"`
+	EMF      EMFConfig             `yaml:"emf"`
+}
+
+// CloudWatchAuditConfig holds the CloudWatch Logs audit sink settings
+type CloudWatchAuditConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	LogGroup      string        `yaml:"logGroup"`  // must already exist
+	LogStream     string        `yaml:"logStream"` // defaults to the hostname
+	FlushInterval time.Duration `yaml:"flushInterval"`
+}
+
+// EMFConfig holds the Embedded Metric Format exporter settings
+type EMFConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Namespace string        `yaml:"namespace"`
+	Interval  time.Duration `yaml:"interval"`
+	LogGroup  string        `yaml:"logGroup"`  // empty writes to stdout for the CloudWatch agent
+	LogStream string        `yaml:"logStream"` // defaults to the hostname
+}
+
+// AlertsConfig holds anomaly alert settings
+type AlertsConfig struct {
+	DenySpike DenySpikeConfig `yaml:"denySpike"`
+}
+
+// DenySpikeConfig alerts when a client is denied Threshold times within
+// Window, a sign of compromised credentials or a misconfigured client
+type DenySpikeConfig struct {
+	Enabled    bool                          `yaml:"enabled"`
+	Threshold  int                           `yaml:"threshold"`
+	Window     time.Duration                 `yaml:"window"`
+	Cooldown   time.Duration                 `yaml:"cooldown"`                 // minimum interval between alerts for a client
+	WebhookURL string                        `yaml:"webhookUrl" secret:"true"` // alerts are POSTed here as JSON and always logged
+	Tenants    map[string]DenySpikeThreshold `yaml:"tenants"`                  // per-tenant overrides
+}
+
+// DenySpikeThreshold overrides the deny spike threshold and window for a
+// tenant; zero values inherit the defaults
+type DenySpikeThreshold struct {
+	Threshold int           `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+}
+
+// AdminConfig holds settings for the admin endpoint listener
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"`
+	Token   string `yaml:"token" secret:"true"` // bearer token required on every request
+	// TenantStateFile persists tenant suspensions and deletions made through
+	// the admin API; empty keeps them in memory until restart
+	TenantStateFile string `yaml:"tenantStateFile"`
+	// GrantStateFile persists break-glass grants made through the admin
+	// API; empty keeps them in memory until restart
+	GrantStateFile string `yaml:"grantStateFile"`
+	// MaxGrantDuration caps how long a break-glass grant may last
+	MaxGrantDuration time.Duration `yaml:"maxGrantDuration"`
+
+	Debug AdminDebugConfig `yaml:"debug"`
+}
+
+// AdminDebugConfig enables pprof, expvar and dump endpoints on the admin
+// listener for profiling production issues
+type AdminDebugConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	DumpDir string `yaml:"dumpDir"` // where POST /admin/debug/dump writes profiles
 }
 
 // ServerConfig holds HTTP server settings
@@ -17,6 +452,81 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration `yaml:"readTimeout"`
 	WriteTimeout    time.Duration `yaml:"writeTimeout"`
 	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// Slow-client protection: how long a client may take to send request
+	// headers, keep an idle connection open, and how large the request line
+	// and headers may be
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+	// DrainTimeout is how long the gateway rejects new requests with 503
+	// and fails health checks before shutting down its listeners
+	DrainTimeout time.Duration `yaml:"drainTimeout"`
+	// UpgradeTimeout is how long the old process of an in-place upgrade
+	// (SIGUSR2) waits for its in-flight requests to finish
+	UpgradeTimeout time.Duration `yaml:"upgradeTimeout"`
+
+	// TrustedProxies lists the CIDRs or IPs of proxies whose
+	// X-Forwarded-For and X-Real-IP headers are honored
+	TrustedProxies []string `yaml:"trustedProxies"`
+
+	// VerboseDenyMessages adds the deny reason, matched policy and statement
+	// Sid to error messages sent to clients
+	VerboseDenyMessages bool `yaml:"verboseDenyMessages"`
+	// DenyReasonHeader adds the deny reason code to denied responses as
+	// X-Gateway-Deny-Reason
+	DenyReasonHeader bool `yaml:"denyReasonHeader"`
+	// VerifyETags hashes GetObject bodies of single-part objects as they
+	// stream and logs and counts those not matching their ETag
+	VerifyETags bool `yaml:"verifyETags"`
+
+	LoadShedding LoadSheddingConfig `yaml:"loadShedding"`
+	Compression  CompressionConfig  `yaml:"compression"`
+
+	// Listeners serve the gateway on several addresses; when empty a single
+	// plaintext listener on Port is used
+	Listeners []ListenerConfig `yaml:"listeners"`
+}
+
+// CompressionConfig compresses responses with gzip or deflate for clients
+// that accept it. API responses such as listings are compressed from MinSize;
+// objects only from MinSize to MaxObjectSize and with a listed content type.
+type CompressionConfig struct {
+	Enabled       bool  `yaml:"enabled"`
+	MinSize       int64 `yaml:"minSize"`       // bytes, defaults to 1 KiB
+	MaxObjectSize int64 `yaml:"maxObjectSize"` // bytes, defaults to 1 MiB
+	// ContentTypes of objects to compress, e.g. text/* or application/json;
+	// default to text, JSON, XML and JavaScript
+	ContentTypes []string `yaml:"contentTypes"`
+}
+
+// LoadSheddingConfig caps concurrent in-flight requests; requests beyond a
+// cap get a 503 SlowDown. Zero means unlimited.
+type LoadSheddingConfig struct {
+	MaxInFlight          int `yaml:"maxInFlight"`
+	MaxInFlightPerTenant int `yaml:"maxInFlightPerTenant"`
+	// TenantQueueTimeout is how long requests beyond a tenant's cap wait for
+	// one of its requests to finish; zero rejects them immediately
+	TenantQueueTimeout time.Duration `yaml:"tenantQueueTimeout"`
+}
+
+// ListenerConfig describes one address the gateway serves on. Timeouts
+// default to the server-level values.
+type ListenerConfig struct {
+	Network      string        `yaml:"network"` // tcp (default) or unix
+	Address      string        `yaml:"address"` // ":8443" or a socket path
+	TLS          TLSConfig     `yaml:"tls"`
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout"`
+
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+}
+
+// TLSConfig holds a certificate for a TLS listener
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
 }
 
 // AWSConfig holds AWS/S3 connection settings
@@ -24,37 +534,249 @@ type AWSConfig struct {
 	Region          string `yaml:"region"`
 	Endpoint        string `yaml:"endpoint"`
 	AccessKeyID     string `yaml:"accessKeyId"`
-	SecretAccessKey string `yaml:"secretAccessKey"`
+	SecretAccessKey string `yaml:"secretAccessKey" secret:"true"`
 	UsePathStyle    bool   `yaml:"usePathStyle"`
+
+	HTTPClient HTTPClientConfig `yaml:"httpClient"`
+	Hedge      HedgeConfig      `yaml:"hedge"`
+	Failover   FailoverConfig   `yaml:"failover"`
+	Timeouts   TimeoutsConfig   `yaml:"timeouts"`
+
+	// RegionRoutes send buckets matching a pattern to a region other than
+	// Region; the first matching route wins
+	RegionRoutes []RegionRoute `yaml:"regionRoutes"`
+
+	// Redirects controls upstream PermanentRedirect handling: "follow"
+	// retries in the indicated region, "passthrough" returns the redirect
+	Redirects string `yaml:"redirects"`
+
+	// StripResponseHeaders lists upstream response headers to drop instead
+	// of passing through to clients, e.g. x-amz-replication-status
+	StripResponseHeaders []string `yaml:"stripResponseHeaders"`
+}
+
+// RegionRoute maps bucket name patterns to an AWS region
+type RegionRoute struct {
+	Buckets []string `yaml:"buckets"` // patterns like "eu-*"
+	Region  string   `yaml:"region"`
+}
+
+// BackendConfig selects the storage backend requests are forwarded to
+type BackendConfig struct {
+	Type       string                  `yaml:"type"` // s3 (default), filesystem, memory or azure
+	Filesystem FilesystemBackendConfig `yaml:"filesystem"`
+	Memory     MemoryBackendConfig     `yaml:"memory"`
+	Azure      AzureBackendConfig      `yaml:"azure"`
+}
+
+// FilesystemBackendConfig holds settings for the local directory backend
+type FilesystemBackendConfig struct {
+	Root string `yaml:"root"` // each bucket is a subdirectory of root
+}
+
+// MemoryBackendConfig holds settings for the in-memory backend used in CI
+type MemoryBackendConfig struct {
+	Buckets []string `yaml:"buckets"` // buckets created empty at startup
+}
+
+// AzureBackendConfig holds settings for the Azure Blob Storage backend
+type AzureBackendConfig struct {
+	AccountName string            `yaml:"accountName"`
+	AccountKey  string            `yaml:"accountKey" secret:"true"` // base64 shared key
+	Endpoint    string            `yaml:"endpoint"`                 // defaults to https://<account>.blob.core.windows.net
+	Containers  map[string]string `yaml:"containers"`               // S3 bucket -> container; unmapped buckets use their own name
+}
+
+// HTTPClientConfig holds tuning for the HTTP client used to reach the upstream
+type HTTPClientConfig struct {
+	MaxIdleConns          int           `yaml:"maxIdleConns"`
+	MaxIdleConnsPerHost   int           `yaml:"maxIdleConnsPerHost"`
+	MaxConnsPerHost       int           `yaml:"maxConnsPerHost"` // 0 means unlimited
+	DialTimeout           time.Duration `yaml:"dialTimeout"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tlsHandshakeTimeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"responseHeaderTimeout"` // 0 means no timeout
+	IdleConnTimeout       time.Duration `yaml:"idleConnTimeout"`
+	ProxyURL              string        `yaml:"proxyUrl" secret:"userinfo"` // empty uses HTTP(S)_PROXY from the environment
+	CABundle              string        `yaml:"caBundle"`                   // PEM file added to the system roots
+}
+
+// TimeoutsConfig bounds upstream calls by operation type; zero means no limit
+type TimeoutsConfig struct {
+	Metadata time.Duration `yaml:"metadata"` // HeadObject, ListBucket, DeleteObject
+	Transfer time.Duration `yaml:"transfer"` // GetObject, PutObject incl. streaming the body
 }
 
+// HedgeConfig controls hedged upstream reads (GetObject/HeadObject)
+type HedgeConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Delay   time.Duration `yaml:"delay"` // wait before issuing the second request
+}
+
+// FailoverConfig configures a secondary endpoint that serves reads while the
+// primary is unhealthy
+type FailoverConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Endpoint         string        `yaml:"endpoint"`         // custom endpoint, e.g. a replica MinIO
+	Region           string        `yaml:"region"`           // replica region, defaults to aws.region
+	FailureThreshold int           `yaml:"failureThreshold"` // consecutive 5xx/timeouts before failing over
+	ProbeInterval    time.Duration `yaml:"probeInterval"`
+	ProbeBucket      string        `yaml:"probeBucket"` // bucket used for HeadBucket health probes
+}
+
+// MetricsConfig holds metrics endpoint settings
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// Labels attached to per-request metrics such as
+	// gateway_decisions_total: any of tenant, client, bucket and action.
+	// Omitted labels are reported empty.
+	Labels []string `yaml:"labels"`
+	// MaxTenants caps the distinct tenant label values; later tenants are
+	// reported as "other"
+	MaxTenants int `yaml:"maxTenants"`
+	// Buckets lists the bucket patterns reported in the bucket label, up to
+	// MaxBuckets distinct buckets; other buckets are reported as "other".
+	// Empty allows every bucket.
+	Buckets    []string `yaml:"buckets"`
+	MaxBuckets int      `yaml:"maxBuckets"`
+}
+
+// Metric labels selectable in metrics.labels
+const (
+	MetricLabelTenant = "tenant"
+	MetricLabelClient = "client"
+	MetricLabelBucket = "bucket"
+	MetricLabelAction = "action"
+)
+
 // AuditConfig holds audit logging settings
 type AuditConfig struct {
 	Enabled  bool   `yaml:"enabled"`
 	Output   string `yaml:"output"` // stdout, file, or both
 	FilePath string `yaml:"filePath"`
 	Format   string `yaml:"format"` // json
+
+	// FailureMode is "open" (count write errors and serve the request) or
+	// "closed" (reject requests whose entry cannot be durably recorded)
+	FailureMode string `yaml:"failureMode"`
+	// Sampling and ExcludeActions reduce the volume of allowed requests;
+	// denials and failed requests are always recorded
+	Sampling       AuditSamplingConfig `yaml:"sampling"`
+	ExcludeActions []string            `yaml:"excludeActions"`
+	// Integrity chains entries by hash so that modified or removed entries
+	// are detected by "gateway audit verify"
+	Integrity AuditIntegrityConfig `yaml:"integrity"`
+	// Database additionally stores entries in a SQL database queried by
+	// GET /admin/audit
+	Database AuditDatabaseConfig `yaml:"database"`
+	// Retention purges old entries from the log file and database
+	Retention AuditRetentionConfig `yaml:"retention"`
+}
+
+// AuditRetentionConfig limits how much audit history the file and database
+// sinks keep. Every Interval the log file is rotated into a timestamped
+// segment, and segments and database entries beyond MaxAgeDays, MaxSize or
+// MaxEntries are deleted, after being uploaded to Archive if it is set.
+// Zero limits keep everything.
+type AuditRetentionConfig struct {
+	MaxAgeDays int                `yaml:"maxAgeDays"`
+	MaxSize    int64              `yaml:"maxSize"`    // bytes of rotated log file segments
+	MaxEntries int64              `yaml:"maxEntries"` // database entries
+	Interval   time.Duration      `yaml:"interval"`   // defaults to 24h
+	Archive    AuditArchiveConfig `yaml:"archive"`
+}
+
+// Enabled reports whether any retention limit is set
+func (c *AuditRetentionConfig) Enabled() bool {
+	return c.MaxAgeDays > 0 || c.MaxSize > 0 || c.MaxEntries > 0
+}
+
+// AuditArchiveConfig uploads purged audit entries to a bucket of the backend
+// before they are deleted
+type AuditArchiveConfig struct {
+	Bucket string `yaml:"bucket"`
+	Prefix string `yaml:"prefix"`
+}
+
+// AuditIntegrityConfig holds audit hash chain settings
+type AuditIntegrityConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	HMACKey string `yaml:"hmacKey" secret:"true"` // keys the chain with HMAC-SHA256; plain SHA-256 when empty
+}
+
+// AuditDatabaseConfig holds the SQL audit sink settings
+type AuditDatabaseConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Driver  string `yaml:"driver"`            // pgx, or sqlite in cgo builds
+	DSN     string `yaml:"dsn" secret:"true"` // may embed a password
+	Table   string `yaml:"table"`
+}
+
+// AuditSamplingConfig holds audit sampling settings
+type AuditSamplingConfig struct {
+	AllowRate float64 `yaml:"allowRate"` // fraction of allowed requests recorded, 0-1
 }
 
 // CredentialsConfig holds the list of client credentials
 type CredentialsConfig struct {
-	Credentials []Credential `yaml:"credentials"`
+	Credentials []Credential      `yaml:"credentials"`
+	Groups      []CredentialGroup `yaml:"groups,omitempty"`
+	Tenants     []Tenant          `yaml:"tenants,omitempty"`
+}
+
+// Tenant grants policies and scopes to every credential of the tenant and,
+// through inheritance, of its descendants
+type Tenant struct {
+	ID          string   `yaml:"id"`
+	Parent      string   `yaml:"parent"`
+	Description string   `yaml:"description"`
+	Policies    []string `yaml:"policies"`
+	Scopes      []string `yaml:"scopes"`
+	// InheritPolicies and InheritScopes select the parent's effective
+	// policies and scopes the tenant inherits: all when unset, none when []
+	InheritPolicies []string `yaml:"inheritPolicies"`
+	InheritScopes   []string `yaml:"inheritScopes"`
+}
+
+// CredentialGroup attaches policies and scopes to every credential that
+// lists it in groups
+type CredentialGroup struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Policies    []string `yaml:"policies"`
+	Scopes      []string `yaml:"scopes"`
 }
 
 // Credential represents a client's authentication credentials
 type Credential struct {
 	AccessKey   string   `yaml:"accessKey"`
-	SecretKey   string   `yaml:"secretKey"`
+	SecretKey   string   `yaml:"secretKey" secret:"true"`
 	ClientID    string   `yaml:"clientId"`
 	TenantID    string   `yaml:"tenantId"`
 	Description string   `yaml:"description"`
 	Policies    []string `yaml:"policies"`
-	Scopes      []string `yaml:"scopes"` // Allowed bucket/prefix patterns
+	Scopes      []string `yaml:"scopes"`  // Allowed bucket/prefix patterns
+	Regions     []string `yaml:"regions"` // accepted signing regions, overriding auth.regions
+	Groups      []string `yaml:"groups"`  // groups whose policies and scopes are added
+	// AllowedActions are granted within Scopes without a policy document,
+	// e.g. [s3:GetObject, s3:ListBucket] for a read-only key
+	AllowedActions []string `yaml:"allowedActions"`
 }
 
 // PoliciesConfig holds the list of IAM-like policies
 type PoliciesConfig struct {
 	Policies []Policy `yaml:"policies"`
+	// BucketPolicies are resource policies attached to buckets, evaluated
+	// with the identity policies of every request to a matching bucket
+	BucketPolicies []BucketPolicy `yaml:"bucketPolicies,omitempty"`
+}
+
+// BucketPolicy is a resource policy attached to buckets by pattern. Its
+// statements name the principals they apply to.
+type BucketPolicy struct {
+	Name       string      `yaml:"name"`
+	Buckets    []string    `yaml:"buckets"` // patterns like "shared-*"
+	Statements []Statement `yaml:"statements"`
 }
 
 // Policy represents an IAM-like policy
@@ -66,11 +788,26 @@ type Policy struct {
 
 // Statement represents a policy statement
 type Statement struct {
-	Sid        string                       `yaml:"sid"`
-	Effect     Effect                       `yaml:"effect"`
-	Actions    []string                     `yaml:"actions"`
-	Resources  []string                     `yaml:"resources"`
-	Conditions map[string]map[string]string `yaml:"conditions,omitempty"`
+	Sid       string   `yaml:"sid"`
+	Effect    Effect   `yaml:"effect"`
+	Actions   []string `yaml:"actions"`
+	Resources []string `yaml:"resources"`
+	// Principals are the client IDs, "tenant:<id>" or "*" a bucket policy
+	// statement applies to; identity policy statements have none
+	Principals []string `yaml:"principals,omitempty"`
+	// Conditions maps operators, optionally qualified with ForAllValues: or
+	// ForAnyValue:, to condition keys and their values
+	Conditions map[string]map[string]ConditionValues `yaml:"conditions,omitempty"`
+	// RateLimit throttles each client's requests allowed by an Allow
+	// statement
+	RateLimit *RateLimit `yaml:"rateLimit,omitempty"`
+}
+
+// RateLimit is a per-client token bucket refilled at RequestsPerSecond,
+// holding at most Burst requests (default: RequestsPerSecond rounded up)
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond" json:"requestsPerSecond"`
+	Burst             int     `yaml:"burst,omitempty" json:"burst,omitempty"`
 }
 
 // Effect represents Allow or Deny
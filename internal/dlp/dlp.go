@@ -0,0 +1,113 @@
+// Package dlp finds sensitive data, such as personal identifiers or secrets,
+// in object uploads, with regular expressions or an external scanning
+// service.
+package dlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Scanner reports the names of the detectors that match data
+type Scanner interface {
+	Scan(ctx context.Context, data []byte) ([]string, error)
+}
+
+// Patterns detects data matching regular expressions
+type Patterns map[string]*regexp.Regexp
+
+// NewPatterns compiles regular expressions by detector name
+func NewPatterns(detectors map[string]string) (Patterns, error) {
+	p := make(Patterns, len(detectors))
+	for name, expr := range detectors {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("detector %s: %w", name, err)
+		}
+		p[name] = re
+	}
+	return p, nil
+}
+
+// Scan returns the detectors matching data, sorted
+func (p Patterns) Scan(_ context.Context, data []byte) ([]string, error) {
+	var findings []string
+	for name, re := range p {
+		if re.Match(data) {
+			findings = append(findings, name)
+		}
+	}
+	sort.Strings(findings)
+	return findings, nil
+}
+
+// Service posts data to a scanning service, which answers with the
+// detectors that matched as {"findings": ["ssn"]}
+type Service struct {
+	url    string
+	client *http.Client
+}
+
+// NewService creates a scanner calling url, waiting at most timeout
+func NewService(url string, timeout time.Duration) *Service {
+	return &Service{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// maxServiceResponse bounds the scanning service's answer
+const maxServiceResponse = 1 << 20
+
+// Scan sends data to the service
+func (s *Service) Scan(ctx context.Context, data []byte) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call scanning service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scanning service returned status %d", resp.StatusCode)
+	}
+	var result struct {
+		Findings []string `json:"findings"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxServiceResponse)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse scanning service response: %w", err)
+	}
+	sort.Strings(result.Findings)
+	return result.Findings, nil
+}
+
+// Scanners runs several scanners, returning the findings of all of them
+type Scanners []Scanner
+
+// Scan returns the sorted, distinct findings of every scanner. It fails if
+// any scanner fails, so no finding is missed silently.
+func (s Scanners) Scan(ctx context.Context, data []byte) ([]string, error) {
+	seen := make(map[string]bool)
+	var findings []string
+	for _, scanner := range s {
+		names, err := scanner.Scan(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				findings = append(findings, name)
+			}
+		}
+	}
+	sort.Strings(findings)
+	return findings, nil
+}
@@ -0,0 +1,69 @@
+package dlp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPatterns(t *testing.T) {
+	p, err := NewPatterns(map[string]string{
+		"ssn":   `\b\d{3}-\d{2}-\d{4}\b`,
+		"email": `[\w.+-]+@[\w-]+\.[\w.]+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, _ := p.Scan(context.Background(), []byte("contact: jane@example.com, ssn 123-45-6789"))
+	if want := []string{"email", "ssn"}; !reflect.DeepEqual(findings, want) {
+		t.Errorf("findings = %v, want %v", findings, want)
+	}
+	if findings, _ := p.Scan(context.Background(), []byte("nothing to see")); len(findings) != 0 {
+		t.Errorf("findings = %v, want none", findings)
+	}
+	if _, err := NewPatterns(map[string]string{"bad": "("}); err == nil {
+		t.Error("expected error for invalid expression")
+	}
+}
+
+func TestService(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]string{"findings": {"name", "credit-card"}})
+	}))
+	defer srv.Close()
+
+	s := NewService(srv.URL, time.Second)
+	findings, err := s.Scan(context.Background(), []byte("4111 1111 1111 1111"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"credit-card", "name"}; !reflect.DeepEqual(findings, want) {
+		t.Errorf("findings = %v, want %v", findings, want)
+	}
+	if _, err := s.Scan(context.Background(), []byte("fail")); err == nil {
+		t.Error("expected error for failed scan")
+	}
+
+	// Findings of several scanners are merged
+	p, _ := NewPatterns(map[string]string{"credit-card": `\d{4} \d{4}`, "digits": `\d`})
+	findings, err = Scanners{p, s}.Scan(context.Background(), []byte("4111 1111"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"credit-card", "digits", "name"}; !reflect.DeepEqual(findings, want) {
+		t.Errorf("merged findings = %v, want %v", findings, want)
+	}
+	if _, err := (Scanners{p, s}).Scan(context.Background(), []byte("fail")); err == nil {
+		t.Error("expected error when one scanner fails")
+	}
+}
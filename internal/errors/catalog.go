@@ -0,0 +1,99 @@
+package errors
+
+import "net/http"
+
+// ErrorCode is an S3 error code with the HTTP status and default message S3
+// sends with it
+type ErrorCode struct {
+	Code       string
+	StatusCode int
+	Message    string
+}
+
+// Error lets a code be returned as an error, e.g. from a body reader
+func (c ErrorCode) Error() string {
+	return c.Code + ": " + c.Message
+}
+
+// WithMessage returns the code with a different message
+func (c ErrorCode) WithMessage(message string) ErrorCode {
+	c.Message = message
+	return c
+}
+
+// S3 error codes, as documented in the Amazon S3 API reference
+var (
+	ErrAccessDenied                 = ErrorCode{"AccessDenied", http.StatusForbidden, "Access Denied"}
+	ErrAccountProblem               = ErrorCode{"AccountProblem", http.StatusForbidden, "There is a problem with your account that prevents the operation from completing successfully."}
+	ErrAuthorizationHeaderMalformed = ErrorCode{"AuthorizationHeaderMalformed", http.StatusBadRequest, "The authorization header you provided is invalid."}
+	ErrBadDigest                    = ErrorCode{"BadDigest", http.StatusBadRequest, "The Content-MD5 or checksum value that you specified did not match what the server received."}
+	ErrBucketAlreadyExists          = ErrorCode{"BucketAlreadyExists", http.StatusConflict, "The requested bucket name is not available."}
+	ErrBucketAlreadyOwnedByYou      = ErrorCode{"BucketAlreadyOwnedByYou", http.StatusConflict, "The bucket that you tried to create already exists, and you own it."}
+	ErrBucketNotEmpty               = ErrorCode{"BucketNotEmpty", http.StatusConflict, "The bucket that you tried to delete is not empty."}
+	ErrConditionalRequestConflict   = ErrorCode{"ConditionalRequestConflict", http.StatusConflict, "A conflicting operation occurred. If using PutObject you can retry the request."}
+	ErrEntityTooLarge               = ErrorCode{"EntityTooLarge", http.StatusBadRequest, "Your proposed upload exceeds the maximum allowed object size."}
+	ErrEntityTooSmall               = ErrorCode{"EntityTooSmall", http.StatusBadRequest, "Your proposed upload is smaller than the minimum allowed object size."}
+	ErrExpiredToken                 = ErrorCode{"ExpiredToken", http.StatusBadRequest, "The provided token has expired."}
+	ErrIncompleteBody               = ErrorCode{"IncompleteBody", http.StatusBadRequest, "You did not provide the number of bytes specified by the Content-Length HTTP header."}
+	ErrInternalError                = ErrorCode{"InternalError", http.StatusInternalServerError, "We encountered an internal error. Please try again."}
+	ErrInvalidAccessKeyID           = ErrorCode{"InvalidAccessKeyId", http.StatusForbidden, "The AWS access key ID that you provided does not exist in our records."}
+	ErrInvalidArgument              = ErrorCode{"InvalidArgument", http.StatusBadRequest, "Invalid Argument"}
+	ErrInvalidBucketName            = ErrorCode{"InvalidBucketName", http.StatusBadRequest, "The specified bucket is not valid."}
+	ErrInvalidDigest                = ErrorCode{"InvalidDigest", http.StatusBadRequest, "The Content-MD5 or checksum value that you specified is not valid."}
+	ErrInvalidPart                  = ErrorCode{"InvalidPart", http.StatusBadRequest, "One or more of the specified parts could not be found."}
+	ErrInvalidPartOrder             = ErrorCode{"InvalidPartOrder", http.StatusBadRequest, "The list of parts was not in ascending order."}
+	ErrInvalidRange                 = ErrorCode{"InvalidRange", http.StatusRequestedRangeNotSatisfiable, "The requested range is not satisfiable"}
+	ErrInvalidRequest               = ErrorCode{"InvalidRequest", http.StatusBadRequest, "Invalid Request"}
+	ErrInvalidStorageClass          = ErrorCode{"InvalidStorageClass", http.StatusBadRequest, "The storage class you specified is not valid."}
+	ErrKeyTooLong                   = ErrorCode{"KeyTooLongError", http.StatusBadRequest, "Your key is too long."}
+	ErrMalformedXML                 = ErrorCode{"MalformedXML", http.StatusBadRequest, "The XML you provided was not well-formed or did not validate against our published schema."}
+	ErrMaxMessageLengthExceeded     = ErrorCode{"MaxMessageLengthExceeded", http.StatusBadRequest, "Your request was too big."}
+	ErrMetadataTooLarge             = ErrorCode{"MetadataTooLarge", http.StatusBadRequest, "Your metadata headers exceed the maximum allowed metadata size."}
+	ErrMethodNotAllowed             = ErrorCode{"MethodNotAllowed", http.StatusMethodNotAllowed, "The specified method is not allowed against this resource."}
+	ErrMissingContentLength         = ErrorCode{"MissingContentLength", http.StatusLengthRequired, "You must provide the Content-Length HTTP header."}
+	ErrMissingSecurityHeader        = ErrorCode{"MissingSecurityHeader", http.StatusBadRequest, "Your request is missing a required header."}
+	ErrNoSuchBucket                 = ErrorCode{"NoSuchBucket", http.StatusNotFound, "The specified bucket does not exist."}
+	ErrNoSuchKey                    = ErrorCode{"NoSuchKey", http.StatusNotFound, "The specified key does not exist."}
+	ErrNoSuchUpload                 = ErrorCode{"NoSuchUpload", http.StatusNotFound, "The specified multipart upload does not exist."}
+	ErrNotImplemented               = ErrorCode{"NotImplemented", http.StatusNotImplemented, "A header you provided implies functionality that is not implemented."}
+	ErrPermanentRedirect            = ErrorCode{"PermanentRedirect", http.StatusMovedPermanently, "The bucket you are attempting to access must be addressed using the specified endpoint."}
+	ErrPreconditionFailed           = ErrorCode{"PreconditionFailed", http.StatusPreconditionFailed, "At least one of the preconditions you specified did not hold."}
+	ErrRequestTimeout               = ErrorCode{"RequestTimeout", http.StatusBadRequest, "Your socket connection to the server was not read from or written to within the timeout period."}
+	ErrRequestTimeTooSkewed         = ErrorCode{"RequestTimeTooSkewed", http.StatusForbidden, "The difference between the request time and the server's time is too large."}
+	ErrServiceUnavailable           = ErrorCode{"ServiceUnavailable", http.StatusServiceUnavailable, "Service is unable to handle request."}
+	ErrSignatureDoesNotMatch        = ErrorCode{"SignatureDoesNotMatch", http.StatusForbidden, "The request signature we calculated does not match the signature you provided"}
+	ErrSlowDown                     = ErrorCode{"SlowDown", http.StatusServiceUnavailable, "Please reduce your request rate."}
+	ErrTooManyBuckets               = ErrorCode{"TooManyBuckets", http.StatusBadRequest, "You have attempted to create more buckets than allowed."}
+	ErrXAmzContentSHA256Mismatch    = ErrorCode{"XAmzContentSHA256Mismatch", http.StatusBadRequest, "The provided 'x-amz-content-sha256' header does not match what was computed."}
+)
+
+// catalog indexes the known codes by name
+var catalog = func() map[string]ErrorCode {
+	m := make(map[string]ErrorCode)
+	for _, c := range []ErrorCode{
+		ErrAccessDenied, ErrAccountProblem, ErrAuthorizationHeaderMalformed, ErrBadDigest, ErrBucketAlreadyExists,
+		ErrBucketAlreadyOwnedByYou, ErrBucketNotEmpty, ErrConditionalRequestConflict, ErrEntityTooLarge, ErrEntityTooSmall,
+		ErrExpiredToken, ErrIncompleteBody, ErrInternalError, ErrInvalidAccessKeyID,
+		ErrInvalidArgument, ErrInvalidBucketName, ErrInvalidDigest, ErrInvalidPart,
+		ErrInvalidPartOrder, ErrInvalidRange, ErrInvalidRequest, ErrInvalidStorageClass,
+		ErrKeyTooLong, ErrMalformedXML, ErrMaxMessageLengthExceeded, ErrMetadataTooLarge,
+		ErrMethodNotAllowed, ErrMissingContentLength, ErrMissingSecurityHeader, ErrNoSuchBucket,
+		ErrNoSuchKey, ErrNoSuchUpload, ErrNotImplemented, ErrPermanentRedirect,
+		ErrPreconditionFailed, ErrRequestTimeout, ErrRequestTimeTooSkewed, ErrServiceUnavailable,
+		ErrSignatureDoesNotMatch, ErrSlowDown, ErrTooManyBuckets, ErrXAmzContentSHA256Mismatch,
+	} {
+		m[c.Code] = c
+	}
+	return m
+}()
+
+// LookupCode returns the catalog entry for an S3 error code
+func LookupCode(code string) (ErrorCode, bool) {
+	c, ok := catalog[code]
+	return c, ok
+}
+
+// WriteS3ErrorCode writes an S3 XML error response for a catalog code
+func WriteS3ErrorCode(w http.ResponseWriter, c ErrorCode, requestID string) {
+	WriteS3ErrorFromCode(w, c.StatusCode, c.Code, c.Message, requestID)
+}
@@ -0,0 +1,81 @@
+package errors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLookupCode(t *testing.T) {
+	tests := []struct {
+		code   string
+		status int
+		ok     bool
+	}{
+		{"NoSuchKey", http.StatusNotFound, true},
+		{"MethodNotAllowed", http.StatusMethodNotAllowed, true},
+		{"MissingContentLength", http.StatusLengthRequired, true},
+		{"RequestTimeout", http.StatusBadRequest, true},
+		{"SlowDown", http.StatusServiceUnavailable, true},
+		{"KeyTooLongError", http.StatusBadRequest, true},
+		{"Bogus", 0, false},
+	}
+	for _, tt := range tests {
+		c, ok := LookupCode(tt.code)
+		if ok != tt.ok || c.StatusCode != tt.status {
+			t.Errorf("LookupCode(%q) = %+v, %v; want status %d, %v", tt.code, c, ok, tt.status, tt.ok)
+		}
+		if ok && (c.Code != tt.code || c.Message == "") {
+			t.Errorf("LookupCode(%q) = %+v: code or message wrong", tt.code, c)
+		}
+	}
+}
+
+func TestAccessDeniedError_Codes(t *testing.T) {
+	tests := []struct {
+		reason DenyReason
+		code   string
+		status int
+	}{
+		{DenyPolicy, "AccessDenied", http.StatusForbidden},
+		{DenyTenantBoundary, "AccessDenied", http.StatusForbidden},
+		{DenyInvalidResource, "InvalidRequest", http.StatusBadRequest},
+		{DenyNotImplemented, "NotImplemented", http.StatusNotImplemented},
+		{DenyAuthFailed, "SignatureDoesNotMatch", http.StatusForbidden},
+		{DenyInternalError, "InternalError", http.StatusInternalServerError},
+		{DenyThrottled, "SlowDown", http.StatusServiceUnavailable},
+		{DenyHookRejected, "AccessDenied", http.StatusForbidden},
+		{DenyInvalidAccessKey, "InvalidAccessKeyId", http.StatusForbidden},
+		{DenyRequestTimeSkewed, "RequestTimeTooSkewed", http.StatusForbidden},
+		{DenyMissingAuthHeader, "MissingSecurityHeader", http.StatusBadRequest},
+		{DenyInvalidSession, "ExpiredToken", http.StatusBadRequest},
+		{DenySessionScope, "AccessDenied", http.StatusForbidden},
+		{DenyAliasScope, "AccessDenied", http.StatusForbidden},
+		{DenyDLP, "AccessDenied", http.StatusForbidden},
+		{DenyUploadLink, "AccessDenied", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		err := NewAccessDeniedError(tt.reason, "", "bucket/key", "req-1")
+		if got := err.ToS3Error().Code; got != tt.code {
+			t.Errorf("%s: code = %s, want %s", tt.reason, got, tt.code)
+		}
+		if got := err.HTTPStatusCode(); got != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.reason, got, tt.status)
+		}
+	}
+}
+
+func TestWriteS3ErrorCode(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteS3ErrorCode(w, ErrPreconditionFailed.WithMessage("etag mismatch"), "req-1")
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("status = %d, want 412", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"<Code>PreconditionFailed</Code>", "<Message>etag mismatch</Message>", "<RequestId>req-1</RequestId>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %s: %s", want, body)
+		}
+	}
+}
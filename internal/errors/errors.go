@@ -15,14 +15,79 @@ const (
 	DenyInvalidResource DenyReason = "DENY_INVALID_RESOURCE"
 	DenyAuthFailed      DenyReason = "DENY_AUTH_FAILED"
 	DenyInternalError   DenyReason = "DENY_INTERNAL_ERROR"
+	DenyThrottled       DenyReason = "DENY_THROTTLED"
+	DenyHookRejected    DenyReason = "DENY_HOOK_REJECTED"
+	DenyTenantSuspended DenyReason = "DENY_TENANT_SUSPENDED"
+	DenyTenantDeleted   DenyReason = "DENY_TENANT_DELETED"
+	DenyBucketNaming    DenyReason = "DENY_BUCKET_NAMING"
+	DenyKeyNaming       DenyReason = "DENY_KEY_NAMING"
+	DenyNotImplemented  DenyReason = "DENY_NOT_IMPLEMENTED"
+	DenyUploadLimit     DenyReason = "DENY_UPLOAD_LIMIT"
+	DenyAliasScope      DenyReason = "DENY_ALIAS_SCOPE" // outside the prefix or operations of an alias
+	DenyDLP             DenyReason = "DENY_DLP"         // upload with sensitive data, or too large to scan
+	DenyUploadLink      DenyReason = "DENY_UPLOAD_LINK" // unknown, used or expired upload link, or an upload it does not allow
+
+	// Authentication failures other than a bad signature (DenyAuthFailed)
+	DenyInvalidAccessKey  DenyReason = "DENY_INVALID_ACCESS_KEY"
+	DenyRequestTimeSkewed DenyReason = "DENY_REQUEST_TIME_SKEWED"
+	DenyMissingAuthHeader DenyReason = "DENY_MISSING_AUTH_HEADER"
+	DenyCredentialScope   DenyReason = "DENY_CREDENTIAL_SCOPE"
+	DenyInvalidSession    DenyReason = "DENY_INVALID_SESSION" // S3 Express session token
+	DenySessionScope      DenyReason = "DENY_SESSION_SCOPE"   // request outside its S3 Express session
+)
+
+// Deny categories group deny reasons by what a client team has to change
+const (
+	CategoryScope          = "scope"          // resource outside the credential's scopes
+	CategoryPolicy         = "policy"         // refused by a policy statement or naming rule
+	CategoryAuthentication = "authentication" // signature, access key or credential problem
+	CategoryTenant         = "tenant"         // tenant suspended
+	CategoryThrottle       = "throttle"       // rate limit or load shedding
+	CategoryRequest        = "request"        // malformed request
+	CategoryHook           = "hook"           // rejected by a request hook
+	CategoryInternal       = "internal"       // gateway failure
 )
 
+// Category returns the deny category of a reason, or "" for unknown reasons
+func (r DenyReason) Category() string {
+	switch r {
+	case DenyTenantBoundary, DenyAliasScope:
+		return CategoryScope
+	case DenyPolicy, DenyBucketNaming, DenyKeyNaming, DenyUploadLimit, DenyDLP, DenyUploadLink:
+		return CategoryPolicy
+	case DenyAuthFailed, DenyInvalidAccessKey, DenyRequestTimeSkewed, DenyMissingAuthHeader,
+		DenyCredentialScope, DenyTenantDeleted, DenyInvalidSession, DenySessionScope:
+		return CategoryAuthentication
+	case DenyTenantSuspended:
+		return CategoryTenant
+	case DenyThrottled:
+		return CategoryThrottle
+	case DenyInvalidResource, DenyNotImplemented:
+		return CategoryRequest
+	case DenyHookRejected:
+		return CategoryHook
+	case DenyInternalError:
+		return CategoryInternal
+	default:
+		return ""
+	}
+}
+
 // AccessDeniedError represents an access denied error
 type AccessDeniedError struct {
 	Reason    DenyReason
 	Message   string
 	Resource  string
 	RequestID string
+
+	// Verbose adds the reason and the matched policy and statement to the
+	// client-facing message, for internal deployments
+	Verbose   bool
+	Policy    string
+	Statement string
+
+	// Operation names the S3 operation of a DenyNotImplemented error
+	Operation string
 }
 
 func (e *AccessDeniedError) Error() string {
@@ -48,29 +113,80 @@ type S3Error struct {
 	RequestID string   `xml:"RequestId"`
 }
 
-// ToS3Error converts an AccessDeniedError to an S3Error
-func (e *AccessDeniedError) ToS3Error() *S3Error {
-	code := "AccessDenied"
-	message := "Access Denied"
-
+// errorCode returns the catalog code a deny reason is reported with
+func (e *AccessDeniedError) errorCode() ErrorCode {
 	switch e.Reason {
 	case DenyTenantBoundary:
-		message = "Access denied: resource is outside your tenant boundary"
+		return ErrAccessDenied.WithMessage("Access denied: resource is outside your tenant boundary")
 	case DenyPolicy:
-		message = "Access denied: action not permitted by policy"
+		return ErrAccessDenied.WithMessage("Access denied: action not permitted by policy")
 	case DenyInvalidResource:
-		code = "InvalidRequest"
-		message = "Invalid resource"
+		return ErrInvalidRequest.WithMessage("Invalid resource")
+	case DenyNotImplemented:
+		if e.Operation != "" {
+			return ErrNotImplemented.WithMessage(e.Operation + " is not implemented by this gateway.")
+		}
+		return ErrNotImplemented.WithMessage("The requested operation is not implemented by this gateway.")
 	case DenyAuthFailed:
-		code = "SignatureDoesNotMatch"
-		message = "The request signature we calculated does not match the signature you provided"
+		return ErrSignatureDoesNotMatch
+	case DenyInvalidAccessKey:
+		return ErrInvalidAccessKeyID
+	case DenyRequestTimeSkewed:
+		return ErrRequestTimeTooSkewed
+	case DenyMissingAuthHeader:
+		return ErrMissingSecurityHeader
+	case DenyCredentialScope:
+		return ErrAuthorizationHeaderMalformed.WithMessage(
+			"The authorization header is malformed; the credential scope region or service is not accepted by this endpoint.")
+	case DenyInvalidSession:
+		return ErrExpiredToken.WithMessage("The session token is invalid or has expired.")
+	case DenySessionScope:
+		return ErrAccessDenied.WithMessage("Access denied: the request is outside the bucket or mode of its session")
+	case DenyAliasScope:
+		return ErrAccessDenied.WithMessage("Access denied: the request is outside the prefix or operations of the alias")
+	case DenyDLP:
+		return ErrAccessDenied.WithMessage("Access denied: the upload contains data this location does not accept, or is too large to be scanned")
+	case DenyUploadLink:
+		return ErrAccessDenied.WithMessage("Access denied: the upload link is invalid, expired or already used")
+	case DenyTenantSuspended:
+		return ErrAccountProblem.WithMessage("Access denied: your tenant is suspended")
+	case DenyTenantDeleted:
+		return ErrInvalidAccessKeyID
+	case DenyBucketNaming:
+		return ErrInvalidBucketName.WithMessage("The specified bucket name is not valid or does not follow this gateway's naming convention.")
+	case DenyKeyNaming:
+		// Naming rules are not secret; the statement Sid names the rule
+		message := "Access denied: the object key does not follow the naming rules for this bucket"
+		if e.Statement != "" && !e.Verbose {
+			message += " (rule: " + e.Statement + ")"
+		}
+		return ErrAccessDenied.WithMessage(message)
 	case DenyInternalError:
-		code = "InternalError"
-		message = "We encountered an internal error. Please try again."
+		return ErrInternalError
+	case DenyThrottled:
+		return ErrSlowDown
+	default:
+		return ErrAccessDenied
+	}
+}
+
+// ToS3Error converts an AccessDeniedError to an S3Error
+func (e *AccessDeniedError) ToS3Error() *S3Error {
+	c := e.errorCode()
+	message := c.Message
+	if e.Verbose {
+		message += " (reason: " + string(e.Reason)
+		if e.Policy != "" {
+			message += ", policy: " + e.Policy
+		}
+		if e.Statement != "" {
+			message += ", statement: " + e.Statement
+		}
+		message += ")"
 	}
 
 	return &S3Error{
-		Code:      code,
+		Code:      c.Code,
 		Message:   message,
 		Resource:  e.Resource,
 		RequestID: e.RequestID,
@@ -79,18 +195,7 @@ func (e *AccessDeniedError) ToS3Error() *S3Error {
 
 // HTTPStatusCode returns the appropriate HTTP status code
 func (e *AccessDeniedError) HTTPStatusCode() int {
-	switch e.Reason {
-	case DenyAuthFailed:
-		return http.StatusForbidden
-	case DenyTenantBoundary, DenyPolicy:
-		return http.StatusForbidden
-	case DenyInvalidResource:
-		return http.StatusBadRequest
-	case DenyInternalError:
-		return http.StatusInternalServerError
-	default:
-		return http.StatusForbidden
-	}
+	return e.errorCode().StatusCode
 }
 
 // WriteS3Error writes an S3 XML error response to the response writer
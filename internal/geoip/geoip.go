@@ -0,0 +1,82 @@
+package geoip
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// Info is what the databases know about an address; empty fields are unknown
+type Info struct {
+	Country string // ISO 3166-1 alpha-2 code
+	ASN     uint32
+	ASOrg   string
+}
+
+// Resolver looks up addresses in the configured country and ASN databases
+type Resolver struct {
+	country *Reader
+	asn     *Reader
+}
+
+// New opens the databases in cfg. It returns nil when none is configured.
+func New(cfg *config.GeoIPConfig) (*Resolver, error) {
+	if cfg.CountryDatabase == "" && cfg.ASNDatabase == "" {
+		return nil, nil
+	}
+
+	r := &Resolver{}
+	var err error
+	if cfg.CountryDatabase != "" {
+		if r.country, err = Open(cfg.CountryDatabase); err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP country database: %w", err)
+		}
+	}
+	if cfg.ASNDatabase != "" {
+		if r.asn, err = Open(cfg.ASNDatabase); err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP ASN database: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// Lookup resolves ip. Unparseable and unknown addresses yield an empty Info.
+func (r *Resolver) Lookup(ip string) Info {
+	var info Info
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return info
+	}
+
+	if r.country != nil {
+		// City databases share the layout; registered_country covers
+		// addresses with no observed location
+		for _, field := range []string{"country", "registered_country"} {
+			if v, ok := r.lookup(r.country, addr, field, "iso_code"); ok {
+				info.Country, _ = v.(string)
+				break
+			}
+		}
+	}
+	if r.asn != nil {
+		if v, ok := r.lookup(r.asn, addr, "autonomous_system_number"); ok {
+			n, _ := v.(uint64)
+			info.ASN = uint32(n)
+		}
+		if v, ok := r.lookup(r.asn, addr, "autonomous_system_organization"); ok {
+			info.ASOrg, _ = v.(string)
+		}
+	}
+	return info
+}
+
+func (r *Resolver) lookup(db *Reader, addr netip.Addr, path ...string) (interface{}, bool) {
+	v, ok, err := db.Lookup(addr, path...)
+	if err != nil {
+		log.Printf("GeoIP lookup of %s failed: %v", addr, err)
+		return nil, false
+	}
+	return v, ok
+}
@@ -0,0 +1,418 @@
+// Package geoip resolves client addresses to countries and autonomous systems
+// using MaxMind DB (.mmdb) files such as GeoLite2-Country and GeoLite2-ASN.
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of the file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Data section types from the MaxMind DB format specification
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+var errCorrupt = errors.New("corrupt MaxMind database")
+
+// Reader looks up addresses in a MaxMind DB file held in memory
+type Reader struct {
+	tree       []byte
+	data       decoder
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint // node reached by 96 zero bits, where IPv4 lives in IPv6 trees
+
+	DatabaseType string // e.g. GeoLite2-Country
+}
+
+// Open reads a MaxMind DB file
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// NewReader parses a MaxMind DB held in buf
+func NewReader(buf []byte) (*Reader, error) {
+	idx := bytes.LastIndex(buf, metadataMarker)
+	if idx < 0 {
+		return nil, fmt.Errorf("not a MaxMind database: metadata not found")
+	}
+	meta := decoder{buf: buf[idx+len(metadataMarker):]}
+	var fields struct {
+		nodeCount, recordSize, ipVersion uint64
+		dbType                           string
+	}
+	for _, f := range []struct {
+		key string
+		dst interface{}
+	}{
+		{"node_count", &fields.nodeCount},
+		{"record_size", &fields.recordSize},
+		{"ip_version", &fields.ipVersion},
+		{"database_type", &fields.dbType},
+	} {
+		v, ok, err := meta.lookup(0, []string{f.key})
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid metadata: missing %s", f.key)
+		}
+		switch dst := f.dst.(type) {
+		case *uint64:
+			n, ok := v.(uint64)
+			if !ok {
+				return nil, fmt.Errorf("invalid metadata: %s is not an integer", f.key)
+			}
+			*dst = n
+		case *string:
+			*dst, _ = v.(string)
+		}
+	}
+
+	r := &Reader{
+		nodeCount:    uint(fields.nodeCount),
+		recordSize:   uint(fields.recordSize),
+		ipVersion:    uint(fields.ipVersion),
+		DatabaseType: fields.dbType,
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", r.ipVersion)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+16 > uint(idx) {
+		return nil, errCorrupt
+	}
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf: buf[treeSize+16 : idx]}
+
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// Lookup returns the value at path in the record for ip, e.g.
+// ["country", "iso_code"]. It reports false when the address or the path is
+// not in the database.
+func (r *Reader) Lookup(ip netip.Addr, path ...string) (interface{}, bool, error) {
+	offset, ok, err := r.find(ip.Unmap())
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return r.data.lookup(offset, path)
+}
+
+// find walks the search tree, returning the data section offset of ip's record
+func (r *Reader) find(ip netip.Addr) (uint, bool, error) {
+	var addr []byte
+	node := uint(0)
+	switch {
+	case ip.Is4() && r.ipVersion == 6:
+		a := ip.As4()
+		addr, node = a[:], r.ipv4Start
+	case ip.Is4():
+		a := ip.As4()
+		addr = a[:]
+	case r.ipVersion == 4:
+		return 0, false, nil
+	default:
+		a := ip.As16()
+		addr = a[:]
+	}
+
+	for i := 0; i < len(addr)*8 && node < r.nodeCount; i++ {
+		bit := uint(addr[i>>3]>>(7-(i&7))) & 1
+		node = r.record(node, bit)
+	}
+	switch {
+	case node == r.nodeCount:
+		return 0, false, nil
+	case node > r.nodeCount:
+		offset := node - r.nodeCount - 16
+		if offset >= uint(len(r.data.buf)) {
+			return 0, false, errCorrupt
+		}
+		return offset, true, nil
+	}
+	return 0, false, errCorrupt
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a tree node
+func (r *Reader) record(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		return uintBE(r.tree[node*6+bit*3:][:3])
+	case 28:
+		off := node * 7
+		if bit == 0 {
+			return uint(r.tree[off+3]&0xF0)<<20 | uintBE(r.tree[off:off+3])
+		}
+		return uint(r.tree[off+3]&0x0F)<<24 | uintBE(r.tree[off+4:off+7])
+	default:
+		return uintBE(r.tree[node*8+bit*4:][:4])
+	}
+}
+
+// decoder reads values from a data section. Pointers are offsets into buf.
+type decoder struct {
+	buf []byte
+}
+
+// header reads a control byte and its extended type and size bytes. For
+// pointers the raw size bits are returned.
+func (d *decoder) header(off uint) (typ int, size uint, next uint, err error) {
+	if off >= uint(len(d.buf)) {
+		return 0, 0, 0, errCorrupt
+	}
+	ctrl := d.buf[off]
+	off++
+	typ = int(ctrl >> 5)
+	if typ == typeExtended {
+		if off >= uint(len(d.buf)) {
+			return 0, 0, 0, errCorrupt
+		}
+		typ = 7 + int(d.buf[off])
+		off++
+	}
+	size = uint(ctrl & 0x1f)
+	if typ == typePointer || size < 29 {
+		return typ, size, off, nil
+	}
+
+	n := size - 28
+	if off+n > uint(len(d.buf)) {
+		return 0, 0, 0, errCorrupt
+	}
+	extra := uintBE(d.buf[off : off+n])
+	switch size {
+	case 29:
+		size = 29 + extra
+	case 30:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+	return typ, size, off + n, nil
+}
+
+// pointer decodes a pointer's target from its size bits and following bytes
+func (d *decoder) pointer(size, off uint) (target, next uint, err error) {
+	ss := (size >> 3) & 3
+	n := ss + 1
+	if off+n > uint(len(d.buf)) {
+		return 0, 0, errCorrupt
+	}
+	p := uintBE(d.buf[off : off+n])
+	switch ss {
+	case 0:
+		p |= (size & 7) << 8
+	case 1:
+		p = (p | (size&7)<<16) + 2048
+	case 2:
+		p = (p | (size&7)<<24) + 526336
+	}
+	return p, off + n, nil
+}
+
+// follow resolves a pointer at off to its target; other values are returned
+// in place
+func (d *decoder) follow(off uint) (uint, error) {
+	typ, size, next, err := d.header(off)
+	if err != nil || typ != typePointer {
+		return off, err
+	}
+	target, _, err := d.pointer(size, next)
+	return target, err
+}
+
+// lookup decodes the value at path within the map at off, skipping
+// everything else
+func (d *decoder) lookup(off uint, path []string) (interface{}, bool, error) {
+	off, err := d.follow(off)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(path) == 0 {
+		v, _, err := d.decode(off)
+		return v, err == nil, err
+	}
+
+	typ, size, off, err := d.header(off)
+	if err != nil || typ != typeMap {
+		return nil, false, err
+	}
+	for i := uint(0); i < size; i++ {
+		key, next, err := d.decode(off)
+		if err != nil {
+			return nil, false, err
+		}
+		if key == path[0] {
+			return d.lookup(next, path[1:])
+		}
+		if off, err = d.skip(next); err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil
+}
+
+// skip returns the offset following the value at off
+func (d *decoder) skip(off uint) (uint, error) {
+	typ, size, off, err := d.header(off)
+	if err != nil {
+		return 0, err
+	}
+	switch typ {
+	case typePointer:
+		_, next, err := d.pointer(size, off)
+		return next, err
+	case typeMap, typeArray:
+		n := size
+		if typ == typeMap {
+			n *= 2
+		}
+		for i := uint(0); i < n; i++ {
+			if off, err = d.skip(off); err != nil {
+				return 0, err
+			}
+		}
+		return off, nil
+	case typeBool:
+		return off, nil
+	}
+	if off+size > uint(len(d.buf)) {
+		return 0, errCorrupt
+	}
+	return off + size, nil
+}
+
+// decode returns the value at off and the offset following it. Unsigned
+// integers decode to uint64, uint128 to *big.Int, maps to
+// map[string]interface{} and arrays to []interface{}.
+func (d *decoder) decode(off uint) (interface{}, uint, error) {
+	typ, size, off, err := d.header(off)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case typePointer:
+		target, next, err := d.pointer(size, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(target)
+		return v, next, err
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(off)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errCorrupt
+			}
+			if m[key], off, err = d.decode(next); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, off, nil
+	case typeArray:
+		a := make([]interface{}, size)
+		for i := range a {
+			if a[i], off, err = d.decode(off); err != nil {
+				return nil, 0, err
+			}
+		}
+		return a, off, nil
+	case typeBool:
+		return size != 0, off, nil
+	}
+
+	if off+size > uint(len(d.buf)) {
+		return nil, 0, errCorrupt
+	}
+	b, next := d.buf[off:off+size], off+size
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float64frombits(uint64BE(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float32frombits(uint32(uint64BE(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errCorrupt
+		}
+		return uint64BE(b), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errCorrupt
+		}
+		return int32(uint32(uint64BE(b))), next, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), next, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unexpected type %d", errCorrupt, typ)
+}
+
+// uintBE decodes a big-endian tree record or size of up to 4 bytes
+func uintBE(b []byte) uint {
+	return uint(uint64BE(b))
+}
+
+// uint64BE decodes a big-endian unsigned integer of up to 8 bytes
+func uint64BE(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
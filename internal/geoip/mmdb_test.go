@@ -0,0 +1,234 @@
+package geoip
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// encodeValue encodes strings, uint32s and maps in the MaxMind DB data format
+func encodeValue(v interface{}) []byte {
+	header := func(typ int, size int) []byte {
+		var b []byte
+		ctrl := byte(0)
+		if typ <= 7 {
+			ctrl = byte(typ) << 5
+		}
+		switch {
+		case size < 29:
+			ctrl |= byte(size)
+		case size < 285:
+			ctrl |= 29
+		default:
+			ctrl |= 30
+		}
+		b = append(b, ctrl)
+		if typ > 7 {
+			b = append(b, byte(typ-7))
+		}
+		switch {
+		case size >= 285:
+			b = append(b, byte((size-285)>>8), byte(size-285))
+		case size >= 29:
+			b = append(b, byte(size-29))
+		}
+		return b
+	}
+
+	switch v := v.(type) {
+	case string:
+		return append(header(typeString, len(v)), v...)
+	case uint32:
+		b := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+		for len(b) > 0 && b[0] == 0 {
+			b = b[1:]
+		}
+		return append(header(typeUint32, len(b)), b...)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := header(typeMap, len(v))
+		for _, k := range keys {
+			out = append(out, encodeValue(k)...)
+			out = append(out, encodeValue(v[k])...)
+		}
+		return out
+	}
+	panic("unsupported value")
+}
+
+// buildDB writes an IPv6 database with 24-bit records mapping each prefix
+// to its record. IPv4 prefixes are placed under ::/96.
+func buildDB(t *testing.T, records map[string]map[string]interface{}) []byte {
+	t.Helper()
+
+	type node struct{ children [2]*node }
+	root := &node{}
+	var data []byte
+	leaves := map[*node]int{}
+
+	for prefix, record := range records {
+		p := netip.MustParsePrefix(prefix)
+		addr, bits := p.Addr().As16(), p.Bits()
+		if p.Addr().Is4() {
+			v4 := p.Addr().As4()
+			addr = [16]byte{12: v4[0], 13: v4[1], 14: v4[2], 15: v4[3]}
+			bits += 96
+		}
+		n := root
+		for i := 0; i < bits; i++ {
+			bit := (addr[i/8] >> (7 - i%8)) & 1
+			if n.children[bit] == nil {
+				n.children[bit] = &node{}
+			}
+			n = n.children[bit]
+		}
+		leaves[n] = len(data)
+		data = append(data, encodeValue(record)...)
+	}
+
+	// Number the interior nodes breadth first
+	var nodes []*node
+	ids := map[*node]int{}
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if _, ok := leaves[n]; ok {
+			continue
+		}
+		ids[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	count := len(nodes)
+	var tree []byte
+	for _, n := range nodes {
+		for _, c := range n.children {
+			value := count // empty
+			if c != nil {
+				if off, ok := leaves[c]; ok {
+					value = count + 16 + off
+				} else {
+					value = ids[c]
+				}
+			}
+			tree = append(tree, byte(value>>16), byte(value>>8), byte(value))
+		}
+	}
+
+	buf := append(tree, make([]byte, 16)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataMarker...)
+	buf = append(buf, encodeValue(map[string]interface{}{
+		"node_count":    uint32(count),
+		"record_size":   uint32(24),
+		"ip_version":    uint32(6),
+		"database_type": "Test-Country",
+	})...)
+	return buf
+}
+
+func TestReader_Lookup(t *testing.T) {
+	db := buildDB(t, map[string]map[string]interface{}{
+		"81.2.69.0/24":  {"country": map[string]interface{}{"iso_code": "GB", "names": map[string]interface{}{"en": "United Kingdom"}}},
+		"2.125.0.0/16":  {"registered_country": map[string]interface{}{"iso_code": "DE"}},
+		"2001:db8::/32": {"country": map[string]interface{}{"iso_code": "JP"}},
+	})
+	r, err := NewReader(db)
+	if err != nil {
+		t.Fatalf("failed to parse database: %v", err)
+	}
+	if r.DatabaseType != "Test-Country" {
+		t.Errorf("DatabaseType = %q", r.DatabaseType)
+	}
+
+	tests := []struct {
+		ip     string
+		path   []string
+		want   interface{}
+		wantOK bool
+	}{
+		{"81.2.69.160", []string{"country", "iso_code"}, "GB", true},
+		{"81.2.69.160", []string{"country", "names", "en"}, "United Kingdom", true},
+		{"::ffff:81.2.69.1", []string{"country", "iso_code"}, "GB", true},
+		{"81.2.70.1", []string{"country", "iso_code"}, nil, false},
+		{"2.125.160.216", []string{"country", "iso_code"}, nil, false},
+		{"2.125.160.216", []string{"registered_country", "iso_code"}, "DE", true},
+		{"2001:db8::1", []string{"country", "iso_code"}, "JP", true},
+		{"2001:db9::1", []string{"country", "iso_code"}, nil, false},
+	}
+	for _, tt := range tests {
+		got, ok, err := r.Lookup(netip.MustParseAddr(tt.ip), tt.path...)
+		if err != nil {
+			t.Errorf("%s %v: unexpected error: %v", tt.ip, tt.path, err)
+			continue
+		}
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("%s %v = %v, %v; want %v, %v", tt.ip, tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNewReader_Invalid(t *testing.T) {
+	if _, err := NewReader([]byte("not a database")); err == nil {
+		t.Error("expected error for data without metadata")
+	}
+	truncated := append([]byte{}, metadataMarker...)
+	truncated = append(truncated, encodeValue(map[string]interface{}{
+		"node_count": uint32(100), "record_size": uint32(24), "ip_version": uint32(6), "database_type": "x",
+	})...)
+	if _, err := NewReader(truncated); err == nil {
+		t.Error("expected error for truncated search tree")
+	}
+}
+
+func TestResolver_Lookup(t *testing.T) {
+	dir := t.TempDir()
+	countryPath := filepath.Join(dir, "country.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	country := buildDB(t, map[string]map[string]interface{}{
+		"81.2.69.0/24": {"country": map[string]interface{}{"iso_code": "GB"}},
+	})
+	asn := buildDB(t, map[string]map[string]interface{}{
+		"81.2.0.0/16": {"autonomous_system_number": uint32(20712), "autonomous_system_organization": "Andrews & Arnold Ltd"},
+	})
+	if err := os.WriteFile(countryPath, country, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(asnPath, asn, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(&config.GeoIPConfig{CountryDatabase: countryPath, ASNDatabase: asnPath})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Info{Country: "GB", ASN: 20712, ASOrg: "Andrews & Arnold Ltd"}
+	if got := r.Lookup("81.2.69.160"); got != want {
+		t.Errorf("Lookup = %+v, want %+v", got, want)
+	}
+	if got := r.Lookup("10.0.0.1"); got != (Info{}) {
+		t.Errorf("expected empty info for unknown address, got %+v", got)
+	}
+	if got := r.Lookup("not-an-ip"); got != (Info{}) {
+		t.Errorf("expected empty info for invalid address, got %+v", got)
+	}
+
+	if r, err := New(&config.GeoIPConfig{}); r != nil || err != nil {
+		t.Errorf("expected nil resolver without databases, got %v, %v", r, err)
+	}
+}
@@ -0,0 +1,294 @@
+// Package grant holds time-limited elevated-access grants made through the
+// admin API for incident response ("break-glass"). A grant attaches an extra
+// policy, and optionally extra scopes, to a client's credentials until it
+// expires or is revoked. Every grant, revocation and expiry is recorded in
+// the audit log together with its justification.
+package grant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/s3-access-control-adapter/internal/audit"
+)
+
+// Audit actions recorded for grant lifecycle events
+const (
+	ActionGrant  = "gateway:GrantElevatedAccess"
+	ActionRevoke = "gateway:RevokeElevatedAccess"
+	ActionExpire = "gateway:ExpireElevatedAccess"
+)
+
+// retention is how long ended grants are kept for GET /admin/grants
+const retention = 7 * 24 * time.Hour
+
+var (
+	ErrNotFound = errors.New("grant not found")
+	ErrEnded    = errors.New("grant already ended")
+)
+
+// Grant is a temporary additional policy for a client
+type Grant struct {
+	ID            string     `json:"id"`
+	ClientID      string     `json:"clientId"`
+	TenantID      string     `json:"tenantId,omitempty"`
+	Policy        string     `json:"policy"`
+	Scopes        []string   `json:"scopes,omitempty"`
+	Justification string     `json:"justification"`
+	GrantedBy     string     `json:"grantedBy,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	RevokedAt     *time.Time `json:"revokedAt,omitempty"`
+	// Expired is set once the expiry has been recorded in the audit log
+	Expired bool `json:"expired,omitempty"`
+}
+
+// Active reports whether the grant is in effect at t
+func (g *Grant) Active(t time.Time) bool {
+	return g.RevokedAt == nil && t.Before(g.ExpiresAt)
+}
+
+// Store holds grants, optionally persisted to a JSON file so they survive
+// restarts. Expired grants stop applying immediately; Sweep records their
+// expiry.
+type Store struct {
+	path   string
+	logger audit.Logger
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	grants map[string]*Grant
+}
+
+// NewStore creates a store persisted to path, loading its previous state,
+// and recording lifecycle events to logger. An empty path keeps grants in
+// memory; a nil logger records nothing.
+func NewStore(path string, logger audit.Logger) (*Store, error) {
+	s := &Store{path: path, logger: logger, now: time.Now, grants: make(map[string]*Grant)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read grant state: %w", err)
+	}
+	var grants []*Grant
+	if err := json.Unmarshal(data, &grants); err != nil {
+		return nil, fmt.Errorf("failed to parse grant state %s: %w", path, err)
+	}
+	for _, g := range grants {
+		s.grants[g.ID] = g
+	}
+	return s, nil
+}
+
+// Create records a grant of policy to a client for ttl and audits it
+func (s *Store) Create(g Grant, ttl time.Duration) (Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	g.ID = uuid.NewString()
+	g.Scopes = append([]string(nil), g.Scopes...)
+	g.CreatedAt, g.ExpiresAt = now, now.Add(ttl)
+	g.RevokedAt, g.Expired = nil, false
+	if err := s.put(&g); err != nil {
+		return Grant{}, err
+	}
+	s.record(ActionGrant, &g)
+	return g.copy(), nil
+}
+
+// Revoke ends a grant before it expires
+func (s *Store) Revoke(id string) (Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.grants[id]
+	if !ok {
+		return Grant{}, ErrNotFound
+	}
+	now := s.now()
+	if !old.Active(now) {
+		return Grant{}, ErrEnded
+	}
+	g := old.copy()
+	g.RevokedAt = &now
+	if err := s.put(&g); err != nil {
+		return Grant{}, err
+	}
+	s.record(ActionRevoke, &g)
+	return g.copy(), nil
+}
+
+// Get returns a grant
+func (s *Store) Get(id string) (Grant, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.grants[id]
+	if !ok {
+		return Grant{}, false
+	}
+	return g.copy(), true
+}
+
+// List returns all grants, newest first
+func (s *Store) List() []Grant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	grants := make([]Grant, 0, len(s.grants))
+	for _, g := range s.grants {
+		grants = append(grants, g.copy())
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].CreatedAt.After(grants[j].CreatedAt) })
+	return grants
+}
+
+// Active returns the grants in effect for a client
+func (s *Store) Active(clientID string) []Grant {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var grants []Grant
+	for _, g := range s.grants {
+		if g.ClientID == clientID && g.Active(now) {
+			grants = append(grants, g.copy())
+		}
+	}
+	return grants
+}
+
+// Sweep records the expiry of grants that ran out and forgets grants that
+// ended longer than the retention period ago
+func (s *Store) Sweep() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	changed := false
+	for id, g := range s.grants {
+		end := g.ExpiresAt
+		if g.RevokedAt != nil {
+			end = *g.RevokedAt
+		}
+		switch {
+		case now.Sub(end) > retention:
+			delete(s.grants, id)
+			changed = true
+		case g.RevokedAt == nil && !g.Expired && !g.Active(now):
+			g.Expired = true
+			s.record(ActionExpire, g)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// Run sweeps every interval until stop is closed
+func (s *Store) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := s.Sweep(); err != nil {
+			// The expiry is retried on the next sweep; expired grants no
+			// longer apply regardless
+			log.Printf("Failed to record grant expiry: %v", err)
+		}
+	}
+}
+
+// record writes a lifecycle event to the audit log. Called with s.mu held.
+func (s *Store) record(action string, g *Grant) {
+	if s.logger == nil {
+		return
+	}
+	s.logger.Log(&audit.Entry{
+		Timestamp:     s.now().UTC(),
+		RequestID:     g.ID,
+		ClientID:      g.ClientID,
+		TenantID:      g.TenantID,
+		Action:        action,
+		Resource:      g.Policy,
+		Decision:      "allow",
+		GrantID:       g.ID,
+		Justification: g.Justification,
+		GrantedBy:     g.GrantedBy,
+	})
+}
+
+// put stores g and persists the store, leaving the previous record in place
+// if that fails. Called with s.mu held.
+func (s *Store) put(g *Grant) error {
+	old, existed := s.grants[g.ID]
+	s.grants[g.ID] = g
+	if err := s.save(); err != nil {
+		if existed {
+			s.grants[g.ID] = old
+		} else {
+			delete(s.grants, g.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// save atomically writes the state file. Called with s.mu held.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	grants := make([]*Grant, 0, len(s.grants))
+	for _, g := range s.grants {
+		grants = append(grants, g)
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].ID < grants[j].ID })
+	data, err := json.MarshalIndent(grants, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".grants-*")
+	if err != nil {
+		return fmt.Errorf("failed to save grant state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save grant state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save grant state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save grant state: %w", err)
+	}
+	return nil
+}
+
+// copy returns a deep copy, so grants handed out are not changed later
+func (g *Grant) copy() Grant {
+	c := *g
+	c.Scopes = append([]string(nil), g.Scopes...)
+	if g.RevokedAt != nil {
+		revoked := *g.RevokedAt
+		c.RevokedAt = &revoked
+	}
+	return c
+}
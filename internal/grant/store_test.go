@@ -0,0 +1,94 @@
+package grant
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+)
+
+type recordingLogger struct{ entries []*audit.Entry }
+
+func (l *recordingLogger) Log(e *audit.Entry) error { l.entries = append(l.entries, e); return nil }
+func (l *recordingLogger) Close() error             { return nil }
+
+func TestStore_Lifecycle(t *testing.T) {
+	logger := &recordingLogger{}
+	s, err := NewStore("", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	g, err := s.Create(Grant{ClientID: "oncall", Policy: "prod-read", Justification: "INC-42"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active := s.Active("oncall"); len(active) != 1 || active[0].Policy != "prod-read" {
+		t.Errorf("Active = %+v", active)
+	}
+	if active := s.Active("other"); len(active) != 0 {
+		t.Errorf("Active(other) = %+v", active)
+	}
+
+	// Expired grants stop applying before the sweep records them
+	now = now.Add(time.Hour)
+	if active := s.Active("oncall"); len(active) != 0 {
+		t.Errorf("expired grant still active: %+v", active)
+	}
+	if _, err := s.Revoke(g.ID); !errors.Is(err, ErrEnded) {
+		t.Errorf("revoke expired: err = %v, want ErrEnded", err)
+	}
+	s.Sweep()
+	s.Sweep()
+
+	g2, _ := s.Create(Grant{ClientID: "oncall", Policy: "prod-write", Justification: "INC-43"}, time.Hour)
+	if _, err := s.Revoke(g2.ID); err != nil || len(s.Active("oncall")) != 0 {
+		t.Errorf("revoke: err = %v, active = %+v", err, s.Active("oncall"))
+	}
+	if _, err := s.Revoke("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoke missing: err = %v, want ErrNotFound", err)
+	}
+
+	var actions []string
+	for _, e := range logger.entries {
+		actions = append(actions, e.Action+" "+e.Justification)
+	}
+	want := []string{ActionGrant + " INC-42", ActionExpire + " INC-42", ActionGrant + " INC-43", ActionRevoke + " INC-43"}
+	if len(actions) != len(want) {
+		t.Fatalf("audit actions = %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("audit actions = %v, want %v", actions, want)
+		}
+	}
+
+	// Ended grants are forgotten after the retention period
+	now = now.Add(retention + time.Hour)
+	s.Sweep()
+	if list := s.List(); len(list) != 0 {
+		t.Errorf("List after retention = %+v", list)
+	}
+}
+
+func TestStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grants.json")
+	s, _ := NewStore(path, nil)
+	g, err := s.Create(Grant{ClientID: "oncall", Policy: "prod-read", Scopes: []string{"prod-*"}, Justification: "INC-42"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := reloaded.Get(g.ID)
+	if !ok || got.Policy != "prod-read" || len(got.Scopes) != 1 || !got.ExpiresAt.Equal(g.ExpiresAt) {
+		t.Errorf("reloaded grant = %+v, %v", got, ok)
+	}
+}
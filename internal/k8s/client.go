@@ -0,0 +1,235 @@
+// Package k8s runs the gateway's Kubernetes controller mode: it watches
+// S3AccessPolicy and S3ClientCredential custom resources and loads them into
+// the policy engine and credential store. It talks to the API server
+// directly over HTTP, so it needs no client libraries.
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceAccountDir holds the in-cluster token, CA and namespace
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// tokenRefreshInterval is how long a token read from a file is reused;
+// projected service account tokens are rotated by the kubelet
+const tokenRefreshInterval = time.Minute
+
+// Client is a minimal Kubernetes API client for listing and watching resources
+type Client struct {
+	host       string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	token     string
+	tokenFile string    // re-read every tokenRefreshInterval when set
+	tokenRead time.Time // when token was last read from tokenFile
+	now       func() time.Time
+}
+
+// NewClient creates a client for the API server at host with a fixed token
+func NewClient(host, token string, httpClient *http.Client) *Client {
+	return &Client{host: strings.TrimSuffix(host, "/"), token: token, httpClient: httpClient, now: time.Now}
+}
+
+// NewTokenFileClient creates a client that reads its token from tokenFile
+// and re-reads it periodically so rotated tokens are picked up
+func NewTokenFileClient(host, tokenFile string, httpClient *http.Client) (*Client, error) {
+	c := NewClient(host, "", httpClient)
+	c.tokenFile = tokenFile
+	token, err := readToken(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	c.token, c.tokenRead = token, c.now()
+	return c, nil
+}
+
+func readToken(path string) (string, error) {
+	token, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// bearerToken returns the current token, re-reading the token file when the
+// cached copy is stale. A failed re-read keeps the previous token.
+func (c *Client) bearerToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokenFile != "" && c.now().Sub(c.tokenRead) >= tokenRefreshInterval {
+		token, err := readToken(c.tokenFile)
+		if err != nil {
+			log.Printf("Kubernetes: %v; keeping the previous token", err)
+		} else {
+			c.token = token
+		}
+		c.tokenRead = c.now()
+	}
+	return c.token
+}
+
+// NewInClusterClient creates a client from the pod's service account
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST is not set")
+	}
+
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in service account CA")
+	}
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}}
+	return NewTokenFileClient("https://"+net.JoinHostPort(host, port), serviceAccountDir+"/token", httpClient)
+}
+
+// InClusterNamespace returns the namespace the pod runs in
+func InClusterNamespace() string {
+	ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(ns))
+}
+
+// objectMeta is the subset of metadata the controller uses
+type objectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// object is a custom resource with an undecoded spec, or a Secret
+type object struct {
+	Metadata objectMeta        `json:"metadata"`
+	Spec     json.RawMessage   `json:"spec"`
+	Data     map[string][]byte `json:"data"` // Secrets only; base64 in JSON, decoded by encoding/json
+}
+
+type objectList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []object `json:"items"`
+}
+
+// watchEvent is one line of a watch stream
+type watchEvent struct {
+	Type   string          `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK or ERROR
+	Object json.RawMessage `json:"object"`
+}
+
+// status is the body of an ERROR watch event
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// secretsPath returns the API path for the Secrets of namespace (all if empty)
+func secretsPath(namespace string) string {
+	if namespace == "" {
+		return "/api/v1/secrets"
+	}
+	return fmt.Sprintf("/api/v1/namespaces/%s/secrets", namespace)
+}
+
+// resourcePath returns the API path for a custom resource collection
+func resourcePath(group, version, namespace, plural string) string {
+	if namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/%s", group, version, plural)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", group, version, namespace, plural)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := c.host + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// list returns all objects in a collection and the list's resourceVersion
+func (c *Client) list(ctx context.Context, path string) (*objectList, error) {
+	resp, err := c.get(ctx, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list objectList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode %s list: %w", path, err)
+	}
+	return &list, nil
+}
+
+// watch streams events after resourceVersion to fn until the stream ends,
+// ctx is canceled or fn returns an error
+func (c *Client) watch(ctx context.Context, path, resourceVersion string, fn func(watchEvent) error) error {
+	query := url.Values{}
+	query.Set("watch", "true")
+	query.Set("resourceVersion", resourceVersion)
+	query.Set("allowWatchBookmarks", "true")
+
+	resp, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var event watchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("failed to decode watch event: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
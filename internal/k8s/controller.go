@@ -0,0 +1,397 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// Custom resource coordinates
+const (
+	Group            = "gateway.s3-access-control.io"
+	Version          = "v1alpha1"
+	PolicyPlural     = "s3accesspolicies"
+	CredentialPlural = "s3clientcredentials"
+)
+
+// SecretPlural is watched alongside the custom resources, so rotated secret
+// keys take effect without waiting for a resync
+const SecretPlural = "secrets"
+
+// relistBackoff is the pause before relisting after a failed list or watch
+const relistBackoff = 5 * time.Second
+
+// PolicyLoader receives the full set of policies on every change
+type PolicyLoader interface {
+	Load(cfg *config.PoliciesConfig) error
+}
+
+// CredentialLoader receives the full set of credentials on every change
+type CredentialLoader interface {
+	Load(cfg *config.CredentialsConfig) error
+}
+
+// policySpec is the spec of an S3AccessPolicy; the policy name is the
+// resource's namespace/name, so namespaces cannot collide or reference each
+// other's policies
+type policySpec struct {
+	Version    string          `json:"version"`
+	Statements []statementSpec `json:"statements"`
+}
+
+type statementSpec struct {
+	Sid        string                                       `json:"sid"`
+	Effect     string                                       `json:"effect"`
+	Actions    []string                                     `json:"actions"`
+	Resources  []string                                     `json:"resources"`
+	Conditions map[string]map[string]config.ConditionValues `json:"conditions"`
+	RateLimit  *config.RateLimit                            `json:"rateLimit"`
+}
+
+// credentialSpec is the spec of an S3ClientCredential. The secret key is read
+// from a Secret in the resource's namespace.
+type credentialSpec struct {
+	AccessKey    string `json:"accessKey"`
+	SecretKeyRef struct {
+		Name string `json:"name"`
+		Key  string `json:"key"`
+	} `json:"secretKeyRef"`
+	ClientID    string   `json:"clientId"`
+	TenantID    string   `json:"tenantId"`
+	Description string   `json:"description"`
+	Policies    []string `json:"policies"`
+	Scopes      []string `json:"scopes"`
+	Regions     []string `json:"regions"`
+
+	AllowedActions []string `json:"allowedActions"`
+}
+
+// Controller mirrors custom resources into the policy engine and credential store
+type Controller struct {
+	client      *Client
+	namespace   string
+	resync      time.Duration
+	tenants     map[string][]string // tenant ID to the namespaces that may claim it; nil allows any
+	policies    PolicyLoader
+	credentials CredentialLoader
+
+	mu             sync.Mutex
+	policyObjs     map[string]object // keyed by namespace/name
+	credentialObjs map[string]object
+	secretObjs     map[string]object
+	secretRefs     map[string]bool // secrets referenced by the last sync
+	listed         map[string]bool // plurals with a completed initial list
+	synced         chan struct{}
+	syncOnce       sync.Once
+
+	// syncMu orders loads, which run outside mu
+	syncMu sync.Mutex
+}
+
+// NewController creates a controller watching cfg.Namespace (all if empty)
+func NewController(client *Client, cfg *config.KubernetesConfig, policies PolicyLoader, credentials CredentialLoader) *Controller {
+	return &Controller{
+		client:         client,
+		namespace:      cfg.Namespace,
+		resync:         cfg.ResyncInterval,
+		tenants:        cfg.TenantNamespaces,
+		policies:       policies,
+		credentials:    credentials,
+		policyObjs:     make(map[string]object),
+		credentialObjs: make(map[string]object),
+		secretObjs:     make(map[string]object),
+		secretRefs:     make(map[string]bool),
+		listed:         make(map[string]bool),
+		synced:         make(chan struct{}),
+	}
+}
+
+// Synced is closed once every watched kind has been listed and loaded
+func (c *Controller) Synced() <-chan struct{} {
+	return c.synced
+}
+
+// Run watches the resource kinds and Secrets until ctx is canceled
+func (c *Controller) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, plural := range []string{PolicyPlural, CredentialPlural, SecretPlural} {
+		wg.Add(1)
+		go func(plural string) {
+			defer wg.Done()
+			c.listAndWatch(ctx, plural)
+		}(plural)
+	}
+	wg.Wait()
+}
+
+// listAndWatch keeps the cache for one kind current, relisting on errors,
+// expired watches and every resync interval
+func (c *Controller) listAndWatch(ctx context.Context, plural string) {
+	path := resourcePath(Group, Version, c.namespace, plural)
+	if plural == SecretPlural {
+		path = secretsPath(c.namespace)
+	}
+	for ctx.Err() == nil {
+		if err := c.listAndWatchOnce(ctx, path, plural); err != nil && ctx.Err() == nil {
+			log.Printf("Kubernetes %s watch failed, relisting: %v", plural, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(relistBackoff):
+			}
+		}
+	}
+}
+
+func (c *Controller) listAndWatchOnce(ctx context.Context, path, plural string) error {
+	list, err := c.client.list(ctx, path)
+	if err != nil {
+		return err
+	}
+	objs := make(map[string]object, len(list.Items))
+	for _, obj := range list.Items {
+		objs[objectKey(obj)] = obj
+	}
+	c.replace(plural, objs)
+
+	watchCtx := ctx
+	if c.resync > 0 {
+		var cancel context.CancelFunc
+		watchCtx, cancel = context.WithTimeout(ctx, c.resync)
+		defer cancel()
+	}
+
+	err = c.client.watch(watchCtx, path, list.Metadata.ResourceVersion, func(event watchEvent) error {
+		return c.handleEvent(plural, event)
+	})
+	if watchCtx.Err() != nil && ctx.Err() == nil {
+		return nil // resync interval elapsed
+	}
+	return err
+}
+
+func (c *Controller) handleEvent(plural string, event watchEvent) error {
+	switch event.Type {
+	case "BOOKMARK":
+		return nil
+	case "ERROR":
+		var st status
+		json.Unmarshal(event.Object, &st)
+		return fmt.Errorf("watch error %d: %s", st.Code, st.Message)
+	}
+
+	var obj object
+	if err := json.Unmarshal(event.Object, &obj); err != nil {
+		return fmt.Errorf("failed to decode %s object: %w", plural, err)
+	}
+
+	key := objectKey(obj)
+	c.mu.Lock()
+	objs := c.objects(plural)
+	if event.Type == "DELETED" {
+		delete(objs, key)
+	} else {
+		objs[key] = obj
+	}
+	// Only the Secrets credentials reference matter
+	unreferenced := plural == SecretPlural && !c.secretRefs[key]
+	c.mu.Unlock()
+
+	if !unreferenced {
+		c.sync()
+	}
+	return nil
+}
+
+// replace swaps in a freshly listed set of objects for one kind
+func (c *Controller) replace(plural string, objs map[string]object) {
+	c.mu.Lock()
+	switch plural {
+	case PolicyPlural:
+		c.policyObjs = objs
+	case CredentialPlural:
+		c.credentialObjs = objs
+	default:
+		c.secretObjs = objs
+	}
+	c.listed[plural] = true
+	c.mu.Unlock()
+
+	c.sync()
+}
+
+func (c *Controller) objects(plural string) map[string]object {
+	switch plural {
+	case PolicyPlural:
+		return c.policyObjs
+	case CredentialPlural:
+		return c.credentialObjs
+	}
+	return c.secretObjs
+}
+
+// sync loads the cached resources once both kinds have been listed. Invalid
+// resources are logged and skipped, so one broken resource cannot hold back
+// changes to the others, deletions in particular.
+func (c *Controller) sync() {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+
+	c.mu.Lock()
+	if !c.listed[PolicyPlural] || !c.listed[CredentialPlural] || !c.listed[SecretPlural] {
+		c.mu.Unlock()
+		return
+	}
+	policyObjs := maps.Clone(c.policyObjs)
+	credentialObjs := maps.Clone(c.credentialObjs)
+	secretObjs := maps.Clone(c.secretObjs)
+	c.mu.Unlock()
+
+	policies := policiesFromObjects(policyObjs)
+	if err := c.policies.Load(policies); err != nil {
+		log.Printf("Kubernetes policies not applied: %v", err)
+	}
+
+	credentials, secretRefs := c.credentialsFromObjects(credentialObjs, secretObjs)
+	c.mu.Lock()
+	c.secretRefs = secretRefs
+	c.mu.Unlock()
+	if err := c.credentials.Load(credentials); err != nil {
+		log.Printf("Kubernetes credentials not applied: %v", err)
+	}
+
+	// Resources may be created in any order, so a missing policy is only
+	// reported; the credential is denied until it appears
+	if err := config.CheckPolicyReferences(credentials.Credentials, policies.Policies); err != nil {
+		log.Printf("Kubernetes credentials reference unknown policies: %v", err)
+	}
+
+	c.syncOnce.Do(func() {
+		log.Printf("Loaded %d policies and %d credentials from Kubernetes", len(policies.Policies), len(credentials.Credentials))
+		close(c.synced)
+	})
+}
+
+// policiesFromObjects converts the valid policies, logging the others
+func policiesFromObjects(objs map[string]object) *config.PoliciesConfig {
+	cfg := &config.PoliciesConfig{}
+	for _, key := range sortedKeys(objs) {
+		obj := objs[key]
+		var spec policySpec
+		if err := json.Unmarshal(obj.Spec, &spec); err != nil {
+			log.Printf("S3AccessPolicy %s skipped: invalid spec: %v", key, err)
+			continue
+		}
+		p := config.Policy{Name: key, Version: spec.Version}
+		for _, stmt := range spec.Statements {
+			p.Statements = append(p.Statements, config.Statement{
+				Sid:        stmt.Sid,
+				Effect:     config.Effect(stmt.Effect),
+				Actions:    stmt.Actions,
+				Resources:  stmt.Resources,
+				Conditions: stmt.Conditions,
+				RateLimit:  stmt.RateLimit,
+			})
+		}
+		if err := config.ValidatePolicies(&config.PoliciesConfig{Policies: []config.Policy{p}}); err != nil {
+			log.Printf("S3AccessPolicy %s skipped: %v", key, err)
+			continue
+		}
+		cfg.Policies = append(cfg.Policies, p)
+	}
+	return cfg
+}
+
+// credentialsFromObjects converts the valid credentials whose secret key is
+// in secrets, logging the others, and returns the Secrets they reference. Of
+// credentials sharing an access key, the first by namespace/name is kept.
+// Policies are resolved in the credential's namespace.
+func (c *Controller) credentialsFromObjects(objs, secrets map[string]object) (*config.CredentialsConfig, map[string]bool) {
+	cfg := &config.CredentialsConfig{}
+	refs := make(map[string]bool)
+	accessKeys := make(map[string]string)
+	for _, key := range sortedKeys(objs) {
+		obj := objs[key]
+		var spec credentialSpec
+		if err := json.Unmarshal(obj.Spec, &spec); err != nil {
+			log.Printf("S3ClientCredential %s skipped: invalid spec: %v", key, err)
+			continue
+		}
+		secretName := obj.Metadata.Namespace + "/" + spec.SecretKeyRef.Name
+		refs[secretName] = true
+		if c.tenants != nil && !slices.Contains(c.tenants[spec.TenantID], obj.Metadata.Namespace) {
+			log.Printf("S3ClientCredential %s skipped: namespace %s may not claim tenant %q", key, obj.Metadata.Namespace, spec.TenantID)
+			continue
+		}
+		if other, ok := accessKeys[spec.AccessKey]; ok {
+			log.Printf("S3ClientCredential %s skipped: access key %s is already used by %s", key, spec.AccessKey, other)
+			continue
+		}
+		secretKey, err := secretValue(secrets, secretName, spec.SecretKeyRef.Key)
+		if err != nil {
+			log.Printf("S3ClientCredential %s skipped: %v", key, err)
+			continue
+		}
+		cred := config.Credential{
+			AccessKey:   spec.AccessKey,
+			SecretKey:   secretKey,
+			ClientID:    spec.ClientID,
+			TenantID:    spec.TenantID,
+			Description: spec.Description,
+			Policies:    namespaced(obj.Metadata.Namespace, spec.Policies),
+			Scopes:      spec.Scopes,
+			Regions:     spec.Regions,
+
+			AllowedActions: spec.AllowedActions,
+		}
+		if err := config.ValidateCredentials(&config.CredentialsConfig{Credentials: []config.Credential{cred}}); err != nil {
+			log.Printf("S3ClientCredential %s skipped: %v", key, err)
+			continue
+		}
+		accessKeys[spec.AccessKey] = key
+		cfg.Credentials = append(cfg.Credentials, cred)
+	}
+	return cfg, refs
+}
+
+// secretValue reads one key of a cached Secret
+func secretValue(secrets map[string]object, name, key string) (string, error) {
+	s, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found", name)
+	}
+	value, ok := s.Data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %q", name, key)
+	}
+	return string(value), nil
+}
+
+// namespaced qualifies policy names with namespace
+func namespaced(namespace string, names []string) []string {
+	qualified := make([]string, len(names))
+	for i, name := range names {
+		qualified[i] = namespace + "/" + name
+	}
+	return qualified
+}
+
+func objectKey(obj object) string {
+	return obj.Metadata.Namespace + "/" + obj.Metadata.Name
+}
+
+func sortedKeys(objs map[string]object) []string {
+	keys := make([]string, 0, len(objs))
+	for key := range objs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,309 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+type recordingPolicies struct {
+	mu   sync.Mutex
+	last *config.PoliciesConfig
+}
+
+func (r *recordingPolicies) Load(cfg *config.PoliciesConfig) error {
+	if err := config.ValidatePolicies(cfg); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = cfg
+	return nil
+}
+
+func (r *recordingPolicies) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	if r.last != nil {
+		for _, p := range r.last.Policies {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+type recordingCredentials struct {
+	mu   sync.Mutex
+	last *config.CredentialsConfig
+}
+
+func (r *recordingCredentials) Load(cfg *config.CredentialsConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = cfg
+	return nil
+}
+
+const policyJSON = `{"metadata":{"name":%q,"namespace":"apps","resourceVersion":"1"},` +
+	`"spec":{"statements":[{"sid":"Read","effect":"Allow","actions":["s3:GetObject"],"resources":["arn:aws:s3:::data/*"]}]}}`
+
+const secretJSON = `{"metadata":{"name":"app-keys","namespace":"apps","resourceVersion":"1"},"data":{"secret":%q}}`
+
+// fakeAPIServer serves one policy, one credential and its Secret, and
+// streams policy and Secret watch events sent on events and secretEvents
+func fakeAPIServer(t *testing.T, events, secretEvents <-chan string) *httptest.Server {
+	mux := http.NewServeMux()
+	policies := resourcePath(Group, Version, "apps", PolicyPlural)
+	credentials := resourcePath(Group, Version, "apps", CredentialPlural)
+
+	stream := func(w http.ResponseWriter, r *http.Request, events <-chan string) {
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				fmt.Fprintln(w, event)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}
+	mux.HandleFunc(policies, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "true" {
+			fmt.Fprintf(w, `{"metadata":{"resourceVersion":"1"},"items":[`+policyJSON+`]}`, "read-data")
+			return
+		}
+		stream(w, r, events)
+	})
+	mux.HandleFunc(credentials, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, `{"metadata":{"resourceVersion":"1"},"items":[{"metadata":{"name":"app","namespace":"apps"},
+"spec":{"accessKey":"AKIAAPP","secretKeyRef":{"name":"app-keys","key":"secret"},"clientId":"app","tenantId":"t1","policies":["read-data"]}}]}`)
+	})
+	mux.HandleFunc(secretsPath("apps"), func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("watch") != "true" {
+			fmt.Fprintf(w, `{"metadata":{"resourceVersion":"1"},"items":[`+secretJSON+`]}`, base64.StdEncoding.EncodeToString([]byte("s3cr3t")))
+			return
+		}
+		stream(w, r, secretEvents)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestController_SyncsResources(t *testing.T) {
+	events := make(chan string)
+	secretEvents := make(chan string)
+	server := fakeAPIServer(t, events, secretEvents)
+
+	policies := &recordingPolicies{}
+	credentials := &recordingCredentials{}
+	client := NewClient(server.URL, "test-token", server.Client())
+	controller := NewController(client, &config.KubernetesConfig{Namespace: "apps"}, policies, credentials)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go controller.Run(ctx)
+
+	select {
+	case <-controller.Synced():
+	case <-time.After(5 * time.Second):
+		t.Fatal("controller did not sync")
+	}
+
+	if names := policies.names(); len(names) != 1 || names[0] != "apps/read-data" {
+		t.Errorf("policies = %v, want [apps/read-data]", names)
+	}
+	credentials.mu.Lock()
+	if creds := credentials.last.Credentials; len(creds) != 1 || creds[0].SecretKey != "s3cr3t" || creds[0].ClientID != "app" ||
+		len(creds[0].Policies) != 1 || creds[0].Policies[0] != "apps/read-data" {
+		t.Errorf("credentials = %+v", creds)
+	}
+	credentials.mu.Unlock()
+
+	// A watch event adds a second policy
+	events <- `{"type":"ADDED","object":` + fmt.Sprintf(policyJSON, "read-more") + `}`
+	waitFor(t, func() bool { return len(policies.names()) == 2 })
+
+	// Deleting it restores the original set
+	events <- `{"type":"DELETED","object":` + fmt.Sprintf(policyJSON, "read-more") + `}`
+	waitFor(t, func() bool { return len(policies.names()) == 1 })
+
+	// A rotated secret key takes effect without a resync
+	secretEvents <- `{"type":"MODIFIED","object":` + fmt.Sprintf(secretJSON, base64.StdEncoding.EncodeToString([]byte("rotated"))) + `}`
+	waitFor(t, func() bool {
+		credentials.mu.Lock()
+		defer credentials.mu.Unlock()
+		return credentials.last.Credentials[0].SecretKey == "rotated"
+	})
+}
+
+func TestPoliciesFromObjects_SkipsInvalid(t *testing.T) {
+	var good object
+	json.Unmarshal([]byte(fmt.Sprintf(policyJSON, "good")), &good)
+	objs := map[string]object{
+		"apps/bad": {
+			Metadata: objectMeta{Name: "bad", Namespace: "apps"},
+			Spec:     json.RawMessage(`{"statements":"not-a-list"}`),
+		},
+		"apps/no-effect": {
+			Metadata: objectMeta{Name: "no-effect", Namespace: "apps"},
+			Spec:     json.RawMessage(`{"statements":[{"actions":["s3:GetObject"],"resources":["*"]}]}`),
+		},
+		"apps/good": good,
+	}
+	cfg := policiesFromObjects(objs)
+	if len(cfg.Policies) != 1 || cfg.Policies[0].Name != "apps/good" {
+		t.Errorf("policies = %+v, want only good", cfg.Policies)
+	}
+}
+
+func TestCredentialsFromObjects_SkipsInvalid(t *testing.T) {
+	secrets := map[string]object{"apps/app-keys": {
+		Metadata: objectMeta{Name: "app-keys", Namespace: "apps"},
+		Data:     map[string][]byte{"secret": []byte("s3cr3t")},
+	}}
+
+	credential := func(name, accessKey, secretName string) object {
+		return object{
+			Metadata: objectMeta{Name: name, Namespace: "apps"},
+			Spec: json.RawMessage(fmt.Sprintf(`{"accessKey":%q,"secretKeyRef":{"name":%q,"key":"secret"},`+
+				`"clientId":%q,"tenantId":"t1","policies":["read-data"]}`, accessKey, secretName, name)),
+		}
+	}
+	objs := map[string]object{
+		"apps/a-good":          credential("a-good", "AKIAGOOD", "app-keys"),
+		"apps/b-missing":       credential("b-missing", "AKIAMISSING", "no-such-secret"),
+		"apps/c-duplicate":     credential("c-duplicate", "AKIAGOOD", "app-keys"),
+		"apps/d-no-access-key": credential("d-no-access-key", "", "app-keys"),
+		"apps/e-bad": {
+			Metadata: objectMeta{Name: "e-bad", Namespace: "apps"},
+			Spec:     json.RawMessage(`{"accessKey":["not-a-string"]}`),
+		},
+		"apps/f-good": credential("f-good", "AKIAOTHER", "app-keys"),
+	}
+
+	controller := NewController(nil, &config.KubernetesConfig{}, nil, nil)
+	cfg, refs := controller.credentialsFromObjects(objs, secrets)
+	var clients []string
+	for _, cred := range cfg.Credentials {
+		clients = append(clients, cred.ClientID)
+	}
+	if len(clients) != 2 || clients[0] != "a-good" || clients[1] != "f-good" {
+		t.Errorf("credentials = %v, want [a-good f-good]", clients)
+	}
+	if !refs["apps/app-keys"] || !refs["apps/no-such-secret"] {
+		t.Errorf("referenced secrets = %v", refs)
+	}
+
+	// Deleting a valid credential takes effect while broken ones remain
+	delete(objs, "apps/f-good")
+	if cfg, _ := controller.credentialsFromObjects(objs, secrets); len(cfg.Credentials) != 1 {
+		t.Errorf("credentials after delete = %+v, want only a-good", cfg.Credentials)
+	}
+}
+
+func TestPoliciesFromObjects_Namespaced(t *testing.T) {
+	objs := make(map[string]object)
+	for _, ns := range []string{"team-a", "team-b"} {
+		var obj object
+		json.Unmarshal([]byte(strings.Replace(fmt.Sprintf(policyJSON, "read"), `"apps"`, strconv.Quote(ns), 1)), &obj)
+		objs[objectKey(obj)] = obj
+	}
+	cfg := policiesFromObjects(objs)
+	if err := config.ValidatePolicies(cfg); err != nil {
+		t.Fatalf("policies with one name in two namespaces: %v", err)
+	}
+	if len(cfg.Policies) != 2 || cfg.Policies[0].Name != "team-a/read" || cfg.Policies[1].Name != "team-b/read" {
+		t.Errorf("policies = %+v", cfg.Policies)
+	}
+}
+
+func TestCredentialsFromObjects_TenantNamespaces(t *testing.T) {
+	secrets := make(map[string]object)
+	objs := make(map[string]object)
+	for _, ns := range []string{"tenant-001", "intruder"} {
+		secrets[ns+"/keys"] = object{Data: map[string][]byte{"secret": []byte("s3cr3t")}}
+		objs[ns+"/app"] = object{
+			Metadata: objectMeta{Name: "app", Namespace: ns},
+			Spec: json.RawMessage(fmt.Sprintf(`{"accessKey":"AKIA%s","secretKeyRef":{"name":"keys","key":"secret"},`+
+				`"clientId":"app","tenantId":"tenant-001","policies":["read"]}`, strings.ToUpper(ns))),
+		}
+	}
+
+	controller := NewController(nil, &config.KubernetesConfig{
+		TenantNamespaces: map[string][]string{"tenant-001": {"tenant-001"}},
+	}, nil, nil)
+	cfg, _ := controller.credentialsFromObjects(objs, secrets)
+	if len(cfg.Credentials) != 1 || cfg.Credentials[0].AccessKey != "AKIATENANT-001" {
+		t.Fatalf("credentials = %+v, want only the tenant's own namespace", cfg.Credentials)
+	}
+	if policies := cfg.Credentials[0].Policies; len(policies) != 1 || policies[0] != "tenant-001/read" {
+		t.Errorf("policies = %v, want [tenant-001/read]", policies)
+	}
+}
+
+func TestClient_RereadsTokenFile(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("first\n"), 0600)
+	client, err := NewTokenFileClient(server.URL, tokenFile, server.Client())
+	if err != nil {
+		t.Fatalf("NewTokenFileClient: %v", err)
+	}
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	os.WriteFile(tokenFile, []byte("second\n"), 0600)
+	client.list(context.Background(), "/a") // cached token still fresh
+	now = now.Add(tokenRefreshInterval)
+	client.list(context.Background(), "/b")
+	os.Remove(tokenFile)
+	now = now.Add(tokenRefreshInterval)
+	client.list(context.Background(), "/c") // unreadable file keeps the last token
+
+	want := []string{"Bearer first", "Bearer second", "Bearer second"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Authorization headers = %q, want %q", got, want)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
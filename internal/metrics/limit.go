@@ -0,0 +1,38 @@
+package metrics
+
+import "sync"
+
+// OverflowValue replaces label values beyond a LabelLimit
+const OverflowValue = "other"
+
+// LabelLimit bounds the distinct values of a label so a metric cannot grow
+// without limit: the first values seen are kept, later ones are reported as
+// OverflowValue. A zero limit keeps every value.
+type LabelLimit struct {
+	limit int
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// NewLabelLimit creates a limit of n distinct values
+func NewLabelLimit(n int) *LabelLimit {
+	return &LabelLimit{limit: n, seen: make(map[string]struct{})}
+}
+
+// Value returns v if it is within the limit, or OverflowValue
+func (l *LabelLimit) Value(v string) string {
+	if l == nil || l.limit == 0 {
+		return v
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[v]; ok {
+		return v
+	}
+	if len(l.seen) >= l.limit {
+		return OverflowValue
+	}
+	l.seen[v] = struct{}{}
+	return v
+}
@@ -0,0 +1,316 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kind is the Prometheus metric type
+type Kind string
+
+const (
+	KindCounter Kind = "counter"
+	KindGauge   Kind = "gauge"
+)
+
+// Registry holds metrics and renders them in the Prometheus text format
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]collector
+}
+
+// collector is implemented by every metric type stored in a Registry
+type collector interface {
+	write(w io.Writer)
+	gather() []Sample
+}
+
+// Sample is the value of one series when it was gathered
+type Sample struct {
+	Name   string
+	Kind   Kind
+	Labels map[string]string
+	Value  float64
+}
+
+// Default is the process-wide registry used by the package-level constructors
+var Default = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]collector)}
+}
+
+// register adds a collector, panicking on duplicate names like other metric libraries
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", name))
+	}
+	r.metrics[name] = c
+}
+
+// Write renders all metrics sorted by name
+func (r *Registry) Write(w io.Writer) {
+	for _, c := range r.sorted() {
+		c.write(w)
+	}
+}
+
+// Gather returns every series, for exporters other than the Prometheus
+// endpoint, sorted by metric name
+func (r *Registry) Gather() []Sample {
+	var samples []Sample
+	for _, c := range r.sorted() {
+		samples = append(samples, c.gather()...)
+	}
+	return samples
+}
+
+// sorted returns the collectors sorted by name
+func (r *Registry) sorted() []collector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]collector, len(names))
+	for i, name := range names {
+		collectors[i] = r.metrics[name]
+	}
+	return collectors
+}
+
+// Handler returns an HTTP handler serving the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.Write(w)
+	})
+}
+
+// vec is a set of float series keyed by label values
+type vec struct {
+	name   string
+	help   string
+	kind   Kind
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+func newVec(name, help string, kind Kind, labels []string) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*series),
+	}
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	v.mu.Lock()
+	v.get(labelValues).value += delta
+	v.mu.Unlock()
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	v.mu.Lock()
+	v.get(labelValues).value = value
+	v.mu.Unlock()
+}
+
+// get returns the series for labelValues, creating it if needed. Callers hold mu.
+func (v *vec) get(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *vec) value(labelValues []string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if s, ok := v.series[strings.Join(labelValues, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		s := v.series[k]
+		lines[i] = v.name + formatLabels(v.labels, s.labelValues) + " " + formatValue(s.value)
+	}
+	v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+func (v *vec) gather() []Sample {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	samples := make([]Sample, len(keys))
+	for i, k := range keys {
+		s := v.series[k]
+		labels := make(map[string]string, len(v.labels))
+		for j, name := range v.labels {
+			labels[name] = s.labelValues[j]
+		}
+		samples[i] = Sample{Name: v.name, Kind: v.kind, Labels: labels, Value: s.value}
+	}
+	return samples
+}
+
+// Counter is a monotonically increasing metric partitioned by labels
+type Counter struct{ v *vec }
+
+// NewCounter registers a counter in the default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter registers a counter in the registry
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{v: newVec(name, help, KindCounter, labels)}
+	r.register(name, c.v)
+	return c
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc(labelValues ...string) { c.v.add(1, labelValues) }
+
+// Add increments the counter by delta, which must not be negative
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.v.add(delta, labelValues)
+}
+
+// Value returns the current value of a series
+func (c *Counter) Value(labelValues ...string) float64 { return c.v.value(labelValues) }
+
+// Gauge is a metric that can go up and down, partitioned by labels
+type Gauge struct{ v *vec }
+
+// NewGauge registers a gauge in the default registry
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// NewGauge registers a gauge in the registry
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{v: newVec(name, help, KindGauge, labels)}
+	r.register(name, g.v)
+	return g
+}
+
+// Set sets the gauge to value
+func (g *Gauge) Set(value float64, labelValues ...string) { g.v.set(value, labelValues) }
+
+// Inc increments the gauge by one
+func (g *Gauge) Inc(labelValues ...string) { g.v.add(1, labelValues) }
+
+// Dec decrements the gauge by one
+func (g *Gauge) Dec(labelValues ...string) { g.v.add(-1, labelValues) }
+
+// Value returns the current value of a series
+func (g *Gauge) Value(labelValues ...string) float64 { return g.v.value(labelValues) }
+
+// infoFunc is a gauge with a single series fixed at 1, whose label values
+// are read each time the registry is written
+type infoFunc struct {
+	name   string
+	help   string
+	labels []string
+	fn     func() []string
+}
+
+// NewInfoFunc registers an info metric in the default registry
+func NewInfoFunc(name, help string, labels []string, fn func() []string) {
+	Default.NewInfoFunc(name, help, labels, fn)
+}
+
+// NewInfoFunc registers an info metric whose label values fn returns when
+// the registry is written, so they follow state that changes at runtime
+func (r *Registry) NewInfoFunc(name, help string, labels []string, fn func() []string) {
+	r.register(name, &infoFunc{name: name, help: help, labels: labels, fn: fn})
+}
+
+func (f *infoFunc) current() *vec {
+	v := newVec(f.name, f.help, KindGauge, f.labels)
+	v.set(1, f.fn())
+	return v
+}
+
+func (f *infoFunc) write(w io.Writer) { f.current().write(w) }
+
+func (f *infoFunc) gather() []Sample { return f.current().gather() }
+
+// formatLabels renders {name="value",...} with Prometheus escaping
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_requests_total", "Requests", "action")
+
+	c.Inc("s3:GetObject")
+	c.Inc("s3:GetObject")
+	c.Add(3, "s3:PutObject")
+	c.Add(-1, "s3:PutObject") // ignored
+
+	if got := c.Value("s3:GetObject"); got != 2 {
+		t.Errorf("GetObject = %v, want 2", got)
+	}
+	if got := c.Value("s3:PutObject"); got != 3 {
+		t.Errorf("PutObject = %v, want 3", got)
+	}
+	if got := c.Value("s3:DeleteObject"); got != 0 {
+		t.Errorf("DeleteObject = %v, want 0", got)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("test_inflight", "In-flight")
+
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	if got := g.Value(); got != 1 {
+		t.Errorf("gauge = %v, want 1", got)
+	}
+
+	g.Set(42)
+	if got := g.Value(); got != 42 {
+		t.Errorf("gauge = %v, want 42", got)
+	}
+}
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("b_total", "B counter", "tenant")
+	g := r.NewGauge("a_value", "A gauge")
+
+	c.Inc(`tenant-"x"`)
+	g.Set(1.5)
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+
+	want := `# HELP a_value A gauge
+# TYPE a_value gauge
+a_value 1.5
+# HELP b_total B counter
+# TYPE b_total counter
+b_total{tenant="tenant-\"x\""} 1
+`
+	if buf.String() != want {
+		t.Errorf("output mismatch:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestRegistry_Gather(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("b_total", "B counter", "tenant")
+	g := r.NewGauge("a_value", "A gauge")
+
+	c.Inc("t2")
+	c.Add(2, "t1")
+	g.Set(1.5)
+
+	want := []Sample{
+		{Name: "a_value", Kind: KindGauge, Labels: map[string]string{}, Value: 1.5},
+		{Name: "b_total", Kind: KindCounter, Labels: map[string]string{"tenant": "t1"}, Value: 2},
+		{Name: "b_total", Kind: KindCounter, Labels: map[string]string{"tenant": "t2"}, Value: 1},
+	}
+	if got := r.Gather(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Gather() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("c_total", "C").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "c_total 1") {
+		t.Errorf("expected c_total in output, got %q", rec.Body.String())
+	}
+}
+
+func TestRegistry_DuplicatePanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("dup_total", "dup")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	r.NewGauge("dup_total", "dup")
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("labels_total", "labels", "a", "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on label count mismatch")
+		}
+	}()
+	c.Inc("only-one")
+}
+
+func TestLabelLimit(t *testing.T) {
+	l := NewLabelLimit(2)
+	got := []string{l.Value("a"), l.Value("b"), l.Value("c"), l.Value("a")}
+	want := []string{"a", "b", OverflowValue, "a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+	if v := NewLabelLimit(0).Value("c"); v != "c" {
+		t.Errorf("unlimited value = %q", v)
+	}
+}
+
+func TestRegistry_InfoFunc(t *testing.T) {
+	r := NewRegistry()
+	version := "v1"
+	r.NewInfoFunc("test_info", "Info", []string{"version"}, func() []string { return []string{version} })
+
+	version = "v2"
+	var buf bytes.Buffer
+	r.Write(&buf)
+	want := `# HELP test_info Info
+# TYPE test_info gauge
+test_info{version="v2"} 1
+`
+	if buf.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	samples := r.Gather()
+	if len(samples) != 1 || samples[0].Labels["version"] != "v2" || samples[0].Value != 1 {
+		t.Errorf("samples = %+v", samples)
+	}
+}
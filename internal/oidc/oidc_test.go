@@ -0,0 +1,62 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/oidc"
+	"github.com/s3-access-control-adapter/internal/oidc/oidctest"
+)
+
+func TestVerifier(t *testing.T) {
+	iss := oidctest.NewIssuer(t)
+	other := oidctest.NewIssuer(t)
+	v := oidc.NewVerifier(iss.URL, "s3-gateway", "", nil)
+	ctx := context.Background()
+
+	claims, err := v.Verify(ctx, iss.Token(t, "s3-gateway", map[string]interface{}{
+		"repository": "myorg/app",
+		"run_id":     12345,
+	}))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.String("repository") != "myorg/app" || claims.String("run_id") != "12345" {
+		t.Errorf("claims = %v", claims)
+	}
+
+	tampered := iss.Token(t, "s3-gateway", nil)
+	parts := strings.Split(tampered, ".")
+	parts[1] = strings.Split(iss.Token(t, "s3-gateway", map[string]interface{}{"sub": "admin"}), ".")[1]
+
+	for name, token := range map[string]string{
+		"wrong audience":   iss.Token(t, "other", nil),
+		"audience list":    iss.Token(t, "other", map[string]interface{}{"aud": []string{"a", "b"}}),
+		"expired":          iss.Token(t, "s3-gateway", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"not yet valid":    iss.Token(t, "s3-gateway", map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()}),
+		"wrong issuer":     iss.Token(t, "s3-gateway", map[string]interface{}{"iss": "https://evil.example"}),
+		"other issuer key": other.Token(t, "s3-gateway", map[string]interface{}{"iss": iss.URL}),
+		"tampered":         strings.Join(parts, "."),
+		"garbage":          "not-a-token",
+	} {
+		if _, err := v.Verify(ctx, token); !errors.Is(err, oidc.ErrInvalidToken) {
+			t.Errorf("%s: Verify() error = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	if _, err := v.Verify(ctx, iss.Token(t, "s3-gateway", map[string]interface{}{"aud": []string{"x", "s3-gateway"}})); err != nil {
+		t.Errorf("audience list: Verify() error = %v", err)
+	}
+}
+
+func TestVerifier_UnreachableIssuer(t *testing.T) {
+	iss := oidctest.NewIssuer(t)
+	token := iss.Token(t, "s3-gateway", nil)
+	v := oidc.NewVerifier("http://127.0.0.1:1", "s3-gateway", "", nil)
+	if _, err := v.Verify(context.Background(), token); err == nil || errors.Is(err, oidc.ErrInvalidToken) {
+		t.Errorf("Verify() error = %v, want a fetch error", err)
+	}
+}
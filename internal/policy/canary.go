@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"log"
+	"math/rand"
+	"slices"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var canaryDiscrepancies = metrics.NewCounter("gateway_policy_canary_discrepancies_total",
+	"Requests the primary and canary policy engines decided differently, by which one decided", "decider")
+
+// Deciders of a canary evaluation
+const (
+	DeciderPrimary = "primary"
+	DeciderCanary  = "canary"
+)
+
+// Discrepancy is a request the primary and canary engines decided differently
+type Discrepancy struct {
+	Ctx     *EvalContext
+	Primary *Decision
+	Canary  *Decision
+	Decider string // which engine's decision was enforced
+}
+
+// CanaryEngine evaluates every request with two engines, e.g. the current
+// policies and a new version of them, to migrate between them safely. The
+// canary decides a percentage of requests and the primary the rest; either
+// way requests they decide differently are reported.
+type CanaryEngine struct {
+	primary Engine
+	canary  Engine
+	percent float64
+
+	// sample returns a number in [0, 100); a request is decided by the
+	// canary when it is below percent
+	sample func() float64
+	// report is called for every discrepancy
+	report func(Discrepancy)
+}
+
+// NewCanaryEngine creates an engine letting canary decide percent of requests
+func NewCanaryEngine(primary, canary Engine, percent float64) *CanaryEngine {
+	return &CanaryEngine{
+		primary: primary,
+		canary:  canary,
+		percent: percent,
+		sample:  func() float64 { return rand.Float64() * 100 },
+		report:  LogDiscrepancy,
+	}
+}
+
+// OnDiscrepancy replaces logging of discrepancies with fn
+func (e *CanaryEngine) OnDiscrepancy(fn func(Discrepancy)) {
+	e.report = fn
+}
+
+// Evaluate evaluates both engines and returns the decision of the one
+// chosen for the request
+func (e *CanaryEngine) Evaluate(ctx *EvalContext, policyNames []string) *Decision {
+	primary := e.primary.Evaluate(ctx, policyNames)
+	canary := e.canary.Evaluate(ctx, policyNames)
+
+	decider, decision := DeciderPrimary, primary
+	if e.sample() < e.percent {
+		decider, decision = DeciderCanary, canary
+	}
+	if primary.Allowed != canary.Allowed || primary.DenyReason != canary.DenyReason {
+		canaryDiscrepancies.Inc(decider)
+		e.report(Discrepancy{Ctx: ctx, Primary: primary, Canary: canary, Decider: decider})
+	}
+	return decision
+}
+
+// Reload reloads both engines
+func (e *CanaryEngine) Reload() error {
+	if err := e.primary.Reload(); err != nil {
+		return err
+	}
+	return e.canary.Reload()
+}
+
+// GetPolicy retrieves a policy by name from the primary engine, or from the
+// canary for policies only it has
+func (e *CanaryEngine) GetPolicy(name string) (*Policy, bool) {
+	if p, ok := e.primary.GetPolicy(name); ok {
+		return p, true
+	}
+	return e.canary.GetPolicy(name)
+}
+
+// BucketPolicies returns the bucket policies of both engines
+func (e *CanaryEngine) BucketPolicies() []*Policy {
+	var policies []*Policy
+	for _, engine := range []Engine{e.primary, e.canary} {
+		if b, ok := engine.(interface{ BucketPolicies() []*Policy }); ok {
+			policies = append(policies, b.BucketPolicies()...)
+		}
+	}
+	return policies
+}
+
+// Fingerprint identifies the policies of both engines
+func (e *CanaryEngine) Fingerprint() string {
+	var versions []string
+	for _, engine := range []Engine{e.primary, e.canary} {
+		f, ok := engine.(interface{ Fingerprint() string })
+		if !ok {
+			return ""
+		}
+		versions = append(versions, f.Fingerprint())
+	}
+	return config.Fingerprint(versions)
+}
+
+// conditionKeys returns the condition keys either engine's version of the
+// named policies references
+func (e *CanaryEngine) conditionKeys(policyNames []string, prefix string) []string {
+	keys := ConditionKeys(e.primary, policyNames, prefix)
+	for _, key := range ConditionKeys(e.canary, policyNames, prefix) {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// LogDiscrepancy logs a discrepancy, as canary engines do by default
+func LogDiscrepancy(d Discrepancy) {
+	log.Printf("Policy canary discrepancy (%s decided): client=%s action=%s resource=%s primary=%s canary=%s",
+		d.Decider, d.Ctx.ClientID, d.Ctx.Action, d.Ctx.Resource, describeDecision(d.Primary), describeDecision(d.Canary))
+}
+
+// describeDecision renders a decision for logs, e.g. "deny:DENY_POLICY(app/DenyAll)"
+func describeDecision(d *Decision) string {
+	s := "allow"
+	if !d.Allowed {
+		s = "deny:" + string(d.DenyReason)
+	}
+	if d.MatchedPolicy != "" {
+		s += "(" + d.MatchedPolicy + "/" + d.MatchedStatement + ")"
+	}
+	return s
+}
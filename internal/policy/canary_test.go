@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestCanaryEngine(t *testing.T) {
+	allowAll := func(actions ...string) *config.PoliciesConfig {
+		return &config.PoliciesConfig{Policies: []config.Policy{{
+			Name: "app",
+			Statements: []config.Statement{{
+				Sid:       "Allow",
+				Effect:    config.EffectAllow,
+				Actions:   actions,
+				Resources: []string{"arn:aws:s3:::data/*"},
+				Conditions: map[string]map[string]config.ConditionValues{
+					"StringEquals": {"header:x-team": {"data"}},
+				},
+			}},
+		}}}
+	}
+	primary, err := NewEngineFromConfig(allowAll("s3:GetObject", "s3:PutObject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canary, err := NewEngineFromConfig(allowAll("s3:GetObject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewCanaryEngine(primary, canary, 25)
+	var discrepancies []Discrepancy
+	engine.OnDiscrepancy(func(d Discrepancy) { discrepancies = append(discrepancies, d) })
+	put := &EvalContext{ClientID: "app", Action: "s3:PutObject", Resource: "arn:aws:s3:::data/a",
+		Conditions: map[string]string{"header:x-team": "data"}}
+	get := &EvalContext{ClientID: "app", Action: "s3:GetObject", Resource: "arn:aws:s3:::data/a",
+		Conditions: map[string]string{"header:x-team": "data"}}
+
+	engine.sample = func() float64 { return 50 }
+	if d := engine.Evaluate(put, []string{"app"}); !d.Allowed {
+		t.Error("primary should decide the put")
+	}
+	engine.sample = func() float64 { return 10 }
+	if d := engine.Evaluate(put, []string{"app"}); d.Allowed {
+		t.Error("canary should decide the put")
+	}
+	if d := engine.Evaluate(get, []string{"app"}); !d.Allowed {
+		t.Error("get should be allowed by both")
+	}
+
+	if len(discrepancies) != 2 {
+		t.Fatalf("discrepancies = %d, want 2", len(discrepancies))
+	}
+	if d := discrepancies[0]; d.Decider != DeciderPrimary || !d.Primary.Allowed || d.Canary.Allowed {
+		t.Errorf("first discrepancy = %+v", d)
+	}
+	if d := discrepancies[1]; d.Decider != DeciderCanary {
+		t.Errorf("second discrepancy decided by %s, want canary", d.Decider)
+	}
+
+	if keys := ConditionKeys(engine, []string{"app"}, HeaderPrefix); len(keys) != 1 || keys[0] != "header:x-team" {
+		t.Errorf("condition keys = %v", keys)
+	}
+	if engine.Fingerprint() == "" || engine.Fingerprint() == primary.Fingerprint() {
+		t.Errorf("fingerprint = %q", engine.Fingerprint())
+	}
+}
@@ -2,6 +2,11 @@ package policy
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/s3-access-control-adapter/internal/config"
@@ -20,9 +25,11 @@ type Engine interface {
 
 // DefaultEngine implements the policy evaluation engine
 type DefaultEngine struct {
-	mu         sync.RWMutex
-	policies   map[string]*Policy
-	configPath string
+	mu             sync.RWMutex
+	policies       map[string]*Policy
+	bucketPolicies []*Policy
+	fingerprint    string
+	configPath     string
 }
 
 // NewEngine creates a new policy engine
@@ -39,41 +46,91 @@ func NewEngine(configPath string) (*DefaultEngine, error) {
 	return engine, nil
 }
 
+// NewEngineFromConfig creates a policy engine from an in-memory
+// configuration, for policies that do not come from a file
+func NewEngineFromConfig(cfg *config.PoliciesConfig) (*DefaultEngine, error) {
+	engine := &DefaultEngine{policies: make(map[string]*Policy)}
+	if err := engine.Load(cfg); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
 // Reload reloads policies from the configuration file
 func (e *DefaultEngine) Reload() error {
+	if e.configPath == "" {
+		return nil
+	}
+
 	cfg, err := config.LoadPolicies(e.configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load policies: %w", err)
 	}
+	return e.Load(cfg)
+}
+
+// Load validates and atomically replaces the loaded policies
+func (e *DefaultEngine) Load(cfg *config.PoliciesConfig) error {
+	if err := config.ValidatePolicies(cfg); err != nil {
+		return err
+	}
 
 	newPolicies := make(map[string]*Policy, len(cfg.Policies))
 	for _, p := range cfg.Policies {
-		policy := &Policy{
+		statements := convertStatements(p.Statements)
+		newPolicies[p.Name] = &Policy{
 			Name:       p.Name,
 			Version:    p.Version,
-			Statements: make([]Statement, len(p.Statements)),
+			Statements: statements,
+			index:      newStatementIndex(statements),
 		}
-
-		for i, s := range p.Statements {
-			policy.Statements[i] = Statement{
-				Sid:        s.Sid,
-				Effect:     Effect(s.Effect),
-				Actions:    s.Actions,
-				Resources:  s.Resources,
-				Conditions: s.Conditions,
-			}
-		}
-
-		newPolicies[p.Name] = policy
+	}
+	bucketPolicies := make([]*Policy, 0, len(cfg.BucketPolicies))
+	for _, p := range cfg.BucketPolicies {
+		statements := convertStatements(p.Statements)
+		bucketPolicies = append(bucketPolicies, &Policy{
+			Name:       p.Name,
+			Statements: statements,
+			Buckets:    p.Buckets,
+			index:      newStatementIndex(statements),
+		})
 	}
 
+	fingerprint := config.Fingerprint(cfg)
+
 	e.mu.Lock()
 	e.policies = newPolicies
+	e.bucketPolicies = bucketPolicies
+	e.fingerprint = fingerprint
 	e.mu.Unlock()
 
 	return nil
 }
 
+// Fingerprint identifies the loaded policies; it is empty before the first
+// load
+func (e *DefaultEngine) Fingerprint() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.fingerprint
+}
+
+func convertStatements(statements []config.Statement) []Statement {
+	converted := make([]Statement, len(statements))
+	for i, s := range statements {
+		converted[i] = Statement{
+			Sid:        s.Sid,
+			Effect:     Effect(s.Effect),
+			Actions:    s.Actions,
+			Resources:  s.Resources,
+			Principals: s.Principals,
+			Conditions: s.Conditions,
+			RateLimit:  s.RateLimit,
+		}
+	}
+	return converted
+}
+
 // GetPolicy retrieves a policy by name
 func (e *DefaultEngine) GetPolicy(name string) (*Policy, bool) {
 	e.mu.RLock()
@@ -83,24 +140,53 @@ func (e *DefaultEngine) GetPolicy(name string) (*Policy, bool) {
 	return policy, ok
 }
 
+// Policies returns all loaded policies sorted by name
+func (e *DefaultEngine) Policies() []*Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	policies := make([]*Policy, 0, len(e.policies))
+	for _, p := range e.policies {
+		policies = append(policies, p)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	return policies
+}
+
+// BucketPolicies returns all loaded bucket policies in configuration order
+func (e *DefaultEngine) BucketPolicies() []*Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.bucketPolicies
+}
+
 // Evaluate evaluates policies for a request
 // It implements AWS IAM evaluation logic:
 // 1. Default deny
 // 2. Explicit deny takes precedence over any allow
 // 3. If there's an explicit allow and no explicit deny, allow
+// The named identity policies are evaluated together with the bucket
+// policies of the request's bucket; an allow from either is enough.
 func (e *DefaultEngine) Evaluate(ctx *EvalContext, policyNames []string) *Decision {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	var allowDecision *Decision
-
-	// Evaluate each policy
+	policies := make([]*Policy, 0, len(policyNames))
 	for _, policyName := range policyNames {
-		policy, ok := e.policies[policyName]
-		if !ok {
-			continue // Policy not found, skip
+		if policy, ok := e.policies[policyName]; ok {
+			policies = append(policies, policy)
+		}
+	}
+	for _, policy := range e.bucketPolicies {
+		if ctx.Bucket != "" && MatchScope(ctx.Bucket, policy.Buckets) {
+			policies = append(policies, policy)
 		}
+	}
+
+	var allowDecision *Decision
 
+	// Evaluate each policy
+	for _, policy := range policies {
 		decision := e.evaluatePolicy(ctx, policy)
 
 		// Explicit deny takes immediate precedence
@@ -108,9 +194,13 @@ func (e *DefaultEngine) Evaluate(ctx *EvalContext, policyNames []string) *Decisi
 			return decision
 		}
 
-		// Track the first allow
-		if decision != nil && decision.Allowed && allowDecision == nil {
-			allowDecision = decision
+		// Track the first allow; every matching rate limit applies
+		if decision != nil && decision.Allowed {
+			if allowDecision == nil {
+				allowDecision = decision
+			} else {
+				allowDecision.RateLimits = append(allowDecision.RateLimits, decision.RateLimits...)
+			}
 		}
 	}
 
@@ -123,23 +213,130 @@ func (e *DefaultEngine) Evaluate(ctx *EvalContext, policyNames []string) *Decisi
 	return DefaultDenyDecision()
 }
 
+// AllowedActionsPolicy is the matched policy of decisions granted by a
+// credential's allowedActions
+const AllowedActionsPolicy = "allowedActions"
+
+// EvaluateCredential checks a credential's allowedActions before its
+// policies. A listed action is allowed unless one of the policies explicitly
+// denies it; other actions are decided by the policies alone.
+func EvaluateCredential(e Engine, ctx *EvalContext, allowedActions, policyNames []string) *Decision {
+	if !MatchAction(ctx.Action, allowedActions) {
+		return e.Evaluate(ctx, policyNames)
+	}
+	// Only an explicit deny, from the policies or a bucket policy, names the
+	// policy that decided
+	decision := e.Evaluate(ctx, policyNames)
+	if !decision.Allowed && decision.MatchedPolicy != "" {
+		return decision
+	}
+	allow := NewAllowDecision(AllowedActionsPolicy, "")
+	if decision.Allowed {
+		allow.RateLimits = decision.RateLimits
+	}
+	return allow
+}
+
+// Condition key prefixes for bucket and object tags, e.g. s3:ResourceTag/team
+const (
+	ResourceTagPrefix       = "s3:ResourceTag/"
+	ExistingObjectTagPrefix = "s3:ExistingObjectTag/"
+	RequestObjectTagPrefix  = "s3:RequestObjectTag/"
+)
+
+// Condition keys for where a request is forwarded
+const (
+	RequestedRegionKey = "aws:RequestedRegion"
+	EndpointKey        = "gateway:Endpoint"
+)
+
+// AliasKey is the alias a request was addressed to; unset for requests
+// addressed to the bucket itself
+const AliasKey = "gateway:Alias"
+
+// Condition keys describing the object key of an upload, for naming rules
+const (
+	ObjectKeyKey       = "gateway:ObjectKey"
+	ObjectKeyLengthKey = "gateway:ObjectKeyLength" // in bytes
+	ObjectKeyDepthKey  = "gateway:ObjectKeyDepth"  // number of "/"-separated segments
+)
+
+// KeyNamingKeys are the condition keys whose Deny statements are reported
+// as naming rule violations
+var KeyNamingKeys = []string{ObjectKeyKey, ObjectKeyLengthKey, ObjectKeyDepthKey}
+
+// HeaderPrefix is the condition key prefix for request headers, e.g.
+// header:x-client-build
+const HeaderPrefix = "header:"
+
+// RequestObjectTagKeys is the multi-valued condition key holding the tag
+// keys of an upload
+const RequestObjectTagKeys = "s3:RequestObjectTagKeys"
+
+// ReferencesConditionKey reports whether a statement of the named policies
+// has a condition on a key starting with prefix, so callers only gather
+// costly condition values when they are used
+func ReferencesConditionKey(e Engine, policyNames []string, prefix string) bool {
+	return len(ConditionKeys(e, policyNames, prefix)) > 0
+}
+
+// ConditionKeys returns the condition keys starting with prefix that
+// statements of the named policies, or of any bucket policy, reference
+func ConditionKeys(e Engine, policyNames []string, prefix string) []string {
+	if c, ok := e.(*CanaryEngine); ok {
+		return c.conditionKeys(policyNames, prefix)
+	}
+	var policies []*Policy
+	for _, name := range policyNames {
+		if p, ok := e.GetPolicy(name); ok {
+			policies = append(policies, p)
+		}
+	}
+	if b, ok := e.(interface{ BucketPolicies() []*Policy }); ok {
+		policies = append(policies, b.BucketPolicies()...)
+	}
+
+	var keys []string
+	for _, p := range policies {
+		for _, stmt := range p.Statements {
+			for _, block := range stmt.Conditions {
+				for key := range block {
+					if strings.HasPrefix(key, prefix) && !slices.Contains(keys, key) {
+						keys = append(keys, key)
+					}
+				}
+			}
+		}
+	}
+	return keys
+}
+
 // evaluatePolicy evaluates a single policy
 func (e *DefaultEngine) evaluatePolicy(ctx *EvalContext, policy *Policy) *Decision {
 	var allowDecision *Decision
 
-	for _, stmt := range policy.Statements {
-		if !e.statementMatches(ctx, &stmt) {
+	for _, i := range policy.candidates(ctx) {
+		stmt := &policy.Statements[i]
+		if !e.statementMatches(ctx, stmt) {
 			continue
 		}
 
 		if stmt.Effect == EffectDeny {
 			// Explicit deny
-			return NewDenyDecision(errors.DenyPolicy, policy.Name, stmt.Sid)
+			reason := errors.DenyPolicy
+			if referencesAny(stmt.Conditions, KeyNamingKeys) {
+				reason = errors.DenyKeyNaming
+			}
+			return NewDenyDecision(reason, policy.Name, stmt.Sid)
 		}
 
 		if stmt.Effect == EffectAllow && allowDecision == nil {
 			allowDecision = NewAllowDecision(policy.Name, stmt.Sid)
 		}
+		if stmt.Effect == EffectAllow && stmt.RateLimit != nil {
+			allowDecision.RateLimits = append(allowDecision.RateLimits,
+				AppliedRateLimit{Policy: policy.Name, Statement: i, RateLimit: *stmt.RateLimit})
+		}
 	}
 
 	return allowDecision
@@ -157,6 +354,11 @@ func (e *DefaultEngine) statementMatches(ctx *EvalContext, stmt *Statement) bool
 		return false
 	}
 
+	// Bucket policy statements apply to their principals only
+	if len(stmt.Principals) > 0 && !matchPrincipal(ctx, stmt.Principals) {
+		return false
+	}
+
 	// Check conditions if present
 	if len(stmt.Conditions) > 0 {
 		if !e.evaluateConditions(ctx, stmt.Conditions) {
@@ -167,36 +369,140 @@ func (e *DefaultEngine) statementMatches(ctx *EvalContext, stmt *Statement) bool
 	return true
 }
 
-// evaluateConditions evaluates condition blocks
-func (e *DefaultEngine) evaluateConditions(ctx *EvalContext, conditions map[string]map[string]string) bool {
+// matchPrincipal reports whether the requesting client is one of principals:
+// its client ID, "tenant:<id>" of its tenant, or "*"
+func matchPrincipal(ctx *EvalContext, principals []string) bool {
+	for _, p := range principals {
+		if p == "*" || p == ctx.ClientID || p == "tenant:"+ctx.TenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateConditions evaluates condition blocks; every key of every block
+// must match
+func (e *DefaultEngine) evaluateConditions(ctx *EvalContext, conditions map[string]map[string]config.ConditionValues) bool {
 	for operator, conditionBlock := range conditions {
-		for key, expectedValue := range conditionBlock {
-			actualValue, ok := ctx.Conditions[key]
-			if !ok {
+		qualifier, op, _ := strings.Cut(operator, ":")
+		if op == "" {
+			qualifier, op = "", operator
+		}
+		for key, expected := range conditionBlock {
+			expected = substituteVariables(ctx, expected, strings.HasSuffix(op, "MatchesRegex"))
+			actual, ok := ctx.Values(key)
+			switch qualifier {
+			case "":
+				// A missing key matches no operator, negated ones included;
+				// multi-valued keys need a qualifier
+				if !ok || len(actual) != 1 || !evaluateCondition(op, actual[0], expected) {
+					return false
+				}
+			case "ForAllValues":
+				// Every request value must match; true when there are none
+				for _, v := range actual {
+					if !evaluateCondition(op, v, expected) {
+						return false
+					}
+				}
+			case "ForAnyValue":
+				if !slices.ContainsFunc(actual, func(v string) bool { return evaluateCondition(op, v, expected) }) {
+					return false
+				}
+			default:
+				// Unsupported qualifier, fail closed
 				return false
 			}
+		}
+	}
+	return true
+}
 
-			if !evaluateCondition(operator, actualValue, expectedValue) {
-				return false
+// substituteVariables replaces policy variables in condition values with the
+// request's tenant and client IDs, quoted for regex operators
+func substituteVariables(ctx *EvalContext, values []string, regex bool) []string {
+	if !slices.ContainsFunc(values, func(v string) bool { return strings.Contains(v, "${") }) {
+		return values
+	}
+	tenantID, clientID := ctx.TenantID, ctx.ClientID
+	if regex {
+		tenantID, clientID = regexp.QuoteMeta(tenantID), regexp.QuoteMeta(clientID)
+	}
+	r := strings.NewReplacer(config.TenantIDVariable, tenantID, config.ClientIDVariable, clientID)
+	substituted := make([]string, len(values))
+	for i, v := range values {
+		substituted[i] = r.Replace(v)
+	}
+	return substituted
+}
+
+// referencesAny reports whether conditions use any of keys
+func referencesAny(conditions map[string]map[string]config.ConditionValues, keys []string) bool {
+	for _, block := range conditions {
+		for key := range block {
+			if slices.Contains(keys, key) {
+				return true
 			}
 		}
 	}
-	return true
+	return false
 }
 
-// evaluateCondition evaluates a single condition
-func evaluateCondition(operator, actual, expected string) bool {
+// evaluateCondition compares a request value with a condition's values. A
+// positive operator matches when any value does; a negated one when none do.
+func evaluateCondition(operator, actual string, expected []string) bool {
 	switch operator {
 	case "StringEquals":
-		return actual == expected
+		return slices.Contains(expected, actual)
 	case "StringNotEquals":
-		return actual != expected
+		return !slices.Contains(expected, actual)
 	case "StringLike":
-		return MatchAction(actual, []string{expected})
+		return MatchAction(actual, expected)
 	case "StringNotLike":
-		return !MatchAction(actual, []string{expected})
+		return !MatchAction(actual, expected)
+	case "StringMatchesRegex":
+		return slices.ContainsFunc(expected, func(p string) bool { return matchRegex(actual, p) })
+	case "StringNotMatchesRegex":
+		return !slices.ContainsFunc(expected, func(p string) bool { return matchRegex(actual, p) })
+	case "NumericEquals", "NumericNotEquals", "NumericLessThan", "NumericLessThanEquals",
+		"NumericGreaterThan", "NumericGreaterThanEquals":
+		return evaluateNumeric(operator, actual, expected)
 	default:
 		// Unsupported operator, fail closed
 		return false
 	}
 }
+
+// evaluateNumeric compares numbers; values that do not parse match nothing
+func evaluateNumeric(operator, actual string, expected []string) bool {
+	a, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return false
+	}
+	if operator == "NumericNotEquals" {
+		return !evaluateNumeric("NumericEquals", actual, expected)
+	}
+	for _, v := range expected {
+		e, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		var match bool
+		switch operator {
+		case "NumericEquals":
+			match = a == e
+		case "NumericLessThan":
+			match = a < e
+		case "NumericLessThanEquals":
+			match = a <= e
+		case "NumericGreaterThan":
+			match = a > e
+		case "NumericGreaterThanEquals":
+			match = a >= e
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,13 @@
 package policy
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/s3-access-control-adapter/internal/config"
 	"github.com/s3-access-control-adapter/internal/errors"
 )
 
@@ -237,3 +240,294 @@ policies:
 		})
 	}
 }
+
+func TestEvaluateCredential(t *testing.T) {
+	engine, err := NewEngineFromConfig(&config.PoliciesConfig{Policies: []config.Policy{{
+		Name: "deny-secrets",
+		Statements: []config.Statement{{
+			Sid:       "DenySecrets",
+			Effect:    config.EffectDeny,
+			Actions:   []string{"s3:*"},
+			Resources: []string{"arn:aws:s3:::bucket/secrets/*"},
+		}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly := []string{"s3:GetObject", "s3:ListBucket"}
+
+	tests := []struct {
+		name     string
+		action   string
+		resource string
+		policies []string
+		allowed  bool
+		policy   string
+	}{
+		{"listed action", "s3:GetObject", "arn:aws:s3:::bucket/key", nil, true, AllowedActionsPolicy},
+		{"unlisted action", "s3:PutObject", "arn:aws:s3:::bucket/key", nil, false, ""},
+		{"listed action with policies", "s3:GetObject", "arn:aws:s3:::bucket/key", []string{"deny-secrets"}, true, AllowedActionsPolicy},
+		{"explicit deny wins", "s3:GetObject", "arn:aws:s3:::bucket/secrets/key", []string{"deny-secrets"}, false, "deny-secrets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &EvalContext{Action: tt.action, Resource: tt.resource}
+			d := EvaluateCredential(engine, ctx, readOnly, tt.policies)
+			if d.Allowed != tt.allowed || d.MatchedPolicy != tt.policy {
+				t.Errorf("decision = %+v, want allowed=%v policy=%q", d, tt.allowed, tt.policy)
+			}
+		})
+	}
+}
+
+func TestPolicyEngine_MultiValueConditions(t *testing.T) {
+	tmpDir := t.TempDir()
+	policyFile := filepath.Join(tmpDir, "policies.yaml")
+	policyContent := `
+policies:
+  - name: test-policy
+    statements:
+      - sid: AllowTeams
+        effect: Allow
+        actions: [s3:GetObject]
+        resources: ["arn:aws:s3:::*/*"]
+        conditions:
+          StringEquals:
+            s3:ResourceTag/team: [analytics, finance]
+      - sid: AllowAllowedTagKeys
+        effect: Allow
+        actions: [s3:PutObject]
+        resources: ["arn:aws:s3:::*/*"]
+        conditions:
+          ForAllValues:StringEquals:
+            s3:RequestObjectTagKeys: [team, project]
+      - sid: DenyRestrictedTags
+        effect: Deny
+        actions: [s3:PutObject]
+        resources: ["arn:aws:s3:::*/*"]
+        conditions:
+          ForAnyValue:StringLike:
+            s3:RequestObjectTagKeys: "restricted-*"
+      - sid: DenyOtherCountries
+        effect: Deny
+        actions: [s3:GetObject]
+        resources: ["arn:aws:s3:::*/*"]
+        conditions:
+          StringNotEquals:
+            aws:SourceCountry: [DE, FR]
+`
+	os.WriteFile(policyFile, []byte(policyContent), 0644)
+
+	engine, err := NewEngine(policyFile)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		action      string
+		conditions  map[string]string
+		multiValues map[string][]string
+		allowed     bool
+	}{
+		{"any listed value", "s3:GetObject", map[string]string{"s3:ResourceTag/team": "finance", "aws:SourceCountry": "DE"}, nil, true},
+		{"unlisted value", "s3:GetObject", map[string]string{"s3:ResourceTag/team": "hr", "aws:SourceCountry": "DE"}, nil, false},
+		{"negated operator matches none", "s3:GetObject", map[string]string{"s3:ResourceTag/team": "analytics", "aws:SourceCountry": "US"}, nil, false},
+		{"negated operator on missing key", "s3:GetObject", map[string]string{"s3:ResourceTag/team": "analytics"}, nil, true},
+		{"all tag keys allowed", "s3:PutObject", nil, map[string][]string{"s3:RequestObjectTagKeys": {"project", "team"}}, true},
+		{"no tag keys", "s3:PutObject", nil, map[string][]string{"s3:RequestObjectTagKeys": {}}, true},
+		{"unlisted tag key", "s3:PutObject", nil, map[string][]string{"s3:RequestObjectTagKeys": {"team", "owner"}}, false},
+		{"restricted tag key", "s3:PutObject", nil, map[string][]string{"s3:RequestObjectTagKeys": {"team", "restricted-x"}}, false},
+	}
+	for _, tt := range tests {
+		conditions := tt.conditions
+		if conditions == nil {
+			conditions = map[string]string{}
+		}
+		ctx := &EvalContext{
+			Action:      tt.action,
+			Resource:    "arn:aws:s3:::bucket/key",
+			Conditions:  conditions,
+			MultiValues: tt.multiValues,
+		}
+		if got := engine.Evaluate(ctx, []string{"test-policy"}).Allowed; got != tt.allowed {
+			t.Errorf("%s: allowed = %v, want %v", tt.name, got, tt.allowed)
+		}
+	}
+}
+
+func TestPolicyEngine_BucketPolicies(t *testing.T) {
+	engine, err := NewEngineFromConfig(&config.PoliciesConfig{
+		Policies: []config.Policy{
+			{Name: "own-buckets", Statements: []config.Statement{{
+				Effect:    config.EffectAllow,
+				Actions:   []string{"s3:*"},
+				Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+			}}},
+		},
+		BucketPolicies: []config.BucketPolicy{
+			{Name: "shared-datasets", Buckets: []string{"shared-*"}, Statements: []config.Statement{
+				{
+					Sid:        "TenantReads",
+					Effect:     config.EffectAllow,
+					Actions:    []string{"s3:GetObject", "s3:ListBucket"},
+					Resources:  []string{"arn:aws:s3:::shared-*", "arn:aws:s3:::shared-*/*"},
+					Principals: []string{"tenant:tenant-001"},
+				},
+				{
+					Sid:        "ContractorWrites",
+					Effect:     config.EffectAllow,
+					Actions:    []string{"s3:PutObject"},
+					Resources:  []string{"arn:aws:s3:::shared-*/*"},
+					Principals: []string{"contractor"},
+				},
+			}},
+			{Name: "legal-hold", Buckets: []string{"tenant-001-legal"}, Statements: []config.Statement{{
+				Sid:        "NoDeletes",
+				Effect:     config.EffectDeny,
+				Actions:    []string{"s3:DeleteObject"},
+				Resources:  []string{"arn:aws:s3:::tenant-001-legal/*"},
+				Principals: []string{"*"},
+			}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		clientID string
+		tenantID string
+		policies []string
+		action   string
+		bucket   string
+		allowed  bool
+		matched  string
+	}{
+		{"identity policy", "app", "tenant-001", []string{"own-buckets"}, "s3:DeleteObject", "tenant-001-data", true, "own-buckets"},
+		{"bucket policy grants tenant", "app", "tenant-001", []string{"own-buckets"}, "s3:GetObject", "shared-data", true, "shared-datasets"},
+		{"bucket policy without identity policy", "contractor", "tenant-002", nil, "s3:PutObject", "shared-data", true, "shared-datasets"},
+		{"other principal", "app", "tenant-002", nil, "s3:GetObject", "shared-data", false, ""},
+		{"action not granted", "app", "tenant-001", []string{"own-buckets"}, "s3:DeleteObject", "shared-data", false, ""},
+		{"bucket policy deny wins", "app", "tenant-001", []string{"own-buckets"}, "s3:DeleteObject", "tenant-001-legal", false, "legal-hold"},
+	}
+	for _, tt := range tests {
+		ctx := &EvalContext{
+			ClientID:   tt.clientID,
+			TenantID:   tt.tenantID,
+			Action:     tt.action,
+			Resource:   BuildResourceARN(tt.bucket, "key"),
+			Bucket:     tt.bucket,
+			Key:        "key",
+			Conditions: map[string]string{},
+		}
+		decision := engine.Evaluate(ctx, tt.policies)
+		if decision.Allowed != tt.allowed || decision.MatchedPolicy != tt.matched {
+			t.Errorf("%s: got allowed=%v policy=%q, want allowed=%v policy=%q",
+				tt.name, decision.Allowed, decision.MatchedPolicy, tt.allowed, tt.matched)
+		}
+	}
+}
+
+func TestPolicyEngine_RateLimits(t *testing.T) {
+	deletes := &config.RateLimit{RequestsPerSecond: 10, Burst: 50}
+	writes := &config.RateLimit{RequestsPerSecond: 100}
+	engine, err := NewEngineFromConfig(&config.PoliciesConfig{Policies: []config.Policy{
+		{Name: "full", Statements: []config.Statement{
+			{Effect: config.EffectAllow, Actions: []string{"s3:*"}, Resources: []string{"arn:aws:s3:::data/*"}},
+			{Effect: config.EffectAllow, Actions: []string{"s3:DeleteObject"}, Resources: []string{"arn:aws:s3:::data/*"}, RateLimit: deletes},
+		}},
+		{Name: "writes", Statements: []config.Statement{
+			{Effect: config.EffectAllow, Actions: []string{"s3:PutObject", "s3:DeleteObject"}, Resources: []string{"arn:aws:s3:::data/*"}, RateLimit: writes},
+		}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		action string
+		want   []AppliedRateLimit
+	}{
+		{"s3:GetObject", nil},
+		{"s3:PutObject", []AppliedRateLimit{{Policy: "writes", Statement: 0, RateLimit: *writes}}},
+		{"s3:DeleteObject", []AppliedRateLimit{
+			{Policy: "full", Statement: 1, RateLimit: *deletes},
+			{Policy: "writes", Statement: 0, RateLimit: *writes},
+		}},
+	}
+	for _, tt := range tests {
+		ctx := &EvalContext{Action: tt.action, Resource: BuildResourceARN("data", "key"), Bucket: "data", Key: "key"}
+		decision := engine.Evaluate(ctx, []string{"full", "writes"})
+		if !decision.Allowed || fmt.Sprint(decision.RateLimits) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: allowed = %v, rate limits = %+v, want %+v", tt.action, decision.Allowed, decision.RateLimits, tt.want)
+		}
+	}
+}
+
+func TestPolicyEngine_KeyNamingRules(t *testing.T) {
+	engine, err := NewEngineFromConfig(&config.PoliciesConfig{Policies: []config.Policy{
+		{Name: "uploads", Statements: []config.Statement{
+			{Effect: config.EffectAllow, Actions: []string{"s3:PutObject"}, Resources: []string{"arn:aws:s3:::data/*"}},
+			{
+				Sid:       "TenantDatePartition",
+				Effect:    config.EffectDeny,
+				Actions:   []string{"s3:PutObject"},
+				Resources: []string{"arn:aws:s3:::data/*"},
+				Conditions: map[string]map[string]config.ConditionValues{
+					"StringNotMatchesRegex": {ObjectKeyKey: {`^${tenant:id}/\d{4}-\d{2}-\d{2}/`}},
+				},
+			},
+			{
+				Sid:       "KeyLimits",
+				Effect:    config.EffectDeny,
+				Actions:   []string{"s3:PutObject"},
+				Resources: []string{"arn:aws:s3:::data/*"},
+				Conditions: map[string]map[string]config.ConditionValues{
+					"NumericGreaterThan": {ObjectKeyLengthKey: {"64"}},
+				},
+			},
+			{
+				Sid:       "Shallow",
+				Effect:    config.EffectDeny,
+				Actions:   []string{"s3:PutObject"},
+				Resources: []string{"arn:aws:s3:::data/*"},
+				Conditions: map[string]map[string]config.ConditionValues{
+					"NumericGreaterThanEquals": {ObjectKeyDepthKey: {"5"}},
+				},
+			},
+		}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key    string
+		reason errors.DenyReason
+		sid    string
+	}{
+		{"t.1/2024-05-01/a.csv", "", ""},
+		{"t.1/latest/a.csv", errors.DenyKeyNaming, "TenantDatePartition"},
+		// The tenant ID is matched literally, not as a regex
+		{"tx1/2024-05-01/a.csv", errors.DenyKeyNaming, "TenantDatePartition"},
+		{"t.1/2024-05-01/" + strings.Repeat("x", 60), errors.DenyKeyNaming, "KeyLimits"},
+		{"t.1/2024-05-01/a/b/c.csv", errors.DenyKeyNaming, "Shallow"},
+	}
+	for _, tt := range tests {
+		key := tt.key
+		ctx := &EvalContext{
+			TenantID: "t.1", Action: "s3:PutObject", Resource: BuildResourceARN("data", key), Bucket: "data", Key: key,
+			Conditions: map[string]string{
+				ObjectKeyKey:       key,
+				ObjectKeyLengthKey: fmt.Sprint(len(key)),
+				ObjectKeyDepthKey:  fmt.Sprint(strings.Count(key, "/") + 1),
+			},
+		}
+		decision := engine.Evaluate(ctx, []string{"uploads"})
+		if decision.Allowed != (tt.reason == "") || decision.DenyReason != tt.reason || decision.MatchedStatement != tt.sid {
+			t.Errorf("%s: decision = %+v, want reason %q from %q", key, decision, tt.reason, tt.sid)
+		}
+	}
+}
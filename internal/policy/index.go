@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+)
+
+// statementIndex narrows the statements of a policy to those that can match
+// a request, so policies with thousands of statements are not scanned in
+// full. Statements keep their order, which decides the reported Sid.
+type statementIndex struct {
+	byActionBucket map[string][]int // "action bucket": literal actions on literal buckets
+	byAction       map[string][]int // literal actions on any bucket
+	anyAction      []int            // wildcard action patterns
+	buckets        [][]string       // literal buckets of each statement; nil when any bucket can match
+}
+
+// newStatementIndex indexes statements by action and bucket
+func newStatementIndex(statements []Statement) *statementIndex {
+	idx := &statementIndex{
+		byActionBucket: make(map[string][]int),
+		byAction:       make(map[string][]int),
+		buckets:        make([][]string, len(statements)),
+	}
+	for i, stmt := range statements {
+		idx.buckets[i] = literalBuckets(stmt.Resources)
+		if slices.ContainsFunc(stmt.Actions, hasWildcard) {
+			idx.anyAction = append(idx.anyAction, i)
+			continue
+		}
+		for _, action := range stmt.Actions {
+			if idx.buckets[i] == nil {
+				idx.byAction[action] = appendIndex(idx.byAction[action], i)
+				continue
+			}
+			for _, bucket := range idx.buckets[i] {
+				key := action + " " + bucket
+				idx.byActionBucket[key] = appendIndex(idx.byActionBucket[key], i)
+			}
+		}
+	}
+	return idx
+}
+
+// candidates returns the indexes of the statements that may match the
+// request, in order
+func (p *Policy) candidates(ctx *EvalContext) []int {
+	if p.index == nil {
+		all := make([]int, len(p.Statements))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+	bucket, _, _ := ParseResourceARN(ctx.Resource)
+	return p.index.candidates(ctx.Action, bucket)
+}
+
+// candidates returns, in order, the statements that may match action on bucket
+func (idx *statementIndex) candidates(action, bucket string) []int {
+	out := append([]int(nil), idx.byActionBucket[action+" "+bucket]...)
+	out = append(out, idx.byAction[action]...)
+	for _, i := range idx.anyAction {
+		if buckets := idx.buckets[i]; buckets == nil || slices.Contains(buckets, bucket) {
+			out = append(out, i)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// appendIndex adds i unless it was just added, as statements listing an
+// action or bucket twice would be
+func appendIndex(list []int, i int) []int {
+	if len(list) > 0 && list[len(list)-1] == i {
+		return list
+	}
+	return append(list, i)
+}
+
+// literalBuckets returns the buckets resource patterns are limited to, or nil
+// when a pattern can match any bucket
+func literalBuckets(resources []string) []string {
+	const prefix = "arn:aws:s3:::"
+	buckets := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		if !strings.HasPrefix(resource, prefix) {
+			return nil
+		}
+		bucket, _, _ := strings.Cut(strings.TrimPrefix(resource, prefix), "/")
+		if hasWildcard(bucket) {
+			return nil
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+func hasWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// largePolicies returns one policy with n statements, each granting a few
+// actions on its own tenant bucket, plus a handful of wildcard statements
+func largePolicies(n int) *config.PoliciesConfig {
+	actions := []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:ListBucket"}
+	p := config.Policy{Name: "large"}
+	for i := 0; i < n; i++ {
+		bucket := fmt.Sprintf("tenant-%05d", i)
+		p.Statements = append(p.Statements, config.Statement{
+			Sid:       fmt.Sprintf("S%d", i),
+			Effect:    config.EffectAllow,
+			Actions:   []string{actions[i%len(actions)], actions[(i+1)%len(actions)]},
+			Resources: []string{"arn:aws:s3:::" + bucket, "arn:aws:s3:::" + bucket + "/*"},
+		})
+		if i%1000 == 0 {
+			p.Statements = append(p.Statements, config.Statement{
+				Sid:       fmt.Sprintf("DenyLogs%d", i),
+				Effect:    config.EffectDeny,
+				Actions:   []string{"s3:Delete*"},
+				Resources: []string{fmt.Sprintf("arn:aws:s3:::tenant-%03d*/logs/*", i/100)},
+			})
+		}
+	}
+	return &config.PoliciesConfig{Policies: []config.Policy{p}}
+}
+
+func TestStatementIndex_MatchesLinearScan(t *testing.T) {
+	engine, err := NewEngineFromConfig(largePolicies(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	linear := *engine.policies["large"]
+	linear.index = nil
+
+	for _, action := range []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:ListBucket", "s3:GetObjectTagging"} {
+		for _, bucket := range []string{"tenant-00000", "tenant-00001", "tenant-01000", "tenant-01999", "other"} {
+			for _, key := range []string{"", "data.csv", "logs/app.log"} {
+				ctx := &EvalContext{Action: action, Resource: BuildResourceARN(bucket, key), Bucket: bucket, Key: key}
+				indexed := engine.evaluatePolicy(ctx, engine.policies["large"])
+				scanned := engine.evaluatePolicy(ctx, &linear)
+				if fmt.Sprint(indexed) != fmt.Sprint(scanned) {
+					t.Errorf("%s %s: indexed %+v, linear %+v", action, ctx.Resource, indexed, scanned)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkEvaluate_10kStatements(b *testing.B) {
+	engine, err := NewEngineFromConfig(largePolicies(10000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := &EvalContext{
+		Action:     "s3:GetObject",
+		Resource:   "arn:aws:s3:::tenant-07776/reports/2024.csv",
+		Bucket:     "tenant-07776",
+		Key:        "reports/2024.csv",
+		Conditions: map[string]string{},
+	}
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if !engine.Evaluate(ctx, []string{"large"}).Allowed {
+				b.Fatal("expected allow")
+			}
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		linear := *engine.policies["large"]
+		linear.index = nil
+		for i := 0; i < b.N; i++ {
+			if engine.evaluatePolicy(ctx, &linear) == nil {
+				b.Fatal("expected allow")
+			}
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+)
+
+// IsolationActions are attempted against the buckets of every other tenant
+var IsolationActions = []string{"s3:ListBucket", "s3:GetObject", "s3:PutObject", "s3:DeleteObject"}
+
+// isolationProbeKey is the object key of object-level attempts, appended
+// to the target scope's prefix
+const isolationProbeKey = "isolation-probe"
+
+// IsolationAttempt is a request by one tenant's credential against a bucket
+// in another tenant's scopes, and how the gateway would decide it
+type IsolationAttempt struct {
+	ClientID     string `json:"clientId"`
+	TenantID     string `json:"tenantId"`
+	TargetTenant string `json:"targetTenant"`
+	TargetScope  string `json:"targetScope"`
+	Action       string `json:"action"`
+	Resource     string `json:"resource"`
+	Allowed      bool   `json:"allowed"`
+	// Reason is DENY_TENANT_BOUNDARY or the policy deny reason for denied
+	// attempts, and the allowing policy and statement otherwise
+	Reason string `json:"reason"`
+}
+
+// IsolationTest attempts every action of IsolationActions with every
+// credential against a bucket in each scope of every other tenant, checking
+// the tenant boundary and then the policies as the gateway does. Where
+// scopes overlap, a bucket belongs to the tenant with the most specific
+// matching scope, and the attempted bucket is one of the target tenant's. A
+// scope the credential's own tenant also holds is shared on purpose and
+// skipped. Every attempt is returned; the allowed ones break isolation.
+func IsolationTest(e Engine, principals []Principal) []IsolationAttempt {
+	tenantScopes := make(scopesByTenant)
+	for _, p := range principals {
+		for _, scope := range p.Scopes {
+			if !containsString(tenantScopes[p.TenantID], scope) {
+				tenantScopes[p.TenantID] = append(tenantScopes[p.TenantID], scope)
+			}
+		}
+	}
+	tenants := make([]string, 0, len(tenantScopes))
+	for t := range tenantScopes {
+		tenants = append(tenants, t)
+	}
+	sort.Strings(tenants)
+
+	var attempts []IsolationAttempt
+	for _, p := range principals {
+		for _, target := range tenants {
+			if target == p.TenantID {
+				continue
+			}
+			for _, scope := range tenantScopes[target] {
+				if containsString(tenantScopes[p.TenantID], scope) {
+					continue
+				}
+				attempts = append(attempts, attemptScope(e, p, target, scope, tenantScopes)...)
+			}
+		}
+	}
+	return attempts
+}
+
+// scopesByTenant holds the scopes of each tenant's credentials
+type scopesByTenant map[string][]string
+
+// owner returns the tenant with the most specific scope matching bucket,
+// or "" when tenants tie
+func (s scopesByTenant) owner(bucket string) string {
+	owner, best := "", -1
+	for tenant, scopes := range s {
+		for _, scope := range scopes {
+			pattern, _, _ := strings.Cut(scope, "/")
+			if !matchPattern(bucket, pattern) {
+				continue
+			}
+			specificity := len(strings.NewReplacer("*", "", "?", "").Replace(pattern))
+			switch {
+			case specificity > best:
+				owner, best = tenant, specificity
+			case specificity == best && tenant != owner:
+				owner = ""
+			}
+		}
+	}
+	return owner
+}
+
+// attemptScope tries every isolation action against one target scope. The
+// bucket is one of the target tenant's inside the credential's own scopes
+// when there is one, so the policies are exercised.
+func attemptScope(e Engine, p Principal, target, scope string, tenantScopes scopesByTenant) []IsolationAttempt {
+	targetBucket, targetPrefix, _ := strings.Cut(scope, "/")
+	bucket := instantiate(targetBucket)
+	for _, own := range p.Scopes {
+		ownBucket, _, _ := strings.Cut(own, "/")
+		if w, ok := globWitness(targetBucket, ownBucket); ok && tenantScopes.owner(w) == target {
+			bucket = w
+			break
+		}
+	}
+	key := instantiate(targetPrefix)
+	if key != "" && !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+	key += isolationProbeKey
+
+	var attempts []IsolationAttempt
+	for _, action := range IsolationActions {
+		a := IsolationAttempt{ClientID: p.ClientID, TenantID: p.TenantID, TargetTenant: target,
+			TargetScope: scope, Action: action}
+		objectKey := key
+		if action == "s3:ListBucket" {
+			objectKey = ""
+		}
+		a.Resource = BuildResourceARN(bucket, objectKey)
+
+		if !MatchScope(bucket, p.Scopes) {
+			a.Reason = "DENY_TENANT_BOUNDARY"
+		} else {
+			ctx := &EvalContext{ClientID: p.ClientID, TenantID: p.TenantID, Action: action,
+				Resource: a.Resource, Bucket: bucket, Key: objectKey, Conditions: map[string]string{}}
+			decision := EvaluateCredential(e, ctx, p.AllowedActions, p.Policies)
+			a.Allowed = decision.Allowed
+			if decision.Allowed {
+				a.Reason = decision.MatchedPolicy + "/" + decision.MatchedStatement
+			} else {
+				a.Reason = string(decision.DenyReason)
+			}
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts
+}
+
+// instantiate returns a string matching a pattern, with wildcards matching
+// as little as possible
+func instantiate(pattern string) string {
+	return strings.NewReplacer("*", "", "?", "x").Replace(pattern)
+}
+
+// globWitness returns a string matching both patterns, if there is one
+func globWitness(a, b string) (string, bool) {
+	if !globsIntersect(a, b) {
+		return "", false
+	}
+	var out strings.Builder
+	// Follow the same choices as globsIntersect, each leading to a match
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && a[i] == '*':
+			if globsIntersect(a[i+1:], b[j:]) {
+				i++
+			} else {
+				out.WriteString(instantiate(b[j : j+1]))
+				j++
+			}
+		case j < len(b) && b[j] == '*':
+			if globsIntersect(a[i:], b[j+1:]) {
+				j++
+			} else {
+				out.WriteString(instantiate(a[i : i+1]))
+				i++
+			}
+		default:
+			c := a[i]
+			if c == '?' {
+				c = b[j]
+			}
+			out.WriteString(instantiate(string(c)))
+			i++
+			j++
+		}
+	}
+	return out.String(), true
+}
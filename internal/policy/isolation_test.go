@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestIsolationTest(t *testing.T) {
+	engine, err := NewEngineFromConfig(&config.PoliciesConfig{Policies: []config.Policy{
+		{Name: "t1", Statements: []config.Statement{{Effect: config.EffectAllow, Actions: []string{"s3:*"},
+			Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"}}}},
+		{Name: "t2", Statements: []config.Statement{{Effect: config.EffectAllow, Actions: []string{"s3:*"},
+			Resources: []string{"arn:aws:s3:::tenant-002-*", "arn:aws:s3:::tenant-002-*/*"}}}},
+		// Overly broad: reaches into tenant-002's buckets for reads
+		{Name: "leaky", Statements: []config.Statement{{Sid: "ReadAll", Effect: config.EffectAllow,
+			Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::*/*"}}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	principals := []Principal{
+		{ClientID: "a", TenantID: "tenant-001", Policies: []string{"t1"}, Scopes: []string{"tenant-001-*", "shared"}},
+		{ClientID: "b", TenantID: "tenant-002", Policies: []string{"t2"}, Scopes: []string{"tenant-002-*/data/*", "shared"}},
+		{ClientID: "c", TenantID: "tenant-003", Policies: []string{"leaky"}, Scopes: []string{"tenant-*"}},
+	}
+
+	attempts := IsolationTest(engine, principals)
+	var allowed []IsolationAttempt
+	boundary := 0
+	for _, a := range attempts {
+		if a.TargetScope == "shared" && a.ClientID != "c" {
+			t.Errorf("shared scope attempted: %+v", a)
+		}
+		if a.Allowed {
+			allowed = append(allowed, a)
+		} else if a.Reason == "DENY_TENANT_BOUNDARY" {
+			boundary++
+		}
+	}
+	// a and b against each other and c's tenant-*, whose buckets in their
+	// scopes are their own; c against tenant-001-*, tenant-002-*/data/* and
+	// twice against shared, which is outside its scopes
+	if len(attempts) != 8*len(IsolationActions) {
+		t.Errorf("attempts = %d, want %d", len(attempts), 8*len(IsolationActions))
+	}
+	if boundary != 6*len(IsolationActions) {
+		t.Errorf("tenant boundary denials = %d, want %d", boundary, 6*len(IsolationActions))
+	}
+	if len(allowed) != 2 {
+		t.Fatalf("allowed = %+v, want c's two reads", allowed)
+	}
+	if a := allowed[0]; a.ClientID != "c" || a.Resource != "arn:aws:s3:::tenant-001-/isolation-probe" || a.Reason != "leaky/ReadAll" {
+		t.Errorf("allowed[0] = %+v", a)
+	}
+	if a := allowed[1]; a.Resource != "arn:aws:s3:::tenant-002-/data/isolation-probe" {
+		t.Errorf("allowed[1] = %+v", a)
+	}
+}
+
+func TestGlobWitness(t *testing.T) {
+	tests := []struct {
+		a, b string
+		ok   bool
+	}{
+		{"tenant-002-*", "tenant-*", true},
+		{"tenant-002-*", "*-logs", true},
+		{"t?nant", "tenant", true},
+		{"tenant-a*", "tenant-b*", false},
+		{"a*b", "ab*c", false},
+	}
+	for _, tt := range tests {
+		w, ok := globWitness(tt.a, tt.b)
+		if ok != tt.ok {
+			t.Errorf("globWitness(%q, %q) ok = %v, want %v", tt.a, tt.b, ok, tt.ok)
+			continue
+		}
+		if ok && (!matchPattern(w, tt.a) || !matchPattern(w, tt.b)) {
+			t.Errorf("globWitness(%q, %q) = %q, matching not both", tt.a, tt.b, w)
+		}
+	}
+}
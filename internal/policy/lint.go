@@ -0,0 +1,315 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KnownActions are the actions the gateway derives from S3 requests
+var KnownActions = []string{
+	"s3:AbortMultipartUpload",
+	"s3:CreateBucket",
+	"s3:DeleteBucket",
+	"s3:DeleteBucketPolicy",
+	"s3:DeleteBucketTagging",
+	"s3:DeleteBucketWebsite",
+	"s3:DeleteLifecycleConfiguration",
+	"s3:DeleteObject",
+	"s3:DeleteObjectTagging",
+	"s3:GetAccelerateConfiguration",
+	"s3:GetAnalyticsConfiguration",
+	"s3:GetBucketAcl",
+	"s3:GetBucketCORS",
+	"s3:GetBucketLocation",
+	"s3:GetBucketLogging",
+	"s3:GetBucketNotification",
+	"s3:GetBucketObjectLockConfiguration",
+	"s3:GetBucketOwnershipControls",
+	"s3:GetBucketPolicy",
+	"s3:GetBucketPolicyStatus",
+	"s3:GetBucketPublicAccessBlock",
+	"s3:GetBucketRequestPayment",
+	"s3:GetBucketTagging",
+	"s3:GetBucketVersioning",
+	"s3:GetBucketWebsite",
+	"s3:GetEncryptionConfiguration",
+	"s3:GetIntelligentTieringConfiguration",
+	"s3:GetInventoryConfiguration",
+	"s3:GetLifecycleConfiguration",
+	"s3:GetMetricsConfiguration",
+	"s3:GetObject",
+	"s3:GetObjectAcl",
+	"s3:GetObjectAttributes",
+	"s3:GetObjectLegalHold",
+	"s3:GetObjectRetention",
+	"s3:GetObjectTagging",
+	"s3:GetObjectTorrent",
+	"s3:GetReplicationConfiguration",
+	"s3:ListBucket",
+	"s3:ListBucketMultipartUploads",
+	"s3:ListBucketVersions",
+	"s3:ListMultipartUploadParts",
+	"s3:PutAccelerateConfiguration",
+	"s3:PutAnalyticsConfiguration",
+	"s3:PutBucketAcl",
+	"s3:PutBucketCORS",
+	"s3:PutBucketLogging",
+	"s3:PutBucketNotification",
+	"s3:PutBucketObjectLockConfiguration",
+	"s3:PutBucketOwnershipControls",
+	"s3:PutBucketPolicy",
+	"s3:PutBucketPublicAccessBlock",
+	"s3:PutBucketRequestPayment",
+	"s3:PutBucketTagging",
+	"s3:PutBucketVersioning",
+	"s3:PutBucketWebsite",
+	"s3:PutEncryptionConfiguration",
+	"s3:PutIntelligentTieringConfiguration",
+	"s3:PutInventoryConfiguration",
+	"s3:PutLifecycleConfiguration",
+	"s3:PutMetricsConfiguration",
+	"s3:PutObject",
+	"s3:PutObjectAcl",
+	"s3:PutObjectLegalHold",
+	"s3:PutObjectRetention",
+	"s3:PutObjectTagging",
+	"s3:PutReplicationConfiguration",
+	"s3:RestoreObject",
+	"s3express:CreateSession",
+}
+
+// Lint checks
+const (
+	LintUnknownAction = "unknown-action" // matches no action the gateway evaluates
+	LintWildcard      = "wildcard"       // grants every action or every resource
+	LintShadowed      = "shadowed"       // an Allow fully covered by an unconditional Deny
+	LintRedundant     = "redundant"      // an Allow fully covered by another Allow
+	LintUnreachable   = "unreachable"    // a resource outside every attached credential's scopes
+	LintUnattached    = "unattached"     // a policy no credential references
+)
+
+// Principal is what linting and isolation tests need to know about a
+// credential
+type Principal struct {
+	ClientID       string
+	TenantID       string
+	Policies       []string
+	Scopes         []string
+	AllowedActions []string
+}
+
+// Finding is a problem found by Lint. Statement is -1 for findings about a
+// whole policy.
+type Finding struct {
+	Policy    string `json:"policy"`
+	Statement int    `json:"statement"`
+	Sid       string `json:"sid,omitempty"`
+	Check     string `json:"check"`
+	Message   string `json:"message"`
+	Warning   bool   `json:"warning"` // reported, but the policy works as written
+}
+
+func (f Finding) String() string {
+	where := fmt.Sprintf("policy %q", f.Policy)
+	if f.Statement >= 0 {
+		where += fmt.Sprintf(" statement %d", f.Statement)
+		if f.Sid != "" {
+			where += fmt.Sprintf(" (%s)", f.Sid)
+		}
+	}
+	if f.Warning {
+		return fmt.Sprintf("%s: warning: %s [%s]", where, f.Message, f.Check)
+	}
+	return fmt.Sprintf("%s: %s [%s]", where, f.Message, f.Check)
+}
+
+// Lint reports statements that cannot take effect or grant more than was
+// likely intended: unknown actions, Allows of wildcard-only patterns, Allows
+// shadowed by a Deny or duplicating another Allow of the same policy, and
+// resources no credential attached to the policy can reach. Bucket policies
+// apply to every credential. Unknown actions are errors, the rest warnings.
+func Lint(policies, bucketPolicies []*Policy, principals []Principal) []Finding {
+	var findings []Finding
+	for _, p := range policies {
+		var scopes []string
+		attached := false
+		for _, pr := range principals {
+			for _, name := range pr.Policies {
+				if name == p.Name {
+					attached = true
+					scopes = append(scopes, pr.Scopes...)
+				}
+			}
+		}
+		if !attached {
+			findings = append(findings, Finding{Policy: p.Name, Statement: -1, Check: LintUnattached,
+				Message: "no credential references this policy", Warning: true})
+		}
+		findings = append(findings, lintPolicy(p, scopes, attached)...)
+	}
+
+	var allScopes []string
+	for _, pr := range principals {
+		allScopes = append(allScopes, pr.Scopes...)
+	}
+	for _, p := range bucketPolicies {
+		findings = append(findings, lintPolicy(p, allScopes, len(principals) > 0)...)
+	}
+	return findings
+}
+
+func lintPolicy(p *Policy, scopes []string, checkReach bool) []Finding {
+	var findings []Finding
+	add := func(i int, check string, warning bool, format string, args ...interface{}) {
+		findings = append(findings, Finding{Policy: p.Name, Statement: i, Sid: p.Statements[i].Sid,
+			Check: check, Warning: warning, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, stmt := range p.Statements {
+		for _, action := range stmt.Actions {
+			if stmt.Effect == EffectAllow && (action == "*" || action == "s3:*") {
+				add(i, LintWildcard, true, "action %q grants every action", action)
+			} else if !matchesAnyKnown(action) {
+				add(i, LintUnknownAction, false, "action %q matches no S3 action the gateway evaluates", action)
+			}
+		}
+		for _, resource := range stmt.Resources {
+			bucket := resourceBucket(resource)
+			if strings.Trim(bucket, "*") == "" {
+				if stmt.Effect == EffectAllow {
+					add(i, LintWildcard, true, "resource %q matches every bucket", resource)
+				}
+			} else if checkReach && !reachable(bucket, scopes) {
+				add(i, LintUnreachable, true, "resource %q is outside the scopes of every credential using this policy", resource)
+			}
+		}
+
+		if stmt.Effect != EffectAllow {
+			continue
+		}
+		for j, other := range p.Statements {
+			if j == i || len(other.Conditions) > 0 || !samePrincipals(stmt, other) || !covers(other, stmt) {
+				continue
+			}
+			if other.Effect == EffectDeny {
+				add(i, LintShadowed, true, "every request it allows is denied by statement %d", j)
+				break
+			}
+			// Of two identical Allows, only the later one is reported. A rate
+			// limit takes effect even when another Allow covers its statement.
+			if stmt.RateLimit == nil && (j < i || !covers(stmt, other)) {
+				add(i, LintRedundant, true, "statement %d already allows every request it allows", j)
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// matchesAnyKnown reports whether an action pattern matches at least one
+// known action
+func matchesAnyKnown(pattern string) bool {
+	for _, action := range KnownActions {
+		if matchPattern(action, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether every action and resource of b is matched by a
+// pattern of a. Allow conditions only narrow b, so they are ignored.
+func covers(a, b Statement) bool {
+	for _, action := range b.Actions {
+		if !patternCovered(action, a.Actions) {
+			return false
+		}
+	}
+	for _, resource := range b.Resources {
+		if !patternCovered(resource, a.Resources) {
+			return false
+		}
+	}
+	return true
+}
+
+// patternCovered reports whether a pattern of patterns matches everything
+// pattern does. Only '*' is treated as covering, as '?' in a broader
+// pattern cannot stand for a '*' in the narrower one.
+func patternCovered(pattern string, patterns []string) bool {
+	for _, p := range patterns {
+		if !strings.Contains(p, "?") && matchPattern(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func samePrincipals(a, b Statement) bool {
+	if len(b.Principals) == 0 {
+		return len(a.Principals) == 0
+	}
+	for _, p := range a.Principals {
+		if p != "*" && !containsString(b.Principals, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceBucket returns the bucket part of a resource pattern; patterns
+// that are not S3 ARNs may match any bucket
+func resourceBucket(resource string) string {
+	const prefix = "arn:aws:s3:::"
+	if !strings.HasPrefix(resource, prefix) {
+		return "*"
+	}
+	bucket, _, _ := strings.Cut(strings.TrimPrefix(resource, prefix), "/")
+	return bucket
+}
+
+// reachable reports whether a bucket pattern shares a bucket with a scope
+func reachable(bucket string, scopes []string) bool {
+	for _, scope := range scopes {
+		scopeBucket, _, _ := strings.Cut(scope, "/")
+		if globsIntersect(bucket, scopeBucket) {
+			return true
+		}
+	}
+	return false
+}
+
+// globsIntersect reports whether some string matches both patterns
+func globsIntersect(a, b string) bool {
+	memo := make(map[[2]int]bool)
+	var match func(i, j int) bool
+	match = func(i, j int) bool {
+		key := [2]int{i, j}
+		if v, ok := memo[key]; ok {
+			return v
+		}
+		var v bool
+		switch {
+		case i < len(a) && a[i] == '*':
+			v = match(i+1, j) || (j < len(b) && match(i, j+1))
+		case j < len(b) && b[j] == '*':
+			v = match(i, j+1) || (i < len(a) && match(i+1, j))
+		case i == len(a) || j == len(b):
+			v = i == len(a) && j == len(b)
+		default:
+			v = (a[i] == '?' || b[j] == '?' || a[i] == b[j]) && match(i+1, j+1)
+		}
+		memo[key] = v
+		return v
+	}
+	return match(0, 0)
+}
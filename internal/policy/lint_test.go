@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestLint(t *testing.T) {
+	policies := []*Policy{
+		{
+			Name: "app",
+			Statements: []Statement{
+				{Sid: "ReadData", Effect: EffectAllow, Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::data/reports/*"}},
+				{Sid: "ReadAll", Effect: EffectAllow, Actions: []string{"s3:Get*"}, Resources: []string{"arn:aws:s3:::data/*"}},
+				{Sid: "Typo", Effect: EffectAllow, Actions: []string{"s3:GetObjekt", "s3:List*"}, Resources: []string{"arn:aws:s3:::data"}},
+				{Sid: "Secrets", Effect: EffectAllow, Actions: []string{"s3:PutObject"}, Resources: []string{"arn:aws:s3:::data/secret/*"}},
+				{Sid: "DenySecrets", Effect: EffectDeny, Actions: []string{"s3:*"}, Resources: []string{"arn:aws:s3:::data/secret/*"}},
+				{Sid: "Other", Effect: EffectAllow, Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::other-*/*"}},
+			},
+		},
+		{
+			Name: "admin",
+			Statements: []Statement{
+				{Sid: "Everything", Effect: EffectAllow, Actions: []string{"*"}, Resources: []string{"*"}},
+			},
+		},
+		{
+			Name: "conditional",
+			Statements: []Statement{
+				{Sid: "Put", Effect: EffectAllow, Actions: []string{"s3:PutObject"}, Resources: []string{"arn:aws:s3:::data/*"}},
+				{Sid: "DenyUnencrypted", Effect: EffectDeny, Actions: []string{"s3:PutObject"}, Resources: []string{"arn:aws:s3:::data/*"},
+					Conditions: map[string]map[string]config.ConditionValues{"Null": {"s3:x-amz-server-side-encryption": {"true"}}}},
+			},
+		},
+	}
+	bucketPolicies := []*Policy{{
+		Name:    "archive",
+		Buckets: []string{"archive"},
+		Statements: []Statement{
+			{Sid: "Read", Effect: EffectAllow, Principals: []string{"*"}, Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::archive/*"}},
+		},
+	}}
+	principals := []Principal{
+		{ClientID: "app", Policies: []string{"app", "conditional"}, Scopes: []string{"data", "data/*"}},
+	}
+
+	var got []string
+	for _, f := range Lint(policies, bucketPolicies, principals) {
+		got = append(got, f.String())
+	}
+	sort.Strings(got)
+	want := []string{
+		`policy "admin" statement 0 (Everything): warning: action "*" grants every action [wildcard]`,
+		`policy "admin" statement 0 (Everything): warning: resource "*" matches every bucket [wildcard]`,
+		`policy "admin": warning: no credential references this policy [unattached]`,
+		`policy "app" statement 0 (ReadData): warning: statement 1 already allows every request it allows [redundant]`,
+		`policy "app" statement 2 (Typo): action "s3:GetObjekt" matches no S3 action the gateway evaluates [unknown-action]`,
+		`policy "app" statement 3 (Secrets): warning: every request it allows is denied by statement 4 [shadowed]`,
+		`policy "app" statement 5 (Other): warning: resource "arn:aws:s3:::other-*/*" is outside the scopes of every credential using this policy [unreachable]`,
+		`policy "archive" statement 0 (Read): warning: resource "arn:aws:s3:::archive/*" is outside the scopes of every credential using this policy [unreachable]`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGlobsIntersect(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"data", "data", true},
+		{"data", "other", false},
+		{"tenant-*", "tenant-001", true},
+		{"tenant-*", "*-logs", true},
+		{"tenant-a*", "tenant-b*", false},
+		{"t?nant", "tenant", true},
+		{"*", "", true},
+		{"a*b", "ab*c", false},
+	}
+	for _, tt := range tests {
+		if got := globsIntersect(tt.a, tt.b); got != tt.want {
+			t.Errorf("globsIntersect(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
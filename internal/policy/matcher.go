@@ -3,6 +3,7 @@ package policy
 import (
 	"regexp"
 	"strings"
+	"sync"
 )
 
 // MatchAction checks if the given action matches any of the action patterns
@@ -41,12 +42,43 @@ func MatchScope(bucket string, scopes []string) bool {
 // - "*" matches any sequence of characters
 // - "?" matches any single character
 func matchPattern(str, pattern string) bool {
-	// Convert pattern to regex
-	regexPattern := patternToRegex(pattern)
-	matched, _ := regexp.MatchString("^"+regexPattern+"$", str)
-	return matched
+	if !hasWildcard(pattern) {
+		return str == pattern
+	}
+	re, ok := compiledPatterns.Load(pattern)
+	if !ok {
+		// Convert pattern to regex
+		compiled, err := regexp.Compile("^" + patternToRegex(pattern) + "$")
+		if err != nil {
+			return false
+		}
+		re, _ = compiledPatterns.LoadOrStore(pattern, compiled)
+	}
+	return re.(*regexp.Regexp).MatchString(str)
 }
 
+// compiledPatterns caches the regexes of patterns, which come from policies
+// and scopes and so are few
+var compiledPatterns sync.Map
+
+// matchRegex reports whether str matches a StringMatchesRegex pattern.
+// Patterns are not anchored; invalid ones, rejected when policies are
+// validated, match nothing.
+func matchRegex(str, pattern string) bool {
+	re, ok := compiledRegexes.Load(pattern)
+	if !ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return false
+		}
+		re, _ = compiledRegexes.LoadOrStore(pattern, compiled)
+	}
+	return re.(*regexp.Regexp).MatchString(str)
+}
+
+// compiledRegexes caches condition regexes after variable substitution
+var compiledRegexes sync.Map
+
 // matchScopePattern matches a bucket name against a scope pattern
 // Scope patterns are like "tenant-001-*" or "shared-bucket/prefix/*"
 func matchScopePattern(bucket string, scopePattern string) bool {
@@ -0,0 +1,195 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// maxRemotePolicies bounds the size of a fetched policies document
+const maxRemotePolicies = 16 << 20
+
+// Fetcher retrieves a remote policies document. When etag is the current
+// version's ETag, it reports changed=false without returning the document.
+type Fetcher interface {
+	Fetch(ctx context.Context, etag string) (data []byte, newETag string, changed bool, err error)
+}
+
+// HTTPFetcher fetches policies from an HTTP(S) URL using If-None-Match
+type HTTPFetcher struct {
+	URL    string
+	Client *http.Client
+}
+
+// Fetch implements Fetcher
+func (f *HTTPFetcher) Fetch(ctx context.Context, etag string) ([]byte, string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, false, nil
+	case http.StatusOK:
+	default:
+		return nil, "", false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return data, resp.Header.Get("ETag"), true, nil
+}
+
+// S3Fetcher fetches policies from an S3 object using IfNoneMatch
+type S3Fetcher struct {
+	Client *s3.Client
+	Bucket string
+	Key    string
+}
+
+// Fetch implements Fetcher
+func (f *S3Fetcher) Fetch(ctx context.Context, etag string) ([]byte, string, bool, error) {
+	input := &s3.GetObjectInput{Bucket: aws.String(f.Bucket), Key: aws.String(f.Key)}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+	output, err := f.Client.GetObject(ctx, input)
+	var statusErr interface{ HTTPStatusCode() int }
+	if stderrors.As(err, &statusErr) && statusErr.HTTPStatusCode() == http.StatusNotModified {
+		return nil, etag, false, nil
+	}
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer output.Body.Close()
+
+	data, err := readLimited(output.Body)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return data, aws.ToString(output.ETag), true, nil
+}
+
+// NewFetcher returns the fetcher for an s3:// or http(s):// policies URL.
+// s3Client is used for s3:// URLs.
+func NewFetcher(location string, s3Client *s3.Client, httpClient *http.Client) (Fetcher, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policies URL: %w", err)
+	}
+	switch u.Scheme {
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid policies URL %q: expected s3://bucket/key", location)
+		}
+		return &S3Fetcher{Client: s3Client, Bucket: u.Host, Key: key}, nil
+	case "http", "https":
+		return &HTTPFetcher{URL: location, Client: httpClient}, nil
+	}
+	return nil, fmt.Errorf("invalid policies URL %q: unsupported scheme", location)
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxRemotePolicies+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRemotePolicies {
+		return nil, fmt.Errorf("policies document exceeds %d bytes", maxRemotePolicies)
+	}
+	return data, nil
+}
+
+// Poller periodically fetches a remote policies document and loads it into
+// an engine. A document that fails to fetch, parse or validate leaves the
+// previous policies in place.
+type Poller struct {
+	location string
+	fetcher  Fetcher
+	engine   *DefaultEngine
+	interval time.Duration
+
+	mu     sync.Mutex
+	loaded bool
+	etag   string
+	digest [sha256.Size]byte
+}
+
+// NewPoller creates a poller loading documents from fetcher into engine
+func NewPoller(location string, fetcher Fetcher, engine *DefaultEngine, interval time.Duration) *Poller {
+	return &Poller{location: location, fetcher: fetcher, engine: engine, interval: interval}
+}
+
+// Poll fetches the document once, loading it if it changed. It reports
+// whether new policies were loaded.
+func (p *Poller) Poll(ctx context.Context) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, etag, changed, err := p.fetcher.Fetch(ctx, p.etag)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch policies from %s: %w", p.location, err)
+	}
+	if !changed {
+		return false, nil
+	}
+	// Servers without ETags send the document every time
+	digest := sha256.Sum256(data)
+	if p.loaded && digest == p.digest {
+		p.etag = etag
+		return false, nil
+	}
+
+	cfg, err := config.ParsePolicies(data)
+	if err != nil {
+		return false, fmt.Errorf("policies from %s: %w", p.location, err)
+	}
+	if err := p.engine.Load(cfg); err != nil {
+		return false, fmt.Errorf("policies from %s: %w", p.location, err)
+	}
+	p.loaded, p.etag, p.digest = true, etag, digest
+	return true, nil
+}
+
+// Run polls every interval until ctx is done
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		loaded, err := p.Poll(ctx)
+		switch {
+		case err != nil:
+			log.Printf("Keeping current policies: %v", err)
+		case loaded:
+			log.Printf("Loaded updated policies from %s", p.location)
+		}
+	}
+}
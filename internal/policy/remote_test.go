@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+const remoteV1 = `
+policies:
+  - name: readers
+    statements:
+      - effect: Allow
+        actions: [s3:GetObject]
+        resources: ["arn:aws:s3:::shared/*"]
+`
+
+const remoteV2 = `
+policies:
+  - name: writers
+    statements:
+      - effect: Allow
+        actions: [s3:PutObject]
+        resources: ["arn:aws:s3:::shared/*"]
+`
+
+func TestPoller_HTTP(t *testing.T) {
+	var mu sync.Mutex
+	doc, etag := remoteV1, `"v1"`
+	fetches, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(doc))
+	}))
+	defer server.Close()
+	set := func(d, e string) {
+		mu.Lock()
+		doc, etag = d, e
+		mu.Unlock()
+	}
+
+	fetcher, err := NewFetcher(server.URL+"/policies.yaml", nil, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, _ := NewEngineFromConfig(&config.PoliciesConfig{})
+	poller := NewPoller(server.URL, fetcher, engine, time.Minute)
+	ctx := context.Background()
+
+	if loaded, err := poller.Poll(ctx); !loaded || err != nil {
+		t.Fatalf("first poll = %v, %v", loaded, err)
+	}
+	if _, ok := engine.GetPolicy("readers"); !ok {
+		t.Fatal("readers policy not loaded")
+	}
+
+	if loaded, err := poller.Poll(ctx); loaded || err != nil {
+		t.Errorf("unchanged poll = %v, %v", loaded, err)
+	}
+	if notModified != 1 {
+		t.Errorf("expected a conditional request answered 304, got %d", notModified)
+	}
+
+	// An invalid document keeps the current policies
+	set("policies: [{name: broken}", `"v2"`)
+	if _, err := poller.Poll(ctx); err == nil {
+		t.Error("expected error for invalid document")
+	}
+	if _, ok := engine.GetPolicy("readers"); !ok {
+		t.Error("readers policy dropped after invalid document")
+	}
+
+	set(remoteV2, `"v3"`)
+	if loaded, err := poller.Poll(ctx); !loaded || err != nil {
+		t.Fatalf("changed poll = %v, %v", loaded, err)
+	}
+	if _, ok := engine.GetPolicy("readers"); ok {
+		t.Error("readers policy still loaded after swap")
+	}
+	if _, ok := engine.GetPolicy("writers"); !ok {
+		t.Error("writers policy not loaded")
+	}
+}
+
+func TestNewFetcher(t *testing.T) {
+	f, err := NewFetcher("s3://security-policies/gateway/policies.yaml", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s3f, ok := f.(*S3Fetcher); !ok || s3f.Bucket != "security-policies" || s3f.Key != "gateway/policies.yaml" {
+		t.Errorf("NewFetcher = %+v", f)
+	}
+	for _, location := range []string{"s3://bucket-only", "ftp://host/policies.yaml"} {
+		if _, err := NewFetcher(location, nil, nil); err == nil {
+			t.Errorf("%s: expected error", location)
+		}
+	}
+}
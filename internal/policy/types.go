@@ -1,6 +1,9 @@
 package policy
 
-import "github.com/s3-access-control-adapter/internal/errors"
+import (
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+)
 
 // Effect represents Allow or Deny
 type Effect string
@@ -15,6 +18,9 @@ type Policy struct {
 	Name       string
 	Version    string
 	Statements []Statement
+	Buckets    []string // bucket patterns of a bucket policy; none for identity policies
+
+	index *statementIndex // set when loaded by an engine
 }
 
 // Statement represents a policy statement
@@ -23,7 +29,9 @@ type Statement struct {
 	Effect     Effect
 	Actions    []string
 	Resources  []string
-	Conditions map[string]map[string]string
+	Principals []string // bucket policies only
+	Conditions map[string]map[string]config.ConditionValues
+	RateLimit  *config.RateLimit
 }
 
 // EvalContext contains the context for policy evaluation
@@ -35,6 +43,20 @@ type EvalContext struct {
 	Bucket     string            // Bucket name for convenience
 	Key        string            // Object key for convenience
 	Conditions map[string]string // Runtime conditions (source IP, etc.)
+	// MultiValues holds multi-valued condition keys, e.g.
+	// s3:RequestObjectTagKeys, compared with ForAllValues/ForAnyValue
+	MultiValues map[string][]string
+}
+
+// Values returns the values of a condition key, and whether it is present
+func (c *EvalContext) Values(key string) ([]string, bool) {
+	if values, ok := c.MultiValues[key]; ok {
+		return values, true
+	}
+	if value, ok := c.Conditions[key]; ok {
+		return []string{value}, true
+	}
+	return nil, false
 }
 
 // Decision represents the result of policy evaluation
@@ -43,6 +65,16 @@ type Decision struct {
 	DenyReason       errors.DenyReason
 	MatchedPolicy    string
 	MatchedStatement string
+	// RateLimits of every Allow statement that matched an allowed request
+	RateLimits []AppliedRateLimit
+}
+
+// AppliedRateLimit is the rate limit of a statement, identified by its
+// policy and index
+type AppliedRateLimit struct {
+	Policy    string
+	Statement int
+	config.RateLimit
 }
 
 // NewAllowDecision creates an allow decision
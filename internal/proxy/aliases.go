@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// prefixBucketOperations are the bucket-level operations served to requests
+// limited to a prefix, such as those through an alias; configuration and
+// deletion of the bucket itself are not
+var prefixBucketOperations = map[string]bool{
+	"HeadBucket":           true,
+	"ListObjects":          true,
+	"ListObjectsV2":        true,
+	"ListObjectVersions":   true,
+	"ListMultipartUploads": true,
+}
+
+// WithAliases serves the configured aliases as buckets, in the manner of S3
+// Access Points: requests addressed to an alias go to its bucket, limited to
+// its prefix, and must also be allowed by the alias's policies
+func WithAliases(aliases []config.AliasConfig) Option {
+	return func(g *Gateway) {
+		g.aliases = make(map[string]*config.AliasConfig, len(aliases))
+		for i := range aliases {
+			g.aliases[aliases[i].Name] = &aliases[i]
+		}
+	}
+}
+
+// resolveAlias points a request addressed to an alias at the alias's
+// bucket. It returns the alias, or nil for other requests.
+func (g *Gateway) resolveAlias(s3req *S3Request) *config.AliasConfig {
+	alias, ok := g.aliases[s3req.Bucket]
+	if !ok {
+		return nil
+	}
+	s3req.Bucket, s3req.Alias = alias.Bucket, alias.Name
+	return alias
+}
+
+// withinAlias reports whether a request addressed to an alias stays within
+// its bucket, prefix and operations, setting the prefix of listings that
+// have none. It is checked after hooks, which may rewrite the request.
+func withinAlias(alias *config.AliasConfig, s3req *S3Request) bool {
+	return alias == nil || (s3req.Bucket == alias.Bucket && confineToPrefix(s3req, alias.Prefix))
+}
+
+// confineToPrefix reports whether a request stays within a key prefix,
+// setting it as the prefix of listings that have none. Of the bucket-level
+// operations only prefixBucketOperations are allowed.
+func confineToPrefix(s3req *S3Request, prefix string) bool {
+	if s3req.Key != "" {
+		return strings.HasPrefix(s3req.Key, prefix)
+	}
+	if !prefixBucketOperations[s3req.Operation] {
+		return false
+	}
+	if prefix == "" || s3req.HTTPMethod != http.MethodGet {
+		return true
+	}
+	listPrefix := s3req.QueryParams.Get("prefix")
+	if listPrefix == "" {
+		s3req.QueryParams.Set("prefix", prefix)
+		return true
+	}
+	return strings.HasPrefix(listPrefix, prefix)
+}
+
+// evaluateAlias checks a request the credential's policies allowed against
+// the policies of the alias it was addressed to. Rate limits of both apply.
+func (g *Gateway) evaluateAlias(alias *config.AliasConfig, evalCtx *policy.EvalContext, decision *policy.Decision) *policy.Decision {
+	if alias == nil || len(alias.Policies) == 0 {
+		return decision
+	}
+	aliasDecision := g.policyEngine.Evaluate(evalCtx, alias.Policies)
+	if !aliasDecision.Allowed {
+		return aliasDecision
+	}
+	decision.RateLimits = append(decision.RateLimits, aliasDecision.RateLimits...)
+	return decision
+}
@@ -0,0 +1,114 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+)
+
+func TestGateway_Aliases(t *testing.T) {
+	var forwarded *proxy.S3Request
+	backend := proxy.ForwarderFunc(func(ctx context.Context, req *proxy.S3Request) (*proxy.S3Response, error) {
+		forwarded = req
+		return &proxy.S3Response{StatusCode: http.StatusOK, Headers: http.Header{}}, nil
+	})
+	partnerCred := config.Credential{
+		AccessKey: "AKIAPARTNER000000001",
+		SecretKey: "partner-secret",
+		ClientID:  "partner",
+		TenantID:  "tenant-001",
+		Policies:  []string{"reports-via-alias"},
+		Scopes:    []string{"tenant-001-*"},
+	}
+	policies := append([]config.Policy{{
+		Name: "reports-via-alias",
+		Statements: []config.Statement{{
+			Effect:     config.EffectAllow,
+			Actions:    []string{"s3:GetObject"},
+			Resources:  []string{"arn:aws:s3:::tenant-001-data/*"},
+			Conditions: map[string]map[string]config.ConditionValues{"StringEquals": {"gateway:Alias": {"tenant-001-reports"}}},
+		}},
+	}}, testPolicies...)
+	gw := proxytest.NewGatewayWithForwarder(t, []config.Credential{writerCred, partnerCred}, policies, backend,
+		proxy.WithAliases([]config.AliasConfig{{
+			Name:     "tenant-001-reports",
+			Bucket:   "tenant-001-data",
+			Prefix:   "reports/",
+			Policies: []string{"tenant-001-readonly"},
+		}}))
+
+	resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-001-reports/reports/q3.csv", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET through alias: status = %d, body = %s", resp.StatusCode, proxytest.ReadBody(t, resp))
+	}
+	if forwarded.Bucket != "tenant-001-data" || forwarded.Key != "reports/q3.csv" {
+		t.Errorf("forwarded %s/%s, want tenant-001-data/reports/q3.csv", forwarded.Bucket, forwarded.Key)
+	}
+	if entry := gw.Audit.Last(); entry.Bucket != "tenant-001-data" || entry.Alias != "tenant-001-reports" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+
+	resp = gw.Do(t, writerCred, http.MethodGet, "/tenant-001-reports?list-type=2", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("listing through alias: status = %d", resp.StatusCode)
+	}
+	if prefix := forwarded.QueryParams.Get("prefix"); prefix != "reports/" {
+		t.Errorf("listing prefix = %q, want reports/", prefix)
+	}
+
+	forwarded = nil
+	for _, tc := range []struct {
+		name, method, path, reason string
+	}{
+		{"key outside prefix", http.MethodGet, "/tenant-001-reports/private/salaries.csv", "DENY_ALIAS_SCOPE"},
+		{"listing outside prefix", http.MethodGet, "/tenant-001-reports?list-type=2&prefix=private/", "DENY_ALIAS_SCOPE"},
+		{"bucket operation", http.MethodDelete, "/tenant-001-reports", "DENY_ALIAS_SCOPE"},
+		{"denied by alias policy", http.MethodPut, "/tenant-001-reports/reports/q4.csv", "DENY_POLICY"},
+	} {
+		resp := gw.Do(t, writerCred, tc.method, tc.path, nil)
+		if body := proxytest.ReadBody(t, resp); resp.StatusCode != http.StatusForbidden || !strings.Contains(body, "AccessDenied") {
+			t.Errorf("%s: status = %d, body = %s", tc.name, resp.StatusCode, body)
+		}
+		if entry := gw.Audit.Last(); entry.DenyReason != tc.reason {
+			t.Errorf("%s: deny reason = %s, want %s", tc.name, entry.DenyReason, tc.reason)
+		}
+	}
+	if forwarded != nil {
+		t.Errorf("denied request forwarded: %s %s/%s", forwarded.Operation, forwarded.Bucket, forwarded.Key)
+	}
+
+	// A grant conditioned on gateway:Alias applies only through the alias
+	if resp := gw.Do(t, partnerCred, http.MethodGet, "/tenant-001-reports/reports/q3.csv", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("partner through alias: status = %d", resp.StatusCode)
+	}
+	if resp := gw.Do(t, partnerCred, http.MethodGet, "/tenant-001-data/reports/q3.csv", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("partner addressing the bucket: status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestGateway_AliasScopeAppliesAfterHooks(t *testing.T) {
+	hooks, err := proxy.NewConfigHooks([]config.HookConfig{
+		{Type: proxy.HookRewriteKey, Buckets: []string{"tenant-001-data"}, From: "reports/old/", To: "private/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithHooks(hooks...),
+		proxy.WithAliases([]config.AliasConfig{{Name: "tenant-001-reports", Bucket: "tenant-001-data", Prefix: "reports/"}}))
+	gw.Backend.PutObject("tenant-001-data", "private/salaries.csv", []byte("x"))
+
+	// The key is within the alias's prefix until the hook rewrites it
+	resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-001-reports/reports/old/salaries.csv", nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("rewritten out of the alias: status = %d, want 403", resp.StatusCode)
+	}
+	if entry := gw.Audit.Last(); entry.DenyReason != "DENY_ALIAS_SCOPE" {
+		t.Errorf("deny reason = %s, want DENY_ALIAS_SCOPE", entry.DenyReason)
+	}
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var auditWriteErrors = metrics.NewCounter("gateway_audit_write_errors_total",
+	"Audit entries that could not be recorded")
+
+// countingReader counts request body bytes read by the gateway
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingResponseWriter counts response body bytes written to the client
+// and records the status and when the headers were sent
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n         int64
+	status    int
+	headersAt time.Time
+}
+
+func (c *countingResponseWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status, c.headersAt = status, time.Now()
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *countingResponseWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status, c.headersAt = http.StatusOK, time.Now()
+	}
+	n, err := c.ResponseWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (c *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// logAudit adds connection, signature and transfer details to an entry and
+// logs it. It is normally called after the response is written so byte counts
+// are final; w is nil when the entry is recorded before the response.
+func (g *Gateway) logAudit(entry *audit.Entry, w http.ResponseWriter, r *http.Request) error {
+	c := correlationFrom(r.Context())
+	entry.CorrelationID = c.ID()
+	entry.TraceParent = c.traceParent
+	entry.Protocol = r.Proto
+	if rc := RequestContextFrom(r.Context()); rc != nil {
+		if rc.S3 != nil {
+			entry.Alias = rc.S3.Alias
+		}
+		entry.DLPFindings = rc.dlpFindings
+	}
+	if r.TLS != nil {
+		entry.TLSVersion = tls.VersionName(r.TLS.Version)
+		entry.TLSCipher = tls.CipherSuiteName(r.TLS.CipherSuite)
+	}
+	if components, err := g.sigValidator.ParseAuthHeader(r.Header.Get("Authorization")); err == nil {
+		entry.AccessKeyID = components.AccessKey
+		entry.SignedHeaders = components.SignedHeaders
+	}
+	if body := countingBody(r.Body); body != nil {
+		entry.BytesIn = body.n
+	}
+	if cw, ok := w.(*countingResponseWriter); ok {
+		entry.BytesOut = cw.n
+	}
+	if g.geo != nil {
+		info := g.geo.Lookup(entry.SourceIP)
+		entry.Country, entry.ASN, entry.ASOrg = info.Country, info.ASN, info.ASOrg
+	}
+	g.metricLabels.countDecision(entry)
+
+	if err := g.auditLogger.Log(entry); err != nil {
+		auditWriteErrors.Inc()
+		log.Printf("[%s] Failed to record audit entry: %v", entry.RequestID, err)
+		return err
+	}
+	return nil
+}
+
+// countingBody finds the countingReader under readers wrapping the request
+// body, such as the signature validator's payload decoding
+func countingBody(body io.ReadCloser) *countingReader {
+	for body != nil {
+		if c, ok := body.(*countingReader); ok {
+			return c
+		}
+		u, ok := body.(interface{ Unwrap() io.ReadCloser })
+		if !ok {
+			return nil
+		}
+		body = u.Unwrap()
+	}
+	return nil
+}
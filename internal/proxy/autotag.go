@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// autoTagOperations are the uploads that take an x-amz-tagging header
+var autoTagOperations = map[string]bool{
+	"PutObject":             true,
+	"CreateMultipartUpload": true,
+}
+
+// WithObjectTagging tags uploads with the configured tags, so objects
+// written through the gateway can be attributed without its audit log
+func WithObjectTagging(cfg *config.ObjectTaggingConfig) Option {
+	return func(g *Gateway) {
+		g.autoTags = cfg.Tags
+	}
+}
+
+// addAutoTags adds the configured tags to an upload, expanding {clientId},
+// {tenantId} and {requestId}. They replace client tags with the same keys,
+// so clients cannot misattribute their uploads.
+func (g *Gateway) addAutoTags(rc *RequestContext, s3req *S3Request) {
+	if len(g.autoTags) == 0 || !autoTagOperations[s3req.Operation] {
+		return
+	}
+	expand := strings.NewReplacer(
+		"{clientId}", rc.ClientID(),
+		"{tenantId}", rc.TenantID(),
+		"{requestId}", rc.RequestID,
+	)
+	tags := make(map[string]string, len(g.autoTags))
+	for key, value := range g.autoTags {
+		tags[key] = expand.Replace(value)
+	}
+	addObjectTags(s3req.Headers, tags)
+}
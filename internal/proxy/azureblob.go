@@ -0,0 +1,482 @@
+package proxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+// azureAPIVersion is the Blob service REST API version the backend speaks
+const azureAPIVersion = "2021-08-06"
+
+// AzureBlobBackend translates S3 object operations to Azure Blob Storage,
+// mapping each S3 bucket to a container and each key to a block blob
+type AzureBlobBackend struct {
+	httpClient *http.Client
+	endpoint   *url.URL
+	account    string
+	key        []byte
+	containers map[string]string // S3 bucket -> container
+}
+
+// NewAzureBlobBackend creates a backend from configuration
+func NewAzureBlobBackend(cfg *config.AzureBackendConfig, httpClient *http.Client) (*AzureBlobBackend, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.AccountKey)
+	if err != nil {
+		return nil, fmt.Errorf("azure accountKey must be base64: %w", err)
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.AccountName)
+	}
+	endpointURL, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid azure endpoint: %w", err)
+	}
+
+	return &AzureBlobBackend{
+		httpClient: httpClient,
+		endpoint:   endpointURL,
+		account:    cfg.AccountName,
+		key:        key,
+		containers: cfg.Containers,
+	}, nil
+}
+
+// Forward executes the request against Azure Blob Storage
+func (b *AzureBlobBackend) Forward(ctx context.Context, req *S3Request) (*S3Response, error) {
+	switch req.Operation {
+	case "GetObject":
+		return b.getBlob(ctx, req, http.MethodGet)
+	case "HeadObject":
+		return b.getBlob(ctx, req, http.MethodHead)
+	case "PutObject":
+		return b.putBlob(ctx, req)
+	case "DeleteObject":
+		return b.deleteBlob(ctx, req)
+	case "ListObjects", "ListObjectsV2":
+		return b.listBlobs(ctx, req)
+	case "HeadBucket":
+		return b.headContainer(ctx, req)
+	default:
+		return nil, errNotImplemented(req.Operation)
+	}
+}
+
+// container returns the container mapped to a bucket; unmapped buckets use
+// a container of the same name
+func (b *AzureBlobBackend) container(bucket string) string {
+	if c, ok := b.containers[bucket]; ok {
+		return c
+	}
+	return bucket
+}
+
+func (b *AzureBlobBackend) getBlob(ctx context.Context, req *S3Request, method string) (*S3Response, error) {
+	httpReq, err := b.newRequest(ctx, method, req.Bucket, req.Key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if v := req.Headers.Get(h); v != "" {
+			httpReq.Header.Set(h, v)
+		}
+	}
+	if v := req.Headers.Get("Range"); v != "" {
+		httpReq.Header.Set("x-ms-range", v)
+	}
+
+	resp, err := b.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := blobHeaders(resp.Header)
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return &S3Response{StatusCode: http.StatusNotModified, Headers: headers}, nil
+	}
+	if method == http.MethodHead {
+		resp.Body.Close()
+		return &S3Response{StatusCode: http.StatusOK, Headers: headers, ContentLength: resp.ContentLength}, nil
+	}
+
+	return &S3Response{
+		StatusCode:    resp.StatusCode,
+		Headers:       headers,
+		Body:          resp.Body,
+		ContentLength: resp.ContentLength,
+	}, nil
+}
+
+func (b *AzureBlobBackend) putBlob(ctx context.Context, req *S3Request) (*S3Response, error) {
+	if req.ContentLength < 0 {
+		return nil, backendError(errors.ErrMissingContentLength)
+	}
+
+	body := req.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	headers := http.Header{}
+	headers.Set("x-ms-blob-type", "BlockBlob")
+	for _, h := range []string{"If-Match", "If-None-Match"} {
+		if v := req.Headers.Get(h); v != "" {
+			headers.Set(h, v)
+		}
+	}
+	for s3Header, azureHeader := range map[string]string{
+		"Content-Type":     "x-ms-blob-content-type",
+		"Content-Encoding": "x-ms-blob-content-encoding",
+		"Cache-Control":    "x-ms-blob-cache-control",
+		"Content-MD5":      "x-ms-blob-content-md5",
+	} {
+		if v := req.Headers.Get(s3Header); v != "" {
+			headers.Set(azureHeader, v)
+		}
+	}
+
+	httpReq, err := b.newRequest(ctx, http.MethodPut, req.Bucket, req.Key, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Body = body
+	httpReq.ContentLength = req.ContentLength
+
+	resp, err := b.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	out := make(http.Header)
+	out.Set("ETag", blobETag(resp.Header))
+	return &S3Response{StatusCode: http.StatusOK, Headers: out}, nil
+}
+
+func (b *AzureBlobBackend) deleteBlob(ctx context.Context, req *S3Request) (*S3Response, error) {
+	httpReq, err := b.newRequest(ctx, http.MethodDelete, req.Bucket, req.Key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.do(httpReq)
+	if err != nil {
+		// Deleting a missing key succeeds, as in S3
+		if backendErr, ok := err.(*BackendError); !ok || backendErr.Code != "NoSuchKey" {
+			return nil, err
+		}
+	} else {
+		resp.Body.Close()
+	}
+
+	return &S3Response{StatusCode: http.StatusNoContent, Headers: make(http.Header)}, nil
+}
+
+// azureListResult is the subset of the List Blobs response the backend uses
+type azureListResult struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string `xml:"Name"`
+			Properties struct {
+				LastModified  string `xml:"Last-Modified"`
+				ETag          string `xml:"Etag"`
+				ContentLength int64  `xml:"Content-Length"`
+				ContentMD5    string `xml:"Content-MD5"`
+			} `xml:"Properties"`
+		} `xml:"Blob"`
+		BlobPrefix []struct {
+			Name string `xml:"Name"`
+		} `xml:"BlobPrefix"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (b *AzureBlobBackend) listBlobs(ctx context.Context, req *S3Request) (*S3Response, error) {
+	query := url.Values{}
+	query.Set("restype", "container")
+	query.Set("comp", "list")
+	prefix := req.QueryParams.Get("prefix")
+	delimiter := req.QueryParams.Get("delimiter")
+	startAfter := req.QueryParams.Get("start-after")
+	token := req.QueryParams.Get("continuation-token")
+	maxKeys, err := parseMaxKeys(req.QueryParams.Get("max-keys"))
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	query.Set("maxresults", strconv.Itoa(int(maxKeys)))
+	if token != "" {
+		query.Set("marker", token)
+	}
+
+	httpReq, err := b.newRequest(ctx, http.MethodGet, req.Bucket, "", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result azureListResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode azure list response: %w", err)
+	}
+
+	output := &s3.ListObjectsV2Output{
+		Name:        aws.String(req.Bucket),
+		Prefix:      aws.String(prefix),
+		MaxKeys:     aws.Int32(maxKeys),
+		IsTruncated: aws.Bool(result.NextMarker != ""),
+	}
+	if delimiter != "" {
+		output.Delimiter = aws.String(delimiter)
+	}
+	if result.NextMarker != "" {
+		output.NextContinuationToken = aws.String(result.NextMarker)
+	}
+	if token != "" {
+		output.ContinuationToken = aws.String(token)
+	}
+	if startAfter != "" {
+		output.StartAfter = aws.String(startAfter)
+	}
+	// Azure has no start-after, so entries up to it are dropped here
+	for _, blob := range result.Blobs.Blob {
+		if blob.Name <= startAfter {
+			continue
+		}
+		etag := blob.Properties.ETag
+		if md5Hex := md5ToHex(blob.Properties.ContentMD5); md5Hex != "" {
+			etag = `"` + md5Hex + `"`
+		}
+		obj := types.Object{
+			Key:  aws.String(blob.Name),
+			Size: aws.Int64(blob.Properties.ContentLength),
+			ETag: aws.String(etag),
+		}
+		if t, err := http.ParseTime(blob.Properties.LastModified); err == nil {
+			obj.LastModified = aws.Time(t)
+		}
+		output.Contents = append(output.Contents, obj)
+	}
+	for _, p := range result.Blobs.BlobPrefix {
+		if p.Name <= startAfter {
+			continue
+		}
+		output.CommonPrefixes = append(output.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(p.Name)})
+	}
+	output.KeyCount = aws.Int32(int32(len(output.Contents) + len(output.CommonPrefixes)))
+
+	return listResponse(req, output)
+}
+
+// headContainer checks the bucket's container exists with Get Container
+// Properties
+func (b *AzureBlobBackend) headContainer(ctx context.Context, req *S3Request) (*S3Response, error) {
+	query := url.Values{}
+	query.Set("restype", "container")
+	httpReq, err := b.newRequest(ctx, http.MethodHead, req.Bucket, "", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &S3Response{StatusCode: http.StatusOK, Headers: blobHeaders(resp.Header)}, nil
+}
+
+// newRequest builds a signed-ready request for a container or blob
+func (b *AzureBlobBackend) newRequest(ctx context.Context, method, bucket, key string, query url.Values, headers http.Header) (*http.Request, error) {
+	u := *b.endpoint
+	u.Path = u.Path + "/" + b.container(bucket)
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = query.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build azure request: %w", err)
+	}
+	for k, v := range headers {
+		httpReq.Header[k] = v
+	}
+	return httpReq, nil
+}
+
+// do signs and sends a request, converting Azure errors to S3 errors
+func (b *AzureBlobBackend) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	if id := correlationFrom(req.Context()).ID(); id != "" {
+		req.Header.Set(headerAzureClientRequestID, id)
+	}
+	req.Header.Set("Authorization", "SharedKey "+b.account+":"+b.sign(req))
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 400 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	var azureErr struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&azureErr)
+	code := azureErr.Code
+	if code == "" {
+		code = resp.Header.Get("x-ms-error-code")
+	}
+	return nil, azureToS3Error(resp.StatusCode, code, azureErr.Message)
+}
+
+// sign computes the SharedKey signature for a request
+func (b *AzureBlobBackend) sign(req *http.Request) string {
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write([]byte(azureStringToSign(req, b.account)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// azureStringToSign builds the SharedKey string-to-sign for a Blob request
+func azureStringToSign(req *http.Request, account string) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var msHeaders []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			msHeaders = append(msHeaders, lower)
+		}
+	}
+	sort.Strings(msHeaders)
+	var canonicalHeaders strings.Builder
+	for _, name := range msHeaders {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	canonicalResource := "/" + account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		canonicalResource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date is sent as x-ms-date
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonicalHeaders.String() + canonicalResource,
+	}, "\n")
+}
+
+// azureToS3Error maps Azure Blob error codes onto the S3 codes clients expect
+func azureToS3Error(status int, code, message string) *BackendError {
+	switch code {
+	case "BlobNotFound":
+		return errNoSuchKey()
+	case "ContainerNotFound":
+		return errNoSuchBucket()
+	case "ConditionNotMet", "BlobAlreadyExists":
+		return backendError(errors.ErrPreconditionFailed)
+	case "InvalidRange":
+		return backendError(errors.ErrInvalidRange)
+	}
+
+	switch {
+	case status == http.StatusNotFound:
+		return errNoSuchKey()
+	case status == http.StatusForbidden:
+		return backendError(errors.ErrAccessDenied.WithMessage("The storage backend denied the request"))
+	case status == http.StatusServiceUnavailable:
+		return backendError(errors.ErrSlowDown)
+	case status < 500:
+		return &BackendError{StatusCode: status, Code: errors.ErrInvalidRequest.Code, Message: message}
+	default:
+		return backendError(errors.ErrInternalError)
+	}
+}
+
+// blobHeaders converts Azure blob response headers to S3 object headers
+func blobHeaders(h http.Header) http.Header {
+	headers := make(http.Header)
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Range", "Content-Encoding",
+		"Cache-Control", "Last-Modified", "Accept-Ranges"} {
+		if v := h.Get(name); v != "" {
+			headers.Set(name, v)
+		}
+	}
+	headers.Set("ETag", blobETag(h))
+	return headers
+}
+
+// blobETag prefers the MD5-based ETag S3 clients expect, falling back to
+// Azure's opaque ETag when the blob has no stored MD5
+func blobETag(h http.Header) string {
+	if md5Hex := md5ToHex(h.Get("Content-MD5")); md5Hex != "" {
+		return `"` + md5Hex + `"`
+	}
+	if md5Hex := md5ToHex(h.Get("x-ms-blob-content-md5")); md5Hex != "" {
+		return `"` + md5Hex + `"`
+	}
+	return h.Get("ETag")
+}
+
+func md5ToHex(b64 string) string {
+	if b64 == "" {
+		return ""
+	}
+	raw, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil || len(raw) != 16 {
+		return ""
+	}
+	return hex.EncodeToString(raw)
+}
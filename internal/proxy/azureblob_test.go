@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// fakeAzure is a minimal Blob service holding blobs for one container
+type fakeAzure struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	auths []string
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auths = append(f.auths, r.Header.Get("Authorization"))
+
+	container, blob, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if container != "mapped-container" {
+		w.Header().Set("x-ms-error-code", "ContainerNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[blob] = data
+		sum := md5.Sum(data)
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodHead && blob == "" && r.URL.Query().Get("restype") == "container":
+		w.Header().Set("ETag", "0x1")
+	case r.Method == http.MethodGet && r.URL.Query().Get("comp") == "list":
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+		for name, data := range f.blobs {
+			fmt.Fprintf(w, `<Blob><Name>%s</Name><Properties><Content-Length>%d</Content-Length><Etag>0x1</Etag></Properties></Blob>`, name, len(data))
+		}
+		fmt.Fprint(w, `</Blobs><NextMarker/></EnumerationResults>`)
+	case r.Method == http.MethodGet:
+		data, ok := f.blobs[blob]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>BlobNotFound</Code><Message>missing</Message></Error>`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.blobs[blob]; !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.blobs, blob)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func newTestAzureBackend(t *testing.T) (*AzureBlobBackend, *fakeAzure) {
+	t.Helper()
+	fake := &fakeAzure{blobs: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	backend, err := NewAzureBlobBackend(&config.AzureBackendConfig{
+		AccountName: "devaccount",
+		AccountKey:  base64.StdEncoding.EncodeToString([]byte("secret")),
+		Endpoint:    server.URL,
+		Containers:  map[string]string{"tenant-001-data": "mapped-container"},
+	}, server.Client())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return backend, fake
+}
+
+func TestAzureBlobBackend_PutGetListDelete(t *testing.T) {
+	backend, fake := newTestAzureBackend(t)
+	ctx := context.Background()
+
+	put := fsRequest("PUT", "s3:PutObject", "tenant-001-data", "docs/a.txt", "hello")
+	resp, err := backend.Forward(ctx, put)
+	if err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	sum := md5.Sum([]byte("hello"))
+	if want := fmt.Sprintf(`"%x"`, sum); resp.Headers.Get("ETag") != want {
+		t.Errorf("ETag = %q, want %q", resp.Headers.Get("ETag"), want)
+	}
+
+	resp, err = backend.Forward(ctx, fsRequest("GET", "s3:GetObject", "tenant-001-data", "docs/a.txt", ""))
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want hello", body)
+	}
+
+	resp, err = backend.Forward(ctx, fsRequest("GET", "s3:ListBucket", "tenant-001-data", "", ""))
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "<Key>docs/a.txt</Key>") {
+		t.Errorf("listing missing key: %s", body)
+	}
+
+	for i := 0; i < 2; i++ {
+		// The second delete targets a missing blob and must still succeed
+		resp, err = backend.Forward(ctx, fsRequest("DELETE", "s3:DeleteObject", "tenant-001-data", "docs/a.txt", ""))
+		if err != nil || resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("delete %d: status %v err %v", i, resp, err)
+		}
+	}
+
+	for _, auth := range fake.auths {
+		if !strings.HasPrefix(auth, "SharedKey devaccount:") {
+			t.Errorf("unexpected Authorization header %q", auth)
+		}
+	}
+}
+
+func TestAzureBlobBackend_ErrorMapping(t *testing.T) {
+	backend, _ := newTestAzureBackend(t)
+	ctx := context.Background()
+
+	_, err := backend.Forward(ctx, fsRequest("GET", "s3:GetObject", "tenant-001-data", "missing.txt", ""))
+	var backendErr *BackendError
+	if !errors.As(err, &backendErr) || backendErr.Code != "NoSuchKey" {
+		t.Errorf("expected NoSuchKey, got %v", err)
+	}
+
+	_, err = backend.Forward(ctx, fsRequest("GET", "s3:GetObject", "unmapped-bucket", "file.txt", ""))
+	if !errors.As(err, &backendErr) || backendErr.Code != "NoSuchBucket" {
+		t.Errorf("expected NoSuchBucket, got %v", err)
+	}
+
+	if resp, err := backend.Forward(ctx, fsRequest("HEAD", "s3:ListBucket", "tenant-001-data", "", "")); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("HeadBucket = %+v, %v; want 200", resp, err)
+	}
+	_, err = backend.Forward(ctx, fsRequest("HEAD", "s3:ListBucket", "unmapped-bucket", "", ""))
+	if !errors.As(err, &backendErr) || backendErr.Code != "NoSuchBucket" {
+		t.Errorf("HeadBucket of an unmapped bucket: expected NoSuchBucket, got %v", err)
+	}
+}
+
+func TestAzureStringToSign(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://acct.blob.core.windows.net/container?restype=container&comp=list&prefix=logs", nil)
+	req.Header.Set("x-ms-version", "2021-08-06")
+	req.Header.Set("x-ms-date", "Mon, 01 Jan 2024 00:00:00 GMT")
+
+	want := "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+		"x-ms-date:Mon, 01 Jan 2024 00:00:00 GMT\n" +
+		"x-ms-version:2021-08-06\n" +
+		"/acct/container\ncomp:list\nprefix:logs\nrestype:container"
+
+	if got := azureStringToSign(req, "acct"); got != want {
+		t.Errorf("string to sign mismatch:\n%q\nwant:\n%q", got, want)
+	}
+}
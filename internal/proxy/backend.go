@@ -0,0 +1,273 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+// Forwarder executes an authorized S3 request against a storage backend
+type Forwarder interface {
+	Forward(ctx context.Context, req *S3Request) (*S3Response, error)
+}
+
+// ForwarderFunc adapts a function to a Forwarder, e.g. to stub upstream
+// responses and failures in tests
+type ForwarderFunc func(ctx context.Context, req *S3Request) (*S3Response, error)
+
+// Forward implements Forwarder
+func (f ForwarderFunc) Forward(ctx context.Context, req *S3Request) (*S3Response, error) {
+	return f(ctx, req)
+}
+
+// BackendError is an S3-style error returned by non-S3 backends
+type BackendError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Headers    http.Header // extra response headers, e.g. x-amz-bucket-region
+}
+
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// backendError returns the error for a catalog code
+func backendError(c errors.ErrorCode) *BackendError {
+	return &BackendError{StatusCode: c.StatusCode, Code: c.Code, Message: c.Message}
+}
+
+func errNoSuchKey() *BackendError {
+	return backendError(errors.ErrNoSuchKey)
+}
+
+func errNoSuchBucket() *BackendError {
+	return backendError(errors.ErrNoSuchBucket)
+}
+
+func errInvalidArgument(message string) *BackendError {
+	return backendError(errors.ErrInvalidArgument.WithMessage(message))
+}
+
+func errNotImplemented(action string) *BackendError {
+	return backendError(errors.ErrNotImplemented.WithMessage(action + " is not supported by this backend"))
+}
+
+// objectInfo is the metadata local backends keep for an object
+type objectInfo struct {
+	Key             string    `json:"key"`
+	Size            int64     `json:"size"`
+	ETag            string    `json:"etag"`
+	LastModified    time.Time `json:"lastModified"`
+	ContentType     string    `json:"contentType,omitempty"`
+	ContentEncoding string    `json:"contentEncoding,omitempty"`
+	CacheControl    string    `json:"cacheControl,omitempty"`
+	StorageClass    string    `json:"storageClass,omitempty"`
+}
+
+// headers returns the response headers describing the object
+func (o *objectInfo) headers() http.Header {
+	headers := make(http.Header)
+	contentType := o.ContentType
+	if contentType == "" {
+		contentType = "binary/octet-stream"
+	}
+	headers.Set("Content-Type", contentType)
+	headers.Set("Content-Length", strconv.FormatInt(o.Size, 10))
+	headers.Set("ETag", o.ETag)
+	headers.Set("Last-Modified", o.LastModified.UTC().Format(http.TimeFormat))
+	headers.Set("Accept-Ranges", "bytes")
+	if o.ContentEncoding != "" {
+		headers.Set("Content-Encoding", o.ContentEncoding)
+	}
+	if o.CacheControl != "" {
+		headers.Set("Cache-Control", o.CacheControl)
+	}
+	if o.StorageClass != "" && o.StorageClass != "STANDARD" {
+		headers.Set("x-amz-storage-class", o.StorageClass) // omitted for STANDARD, as S3 does
+	}
+	return headers
+}
+
+// checkPreconditions evaluates If-Match/If-None-Match against an ETag and
+// returns the status to short-circuit with, or 0 to proceed
+func checkPreconditions(headers http.Header, etag string) int {
+	if v := headers.Get("If-Match"); v != "" && v != "*" && v != etag {
+		return http.StatusPreconditionFailed
+	}
+	if v := headers.Get("If-None-Match"); v != "" && (v == "*" || v == etag) {
+		return http.StatusNotModified
+	}
+	return 0
+}
+
+// checkWritePreconditions evaluates the If-None-Match and If-Match headers of
+// a write against the ETag of the object it replaces, "" when there is none.
+// As in S3, If-None-Match only takes "*", creating the object if absent.
+func checkWritePreconditions(headers http.Header, etag string) error {
+	if v := headers.Get("If-None-Match"); v != "" {
+		if v != "*" {
+			return backendError(errors.ErrNotImplemented.WithMessage("If-None-Match on writes only supports *."))
+		}
+		if etag != "" {
+			return backendError(errors.ErrPreconditionFailed)
+		}
+	}
+	if v := headers.Get("If-Match"); v != "" {
+		if etag == "" {
+			return errNoSuchKey()
+		}
+		if v != "*" && v != etag {
+			return backendError(errors.ErrPreconditionFailed)
+		}
+	}
+	return nil
+}
+
+// parseRange parses a single "bytes=" range against an object size,
+// returning ok=false when the header is absent
+func parseRange(header string, size int64) (start, end int64, ok bool, err error) {
+	if header == "" {
+		return 0, 0, false, nil
+	}
+
+	invalid := backendError(errors.ErrInvalidRange)
+
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, invalid
+	}
+	from, to, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false, invalid
+	}
+
+	switch {
+	case from == "":
+		// Suffix range: last N bytes
+		n, perr := strconv.ParseInt(to, 10, 64)
+		if perr != nil || n <= 0 {
+			return 0, 0, false, invalid
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	default:
+		s, perr := strconv.ParseInt(from, 10, 64)
+		if perr != nil || s >= size {
+			return 0, 0, false, invalid
+		}
+		start, end = s, size-1
+		if to != "" {
+			e, perr := strconv.ParseInt(to, 10, 64)
+			if perr != nil || e < s {
+				return 0, 0, false, invalid
+			}
+			if e < end {
+				end = e
+			}
+		}
+	}
+
+	return start, end, true, nil
+}
+
+// listObjectsV2 applies ListObjectsV2 semantics (prefix, delimiter,
+// start-after, pagination) to a set of objects held by a local backend
+func listObjectsV2(bucket string, objects []objectInfo, query url.Values) (*s3.ListObjectsV2Output, error) {
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	maxKeys, err := parseMaxKeys(query.Get("max-keys"))
+	if err != nil {
+		return nil, err
+	}
+	startAfter := query.Get("start-after")
+	token := query.Get("continuation-token")
+	after := startAfter
+	if token != "" {
+		after = token
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	output := &s3.ListObjectsV2Output{
+		Name:    aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(maxKeys),
+	}
+	if delimiter != "" {
+		output.Delimiter = aws.String(delimiter)
+	}
+	if startAfter != "" {
+		output.StartAfter = aws.String(startAfter)
+	}
+	if token != "" {
+		output.ContinuationToken = aws.String(token)
+	}
+
+	seenPrefixes := make(map[string]bool)
+	var count int32
+	var lastKey string
+	truncated := false
+	for _, obj := range objects {
+		if !strings.HasPrefix(obj.Key, prefix) || obj.Key <= after {
+			continue
+		}
+
+		// Group keys sharing a prefix up to the delimiter
+		commonPrefix := ""
+		if delimiter != "" {
+			if idx := strings.Index(obj.Key[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix = obj.Key[:len(prefix)+idx+len(delimiter)]
+			}
+		}
+		if commonPrefix != "" && seenPrefixes[commonPrefix] {
+			continue
+		}
+		if commonPrefix != "" && commonPrefix == after {
+			continue // continuation token pointing at a common prefix
+		}
+
+		if count >= maxKeys {
+			// Like S3, max-keys=0 is never truncated: there is no key
+			// to continue from
+			truncated = maxKeys > 0
+			break
+		}
+		count++
+
+		if commonPrefix != "" {
+			seenPrefixes[commonPrefix] = true
+			output.CommonPrefixes = append(output.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(commonPrefix)})
+			lastKey = commonPrefix
+			continue
+		}
+
+		output.Contents = append(output.Contents, types.Object{
+			Key:          aws.String(obj.Key),
+			Size:         aws.Int64(obj.Size),
+			ETag:         aws.String(obj.ETag),
+			LastModified: aws.Time(obj.LastModified),
+			StorageClass: types.ObjectStorageClass(obj.StorageClass),
+		})
+		lastKey = obj.Key
+	}
+
+	output.IsTruncated = aws.Bool(truncated)
+	output.KeyCount = aws.Int32(count)
+	if truncated {
+		output.NextContinuationToken = aws.String(lastKey)
+	}
+	return output, nil
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantStart int64
+		wantEnd   int64
+		wantOK    bool
+		wantErr   bool
+	}{
+		{"", 0, 0, false, false},
+		{"bytes=0-4", 0, 4, true, false},
+		{"bytes=5-", 5, 9, true, false},
+		{"bytes=-3", 7, 9, true, false},
+		{"bytes=8-100", 8, 9, true, false},
+		{"bytes=10-", 0, 0, false, true},
+		{"bytes=5-2", 0, 0, false, true},
+		{"bytes=0-1,3-4", 0, 0, false, true},
+		{"items=0-1", 0, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			start, end, ok, err := parseRange(tt.header, 10)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("got (%d, %d, %v), want (%d, %d, %v)", start, end, ok, tt.wantStart, tt.wantEnd, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestListObjectsV2_Pagination(t *testing.T) {
+	now := time.Now()
+	objects := []objectInfo{
+		{Key: "c", LastModified: now},
+		{Key: "a", LastModified: now},
+		{Key: "dir/1", LastModified: now},
+		{Key: "dir/2", LastModified: now},
+		{Key: "b", LastModified: now},
+	}
+
+	var keys []string
+	query := url.Values{"max-keys": {"2"}, "delimiter": {"/"}}
+	for page := 0; page < 5; page++ {
+		output, err := listObjectsV2("bucket", objects, query)
+		if err != nil {
+			t.Fatalf("listObjectsV2: %v", err)
+		}
+		for _, obj := range output.Contents {
+			keys = append(keys, *obj.Key)
+		}
+		for _, p := range output.CommonPrefixes {
+			keys = append(keys, *p.Prefix)
+		}
+		if !*output.IsTruncated {
+			break
+		}
+		query.Set("continuation-token", *output.NextContinuationToken)
+	}
+
+	want := []string{"a", "b", "c", "dir/"}
+	if len(keys) != len(want) {
+		t.Fatalf("keys = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("keys = %v, want %v", keys, want)
+			break
+		}
+	}
+}
+
+func TestListObjectsV2_Prefix(t *testing.T) {
+	objects := []objectInfo{{Key: "logs/a"}, {Key: "logs/b"}, {Key: "data/c"}}
+
+	output, err := listObjectsV2("bucket", objects, url.Values{"prefix": {"logs/"}})
+	if err != nil {
+		t.Fatalf("listObjectsV2: %v", err)
+	}
+	if *output.KeyCount != 2 {
+		t.Errorf("KeyCount = %d, want 2", *output.KeyCount)
+	}
+	if *output.IsTruncated {
+		t.Error("expected listing not to be truncated")
+	}
+}
+
+func TestListObjectsV2_ZeroMaxKeys(t *testing.T) {
+	objects := []objectInfo{{Key: "a"}, {Key: "b"}}
+
+	output, err := listObjectsV2("bucket", objects, url.Values{"max-keys": {"0"}})
+	if err != nil {
+		t.Fatalf("listObjectsV2: %v", err)
+	}
+	if *output.KeyCount != 0 || len(output.Contents) != 0 {
+		t.Errorf("KeyCount = %d, Contents = %d, want none", *output.KeyCount, len(output.Contents))
+	}
+	if *output.IsTruncated {
+		t.Error("expected max-keys=0 not to be truncated")
+	}
+	if output.NextContinuationToken != nil {
+		t.Errorf("NextContinuationToken = %q, want none", *output.NextContinuationToken)
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// bucketNameRegex is the S3 general purpose bucket naming rule: 3-63
+// lowercase letters, digits, dots and hyphens, starting and ending with a
+// letter or digit
+var bucketNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// WithBucketNaming requires buckets created through the gateway to follow the
+// configured naming convention
+func WithBucketNaming(cfg *config.BucketsConfig) Option {
+	return func(g *Gateway) {
+		g.buckets = cfg
+	}
+}
+
+// validBucketName reports whether name may be used for a new S3 bucket
+func validBucketName(name string) bool {
+	if isDirectoryBucket(name) {
+		return validDirectoryBucketName(name)
+	}
+	if !bucketNameRegex.MatchString(name) || strings.Contains(name, "..") {
+		return false
+	}
+	if net.ParseIP(name) != nil {
+		return false // formatted as an IP address
+	}
+	for _, prefix := range []string{"xn--", "sthree-", "amzn-s3-demo-"} {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	for _, suffix := range []string{"-s3alias", "--ol-s3", ".mrap", "--x-s3"} {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// bucketNameAllowed reports whether a tenant may create a bucket named name
+func (g *Gateway) bucketNameAllowed(name, tenantID string) bool {
+	if !validBucketName(name) {
+		return false
+	}
+	if g.buckets == nil {
+		return true
+	}
+	re, err := g.buckets.NameRegexp(tenantID)
+	if err != nil {
+		return false // validated at load, so only reachable with odd tenant IDs
+	}
+	return re == nil || re.MatchString(name)
+}
+
+// clampListing caps the max-keys of a listing at the tenant's limit, setting
+// it when the client sent none, so large listings must be paginated and the
+// response reports the page size actually used. Invalid values are left for
+// the backend to reject.
+func (g *Gateway) clampListing(s3req *S3Request, tenantID string) {
+	if g.buckets == nil || s3req.Action != "s3:ListBucket" || s3req.HTTPMethod != http.MethodGet {
+		return
+	}
+	limit := g.buckets.MaxListKeys(tenantID)
+	if limit == 0 {
+		return
+	}
+	if v := s3req.QueryParams.Get("max-keys"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 0 || n <= int64(limit) {
+			return
+		}
+	}
+	s3req.QueryParams.Set("max-keys", strconv.Itoa(limit))
+}
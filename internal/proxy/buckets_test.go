@@ -0,0 +1,32 @@
+package proxy
+
+import "testing"
+
+func TestValidBucketName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"tenant-001-data", true},
+		{"a.b.c", true},
+		{"abc", true},
+		{"ab", false},
+		{"Tenant-001", false},
+		{"tenant_001", false},
+		{"-tenant", false},
+		{"tenant-", false},
+		{"a..b", false},
+		{"192.168.5.4", false},
+		{"xn--tenant", false},
+		{"tenant-s3alias", false},
+		{"tenant--x-s3", false},
+		{"tenant-001-fast--use1-az4--x-s3", true},
+		{"Tenant--use1-az4--x-s3", false},
+		{"a123456789012345678901234567890123456789012345678901234567890123", false},
+	}
+	for _, tt := range tests {
+		if got := validBucketName(tt.name); got != tt.want {
+			t.Errorf("validBucketName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
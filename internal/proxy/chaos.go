@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/internal/metrics"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+var chaosFaults = metrics.NewCounter("gateway_chaos_faults_total",
+	"Faults injected by chaos mode.", "action", "fault")
+
+// Faults injected by chaos mode
+const (
+	faultLatency = "latency"
+	faultError   = "error"
+	faultReset   = "reset"
+)
+
+// chaosInjector adds latency, 500 errors and connection resets to authorized
+// requests at configured rates, for testing client retry behavior
+type chaosInjector struct {
+	rules []config.ChaosRule
+	roll  func() float64 // uniform in [0, 1)
+}
+
+// WithChaos enables fault injection. Never enable it in production.
+func WithChaos(cfg *config.ChaosConfig) Option {
+	return func(g *Gateway) {
+		g.chaos = &chaosInjector{rules: cfg.Rules, roll: rand.Float64}
+	}
+}
+
+// rule returns the first rule matching action, or nil
+func (c *chaosInjector) rule(action string) *config.ChaosRule {
+	for i := range c.rules {
+		if policy.MatchAction(action, c.rules[i].Actions) {
+			return &c.rules[i]
+		}
+	}
+	return nil
+}
+
+// inject applies the faults drawn for a request. It returns the fault that
+// ends the request, or "" when the request should proceed. An error has
+// been written by then; for a reset the caller drops the connection with
+// resetConnection once the request is audited.
+func (c *chaosInjector) inject(w http.ResponseWriter, r *http.Request, s3req *S3Request, requestID string) string {
+	rule := c.rule(s3req.Action)
+	if rule == nil {
+		return ""
+	}
+
+	if rule.Latency > 0 && c.roll() < rule.LatencyRate {
+		chaosFaults.Inc(s3req.Action, faultLatency)
+		timer := time.NewTimer(rule.Latency)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+		}
+	}
+
+	switch n := c.roll(); {
+	case n < rule.ErrorRate:
+		chaosFaults.Inc(s3req.Action, faultError)
+		log.Printf("[%s] Chaos: injected 500 for %s", requestID, s3req.Action)
+		errors.WriteS3ErrorCode(w, errors.ErrInternalError, requestID)
+		return faultError
+	case n < rule.ErrorRate+rule.ResetRate:
+		chaosFaults.Inc(s3req.Action, faultReset)
+		log.Printf("[%s] Chaos: resetting connection for %s", requestID, s3req.Action)
+		return faultReset
+	}
+	return ""
+}
+
+// resetConnection drops the client connection without a response, with an
+// RST where the connection can be hijacked. HTTP/2 streams are aborted.
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
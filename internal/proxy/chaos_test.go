@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestChaosInjector_Inject(t *testing.T) {
+	c := &chaosInjector{rules: []config.ChaosRule{
+		{Actions: []string{"s3:GetObject"}, Latency: 20 * time.Millisecond, LatencyRate: 0.5, ErrorRate: 0.2, ResetRate: 0.1},
+		{Actions: []string{"s3:*"}, ErrorRate: 0.5},
+	}}
+
+	tests := []struct {
+		action      string
+		rolls       []float64 // latency roll (when the rule has latency), then fault roll
+		want        string
+		wantStatus  int
+		wantLatency bool
+	}{
+		{"s3:GetObject", []float64{0.9, 0.9}, "", 0, false},
+		{"s3:GetObject", []float64{0.1, 0.9}, "", 0, true},
+		{"s3:GetObject", []float64{0.9, 0.1}, faultError, http.StatusInternalServerError, false},
+		{"s3:GetObject", []float64{0.9, 0.25}, faultReset, 0, false},
+		{"s3:PutObject", []float64{0.4}, faultError, http.StatusInternalServerError, false},
+		{"s3:PutObject", []float64{0.6}, "", 0, false},
+	}
+	for _, tt := range tests {
+		rolls := tt.rolls
+		c.roll = func() float64 {
+			n := rolls[0]
+			rolls = rolls[1:]
+			return n
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+
+		start := time.Now()
+		got := c.inject(w, r, &S3Request{Action: tt.action}, "req-1")
+		elapsed := time.Since(start)
+
+		if got != tt.want {
+			t.Errorf("%s %v: fault = %q, want %q", tt.action, tt.rolls, got, tt.want)
+		}
+		if tt.wantStatus != 0 && w.Code != tt.wantStatus {
+			t.Errorf("%s %v: status = %d, want %d", tt.action, tt.rolls, w.Code, tt.wantStatus)
+		}
+		if tt.wantLatency != (elapsed >= 20*time.Millisecond) {
+			t.Errorf("%s %v: took %s, latency expected %v", tt.action, tt.rolls, elapsed, tt.wantLatency)
+		}
+		if len(rolls) != 0 {
+			t.Errorf("%s %v: %d rolls unused", tt.action, tt.rolls, len(rolls))
+		}
+	}
+
+	if rule := (&chaosInjector{rules: c.rules[:1]}).rule("s3:ListBucket"); rule != nil {
+		t.Errorf("expected no rule for unmatched action, got %+v", rule)
+	}
+}
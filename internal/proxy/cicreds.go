@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/internal/oidc"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// ActionIssueCICredentials is the audit action of credential exchanges
+const ActionIssueCICredentials = "gateway:IssueCICredentials"
+
+// maxExchangeBody bounds exchange requests; OIDC tokens are a few KiB
+const maxExchangeBody = 64 << 10
+
+type ciCredentials struct {
+	cfg       *config.CICredentialsConfig
+	key       []byte
+	verifiers map[string]*oidc.Verifier // by provider name
+}
+
+// WithCICredentials serves the exchange of CI jobs' OIDC tokens for
+// short-lived credentials at cfg.Path, and accepts the issued credentials
+func WithCICredentials(cfg *config.CICredentialsConfig) Option {
+	return func(g *Gateway) {
+		c := &ciCredentials{cfg: cfg, key: []byte(cfg.SigningKey), verifiers: make(map[string]*oidc.Verifier)}
+		for _, p := range cfg.Providers {
+			c.verifiers[p.Name] = oidc.NewVerifier(p.Issuer, p.Audience, p.JWKSURL, nil)
+		}
+		g.ci = c
+	}
+}
+
+// CICredentialsRequest is the body of an exchange request
+type CICredentialsRequest struct {
+	Role  string `json:"role"`
+	Token string `json:"token"` // the CI job's OIDC token
+}
+
+// CICredentialsResponse holds issued credentials in the format of the AWS
+// SDKs' credential_process, with the bucket and prefix they are limited to
+type CICredentialsResponse struct {
+	Version         int       `json:"Version"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"SessionToken"`
+	Expiration      time.Time `json:"Expiration"`
+	Bucket          string    `json:"Bucket"`
+	Prefix          string    `json:"Prefix"`
+}
+
+// serveCICredentials exchanges an OIDC token for credentials of the
+// requested role. Every exchange is audited, whether it succeeds or not.
+func (g *Gateway) serveCICredentials(w http.ResponseWriter, r *http.Request, rc *RequestContext) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req CICredentialsRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxExchangeBody)).Decode(&req); err != nil || req.Role == "" || req.Token == "" {
+		writeJSONError(w, http.StatusBadRequest, "expected a JSON body with role and token")
+		return
+	}
+
+	deny := func(status int, reason errors.DenyReason, clientID, tenantID, bucket, msg string) {
+		log.Printf("[%s] CI credentials refused: role=%s reason=%s: %s", rc.RequestID, req.Role, reason, msg)
+		e := audit.NewDenyEntry(rc.RequestID, clientID, tenantID, ActionIssueCICredentials, bucket, "",
+			g.clientIP(r), r.UserAgent(), string(reason), time.Since(rc.StartTime))
+		e.ErrorMsg = msg
+		g.logAudit(e, w, r)
+		writeJSONError(w, status, msg)
+	}
+
+	role, ok := g.ci.cfg.Role(req.Role)
+	if !ok {
+		deny(http.StatusForbidden, errors.DenyPolicy, "", "", "", "unknown role")
+		return
+	}
+	claims, err := g.ci.verifiers[role.Provider].Verify(r.Context(), req.Token)
+	if stderrors.Is(err, oidc.ErrInvalidToken) {
+		deny(http.StatusUnauthorized, errors.DenyAuthFailed, "", role.TenantID, role.Bucket, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] Failed to verify OIDC token of provider %s: %v", rc.RequestID, role.Provider, err)
+		writeJSONError(w, http.StatusBadGateway, "the token could not be verified")
+		return
+	}
+	clientID := ciClientID(role.Name, claims.String("sub"))
+	if name, ok := mismatchedClaim(role.Claims, claims); !ok {
+		deny(http.StatusForbidden, errors.DenyPolicy, clientID, role.TenantID, role.Bucket, "claim "+name+" does not match the role")
+		return
+	}
+	prefix, err := role.ExpandPrefix(claims.String)
+	if err != nil {
+		deny(http.StatusForbidden, errors.DenyPolicy, clientID, role.TenantID, role.Bucket, err.Error())
+		return
+	}
+
+	ttl := g.ci.cfg.TTL
+	if role.TTL > 0 {
+		ttl = role.TTL
+	}
+	creds := auth.IssueCICredentials(g.ci.key, auth.CIToken{
+		Role:    role.Name,
+		Subject: claims.String("sub"),
+		Bucket:  role.Bucket,
+		Prefix:  prefix,
+		Expires: time.Now().Add(ttl).UTC().Truncate(time.Second),
+	})
+	e := audit.NewAllowEntry(rc.RequestID, clientID, role.TenantID, ActionIssueCICredentials, role.Bucket, prefix,
+		g.clientIP(r), r.UserAgent(), time.Since(rc.StartTime), http.StatusOK)
+	e.AccessKeyID = creds.AccessKeyID
+	g.logAudit(e, w, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(CICredentialsResponse{
+		Version:         1,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expiration,
+		Bucket:          role.Bucket,
+		Prefix:          prefix,
+	})
+}
+
+// authenticateCI authenticates a request signed with issued CI credentials.
+// The role's current tenant and policies apply; removing the role ends the
+// credentials issued for it.
+func (g *Gateway) authenticateCI(r *http.Request, token string) (*auth.AuthContext, error) {
+	t, signer, err := auth.VerifyCIToken(g.ci.key, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	role, ok := g.ci.cfg.Role(t.Role)
+	if !ok || role.Bucket != t.Bucket {
+		return nil, errors.NewAccessDeniedError(errors.DenyInvalidSession, "the role of the security token no longer exists", "", "")
+	}
+	if _, err := g.sigValidator.ParseAndValidate(r, signer); err != nil {
+		return nil, err
+	}
+	return &auth.AuthContext{
+		ClientID:  ciClientID(role.Name, t.Subject),
+		TenantID:  role.TenantID,
+		AccessKey: signer.AccessKey,
+		Policies:  role.Policies,
+		Scopes:    []string{t.Bucket},
+		KeyPrefix: t.Prefix,
+	}, nil
+}
+
+// ciClientID identifies a CI job in audit entries by role and token subject
+func ciClientID(role, subject string) string {
+	return "ci:" + role + ":" + subject
+}
+
+// mismatchedClaim returns the first claim, in name order, whose value does
+// not match the role's pattern
+func mismatchedClaim(patterns map[string]string, claims oidc.Claims) (string, bool) {
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := claims.String(name)
+		if v == "" || !policy.MatchAction(v, []string{patterns[name]}) {
+			return name, false
+		}
+	}
+	return "", true
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
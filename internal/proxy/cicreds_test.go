@@ -0,0 +1,140 @@
+package proxy_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/oidc/oidctest"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+	"github.com/s3-access-control-adapter/pkg/gatewayclient"
+)
+
+func TestGateway_CICredentials(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	cfg := &config.CICredentialsConfig{
+		Path:       "/_gateway/ci-credentials",
+		SigningKey: "0123456789abcdef0123456789abcdef",
+		TTL:        15 * time.Minute,
+		Providers:  []config.OIDCProvider{{Name: "github", Issuer: issuer.URL, Audience: "s3-gateway"}},
+		Roles: []config.CIRole{{
+			Name:     "build-artifacts",
+			Provider: "github",
+			Claims:   map[string]string{"repository": "myorg/*", "ref": "refs/heads/main"},
+			TenantID: "tenant-001",
+			Bucket:   "tenant-001-data",
+			Prefix:   "ci/{repository_id}/{run_id}/",
+			Policies: []string{"tenant-001-full-access"},
+		}},
+	}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithCICredentials(cfg))
+
+	exchange := func(role, token string) (*http.Response, proxy.CICredentialsResponse) {
+		body, _ := json.Marshal(proxy.CICredentialsRequest{Role: role, Token: token})
+		resp, err := http.Post(gw.URL+cfg.Path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var creds proxy.CICredentialsResponse
+		json.NewDecoder(resp.Body).Decode(&creds)
+		return resp, creds
+	}
+
+	claims := map[string]interface{}{"repository": "myorg/app", "repository_id": 17, "ref": "refs/heads/main", "run_id": 42, "sub": "repo:myorg/app:ref:refs/heads/main"}
+	resp, creds := exchange("build-artifacts", issuer.Token(t, "s3-gateway", claims))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("exchange: status = %d", resp.StatusCode)
+	}
+	if creds.Bucket != "tenant-001-data" || creds.Prefix != "ci/17/42/" || creds.SessionToken == "" {
+		t.Fatalf("unexpected credentials: %+v", creds)
+	}
+	entry := gw.Audit.Last()
+	if entry.Action != proxy.ActionIssueCICredentials || entry.Decision != "allow" ||
+		entry.ClientID != "ci:build-artifacts:repo:myorg/app:ref:refs/heads/main" || entry.Key != "ci/17/42/" {
+		t.Errorf("unexpected exchange audit entry: %+v", entry)
+	}
+
+	client, err := gatewayclient.New(gatewayclient.Config{
+		Endpoint:        gw.URL,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	put := func(key string) error {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String("tenant-001-data"),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("artifact"),
+		})
+		return err
+	}
+	if err := put("ci/17/42/build.tar"); err != nil {
+		t.Fatalf("PutObject within the prefix: %v", err)
+	}
+	if entry := gw.Audit.Last(); entry.ClientID != "ci:build-artifacts:repo:myorg/app:ref:refs/heads/main" || entry.TenantID != "tenant-001" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+	// Sibling prefixes sharing the job's prefix as a string are outside it
+	for _, key := range []string{"ci/17/41/build.tar", "ci/17/420/build.tar"} {
+		if err := put(key); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+			t.Errorf("PutObject %s outside the prefix: err = %v, want AccessDenied", key, err)
+		}
+		if gw.Audit.Last().DenyReason != "DENY_TENANT_BOUNDARY" {
+			t.Errorf("%s: deny reason = %s, want DENY_TENANT_BOUNDARY", key, gw.Audit.Last().DenyReason)
+		}
+	}
+	out, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("tenant-001-data")})
+	if err != nil {
+		t.Fatalf("ListObjectsV2: %v", err)
+	}
+	if len(out.Contents) != 1 || aws.ToString(out.Prefix) != "ci/17/42/" {
+		t.Errorf("listing = prefix %q, %d objects; want only the job's prefix", aws.ToString(out.Prefix), len(out.Contents))
+	}
+
+	// Tokens of other repositories or branches, tokens whose claims would leave
+	// the job's prefix, and invalid tokens are refused
+	for name, tc := range map[string]struct {
+		role, token string
+		status      int
+	}{
+		"other repository":  {"build-artifacts", issuer.Token(t, "s3-gateway", map[string]interface{}{"repository": "evil/app", "ref": "refs/heads/main", "run_id": 1}), http.StatusForbidden},
+		"other branch":      {"build-artifacts", issuer.Token(t, "s3-gateway", map[string]interface{}{"repository": "myorg/app", "ref": "refs/heads/dev", "run_id": 1}), http.StatusForbidden},
+		"missing claim":     {"build-artifacts", issuer.Token(t, "s3-gateway", map[string]interface{}{"repository": "myorg/app", "ref": "refs/heads/main", "repository_id": 17}), http.StatusForbidden},
+		"claim with a path": {"build-artifacts", issuer.Token(t, "s3-gateway", map[string]interface{}{"repository": "myorg/app", "ref": "refs/heads/main", "repository_id": "17/../18", "run_id": 1}), http.StatusForbidden},
+		"wrong audience":    {"build-artifacts", issuer.Token(t, "other", claims), http.StatusUnauthorized},
+		"unknown role":      {"admin", issuer.Token(t, "s3-gateway", claims), http.StatusForbidden},
+	} {
+		if resp, _ := exchange(tc.role, tc.token); resp.StatusCode != tc.status {
+			t.Errorf("%s: status = %d, want %d", name, resp.StatusCode, tc.status)
+		}
+		if entry := gw.Audit.Last(); entry.Action != proxy.ActionIssueCICredentials || entry.Decision != "deny" {
+			t.Errorf("%s: unexpected audit entry: %+v", name, entry)
+		}
+	}
+
+	// A tampered token is not accepted
+	tampered, _ := gatewayclient.New(gatewayclient.Config{
+		Endpoint:        gw.URL,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    "e30" + creds.SessionToken[3:],
+	})
+	_, err = tampered.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("tenant-001-data"), Key: aws.String("ci/x"), Body: strings.NewReader("x")})
+	if err == nil || gw.Audit.Last().DenyReason != "DENY_INVALID_SESSION" {
+		t.Errorf("tampered token: err = %v, deny reason %s", err, gw.Audit.Last().DenyReason)
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// EmptyBucket deletes every object in bucket through the backend, returning
+// how many it deleted. It is used to clean up after a deleted tenant.
+func EmptyBucket(ctx context.Context, backend Forwarder, bucket string) (int, error) {
+	deleted := 0
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := backend.Forward(ctx, &S3Request{
+			Bucket:      bucket,
+			Action:      "s3:ListBucket",
+			Operation:   "ListObjectsV2",
+			HTTPMethod:  http.MethodGet,
+			Headers:     make(http.Header),
+			QueryParams: query,
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list objects: %w", err)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to parse object listing: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			if err := ctx.Err(); err != nil {
+				return deleted, err
+			}
+			resp, err := backend.Forward(ctx, &S3Request{
+				Bucket:      bucket,
+				Key:         obj.Key,
+				Action:      "s3:DeleteObject",
+				Operation:   "DeleteObject",
+				HTTPMethod:  http.MethodDelete,
+				Headers:     make(http.Header),
+				QueryParams: url.Values{},
+			})
+			if err != nil {
+				return deleted, fmt.Errorf("failed to delete %s: %w", obj.Key, err)
+			}
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			deleted++
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return deleted, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
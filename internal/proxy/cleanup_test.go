@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestEmptyBucket(t *testing.T) {
+	backend := NewMemoryBackend("doomed", "kept")
+	for i := 0; i < 1500; i++ {
+		if err := backend.PutObject("doomed", fmt.Sprintf("dir/%04d", i), []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := backend.PutObject("kept", "a", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := EmptyBucket(context.Background(), backend, "doomed")
+	if err != nil || n != 1500 {
+		t.Fatalf("EmptyBucket = %d, %v; want 1500 deleted", n, err)
+	}
+	if _, ok := backend.GetObject("doomed", "dir/1499"); ok {
+		t.Error("object survived cleanup")
+	}
+	if _, ok := backend.GetObject("kept", "a"); !ok {
+		t.Error("cleanup deleted from another bucket")
+	}
+
+	if _, err := EmptyBucket(context.Background(), backend, "missing"); err == nil {
+		t.Error("expected error for missing bucket")
+	}
+}
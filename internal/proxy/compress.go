@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// WithCompression compresses responses for clients sending Accept-Encoding
+func WithCompression(cfg *config.CompressionConfig) Option {
+	return func(g *Gateway) {
+		g.compression = cfg
+	}
+}
+
+// responseEncoding returns the encoding to compress a response with, or ""
+// to send it as it is. Partial, empty and already encoded responses are
+// never compressed.
+func (g *Gateway) responseEncoding(r *http.Request, s3req *S3Request, resp *S3Response) string {
+	c := g.compression
+	if c == nil || resp.Body == nil || s3req.HTTPMethod != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	if resp.Headers.Get("Content-Encoding") != "" || resp.Headers.Get("Content-Range") != "" {
+		return ""
+	}
+	size := resp.ContentLength
+	if s3req.Operation == "GetObject" {
+		if size < c.MinSize || size > c.MaxObjectSize || !compressibleType(resp.Headers.Get("Content-Type"), c.ContentTypes) {
+			return ""
+		}
+	} else if size >= 0 && size < c.MinSize {
+		return ""
+	}
+	return acceptedEncoding(r.Header.Values("Accept-Encoding"))
+}
+
+// compressibleType reports whether a content type matches a pattern such as
+// text/* or application/json
+func compressibleType(contentType string, patterns []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		if p == mediaType || (strings.HasSuffix(p, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedEncoding picks gzip, or else deflate, when Accept-Encoding allows
+// it. Quality values only matter when zero.
+func acceptedEncoding(headers []string) string {
+	accepted := make(map[string]bool)
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					continue
+				}
+			}
+			accepted[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+	switch {
+	case accepted["gzip"] || accepted["*"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	}
+	return ""
+}
+
+// newEncoder returns a writer compressing to w
+func newEncoder(w io.Writer, encoding string) io.WriteCloser {
+	if encoding == "gzip" {
+		return gzip.NewWriter(w)
+	}
+	return zlib.NewWriter(w)
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import "testing"
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		headers []string
+		want    string
+	}{
+		{nil, ""},
+		{[]string{"gzip, deflate, br"}, "gzip"},
+		{[]string{"deflate"}, "deflate"},
+		{[]string{"br", "deflate;q=0.5"}, "deflate"},
+		{[]string{"gzip;q=0, deflate"}, "deflate"},
+		{[]string{"*"}, "gzip"},
+		{[]string{"identity"}, ""},
+	}
+	for _, tt := range tests {
+		if got := acceptedEncoding(tt.headers); got != tt.want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", tt.headers, got, tt.want)
+		}
+	}
+}
+
+func TestCompressibleType(t *testing.T) {
+	patterns := []string{"text/*", "application/json"}
+	for contentType, want := range map[string]bool{
+		"text/csv":                  true,
+		"text/plain; charset=utf-8": true,
+		"application/json":          true,
+		"application/octet-stream":  false,
+		"image/png":                 false,
+		"":                          false,
+	} {
+		if got := compressibleType(contentType, patterns); got != want {
+			t.Errorf("compressibleType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+//go:build conformance
+
+package proxy_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+)
+
+// The conformance suite runs an in-process gateway in front of a real
+// S3-compatible store and drives it with the AWS SDK, the way clients do:
+//
+//	make docker-up   # LocalStack on :4566; MinIO works too
+//	CONFORMANCE_S3_ENDPOINT=http://localhost:4566 go test -tags conformance -run Conformance ./internal/proxy/
+//
+// CONFORMANCE_S3_ACCESS_KEY and CONFORMANCE_S3_SECRET_KEY default to
+// LocalStack's "test"; use minioadmin for a default MinIO.
+func TestConformance(t *testing.T) {
+	endpoint := os.Getenv("CONFORMANCE_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("CONFORMANCE_S3_ENDPOINT is not set")
+	}
+	ctx := context.Background()
+
+	upstream, err := proxy.NewS3Client(ctx, &config.AWSConfig{
+		Region:          "us-east-1",
+		Endpoint:        endpoint,
+		AccessKeyID:     envOr("CONFORMANCE_S3_ACCESS_KEY", "test"),
+		SecretAccessKey: envOr("CONFORMANCE_S3_SECRET_KEY", "test"),
+		UsePathStyle:    true,
+		Redirects:       "passthrough",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cred := config.Credential{
+		AccessKey: "AKIACONFORMANCE00001",
+		SecretKey: "conformance-secret",
+		ClientID:  "conformance",
+		TenantID:  "conformance",
+		Policies:  []string{"conformance"},
+		Scopes:    []string{"conformance-*"},
+	}
+	policies := []config.Policy{{
+		Name: "conformance",
+		Statements: []config.Statement{{
+			Effect:    config.EffectAllow,
+			Actions:   []string{"s3:*"},
+			Resources: []string{"arn:aws:s3:::conformance-*", "arn:aws:s3:::conformance-*/*"},
+		}},
+	}}
+	gw := proxytest.NewGatewayWithForwarder(t, []config.Credential{cred}, policies, upstream)
+
+	client := s3.New(s3.Options{
+		Region:       proxytest.Region,
+		BaseEndpoint: aws.String(gw.URL),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(cred.AccessKey, cred.SecretKey, ""),
+	})
+	bucket := fmt.Sprintf("conformance-%d", time.Now().UnixNano())
+	body := []byte("conformance payload")
+
+	steps := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"CreateBucket", func(t *testing.T) {
+			if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: &bucket}); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"PutObject", func(t *testing.T) {
+			_, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: aws.String("dir/a.txt"),
+				Body: bytes.NewReader(body), ContentType: aws.String("text/plain")})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"HeadObject", func(t *testing.T) {
+			out, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: aws.String("dir/a.txt")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aws.ToInt64(out.ContentLength) != int64(len(body)) || aws.ToString(out.ContentType) != "text/plain" {
+				t.Errorf("length = %d, type = %q", aws.ToInt64(out.ContentLength), aws.ToString(out.ContentType))
+			}
+		}},
+		{"GetObject", func(t *testing.T) {
+			out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: aws.String("dir/a.txt")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Body.Close()
+			if got, _ := io.ReadAll(out.Body); !bytes.Equal(got, body) {
+				t.Errorf("body = %q, want %q", got, body)
+			}
+		}},
+		{"GetObjectRange", func(t *testing.T) {
+			out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: aws.String("dir/a.txt"),
+				Range: aws.String("bytes=0-10")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Body.Close()
+			if got, _ := io.ReadAll(out.Body); !bytes.Equal(got, body[:11]) {
+				t.Errorf("body = %q, want %q", got, body[:11])
+			}
+		}},
+		{"ListObjectsV2", func(t *testing.T) {
+			out, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: &bucket, Prefix: aws.String("dir/")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out.Contents) != 1 || aws.ToString(out.Contents[0].Key) != "dir/a.txt" {
+				t.Errorf("contents = %+v", out.Contents)
+			}
+		}},
+		{"GetObjectMissing", func(t *testing.T) {
+			_, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: aws.String("missing")})
+			if code := errorCode(err); code != "NoSuchKey" {
+				t.Errorf("error code = %q, want NoSuchKey (%v)", code, err)
+			}
+		}},
+		{"AccessDeniedOutsideScope", func(t *testing.T) {
+			_, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("other-bucket"), Key: aws.String("a.txt"),
+				Body: bytes.NewReader(body)})
+			if code := errorCode(err); code != "AccessDenied" {
+				t.Errorf("error code = %q, want AccessDenied (%v)", code, err)
+			}
+		}},
+		{"MultipartUpload", func(t *testing.T) {
+			t.Skip("multipart operations are not forwarded by the S3 backend yet")
+		}},
+		{"PresignedGet", func(t *testing.T) {
+			t.Skip("the gateway does not accept query-string (presigned) signatures yet")
+		}},
+		{"DeleteObject", func(t *testing.T) {
+			if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &bucket, Key: aws.String("dir/a.txt")}); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"DeleteBucket", func(t *testing.T) {
+			if _, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: &bucket}); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, step.run)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// errorCode returns the S3 error code of an SDK error
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
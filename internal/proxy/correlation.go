@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Correlation headers accepted from clients and forwarded upstream
+const (
+	headerRequestID   = "X-Request-Id"
+	headerTraceParent = "Traceparent"
+	// headerAzureClientRequestID is Azure's equivalent of X-Request-ID,
+	// recorded in its storage analytics logs
+	headerAzureClientRequestID = "x-ms-client-request-id"
+)
+
+var (
+	// requestIDRegex bounds client-supplied IDs to safe, log-friendly values
+	requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]{1,128}$`)
+	// traceParentRegex matches a W3C trace context traceparent header
+	traceParentRegex = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// correlation identifies a request across the client, the gateway and the
+// storage backend's access logs
+type correlation struct {
+	requestID   string // client-supplied X-Request-ID
+	traceParent string
+}
+
+type correlationKey struct{}
+
+// parseCorrelation reads the correlation headers, ignoring malformed values
+func parseCorrelation(h http.Header) correlation {
+	var c correlation
+	if v := h.Get(headerRequestID); requestIDRegex.MatchString(v) {
+		c.requestID = v
+	}
+	if v := h.Get(headerTraceParent); traceParentRegex.MatchString(v) {
+		c.traceParent = v
+	}
+	return c
+}
+
+// ID returns the client's request ID, or else the trace ID of its traceparent
+func (c correlation) ID() string {
+	if c.requestID != "" {
+		return c.requestID
+	}
+	if m := traceParentRegex.FindStringSubmatch(c.traceParent); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func withCorrelation(ctx context.Context, c correlation) context.Context {
+	return context.WithValue(ctx, correlationKey{}, c)
+}
+
+func correlationFrom(ctx context.Context) correlation {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	return c
+}
+
+// addCorrelationHeaders is an S3 client API option forwarding the request's
+// correlation headers upstream, where they appear in S3 server access logs
+func addCorrelationHeaders(stack *middleware.Stack) error {
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("GatewayCorrelationHeaders",
+		func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+			if req, ok := in.Request.(*smithyhttp.Request); ok {
+				c := correlationFrom(ctx)
+				if c.requestID != "" {
+					req.Header.Set(headerRequestID, c.requestID)
+				}
+				if c.traceParent != "" {
+					req.Header.Set(headerTraceParent, c.traceParent)
+				}
+			}
+			return next.HandleBuild(ctx, in)
+		}), middleware.After)
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseCorrelation(t *testing.T) {
+	tests := []struct {
+		name        string
+		requestID   string
+		traceParent string
+		wantID      string
+		wantTrace   string
+	}{
+		{"none", "", "", "", ""},
+		{"request id", "req-123", "", "req-123", ""},
+		{"traceparent only", "", testTraceParent, "4bf92f3577b34da6a3ce929d0e0e4736", testTraceParent},
+		{"request id wins", "req-123", testTraceParent, "req-123", testTraceParent},
+		{"malformed request id", "bad id\nwith newline", "", "", ""},
+		{"malformed traceparent", "", "00-xyz", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := make(http.Header)
+			if tt.requestID != "" {
+				h.Set(headerRequestID, tt.requestID)
+			}
+			if tt.traceParent != "" {
+				h.Set(headerTraceParent, tt.traceParent)
+			}
+			c := parseCorrelation(h)
+			if c.ID() != tt.wantID || c.traceParent != tt.wantTrace {
+				t.Errorf("got id=%q trace=%q, want id=%q trace=%q", c.ID(), c.traceParent, tt.wantID, tt.wantTrace)
+			}
+		})
+	}
+}
+
+func TestS3Client_ForwardsCorrelationHeaders(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+	client := newTestS3Client(t, upstream.URL, RedirectFollow)
+
+	ctx := withCorrelation(context.Background(), correlation{requestID: "req-123", traceParent: testTraceParent})
+	req := &S3Request{Bucket: "bucket", Key: "a.txt", Action: "s3:DeleteObject", Operation: "DeleteObject", HTTPMethod: http.MethodDelete, Headers: make(http.Header)}
+	if _, err := client.Forward(ctx, req); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+
+	if got.Get(headerRequestID) != "req-123" || got.Get(headerTraceParent) != testTraceParent {
+		t.Errorf("upstream headers: X-Request-Id=%q traceparent=%q", got.Get(headerRequestID), got.Get(headerTraceParent))
+	}
+}
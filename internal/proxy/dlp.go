@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/dlp"
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// DLPTag is the object tag naming the findings of uploads stored by a tag
+// rule, e.g. "email+ssn"
+const DLPTag = "gateway-dlp-findings"
+
+// errDLPBlocked is returned for uploads a block rule rejects
+var errDLPBlocked = stderrors.New("upload rejected by DLP rule")
+
+// dlpScanError is a scan failure under a block rule
+type dlpScanError struct {
+	err error
+}
+
+func (e *dlpScanError) Error() string { return "failed to scan upload: " + e.err.Error() }
+func (e *dlpScanError) Unwrap() error { return e.err }
+
+// dlpScanner applies the DLP rules to uploads
+type dlpScanner struct {
+	cfg     config.DLPConfig
+	scanner dlp.Scanner
+}
+
+// WithDLP scans PutObject bodies matching the configured rules with scanner
+// and blocks, tags or audits uploads with findings
+func WithDLP(cfg *config.DLPConfig, scanner dlp.Scanner) Option {
+	return func(g *Gateway) {
+		g.dlp = &dlpScanner{cfg: *cfg, scanner: scanner}
+	}
+}
+
+// rule returns the first rule matching an upload, or nil
+func (d *dlpScanner) rule(s3req *S3Request, tenantID string) *config.DLPRule {
+	for i := range d.cfg.Rules {
+		r := &d.cfg.Rules[i]
+		if len(r.Tenants) > 0 && !slices.Contains(r.Tenants, tenantID) {
+			continue
+		}
+		if len(r.Buckets) > 0 && !policy.MatchScope(s3req.Bucket, r.Buckets) {
+			continue
+		}
+		if len(r.Prefixes) > 0 && !slices.ContainsFunc(r.Prefixes, func(p string) bool {
+			return strings.HasPrefix(s3req.Key, p)
+		}) {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// check scans an upload matching a rule, buffering its body, and records
+// the findings on rc for the audit entry. Uploads a block rule rejects
+// return errDLPBlocked; tag rules add DLPTag to the upload. Block rules fail
+// closed when the body is too large to scan or the scan fails; other rules
+// let the upload through unscanned.
+func (d *dlpScanner) check(ctx context.Context, rc *RequestContext, s3req *S3Request) error {
+	if d == nil || s3req.Action != "s3:PutObject" || s3req.Key == "" {
+		return nil
+	}
+	rule := d.rule(s3req, rc.TenantID())
+	if rule == nil {
+		return nil
+	}
+	block := rule.Action == config.DLPActionBlock
+
+	var data []byte
+	if s3req.Body != nil {
+		if s3req.ContentLength > d.cfg.MaxScanSize {
+			return d.tooLarge(rc, block)
+		}
+		var err error
+		data, err = io.ReadAll(io.LimitReader(s3req.Body, d.cfg.MaxScanSize+1))
+		s3req.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read upload: %w", err)
+		}
+		s3req.Body, s3req.ContentLength = io.NopCloser(bytes.NewReader(data)), int64(len(data))
+		if int64(len(data)) > d.cfg.MaxScanSize {
+			return d.tooLarge(rc, block)
+		}
+	}
+
+	findings, err := d.scanner.Scan(ctx, data)
+	if err != nil {
+		if block {
+			return &dlpScanError{err: err}
+		}
+		log.Printf("[%s] Upload not scanned for sensitive data: %v", rc.RequestID, err)
+		return nil
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	rc.dlpFindings = findings
+	log.Printf("[%s] DLP findings: client=%s resource=%s/%s action=%s findings=%s",
+		rc.RequestID, rc.ClientID(), s3req.Bucket, rc.logKey(), rule.Action, strings.Join(findings, ","))
+	switch rule.Action {
+	case config.DLPActionBlock:
+		return errDLPBlocked
+	case config.DLPActionTag:
+		addObjectTags(s3req.Headers, map[string]string{DLPTag: strings.Join(findings, "+")})
+	}
+	return nil
+}
+
+// tooLarge handles an upload larger than maxScanSize: block rules reject
+// it, others let it through
+func (d *dlpScanner) tooLarge(rc *RequestContext, block bool) error {
+	if block {
+		return fmt.Errorf("%w: body exceeds maxScanSize", errDLPBlocked)
+	}
+	log.Printf("[%s] Upload not scanned for sensitive data: body exceeds maxScanSize", rc.RequestID)
+	return nil
+}
+
+// handleDLP writes the error of an upload refused by DLP scanning; other
+// errors come from reading the upload
+func (g *Gateway) handleDLP(w http.ResponseWriter, rc *RequestContext, err error) {
+	var scanErr *dlpScanError
+	switch {
+	case stderrors.Is(err, errDLPBlocked):
+		log.Printf("[%s] Upload blocked: client=%s resource=%s/%s: %v",
+			rc.RequestID, rc.ClientID(), rc.S3.Bucket, rc.logKey(), err)
+		g.handleError(w, rc, errors.DenyDLP, err)
+	case stderrors.As(err, &scanErr):
+		log.Printf("[%s] %v", rc.RequestID, err)
+		g.handleError(w, rc, errors.DenyInternalError, err)
+	default:
+		g.handleS3Error(w, rc, err)
+	}
+}
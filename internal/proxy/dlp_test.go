@@ -0,0 +1,84 @@
+package proxy_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/dlp"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+)
+
+func TestGateway_DLP(t *testing.T) {
+	patterns, err := dlp.NewPatterns(map[string]string{"ssn": `\b\d{3}-\d{2}-\d{4}\b`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.DLPConfig{
+		MaxScanSize: 64,
+		Rules: []config.DLPRule{
+			{Prefixes: []string{"hr/"}, Action: config.DLPActionBlock},
+			{Prefixes: []string{"shared/"}, Action: config.DLPActionTag},
+			{Tenants: []string{"tenant-001"}, Action: config.DLPActionAudit},
+		},
+	}
+	backend := proxy.NewMemoryBackend("tenant-001-data")
+	gw := proxytest.NewGatewayWithBackend(t, []config.Credential{writerCred}, testPolicies, backend,
+		proxy.WithDLP(cfg, patterns))
+	sensitive := []byte("employee ssn 123-45-6789")
+
+	resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/hr/a.txt", sensitive)
+	if body := proxytest.ReadBody(t, resp); resp.StatusCode != http.StatusForbidden || !strings.Contains(body, "AccessDenied") {
+		t.Errorf("blocked upload: status = %d, body = %s", resp.StatusCode, body)
+	}
+	if entry := gw.Audit.Last(); entry.DenyReason != "DENY_DLP" || !reflect.DeepEqual(entry.DLPFindings, []string{"ssn"}) {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+	if _, ok := backend.GetObject("tenant-001-data", "hr/a.txt"); ok {
+		t.Error("blocked upload was stored")
+	}
+	if resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/hr/b.txt", []byte("no findings")); resp.StatusCode != http.StatusOK {
+		t.Errorf("clean upload: status = %d", resp.StatusCode)
+	}
+	if data, _ := backend.GetObject("tenant-001-data", "hr/b.txt"); string(data) != "no findings" {
+		t.Errorf("stored %q after scanning", data)
+	}
+	// Block rules fail closed for bodies too large to scan
+	resp = gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/hr/big.txt", bytes.Repeat([]byte("x"), 65))
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("oversized upload: status = %d, want 403", resp.StatusCode)
+	}
+
+	// Tag rules keep the client's tags
+	req, _ := http.NewRequest(http.MethodPut, gw.URL+"/tenant-001-data/shared/c.txt", bytes.NewReader(sensitive))
+	req.Header.Set("x-amz-tagging", "team=payroll")
+	proxytest.Sign(t, req, writerCred, sensitive)
+	resp, err = gw.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("tagged upload: status = %d", resp.StatusCode)
+	}
+	tags, _ := backend.ObjectTags(context.Background(), "tenant-001-data", "shared/c.txt")
+	if want := map[string]string{"team": "payroll", proxy.DLPTag: "ssn"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+
+	resp = gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/other/d.txt", sensitive)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("audited upload: status = %d", resp.StatusCode)
+	}
+	if entry := gw.Audit.Last(); entry.Decision != "allow" || !reflect.DeepEqual(entry.DLPFindings, []string{"ssn"}) {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+	if tags, _ := backend.ObjectTags(context.Background(), "tenant-001-data", "other/d.txt"); len(tags) != 0 {
+		t.Errorf("audited upload tagged: %v", tags)
+	}
+}
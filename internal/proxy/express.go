@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"encoding/xml"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+// directoryBucketRegex is the S3 Express directory bucket naming rule: a
+// base name and an availability zone ID, e.g. "logs--usw2-az1--x-s3"
+var directoryBucketRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*[a-z0-9]--[a-z0-9-]+--x-s3$`)
+
+// isDirectoryBucket reports whether name is an S3 Express directory bucket
+func isDirectoryBucket(name string) bool {
+	return strings.HasSuffix(name, "--x-s3")
+}
+
+// validDirectoryBucketName reports whether name may be used for a new
+// directory bucket
+func validDirectoryBucketName(name string) bool {
+	return len(name) >= 3 && len(name) <= 63 && directoryBucketRegex.MatchString(name) && !strings.HasPrefix(name, "xn--")
+}
+
+// directoryBucketHost returns the bucket of a virtual-hosted request to a
+// directory bucket, or "". SDKs address directory buckets by host name even
+// when configured for path-style requests, except at IP endpoints; a host
+// label ending in --x-s3 cannot be anything but such a bucket.
+func directoryBucketHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, _, _ := strings.Cut(host, ".")
+	if !isDirectoryBucket(label) {
+		return ""
+	}
+	return label
+}
+
+// sessionPermits reports whether a request signed with an S3 Express
+// session is within it: the session's bucket, and reads only for ReadOnly
+// sessions. Sessions cannot create further sessions.
+func sessionPermits(s *auth.Session, req *S3Request) bool {
+	if req.Bucket != s.Bucket || req.Operation == "CreateSession" {
+		return false
+	}
+	return !s.ReadOnly || req.HTTPMethod == http.MethodGet || req.HTTPMethod == http.MethodHead
+}
+
+// createSessionResult is the CreateSession response body
+type createSessionResult struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CreateSessionResult"`
+	Credentials struct {
+		SessionToken    string    `xml:"SessionToken"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"Credentials"`
+}
+
+// createSession answers CreateSession for a directory bucket itself. The
+// session credentials are the gateway's, not the backend's: requests signed
+// with them are authenticated and authorized like any other.
+func (g *Gateway) createSession(w http.ResponseWriter, rc *RequestContext, authCtx *auth.AuthContext) {
+	s3req := rc.S3
+	if !isDirectoryBucket(s3req.Bucket) {
+		g.handleS3Error(w, rc, errors.ErrInvalidRequest.WithMessage("CreateSession is only supported for directory buckets."))
+		return
+	}
+	session := auth.Session{Bucket: s3req.Bucket, Expires: time.Now().Add(auth.SessionTTL).UTC().Truncate(time.Second)}
+	switch s3req.Headers.Get("X-Amz-Create-Session-Mode") {
+	case "", "ReadWrite":
+	case "ReadOnly":
+		session.ReadOnly = true
+	default:
+		g.handleS3Error(w, rc, errors.ErrInvalidArgument.WithMessage("Invalid session mode"))
+		return
+	}
+	cred, err := g.credStore.GetCredential(authCtx.AccessKey)
+	if err != nil {
+		// Removed by a reload since the request was authenticated
+		g.handleError(w, rc, errors.DenyInvalidAccessKey, err)
+		return
+	}
+
+	creds := auth.IssueSession(cred, session)
+	var result createSessionResult
+	result.Credentials.SessionToken = creds.SessionToken
+	result.Credentials.SecretAccessKey = creds.SecretAccessKey
+	result.Credentials.AccessKeyID = creds.AccessKeyID
+	result.Credentials.Expiration = creds.Expiration
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("x-amz-request-id", rc.RequestID)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(result)
+
+	g.logAudit(audit.NewAllowEntry(rc.RequestID, authCtx.ClientID, authCtx.TenantID, s3req.Action,
+		s3req.Bucket, "", g.clientIP(rc.Request), rc.Request.UserAgent(), time.Since(rc.StartTime),
+		http.StatusOK), w, rc.Request)
+}
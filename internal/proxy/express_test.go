@@ -0,0 +1,135 @@
+package proxy_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+)
+
+const directoryBucket = "tenant-001-fast--use1-az4--x-s3"
+
+func TestGateway_S3ExpressSession(t *testing.T) {
+	policies := []config.Policy{{
+		Name: "tenant-001-full-access",
+		Statements: []config.Statement{{
+			Effect:    config.EffectAllow,
+			Actions:   []string{"s3:*", "s3express:CreateSession"},
+			Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+		}},
+	}}
+	gw := proxytest.NewGateway(t, []config.Credential{writerCred}, policies, directoryBucket, "tenant-001-data")
+
+	// The SDK creates a session for the directory bucket and signs the
+	// object requests with it
+	client := s3.New(s3.Options{
+		Region:       proxytest.Region,
+		BaseEndpoint: aws.String(gw.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider(writerCred.AccessKey, writerCred.SecretKey, ""),
+	})
+	ctx := context.Background()
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(directoryBucket),
+		Key:    aws.String("hot/key"),
+		Body:   bytes.NewReader([]byte("fast")),
+	})
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(directoryBucket), Key: aws.String("hot/key")})
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	data, _ := io.ReadAll(out.Body)
+	out.Body.Close()
+	if string(data) != "fast" {
+		t.Errorf("GetObject = %q, want fast", data)
+	}
+
+	var sessions, signed int
+	for _, e := range gw.Audit.Entries() {
+		switch e.Action {
+		case "s3express:CreateSession":
+			sessions++
+		case "s3:GetObject", "s3:PutObject":
+			signed++
+		}
+	}
+	if sessions != 1 || signed != 2 {
+		t.Errorf("sessions = %d, object requests = %d, want 1 and 2", sessions, signed)
+	}
+
+	// Sessions are only created for directory buckets
+	resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data?session", nil)
+	if body := proxytest.ReadBody(t, resp); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("general purpose bucket: status = %d, body = %s", resp.StatusCode, body)
+	}
+}
+
+func TestGateway_S3ExpressSessionScope(t *testing.T) {
+	policies := []config.Policy{{
+		Name: "tenant-001-full-access",
+		Statements: []config.Statement{{
+			Effect:    config.EffectAllow,
+			Actions:   []string{"s3:*", "s3express:CreateSession"},
+			Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+		}},
+	}}
+	gw := proxytest.NewGateway(t, []config.Credential{writerCred}, policies, directoryBucket)
+
+	// A ReadOnly session cannot write
+	client := s3.New(s3.Options{
+		Region:       proxytest.Region,
+		BaseEndpoint: aws.String(gw.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider(writerCred.AccessKey, writerCred.SecretKey, ""),
+	})
+	session, err := client.CreateSession(context.Background(), &s3.CreateSessionInput{
+		Bucket:      aws.String(directoryBucket),
+		SessionMode: "ReadOnly",
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	creds := session.Credentials
+	readOnly := s3.New(s3.Options{
+		Region:       proxytest.Region,
+		BaseEndpoint: aws.String(gw.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider(writerCred.AccessKey, writerCred.SecretKey, ""),
+		ExpressCredentials: staticExpressCredentials{aws.Credentials{
+			AccessKeyID:     aws.ToString(creds.AccessKeyId),
+			SecretAccessKey: aws.ToString(creds.SecretAccessKey),
+			SessionToken:    aws.ToString(creds.SessionToken),
+		}},
+	})
+	_, err = readOnly.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(directoryBucket),
+		Key:    aws.String("key"),
+		Body:   bytes.NewReader([]byte("x")),
+	})
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "AccessDenied" {
+		t.Errorf("PutObject with a ReadOnly session: err = %v, want AccessDenied", err)
+	}
+	if e := gw.Audit.Last(); e.DenyReason != "DENY_SESSION_SCOPE" {
+		t.Errorf("deny reason = %s, want DENY_SESSION_SCOPE", e.DenyReason)
+	}
+	if _, err := readOnly.HeadBucket(context.Background(), &s3.HeadBucketInput{Bucket: aws.String(directoryBucket)}); err != nil {
+		t.Errorf("HeadBucket with a ReadOnly session: %v", err)
+	}
+}
+
+// staticExpressCredentials serves one session for every directory bucket
+type staticExpressCredentials struct{ creds aws.Credentials }
+
+func (s staticExpressCredentials) Retrieve(ctx context.Context, bucket string) (aws.Credentials, error) {
+	return s.creds, nil
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var (
+	failoverActive = metrics.NewGauge("gateway_upstream_failover_active",
+		"1 while reads are served by the secondary endpoint")
+	failoverTransitions = metrics.NewCounter("gateway_upstream_failover_transitions_total",
+		"Switches between primary and secondary read endpoints", "to")
+)
+
+// endpointHealth tracks consecutive primary failures and whether reads have
+// failed over to the secondary endpoint
+type endpointHealth struct {
+	mu         sync.Mutex
+	threshold  int
+	failures   int
+	failedOver bool
+}
+
+func newEndpointHealth(threshold int) *endpointHealth {
+	return &endpointHealth{threshold: threshold}
+}
+
+// useSecondary reports whether reads should currently go to the secondary
+func (h *endpointHealth) useSecondary() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.failedOver
+}
+
+// recordPrimary records the outcome of a read served by the primary
+func (h *endpointHealth) recordPrimary(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !isUpstreamFailure(err) {
+		h.failures = 0
+		return
+	}
+
+	h.failures++
+	if !h.failedOver && h.failures >= h.threshold {
+		h.failedOver = true
+		failoverActive.Set(1)
+		failoverTransitions.Inc("secondary")
+		log.Printf("Primary upstream failed %d consecutive reads, failing over to secondary", h.failures)
+	}
+}
+
+// restore fails reads back to the primary after a successful probe
+func (h *endpointHealth) restore() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.failedOver {
+		return
+	}
+	h.failedOver = false
+	h.failures = 0
+	failoverActive.Set(0)
+	failoverTransitions.Inc("primary")
+	log.Printf("Primary upstream healthy again, failing back")
+}
+
+// isUpstreamFailure reports whether err indicates the endpoint itself is
+// unhealthy (5xx, timeout or transport error) rather than a client error
+func isUpstreamFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.HTTPStatusCode() >= http.StatusInternalServerError
+	}
+
+	// No HTTP response at all: timeouts, resets, connection refused
+	return true
+}
+
+// probePrimary periodically checks the primary while failed over and fails
+// back once it answers without a server error. Any non-5xx answer to the
+// probe (including 403/404) means the endpoint is serving again.
+func (c *S3Client) probePrimary(ctx context.Context, interval time.Duration, bucket string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !c.health.useSecondary() {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, interval)
+		_, err := c.client.HeadBucket(probeCtx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+		cancel()
+
+		if !isUpstreamFailure(err) {
+			c.health.restore()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type statusError struct{ code int }
+
+func (e *statusError) Error() string       { return fmt.Sprintf("status %d", e.code) }
+func (e *statusError) HTTPStatusCode() int { return e.code }
+
+func TestIsUpstreamFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled by client", context.Canceled, false},
+		{"not found", &statusError{404}, false},
+		{"access denied", fmt.Errorf("wrapped: %w", &statusError{403}), false},
+		{"internal error", &statusError{500}, true},
+		{"slow down", fmt.Errorf("wrapped: %w", &statusError{503}), true},
+		{"timeout", context.DeadlineExceeded, true},
+		{"connection refused", errors.New("dial tcp: connection refused"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUpstreamFailure(tt.err); got != tt.want {
+				t.Errorf("isUpstreamFailure(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointHealth_FailoverAndFailback(t *testing.T) {
+	h := newEndpointHealth(3)
+
+	h.recordPrimary(&statusError{500})
+	h.recordPrimary(&statusError{502})
+	if h.useSecondary() {
+		t.Fatal("failed over before reaching the threshold")
+	}
+
+	// A success resets the consecutive failure count
+	h.recordPrimary(nil)
+	h.recordPrimary(&statusError{500})
+	h.recordPrimary(&statusError{500})
+	if h.useSecondary() {
+		t.Fatal("failure count was not reset by a success")
+	}
+
+	h.recordPrimary(context.DeadlineExceeded)
+	if !h.useSecondary() {
+		t.Fatal("expected failover after 3 consecutive failures")
+	}
+
+	h.restore()
+	if h.useSecondary() {
+		t.Fatal("expected failback after restore")
+	}
+}
+
+func TestEndpointHealth_ClientErrorsDoNotCount(t *testing.T) {
+	h := newEndpointHealth(1)
+
+	h.recordPrimary(&statusError{404})
+	h.recordPrimary(&statusError{403})
+	if h.useSecondary() {
+		t.Error("client errors must not trigger failover")
+	}
+}
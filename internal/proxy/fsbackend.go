@@ -0,0 +1,313 @@
+package proxy
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metaDir holds metadata sidecars under the backend root. Bucket names cannot
+// start with a dot, so it never collides with a bucket directory.
+const metaDir = ".meta"
+
+// FilesystemBackend serves S3 requests from a local directory: each bucket is
+// a directory under the root and each object a file, with content metadata
+// stored in JSON sidecars. It is intended for local development only.
+type FilesystemBackend struct {
+	root string
+
+	// commitMu makes checking the preconditions of a write and replacing the
+	// object atomic within the process
+	commitMu sync.Mutex
+}
+
+// NewFilesystemBackend creates a backend rooted at dir
+func NewFilesystemBackend(dir string) (*FilesystemBackend, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filesystem backend root: %w", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open filesystem backend root: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("filesystem backend root %s is not a directory", root)
+	}
+	return &FilesystemBackend{root: root}, nil
+}
+
+// Forward executes the request against the local directory
+func (b *FilesystemBackend) Forward(ctx context.Context, req *S3Request) (*S3Response, error) {
+	if !b.bucketExists(req.Bucket) {
+		return nil, errNoSuchBucket()
+	}
+
+	switch req.Operation {
+	case "GetObject":
+		return b.getObject(req, false)
+	case "HeadObject":
+		return b.getObject(req, true)
+	case "PutObject":
+		return b.putObject(req)
+	case "DeleteObject":
+		return b.deleteObject(req)
+	case "ListObjects", "ListObjectsV2":
+		return b.listObjects(req)
+	case "HeadBucket":
+		return &S3Response{StatusCode: http.StatusOK, Headers: make(http.Header)}, nil
+	default:
+		return nil, errNotImplemented(req.Operation)
+	}
+}
+
+func (b *FilesystemBackend) bucketExists(bucket string) bool {
+	if bucket == "" || strings.HasPrefix(bucket, ".") || strings.ContainsAny(bucket, `/\`) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(b.root, bucket))
+	return err == nil && info.IsDir()
+}
+
+// paths returns the data and sidecar paths for an object, rejecting keys
+// that would escape the bucket directory
+func (b *FilesystemBackend) paths(bucket, key string) (dataPath, metaPath string, err error) {
+	if key == "" || strings.HasSuffix(key, "/") {
+		return "", "", errInvalidArgument("the filesystem backend does not support empty or directory keys")
+	}
+	rel := filepath.FromSlash(key)
+	if !filepath.IsLocal(rel) {
+		return "", "", errInvalidArgument("object key is not a valid local path")
+	}
+	return filepath.Join(b.root, bucket, rel), filepath.Join(b.root, metaDir, bucket, rel+".json"), nil
+}
+
+func (b *FilesystemBackend) getObject(req *S3Request, headOnly bool) (*S3Response, error) {
+	dataPath, metaPath, err := b.paths(req.Bucket, req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := b.stat(req.Key, dataPath, metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := info.headers()
+	if status := checkPreconditions(req.Headers, info.ETag); status != 0 {
+		return &S3Response{StatusCode: status, Headers: headers}, nil
+	}
+	if headOnly {
+		return &S3Response{StatusCode: http.StatusOK, Headers: headers, ContentLength: info.Size}, nil
+	}
+
+	start, end, partial, err := parseRange(req.Headers.Get("Range"), info.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+
+	if !partial {
+		return &S3Response{StatusCode: http.StatusOK, Headers: headers, Body: file, ContentLength: info.Size}, nil
+	}
+
+	length := end - start + 1
+	headers.Set("Content-Length", strconv.FormatInt(length, 10))
+	headers.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size))
+	return &S3Response{
+		StatusCode:    http.StatusPartialContent,
+		Headers:       headers,
+		Body:          readCloser{Reader: io.NewSectionReader(file, start, length), Closer: file},
+		ContentLength: length,
+	}, nil
+}
+
+// stat returns object metadata, deriving it from the file when the sidecar is missing
+func (b *FilesystemBackend) stat(key, dataPath, metaPath string) (*objectInfo, error) {
+	fi, err := os.Stat(dataPath)
+	if err != nil || fi.IsDir() {
+		return nil, errNoSuchKey()
+	}
+
+	info := &objectInfo{}
+	if data, err := os.ReadFile(metaPath); err == nil {
+		if err := json.Unmarshal(data, info); err != nil {
+			return nil, fmt.Errorf("corrupt metadata for %s: %w", key, err)
+		}
+	} else {
+		etag, err := fileETag(dataPath)
+		if err != nil {
+			return nil, err
+		}
+		info.ETag = etag
+	}
+
+	info.Key = key
+	info.Size = fi.Size()
+	info.LastModified = fi.ModTime()
+	return info, nil
+}
+
+func (b *FilesystemBackend) putObject(req *S3Request) (*S3Response, error) {
+	dataPath, metaPath, err := b.paths(req.Bucket, req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	// Write to a temp file and rename so readers never see partial objects
+	tmp, err := os.CreateTemp(filepath.Dir(dataPath), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	var body io.Reader = http.NoBody
+	if req.Body != nil {
+		body = req.Body
+	}
+	size, err := io.Copy(io.MultiWriter(tmp, hash), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write object: %w", err)
+	}
+
+	info := objectInfo{
+		Key:             req.Key,
+		Size:            size,
+		ETag:            `"` + hex.EncodeToString(hash.Sum(nil)) + `"`,
+		LastModified:    time.Now().UTC(),
+		ContentType:     req.Headers.Get("Content-Type"),
+		ContentEncoding: req.Headers.Get("Content-Encoding"),
+		CacheControl:    req.Headers.Get("Cache-Control"),
+		StorageClass:    req.Headers.Get("x-amz-storage-class"),
+	}
+	meta, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	b.commitMu.Lock()
+	defer b.commitMu.Unlock()
+	if req.Headers.Get("If-Match") != "" || req.Headers.Get("If-None-Match") != "" {
+		var etag string
+		if old, err := b.stat(req.Key, dataPath, metaPath); err == nil {
+			etag = old.ETag
+		}
+		if err := checkWritePreconditions(req.Headers, etag); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+	if err := os.WriteFile(metaPath, meta, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dataPath); err != nil {
+		return nil, fmt.Errorf("failed to store object: %w", err)
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", info.ETag)
+	return &S3Response{StatusCode: http.StatusOK, Headers: headers}, nil
+}
+
+func (b *FilesystemBackend) deleteObject(req *S3Request) (*S3Response, error) {
+	dataPath, metaPath, err := b.paths(req.Bucket, req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting a missing key succeeds, as in S3
+	for _, path := range []string{dataPath, metaPath} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
+
+	return &S3Response{StatusCode: http.StatusNoContent, Headers: make(http.Header)}, nil
+}
+
+func (b *FilesystemBackend) listObjects(req *S3Request) (*S3Response, error) {
+	bucketDir := filepath.Join(b.root, req.Bucket)
+
+	var objects []objectInfo
+	err := filepath.WalkDir(bucketDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(bucketDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		_, metaPath, err := b.paths(req.Bucket, key)
+		if err != nil {
+			return nil
+		}
+		info, err := b.stat(key, path, metaPath)
+		if err != nil {
+			return nil // removed while listing
+		}
+		objects = append(objects, *info)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bucket: %w", err)
+	}
+
+	output, err := listObjectsV2(req.Bucket, objects, req.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	return listResponse(req, output)
+}
+
+// fileETag computes an S3-style ETag (quoted MD5) for a file without a sidecar
+func fileETag(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open object: %w", err)
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash object: %w", err)
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// readCloser pairs a reader with the closer of its underlying resource
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
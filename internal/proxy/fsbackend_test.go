@@ -0,0 +1,247 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFSBackend(t *testing.T) *FilesystemBackend {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "tenant-001-data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewFilesystemBackend(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return backend
+}
+
+func fsRequest(method, action, bucket, key, body string) *S3Request {
+	req := &S3Request{
+		Bucket:      bucket,
+		Key:         key,
+		Action:      action,
+		HTTPMethod:  method,
+		Headers:     make(http.Header),
+		QueryParams: url.Values{},
+	}
+	if op, ok := classify(method, key, req.QueryParams, req.Headers); ok {
+		req.Operation = op.name
+	}
+	if body != "" {
+		req.Body = io.NopCloser(strings.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	return req
+}
+
+func TestFilesystemBackend_PutGetDelete(t *testing.T) {
+	backend := newTestFSBackend(t)
+	ctx := context.Background()
+
+	put := fsRequest("PUT", "s3:PutObject", "tenant-001-data", "reports/2024/q1.csv", "a,b,c\n")
+	put.Headers.Set("Content-Type", "text/csv")
+	resp, err := backend.Forward(ctx, put)
+	if err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	etag := resp.Headers.Get("ETag")
+	if etag != `"c55816ab61248b6b5a7ba3448e9e5384"` {
+		t.Errorf("unexpected ETag %q", etag)
+	}
+
+	resp, err = backend.Forward(ctx, fsRequest("GET", "s3:GetObject", "tenant-001-data", "reports/2024/q1.csv", ""))
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "a,b,c\n" {
+		t.Errorf("body = %q", body)
+	}
+	if resp.Headers.Get("Content-Type") != "text/csv" {
+		t.Errorf("Content-Type = %q, want text/csv", resp.Headers.Get("Content-Type"))
+	}
+	if resp.Headers.Get("ETag") != etag {
+		t.Errorf("ETag = %q, want %q", resp.Headers.Get("ETag"), etag)
+	}
+
+	resp, err = backend.Forward(ctx, fsRequest("DELETE", "s3:DeleteObject", "tenant-001-data", "reports/2024/q1.csv", ""))
+	if err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", resp.StatusCode)
+	}
+
+	_, err = backend.Forward(ctx, fsRequest("GET", "s3:GetObject", "tenant-001-data", "reports/2024/q1.csv", ""))
+	var backendErr *BackendError
+	if !errors.As(err, &backendErr) || backendErr.Code != "NoSuchKey" {
+		t.Errorf("expected NoSuchKey after delete, got %v", err)
+	}
+}
+
+func TestFilesystemBackend_RangeAndHead(t *testing.T) {
+	backend := newTestFSBackend(t)
+	ctx := context.Background()
+
+	if _, err := backend.Forward(ctx, fsRequest("PUT", "s3:PutObject", "tenant-001-data", "file.txt", "0123456789")); err != nil {
+		t.Fatal(err)
+	}
+
+	get := fsRequest("GET", "s3:GetObject", "tenant-001-data", "file.txt", "")
+	get.Headers.Set("Range", "bytes=2-5")
+	resp, err := backend.Forward(ctx, get)
+	if err != nil {
+		t.Fatalf("range get failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "2345" {
+		t.Errorf("got %d %q, want 206 \"2345\"", resp.StatusCode, body)
+	}
+	if resp.Headers.Get("Content-Range") != "bytes 2-5/10" {
+		t.Errorf("Content-Range = %q", resp.Headers.Get("Content-Range"))
+	}
+
+	resp, err = backend.Forward(ctx, fsRequest("HEAD", "s3:GetObject", "tenant-001-data", "file.txt", ""))
+	if err != nil {
+		t.Fatalf("head failed: %v", err)
+	}
+	if resp.Body != nil {
+		t.Error("expected no body for HEAD")
+	}
+	if resp.Headers.Get("Content-Length") != "10" {
+		t.Errorf("Content-Length = %q, want 10", resp.Headers.Get("Content-Length"))
+	}
+}
+
+func TestFilesystemBackend_List(t *testing.T) {
+	backend := newTestFSBackend(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"a.txt", "logs/1.log", "logs/2.log", "z.txt"} {
+		if _, err := backend.Forward(ctx, fsRequest("PUT", "s3:PutObject", "tenant-001-data", key, "x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := fsRequest("GET", "s3:ListBucket", "tenant-001-data", "", "")
+	list.QueryParams.Set("delimiter", "/")
+	resp, err := backend.Forward(ctx, list)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	xml := string(body)
+
+	for _, want := range []string{"<Key>a.txt</Key>", "<Key>z.txt</Key>", "<Prefix>logs/</Prefix>"} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("listing missing %s: %s", want, xml)
+		}
+	}
+	if strings.Contains(xml, "logs/1.log") {
+		t.Errorf("keys under a common prefix should be grouped: %s", xml)
+	}
+	if strings.Contains(xml, ".meta") {
+		t.Errorf("metadata sidecars must not be listed: %s", xml)
+	}
+}
+
+func TestFilesystemBackend_RejectsTraversal(t *testing.T) {
+	backend := newTestFSBackend(t)
+
+	for _, key := range []string{"../escape.txt", "a/../../escape.txt", "/abs"} {
+		_, err := backend.Forward(context.Background(), fsRequest("PUT", "s3:PutObject", "tenant-001-data", key, "x"))
+		var backendErr *BackendError
+		if !errors.As(err, &backendErr) || backendErr.Code != "InvalidArgument" {
+			t.Errorf("key %q: expected InvalidArgument, got %v", key, err)
+		}
+	}
+}
+
+func TestFilesystemBackend_NoSuchBucket(t *testing.T) {
+	backend := newTestFSBackend(t)
+
+	for _, bucket := range []string{"missing-bucket", ".meta"} {
+		_, err := backend.Forward(context.Background(), fsRequest("GET", "s3:ListBucket", bucket, "", ""))
+		var backendErr *BackendError
+		if !errors.As(err, &backendErr) || backendErr.Code != "NoSuchBucket" {
+			t.Errorf("bucket %q: expected NoSuchBucket, got %v", bucket, err)
+		}
+	}
+}
+
+func TestLocalBackends_DispatchOnOperation(t *testing.T) {
+	backends := map[string]Forwarder{
+		"filesystem": newTestFSBackend(t),
+		"memory":     NewMemoryBackend("tenant-001-data"),
+	}
+	for name, backend := range backends {
+		ctx := context.Background()
+		resp, err := backend.Forward(ctx, fsRequest("HEAD", "s3:ListBucket", "tenant-001-data", "", ""))
+		if err != nil || resp.StatusCode != http.StatusOK || resp.Body != nil {
+			t.Errorf("%s: HeadBucket = %+v, %v; want 200 without a listing", name, resp, err)
+		}
+		_, err = backend.Forward(ctx, fsRequest("HEAD", "s3:ListBucket", "missing-bucket", "", ""))
+		var backendErr *BackendError
+		if !errors.As(err, &backendErr) || backendErr.Code != "NoSuchBucket" {
+			t.Errorf("%s: HeadBucket of a missing bucket: expected NoSuchBucket, got %v", name, err)
+		}
+
+		// CopyObject authorizes as s3:PutObject but is not an upload
+		copyReq := fsRequest("PUT", "s3:PutObject", "tenant-001-data", "copy.txt", "")
+		copyReq.Operation = "CopyObject"
+		_, err = backend.Forward(ctx, copyReq)
+		if !errors.As(err, &backendErr) || backendErr.Code != "NotImplemented" {
+			t.Errorf("%s: CopyObject: expected NotImplemented, got %v", name, err)
+		}
+	}
+}
+
+func TestFilesystemBackend_ConditionalWrites(t *testing.T) {
+	backend := newTestFSBackend(t)
+	ctx := context.Background()
+	put := func(header, value string) (*S3Response, error) {
+		req := fsRequest("PUT", "s3:PutObject", "tenant-001-data", "lock.json", "v")
+		req.Headers.Set(header, value)
+		return backend.Forward(ctx, req)
+	}
+	code := func(err error) string {
+		var backendErr *BackendError
+		if errors.As(err, &backendErr) {
+			return backendErr.Code
+		}
+		return ""
+	}
+
+	if _, err := put("If-Match", `"abc"`); code(err) != "NoSuchKey" {
+		t.Errorf("If-Match on a missing object: expected NoSuchKey, got %v", err)
+	}
+	resp, err := put("If-None-Match", "*")
+	if err != nil {
+		t.Fatalf("create if absent: %v", err)
+	}
+	etag := resp.Headers.Get("ETag")
+	if _, err := put("If-None-Match", "*"); code(err) != "PreconditionFailed" {
+		t.Errorf("create over an existing object: expected PreconditionFailed, got %v", err)
+	}
+	if _, err := put("If-None-Match", etag); code(err) != "NotImplemented" {
+		t.Errorf("If-None-Match with an ETag: expected NotImplemented, got %v", err)
+	}
+	if _, err := put("If-Match", `"stale"`); code(err) != "PreconditionFailed" {
+		t.Errorf("stale If-Match: expected PreconditionFailed, got %v", err)
+	}
+	if _, err := put("If-Match", etag); err != nil {
+		t.Errorf("matching If-Match: %v", err)
+	}
+}
@@ -0,0 +1,1364 @@
+package proxy_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/geoip"
+	"github.com/s3-access-control-adapter/internal/grant"
+	"github.com/s3-access-control-adapter/internal/metrics"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+	"github.com/s3-access-control-adapter/internal/tenant"
+)
+
+var (
+	writerCred = config.Credential{
+		AccessKey: "AKIAWRITER0000000001",
+		SecretKey: "writer-secret",
+		ClientID:  "writer",
+		TenantID:  "tenant-001",
+		Policies:  []string{"tenant-001-full-access"},
+		Scopes:    []string{"tenant-001-*"},
+	}
+	readerCred = config.Credential{
+		AccessKey: "AKIAREADER0000000001",
+		SecretKey: "reader-secret",
+		ClientID:  "reader",
+		TenantID:  "tenant-001",
+		Policies:  []string{"tenant-001-readonly"},
+		Scopes:    []string{"tenant-001-*"},
+	}
+	testPolicies = []config.Policy{
+		{
+			Name: "tenant-001-full-access",
+			Statements: []config.Statement{{
+				Sid:       "AllowAll",
+				Effect:    config.EffectAllow,
+				Actions:   []string{"s3:*"},
+				Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+			}},
+		},
+		{
+			Name: "tenant-001-readonly",
+			Statements: []config.Statement{{
+				Sid:       "AllowRead",
+				Effect:    config.EffectAllow,
+				Actions:   []string{"s3:GetObject", "s3:ListBucket"},
+				Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+			}},
+		},
+	}
+)
+
+func newTestGateway(t *testing.T) *proxytest.Gateway {
+	return proxytest.NewGateway(t, []config.Credential{writerCred, readerCred}, testPolicies,
+		"tenant-001-data", "tenant-002-data")
+}
+
+func TestGateway_PutGetList(t *testing.T) {
+	gw := newTestGateway(t)
+
+	resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/docs/readme.txt", []byte("hello"))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put status = %d: %s", resp.StatusCode, proxytest.ReadBody(t, resp))
+	}
+
+	resp = gw.Do(t, readerCred, http.MethodGet, "/tenant-001-data/docs/readme.txt", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("get status = %d", resp.StatusCode)
+	}
+	if body := proxytest.ReadBody(t, resp); body != "hello" {
+		t.Errorf("body = %q, want hello", body)
+	}
+
+	resp = gw.Do(t, readerCred, http.MethodGet, "/tenant-001-data?list-type=2&prefix=docs/", nil)
+	if body := proxytest.ReadBody(t, resp); !strings.Contains(body, "<Key>docs/readme.txt</Key>") {
+		t.Errorf("listing missing key: %s", body)
+	}
+
+	entry := gw.Audit.Last()
+	if entry == nil || entry.Decision != "allow" || entry.ClientID != "reader" || entry.Action != "s3:ListBucket" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestGateway_ReadOnlyCannotWrite(t *testing.T) {
+	gw := newTestGateway(t)
+
+	resp := gw.Do(t, readerCred, http.MethodPut, "/tenant-001-data/file.txt", []byte("nope"))
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+	if _, ok := gw.Backend.GetObject("tenant-001-data", "file.txt"); ok {
+		t.Error("denied write reached the backend")
+	}
+
+	entry := gw.Audit.Last()
+	if entry == nil || entry.Decision != "deny" || entry.DenyReason != "DENY_POLICY" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestGateway_TenantBoundary(t *testing.T) {
+	gw := newTestGateway(t)
+	if err := gw.Backend.PutObject("tenant-002-data", "secret.txt", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-002-data/secret.txt", nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+
+	entry := gw.Audit.Last()
+	if entry == nil || entry.DenyReason != "DENY_TENANT_BOUNDARY" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestGateway_BadSignature(t *testing.T) {
+	gw := newTestGateway(t)
+
+	forged := writerCred
+	forged.SecretKey = "wrong-secret"
+	resp := gw.Do(t, forged, http.MethodGet, "/tenant-001-data/file.txt", nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+
+	entry := gw.Audit.Last()
+	if entry == nil || entry.DenyReason != "DENY_AUTH_FAILED" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestGateway_AuthFailures(t *testing.T) {
+	gw := newTestGateway(t)
+	unknown := writerCred
+	unknown.AccessKey = "AKIAUNKNOWN000000001"
+
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+		code   string
+		reason string
+	}{
+		{"unknown key", func() *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, gw.URL+"/tenant-001-data/f", nil)
+			proxytest.Sign(t, req, unknown, nil)
+			return req
+		}, http.StatusForbidden, "InvalidAccessKeyId", "DENY_INVALID_ACCESS_KEY"},
+		{"no authorization", func() *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, gw.URL+"/tenant-001-data/f", nil)
+			return req
+		}, http.StatusBadRequest, "MissingSecurityHeader", "DENY_MISSING_AUTH_HEADER"},
+		{"no date", func() *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, gw.URL+"/tenant-001-data/f", nil)
+			proxytest.Sign(t, req, writerCred, nil)
+			req.Header.Del("X-Amz-Date")
+			return req
+		}, http.StatusBadRequest, "MissingSecurityHeader", "DENY_MISSING_AUTH_HEADER"},
+		{"skewed clock", func() *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, gw.URL+"/tenant-001-data/f", nil)
+			proxytest.Sign(t, req, writerCred, nil)
+			req.Header.Set("X-Amz-Date", time.Now().UTC().Add(-time.Hour).Format("20060102T150405Z"))
+			return req
+		}, http.StatusForbidden, "RequestTimeTooSkewed", "DENY_REQUEST_TIME_SKEWED"},
+	}
+	for _, tt := range tests {
+		resp, err := gw.Client().Do(tt.req())
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := proxytest.ReadBody(t, resp)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || !strings.Contains(body, "<Code>"+tt.code+"</Code>") {
+			t.Errorf("%s: status = %d, body = %s; want %d %s", tt.name, resp.StatusCode, body, tt.status, tt.code)
+		}
+		if entry := gw.Audit.Last(); entry == nil || entry.DenyReason != tt.reason {
+			t.Errorf("%s: unexpected audit entry: %+v", tt.name, entry)
+		}
+	}
+}
+
+func TestGateway_MissingKey(t *testing.T) {
+	gw := newTestGateway(t)
+
+	resp := gw.Do(t, readerCred, http.MethodGet, "/tenant-001-data/missing.txt", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+	if body := proxytest.ReadBody(t, resp); !strings.Contains(body, "<Code>NoSuchKey</Code>") {
+		t.Errorf("expected NoSuchKey error, got %s", body)
+	}
+}
+
+func TestGateway_MaintenanceMode(t *testing.T) {
+	gw := newTestGateway(t)
+	gw.Gateway.SetMaintenance(true)
+
+	resp := gw.Do(t, readerCred, http.MethodGet, "/tenant-001-data/a.txt", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if body := proxytest.ReadBody(t, resp); !strings.Contains(body, "<Code>SlowDown</Code>") {
+		t.Errorf("body = %s", body)
+	}
+
+	health, err := gw.Client().Get(gw.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	health.Body.Close()
+	if health.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("health status = %d, want 503", health.StatusCode)
+	}
+
+	gw.Gateway.SetMaintenance(false)
+	resp = gw.Do(t, readerCred, http.MethodGet, "/tenant-001-data/a.txt", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status after maintenance = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestGateway_Readiness(t *testing.T) {
+	readyz := func(gw *proxytest.Gateway) (int, proxy.ConfigVersions) {
+		t.Helper()
+		resp, err := gw.Client().Get(gw.URL + "/readyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var v proxy.ConfigVersions
+		if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, v
+	}
+
+	a := newTestGateway(t)
+	b := newTestGateway(t)
+	status, va := readyz(a)
+	if status != http.StatusOK || va.Policies == "" || va.Credentials == "" || va.Fingerprint == "" {
+		t.Fatalf("status = %d, versions = %+v", status, va)
+	}
+	if _, vb := readyz(b); vb != va {
+		t.Errorf("same configuration: versions %+v, want %+v", vb, va)
+	}
+
+	changed := proxytest.NewGateway(t, []config.Credential{writerCred, readerCred}, testPolicies[:1], "tenant-001-data")
+	_, vc := readyz(changed)
+	if vc.Policies == va.Policies || vc.Fingerprint == va.Fingerprint || vc.Credentials != va.Credentials {
+		t.Errorf("changed policies: versions %+v, was %+v", vc, va)
+	}
+
+	a.Gateway.SetMaintenance(true)
+	if status, _ := readyz(a); status != http.StatusServiceUnavailable {
+		t.Errorf("status in maintenance = %d, want 503", status)
+	}
+}
+
+func TestGateway_Mirror(t *testing.T) {
+	staging := proxy.NewMemoryBackend("tenant-001-data")
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred, readerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithMirror(staging, &config.MirrorConfig{SampleRate: 1, MaxBodyBytes: 8, MaxInFlight: 4}))
+
+	for key, body := range map[string]string{"small.txt": "hello", "large.txt": "more than eight bytes"} {
+		resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/"+key, []byte(body))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("PUT %s status = %d", key, resp.StatusCode)
+		}
+		proxytest.ReadBody(t, resp)
+		if data, _ := gw.Backend.GetObject("tenant-001-data", key); string(data) != body {
+			t.Errorf("primary %s = %q, want %q", key, data, body)
+		}
+	}
+
+	// Small bodies are mirrored in full, larger ones without a body
+	want := map[string]string{"small.txt": "hello", "large.txt": ""}
+	deadline := time.Now().Add(5 * time.Second)
+	for key, body := range want {
+		for {
+			data, ok := staging.GetObject("tenant-001-data", key)
+			if ok {
+				if string(data) != body {
+					t.Errorf("mirrored %s = %q, want %q", key, data, body)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s was not mirrored", key)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Denied requests are not mirrored
+	resp := gw.Do(t, readerCred, http.MethodPut, "/tenant-001-data/denied.txt", []byte("x"))
+	proxytest.ReadBody(t, resp)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", resp.StatusCode)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := staging.GetObject("tenant-001-data", "denied.txt"); ok {
+		t.Error("denied request was mirrored")
+	}
+}
+
+func TestGateway_ForwarderErrors(t *testing.T) {
+	var forwarded []string
+	backend := proxy.ForwarderFunc(func(ctx context.Context, req *proxy.S3Request) (*proxy.S3Response, error) {
+		forwarded = append(forwarded, req.Key)
+		switch req.Key {
+		case "slow":
+			return nil, &proxy.BackendError{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown",
+				Message: "Reduce your request rate.", Headers: http.Header{"Retry-After": {"2"}}}
+		case "broken":
+			return nil, errors.New("connection reset by peer")
+		}
+		return &proxy.S3Response{StatusCode: http.StatusOK, Headers: http.Header{}}, nil
+	})
+	gw := proxytest.NewGatewayWithForwarder(t, []config.Credential{writerCred, readerCred}, testPolicies, backend)
+
+	tests := []struct {
+		cred   config.Credential
+		method string
+		key    string
+		status int
+		code   string
+	}{
+		{readerCred, http.MethodGet, "ok", http.StatusOK, ""},
+		{readerCred, http.MethodGet, "slow", http.StatusServiceUnavailable, "SlowDown"},
+		{readerCred, http.MethodGet, "broken", http.StatusInternalServerError, "InternalError"},
+		{readerCred, http.MethodDelete, "denied", http.StatusForbidden, "AccessDenied"},
+	}
+	for _, tt := range tests {
+		resp := gw.Do(t, tt.cred, tt.method, "/tenant-001-data/"+tt.key, nil)
+		body := proxytest.ReadBody(t, resp)
+		if resp.StatusCode != tt.status || !strings.Contains(body, tt.code) {
+			t.Errorf("%s %s: status = %d, body = %s", tt.method, tt.key, resp.StatusCode, body)
+		}
+		if tt.code == "SlowDown" && resp.Header.Get("Retry-After") != "2" {
+			t.Errorf("Retry-After = %q, want 2", resp.Header.Get("Retry-After"))
+		}
+		if entry := gw.Audit.Last(); tt.key == "broken" && (entry.DenyReason != "S3_ERROR" || entry.ErrorMsg == "") {
+			t.Errorf("audit entry: reason=%q error=%q", entry.DenyReason, entry.ErrorMsg)
+		}
+	}
+	if !reflect.DeepEqual(forwarded, []string{"ok", "slow", "broken"}) {
+		t.Errorf("forwarded = %v", forwarded)
+	}
+}
+
+func TestGateway_UnknownOperation(t *testing.T) {
+	var forwarded int
+	backend := proxy.ForwarderFunc(func(ctx context.Context, req *proxy.S3Request) (*proxy.S3Response, error) {
+		forwarded++
+		return &proxy.S3Response{StatusCode: http.StatusOK, Headers: http.Header{}}, nil
+	})
+	gw := proxytest.NewGatewayWithForwarder(t, []config.Credential{writerCred}, testPolicies, backend)
+
+	for _, path := range []string{"/tenant-001-data/key", "/tenant-001-data/key?x-id=Bogus", "/tenant-001-data/key?acl&x-id=PutObject"} {
+		resp := gw.Do(t, writerCred, http.MethodPost, path, []byte("x"))
+		body := proxytest.ReadBody(t, resp)
+		if resp.StatusCode != http.StatusNotImplemented || !strings.Contains(body, "NotImplemented") {
+			t.Errorf("POST %s: status = %d, body = %s", path, resp.StatusCode, body)
+		}
+		if entry := gw.Audit.Last(); entry == nil || entry.DenyReason != "DENY_NOT_IMPLEMENTED" {
+			t.Errorf("POST %s: unexpected audit entry: %+v", path, entry)
+		}
+	}
+	if forwarded != 0 {
+		t.Errorf("forwarded %d unknown operations", forwarded)
+	}
+
+	if resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/key?x-id=PutObject", []byte("x")); resp.StatusCode != http.StatusOK {
+		t.Errorf("PutObject with x-id: status = %d", resp.StatusCode)
+	}
+}
+
+func TestGateway_OperationAllowlist(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations []string // nil for the default
+		method     string
+		path       string
+		status     int
+		message    string
+	}{
+		{"default get", nil, http.MethodGet, "/tenant-001-data/key", http.StatusOK, ""},
+		{"default complete upload", nil, http.MethodPost, "/tenant-001-data/key?uploadId=u", http.StatusNotImplemented,
+			"CompleteMultipartUpload is not implemented"},
+		{"default bucket acl", nil, http.MethodGet, "/tenant-001-data?acl", http.StatusNotImplemented, "GetBucketAcl is not implemented"},
+		{"allowed", []string{"GetObject"}, http.MethodGet, "/tenant-001-data/key", http.StatusOK, ""},
+		{"not allowed", []string{"GetObject"}, http.MethodDelete, "/tenant-001-data/key", http.StatusNotImplemented,
+			"DeleteObject is not implemented"},
+	}
+	backend := proxy.ForwarderFunc(func(ctx context.Context, req *proxy.S3Request) (*proxy.S3Response, error) {
+		return &proxy.S3Response{StatusCode: http.StatusOK, Headers: http.Header{}}, nil
+	})
+	for _, tt := range tests {
+		var opts []proxy.Option
+		if tt.operations != nil {
+			opts = append(opts, proxy.WithOperations(tt.operations))
+		}
+		gw := proxytest.NewGatewayWithForwarder(t, []config.Credential{writerCred}, testPolicies, backend, opts...)
+		resp := gw.Do(t, writerCred, tt.method, tt.path, nil)
+		body := proxytest.ReadBody(t, resp)
+		if resp.StatusCode != tt.status || !strings.Contains(body, tt.message) {
+			t.Errorf("%s: status = %d, body = %s", tt.name, resp.StatusCode, body)
+		}
+	}
+
+	// A copy is not a PutObject with an empty body
+	gw := proxytest.NewGatewayWithForwarder(t, []config.Credential{writerCred}, testPolicies, backend)
+	req, _ := http.NewRequest(http.MethodPut, gw.URL+"/tenant-001-data/copy", http.NoBody)
+	req.Header.Set("X-Amz-Copy-Source", "tenant-001-data/key")
+	proxytest.Sign(t, req, writerCred, nil)
+	resp, err := gw.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("CopyObject: status = %d, want 501", resp.StatusCode)
+	}
+}
+
+func TestGateway_MultipartLimits(t *testing.T) {
+	var forwarded []string
+	backend := proxy.ForwarderFunc(func(ctx context.Context, req *proxy.S3Request) (*proxy.S3Response, error) {
+		if req.Body != nil {
+			io.Copy(io.Discard, req.Body)
+		}
+		forwarded = append(forwarded, req.Operation)
+		return &proxy.S3Response{StatusCode: http.StatusOK, Headers: http.Header{}}, nil
+	})
+	cfg := &config.MultipartConfig{
+		MultipartLimits: config.MultipartLimits{MinPartSize: 5, MaxPartSize: 10, MaxParts: 3, MaxUploadSize: 25},
+		Tenants:         map[string]config.MultipartLimits{"tenant-other": {}},
+	}
+	gw := proxytest.NewGatewayWithForwarder(t, []config.Credential{writerCred}, testPolicies, backend,
+		proxy.WithOperations([]string{"UploadPart", "CompleteMultipartUpload", "AbortMultipartUpload"}),
+		proxy.WithMultipartLimits(cfg))
+
+	part := func(n int, size int) (int, string) {
+		resp := gw.Do(t, writerCred, http.MethodPut,
+			fmt.Sprintf("/tenant-001-data/big?partNumber=%d&uploadId=u1", n), bytes.Repeat([]byte("x"), size))
+		return resp.StatusCode, proxytest.ReadBody(t, resp)
+	}
+	complete := func(parts ...int) (int, string) {
+		var b strings.Builder
+		b.WriteString("<CompleteMultipartUpload>")
+		for _, n := range parts {
+			fmt.Fprintf(&b, "<Part><PartNumber>%d</PartNumber><ETag>e</ETag></Part>", n)
+		}
+		b.WriteString("</CompleteMultipartUpload>")
+		resp := gw.Do(t, writerCred, http.MethodPost, "/tenant-001-data/big?uploadId=u1", []byte(b.String()))
+		return resp.StatusCode, proxytest.ReadBody(t, resp)
+	}
+
+	if status, body := part(4, 5); status != http.StatusBadRequest || !strings.Contains(body, "InvalidArgument") {
+		t.Errorf("part number over maxParts: status = %d, body = %s", status, body)
+	}
+	if entry := gw.Audit.Last(); entry.DenyReason != "DENY_UPLOAD_LIMIT" || entry.ErrorMsg == "" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+	if status, body := part(1, 11); status != http.StatusBadRequest || !strings.Contains(body, "EntityTooLarge") {
+		t.Errorf("part over maxPartSize: status = %d, body = %s", status, body)
+	}
+	for n, size := range []int{10, 3, 10} {
+		if status, body := part(n+1, size); status != http.StatusOK {
+			t.Fatalf("part %d: status = %d, body = %s", n+1, status, body)
+		}
+	}
+	// A new upload of a part replaces its size
+	if status, body := part(3, 10); status != http.StatusOK {
+		t.Errorf("re-uploaded part: status = %d, body = %s", status, body)
+	}
+	if status, body := complete(1, 2, 3, 4); status != http.StatusBadRequest || !strings.Contains(body, "InvalidArgument") {
+		t.Errorf("too many parts: status = %d, body = %s", status, body)
+	}
+	if status, body := complete(1, 2, 3); status != http.StatusBadRequest || !strings.Contains(body, "EntityTooSmall") {
+		t.Errorf("small part: status = %d, body = %s", status, body)
+	}
+	if status, body := part(2, 6); status != http.StatusBadRequest || !strings.Contains(body, "EntityTooLarge") {
+		t.Errorf("upload over maxUploadSize: status = %d, body = %s", status, body)
+	}
+	if status, body := part(2, 5); status != http.StatusOK {
+		t.Fatalf("part 2: status = %d, body = %s", status, body)
+	}
+	if status, body := complete(1, 2, 3); status != http.StatusOK {
+		t.Errorf("complete: status = %d, body = %s", status, body)
+	}
+	if got := forwarded[len(forwarded)-1]; got != "CompleteMultipartUpload" {
+		t.Errorf("last forwarded = %s, want CompleteMultipartUpload", got)
+	}
+}
+
+func TestGateway_AuditEnrichment(t *testing.T) {
+	gw := newTestGateway(t)
+
+	resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/file.txt", []byte("hello"))
+	proxytest.ReadBody(t, resp)
+	entry := gw.Audit.Last()
+	if entry.BytesIn != 5 || entry.Protocol != "HTTP/1.1" || entry.AccessKeyID != writerCred.AccessKey {
+		t.Errorf("unexpected put entry: %+v", entry)
+	}
+	if !strings.Contains(strings.Join(entry.SignedHeaders, ";"), "host") {
+		t.Errorf("signed headers = %v, want host included", entry.SignedHeaders)
+	}
+	if entry.TLSVersion != "" {
+		t.Errorf("tls version = %q for a plain HTTP request", entry.TLSVersion)
+	}
+
+	resp = gw.Do(t, readerCred, http.MethodGet, "/tenant-001-data/file.txt", nil)
+	proxytest.ReadBody(t, resp)
+	if entry := gw.Audit.Last(); entry.BytesIn != 0 || entry.BytesOut != 5 {
+		t.Errorf("get bytes in/out = %d/%d, want 0/5", entry.BytesIn, entry.BytesOut)
+	}
+
+	resp = gw.Do(t, readerCred, http.MethodPut, "/tenant-001-data/file.txt", []byte("denied"))
+	body := proxytest.ReadBody(t, resp)
+	if entry := gw.Audit.Last(); entry.Decision != "deny" || entry.BytesOut != int64(len(body)) || entry.AccessKeyID != readerCred.AccessKey {
+		t.Errorf("unexpected deny entry: %+v", entry)
+	}
+}
+
+func TestGateway_CorrelationID(t *testing.T) {
+	gw := newTestGateway(t)
+
+	req, err := http.NewRequest(http.MethodGet, gw.URL+"/tenant-001-data?list-type=2", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Request-ID", "client-req-42")
+	proxytest.Sign(t, req, readerCred, nil)
+	resp, err := gw.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := resp.Header.Get("X-Request-ID"); got != "client-req-42" {
+		t.Errorf("response X-Request-ID = %q", got)
+	}
+	entry := gw.Audit.Last()
+	if entry.CorrelationID != "client-req-42" || entry.RequestID == "" || entry.RequestID == entry.CorrelationID {
+		t.Errorf("unexpected audit ids: requestId=%q correlationId=%q", entry.RequestID, entry.CorrelationID)
+	}
+}
+
+func TestGateway_AuditFailureMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		failClosed bool
+		wantStatus int
+	}{
+		{"fail open serves the request", false, http.StatusOK},
+		{"fail closed rejects the request", true, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred, readerCred}, testPolicies,
+				[]string{"tenant-001-data"}, proxy.WithAuditFailClosed(tt.failClosed))
+			gw.Backend.PutObject("tenant-001-data", "file.txt", []byte("secret data"))
+			gw.Audit.SetError(errors.New("disk full"))
+
+			resp := gw.Do(t, readerCred, http.MethodGet, "/tenant-001-data/file.txt", nil)
+			body := proxytest.ReadBody(t, resp)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.failClosed && strings.Contains(body, "secret data") {
+				t.Error("object data released without an audit record")
+			}
+		})
+	}
+}
+
+// staticGeo places every address in one country
+type staticGeo geoip.Info
+
+func (g staticGeo) Lookup(ip string) geoip.Info { return geoip.Info(g) }
+
+func TestGateway_SourceCountry(t *testing.T) {
+	geoCred := config.Credential{
+		AccessKey: "AKIAGEOFENCED0000001",
+		SecretKey: "geo-secret",
+		ClientID:  "geo",
+		TenantID:  "tenant-001",
+		Policies:  []string{"eu-only"},
+		Scopes:    []string{"tenant-001-*"},
+	}
+	policies := []config.Policy{{
+		Name: "eu-only",
+		Statements: []config.Statement{{
+			Effect:     config.EffectAllow,
+			Actions:    []string{"s3:GetObject"},
+			Resources:  []string{"arn:aws:s3:::tenant-001-*/*"},
+			Conditions: map[string]map[string]config.ConditionValues{"StringEquals": {"aws:SourceCountry": {"DE"}}},
+		}},
+	}}
+
+	tests := []struct {
+		name       string
+		geo        staticGeo
+		wantStatus int
+	}{
+		{"allowed country", staticGeo{Country: "DE", ASN: 3320}, http.StatusOK},
+		{"other country", staticGeo{Country: "US"}, http.StatusForbidden},
+		{"unknown location", staticGeo{}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := proxytest.NewGatewayWithOptions(t, []config.Credential{geoCred}, policies,
+				[]string{"tenant-001-data"}, proxy.WithGeoIP(tt.geo))
+			gw.Backend.PutObject("tenant-001-data", "file.txt", []byte("data"))
+
+			resp := gw.Do(t, geoCred, http.MethodGet, "/tenant-001-data/file.txt", nil)
+			proxytest.ReadBody(t, resp)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			entry := gw.Audit.Last()
+			if entry.Country != tt.geo.Country || entry.ASN != tt.geo.ASN {
+				t.Errorf("audit location = %q/%d, want %q/%d", entry.Country, entry.ASN, tt.geo.Country, tt.geo.ASN)
+			}
+		})
+	}
+}
+
+func TestGateway_Chaos(t *testing.T) {
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithChaos(&config.ChaosConfig{
+			Enabled: true,
+			Rules: []config.ChaosRule{
+				{Actions: []string{"s3:GetObject"}, ErrorRate: 1},
+				{Actions: []string{"s3:PutObject"}, ResetRate: 1},
+			},
+		}))
+
+	resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data/key", nil)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("get status = %d, want 500", resp.StatusCode)
+	}
+	if entry := gw.Audit.Last(); entry == nil || entry.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected audited 500, got %+v", entry)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, gw.URL+"/tenant-001-data/key", strings.NewReader("data"))
+	proxytest.Sign(t, req, writerCred, []byte("data"))
+	if resp, err := gw.Client().Do(req); err == nil {
+		resp.Body.Close()
+		t.Errorf("put: expected connection reset, got status %d", resp.StatusCode)
+	}
+	if _, ok := gw.Backend.GetObject("tenant-001-data", "key"); ok {
+		t.Error("reset put reached the backend")
+	}
+
+	resp = gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data?list-type=2", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("list status = %d, want 200 without a matching rule", resp.StatusCode)
+	}
+}
+
+func TestGateway_VerboseDenyMessages(t *testing.T) {
+	denySecrets := config.Policy{
+		Name: "deny-secrets",
+		Statements: []config.Statement{{
+			Sid:       "DenySecrets",
+			Effect:    config.EffectDeny,
+			Actions:   []string{"s3:*"},
+			Resources: []string{"arn:aws:s3:::tenant-001-data/secrets/*"},
+		}},
+	}
+	cred := writerCred
+	cred.Policies = []string{"tenant-001-full-access", "deny-secrets"}
+	policies := append([]config.Policy{denySecrets}, testPolicies...)
+
+	tests := []struct {
+		verbose bool
+		cred    config.Credential
+		path    string
+		want    string
+	}{
+		{false, cred, "/tenant-001-data/secrets/key", "<Message>Access denied: action not permitted by policy</Message>"},
+		{true, cred, "/tenant-001-data/secrets/key", "(reason: DENY_POLICY, policy: deny-secrets, statement: DenySecrets)"},
+		{true, readerCred, "/tenant-001-data/key", "<Message>Access denied: action not permitted by policy (reason: DENY_POLICY)</Message>"},
+		{true, cred, "/tenant-002-data/key", "outside your tenant boundary (reason: DENY_TENANT_BOUNDARY)"},
+	}
+	for _, tt := range tests {
+		gw := proxytest.NewGatewayWithOptions(t, []config.Credential{tt.cred}, policies,
+			[]string{"tenant-001-data", "tenant-002-data"}, proxy.WithVerboseDenyMessages(tt.verbose))
+		resp := gw.Do(t, tt.cred, http.MethodPut, tt.path, []byte("x"))
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403", tt.path, resp.StatusCode)
+		}
+		if body := proxytest.ReadBody(t, resp); !strings.Contains(body, tt.want) {
+			t.Errorf("verbose=%v %s: body missing %q:\n%s", tt.verbose, tt.path, tt.want, body)
+		}
+	}
+}
+
+func TestGateway_DenyReasonHeader(t *testing.T) {
+	tests := []struct {
+		enabled  bool
+		cred     config.Credential
+		path     string
+		header   string
+		category string
+	}{
+		{true, writerCred, "/tenant-002-data/key", "DENY_TENANT_BOUNDARY", "scope"},
+		{true, readerCred, "/tenant-001-data/key", "DENY_POLICY", "policy"},
+		{false, readerCred, "/tenant-001-data/key", "", "policy"},
+	}
+	for _, tt := range tests {
+		gw := proxytest.NewGatewayWithOptions(t, []config.Credential{tt.cred}, testPolicies,
+			[]string{"tenant-001-data", "tenant-002-data"}, proxy.WithDenyReasonHeader(tt.enabled))
+		resp := gw.Do(t, tt.cred, http.MethodPut, tt.path, []byte("x"))
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403", tt.path, resp.StatusCode)
+		}
+		if got := resp.Header.Get(proxy.DenyReasonHeader); got != tt.header {
+			t.Errorf("enabled=%v %s: header = %q, want %q", tt.enabled, tt.path, got, tt.header)
+		}
+		if entry := gw.Audit.Last(); entry == nil || entry.DenyCategory != tt.category {
+			t.Errorf("%s: unexpected audit entry: %+v", tt.path, entry)
+		}
+	}
+}
+
+func TestGateway_DecisionMetrics(t *testing.T) {
+	credA, credB := readerCred, readerCred
+	credA.AccessKey, credA.ClientID, credA.TenantID = "AKIAMETRICSA00000001", "metrics-a", "metrics-a"
+	credB.AccessKey, credB.ClientID, credB.TenantID = "AKIAMETRICSB00000001", "metrics-b", "metrics-b"
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{credA, credB}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithMetricLabels(&config.MetricsConfig{
+			Labels:     []string{"tenant", "bucket", "action"},
+			MaxTenants: 1,
+			Buckets:    []string{"tenant-001-*"},
+		}))
+	if err := gw.Backend.PutObject("tenant-001-data", "file.txt", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(s [5]string) float64 {
+		want := map[string]string{"tenant": s[0], "client": "", "bucket": s[1], "action": s[2], "decision": s[3], "reason": s[4]}
+		for _, s := range metrics.Default.Gather() {
+			if s.Name == "gateway_decisions_total" && reflect.DeepEqual(s.Labels, want) {
+				return s.Value
+			}
+		}
+		return 0
+	}
+	series := [][5]string{
+		{"metrics-a", "tenant-001-data", "s3:GetObject", "allow", ""},
+		{"metrics-a", "tenant-001-data", "s3:PutObject", "deny", "DENY_POLICY"},
+		{metrics.OverflowValue, "tenant-001-data", "s3:GetObject", "allow", ""},
+		{"metrics-b", "tenant-001-data", "s3:GetObject", "allow", ""},
+		{"metrics-a", metrics.OverflowValue, "s3:GetObject", "deny", "DENY_TENANT_BOUNDARY"},
+	}
+	before := make([]float64, len(series))
+	for i, s := range series {
+		before[i] = count(s)
+	}
+
+	gw.Do(t, credA, http.MethodGet, "/tenant-001-data/file.txt", nil)
+	gw.Do(t, credA, http.MethodPut, "/tenant-001-data/file.txt", []byte("y"))
+	gw.Do(t, credB, http.MethodGet, "/tenant-001-data/file.txt", nil)
+	gw.Do(t, credA, http.MethodGet, "/random-bucket-1234/file.txt", nil)
+
+	for i, want := range []float64{1, 1, 1, 0, 1} {
+		s := series[i]
+		if got := count(s) - before[i]; got != want {
+			t.Errorf("%v: counted %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestGateway_PayloadModes(t *testing.T) {
+	gw := newTestGateway(t)
+
+	tests := []struct {
+		name    string
+		mode    string
+		headers map[string]string
+		body    string
+		status  int
+		code    string
+	}{
+		{"unsigned payload", "UNSIGNED-PAYLOAD", nil, "hello, world", http.StatusOK, ""},
+		{"streaming unsigned trailer", "STREAMING-UNSIGNED-PAYLOAD-TRAILER", map[string]string{
+			"Content-Encoding":             "aws-chunked",
+			"X-Amz-Decoded-Content-Length": "12",
+			"X-Amz-Trailer":                "x-amz-checksum-crc32",
+		}, "5\r\nhello\r\n7\r\n, world\r\n0\r\nx-amz-checksum-crc32:/6tyOg==\r\n\r\n", http.StatusOK, ""},
+		{"bad trailing checksum", "STREAMING-UNSIGNED-PAYLOAD-TRAILER", map[string]string{
+			"Content-Encoding":             "aws-chunked",
+			"X-Amz-Decoded-Content-Length": "12",
+			"X-Amz-Trailer":                "x-amz-checksum-crc32",
+		}, "5\r\nhello\r\n7\r\n, world\r\n0\r\nx-amz-checksum-crc32:AAAAAA==\r\n\r\n", http.StatusBadRequest, "BadDigest"},
+		{"payload hash mismatch", "0000000000000000000000000000000000000000000000000000000000000000", nil,
+			"hello, world", http.StatusBadRequest, "XAmzContentSHA256Mismatch"},
+	}
+	for _, tt := range tests {
+		key := "/tenant-001-data/" + strings.ReplaceAll(tt.name, " ", "-")
+		req, _ := http.NewRequest(http.MethodPut, gw.URL+key, strings.NewReader(tt.body))
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		proxytest.SignPayload(t, req, writerCred, tt.mode)
+		resp, err := gw.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := proxytest.ReadBody(t, resp)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || !strings.Contains(body, tt.code) {
+			t.Errorf("%s: status = %d, body = %s; want %d %s", tt.name, resp.StatusCode, body, tt.status, tt.code)
+			continue
+		}
+
+		resp = gw.Do(t, readerCred, http.MethodGet, key, nil)
+		got := proxytest.ReadBody(t, resp)
+		if tt.status == http.StatusOK && got != "hello, world" {
+			t.Errorf("%s: stored %q, want decoded payload", tt.name, got)
+		}
+		if tt.status != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: rejected upload was stored: %d %q", tt.name, resp.StatusCode, got)
+		}
+	}
+}
+
+func TestGateway_AllowedActions(t *testing.T) {
+	keyOnly := config.Credential{
+		AccessKey:      "AKIAKEYONLY000000001",
+		SecretKey:      "key-only-secret",
+		ClientID:       "key-only",
+		TenantID:       "tenant-001",
+		AllowedActions: []string{"s3:GetObject", "s3:ListBucket"},
+		Scopes:         []string{"tenant-001-*"},
+	}
+	gw := proxytest.NewGateway(t, []config.Credential{writerCred, keyOnly}, testPolicies, "tenant-001-data", "tenant-002-data")
+	if err := gw.Backend.PutObject("tenant-001-data", "report.csv", []byte("a,b")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/tenant-001-data/report.csv", http.StatusOK},
+		{http.MethodGet, "/tenant-001-data?list-type=2", http.StatusOK},
+		{http.MethodPut, "/tenant-001-data/report.csv", http.StatusForbidden},
+		{http.MethodGet, "/tenant-002-data/report.csv", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		resp := gw.Do(t, keyOnly, tt.method, tt.path, nil)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestGateway_TenantLifecycle(t *testing.T) {
+	registry, err := tenant.NewRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred, readerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithTenantStates(registry))
+
+	get := func() (int, string) {
+		resp := gw.Do(t, readerCred, http.MethodGet, "/tenant-001-data/missing.txt", nil)
+		return resp.StatusCode, proxytest.ReadBody(t, resp)
+	}
+
+	if status, _ := get(); status != http.StatusNotFound {
+		t.Fatalf("active tenant: status = %d, want 404", status)
+	}
+
+	registry.Suspend("tenant-001")
+	if status, body := get(); status != http.StatusForbidden || !strings.Contains(body, "<Code>AccountProblem</Code>") {
+		t.Errorf("suspended tenant: status = %d, body = %s", status, body)
+	}
+	if entry := gw.Audit.Last(); entry == nil || entry.DenyReason != "DENY_TENANT_SUSPENDED" || entry.ClientID != readerCred.ClientID {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+
+	registry.Resume("tenant-001")
+	if status, _ := get(); status != http.StatusNotFound {
+		t.Errorf("resumed tenant: status = %d, want 404", status)
+	}
+
+	registry.Delete("tenant-001", nil, nil)
+	if status, body := get(); status != http.StatusForbidden || !strings.Contains(body, "<Code>InvalidAccessKeyId</Code>") {
+		t.Errorf("deleted tenant: status = %d, body = %s", status, body)
+	}
+	if entry := gw.Audit.Last(); entry == nil || entry.DenyReason != "DENY_TENANT_DELETED" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestGateway_ListingLimits(t *testing.T) {
+	buckets := &config.BucketsConfig{Listing: config.ListingLimits{MaxKeys: 50, Tenants: map[string]int{"tenant-001": 2}}}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithBucketNaming(buckets))
+	for _, key := range []string{"a", "b", "c"} {
+		if err := gw.Backend.PutObject("tenant-001-data", key, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query   string
+		maxKeys string
+		keys    int
+	}{
+		{"", "2", 2},
+		{"&max-keys=1000", "2", 2},
+		{"&max-keys=1", "1", 1},
+	}
+	for _, tt := range tests {
+		resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data?list-type=2"+tt.query, nil)
+		body := proxytest.ReadBody(t, resp)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%q: status = %d, body = %s", tt.query, resp.StatusCode, body)
+		}
+		if !strings.Contains(body, "<MaxKeys>"+tt.maxKeys+"</MaxKeys>") || strings.Count(body, "<Contents>") != tt.keys {
+			t.Errorf("%q: want MaxKeys %s and %d keys, got %s", tt.query, tt.maxKeys, tt.keys, body)
+		}
+		if !strings.Contains(body, "<IsTruncated>true</IsTruncated>") {
+			t.Errorf("%q: listing not truncated: %s", tt.query, body)
+		}
+	}
+}
+
+func TestGateway_CreateDeleteBucket(t *testing.T) {
+	naming := &config.BucketsConfig{NamePattern: "^{tenantId}-[a-z0-9-]+$"}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred, readerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithBucketNaming(naming))
+	if err := gw.Backend.PutObject("tenant-001-data", "file.txt", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cred   config.Credential
+		method string
+		bucket string
+		status int
+		code   string
+	}{
+		{"create", writerCred, http.MethodPut, "tenant-001-new", http.StatusOK, ""},
+		{"create existing", writerCred, http.MethodPut, "tenant-001-new", http.StatusConflict, "BucketAlreadyOwnedByYou"},
+		{"outside scopes", writerCred, http.MethodPut, "tenant-002-new", http.StatusForbidden, "AccessDenied"},
+		{"naming convention", writerCred, http.MethodPut, "tenant-001-a.b", http.StatusBadRequest, "InvalidBucketName"},
+		{"invalid S3 name", writerCred, http.MethodPut, "tenant-001-", http.StatusBadRequest, "InvalidBucketName"},
+		{"not allowed by policy", readerCred, http.MethodPut, "tenant-001-other", http.StatusForbidden, "AccessDenied"},
+		{"delete non-empty", writerCred, http.MethodDelete, "tenant-001-data", http.StatusConflict, "BucketNotEmpty"},
+		{"delete", writerCred, http.MethodDelete, "tenant-001-new", http.StatusNoContent, ""},
+		{"delete missing", writerCred, http.MethodDelete, "tenant-001-new", http.StatusNotFound, "NoSuchBucket"},
+	}
+	for _, tt := range tests {
+		resp := gw.Do(t, tt.cred, tt.method, "/"+tt.bucket, nil)
+		body := proxytest.ReadBody(t, resp)
+		if resp.StatusCode != tt.status || (tt.code != "" && !strings.Contains(body, "<Code>"+tt.code+"</Code>")) {
+			t.Errorf("%s: status = %d, body = %s; want %d %s", tt.name, resp.StatusCode, body, tt.status, tt.code)
+		}
+	}
+	if entry := gw.Audit.Last(); entry == nil || entry.Action != "s3:DeleteBucket" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestGateway_BucketTagConditions(t *testing.T) {
+	teamCred := config.Credential{
+		AccessKey: "AKIATEAM000000000001",
+		SecretKey: "team-secret",
+		ClientID:  "analytics-job",
+		TenantID:  "tenant-001",
+		Policies:  []string{"team-analytics"},
+		Scopes:    []string{"tenant-001-*"},
+	}
+	policies := []config.Policy{{
+		Name: "team-analytics",
+		Statements: []config.Statement{
+			{
+				Sid:        "AllowTeamBuckets",
+				Effect:     config.EffectAllow,
+				Actions:    []string{"s3:GetObject", "s3:ListBucket"},
+				Resources:  []string{"arn:aws:s3:::*", "arn:aws:s3:::*/*"},
+				Conditions: map[string]map[string]config.ConditionValues{"StringEquals": {"s3:ResourceTag/team": {"analytics"}}},
+			},
+			{
+				Sid:        "DenyRestricted",
+				Effect:     config.EffectDeny,
+				Actions:    []string{"s3:*"},
+				Resources:  []string{"arn:aws:s3:::*", "arn:aws:s3:::*/*"},
+				Conditions: map[string]map[string]config.ConditionValues{"StringEquals": {"s3:ResourceTag/classification": {"restricted"}}},
+			},
+		},
+	}}
+	backend := proxy.NewMemoryBackend("tenant-001-events", "tenant-001-billing", "tenant-001-secrets")
+	backend.SetBucketTags("tenant-001-events", map[string]string{"team": "analytics"})
+	backend.SetBucketTags("tenant-001-billing", map[string]string{"team": "finance"})
+	backend.SetBucketTags("tenant-001-secrets", map[string]string{"team": "analytics", "classification": "restricted"})
+	gw := proxytest.NewGatewayWithBackend(t, []config.Credential{teamCred}, policies, backend,
+		proxy.WithBucketTags(backend, time.Minute))
+
+	tests := []struct {
+		bucket string
+		status int
+	}{
+		{"tenant-001-events", http.StatusOK},
+		{"tenant-001-billing", http.StatusForbidden},
+		{"tenant-001-secrets", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		resp := gw.Do(t, teamCred, http.MethodGet, "/"+tt.bucket+"?list-type=2", nil)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.bucket, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestGateway_ExistingObjectTagConditions(t *testing.T) {
+	policies := []config.Policy{{
+		Name: "tenant-001-full-access",
+		Statements: []config.Statement{
+			{
+				Effect:    config.EffectAllow,
+				Actions:   []string{"s3:PutObject"},
+				Resources: []string{"arn:aws:s3:::tenant-001-*/*"},
+			},
+			{
+				Effect:     config.EffectAllow,
+				Actions:    []string{"s3:GetObject", "s3:DeleteObject"},
+				Resources:  []string{"arn:aws:s3:::tenant-001-*/*"},
+				Conditions: map[string]map[string]config.ConditionValues{"StringEquals": {"s3:ExistingObjectTag/classification": {"public"}}},
+			},
+		},
+	}}
+	backend := proxy.NewMemoryBackend("tenant-001-data")
+	gw := proxytest.NewGatewayWithBackend(t, []config.Credential{writerCred}, policies, backend,
+		proxy.WithObjectTags(backend, time.Minute))
+
+	put := func(key, tagging string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, gw.URL+"/tenant-001-data/"+key, strings.NewReader("data"))
+		req.Header.Set("x-amz-tagging", tagging)
+		proxytest.Sign(t, req, writerCred, []byte("data"))
+		resp, err := gw.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("put %s: status = %d", key, resp.StatusCode)
+		}
+	}
+	get := func(key string) int {
+		t.Helper()
+		resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data/"+key, nil)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	put("public.txt", "classification=public")
+	put("internal.txt", "classification=internal")
+	if status := get("public.txt"); status != http.StatusOK {
+		t.Errorf("public object: status = %d, want 200", status)
+	}
+	if status := get("internal.txt"); status != http.StatusForbidden {
+		t.Errorf("internal object: status = %d, want 403", status)
+	}
+	if status := get("missing.txt"); status != http.StatusForbidden {
+		t.Errorf("missing object: status = %d, want 403", status)
+	}
+
+	// Overwriting through the gateway drops the cached tags
+	put("public.txt", "classification=internal")
+	if status := get("public.txt"); status != http.StatusForbidden {
+		t.Errorf("retagged object: status = %d, want 403", status)
+	}
+}
+
+func TestGateway_ObjectTagging(t *testing.T) {
+	backend := proxy.NewMemoryBackend("tenant-001-data")
+	gw := proxytest.NewGatewayWithBackend(t, []config.Credential{writerCred}, testPolicies, backend,
+		proxy.WithObjectTagging(&config.ObjectTaggingConfig{Enabled: true, Tags: map[string]string{
+			"tenant":             "{tenantId}",
+			"client":             "{clientId}",
+			"gateway-request-id": "{requestId}",
+		}}))
+
+	// Client tags are kept, except those the gateway sets
+	req, _ := http.NewRequest(http.MethodPut, gw.URL+"/tenant-001-data/report.csv", strings.NewReader("data"))
+	req.Header.Set("x-amz-tagging", "team=finance&client=someone-else")
+	proxytest.Sign(t, req, writerCred, []byte("data"))
+	resp, err := gw.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put: status = %d", resp.StatusCode)
+	}
+
+	tags, _ := backend.ObjectTags(context.Background(), "tenant-001-data", "report.csv")
+	want := map[string]string{
+		"team":               "finance",
+		"tenant":             "tenant-001",
+		"client":             "writer",
+		"gateway-request-id": resp.Header.Get("x-amz-request-id"),
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+}
+
+func TestGateway_Compression(t *testing.T) {
+	listing := strings.Repeat("<Contents><Key>reports/q3.csv</Key></Contents>", 100)
+	backend := proxy.ForwarderFunc(func(ctx context.Context, req *proxy.S3Request) (*proxy.S3Response, error) {
+		body, contentType := listing, "application/xml"
+		if req.Key == "image.png" {
+			contentType = "image/png"
+		}
+		return &proxy.S3Response{
+			StatusCode:    http.StatusOK,
+			Headers:       http.Header{"Content-Type": {contentType}, "Content-Length": {fmt.Sprint(len(body))}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		}, nil
+	})
+	gw := proxytest.NewGatewayWithForwarder(t, []config.Credential{writerCred}, testPolicies, backend,
+		proxy.WithCompression(&config.CompressionConfig{Enabled: true, MinSize: 1024, MaxObjectSize: 1 << 20,
+			ContentTypes: []string{"text/*", "application/xml"}}))
+
+	get := func(path, acceptEncoding string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, gw.URL+path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		proxytest.Sign(t, req, writerCred, nil)
+		resp, err := gw.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := get("/tenant-001-data?list-type=2", "gzip")
+	if resp.Header.Get("Content-Encoding") != "gzip" || resp.ContentLength >= int64(len(listing)) {
+		t.Fatalf("listing not compressed: headers = %v", resp.Header)
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != listing {
+		t.Errorf("decompressed listing differs: %d bytes, want %d", len(body), len(listing))
+	}
+
+	for _, tc := range []struct{ name, path, acceptEncoding string }{
+		{"no Accept-Encoding", "/tenant-001-data?list-type=2", "identity"},
+		{"incompressible object", "/tenant-001-data/image.png", "gzip"},
+	} {
+		resp := get(tc.path, tc.acceptEncoding)
+		if body := proxytest.ReadBody(t, resp); resp.Header.Get("Content-Encoding") != "" || body != listing {
+			t.Errorf("%s: compressed, headers = %v", tc.name, resp.Header)
+		}
+	}
+}
+
+func TestGateway_ConditionalWrites(t *testing.T) {
+	gw := newTestGateway(t)
+	put := func(header, value string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, gw.URL+"/tenant-001-data/lock.json", strings.NewReader("v"))
+		req.Header.Set(header, value)
+		proxytest.Sign(t, req, writerCred, []byte("v"))
+		resp, err := gw.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := put("If-None-Match", "*")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create if absent: status = %d", resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+	resp = put("If-None-Match", "*")
+	if body := proxytest.ReadBody(t, resp); resp.StatusCode != http.StatusPreconditionFailed || !strings.Contains(body, "PreconditionFailed") {
+		t.Errorf("create over an existing object: status = %d, body = %s", resp.StatusCode, body)
+	}
+	if resp := put("If-Match", `"stale"`); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: status = %d, want 412", resp.StatusCode)
+	}
+	if resp := put("If-Match", etag); resp.StatusCode != http.StatusOK {
+		t.Errorf("matching If-Match: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestGateway_HeaderConditions(t *testing.T) {
+	policies := []config.Policy{{
+		Name: "tenant-001-full-access",
+		Statements: []config.Statement{
+			{
+				Effect:    config.EffectAllow,
+				Actions:   []string{"s3:*"},
+				Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+			},
+			{
+				Sid:       "DenyOutdatedWriters",
+				Effect:    config.EffectDeny,
+				Actions:   []string{"s3:PutObject"},
+				Resources: []string{"arn:aws:s3:::tenant-001-*/*"},
+				Conditions: map[string]map[string]config.ConditionValues{
+					"StringLike": {"header:x-client-build": {"1.*", "2.0.*"}},
+				},
+			},
+			{
+				Sid:        "DenyScripts",
+				Effect:     config.EffectDeny,
+				Actions:    []string{"s3:*"},
+				Resources:  []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+				Conditions: map[string]map[string]config.ConditionValues{"StringLike": {"aws:UserAgent": {"curl/*"}}},
+			},
+		},
+	}}
+	gw := proxytest.NewGateway(t, []config.Credential{writerCred}, policies, "tenant-001-data")
+
+	tests := []struct {
+		name      string
+		build     string
+		userAgent string
+		status    int
+	}{
+		{"current build", "2.1.0", "app/2.1.0", http.StatusOK},
+		{"outdated build", "2.0.9", "app/2.0.9", http.StatusForbidden},
+		{"no build header", "", "app/2.1.0", http.StatusOK},
+		{"denied user agent", "2.1.0", "curl/8.4.0", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPut, gw.URL+"/tenant-001-data/file.txt", strings.NewReader("data"))
+		if tt.build != "" {
+			req.Header.Set("X-Client-Build", tt.build)
+		}
+		req.Header.Set("User-Agent", tt.userAgent)
+		proxytest.Sign(t, req, writerCred, []byte("data"))
+		resp, err := gw.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestGateway_ElevatedAccess(t *testing.T) {
+	grants, err := grant.NewStore("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred, readerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithElevatedAccess(grants))
+
+	put := func() int {
+		resp := gw.Do(t, readerCred, http.MethodPut, "/tenant-001-data/fix.txt", []byte("fix"))
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := put(); status != http.StatusForbidden {
+		t.Fatalf("without grant: status = %d, want 403", status)
+	}
+
+	g, err := grants.Create(grant.Grant{ClientID: readerCred.ClientID, Policy: "tenant-001-full-access", Justification: "INC-42"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := put(); status != http.StatusOK {
+		t.Errorf("with grant: status = %d, want 200", status)
+	}
+	if entry := gw.Audit.Last(); entry == nil || entry.GrantID != g.ID {
+		t.Errorf("audit entry not attributed to the grant: %+v", entry)
+	}
+
+	grants.Revoke(g.ID)
+	if status := put(); status != http.StatusForbidden {
+		t.Errorf("after revoke: status = %d, want 403", status)
+	}
+}
+
+func TestGateway_KeyNamingRules(t *testing.T) {
+	policies := []config.Policy{{
+		Name: "tenant-001-full-access",
+		Statements: []config.Statement{
+			{
+				Effect:    config.EffectAllow,
+				Actions:   []string{"s3:*"},
+				Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+			},
+			{
+				Sid:       "TenantDatePartition",
+				Effect:    config.EffectDeny,
+				Actions:   []string{"s3:PutObject"},
+				Resources: []string{"arn:aws:s3:::tenant-001-*/*"},
+				Conditions: map[string]map[string]config.ConditionValues{
+					"StringNotMatchesRegex": {"gateway:ObjectKey": {`^${tenant:id}/\d{4}-\d{2}-\d{2}/`}},
+				},
+			},
+		},
+	}}
+	gw := proxytest.NewGateway(t, []config.Credential{writerCred}, policies, "tenant-001-data")
+
+	resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/tenant-001/2024-05-01/a.csv", []byte("a"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("conforming key: status = %d, want 200", resp.StatusCode)
+	}
+
+	resp = gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/a.csv", []byte("a"))
+	body := proxytest.ReadBody(t, resp)
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(body, "naming rules for this bucket (rule: TenantDatePartition)") {
+		t.Errorf("non-conforming key: status = %d, body = %s", resp.StatusCode, body)
+	}
+	if entry := gw.Audit.Last(); entry == nil || entry.DenyReason != "DENY_KEY_NAMING" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+
+	// Reads are not subject to naming rules
+	resp = gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data/a.csv", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("read: status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"slices"
+
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/grant"
+)
+
+// ElevatedAccess reports the break-glass grants in effect for a client
+type ElevatedAccess interface {
+	Active(clientID string) []grant.Grant
+}
+
+// WithElevatedAccess adds the policies and scopes of a client's active
+// grants to its credential's
+func WithElevatedAccess(grants ElevatedAccess) Option {
+	return func(g *Gateway) {
+		g.grants = grants
+	}
+}
+
+// applyGrants extends authCtx with the client's active grants. It returns
+// the grant ID by policy name for the policies that only a grant attaches,
+// so requests they allow can be attributed to the grant.
+func (g *Gateway) applyGrants(authCtx *auth.AuthContext) map[string]string {
+	if g.grants == nil {
+		return nil
+	}
+	active := g.grants.Active(authCtx.ClientID)
+	if len(active) == 0 {
+		return nil
+	}
+
+	granted := make(map[string]string, len(active))
+	policies := append([]string(nil), authCtx.Policies...)
+	scopes := append([]string(nil), authCtx.Scopes...)
+	for _, gr := range active {
+		if !slices.Contains(policies, gr.Policy) {
+			policies = append(policies, gr.Policy)
+			granted[gr.Policy] = gr.ID
+		}
+		// A credential without scopes is not restricted; adding a grant's
+		// scopes must not restrict it
+		if len(authCtx.Scopes) > 0 {
+			scopes = append(scopes, gr.Scopes...)
+		}
+	}
+	authCtx.Policies, authCtx.Scopes = policies, scopes
+	return granted
+}
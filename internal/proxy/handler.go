@@ -1,17 +1,30 @@
 package proxy
 
 import (
+	stderrors "errors"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
 	"github.com/google/uuid"
 	"github.com/s3-access-control-adapter/internal/audit"
 	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/config"
 	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/internal/geoip"
 	"github.com/s3-access-control-adapter/internal/policy"
+	"github.com/s3-access-control-adapter/internal/redact"
+	"github.com/s3-access-control-adapter/internal/slo"
+	"github.com/s3-access-control-adapter/internal/tenant"
+	"github.com/s3-access-control-adapter/internal/uploadlink"
 )
 
 // Gateway is the main HTTP handler for the S3 proxy
@@ -19,8 +32,135 @@ type Gateway struct {
 	credStore    auth.CredentialStore
 	sigValidator auth.SignatureValidator
 	policyEngine policy.Engine
-	s3Client     *S3Client
+	backend      Forwarder
 	auditLogger  audit.Logger
+
+	trustedProxies   []netip.Prefix
+	maintenance      atomic.Bool
+	shedder          *loadShedder
+	auditFailClosed  bool
+	geo              GeoResolver
+	slo              *slo.Tracker
+	chaos            *chaosInjector
+	hooks            []Hook
+	verboseDenies    bool
+	denyReasonHeader bool
+	metricLabels     *metricLabels
+	tenants          TenantStates
+	grants           ElevatedAccess
+	rateLimiter      *rateLimiter
+	buckets          *config.BucketsConfig
+	bucketTags       *tagCache
+	objectTags       *tagCache
+	mirror           *mirror
+	operations       map[string]bool
+	multipart        *multipartLimiter
+	aliases          map[string]*config.AliasConfig
+	dlp              *dlpScanner
+	autoTags         map[string]string
+	compression      *config.CompressionConfig
+	verifyETags      bool
+	ci               *ciCredentials
+	uploadLinks      *uploadlink.Store
+	redactor         *redact.Redactor
+}
+
+// Retry-After values (seconds) sent with 503 SlowDown responses
+const (
+	maintenanceRetryAfter = "5"
+	shedRetryAfter        = "1"
+)
+
+// Option configures optional Gateway behavior
+type Option func(*Gateway)
+
+// WithKeyRedaction redacts sensitive object keys in log lines. Audit
+// entries are redacted by wrapping the audit logger, see
+// audit.NewRedactingLogger.
+func WithKeyRedaction(redactor *redact.Redactor) Option {
+	return func(g *Gateway) {
+		g.redactor = redactor
+	}
+}
+
+// WithTrustedProxies honors forwarding headers only from the given proxies
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(g *Gateway) {
+		g.trustedProxies = prefixes
+	}
+}
+
+// WithLoadShedding caps concurrent in-flight requests globally and per
+// tenant; zero disables a cap. Requests beyond a tenant's cap wait up to
+// tenantQueueTimeout for a slot before they are rejected.
+func WithLoadShedding(maxInFlight, maxInFlightPerTenant int, tenantQueueTimeout time.Duration) Option {
+	return func(g *Gateway) {
+		g.shedder = newLoadShedder(maxInFlight, maxInFlightPerTenant, tenantQueueTimeout)
+	}
+}
+
+// WithAuditFailClosed rejects requests with 503 when their audit entry
+// cannot be recorded, instead of only counting the failure
+func WithAuditFailClosed(enabled bool) Option {
+	return func(g *Gateway) {
+		g.auditFailClosed = enabled
+	}
+}
+
+// DenyReasonHeader carries the deny reason code of denied requests when
+// enabled with WithDenyReasonHeader
+const DenyReasonHeader = "X-Gateway-Deny-Reason"
+
+// WithDenyReasonHeader adds the deny reason code (e.g. DENY_TENANT_BOUNDARY
+// or DENY_POLICY) to denied responses, so client teams can tell a request
+// outside their scopes from one refused by policy. Unlike verbose messages
+// it names no policies.
+func WithDenyReasonHeader(enabled bool) Option {
+	return func(g *Gateway) {
+		g.denyReasonHeader = enabled
+	}
+}
+
+// WithVerboseDenyMessages includes the deny reason and the matched policy and
+// statement in AccessDenied messages. Keep it off where clients are external:
+// it reveals how access is configured.
+func WithVerboseDenyMessages(enabled bool) Option {
+	return func(g *Gateway) {
+		g.verboseDenies = enabled
+	}
+}
+
+// GeoResolver locates client addresses
+type GeoResolver interface {
+	Lookup(ip string) geoip.Info
+}
+
+// WithGeoIP adds country and ASN details to audit entries and provides the
+// aws:SourceCountry condition key
+func WithGeoIP(resolver GeoResolver) Option {
+	return func(g *Gateway) {
+		g.geo = resolver
+	}
+}
+
+// WithSLO records every S3 request's status and time to first byte in tracker
+func WithSLO(tracker *slo.Tracker) Option {
+	return func(g *Gateway) {
+		g.slo = tracker
+	}
+}
+
+// TenantStates reports tenant lifecycle states
+type TenantStates interface {
+	State(tenantID string) tenant.State
+}
+
+// WithTenantStates rejects requests signed by credentials of suspended or
+// deleted tenants
+func WithTenantStates(states TenantStates) Option {
+	return func(g *Gateway) {
+		g.tenants = states
+	}
 }
 
 // NewGateway creates a new Gateway
@@ -28,16 +168,43 @@ func NewGateway(
 	credStore auth.CredentialStore,
 	sigValidator auth.SignatureValidator,
 	policyEngine policy.Engine,
-	s3Client *S3Client,
+	backend Forwarder,
 	auditLogger audit.Logger,
+	opts ...Option,
 ) *Gateway {
-	return &Gateway{
+	g := &Gateway{
 		credStore:    credStore,
 		sigValidator: sigValidator,
 		policyEngine: policyEngine,
-		s3Client:     s3Client,
+		backend:      backend,
 		auditLogger:  auditLogger,
+		shedder:      newLoadShedder(0, 0, 0),
+		rateLimiter:  newRateLimiter(),
+		metricLabels: newMetricLabels(nil),
+	}
+	WithOperations(SupportedOperations)(g)
+	for _, opt := range opts {
+		opt(g)
 	}
+	return g
+}
+
+// SetMaintenance toggles maintenance mode. While enabled, new requests are
+// rejected with 503 SlowDown and health checks fail; in-flight requests finish.
+func (g *Gateway) SetMaintenance(enabled bool) {
+	if g.maintenance.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		log.Println("Maintenance mode enabled")
+	} else {
+		log.Println("Maintenance mode disabled")
+	}
+}
+
+// InMaintenance reports whether maintenance mode is enabled
+func (g *Gateway) InMaintenance() bool {
+	return g.maintenance.Load()
 }
 
 // ServeHTTP handles incoming HTTP requests
@@ -45,35 +212,136 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	requestID := uuid.New().String()
 
+	// Count transferred bytes for the audit entry
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &countingReader{ReadCloser: r.Body}
+	}
+	cw := &countingResponseWriter{ResponseWriter: w}
+	w = cw
+
 	// Add request ID to response headers
 	w.Header().Set("x-amz-request-id", requestID)
 
+	// Carry the client's correlation ID through to audit and upstream
+	corr := parseCorrelation(r.Header)
+	if corr.requestID != "" {
+		w.Header().Set(headerRequestID, corr.requestID)
+	}
+	rc := &RequestContext{RequestID: requestID, StartTime: startTime, redactor: g.redactor}
+	r = r.WithContext(withRequestContext(withCorrelation(r.Context(), corr), rc))
+	rc.Request = r
+
 	// Health check endpoint
 	if r.URL.Path == "/health" {
+		if g.InMaintenance() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("MAINTENANCE"))
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 		return
 	}
+	if r.URL.Path == "/readyz" {
+		g.serveReadiness(w)
+		return
+	}
+
+	if g.slo != nil {
+		defer func() {
+			if cw.status == 0 {
+				g.slo.Record(http.StatusOK, time.Since(startTime))
+				return
+			}
+			g.slo.Record(cw.status, cw.headersAt.Sub(startTime))
+		}()
+	}
+
+	if g.InMaintenance() {
+		w.Header().Set("Retry-After", maintenanceRetryAfter)
+		errors.WriteS3ErrorCode(w, errors.ErrSlowDown.WithMessage("The gateway is in maintenance mode. Please retry."), requestID)
+		return
+	}
+	if g.ci != nil && r.URL.Path == g.ci.cfg.Path {
+		g.serveCICredentials(w, r, rc)
+		return
+	}
+	if g.uploadLinks != nil && strings.HasPrefix(r.URL.Path, UploadLinkPath) {
+		g.serveUploadLink(w, r, rc)
+		return
+	}
 
 	// Parse S3 request
 	s3req, err := ParseS3Request(r)
+	rc.S3 = s3req
 	if err != nil {
-		g.handleError(w, requestID, "", "", s3req, errors.DenyInvalidResource, err, startTime, r)
+		g.handleError(w, rc, errors.DenyInvalidResource, err)
 		return
 	}
 
 	// Check if bucket is empty (listing buckets is not supported)
 	if s3req.Bucket == "" {
-		g.handleError(w, requestID, "", "", s3req, errors.DenyInvalidResource,
-			nil, startTime, r)
+		g.handleError(w, rc, errors.DenyInvalidResource, nil)
+		return
+	}
+	if s3req.Action == "" || !g.operations[s3req.Operation] {
+		g.handleError(w, rc, errors.DenyNotImplemented, nil)
 		return
 	}
+	alias := g.resolveAlias(s3req)
+
+	// Shed load beyond the concurrency caps instead of queuing
+	if !g.shedder.acquire() {
+		w.Header().Set("Retry-After", shedRetryAfter)
+		g.handleError(w, rc, errors.DenyThrottled, nil)
+		return
+	}
+	defer g.shedder.release()
 
 	// Authenticate request
 	authCtx, err := g.authenticate(r)
 	if err != nil {
 		log.Printf("[%s] Authentication failed: %v", requestID, err)
-		g.handleError(w, requestID, "", "", s3req, errors.DenyAuthFailed, err, startTime, r)
+		g.handleError(w, rc, authDenyReason(err), err)
+		return
+	}
+	rc.Auth = authCtx
+	if authCtx.Session != nil && !sessionPermits(authCtx.Session, s3req) {
+		log.Printf("[%s] Request outside its session: client=%s bucket=%s session=%s",
+			requestID, authCtx.ClientID, s3req.Bucket, authCtx.Session.Bucket)
+		g.handleError(w, rc, errors.DenySessionScope, nil)
+		return
+	}
+
+	if reason := g.tenantDenyReason(authCtx.TenantID); reason != "" {
+		log.Printf("[%s] Tenant not active: client=%s tenant=%s reason=%s",
+			requestID, authCtx.ClientID, authCtx.TenantID, reason)
+		g.handleError(w, rc, reason, nil)
+		return
+	}
+	grantedPolicies := g.applyGrants(authCtx)
+
+	// Validation may replace the body to decode aws-chunked payloads and
+	// verify payload hashes as the backend reads it
+	s3req.Body, s3req.ContentLength = r.Body, r.ContentLength
+	if v := r.Header.Get("Content-Encoding"); v != "" {
+		s3req.Headers.Set("Content-Encoding", v)
+	} else {
+		s3req.Headers.Del("Content-Encoding")
+	}
+
+	if !g.shedder.acquireTenant(r.Context(), authCtx.TenantID) {
+		w.Header().Set("Retry-After", shedRetryAfter)
+		g.handleError(w, rc, errors.DenyThrottled, nil)
+		return
+	}
+	defer g.shedder.releaseTenant(authCtx.TenantID)
+
+	// Hooks may rewrite the request before it is authorized
+	hc := &HookContext{RequestContext: rc, Conditions: map[string]string{}}
+	if err := g.runBeforePolicy(hc); err != nil {
+		log.Printf("[%s] Request rejected: %v", requestID, err)
+		g.handleHookError(w, rc, err)
 		return
 	}
 
@@ -81,64 +349,215 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !g.checkTenantBoundary(authCtx, s3req) {
 		log.Printf("[%s] Tenant boundary violation: client=%s tenant=%s bucket=%s",
 			requestID, authCtx.ClientID, authCtx.TenantID, s3req.Bucket)
-		g.handleError(w, requestID, authCtx.ClientID, authCtx.TenantID, s3req,
-			errors.DenyTenantBoundary, nil, startTime, r)
+		g.handleError(w, rc, errors.DenyTenantBoundary, nil)
+		return
+	}
+	if authCtx.KeyPrefix != "" && !confineToPrefix(s3req, authCtx.KeyPrefix) {
+		log.Printf("[%s] Key prefix violation: client=%s prefix=%s key=%s",
+			requestID, authCtx.ClientID, authCtx.KeyPrefix, rc.logKey())
+		g.handleError(w, rc, errors.DenyTenantBoundary, nil)
+		return
+	}
+	if !withinAlias(alias, s3req) {
+		log.Printf("[%s] Request outside alias: client=%s alias=%s operation=%s key=%s",
+			requestID, authCtx.ClientID, alias.Name, s3req.Operation, rc.logKey())
+		g.handleError(w, rc, errors.DenyAliasScope, nil)
 		return
 	}
 
 	// Evaluate policy
 	evalCtx := &policy.EvalContext{
-		ClientID: authCtx.ClientID,
-		TenantID: authCtx.TenantID,
-		Action:   s3req.Action,
-		Resource: s3req.ToARN(),
-		Bucket:   s3req.Bucket,
-		Key:      s3req.Key,
-		Conditions: map[string]string{
-			"aws:SourceIp": getClientIP(r),
-		},
-	}
-
-	decision := g.policyEngine.Evaluate(evalCtx, authCtx.Policies)
+		ClientID:   authCtx.ClientID,
+		TenantID:   authCtx.TenantID,
+		Action:     s3req.Action,
+		Resource:   s3req.ToARN(),
+		Bucket:     s3req.Bucket,
+		Key:        s3req.Key,
+		Conditions: hc.Conditions,
+	}
+	// Never taken from hooks
+	evalCtx.Conditions["aws:SourceIp"] = g.clientIP(r)
+	evalCtx.Conditions["aws:UserAgent"] = r.UserAgent()
+	g.addHeaderConditions(evalCtx, r, authCtx.Policies)
+	g.addRouteConditions(evalCtx, s3req)
+	addKeyConditions(evalCtx, s3req)
+	delete(evalCtx.Conditions, policy.AliasKey)
+	if alias != nil {
+		evalCtx.Conditions[policy.AliasKey] = alias.Name
+	}
+	// Left unset for unknown locations, so no condition on it matches; geo-fence
+	// with Allow statements rather than Deny ones to fail closed
+	if g.geo != nil {
+		if country := g.geo.Lookup(evalCtx.Conditions["aws:SourceIp"]).Country; country != "" {
+			evalCtx.Conditions["aws:SourceCountry"] = country
+		}
+	}
+
+	addRequestTags(evalCtx, s3req)
+	if err := g.addTagConditions(r.Context(), evalCtx, authCtx.Policies); err != nil {
+		// Fail closed: without the tags, Deny statements on them cannot apply
+		log.Printf("[%s] Failed to fetch tags of %s: %v", requestID, rc.logResource(), err)
+		g.handleError(w, rc, errors.DenyInternalError, err)
+		return
+	}
+
+	decision := policy.EvaluateCredential(g.policyEngine, evalCtx, authCtx.AllowedActions, authCtx.Policies)
+	if decision.Allowed {
+		decision = g.evaluateAlias(alias, evalCtx, decision)
+	}
 	if !decision.Allowed {
 		log.Printf("[%s] Policy denied: client=%s action=%s resource=%s reason=%s",
-			requestID, authCtx.ClientID, s3req.Action, s3req.ToARN(), decision.DenyReason)
-		g.handleError(w, requestID, authCtx.ClientID, authCtx.TenantID, s3req,
-			decision.DenyReason, nil, startTime, r)
+			requestID, authCtx.ClientID, s3req.Action, rc.logResource(), decision.DenyReason)
+		g.handleDenial(w, rc, decision)
+		return
+	}
+	if ok, wait := g.rateLimiter.allow(r.Context(), authCtx.ClientID, decision.RateLimits); !ok {
+		log.Printf("[%s] Rate limited: client=%s action=%s resource=%s",
+			requestID, authCtx.ClientID, s3req.Action, rc.logResource())
+		w.Header().Set("Retry-After", retryAfter(wait))
+		g.handleError(w, rc, errors.DenyThrottled, nil)
+		return
+	}
+
+	if s3req.Action == "s3:CreateBucket" && !g.bucketNameAllowed(s3req.Bucket, authCtx.TenantID) {
+		log.Printf("[%s] Bucket name rejected: client=%s tenant=%s bucket=%s",
+			requestID, authCtx.ClientID, authCtx.TenantID, s3req.Bucket)
+		g.handleError(w, rc, errors.DenyBucketNaming, nil)
+		return
+	}
+	g.clampListing(s3req, authCtx.TenantID)
+	if err := g.multipart.check(s3req, authCtx.TenantID); err != nil {
+		g.handleUploadLimit(w, rc, err)
+		return
+	}
+	if err := g.dlp.check(r.Context(), rc, s3req); err != nil {
+		g.handleDLP(w, rc, err)
+		return
+	}
+	g.addAutoTags(rc, s3req)
+	if s3req.Operation == "CreateSession" {
+		g.createSession(w, rc, authCtx)
 		return
 	}
 
-	// Forward to S3
-	resp, err := g.s3Client.Forward(r.Context(), s3req)
+	// Chaos mode fails authorized requests before they reach the backend
+	if g.chaos != nil {
+		if fault := g.chaos.inject(w, r, s3req, requestID); fault != "" {
+			status := http.StatusInternalServerError
+			if fault == faultReset {
+				status = 0
+			}
+			g.logAudit(audit.NewAllowEntry(requestID, authCtx.ClientID, authCtx.TenantID, s3req.Action,
+				s3req.Bucket, s3req.Key, g.clientIP(r), r.UserAgent(), time.Since(startTime), status), w, r)
+			if fault == faultReset {
+				resetConnection(w)
+			}
+			return
+		}
+	}
+
+	if err := g.runBeforeForward(hc); err != nil {
+		log.Printf("[%s] Request rejected: %v", requestID, err)
+		g.handleHookError(w, rc, err)
+		return
+	}
+
+	// Forward to the storage backend
+	mirrored := g.mirror.capture(s3req)
+	resp, err := g.backend.Forward(r.Context(), s3req)
 	if err != nil {
 		log.Printf("[%s] S3 forward error: %v", requestID, err)
-		g.handleS3Error(w, requestID, authCtx.ClientID, authCtx.TenantID, s3req, err, startTime, r)
+		g.handleS3Error(w, rc, err)
 		return
 	}
+	g.mirror.send(mirrored, rc.logKey())
+	g.objectWritten(s3req)
+	g.multipart.record(s3req)
+	if err := g.runBeforeResponse(hc, resp); err != nil {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		log.Printf("[%s] Response rejected: %v", requestID, err)
+		g.handleHookError(w, rc, err)
+		return
+	}
+	g.verifyETag(rc, s3req, resp)
+
+	entry := func() *audit.Entry {
+		e := audit.NewAllowEntry(
+			requestID,
+			authCtx.ClientID,
+			authCtx.TenantID,
+			s3req.Action,
+			s3req.Bucket,
+			s3req.Key,
+			g.clientIP(r),
+			r.UserAgent(),
+			time.Since(startTime),
+			resp.StatusCode,
+		)
+		e.GrantID = grantedPolicies[decision.MatchedPolicy]
+		return e
+	}
 
-	// Log successful request
-	g.auditLogger.Log(audit.NewAllowEntry(
-		requestID,
-		authCtx.ClientID,
-		authCtx.TenantID,
-		s3req.Action,
-		s3req.Bucket,
-		s3req.Key,
-		getClientIP(r),
-		r.UserAgent(),
-		time.Since(startTime),
-		resp.StatusCode,
-	))
-
-	// Write response
-	g.writeResponse(w, resp)
+	// In fail-closed mode the entry must be recorded before the response is
+	// released. The upstream operation has already run, as with any 5xx.
+	if g.auditFailClosed {
+		e := entry()
+		e.BytesOut = max(resp.ContentLength, 0)
+		if err := g.logAudit(e, nil, r); err != nil {
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			errors.WriteS3ErrorCode(w, errors.ErrServiceUnavailable.WithMessage("The request could not be audited. Please retry."), requestID)
+			return
+		}
+		g.writeResponse(w, r, s3req, resp)
+		return
+	}
+
+	// Write response, then log the successful request
+	g.writeResponse(w, r, s3req, resp)
+	g.logAudit(entry(), w, r)
+}
+
+// addHeaderConditions sets the request headers the policies reference as
+// header:<name> condition keys. Absent headers are left unset, so no
+// condition on them matches; keys set by hooks are replaced. Repeated
+// headers are joined with commas.
+func (g *Gateway) addHeaderConditions(evalCtx *policy.EvalContext, r *http.Request, policyNames []string) {
+	for key := range evalCtx.Conditions {
+		if strings.HasPrefix(key, policy.HeaderPrefix) {
+			delete(evalCtx.Conditions, key)
+		}
+	}
+	for _, key := range policy.ConditionKeys(g.policyEngine, policyNames, policy.HeaderPrefix) {
+		if values := r.Header.Values(strings.TrimPrefix(key, policy.HeaderPrefix)); len(values) > 0 {
+			evalCtx.Conditions[key] = strings.Join(values, ",")
+		}
+	}
+}
+
+// addKeyConditions describes the object key of uploads for naming rules.
+// Other requests leave the keys unset, so Deny statements on them do not
+// apply; values set by hooks are replaced.
+func addKeyConditions(evalCtx *policy.EvalContext, s3req *S3Request) {
+	for _, key := range policy.KeyNamingKeys {
+		delete(evalCtx.Conditions, key)
+	}
+	if s3req.Action != "s3:PutObject" || s3req.Key == "" {
+		return
+	}
+	evalCtx.Conditions[policy.ObjectKeyKey] = s3req.Key
+	evalCtx.Conditions[policy.ObjectKeyLengthKey] = strconv.Itoa(len(s3req.Key))
+	evalCtx.Conditions[policy.ObjectKeyDepthKey] = strconv.Itoa(strings.Count(s3req.Key, "/") + 1)
 }
 
 // authenticate validates the request signature and returns the auth context
 func (g *Gateway) authenticate(r *http.Request) (*auth.AuthContext, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		return nil, errors.NewAccessDeniedError(errors.DenyAuthFailed,
+		return nil, errors.NewAccessDeniedError(errors.DenyMissingAuthHeader,
 			"missing Authorization header", "", "")
 	}
 
@@ -148,14 +567,32 @@ func (g *Gateway) authenticate(r *http.Request) (*auth.AuthContext, error) {
 		return nil, err
 	}
 
-	// Look up the credential
-	cred, err := g.credStore.GetCredential(components.AccessKey)
+	// Credentials issued to CI jobs carry their identity in the token
+	if token := r.Header.Get(auth.SecurityTokenHeader); token != "" && g.ci != nil {
+		return g.authenticateCI(r, token)
+	}
+
+	// Look up the credential. S3 Express requests are signed with the keys
+	// of their session, whose token names the credential.
+	accessKey := components.AccessKey
+	token := r.Header.Get(auth.SessionTokenHeader)
+	if token != "" {
+		accessKey = auth.SessionCredentialKey(token)
+	}
+	cred, err := g.credStore.GetCredential(accessKey)
 	if err != nil {
-		return nil, err
+		return nil, errors.NewAccessDeniedError(errors.DenyInvalidAccessKey, err.Error(), "", "")
+	}
+	signer := cred
+	var session *auth.Session
+	if token != "" {
+		if session, signer, err = auth.VerifySession(cred, token, time.Now()); err != nil {
+			return nil, err
+		}
 	}
 
 	// Validate the signature
-	_, err = g.sigValidator.ParseAndValidate(r, cred)
+	_, err = g.sigValidator.ParseAndValidate(r, signer)
 	if err != nil {
 		return nil, err
 	}
@@ -166,9 +603,37 @@ func (g *Gateway) authenticate(r *http.Request) (*auth.AuthContext, error) {
 		AccessKey: cred.AccessKey,
 		Policies:  cred.Policies,
 		Scopes:    cred.Scopes,
+		Session:   session,
+
+		AllowedActions: cred.AllowedActions,
 	}, nil
 }
 
+// authDenyReason returns the deny reason for an authentication failure.
+// Failures without a more specific reason are signature mismatches.
+func authDenyReason(err error) errors.DenyReason {
+	var denied *errors.AccessDeniedError
+	if stderrors.As(err, &denied) {
+		return denied.Reason
+	}
+	return errors.DenyAuthFailed
+}
+
+// tenantDenyReason returns the deny reason for requests of a suspended or
+// deleted tenant, or "" when the tenant is active
+func (g *Gateway) tenantDenyReason(tenantID string) errors.DenyReason {
+	if g.tenants == nil {
+		return ""
+	}
+	switch g.tenants.State(tenantID) {
+	case tenant.Suspended:
+		return errors.DenyTenantSuspended
+	case tenant.Deleted:
+		return errors.DenyTenantDeleted
+	}
+	return ""
+}
+
 // checkTenantBoundary verifies that the request is within the client's allowed scope
 func (g *Gateway) checkTenantBoundary(authCtx *auth.AuthContext, s3req *S3Request) bool {
 	if len(authCtx.Scopes) == 0 {
@@ -179,88 +644,128 @@ func (g *Gateway) checkTenantBoundary(authCtx *auth.AuthContext, s3req *S3Reques
 }
 
 // handleError writes an error response and logs the denial
-func (g *Gateway) handleError(
-	w http.ResponseWriter,
-	requestID, clientID, tenantID string,
-	s3req *S3Request,
-	reason errors.DenyReason,
-	err error,
-	startTime time.Time,
-	r *http.Request,
-) {
+func (g *Gateway) handleError(w http.ResponseWriter, rc *RequestContext, reason errors.DenyReason, err error) {
+	g.handleDenial(w, rc, policy.NewDenyDecision(reason, "", ""))
+}
+
+// handleDenial writes the error response for a deny decision and logs it
+func (g *Gateway) handleDenial(w http.ResponseWriter, rc *RequestContext, decision *policy.Decision) {
+	reason := decision.DenyReason
 	bucket := ""
 	key := ""
 	action := ""
-	if s3req != nil {
-		bucket = s3req.Bucket
-		key = s3req.Key
-		action = s3req.Action
+	if rc.S3 != nil {
+		bucket = rc.S3.Bucket
+		key = rc.S3.Key
+		action = rc.S3.Action
+	}
+
+	// Write error response
+	accessErr := errors.NewAccessDeniedError(reason, "", bucket+"/"+key, rc.RequestID)
+	if g.verboseDenies {
+		accessErr.Verbose = true
+		accessErr.Policy, accessErr.Statement = decision.MatchedPolicy, decision.MatchedStatement
+	} else if reason == errors.DenyKeyNaming {
+		accessErr.Statement = decision.MatchedStatement
+	}
+	if reason == errors.DenyNotImplemented && rc.S3 != nil {
+		accessErr.Operation = rc.S3.Operation
 	}
+	if g.denyReasonHeader {
+		w.Header().Set(DenyReasonHeader, string(reason))
+	}
+	errors.WriteS3Error(w, accessErr)
 
 	// Log the denial
-	g.auditLogger.Log(audit.NewDenyEntry(
-		requestID,
-		clientID,
-		tenantID,
+	g.logAudit(audit.NewDenyEntry(
+		rc.RequestID,
+		rc.ClientID(),
+		rc.TenantID(),
 		action,
 		bucket,
 		key,
-		getClientIP(r),
-		r.UserAgent(),
+		g.clientIP(rc.Request),
+		rc.Request.UserAgent(),
 		string(reason),
-		time.Since(startTime),
-	))
-
-	// Write error response
-	accessErr := errors.NewAccessDeniedError(reason, "", bucket+"/"+key, requestID)
-	errors.WriteS3Error(w, accessErr)
+		time.Since(rc.StartTime),
+	), w, rc.Request)
 }
 
 // handleS3Error handles errors from the upstream S3
-func (g *Gateway) handleS3Error(
-	w http.ResponseWriter,
-	requestID, clientID, tenantID string,
-	s3req *S3Request,
-	err error,
-	startTime time.Time,
-	r *http.Request,
-) {
+func (g *Gateway) handleS3Error(w http.ResponseWriter, rc *RequestContext, err error) {
 	// Log the error
 	entry := audit.NewDenyEntry(
-		requestID,
-		clientID,
-		tenantID,
-		s3req.Action,
-		s3req.Bucket,
-		s3req.Key,
-		getClientIP(r),
-		r.UserAgent(),
+		rc.RequestID,
+		rc.ClientID(),
+		rc.TenantID(),
+		rc.S3.Action,
+		rc.S3.Bucket,
+		rc.S3.Key,
+		g.clientIP(rc.Request),
+		rc.Request.UserAgent(),
 		"S3_ERROR",
-		time.Since(startTime),
+		time.Since(rc.StartTime),
 	)
 	entry.ErrorMsg = err.Error()
-	g.auditLogger.Log(entry)
+	defer g.logAudit(entry, w, rc.Request) // after the error response is written
 
-	// Check if it's a not found error
-	errStr := err.Error()
-	if strings.Contains(errStr, "NoSuchKey") || strings.Contains(errStr, "NotFound") {
-		errors.WriteS3ErrorFromCode(w, http.StatusNotFound, "NoSuchKey",
-			"The specified key does not exist.", requestID)
+	// Backends other than S3 report S3-style errors directly
+	var backendErr *BackendError
+	if stderrors.As(err, &backendErr) {
+		for key, values := range backendErr.Headers {
+			w.Header()[key] = values
+		}
+		errors.WriteS3ErrorFromCode(w, backendErr.StatusCode, backendErr.Code, backendErr.Message, rc.RequestID)
 		return
 	}
-	if strings.Contains(errStr, "NoSuchBucket") {
-		errors.WriteS3ErrorFromCode(w, http.StatusNotFound, "NoSuchBucket",
-			"The specified bucket does not exist.", requestID)
-		return
+
+	errors.WriteS3ErrorCode(w, upstreamErrorCode(err), rc.RequestID)
+}
+
+// upstreamErrorCode maps an S3 SDK error onto the error catalog, keeping the
+// upstream message. Responses without an error body, such as a HEAD
+// request's 404, are mapped by status; anything else is an internal error.
+func upstreamErrorCode(err error) errors.ErrorCode {
+	// Reported by the request body, e.g. a payload hash mismatch
+	var code errors.ErrorCode
+	if stderrors.As(err, &code) {
+		return code
 	}
 
-	// Generic internal error
-	errors.WriteS3ErrorFromCode(w, http.StatusInternalServerError, "InternalError",
-		"We encountered an internal error. Please try again.", requestID)
+	var apiErr smithy.APIError
+	if stderrors.As(err, &apiErr) {
+		if c, ok := errors.LookupCode(apiErr.ErrorCode()); ok {
+			if msg := apiErr.ErrorMessage(); msg != "" {
+				c = c.WithMessage(msg)
+			}
+			return c
+		}
+	}
+
+	var respErr *awshttp.ResponseError
+	if stderrors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusNotFound:
+			return errors.ErrNoSuchKey
+		case http.StatusPreconditionFailed:
+			return errors.ErrPreconditionFailed
+		case http.StatusRequestedRangeNotSatisfiable:
+			return errors.ErrInvalidRange
+		}
+	}
+
+	errStr := err.Error()
+	switch {
+	case strings.Contains(errStr, "NoSuchBucket"):
+		return errors.ErrNoSuchBucket
+	case strings.Contains(errStr, "NoSuchKey"), strings.Contains(errStr, "NotFound"):
+		return errors.ErrNoSuchKey
+	}
+	return errors.ErrInternalError
 }
 
 // writeResponse writes the S3 response to the HTTP response writer
-func (g *Gateway) writeResponse(w http.ResponseWriter, resp *S3Response) {
+func (g *Gateway) writeResponse(w http.ResponseWriter, r *http.Request, s3req *S3Request, resp *S3Response) {
 	// Copy headers
 	for key, values := range resp.Headers {
 		for _, value := range values {
@@ -268,34 +773,84 @@ func (g *Gateway) writeResponse(w http.ResponseWriter, resp *S3Response) {
 		}
 	}
 
+	// Length and MD5 headers describe the uncompressed body
+	encoding := g.responseEncoding(r, s3req, resp)
+	if encoding != "" {
+		w.Header().Del("Content-Length")
+		w.Header().Del("Content-MD5")
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+
 	// Write status code
 	w.WriteHeader(resp.StatusCode)
 
 	// Copy body if present
 	if resp.Body != nil {
 		defer resp.Body.Close()
-		io.Copy(w, resp.Body)
+		if encoding == "" {
+			io.Copy(w, resp.Body)
+			return
+		}
+		enc := newEncoder(w, encoding)
+		io.Copy(enc, resp.Body)
+		enc.Close()
 	}
 }
 
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For first (for proxied requests)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
+// clientIP returns the client IP. Forwarding headers are honored only when
+// the connection comes from a trusted proxy; X-Forwarded-For is walked from
+// the right, skipping trusted hops, so clients cannot spoof their address.
+// A hop that is not an IP address ends the walk at the trusted peer, since
+// only the client could have written it.
+func (g *Gateway) clientIP(r *http.Request) string {
+	remote := remoteIP(r.RemoteAddr)
+	if !g.isTrustedProxy(remote) {
+		return remote
 	}
 
-	// Check X-Real-IP
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			if _, err := netip.ParseAddr(hop); err != nil {
+				return remote
+			}
+			if i == 0 || !g.isTrustedProxy(hop) {
+				return hop
+			}
+		}
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		if _, err := netip.ParseAddr(xri); err == nil {
+			return xri
+		}
 	}
+	return remote
+}
+
+func (g *Gateway) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range g.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
 
-	// Fall back to RemoteAddr
-	addr := r.RemoteAddr
-	// Remove port if present
-	if colonIdx := strings.LastIndex(addr, ":"); colonIdx != -1 {
-		addr = addr[:colonIdx]
+// remoteIP strips the port from a RemoteAddr
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
 	}
 	return addr
 }
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestGateway_ClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	tests := []struct {
+		name    string
+		trusted []netip.Prefix
+		remote  string
+		xff     string
+		realIP  string
+		want    string
+	}{
+		{"no trusted proxies ignores headers", nil, "203.0.113.9:1234", "1.2.3.4", "5.6.7.8", "203.0.113.9"},
+		{"untrusted peer ignores headers", trusted, "203.0.113.9:1234", "1.2.3.4", "", "203.0.113.9"},
+		{"trusted peer uses forwarded client", trusted, "10.0.0.5:1234", "198.51.100.7", "", "198.51.100.7"},
+		{"spoofed leftmost entry is skipped", trusted, "10.0.0.5:1234", "1.2.3.4, 198.51.100.7, 10.0.0.6", "", "198.51.100.7"},
+		{"all hops trusted uses leftmost", trusted, "10.0.0.5:1234", "10.1.1.1, 10.0.0.6", "", "10.1.1.1"},
+		{"trusted peer uses X-Real-IP", trusted, "10.0.0.5:1234", "", "198.51.100.7", "198.51.100.7"},
+		{"trusted peer without headers", trusted, "10.0.0.5:1234", "", "", "10.0.0.5"},
+		{"ipv6 peer", trusted, "[fd00::1]:1234", "2001:db8::5", "", "2001:db8::5"},
+		{"ipv6 peer without trust", nil, "[2001:db8::9]:1234", "", "", "2001:db8::9"},
+		{"invalid hop falls back to peer", trusted, "10.0.0.5:1234", "198.51.100.7, <script>", "", "10.0.0.5"},
+		{"invalid hop stops the walk", trusted, "10.0.0.5:1234", "198.51.100.7, unknown, 10.0.0.6", "", "10.0.0.5"},
+		{"invalid X-Real-IP is ignored", trusted, "10.0.0.5:1234", "", "not-an-ip", "10.0.0.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGateway(nil, nil, nil, nil, nil, WithTrustedProxies(tt.trusted))
+			r := httptest.NewRequest("GET", "/bucket/key", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := g.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpstreamErrorCode(t *testing.T) {
+	statusErr := func(status int) error {
+		return &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+			Err:      fmt.Errorf("api error"),
+		}}
+	}
+	tests := []struct {
+		name    string
+		err     error
+		code    string
+		status  int
+		message string
+	}{
+		{"catalog code keeps message", &smithy.GenericAPIError{Code: "AccessDenied", Message: "Bucket owner denied"},
+			"AccessDenied", http.StatusForbidden, "Bucket owner denied"},
+		{"catalog code default message", &smithy.GenericAPIError{Code: "SlowDown"},
+			"SlowDown", http.StatusServiceUnavailable, "Please reduce your request rate."},
+		{"unknown code", &smithy.GenericAPIError{Code: "WeirdError", Message: "x"},
+			"InternalError", http.StatusInternalServerError, ""},
+		{"HEAD not found", statusErr(http.StatusNotFound), "NoSuchKey", http.StatusNotFound, ""},
+		{"precondition", statusErr(http.StatusPreconditionFailed), "PreconditionFailed", http.StatusPreconditionFailed, ""},
+		{"plain error", fmt.Errorf("operation error S3: NoSuchBucket"), "NoSuchBucket", http.StatusNotFound, ""},
+		{"network error", fmt.Errorf("connection refused"), "InternalError", http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		c := upstreamErrorCode(tt.err)
+		if c.Code != tt.code || c.StatusCode != tt.status {
+			t.Errorf("%s: got %s %d, want %s %d", tt.name, c.Code, c.StatusCode, tt.code, tt.status)
+		}
+		if tt.message != "" && c.Message != tt.message {
+			t.Errorf("%s: message = %q, want %q", tt.name, c.Message, tt.message)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// alwaysStrippedHeaders are upstream response headers never forwarded:
+// hop-by-hop headers, and headers the gateway sets itself
+var alwaysStrippedHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Date",
+	"Server",
+	"X-Amz-Request-Id",
+	"X-Amz-Id-2",
+}
+
+// headerFilter decides which upstream response headers reach the client
+type headerFilter map[string]bool
+
+// newHeaderFilter strips the always-stripped headers plus extra
+func newHeaderFilter(extra []string) headerFilter {
+	f := make(headerFilter)
+	for _, name := range alwaysStrippedHeaders {
+		f[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range extra {
+		f[http.CanonicalHeaderKey(name)] = true
+	}
+	return f
+}
+
+// responseHeaders returns every header of the raw upstream response behind
+// an SDK output, minus stripped ones, so metadata such as storage class,
+// SSE, version id, replication and restore status passes through unchanged
+func (f headerFilter) responseHeaders(metadata middleware.Metadata) http.Header {
+	headers := make(http.Header)
+	raw, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response)
+	if !ok || raw == nil {
+		return headers
+	}
+	for key, values := range raw.Header {
+		if f[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		headers[key] = append([]string(nil), values...)
+	}
+	return headers
+}
+
+// rawStatusCode returns the upstream HTTP status behind an SDK output, or fallback
+func rawStatusCode(metadata middleware.Metadata, fallback int) int {
+	if raw, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok && raw != nil {
+		return raw.StatusCode
+	}
+	return fallback
+}
+
+// conditionalWriteHeaders are the precondition headers of conditional writes
+var conditionalWriteHeaders = []string{"If-Match", "If-None-Match"}
+
+// withConditionalWrite is an S3 operation option forwarding the request's
+// If-Match and If-None-Match headers, which the SDK's write inputs have no
+// fields for. They are added before signing.
+func withConditionalWrite(req *S3Request) func(*s3.Options) {
+	headers := make(http.Header)
+	for _, name := range conditionalWriteHeaders {
+		if v := req.Headers.Get(name); v != "" {
+			headers.Set(name, v)
+		}
+	}
+	return func(o *s3.Options) {
+		if len(headers) == 0 {
+			return
+		}
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Build.Add(middleware.BuildMiddlewareFunc("GatewayConditionalWrite",
+				func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+					if r, ok := in.Request.(*smithyhttp.Request); ok {
+						for name, values := range headers {
+							r.Header[name] = values
+						}
+					}
+					return next.HandleBuild(ctx, in)
+				}), middleware.After)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestS3Client_PassesUpstreamHeadersThrough(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("X-Amz-Storage-Class", "GLACIER")
+		w.Header().Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		w.Header().Set("X-Amz-Version-Id", "v1")
+		w.Header().Set("X-Amz-Restore", `ongoing-request="false"`)
+		w.Header().Set("X-Amz-Replication-Status", "COMPLETED")
+		w.Header().Set("X-Amz-Meta-Owner", "team-a")
+		w.Header().Set("X-Amz-Request-Id", "upstream-id")
+		io.WriteString(w, "hello")
+	}))
+	defer upstream.Close()
+
+	client := newTestS3Client(t, upstream.URL, RedirectFollow)
+	client.headers = newHeaderFilter([]string{"x-amz-replication-status"})
+
+	for _, op := range []string{"GetObject", "HeadObject"} {
+		t.Run(op, func(t *testing.T) {
+			req := &S3Request{Bucket: "data", Key: "a.txt", Action: "s3:GetObject", Operation: op, HTTPMethod: http.MethodGet, Headers: make(http.Header)}
+			resp, err := client.Forward(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Forward: %v", err)
+			}
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+
+			want := map[string]string{
+				"Content-Type":                 "text/plain",
+				"ETag":                         `"abc"`,
+				"X-Amz-Storage-Class":          "GLACIER",
+				"X-Amz-Server-Side-Encryption": "aws:kms",
+				"X-Amz-Version-Id":             "v1",
+				"X-Amz-Restore":                `ongoing-request="false"`,
+				"X-Amz-Meta-Owner":             "team-a",
+				"X-Amz-Replication-Status":     "",
+				"X-Amz-Request-Id":             "",
+			}
+			for name, value := range want {
+				if got := resp.Headers.Get(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestS3Client_ForwardsConditionalWriteHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.Header.Get("If-None-Match") != "*" || !strings.Contains(r.Header.Get("Authorization"), "if-none-match") {
+			t.Errorf("If-None-Match = %q, Authorization = %q", r.Header.Get("If-None-Match"), r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusPreconditionFailed)
+		io.WriteString(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+	}))
+	defer upstream.Close()
+
+	client := newTestS3Client(t, upstream.URL, RedirectFollow)
+	req := &S3Request{Bucket: "data", Key: "a.txt", Action: "s3:PutObject", Operation: "PutObject", HTTPMethod: http.MethodPut,
+		Headers: http.Header{"If-None-Match": {"*"}}, Body: seekableBody{strings.NewReader("x")}, ContentLength: 1}
+	_, err := client.Forward(context.Background(), req)
+	if code := upstreamErrorCode(err); code.Code != "PreconditionFailed" || code.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("error = %v, mapped to %+v", err, code)
+	}
+}
+
+// seekableBody lets the SDK hash the payload of plain HTTP requests
+type seekableBody struct {
+	*strings.Reader
+}
+
+func (seekableBody) Close() error { return nil }
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var (
+	hedgeEligible = metrics.NewCounter("gateway_upstream_hedge_eligible_total",
+		"Upstream requests eligible for hedging", "action")
+	hedgeIssued = metrics.NewCounter("gateway_upstream_hedge_issued_total",
+		"Hedged upstream requests issued after the hedge delay", "action")
+	hedgeWon = metrics.NewCounter("gateway_upstream_hedge_won_total",
+		"Hedged upstream requests that responded before the original", "action")
+)
+
+// upstreamCall performs a single upstream attempt
+type upstreamCall func(ctx context.Context) (*S3Response, error)
+
+type attemptResult struct {
+	index int
+	resp  *S3Response
+	err   error
+}
+
+// hedged runs call and, if it has not completed after delay, issues a second
+// identical attempt. The first successful response wins and the other attempt
+// is canceled. Only idempotent reads may be hedged.
+func hedged(ctx context.Context, action string, delay time.Duration, call upstreamCall) (*S3Response, error) {
+	hedgeEligible.Inc(action)
+
+	results := make(chan attemptResult, 2)
+	var cancels []context.CancelFunc
+	launch := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := call(attemptCtx)
+			results <- attemptResult{index: index, resp: resp, err: err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	timerC := timer.C
+
+	pending := 1
+	for {
+		select {
+		case <-timerC:
+			timerC = nil
+			hedgeIssued.Inc(action)
+			launch()
+			pending++
+
+		case res := <-results:
+			pending--
+			if res.err != nil {
+				cancels[res.index]()
+				if pending == 0 {
+					// Either both attempts failed, or the original failed
+					// before the hedge fired; the SDK already retries errors.
+					return nil, res.err
+				}
+				continue
+			}
+
+			if res.index > 0 {
+				hedgeWon.Inc(action)
+			}
+			for i, cancel := range cancels {
+				if i != res.index {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go discardResult(results)
+			}
+
+			// The winner's context must stay alive while its body streams
+			if res.resp.Body == nil {
+				cancels[res.index]()
+			} else {
+				res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: cancels[res.index]}
+			}
+			return res.resp, nil
+		}
+	}
+}
+
+// discardResult waits for the losing attempt and releases its body
+func discardResult(results <-chan attemptResult) {
+	res := <-results
+	if res.resp != nil && res.resp.Body != nil {
+		res.resp.Body.Close()
+	}
+}
+
+// cancelOnClose releases an attempt's context once the body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedged_FastOriginalSkipsHedge(t *testing.T) {
+	var calls int32
+	resp, err := hedged(context.Background(), "test:fast", 50*time.Millisecond, func(ctx context.Context) (*S3Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return &S3Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("original"))}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 upstream call, got %d", n)
+	}
+	if hedgeIssued.Value("test:fast") != 0 {
+		t.Error("expected no hedge to be issued")
+	}
+}
+
+func TestHedged_SlowOriginalLosesToHedge(t *testing.T) {
+	var calls int32
+	originalCanceled := make(chan struct{})
+	issued, won := hedgeIssued.Value("test:slow"), hedgeWon.Value("test:slow")
+
+	resp, err := hedged(context.Background(), "test:slow", 10*time.Millisecond, func(ctx context.Context) (*S3Response, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			close(originalCanceled)
+			return nil, ctx.Err()
+		}
+		return &S3Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("hedge"))}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hedge" {
+		t.Errorf("body = %q, want hedge", body)
+	}
+
+	select {
+	case <-originalCanceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the losing attempt to be canceled")
+	}
+
+	if n := hedgeIssued.Value("test:slow") - issued; n != 1 {
+		t.Errorf("hedges issued = %v, want 1", n)
+	}
+	if n := hedgeWon.Value("test:slow") - won; n != 1 {
+		t.Errorf("hedges won = %v, want 1", n)
+	}
+}
+
+func TestHedged_WinnerContextLivesUntilClose(t *testing.T) {
+	var winnerCtx context.Context
+	resp, err := hedged(context.Background(), "test:ctx", time.Second, func(ctx context.Context) (*S3Response, error) {
+		winnerCtx = ctx
+		return &S3Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("data"))}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if winnerCtx.Err() != nil {
+		t.Fatal("winner context canceled before body was closed")
+	}
+	resp.Body.Close()
+	if winnerCtx.Err() == nil {
+		t.Error("expected winner context to be canceled after Close")
+	}
+}
+
+func TestHedged_BothFail(t *testing.T) {
+	wantErr := errors.New("upstream unavailable")
+	_, err := hedged(context.Background(), "test:fail", time.Millisecond, func(ctx context.Context) (*S3Response, error) {
+		time.Sleep(5 * time.Millisecond)
+		return nil, wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("err = %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// HookContext describes the request a hook runs for. Hooks may change S3
+// (bucket, key, headers) and, before policy evaluation, add Conditions.
+type HookContext struct {
+	*RequestContext
+	Conditions map[string]string // merged into the policy evaluation context
+}
+
+// Hook customizes request handling at fixed points, so embedding programs
+// and config built-ins can change requests without patching the handler.
+// Every stage is optional and runs for authenticated requests only:
+//
+//   - BeforePolicy runs before the tenant boundary check and policy
+//     evaluation, so rewritten buckets and keys are what gets authorized
+//   - BeforeForward runs once the request is allowed
+//   - BeforeResponse runs on the backend response before it is written
+//
+// An error ends the request: a *BackendError is returned to the client as
+// is, any other error as AccessDenied.
+type Hook struct {
+	Name           string
+	BeforePolicy   func(hc *HookContext) error
+	BeforeForward  func(hc *HookContext) error
+	BeforeResponse func(hc *HookContext, resp *S3Response) error
+}
+
+// WithHooks appends hooks to the pipeline; they run in order at each stage
+func WithHooks(hooks ...Hook) Option {
+	return func(g *Gateway) {
+		g.hooks = append(g.hooks, hooks...)
+	}
+}
+
+// hookError wraps a hook failure with the hook's name
+type hookError struct {
+	hook string
+	err  error
+}
+
+func (e *hookError) Error() string { return fmt.Sprintf("hook %s: %v", e.hook, e.err) }
+func (e *hookError) Unwrap() error { return e.err }
+
+func (g *Gateway) runBeforePolicy(hc *HookContext) error {
+	for _, h := range g.hooks {
+		if h.BeforePolicy != nil {
+			if err := h.BeforePolicy(hc); err != nil {
+				return &hookError{hook: h.Name, err: err}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Gateway) runBeforeForward(hc *HookContext) error {
+	for _, h := range g.hooks {
+		if h.BeforeForward != nil {
+			if err := h.BeforeForward(hc); err != nil {
+				return &hookError{hook: h.Name, err: err}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Gateway) runBeforeResponse(hc *HookContext, resp *S3Response) error {
+	for _, h := range g.hooks {
+		if h.BeforeResponse != nil {
+			if err := h.BeforeResponse(hc, resp); err != nil {
+				return &hookError{hook: h.Name, err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// handleHookError writes the response for a request ended by a hook and
+// records it as denied
+func (g *Gateway) handleHookError(w http.ResponseWriter, rc *RequestContext, err error) {
+	entry := audit.NewDenyEntry(rc.RequestID, rc.ClientID(), rc.TenantID(), rc.S3.Action,
+		rc.S3.Bucket, rc.S3.Key, g.clientIP(rc.Request), rc.Request.UserAgent(),
+		string(errors.DenyHookRejected), time.Since(rc.StartTime))
+	entry.ErrorMsg = err.Error()
+	defer g.logAudit(entry, w, rc.Request)
+
+	var backendErr *BackendError
+	if stderrors.As(err, &backendErr) {
+		for key, values := range backendErr.Headers {
+			w.Header()[key] = values
+		}
+		errors.WriteS3ErrorFromCode(w, backendErr.StatusCode, backendErr.Code, backendErr.Message, rc.RequestID)
+		return
+	}
+	if g.denyReasonHeader {
+		w.Header().Set(DenyReasonHeader, string(errors.DenyHookRejected))
+	}
+	errors.WriteS3Error(w, errors.NewAccessDeniedError(errors.DenyHookRejected, "",
+		rc.S3.Bucket+"/"+rc.S3.Key, rc.RequestID))
+}
+
+// Built-in hook types selectable in config
+const (
+	HookSetHeaders = "setHeaders"
+	HookRewriteKey = "rewriteKey"
+)
+
+// NewConfigHooks builds the built-in hooks listed in config
+func NewConfigHooks(cfgs []config.HookConfig) ([]Hook, error) {
+	hooks := make([]Hook, 0, len(cfgs))
+	for i := range cfgs {
+		cfg := cfgs[i]
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("%s[%d]", cfg.Type, i)
+		}
+		hook := Hook{Name: name}
+
+		switch cfg.Type {
+		case HookSetHeaders:
+			if cfg.Stage == "response" {
+				hook.BeforeResponse = func(hc *HookContext, resp *S3Response) error {
+					if hookApplies(&cfg, hc) {
+						if resp.Headers == nil {
+							resp.Headers = make(http.Header)
+						}
+						setHeaders(resp.Headers, &cfg, hc)
+					}
+					return nil
+				}
+			} else {
+				hook.BeforeForward = func(hc *HookContext) error {
+					if hookApplies(&cfg, hc) {
+						setHeaders(hc.S3.Headers, &cfg, hc)
+					}
+					return nil
+				}
+			}
+		case HookRewriteKey:
+			hook.BeforePolicy = func(hc *HookContext) error {
+				if hookApplies(&cfg, hc) && strings.HasPrefix(hc.S3.Key, cfg.From) {
+					hc.S3.Key = cfg.To + strings.TrimPrefix(hc.S3.Key, cfg.From)
+				}
+				return nil
+			}
+		default:
+			return nil, fmt.Errorf("hooks[%d]: unknown type %q", i, cfg.Type)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// hookApplies reports whether a built-in hook's filters match the request
+func hookApplies(cfg *config.HookConfig, hc *HookContext) bool {
+	if len(cfg.Actions) > 0 && !policy.MatchAction(hc.S3.Action, cfg.Actions) {
+		return false
+	}
+	if len(cfg.Buckets) > 0 && !policy.MatchScope(hc.S3.Bucket, cfg.Buckets) {
+		return false
+	}
+	if len(cfg.Tenants) > 0 && !slices.Contains(cfg.Tenants, hc.Auth.TenantID) {
+		return false
+	}
+	if len(cfg.Prefixes) > 0 && !slices.ContainsFunc(cfg.Prefixes, func(p string) bool {
+		return strings.HasPrefix(hc.S3.Key, p)
+	}) {
+		return false
+	}
+	return true
+}
+
+// setHeaders sets the hook's headers, expanding {clientId}, {tenantId} and
+// {requestId} in values. Defaults leave headers already present alone.
+func setHeaders(h http.Header, cfg *config.HookConfig, hc *HookContext) {
+	expand := strings.NewReplacer(
+		"{clientId}", hc.Auth.ClientID,
+		"{tenantId}", hc.Auth.TenantID,
+		"{requestId}", hc.RequestID,
+	)
+	for name, value := range cfg.Headers {
+		if cfg.Default && h.Get(name) != "" {
+			continue
+		}
+		h.Set(name, expand.Replace(value))
+	}
+}
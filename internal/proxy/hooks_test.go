@@ -0,0 +1,203 @@
+package proxy_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+)
+
+func TestGateway_Hooks(t *testing.T) {
+	var stages []string
+	hook := proxy.Hook{
+		Name: "test",
+		BeforePolicy: func(hc *proxy.HookContext) error {
+			stages = append(stages, "policy:"+hc.S3.Key)
+			if hc.S3.Key == "blocked" {
+				return errors.New("blocked key")
+			}
+			if hc.S3.Key == "gone" {
+				return &proxy.BackendError{StatusCode: http.StatusGone, Code: "Gone", Message: "gone"}
+			}
+			hc.S3.Key = "rewritten/" + hc.S3.Key
+			return nil
+		},
+		BeforeForward: func(hc *proxy.HookContext) error {
+			stages = append(stages, "forward:"+hc.S3.Key)
+			return nil
+		},
+		BeforeResponse: func(hc *proxy.HookContext, resp *proxy.S3Response) error {
+			stages = append(stages, "response")
+			resp.Headers.Set("X-Hooked", hc.Auth.ClientID)
+			return nil
+		},
+	}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithHooks(hook))
+
+	resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/a.txt", []byte("x"))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put status = %d: %s", resp.StatusCode, proxytest.ReadBody(t, resp))
+	}
+	if got := resp.Header.Get("X-Hooked"); got != "writer" {
+		t.Errorf("X-Hooked = %q, want writer", got)
+	}
+	if _, ok := gw.Backend.GetObject("tenant-001-data", "rewritten/a.txt"); !ok {
+		t.Error("object not stored under the rewritten key")
+	}
+	want := []string{"policy:a.txt", "forward:rewritten/a.txt", "response"}
+	if len(stages) != len(want) {
+		t.Fatalf("stages = %v, want %v", stages, want)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Errorf("stages = %v, want %v", stages, want)
+			break
+		}
+	}
+
+	resp = gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data/blocked", nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("blocked status = %d, want 403", resp.StatusCode)
+	}
+	if entry := gw.Audit.Last(); entry.DenyReason != "DENY_HOOK_REJECTED" || entry.ErrorMsg != "hook test: blocked key" {
+		t.Errorf("unexpected audit entry: reason=%q error=%q", entry.DenyReason, entry.ErrorMsg)
+	}
+
+	resp = gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data/gone", nil)
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("gone status = %d, want 410", resp.StatusCode)
+	}
+}
+
+func TestGateway_HookRewriteIsAuthorized(t *testing.T) {
+	hooks, err := proxy.NewConfigHooks([]config.HookConfig{
+		{Type: proxy.HookRewriteKey, Buckets: []string{"tenant-001-*"}, From: "v1/", To: "v2/"},
+		{Type: proxy.HookSetHeaders, Stage: "response", Actions: []string{"s3:GetObject"},
+			Headers: map[string]string{"X-Tenant": "{tenantId}"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A bucket rewrite out of the tenant is rejected by the boundary check
+	escape := proxy.Hook{Name: "escape", BeforePolicy: func(hc *proxy.HookContext) error {
+		if hc.S3.Key == "other" {
+			hc.S3.Bucket = "tenant-002-data"
+		}
+		return nil
+	}}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data", "tenant-002-data"}, proxy.WithHooks(hooks...), proxy.WithHooks(escape))
+	gw.Backend.PutObject("tenant-001-data", "v2/doc", []byte("x"))
+
+	resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data/v1/doc", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Tenant") != "tenant-001" {
+		t.Errorf("get status = %d, X-Tenant = %q", resp.StatusCode, resp.Header.Get("X-Tenant"))
+	}
+
+	resp = gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data/other", nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("rewritten bucket status = %d, want 403", resp.StatusCode)
+	}
+	if entry := gw.Audit.Last(); entry.DenyReason != "DENY_TENANT_BOUNDARY" {
+		t.Errorf("deny reason = %q, want DENY_TENANT_BOUNDARY", entry.DenyReason)
+	}
+}
+
+func TestNewConfigHooks_UnknownType(t *testing.T) {
+	if _, err := proxy.NewConfigHooks([]config.HookConfig{{Type: "bogus"}}); err == nil {
+		t.Error("expected error for unknown hook type")
+	}
+}
+
+func TestGateway_UploadDefaults(t *testing.T) {
+	hooks, err := proxy.NewConfigHooks([]config.HookConfig{{
+		Type:     proxy.HookSetHeaders,
+		Actions:  []string{"s3:PutObject"},
+		Tenants:  []string{"tenant-001"},
+		Prefixes: []string{"logs/"},
+		Default:  true,
+		Headers: map[string]string{
+			"x-amz-storage-class": "STANDARD_IA",
+			"Cache-Control":       "max-age=3600",
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithHooks(hooks...))
+
+	put := func(key, storageClass string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, gw.URL+"/tenant-001-data/"+key, strings.NewReader("x"))
+		if storageClass != "" {
+			req.Header.Set("x-amz-storage-class", storageClass)
+		}
+		proxytest.Sign(t, req, writerCred, []byte("x"))
+		resp, err := gw.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("put %s: status %d", key, resp.StatusCode)
+		}
+	}
+	put("logs/a", "")
+	put("logs/b", "GLACIER_IR")
+	put("data/c", "")
+
+	tests := []struct {
+		key, storageClass, cacheControl string
+	}{
+		{"logs/a", "STANDARD_IA", "max-age=3600"},
+		{"logs/b", "GLACIER_IR", "max-age=3600"},
+		{"data/c", "", ""},
+	}
+	for _, tt := range tests {
+		resp := gw.Do(t, writerCred, http.MethodGet, "/tenant-001-data/"+tt.key, nil)
+		if got := resp.Header.Get("x-amz-storage-class"); got != tt.storageClass {
+			t.Errorf("%s: storage class = %q, want %q", tt.key, got, tt.storageClass)
+		}
+		if got := resp.Header.Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.key, got, tt.cacheControl)
+		}
+	}
+}
+
+func TestGateway_RequestContext(t *testing.T) {
+	var seen *proxy.RequestContext
+	hook := proxy.Hook{
+		Name: "context",
+		BeforeForward: func(hc *proxy.HookContext) error {
+			seen = proxy.RequestContextFrom(hc.Request.Context())
+			if seen != hc.RequestContext {
+				t.Error("request context differs from the hook's")
+			}
+			return nil
+		},
+	}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithHooks(hook))
+
+	resp := gw.Do(t, writerCred, http.MethodPut, "/tenant-001-data/a.txt", []byte("x"))
+	proxytest.ReadBody(t, resp)
+	if seen == nil {
+		t.Fatal("hook did not run")
+	}
+	if seen.RequestID != resp.Header.Get("x-amz-request-id") || seen.StartTime.IsZero() {
+		t.Errorf("request context = %+v", seen)
+	}
+	if seen.ClientID() != "writer" || seen.TenantID() != "tenant-001" || seen.S3.Key != "a.txt" {
+		t.Errorf("client=%s tenant=%s key=%s", seen.ClientID(), seen.TenantID(), seen.S3.Key)
+	}
+	if rc := proxy.RequestContextFrom(context.Background()); rc != nil {
+		t.Errorf("outside a request: %+v", rc)
+	}
+}
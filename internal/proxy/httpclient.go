@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// NewHTTPClient builds the HTTP client used for upstream calls
+func NewHTTPClient(cfg *config.HTTPClientConfig) (*http.Client, error) {
+	configure, err := transportOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	configure(transport)
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newSDKHTTPClient builds the HTTP client for the AWS SDK. It extends the
+// SDK's buildable client, which LoadDefaultConfig requires to apply
+// AWS_CA_BUNDLE on top of our settings.
+func newSDKHTTPClient(cfg *config.HTTPClientConfig) (*awshttp.BuildableClient, error) {
+	configure, err := transportOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return awshttp.NewBuildableClient().WithTransportOptions(configure), nil
+}
+
+// transportOptions validates cfg and returns a function applying it to a transport
+func transportOptions(cfg *config.HTTPClientConfig) (func(*http.Transport), error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxyUrl: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.CABundle != "" {
+		pool, err := loadCABundle(cfg.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return func(tr *http.Transport) {
+		tr.Proxy = proxy
+		tr.DialContext = (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		tr.MaxIdleConns = cfg.MaxIdleConns
+		tr.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		tr.MaxConnsPerHost = cfg.MaxConnsPerHost
+		tr.IdleConnTimeout = cfg.IdleConnTimeout
+		tr.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+		tr.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+		if tlsConfig != nil {
+			tr.TLSClientConfig = tlsConfig.Clone()
+		}
+	}, nil
+}
+
+// loadCABundle returns the system roots extended with the certificates in path
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestNewHTTPClient_Tuning(t *testing.T) {
+	cfg := &config.HTTPClientConfig{
+		MaxIdleConns:          512,
+		MaxIdleConnsPerHost:   64,
+		MaxConnsPerHost:       200,
+		DialTimeout:           5 * time.Second,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+		IdleConnTimeout:       time.Minute,
+	}
+
+	client, err := NewHTTPClient(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got %T", client.Transport)
+	}
+	if transport.MaxIdleConns != 512 {
+		t.Errorf("MaxIdleConns = %d, want 512", transport.MaxIdleConns)
+	}
+	if transport.MaxIdleConnsPerHost != 64 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 64", transport.MaxIdleConnsPerHost)
+	}
+	if transport.MaxConnsPerHost != 200 {
+		t.Errorf("MaxConnsPerHost = %d, want 200", transport.MaxConnsPerHost)
+	}
+	if transport.ResponseHeaderTimeout != 15*time.Second {
+		t.Errorf("ResponseHeaderTimeout = %v, want 15s", transport.ResponseHeaderTimeout)
+	}
+	if transport.TLSClientConfig != nil {
+		t.Error("expected default TLS config without a CA bundle")
+	}
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	client, err := NewHTTPClient(&config.HTTPClientConfig{ProxyURL: "http://proxy.internal:3128"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://s3.amazonaws.com/bucket", nil)
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proxyURL == nil || proxyURL.Host != "proxy.internal:3128" {
+		t.Errorf("proxy = %v, want proxy.internal:3128", proxyURL)
+	}
+
+	if _, err := NewHTTPClient(&config.HTTPClientConfig{ProxyURL: "://bad"}); err == nil {
+		t.Error("expected error for invalid proxy URL")
+	}
+}
+
+func TestNewHTTPClient_CABundle(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	missing := filepath.Join(tmpDir, "missing.pem")
+	if _, err := NewHTTPClient(&config.HTTPClientConfig{CABundle: missing}); err == nil {
+		t.Error("expected error for missing CA bundle")
+	}
+
+	empty := filepath.Join(tmpDir, "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHTTPClient(&config.HTTPClientConfig{CABundle: empty}); err == nil {
+		t.Error("expected error for CA bundle without certificates")
+	}
+}
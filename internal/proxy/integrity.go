@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var (
+	etagVerified = metrics.NewCounter("gateway_etag_verified_total",
+		"GetObject bodies hashed and compared to their ETag")
+	etagMismatches = metrics.NewCounter("gateway_etag_mismatches_total",
+		"GetObject bodies whose MD5 did not match their ETag")
+)
+
+// WithETagVerification hashes GetObject bodies while they stream to the
+// client and reports bodies that do not match their ETag
+func WithETagVerification() Option {
+	return func(g *Gateway) {
+		g.verifyETags = true
+	}
+}
+
+// verifyETag wraps a GetObject body in a reader checking it against its
+// ETag. Only whole, unencrypted objects uploaded in one part have the MD5 of
+// their body as ETag; other responses are left as they are.
+func (g *Gateway) verifyETag(rc *RequestContext, s3req *S3Request, resp *S3Response) {
+	if !g.verifyETags || resp.Body == nil || s3req.Operation != "GetObject" || resp.StatusCode != 200 {
+		return
+	}
+	if resp.Headers.Get("Content-Range") != "" {
+		return
+	}
+	sse := resp.Headers.Get("X-Amz-Server-Side-Encryption")
+	if strings.HasPrefix(sse, "aws:kms") || resp.Headers.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" {
+		return
+	}
+	etag := strings.ToLower(strings.Trim(resp.Headers.Get("ETag"), `"`))
+	if !isMD5Hex(etag) {
+		return
+	}
+	resp.Body = &etagVerifier{
+		ReadCloser: resp.Body,
+		hash:       md5.New(),
+		etag:       etag,
+		requestID:  rc.RequestID,
+		bucket:     s3req.Bucket,
+		key:        rc.logKey(),
+	}
+}
+
+// etagVerifier hashes a body as it is read and compares the hash to the
+// ETag at EOF. Bodies not read to the end are not checked.
+type etagVerifier struct {
+	io.ReadCloser
+	hash      hash.Hash
+	etag      string
+	requestID string
+	bucket    string
+	key       string
+	done      bool
+}
+
+func (v *etagVerifier) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && !v.done {
+		v.done = true
+		etagVerified.Inc()
+		if sum := hex.EncodeToString(v.hash.Sum(nil)); sum != v.etag {
+			etagMismatches.Inc()
+			log.Printf("ETag mismatch for s3://%s/%s (request %s): body MD5 %s, ETag %s",
+				v.bucket, v.key, v.requestID, sum, v.etag)
+		}
+	}
+	return n, err
+}
+
+func isMD5Hex(s string) bool {
+	if len(s) != 2*md5.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVerifyETag(t *testing.T) {
+	g := &Gateway{verifyETags: true}
+	rc := &RequestContext{RequestID: "req-1"}
+	s3req := &S3Request{Operation: "GetObject", Bucket: "b", Key: "k"}
+	const md5Hello = "5d41402abc4b2a76b9719d911017c592" // MD5 of "hello"
+
+	tests := []struct {
+		name     string
+		body     string
+		headers  map[string]string
+		mismatch bool
+		checked  bool
+	}{
+		{"match", "hello", map[string]string{"ETag": `"` + md5Hello + `"`}, false, true},
+		{"corrupted", "hellO", map[string]string{"ETag": `"` + md5Hello + `"`}, true, true},
+		{"multipart", "hellO", map[string]string{"ETag": `"` + md5Hello + `-2"`}, false, false},
+		{"range", "hellO", map[string]string{"ETag": `"` + md5Hello + `"`, "Content-Range": "bytes 0-4/10"}, false, false},
+		{"kms", "hellO", map[string]string{"ETag": `"` + md5Hello + `"`, "X-Amz-Server-Side-Encryption": "aws:kms"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &S3Response{StatusCode: http.StatusOK, Headers: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body))}
+			for k, v := range tt.headers {
+				resp.Headers.Set(k, v)
+			}
+			verified, mismatches := etagVerified.Value(), etagMismatches.Value()
+			g.verifyETag(rc, s3req, resp)
+			got, err := io.ReadAll(resp.Body)
+			if err != nil || string(got) != tt.body {
+				t.Fatalf("body = %q, %v; want %q", got, err, tt.body)
+			}
+			if checked := etagVerified.Value() > verified; checked != tt.checked {
+				t.Errorf("checked = %v, want %v", checked, tt.checked)
+			}
+			if mismatch := etagMismatches.Value() > mismatches; mismatch != tt.mismatch {
+				t.Errorf("mismatch = %v, want %v", mismatch, tt.mismatch)
+			}
+		})
+	}
+}
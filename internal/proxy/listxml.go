@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// listBucketResult is the ListObjectsV2 response document
+type listBucketResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name         string   `xml:"Name"`
+	Prefix       string   `xml:"Prefix"`
+	Delimiter    string   `xml:"Delimiter,omitempty"`
+	MaxKeys      int32    `xml:"MaxKeys"`
+	EncodingType string   `xml:"EncodingType,omitempty"`
+	IsTruncated  bool     `xml:"IsTruncated"`
+	// ContinuationToken and NextContinuationToken are opaque and never URL-encoded
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int32          `xml:"KeyCount"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	Contents              []listEntry    `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type listEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified,omitempty"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+	Owner        *owner `xml:"Owner,omitempty"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listResponse renders a ListObjectsV2 output as an S3 response, applying
+// the request's encoding-type
+func listResponse(req *S3Request, output *s3.ListObjectsV2Output) (*S3Response, error) {
+	encodingType := req.QueryParams.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return nil, errInvalidArgument("Invalid Encoding Method specified in Request")
+	}
+
+	body, err := encodeListResult(req.Bucket, output, encodingType)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+
+	return &S3Response{
+		StatusCode:    http.StatusOK,
+		Headers:       headers,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+// encodeListResult marshals a ListObjectsV2 output. With encodingType "url",
+// keys, prefixes and the delimiter are URL-encoded as S3 does.
+func encodeListResult(bucket string, output *s3.ListObjectsV2Output, encodingType string) ([]byte, error) {
+	encode := func(s string) string { return s }
+	if encodingType == "url" {
+		encode = s3URLEncode
+	}
+
+	result := listBucketResult{
+		Name:         bucket,
+		Prefix:       encode(deref(output.Prefix)),
+		Delimiter:    encode(deref(output.Delimiter)),
+		EncodingType: encodingType,
+		IsTruncated:  output.IsTruncated != nil && *output.IsTruncated,
+
+		ContinuationToken:     deref(output.ContinuationToken),
+		NextContinuationToken: deref(output.NextContinuationToken),
+		StartAfter:            encode(deref(output.StartAfter)),
+	}
+	if output.MaxKeys != nil {
+		result.MaxKeys = *output.MaxKeys
+	}
+	if output.KeyCount != nil {
+		result.KeyCount = *output.KeyCount
+	} else {
+		result.KeyCount = int32(len(output.Contents) + len(output.CommonPrefixes))
+	}
+
+	for _, obj := range output.Contents {
+		entry := listEntry{
+			Key:          encode(deref(obj.Key)),
+			ETag:         deref(obj.ETag),
+			StorageClass: string(obj.StorageClass),
+		}
+		if obj.LastModified != nil {
+			entry.LastModified = obj.LastModified.UTC().Format("2006-01-02T15:04:05.000Z")
+		}
+		if obj.Size != nil {
+			entry.Size = *obj.Size
+		}
+		if entry.StorageClass == "" {
+			entry.StorageClass = "STANDARD"
+		}
+		if obj.Owner != nil {
+			entry.Owner = &owner{ID: deref(obj.Owner.ID), DisplayName: deref(obj.Owner.DisplayName)}
+		}
+		result.Contents = append(result.Contents, entry)
+	}
+	for _, p := range output.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(deref(p.Prefix))})
+	}
+
+	body, err := xml.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode list response: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// parseMaxKeys parses the max-keys parameter, capping it at the S3 page size
+func parseMaxKeys(v string) (int32, error) {
+	if v == "" {
+		return 1000, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || n < 0 {
+		return 0, errInvalidArgument("Provided max-keys not an integer or within integer range")
+	}
+	if n > 1000 {
+		n = 1000
+	}
+	return int32(n), nil
+}
+
+// s3URLEncode encodes a key the way S3 does for encoding-type=url
+func s3URLEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "%2F", "/")
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func decodeList(t *testing.T, resp *S3Response) listBucketResult {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	var result listBucketResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		t.Fatalf("invalid XML %q: %v", body, err)
+	}
+	return result
+}
+
+func TestListResponse_EscapesXML(t *testing.T) {
+	output := &s3.ListObjectsV2Output{
+		Prefix:         aws.String("a&b/"),
+		Contents:       []types.Object{{Key: aws.String("a&b/<x>.txt"), Size: aws.Int64(1)}},
+		CommonPrefixes: []types.CommonPrefix{{Prefix: aws.String("a&b/\"q\"/")}},
+	}
+	req := &S3Request{Bucket: "data", QueryParams: url.Values{}}
+
+	resp, err := listResponse(req, output)
+	if err != nil {
+		t.Fatalf("listResponse: %v", err)
+	}
+	result := decodeList(t, resp)
+
+	if result.Prefix != "a&b/" {
+		t.Errorf("Prefix = %q", result.Prefix)
+	}
+	if len(result.Contents) != 1 || result.Contents[0].Key != "a&b/<x>.txt" {
+		t.Errorf("Contents = %+v", result.Contents)
+	}
+	if len(result.CommonPrefixes) != 1 || result.CommonPrefixes[0].Prefix != "a&b/\"q\"/" {
+		t.Errorf("CommonPrefixes = %+v", result.CommonPrefixes)
+	}
+}
+
+func TestListResponse_URLEncoding(t *testing.T) {
+	output := &s3.ListObjectsV2Output{
+		Prefix:    aws.String("dir one/"),
+		Delimiter: aws.String("/"),
+		Contents:  []types.Object{{Key: aws.String("dir one/a+b&c.txt")}},
+	}
+	req := &S3Request{Bucket: "data", QueryParams: url.Values{"encoding-type": {"url"}}}
+
+	resp, err := listResponse(req, output)
+	if err != nil {
+		t.Fatalf("listResponse: %v", err)
+	}
+	result := decodeList(t, resp)
+
+	if result.EncodingType != "url" {
+		t.Errorf("EncodingType = %q, want url", result.EncodingType)
+	}
+	if result.Prefix != "dir+one/" {
+		t.Errorf("Prefix = %q, want dir+one/", result.Prefix)
+	}
+	if got := result.Contents[0].Key; got != "dir+one/a%2Bb%26c.txt" {
+		t.Errorf("Key = %q", got)
+	}
+	if decoded, _ := url.QueryUnescape(result.Contents[0].Key); decoded != "dir one/a+b&c.txt" {
+		t.Errorf("decoded key = %q", decoded)
+	}
+
+	req.QueryParams.Set("encoding-type", "base64")
+	if _, err := listResponse(req, output); err == nil {
+		t.Error("expected error for unsupported encoding-type")
+	}
+}
+
+func TestListResponse_PaginationFields(t *testing.T) {
+	objects := []objectInfo{{Key: "a"}, {Key: "b"}, {Key: "c"}, {Key: "d"}}
+	query := url.Values{"start-after": {"a"}, "max-keys": {"2"}}
+	req := &S3Request{Bucket: "data", QueryParams: query}
+
+	output, err := listObjectsV2("data", objects, query)
+	if err != nil {
+		t.Fatalf("listObjectsV2: %v", err)
+	}
+	resp, err := listResponse(req, output)
+	if err != nil {
+		t.Fatalf("listResponse: %v", err)
+	}
+	result := decodeList(t, resp)
+
+	if result.KeyCount != 2 || !result.IsTruncated {
+		t.Errorf("KeyCount = %d, IsTruncated = %v, want 2, true", result.KeyCount, result.IsTruncated)
+	}
+	if result.StartAfter != "a" {
+		t.Errorf("StartAfter = %q, want a", result.StartAfter)
+	}
+	if result.Contents[0].Key != "b" {
+		t.Errorf("first key = %q, want b", result.Contents[0].Key)
+	}
+	if result.NextContinuationToken == "" {
+		t.Fatal("expected NextContinuationToken")
+	}
+
+	query.Set("continuation-token", result.NextContinuationToken)
+	output, err = listObjectsV2("data", objects, query)
+	if err != nil {
+		t.Fatalf("listObjectsV2: %v", err)
+	}
+	resp, _ = listResponse(req, output)
+	result = decodeList(t, resp)
+	if result.ContinuationToken != query.Get("continuation-token") {
+		t.Errorf("ContinuationToken = %q", result.ContinuationToken)
+	}
+	if result.KeyCount != 1 || result.IsTruncated || result.Contents[0].Key != "d" {
+		t.Errorf("second page = %+v", result)
+	}
+}
+
+func TestParseMaxKeys(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int32
+		wantErr bool
+	}{
+		{"", 1000, false},
+		{"0", 0, false},
+		{"50", 50, false},
+		{"5000", 1000, false},
+		{"-1", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMaxKeys(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseMaxKeys(%q) = %d, %v; want %d, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
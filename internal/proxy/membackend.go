@@ -0,0 +1,312 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+// MemoryBackend is an in-process fake S3 holding objects in memory. It is
+// meant for tests and CI runs that exercise policies without Docker.
+type MemoryBackend struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]*memObject
+	tags    map[string]map[string]string
+}
+
+type memObject struct {
+	info objectInfo
+	data []byte
+	tags map[string]string
+}
+
+// NewMemoryBackend creates a backend with the given (empty) buckets
+func NewMemoryBackend(buckets ...string) *MemoryBackend {
+	b := &MemoryBackend{buckets: make(map[string]map[string]*memObject)}
+	for _, bucket := range buckets {
+		b.CreateBucket(bucket)
+	}
+	return b
+}
+
+// CreateBucket adds an empty bucket if it does not exist
+func (b *MemoryBackend) CreateBucket(bucket string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.buckets[bucket]; !ok {
+		b.buckets[bucket] = make(map[string]*memObject)
+	}
+}
+
+// PutObject stores an object directly, bypassing the gateway
+func (b *MemoryBackend) PutObject(bucket, key string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	objects, ok := b.buckets[bucket]
+	if !ok {
+		return errNoSuchBucket()
+	}
+	objects[key] = newMemObject(key, data, make(http.Header))
+	return nil
+}
+
+// SetBucketTags replaces a bucket's tags
+func (b *MemoryBackend) SetBucketTags(bucket string, tags map[string]string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tags == nil {
+		b.tags = make(map[string]map[string]string)
+	}
+	b.tags[bucket] = tags
+}
+
+// BucketTags returns a bucket's tags
+func (b *MemoryBackend) BucketTags(ctx context.Context, bucket string) (map[string]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if _, ok := b.buckets[bucket]; !ok {
+		return nil, errNoSuchBucket()
+	}
+	tags := make(map[string]string, len(b.tags[bucket]))
+	for k, v := range b.tags[bucket] {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// ObjectTags returns an object's tags, set with the x-amz-tagging header
+// when it was uploaded
+func (b *MemoryBackend) ObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	objects, ok := b.buckets[bucket]
+	if !ok {
+		return nil, errNoSuchBucket()
+	}
+	tags := make(map[string]string)
+	if obj, ok := objects[key]; ok {
+		for k, v := range obj.tags {
+			tags[k] = v
+		}
+	}
+	return tags, nil
+}
+
+// GetObject returns an object's content directly, bypassing the gateway
+func (b *MemoryBackend) GetObject(bucket, key string) ([]byte, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	obj, ok := b.buckets[bucket][key]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), obj.data...), true
+}
+
+// Forward executes the request against the in-memory store
+func (b *MemoryBackend) Forward(ctx context.Context, req *S3Request) (*S3Response, error) {
+	switch req.Operation {
+	case "GetObject":
+		return b.getObject(req, false)
+	case "HeadObject":
+		return b.getObject(req, true)
+	case "PutObject":
+		return b.putObject(req)
+	case "DeleteObject":
+		return b.deleteObject(req)
+	case "ListObjects", "ListObjectsV2":
+		return b.listObjects(req)
+	case "HeadBucket":
+		return b.headBucket(req)
+	case "CreateBucket":
+		return b.createBucket(req)
+	case "DeleteBucket":
+		return b.deleteBucket(req)
+	default:
+		return nil, errNotImplemented(req.Operation)
+	}
+}
+
+func (b *MemoryBackend) getObject(req *S3Request, headOnly bool) (*S3Response, error) {
+	b.mu.RLock()
+	objects, ok := b.buckets[req.Bucket]
+	if !ok {
+		b.mu.RUnlock()
+		return nil, errNoSuchBucket()
+	}
+	obj, ok := objects[req.Key]
+	b.mu.RUnlock()
+	if !ok {
+		return nil, errNoSuchKey()
+	}
+
+	// Objects are replaced, never mutated, so obj is safe to use unlocked
+	headers := obj.info.headers()
+	if status := checkPreconditions(req.Headers, obj.info.ETag); status != 0 {
+		return &S3Response{StatusCode: status, Headers: headers}, nil
+	}
+	if headOnly {
+		return &S3Response{StatusCode: http.StatusOK, Headers: headers, ContentLength: obj.info.Size}, nil
+	}
+
+	start, end, partial, err := parseRange(req.Headers.Get("Range"), obj.info.Size)
+	if err != nil {
+		return nil, err
+	}
+	if !partial {
+		return &S3Response{
+			StatusCode:    http.StatusOK,
+			Headers:       headers,
+			Body:          io.NopCloser(bytes.NewReader(obj.data)),
+			ContentLength: obj.info.Size,
+		}, nil
+	}
+
+	length := end - start + 1
+	headers.Set("Content-Length", strconv.FormatInt(length, 10))
+	headers.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, obj.info.Size))
+	return &S3Response{
+		StatusCode:    http.StatusPartialContent,
+		Headers:       headers,
+		Body:          io.NopCloser(bytes.NewReader(obj.data[start : end+1])),
+		ContentLength: length,
+	}, nil
+}
+
+func (b *MemoryBackend) putObject(req *S3Request) (*S3Response, error) {
+	var data []byte
+	if req.Body != nil {
+		var err error
+		data, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	objects, ok := b.buckets[req.Bucket]
+	if !ok {
+		return nil, errNoSuchBucket()
+	}
+	var etag string
+	if old, ok := objects[req.Key]; ok {
+		etag = old.info.ETag
+	}
+	if err := checkWritePreconditions(req.Headers, etag); err != nil {
+		return nil, err
+	}
+	obj := newMemObject(req.Key, data, req.Headers)
+	objects[req.Key] = obj
+
+	headers := make(http.Header)
+	headers.Set("ETag", obj.info.ETag)
+	return &S3Response{StatusCode: http.StatusOK, Headers: headers}, nil
+}
+
+func (b *MemoryBackend) deleteObject(req *S3Request) (*S3Response, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	objects, ok := b.buckets[req.Bucket]
+	if !ok {
+		return nil, errNoSuchBucket()
+	}
+	delete(objects, req.Key)
+
+	return &S3Response{StatusCode: http.StatusNoContent, Headers: make(http.Header)}, nil
+}
+
+func (b *MemoryBackend) headBucket(req *S3Request) (*S3Response, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if _, ok := b.buckets[req.Bucket]; !ok {
+		return nil, errNoSuchBucket()
+	}
+	return &S3Response{StatusCode: http.StatusOK, Headers: make(http.Header)}, nil
+}
+
+func (b *MemoryBackend) createBucket(req *S3Request) (*S3Response, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.buckets[req.Bucket]; ok {
+		return nil, backendError(errors.ErrBucketAlreadyOwnedByYou)
+	}
+	b.buckets[req.Bucket] = make(map[string]*memObject)
+
+	headers := make(http.Header)
+	headers.Set("Location", "/"+req.Bucket)
+	return &S3Response{StatusCode: http.StatusOK, Headers: headers}, nil
+}
+
+func (b *MemoryBackend) deleteBucket(req *S3Request) (*S3Response, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	objects, ok := b.buckets[req.Bucket]
+	if !ok {
+		return nil, errNoSuchBucket()
+	}
+	if len(objects) > 0 {
+		return nil, backendError(errors.ErrBucketNotEmpty)
+	}
+	delete(b.buckets, req.Bucket)
+
+	return &S3Response{StatusCode: http.StatusNoContent, Headers: make(http.Header)}, nil
+}
+
+func (b *MemoryBackend) listObjects(req *S3Request) (*S3Response, error) {
+	b.mu.RLock()
+	objects, ok := b.buckets[req.Bucket]
+	if !ok {
+		b.mu.RUnlock()
+		return nil, errNoSuchBucket()
+	}
+	infos := make([]objectInfo, 0, len(objects))
+	for _, obj := range objects {
+		infos = append(infos, obj.info)
+	}
+	b.mu.RUnlock()
+
+	output, err := listObjectsV2(req.Bucket, infos, req.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	return listResponse(req, output)
+}
+
+func newMemObject(key string, data []byte, headers http.Header) *memObject {
+	sum := md5.Sum(data)
+	return &memObject{
+		info: objectInfo{
+			Key:             key,
+			Size:            int64(len(data)),
+			ETag:            `"` + hex.EncodeToString(sum[:]) + `"`,
+			LastModified:    time.Now().UTC(),
+			ContentType:     headers.Get("Content-Type"),
+			ContentEncoding: headers.Get("Content-Encoding"),
+			CacheControl:    headers.Get("Cache-Control"),
+			StorageClass:    headers.Get("x-amz-storage-class"),
+		},
+		data: data,
+		tags: parseTagging(headers.Get("x-amz-tagging")),
+	}
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/metrics"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+var decisionsTotal = metrics.NewCounter("gateway_decisions_total",
+	"Requests by tenant, client, bucket, action, decision and deny reason.",
+	"tenant", "client", "bucket", "action", "decision", "reason")
+
+// WithMetricLabels selects the labels of per-request metrics and bounds
+// their values, so thousands of tenants or buckets cannot blow up the
+// metrics backend. Without it tenant and action are reported unbounded.
+func WithMetricLabels(cfg *config.MetricsConfig) Option {
+	return func(g *Gateway) {
+		g.metricLabels = newMetricLabels(cfg)
+	}
+}
+
+// metricLabels decides the label values of per-request metrics. Disabled
+// labels are reported empty; values beyond a limit as metrics.OverflowValue.
+type metricLabels struct {
+	tenant, client, bucket, action bool
+
+	tenants *metrics.LabelLimit
+	buckets *metrics.LabelLimit
+	// bucketPatterns restricts the buckets reported by name
+	bucketPatterns []string
+}
+
+func newMetricLabels(cfg *config.MetricsConfig) *metricLabels {
+	if cfg == nil {
+		return &metricLabels{tenant: true, action: true}
+	}
+	m := &metricLabels{
+		tenants:        metrics.NewLabelLimit(cfg.MaxTenants),
+		buckets:        metrics.NewLabelLimit(cfg.MaxBuckets),
+		bucketPatterns: cfg.Buckets,
+	}
+	for _, label := range cfg.Labels {
+		switch label {
+		case config.MetricLabelTenant:
+			m.tenant = true
+		case config.MetricLabelClient:
+			m.client = true
+		case config.MetricLabelBucket:
+			m.bucket = true
+		case config.MetricLabelAction:
+			m.action = true
+		}
+	}
+	return m
+}
+
+// countDecision counts a request by its audit entry
+func (m *metricLabels) countDecision(e *audit.Entry) {
+	var tenant, client, bucket, action string
+	if m.tenant {
+		tenant = m.tenants.Value(e.TenantID)
+	}
+	if m.client {
+		// Client IDs come from the credential store, so they are bounded
+		// by the configured credentials
+		client = e.ClientID
+	}
+	if m.bucket {
+		bucket = m.bucketValue(e.Bucket)
+	}
+	if m.action {
+		action = e.Action
+	}
+	decisionsTotal.Inc(tenant, client, bucket, action, e.Decision, e.DenyReason)
+}
+
+// bucketValue reports buckets outside the allowed patterns, whose names may
+// come from unauthenticated requests, as metrics.OverflowValue
+func (m *metricLabels) bucketValue(bucket string) string {
+	if bucket == "" {
+		return ""
+	}
+	if len(m.bucketPatterns) > 0 && !policy.MatchScope(bucket, m.bucketPatterns) {
+		return metrics.OverflowValue
+	}
+	return m.buckets.Value(bucket)
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var mirroredRequests = metrics.NewCounter("gateway_mirror_requests_total",
+	"Allowed requests duplicated to the staging backend, by result (sent, failed, dropped)", "result")
+
+// mirrorTimeout bounds a mirrored request, including reading its response
+const mirrorTimeout = time.Minute
+
+// mirror duplicates a sample of allowed requests to a staging backend. The
+// copy is sent once the primary backend has answered and its response is
+// discarded, so the staging backend cannot affect clients.
+type mirror struct {
+	target       Forwarder
+	sampleRate   float64
+	maxBodyBytes int64
+	slots        chan struct{}
+	sample       func() float64 // returns a number in [0, 1)
+}
+
+// WithMirror duplicates a sample of allowed requests to target, e.g. an
+// S3Client for a staging endpoint. Bodies up to cfg.MaxBodyBytes are sent
+// in full; larger uploads are mirrored with their headers only.
+func WithMirror(target Forwarder, cfg *config.MirrorConfig) Option {
+	return func(g *Gateway) {
+		g.mirror = &mirror{
+			target:       target,
+			sampleRate:   cfg.SampleRate,
+			maxBodyBytes: cfg.MaxBodyBytes,
+			slots:        make(chan struct{}, cfg.MaxInFlight),
+			sample:       rand.Float64,
+		}
+	}
+}
+
+// mirrorCapture is a sampled request waiting for the primary to answer
+type mirrorCapture struct {
+	req  *S3Request
+	body *capturingReader // nil when the body is not mirrored
+}
+
+// capture samples req and, if it is selected, copies it and records its
+// body as the primary backend reads it. Returns nil for requests that are
+// not mirrored.
+func (m *mirror) capture(req *S3Request) *mirrorCapture {
+	if m == nil || m.sample() >= m.sampleRate {
+		return nil
+	}
+	query := make(url.Values, len(req.QueryParams))
+	for k, v := range req.QueryParams {
+		query[k] = append([]string(nil), v...)
+	}
+	c := &mirrorCapture{req: &S3Request{
+		Bucket:      req.Bucket,
+		Key:         req.Key,
+		Action:      req.Action,
+		Operation:   req.Operation,
+		HTTPMethod:  req.HTTPMethod,
+		Headers:     req.Headers.Clone(),
+		QueryParams: query,
+		Body:        http.NoBody,
+	}}
+	if req.Body != nil && req.Body != http.NoBody && req.ContentLength <= m.maxBodyBytes {
+		c.body = &capturingReader{ReadCloser: req.Body, max: m.maxBodyBytes}
+		req.Body = c.body
+	}
+	return c
+}
+
+// send mirrors a captured request in the background once the primary
+// backend has read its body. Requests beyond the in-flight cap are dropped.
+func (m *mirror) send(c *mirrorCapture, logKey string) {
+	if c == nil {
+		return
+	}
+	if c.body != nil && !c.body.overflow {
+		data := c.body.buf.Bytes()
+		c.req.Body, c.req.ContentLength = io.NopCloser(bytes.NewReader(data)), int64(len(data))
+	} else {
+		// Mirrored without its body; checksums of the original no longer match
+		for key := range c.req.Headers {
+			if strings.HasPrefix(strings.ToLower(key), "x-amz-checksum-") || strings.EqualFold(key, "Content-MD5") {
+				c.req.Headers.Del(key)
+			}
+		}
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		mirroredRequests.Inc("dropped")
+		return
+	}
+	go func() {
+		defer func() { <-m.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+		defer cancel()
+		resp, err := m.target.Forward(ctx, c.req)
+		if err != nil {
+			mirroredRequests.Inc("failed")
+			log.Printf("Mirrored %s %s/%s failed: %v", c.req.Action, c.req.Bucket, logKey, err)
+			return
+		}
+		if resp.Body != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		mirroredRequests.Inc("sent")
+	}()
+}
+
+// capturingReader keeps a copy of what is read from a body, up to max bytes
+type capturingReader struct {
+	io.ReadCloser
+	max      int64
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (r *capturingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if !r.overflow {
+		if int64(r.buf.Len()+n) > r.max {
+			r.overflow = true
+			r.buf = bytes.Buffer{}
+		} else {
+			r.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,282 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+const (
+	// maxCompleteBody bounds the CompleteMultipartUpload body read to check
+	// its part list; 10000 parts take well under this
+	maxCompleteBody = 2 << 20
+	// uploadIdleTimeout is how long the part sizes of an upload without
+	// activity are kept
+	uploadIdleTimeout = 7 * 24 * time.Hour
+)
+
+// WithMultipartLimits enforces per-tenant limits on the parts of multipart
+// uploads
+func WithMultipartLimits(cfg *config.MultipartConfig) Option {
+	return func(g *Gateway) {
+		g.multipart = &multipartLimiter{cfg: cfg, uploads: make(map[string]*uploadParts), now: time.Now}
+	}
+}
+
+// multipartLimiter checks part numbers and sizes at UploadPart and the part
+// list at CompleteMultipartUpload. Part sizes are remembered per upload ID as
+// parts pass through this gateway; parts uploaded through another replica
+// are unknown, so size checks at completion only cover the parts seen here.
+type multipartLimiter struct {
+	cfg *config.MultipartConfig
+	now func() time.Time
+
+	mu        sync.Mutex
+	uploads   map[string]*uploadParts
+	lastPrune time.Time
+}
+
+type uploadParts struct {
+	sizes   map[int]int64 // by part number
+	updated time.Time
+}
+
+// uploadLimitError is a request refused by a multipart limit
+type uploadLimitError struct {
+	code errors.ErrorCode
+}
+
+func (e *uploadLimitError) Error() string {
+	return e.code.Message
+}
+
+func limitError(c errors.ErrorCode, format string, args ...interface{}) error {
+	return &uploadLimitError{code: c.WithMessage(fmt.Sprintf(format, args...))}
+}
+
+// check refuses an UploadPart, UploadPartCopy or CompleteMultipartUpload
+// request outside the tenant's limits. A CompleteMultipartUpload body is
+// buffered to read its part list and replaced for forwarding.
+func (m *multipartLimiter) check(s3req *S3Request, tenantID string) error {
+	if m == nil {
+		return nil
+	}
+	limits := m.cfg.Limits(tenantID)
+	if limits == (config.MultipartLimits{}) {
+		return nil
+	}
+	uploadID := s3req.QueryParams.Get("uploadId")
+	switch s3req.Operation {
+	case "UploadPart", "UploadPartCopy":
+		return m.checkPart(s3req, uploadID, limits)
+	case "CompleteMultipartUpload":
+		return m.checkComplete(s3req, uploadID, limits)
+	}
+	return nil
+}
+
+func (m *multipartLimiter) checkPart(s3req *S3Request, uploadID string, limits config.MultipartLimits) error {
+	number, err := strconv.Atoi(s3req.QueryParams.Get("partNumber"))
+	if err != nil {
+		return nil // left for the backend to reject
+	}
+	if limits.MaxParts > 0 && number > limits.MaxParts {
+		return limitError(errors.ErrInvalidArgument,
+			"Part number must not exceed %d, the maximum number of parts per upload.", limits.MaxParts)
+	}
+	if limits.MaxPartSize == 0 && limits.MaxUploadSize == 0 {
+		return nil
+	}
+
+	size := s3req.ContentLength
+	if s3req.Operation == "UploadPartCopy" {
+		var ok bool
+		if size, ok = copyRangeSize(s3req.Headers.Get("X-Amz-Copy-Source-Range")); !ok {
+			return nil // the source size is not known without a HEAD request
+		}
+	} else if size < 0 {
+		return &uploadLimitError{code: errors.ErrMissingContentLength}
+	}
+	if limits.MaxPartSize > 0 && size > limits.MaxPartSize {
+		return limitError(errors.ErrEntityTooLarge,
+			"Part %d is larger than the maximum part size of %d bytes.", number, limits.MaxPartSize)
+	}
+	if limits.MaxUploadSize > 0 && m.uploadedSize(uploadID, number)+size > limits.MaxUploadSize {
+		return limitError(errors.ErrEntityTooLarge,
+			"The upload would exceed the maximum upload size of %d bytes.", limits.MaxUploadSize)
+	}
+	return nil
+}
+
+// completeMultipartUpload is the part list of a CompleteMultipartUpload body
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int `xml:"PartNumber"`
+	} `xml:"Part"`
+}
+
+func (m *multipartLimiter) checkComplete(s3req *S3Request, uploadID string, limits config.MultipartLimits) error {
+	if s3req.Body == nil {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(s3req.Body, maxCompleteBody+1))
+	s3req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read part list: %w", err)
+	}
+	if len(data) > maxCompleteBody {
+		return &uploadLimitError{code: errors.ErrMaxMessageLengthExceeded}
+	}
+	s3req.Body, s3req.ContentLength = io.NopCloser(bytes.NewReader(data)), int64(len(data))
+
+	var body completeMultipartUpload
+	if err := xml.Unmarshal(data, &body); err != nil {
+		return &uploadLimitError{code: errors.ErrMalformedXML}
+	}
+	if limits.MaxParts > 0 && len(body.Parts) > limits.MaxParts {
+		return limitError(errors.ErrInvalidArgument,
+			"An upload may have at most %d parts.", limits.MaxParts)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[uploadID]
+	if !ok {
+		return nil
+	}
+	var total int64
+	for i, part := range body.Parts {
+		size, ok := upload.sizes[part.PartNumber]
+		if !ok {
+			continue
+		}
+		total += size
+		if i < len(body.Parts)-1 && size < limits.MinPartSize {
+			return limitError(errors.ErrEntityTooSmall,
+				"Part %d is smaller than the minimum part size of %d bytes.", part.PartNumber, limits.MinPartSize)
+		}
+	}
+	if limits.MaxUploadSize > 0 && total > limits.MaxUploadSize {
+		return limitError(errors.ErrEntityTooLarge,
+			"The upload exceeds the maximum upload size of %d bytes.", limits.MaxUploadSize)
+	}
+	return nil
+}
+
+// uploadedSize returns the size of the parts of an upload seen so far,
+// excluding part number, which a new upload of the part replaces
+func (m *multipartLimiter) uploadedSize(uploadID string, number int) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[uploadID]
+	if !ok {
+		return 0
+	}
+	var total int64
+	for n, size := range upload.sizes {
+		if n != number {
+			total += size
+		}
+	}
+	return total
+}
+
+// record remembers the size of a part the backend accepted, and forgets
+// uploads that were completed or aborted
+func (m *multipartLimiter) record(s3req *S3Request) {
+	if m == nil {
+		return
+	}
+	uploadID := s3req.QueryParams.Get("uploadId")
+	if uploadID == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.Sub(m.lastPrune) > time.Hour {
+		for id, upload := range m.uploads {
+			if now.Sub(upload.updated) > uploadIdleTimeout {
+				delete(m.uploads, id)
+			}
+		}
+		m.lastPrune = now
+	}
+
+	switch s3req.Operation {
+	case "CompleteMultipartUpload", "AbortMultipartUpload":
+		delete(m.uploads, uploadID)
+	case "UploadPart", "UploadPartCopy":
+		number, err := strconv.Atoi(s3req.QueryParams.Get("partNumber"))
+		if err != nil {
+			return
+		}
+		size := s3req.ContentLength
+		if s3req.Operation == "UploadPartCopy" {
+			var ok bool
+			if size, ok = copyRangeSize(s3req.Headers.Get("X-Amz-Copy-Source-Range")); !ok {
+				return
+			}
+		}
+		if size < 0 {
+			return
+		}
+		upload, ok := m.uploads[uploadID]
+		if !ok {
+			upload = &uploadParts{sizes: make(map[int]int64)}
+			m.uploads[uploadID] = upload
+		}
+		upload.sizes[number] = size
+		upload.updated = now
+	}
+}
+
+// copyRangeSize returns the length of an x-amz-copy-source-range, e.g.
+// "bytes=0-1048575"
+func copyRangeSize(v string) (int64, bool) {
+	first, last, ok := strings.Cut(strings.TrimPrefix(v, "bytes="), "-")
+	if !ok {
+		return 0, false
+	}
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	end, err2 := strconv.ParseInt(last, 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return 0, false
+	}
+	return end - start + 1, true
+}
+
+// handleUploadLimit writes the error of a request refused by a multipart
+// limit and logs the denial; other errors come from reading the request
+func (g *Gateway) handleUploadLimit(w http.ResponseWriter, rc *RequestContext, err error) {
+	var limitErr *uploadLimitError
+	if !stderrors.As(err, &limitErr) {
+		g.handleS3Error(w, rc, err)
+		return
+	}
+	log.Printf("[%s] Upload limit exceeded: client=%s tenant=%s upload=%s: %v",
+		rc.RequestID, rc.ClientID(), rc.TenantID(), rc.S3.QueryParams.Get("uploadId"), err)
+
+	entry := audit.NewDenyEntry(rc.RequestID, rc.ClientID(), rc.TenantID(), rc.S3.Action,
+		rc.S3.Bucket, rc.S3.Key, g.clientIP(rc.Request), rc.Request.UserAgent(),
+		string(errors.DenyUploadLimit), time.Since(rc.StartTime))
+	entry.ErrorMsg = err.Error()
+	defer g.logAudit(entry, w, rc.Request)
+
+	if g.denyReasonHeader {
+		w.Header().Set(DenyReasonHeader, string(errors.DenyUploadLimit))
+	}
+	errors.WriteS3ErrorCode(w, limitErr.code, rc.RequestID)
+}
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// operation is an S3 API operation and the action policies authorize it as
+type operation struct {
+	name   string // e.g. "ListObjectsV2"
+	action string // e.g. "s3:ListBucket"
+}
+
+// route is what distinguishes S3 operations on the REST API: the method,
+// the subresource query parameter and whether the request names an object
+type route struct {
+	method      string
+	subresource string // "" for none
+	object      bool
+}
+
+// subresources are the query parameters that select an operation, in order
+// of precedence
+var subresources = []string{
+	"uploadId", "uploads", "delete", "restore", "select", "attributes",
+	"acl", "tagging", "retention", "legal-hold", "torrent",
+	"accelerate", "analytics", "cors", "encryption", "intelligent-tiering", "inventory",
+	"lifecycle", "location", "logging", "metrics", "notification", "object-lock",
+	"ownershipControls", "policy", "policyStatus", "publicAccessBlock", "replication",
+	"requestPayment", "versioning", "versions", "website", "session",
+}
+
+// routes lists the operations of each route. Where a route has two, the
+// second is chosen by alternate.
+var routes = map[route][]operation{
+	// Objects
+	{http.MethodGet, "", true}:            {{"GetObject", "s3:GetObject"}},
+	{http.MethodHead, "", true}:           {{"HeadObject", "s3:GetObject"}},
+	{http.MethodPut, "", true}:            {{"PutObject", "s3:PutObject"}, {"CopyObject", "s3:PutObject"}},
+	{http.MethodDelete, "", true}:         {{"DeleteObject", "s3:DeleteObject"}},
+	{http.MethodPost, "uploads", true}:    {{"CreateMultipartUpload", "s3:PutObject"}},
+	{http.MethodPut, "uploadId", true}:    {{"UploadPart", "s3:PutObject"}, {"UploadPartCopy", "s3:PutObject"}},
+	{http.MethodPost, "uploadId", true}:   {{"CompleteMultipartUpload", "s3:PutObject"}},
+	{http.MethodDelete, "uploadId", true}: {{"AbortMultipartUpload", "s3:AbortMultipartUpload"}},
+	{http.MethodGet, "uploadId", true}:    {{"ListParts", "s3:ListMultipartUploadParts"}},
+	{http.MethodGet, "acl", true}:         {{"GetObjectAcl", "s3:GetObjectAcl"}},
+	{http.MethodPut, "acl", true}:         {{"PutObjectAcl", "s3:PutObjectAcl"}},
+	{http.MethodGet, "tagging", true}:     {{"GetObjectTagging", "s3:GetObjectTagging"}},
+	{http.MethodPut, "tagging", true}:     {{"PutObjectTagging", "s3:PutObjectTagging"}},
+	{http.MethodDelete, "tagging", true}:  {{"DeleteObjectTagging", "s3:DeleteObjectTagging"}},
+	{http.MethodGet, "attributes", true}:  {{"GetObjectAttributes", "s3:GetObjectAttributes"}},
+	{http.MethodGet, "retention", true}:   {{"GetObjectRetention", "s3:GetObjectRetention"}},
+	{http.MethodPut, "retention", true}:   {{"PutObjectRetention", "s3:PutObjectRetention"}},
+	{http.MethodGet, "legal-hold", true}:  {{"GetObjectLegalHold", "s3:GetObjectLegalHold"}},
+	{http.MethodPut, "legal-hold", true}:  {{"PutObjectLegalHold", "s3:PutObjectLegalHold"}},
+	{http.MethodGet, "torrent", true}:     {{"GetObjectTorrent", "s3:GetObjectTorrent"}},
+	{http.MethodPost, "restore", true}:    {{"RestoreObject", "s3:RestoreObject"}},
+	{http.MethodPost, "select", true}:     {{"SelectObjectContent", "s3:GetObject"}},
+
+	// Buckets
+	{http.MethodGet, "", false}:             {{"ListObjects", "s3:ListBucket"}, {"ListObjectsV2", "s3:ListBucket"}},
+	{http.MethodHead, "", false}:            {{"HeadBucket", "s3:ListBucket"}},
+	{http.MethodPut, "", false}:             {{"CreateBucket", "s3:CreateBucket"}},
+	{http.MethodDelete, "", false}:          {{"DeleteBucket", "s3:DeleteBucket"}},
+	{http.MethodPost, "delete", false}:      {{"DeleteObjects", "s3:DeleteObject"}},
+	{http.MethodGet, "uploads", false}:      {{"ListMultipartUploads", "s3:ListBucketMultipartUploads"}},
+	{http.MethodGet, "versions", false}:     {{"ListObjectVersions", "s3:ListBucketVersions"}},
+	{http.MethodGet, "location", false}:     {{"GetBucketLocation", "s3:GetBucketLocation"}},
+	{http.MethodGet, "policyStatus", false}: {{"GetBucketPolicyStatus", "s3:GetBucketPolicyStatus"}},
+	{http.MethodGet, "session", false}:      {{"CreateSession", "s3express:CreateSession"}},
+
+	// Bucket configuration
+	{http.MethodGet, "acl", false}:                    {{"GetBucketAcl", "s3:GetBucketAcl"}},
+	{http.MethodPut, "acl", false}:                    {{"PutBucketAcl", "s3:PutBucketAcl"}},
+	{http.MethodGet, "tagging", false}:                {{"GetBucketTagging", "s3:GetBucketTagging"}},
+	{http.MethodPut, "tagging", false}:                {{"PutBucketTagging", "s3:PutBucketTagging"}},
+	{http.MethodDelete, "tagging", false}:             {{"DeleteBucketTagging", "s3:DeleteBucketTagging"}},
+	{http.MethodGet, "policy", false}:                 {{"GetBucketPolicy", "s3:GetBucketPolicy"}},
+	{http.MethodPut, "policy", false}:                 {{"PutBucketPolicy", "s3:PutBucketPolicy"}},
+	{http.MethodDelete, "policy", false}:              {{"DeleteBucketPolicy", "s3:DeleteBucketPolicy"}},
+	{http.MethodGet, "versioning", false}:             {{"GetBucketVersioning", "s3:GetBucketVersioning"}},
+	{http.MethodPut, "versioning", false}:             {{"PutBucketVersioning", "s3:PutBucketVersioning"}},
+	{http.MethodGet, "lifecycle", false}:              {{"GetBucketLifecycleConfiguration", "s3:GetLifecycleConfiguration"}},
+	{http.MethodPut, "lifecycle", false}:              {{"PutBucketLifecycleConfiguration", "s3:PutLifecycleConfiguration"}},
+	{http.MethodDelete, "lifecycle", false}:           {{"DeleteBucketLifecycle", "s3:DeleteLifecycleConfiguration"}},
+	{http.MethodGet, "cors", false}:                   {{"GetBucketCors", "s3:GetBucketCORS"}},
+	{http.MethodPut, "cors", false}:                   {{"PutBucketCors", "s3:PutBucketCORS"}},
+	{http.MethodDelete, "cors", false}:                {{"DeleteBucketCors", "s3:PutBucketCORS"}},
+	{http.MethodGet, "encryption", false}:             {{"GetBucketEncryption", "s3:GetEncryptionConfiguration"}},
+	{http.MethodPut, "encryption", false}:             {{"PutBucketEncryption", "s3:PutEncryptionConfiguration"}},
+	{http.MethodDelete, "encryption", false}:          {{"DeleteBucketEncryption", "s3:PutEncryptionConfiguration"}},
+	{http.MethodGet, "website", false}:                {{"GetBucketWebsite", "s3:GetBucketWebsite"}},
+	{http.MethodPut, "website", false}:                {{"PutBucketWebsite", "s3:PutBucketWebsite"}},
+	{http.MethodDelete, "website", false}:             {{"DeleteBucketWebsite", "s3:DeleteBucketWebsite"}},
+	{http.MethodGet, "logging", false}:                {{"GetBucketLogging", "s3:GetBucketLogging"}},
+	{http.MethodPut, "logging", false}:                {{"PutBucketLogging", "s3:PutBucketLogging"}},
+	{http.MethodGet, "notification", false}:           {{"GetBucketNotificationConfiguration", "s3:GetBucketNotification"}},
+	{http.MethodPut, "notification", false}:           {{"PutBucketNotificationConfiguration", "s3:PutBucketNotification"}},
+	{http.MethodGet, "object-lock", false}:            {{"GetObjectLockConfiguration", "s3:GetBucketObjectLockConfiguration"}},
+	{http.MethodPut, "object-lock", false}:            {{"PutObjectLockConfiguration", "s3:PutBucketObjectLockConfiguration"}},
+	{http.MethodGet, "ownershipControls", false}:      {{"GetBucketOwnershipControls", "s3:GetBucketOwnershipControls"}},
+	{http.MethodPut, "ownershipControls", false}:      {{"PutBucketOwnershipControls", "s3:PutBucketOwnershipControls"}},
+	{http.MethodDelete, "ownershipControls", false}:   {{"DeleteBucketOwnershipControls", "s3:PutBucketOwnershipControls"}},
+	{http.MethodGet, "publicAccessBlock", false}:      {{"GetPublicAccessBlock", "s3:GetBucketPublicAccessBlock"}},
+	{http.MethodPut, "publicAccessBlock", false}:      {{"PutPublicAccessBlock", "s3:PutBucketPublicAccessBlock"}},
+	{http.MethodDelete, "publicAccessBlock", false}:   {{"DeletePublicAccessBlock", "s3:PutBucketPublicAccessBlock"}},
+	{http.MethodGet, "replication", false}:            {{"GetBucketReplication", "s3:GetReplicationConfiguration"}},
+	{http.MethodPut, "replication", false}:            {{"PutBucketReplication", "s3:PutReplicationConfiguration"}},
+	{http.MethodDelete, "replication", false}:         {{"DeleteBucketReplication", "s3:PutReplicationConfiguration"}},
+	{http.MethodGet, "requestPayment", false}:         {{"GetBucketRequestPayment", "s3:GetBucketRequestPayment"}},
+	{http.MethodPut, "requestPayment", false}:         {{"PutBucketRequestPayment", "s3:PutBucketRequestPayment"}},
+	{http.MethodGet, "accelerate", false}:             {{"GetBucketAccelerateConfiguration", "s3:GetAccelerateConfiguration"}},
+	{http.MethodPut, "accelerate", false}:             {{"PutBucketAccelerateConfiguration", "s3:PutAccelerateConfiguration"}},
+	{http.MethodGet, "analytics", false}:              {{"GetBucketAnalyticsConfiguration", "s3:GetAnalyticsConfiguration"}, {"ListBucketAnalyticsConfigurations", "s3:GetAnalyticsConfiguration"}},
+	{http.MethodPut, "analytics", false}:              {{"PutBucketAnalyticsConfiguration", "s3:PutAnalyticsConfiguration"}},
+	{http.MethodDelete, "analytics", false}:           {{"DeleteBucketAnalyticsConfiguration", "s3:PutAnalyticsConfiguration"}},
+	{http.MethodGet, "intelligent-tiering", false}:    {{"GetBucketIntelligentTieringConfiguration", "s3:GetIntelligentTieringConfiguration"}, {"ListBucketIntelligentTieringConfigurations", "s3:GetIntelligentTieringConfiguration"}},
+	{http.MethodPut, "intelligent-tiering", false}:    {{"PutBucketIntelligentTieringConfiguration", "s3:PutIntelligentTieringConfiguration"}},
+	{http.MethodDelete, "intelligent-tiering", false}: {{"DeleteBucketIntelligentTieringConfiguration", "s3:PutIntelligentTieringConfiguration"}},
+	{http.MethodGet, "inventory", false}:              {{"GetBucketInventoryConfiguration", "s3:GetInventoryConfiguration"}, {"ListBucketInventoryConfigurations", "s3:GetInventoryConfiguration"}},
+	{http.MethodPut, "inventory", false}:              {{"PutBucketInventoryConfiguration", "s3:PutInventoryConfiguration"}},
+	{http.MethodDelete, "inventory", false}:           {{"DeleteBucketInventoryConfiguration", "s3:PutInventoryConfiguration"}},
+	{http.MethodGet, "metrics", false}:                {{"GetBucketMetricsConfiguration", "s3:GetMetricsConfiguration"}, {"ListBucketMetricsConfigurations", "s3:GetMetricsConfiguration"}},
+	{http.MethodPut, "metrics", false}:                {{"PutBucketMetricsConfiguration", "s3:PutMetricsConfiguration"}},
+	{http.MethodDelete, "metrics", false}:             {{"DeleteBucketMetricsConfiguration", "s3:PutMetricsConfiguration"}},
+}
+
+// classify returns the operation of a request, or false when it is not an
+// S3 operation the gateway knows. An x-id query parameter, sent by the SDKs
+// for operations that share a route, must name the operation the request's
+// headers and query select, so it cannot disguise a copy as a plain upload.
+func classify(method, key string, query url.Values, header http.Header) (operation, bool) {
+	r := route{method: method, object: key != ""}
+	for _, s := range subresources {
+		if query.Has(s) {
+			r.subresource = s
+			break
+		}
+	}
+	ops := routes[r]
+	if len(ops) == 0 {
+		return operation{}, false
+	}
+
+	op := ops[0]
+	if len(ops) > 1 && alternate(r, query, header) {
+		op = ops[1]
+	}
+	if id := query.Get("x-id"); id != "" && id != op.name {
+		return operation{}, false
+	}
+	return op, true
+}
+
+// alternate reports whether a request is the second operation of its route:
+// a V2 listing, a copy, or a listing of bucket configurations without an id
+func alternate(r route, query url.Values, header http.Header) bool {
+	switch {
+	case r.method == http.MethodPut:
+		return header.Get("X-Amz-Copy-Source") != ""
+	case r.subresource == "":
+		return query.Get("list-type") == "2"
+	default:
+		return !query.Has("id")
+	}
+}
+
+// SupportedOperations are the operations the built-in backends forward, or
+// the gateway answers itself (CreateSession), and the operations a gateway
+// serves unless configured otherwise
+var SupportedOperations = []string{
+	"GetObject", "HeadObject", "PutObject", "DeleteObject",
+	"ListObjects", "ListObjectsV2", "HeadBucket", "CreateBucket", "DeleteBucket",
+	"CreateSession",
+}
+
+// WithOperations serves only the named S3 operations; others are rejected
+// with 501 NotImplemented before authentication. Names are not checked, so a
+// custom Forwarder may serve operations the built-in backends do not.
+func WithOperations(names []string) Option {
+	return func(g *Gateway) {
+		g.operations = make(map[string]bool, len(names))
+		for _, name := range names {
+			g.operations[name] = true
+		}
+	}
+}
+
+// CheckOperations reports operation names that are not S3 operations or
+// that the built-in backends do not forward
+func CheckOperations(names []string) error {
+	known := make(map[string]bool)
+	for _, ops := range routes {
+		for _, op := range ops {
+			known[op.name] = true
+		}
+	}
+	var errs []error
+	for _, name := range names {
+		switch {
+		case !known[name]:
+			errs = append(errs, fmt.Errorf("operations: %q is not an S3 operation", name))
+		case !slices.Contains(SupportedOperations, name):
+			errs = append(errs, fmt.Errorf("operations: %s is not supported by the backends", name))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// Every action the gateway derives must be known to policy lint
+func TestRoutes_KnownActions(t *testing.T) {
+	for r, ops := range routes {
+		for _, op := range ops {
+			if !slices.Contains(policy.KnownActions, op.action) {
+				t.Errorf("%+v: %s action %s is not in policy.KnownActions", r, op.name, op.action)
+			}
+		}
+	}
+}
+
+func TestCheckOperations(t *testing.T) {
+	if err := CheckOperations(SupportedOperations); err != nil {
+		t.Errorf("supported operations: %v", err)
+	}
+	err := CheckOperations([]string{"GetObject", "CopyObject", "GetObjekt"})
+	if err == nil || !strings.Contains(err.Error(), "CopyObject is not supported") || !strings.Contains(err.Error(), `"GetObjekt" is not an S3 operation`) {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+// Package proxytest runs a complete Gateway in-process against the in-memory
+// backend, so policy and audit behavior can be tested without S3 or Docker.
+package proxytest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"gopkg.in/yaml.v3"
+)
+
+// Region is the region test requests are signed for
+const Region = "us-east-1"
+
+// Gateway is a running test gateway
+type Gateway struct {
+	*httptest.Server
+	Gateway *proxy.Gateway
+	Backend *proxy.MemoryBackend
+	Audit   *RecordingLogger
+}
+
+// NewGateway starts a gateway loaded with the given credentials and policies,
+// backed by an in-memory store containing buckets. The server is closed when
+// the test finishes.
+func NewGateway(t testing.TB, credentials []config.Credential, policies []config.Policy, buckets ...string) *Gateway {
+	t.Helper()
+	return NewGatewayWithOptions(t, credentials, policies, buckets)
+}
+
+// NewGatewayWithOptions is NewGateway with additional gateway options
+func NewGatewayWithOptions(t testing.TB, credentials []config.Credential, policies []config.Policy, buckets []string, opts ...proxy.Option) *Gateway {
+	t.Helper()
+	return NewGatewayWithBackend(t, credentials, policies, proxy.NewMemoryBackend(buckets...), opts...)
+}
+
+// NewGatewayWithBackend is NewGatewayWithOptions with a backend created by the
+// caller, for options that take the backend
+func NewGatewayWithBackend(t testing.TB, credentials []config.Credential, policies []config.Policy, backend *proxy.MemoryBackend, opts ...proxy.Option) *Gateway {
+	t.Helper()
+	gw := NewGatewayWithForwarder(t, credentials, policies, backend, opts...)
+	gw.Backend = backend
+	return gw
+}
+
+// NewGatewayWithForwarder starts a gateway forwarding to any backend, such
+// as a proxy.ForwarderFunc stubbing upstream failures. Backend is left nil.
+func NewGatewayWithForwarder(t testing.TB, credentials []config.Credential, policies []config.Policy, backend proxy.Forwarder, opts ...proxy.Option) *Gateway {
+	t.Helper()
+
+	dir := t.TempDir()
+	credsPath := writeYAML(t, dir, "credentials.yaml", config.CredentialsConfig{Credentials: credentials})
+	policiesPath := writeYAML(t, dir, "policies.yaml", config.PoliciesConfig{Policies: policies})
+
+	credStore, err := auth.NewInMemoryCredentialStore(credsPath)
+	if err != nil {
+		t.Fatalf("proxytest: failed to load credentials: %v", err)
+	}
+	policyEngine, err := policy.NewEngine(policiesPath)
+	if err != nil {
+		t.Fatalf("proxytest: failed to load policies: %v", err)
+	}
+
+	recorder := &RecordingLogger{}
+	gateway := proxy.NewGateway(credStore, auth.NewSignatureValidator(), policyEngine, backend, recorder, opts...)
+
+	// Audit entries are logged after the response is written, so a client
+	// can see the response first; reads of the recorder wait for handlers
+	recorder.inflight.cond = sync.NewCond(&recorder.inflight.mu)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder.inflight.add(1)
+		defer recorder.inflight.add(-1)
+		gateway.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return &Gateway{
+		Server:  server,
+		Gateway: gateway,
+		Audit:   recorder,
+	}
+}
+
+// Do sends a request signed with cred to the gateway. path is the
+// path-style resource, e.g. "/bucket/key?list-type=2".
+func (g *Gateway) Do(t testing.TB, cred config.Credential, method, path string, body []byte) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, g.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("proxytest: failed to build request: %v", err)
+	}
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	Sign(t, req, cred, body)
+
+	resp, err := g.Client().Do(req)
+	if err != nil {
+		t.Fatalf("proxytest: request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// Sign signs req with cred using AWS Signature V4, as an S3 SDK would
+func Sign(t testing.TB, req *http.Request, cred config.Credential, body []byte) {
+	t.Helper()
+
+	sum := sha256.Sum256(body)
+	SignPayload(t, req, cred, hex.EncodeToString(sum[:]))
+}
+
+// SignPayload signs req with an explicit x-amz-content-sha256 value, such as
+// UNSIGNED-PAYLOAD or a streaming mode
+func SignPayload(t testing.TB, req *http.Request, cred config.Credential, payloadHash string) {
+	t.Helper()
+
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds := aws.Credentials{AccessKeyID: cred.AccessKey, SecretAccessKey: cred.SecretKey}
+	err := v4.NewSigner().SignHTTP(context.Background(), creds, req, payloadHash, "s3", Region, time.Now().UTC(),
+		func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+	if err != nil {
+		t.Fatalf("proxytest: failed to sign request: %v", err)
+	}
+}
+
+// ReadBody reads and returns the response body
+func ReadBody(t testing.TB, resp *http.Response) string {
+	t.Helper()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("proxytest: failed to read body: %v", err)
+	}
+	return string(data)
+}
+
+// RecordingLogger is an audit.Logger that keeps entries in memory
+type RecordingLogger struct {
+	mu       sync.Mutex
+	entries  []*audit.Entry
+	err      error
+	inflight inflight
+}
+
+// inflight counts running handlers of a test gateway
+type inflight struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	n    int
+}
+
+func (f *inflight) add(delta int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n += delta
+	if f.n == 0 {
+		f.cond.Broadcast()
+	}
+}
+
+// wait blocks until no handler is running
+func (f *inflight) wait() {
+	if f.cond == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.n > 0 {
+		f.cond.Wait()
+	}
+}
+
+// Log records an entry, or fails with the error set by SetError
+func (l *RecordingLogger) Log(entry *audit.Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// SetError makes subsequent Log calls fail with err, simulating a broken
+// audit sink; nil restores normal recording
+func (l *RecordingLogger) SetError(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+}
+
+// Close implements audit.Logger
+func (l *RecordingLogger) Close() error { return nil }
+
+// Entries returns a copy of the recorded entries, once in-flight requests
+// to the test gateway have finished
+func (l *RecordingLogger) Entries() []*audit.Entry {
+	l.inflight.wait()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*audit.Entry(nil), l.entries...)
+}
+
+// Last returns the most recent entry, or nil, once in-flight requests to the
+// test gateway have finished
+func (l *RecordingLogger) Last() *audit.Entry {
+	l.inflight.wait()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) == 0 {
+		return nil
+	}
+	return l.entries[len(l.entries)-1]
+}
+
+func writeYAML(t testing.TB, dir, name string, v interface{}) string {
+	t.Helper()
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatalf("proxytest: failed to marshal %s: %v", name, err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("proxytest: failed to write %s: %v", name, err)
+	}
+	return path
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/metrics"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+var (
+	rateLimitedRequests = metrics.NewCounter("gateway_rate_limited_requests_total",
+		"Requests rejected by a policy statement's rate limit.", "policy")
+	sharedRateLimitErrors = metrics.NewCounter("gateway_shared_rate_limit_errors_total",
+		"Rate limit checks that fell back to per-instance limits because shared state was unavailable.")
+)
+
+// SharedRateLimiter enforces rate limits across gateway replicas. Allow
+// takes a token from the client's bucket of every limit, or none if one is
+// empty, returning that limit's index and the wait until it refills; the
+// index is -1 if the request is allowed.
+type SharedRateLimiter interface {
+	Allow(ctx context.Context, clientID string, limits []policy.AppliedRateLimit) (int, time.Duration, error)
+}
+
+// WithSharedRateLimits enforces statement rate limits cluster-wide. While
+// the shared state is unavailable each instance enforces them on its own.
+func WithSharedRateLimits(shared SharedRateLimiter) Option {
+	return func(g *Gateway) {
+		g.rateLimiter.shared = shared
+	}
+}
+
+// rateLimiter enforces the rate limits of policy statements with a token
+// bucket per client and statement, held locally or in shared state
+type rateLimiter struct {
+	now    func() time.Time
+	shared SharedRateLimiter
+
+	mu              sync.Mutex
+	buckets         map[string]*tokenBucket
+	lastSweep       time.Time
+	lastSharedError time.Time
+}
+
+type tokenBucket struct {
+	limit  policy.AppliedRateLimit
+	tokens float64
+	last   time.Time
+}
+
+// rateLimitSweepInterval is how often buckets refilled to capacity, which
+// behave like new ones, are forgotten
+const rateLimitSweepInterval = time.Minute
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{now: time.Now, buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token from the client's bucket of every limit. If any is
+// empty, none is taken and allow returns how long until it refills.
+func (l *rateLimiter) allow(ctx context.Context, clientID string, limits []policy.AppliedRateLimit) (bool, time.Duration) {
+	if len(limits) == 0 {
+		return true, 0
+	}
+	if l.shared != nil {
+		denied, wait, err := l.shared.Allow(ctx, clientID, limits)
+		if err == nil {
+			if denied >= 0 {
+				rateLimitedRequests.Inc(limits[denied].Policy)
+				return false, wait
+			}
+			return true, 0
+		}
+		sharedRateLimitErrors.Inc()
+		l.logSharedError(err)
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	buckets := make([]*tokenBucket, len(limits))
+	for i, limit := range limits {
+		key := fmt.Sprintf("%s\x00%s\x00%d", clientID, limit.Policy, limit.Statement)
+		b, ok := l.buckets[key]
+		// A reloaded policy may change the limit; start it afresh
+		if !ok || b.limit != limit {
+			b = &tokenBucket{limit: limit, tokens: burst(limit), last: now}
+			l.buckets[key] = b
+		}
+		b.refill(now)
+		if b.tokens < 1 {
+			rateLimitedRequests.Inc(limit.Policy)
+			wait := time.Duration((1 - b.tokens) / limit.RequestsPerSecond * float64(time.Second))
+			return false, wait
+		}
+		buckets[i] = b
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0
+}
+
+// logSharedError logs shared state failures at most once a minute, as
+// every rate-limited request fails while it is unavailable
+func (l *rateLimiter) logSharedError(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := l.now(); now.Sub(l.lastSharedError) >= time.Minute {
+		l.lastSharedError = now
+		log.Printf("Shared rate limits unavailable, enforcing them per instance: %v", err)
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(burst(b.limit), b.tokens+elapsed*b.limit.RequestsPerSecond)
+	b.last = now
+}
+
+// sweep forgets full buckets. Called with l.mu held.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.refill(now); b.tokens >= burst(b.limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func burst(limit policy.AppliedRateLimit) float64 {
+	if limit.Burst > 0 {
+		return float64(limit.Burst)
+	}
+	return math.Ceil(limit.RequestsPerSecond)
+}
+
+// retryAfter formats a wait as whole seconds for the Retry-After header
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(wait.Seconds()))))
+}
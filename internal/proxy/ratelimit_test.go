@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter()
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	deletes := policy.AppliedRateLimit{Policy: "full", Statement: 1, RateLimit: config.RateLimit{RequestsPerSecond: 2, Burst: 3}}
+	writes := policy.AppliedRateLimit{Policy: "writes", Statement: 0, RateLimit: config.RateLimit{RequestsPerSecond: 10}}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow(ctx, "app", []policy.AppliedRateLimit{deletes, writes}); !ok {
+			t.Fatalf("request %d within burst was limited", i)
+		}
+	}
+	ok, wait := l.allow(ctx, "app", []policy.AppliedRateLimit{deletes, writes})
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("beyond burst: ok = %v, wait = %s, want limited for 500ms", ok, wait)
+	}
+	// A rejected request takes no token from the other buckets
+	for i := 0; i < 7; i++ {
+		if ok, _ := l.allow(ctx, "app", []policy.AppliedRateLimit{writes}); !ok {
+			t.Fatalf("writes limited after %d requests", 3+i)
+		}
+	}
+	if ok, _ := l.allow(ctx, "other", []policy.AppliedRateLimit{deletes}); !ok {
+		t.Error("another client shares the bucket")
+	}
+	if ok, _ := l.allow(ctx, "app", nil); !ok {
+		t.Error("request without rate limits was limited")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.allow(ctx, "app", []policy.AppliedRateLimit{deletes}); !ok {
+		t.Error("bucket did not refill")
+	}
+
+	// A changed limit starts a new bucket
+	deletes.Burst = 5
+	if ok, _ := l.allow(ctx, "app", []policy.AppliedRateLimit{deletes}); !ok {
+		t.Error("changed limit kept the old bucket")
+	}
+}
+
+type fakeSharedLimiter struct {
+	denied int
+	err    error
+}
+
+func (f *fakeSharedLimiter) Allow(context.Context, string, []policy.AppliedRateLimit) (int, time.Duration, error) {
+	return f.denied, 2 * time.Second, f.err
+}
+
+func TestRateLimiter_Shared(t *testing.T) {
+	l := newRateLimiter()
+	ctx := context.Background()
+	shared := &fakeSharedLimiter{denied: 0}
+	l.shared = shared
+	limits := []policy.AppliedRateLimit{{Policy: "full", RateLimit: config.RateLimit{RequestsPerSecond: 1}}}
+
+	if ok, wait := l.allow(ctx, "app", limits); ok || wait != 2*time.Second {
+		t.Errorf("shared denial: ok = %v, wait = %v", ok, wait)
+	}
+	shared.denied = -1
+	if ok, _ := l.allow(ctx, "app", limits); !ok {
+		t.Error("shared allow was limited")
+	}
+
+	// Unavailable shared state falls back to the local bucket
+	shared.err = errors.New("connection refused")
+	if ok, _ := l.allow(ctx, "app", limits); !ok {
+		t.Error("first local request was limited")
+	}
+	if ok, _ := l.allow(ctx, "app", limits); ok {
+		t.Error("local fallback did not enforce the limit")
+	}
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// fingerprinter is implemented by policy engines and credential stores that
+// can identify the configuration they loaded
+type fingerprinter interface {
+	Fingerprint() string
+}
+
+// ConfigVersions identifies the policies and credentials a gateway serves,
+// so replicas left on an old version by a partial rollout stand out
+type ConfigVersions struct {
+	Policies    string `json:"policies"`
+	Credentials string `json:"credentials"`
+	// Fingerprint combines both
+	Fingerprint string `json:"fingerprint"`
+}
+
+// ConfigVersions returns the fingerprints of the loaded configuration;
+// sources that cannot fingerprint their content report empty versions
+func (g *Gateway) ConfigVersions() ConfigVersions {
+	var v ConfigVersions
+	if f, ok := g.policyEngine.(fingerprinter); ok {
+		v.Policies = f.Fingerprint()
+	}
+	if f, ok := g.credStore.(fingerprinter); ok {
+		v.Credentials = f.Fingerprint()
+	}
+	v.Fingerprint = config.Fingerprint([]string{v.Policies, v.Credentials})
+	return v
+}
+
+// serveReadiness answers /readyz with the configuration versions, failing
+// like /health while in maintenance
+func (g *Gateway) serveReadiness(w http.ResponseWriter) {
+	status, state := http.StatusOK, "ready"
+	if g.InMaintenance() {
+		status, state = http.StatusServiceUnavailable, "maintenance"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Status string `json:"status"`
+		ConfigVersions
+	}{state, g.ConfigVersions()})
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+// Redirect modes for upstream PermanentRedirect responses
+const (
+	RedirectFollow      = "follow"
+	RedirectPassthrough = "passthrough"
+)
+
+var redirectsTotal = metrics.NewCounter("gateway_upstream_redirects_total",
+	"Upstream region redirects, by how they were handled.", "outcome")
+
+// redirectRegion returns the region an upstream 301 redirect points at, or
+// "" if err is not a region redirect
+func redirectRegion(err error) string {
+	var respErr *awshttp.ResponseError
+	if !stderrors.As(err, &respErr) || respErr.Response == nil {
+		return ""
+	}
+	if respErr.HTTPStatusCode() != http.StatusMovedPermanently {
+		return ""
+	}
+	return respErr.Response.Header.Get("X-Amz-Bucket-Region")
+}
+
+// errPermanentRedirect reports that bucket lives in region, as S3 does
+func errPermanentRedirect(bucket, region string) *BackendError {
+	headers := make(http.Header)
+	headers.Set("X-Amz-Bucket-Region", region)
+	err := backendError(errors.ErrPermanentRedirect.WithMessage(fmt.Sprintf(
+		"The bucket %s must be addressed in region %s. Send all future requests to this region.", bucket, region)))
+	err.Headers = headers
+	return err
+}
+
+// followRedirect handles a region redirect for req. The bucket's region is
+// remembered so later requests go straight there; in follow mode the request
+// is retried against that region when its body has not been consumed.
+func (c *S3Client) followRedirect(ctx context.Context, req *S3Request, region string) (*S3Response, error) {
+	c.regions.learn(req.Bucket, region)
+
+	if c.cfg.Redirects == RedirectPassthrough {
+		redirectsTotal.Inc("passthrough")
+		return nil, errPermanentRedirect(req.Bucket, region)
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		// The body was streamed to the wrong region; the client's retry
+		// will land in the right one
+		redirectsTotal.Inc("unretryable")
+		return nil, errPermanentRedirect(req.Bucket, region)
+	}
+
+	redirectsTotal.Inc("followed")
+	return c.forward(ctx, req)
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// newRedirectingUpstream serves bucket "eu-data" only to requests signed for
+// eu-west-1 and redirects everything else there, like S3 does
+func newRedirectingUpstream(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/s3/") {
+			w.Header().Set("X-Amz-Bucket-Region", "eu-west-1")
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusMovedPermanently)
+			io.WriteString(w, `<Error><Code>PermanentRedirect</Code><Message>redirect</Message></Error>`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "hello")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestS3Client(t *testing.T, endpoint, redirects string) *S3Client {
+	cfg := &config.AWSConfig{
+		Region:          "us-east-1",
+		Endpoint:        endpoint,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		UsePathStyle:    true,
+		Redirects:       redirects,
+	}
+	client, err := NewS3Client(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewS3Client: %v", err)
+	}
+	return client
+}
+
+func TestS3Client_FollowsRegionRedirect(t *testing.T) {
+	var requests atomic.Int32
+	upstream := newRedirectingUpstream(t, &requests)
+	client := newTestS3Client(t, upstream.URL, RedirectFollow)
+
+	req := &S3Request{Bucket: "eu-data", Key: "a.txt", Action: "s3:GetObject", Operation: "GetObject", HTTPMethod: http.MethodGet, Headers: make(http.Header)}
+	resp, err := client.Forward(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want hello", body)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("upstream requests = %d, want 2", got)
+	}
+
+	// The region is remembered, so the next request goes straight there
+	resp, err = client.Forward(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	resp.Body.Close()
+	if got := requests.Load(); got != 3 {
+		t.Errorf("upstream requests = %d, want 3", got)
+	}
+	if got := client.RegionFor("eu-data"); got != "eu-west-1" {
+		t.Errorf("RegionFor = %q, want eu-west-1", got)
+	}
+}
+
+func TestS3Client_PassesRedirectThrough(t *testing.T) {
+	var requests atomic.Int32
+	upstream := newRedirectingUpstream(t, &requests)
+	client := newTestS3Client(t, upstream.URL, RedirectPassthrough)
+
+	req := &S3Request{Bucket: "eu-data", Key: "a.txt", Action: "s3:GetObject", Operation: "GetObject", HTTPMethod: http.MethodGet, Headers: make(http.Header)}
+	_, err := client.Forward(context.Background(), req)
+
+	var backendErr *BackendError
+	if !stderrors.As(err, &backendErr) {
+		t.Fatalf("expected BackendError, got %v", err)
+	}
+	if backendErr.StatusCode != http.StatusMovedPermanently || backendErr.Code != "PermanentRedirect" {
+		t.Errorf("got %d %s, want 301 PermanentRedirect", backendErr.StatusCode, backendErr.Code)
+	}
+	if got := backendErr.Headers.Get("X-Amz-Bucket-Region"); got != "eu-west-1" {
+		t.Errorf("x-amz-bucket-region = %q, want eu-west-1", got)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("upstream requests = %d, want 1", got)
+	}
+}
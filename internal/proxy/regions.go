@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// regionRouter maps buckets to regions and lazily creates one SDK client
+// per region, so buckets outside the default region are addressed directly
+// instead of failing with PermanentRedirect
+type regionRouter struct {
+	awsCfg        aws.Config
+	defaultRegion string
+	endpoint      string
+	usePathStyle  bool
+	routes        []config.RegionRoute
+
+	mu      sync.Mutex
+	clients map[string]*s3.Client
+	learned map[string]string // bucket -> region discovered from redirects
+}
+
+func newRegionRouter(awsCfg aws.Config, cfg *config.AWSConfig, defaultClient *s3.Client) *regionRouter {
+	return &regionRouter{
+		awsCfg:        awsCfg,
+		defaultRegion: cfg.Region,
+		endpoint:      cfg.Endpoint,
+		usePathStyle:  cfg.UsePathStyle,
+		routes:        cfg.RegionRoutes,
+		clients:       map[string]*s3.Client{cfg.Region: defaultClient},
+		learned:       make(map[string]string),
+	}
+}
+
+// regionFor returns the region learned for bucket from a redirect, else the
+// region of the first route whose bucket patterns match, else the default
+func (r *regionRouter) regionFor(bucket string) string {
+	r.mu.Lock()
+	region, ok := r.learned[bucket]
+	r.mu.Unlock()
+	if ok {
+		return region
+	}
+
+	for _, route := range r.routes {
+		if policy.MatchScope(bucket, route.Buckets) {
+			return route.Region
+		}
+	}
+	return r.defaultRegion
+}
+
+// learn records the region upstream reported for bucket
+func (r *regionRouter) learn(bucket, region string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.learned[bucket] = region
+}
+
+// client returns the client for a region, creating it on first use
+func (r *regionRouter) client(region string) *s3.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[region]
+	if !ok {
+		client = newSDKClient(r.awsCfg, region, r.endpoint, r.usePathStyle)
+		r.clients[region] = client
+	}
+	return client
+}
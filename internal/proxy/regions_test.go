@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestRegionRouter(t *testing.T) {
+	cfg := &config.AWSConfig{
+		Region: "us-east-1",
+		RegionRoutes: []config.RegionRoute{
+			{Buckets: []string{"eu-*"}, Region: "eu-west-1"},
+			{Buckets: []string{"eu-archive", "apac-*"}, Region: "ap-southeast-2"},
+		},
+	}
+	router := newRegionRouter(aws.Config{}, cfg, s3.New(s3.Options{Region: "us-east-1"}))
+
+	tests := []struct {
+		bucket string
+		want   string
+	}{
+		{"eu-data", "eu-west-1"},
+		{"eu-archive", "eu-west-1"}, // first match wins
+		{"apac-logs", "ap-southeast-2"},
+		{"us-data", "us-east-1"},
+	}
+	for _, tt := range tests {
+		if got := router.regionFor(tt.bucket); got != tt.want {
+			t.Errorf("regionFor(%q) = %q, want %q", tt.bucket, got, tt.want)
+		}
+	}
+
+	eu := router.client("eu-west-1")
+	if eu != router.client("eu-west-1") {
+		t.Error("expected regional client to be cached")
+	}
+	if got := eu.Options().Region; got != "eu-west-1" {
+		t.Errorf("regional client region = %q, want eu-west-1", got)
+	}
+	if router.client("us-east-1") == eu {
+		t.Error("expected default region to use the default client")
+	}
+}
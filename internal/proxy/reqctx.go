@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/redact"
+)
+
+// RequestContext describes a request as the gateway handles it. It is
+// created when the request arrives and filled in as the request is parsed
+// and authenticated, and carried by the request's context, so hooks,
+// backends and middleware can read it with RequestContextFrom.
+type RequestContext struct {
+	RequestID string
+	StartTime time.Time
+	Request   *http.Request     // the client's request, for reading only
+	S3        *S3Request        // nil until the request is parsed
+	Auth      *auth.AuthContext // nil until the request is authenticated
+
+	dlpFindings []string         // recorded in the audit entry
+	redactor    *redact.Redactor // of the gateway, for log lines
+}
+
+// ClientID returns the authenticated client, or "" before authentication
+func (rc *RequestContext) ClientID() string {
+	if rc.Auth == nil {
+		return ""
+	}
+	return rc.Auth.ClientID
+}
+
+// TenantID returns the authenticated client's tenant, or "" before
+// authentication
+func (rc *RequestContext) TenantID() string {
+	if rc.Auth == nil {
+		return ""
+	}
+	return rc.Auth.TenantID
+}
+
+// logKey returns the object key as log lines may show it
+func (rc *RequestContext) logKey() string {
+	if rc.S3 == nil {
+		return ""
+	}
+	return rc.redactor.Key(rc.S3.Bucket, rc.S3.Key)
+}
+
+// logResource returns the ARN of the request as log lines may show it
+func (rc *RequestContext) logResource() string {
+	if rc.S3 == nil {
+		return ""
+	}
+	return rc.redactor.Resource(rc.S3.ToARN())
+}
+
+type requestContextKey struct{}
+
+func withRequestContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, rc)
+}
+
+// RequestContextFrom returns the gateway request a context belongs to, or
+// nil outside request handling
+func RequestContextFrom(ctx context.Context) *RequestContext {
+	rc, _ := ctx.Value(requestContextKey{}).(*RequestContext)
+	return rc
+}
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -13,12 +14,14 @@ import (
 type S3Request struct {
 	Bucket        string
 	Key           string
-	Action        string
+	Action        string // policy action, e.g. "s3:ListBucket"; shared by several operations
+	Operation     string // S3 API operation, e.g. "ListObjectsV2"; backends dispatch on it
 	HTTPMethod    string
 	Headers       http.Header
 	Body          io.ReadCloser
 	QueryParams   url.Values
 	ContentLength int64
+	Alias         string // alias the client addressed instead of Bucket, if any
 }
 
 // ToARN returns the S3 resource ARN for this request
@@ -27,9 +30,19 @@ func (r *S3Request) ToARN() string {
 }
 
 // ParseS3Request parses an HTTP request into an S3Request
-// Supports path-style URLs: /bucket/key
+// Supports path-style URLs: /bucket/key. A query that does not parse is an
+// error, as dropping the malformed parameters could change the action.
+// Action and Operation are empty for requests that are not a known S3
+// operation.
 func ParseS3Request(req *http.Request) (*S3Request, error) {
 	bucket, key := parsePath(req.URL.Path)
+	if b := directoryBucketHost(req.Host); b != "" {
+		bucket, key = b, strings.TrimPrefix(req.URL.Path, "/")
+	}
+	query, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query string: %w", err)
+	}
 
 	s3req := &S3Request{
 		Bucket:        bucket,
@@ -37,11 +50,13 @@ func ParseS3Request(req *http.Request) (*S3Request, error) {
 		HTTPMethod:    req.Method,
 		Headers:       req.Header.Clone(),
 		Body:          req.Body,
-		QueryParams:   req.URL.Query(),
+		QueryParams:   query,
 		ContentLength: req.ContentLength,
 	}
 
-	s3req.Action = determineAction(req.Method, bucket, key, req.URL.Query())
+	if op, ok := classify(req.Method, key, query, req.Header); ok {
+		s3req.Operation, s3req.Action = op.name, op.action
+	}
 
 	return s3req, nil
 }
@@ -65,140 +80,3 @@ func parsePath(path string) (bucket, key string) {
 
 	return bucket, key
 }
-
-// determineAction maps HTTP method and query params to S3 action
-func determineAction(method, bucket, key string, query url.Values) string {
-	// Check for specific query parameters that indicate special operations
-	if query.Has("acl") {
-		if method == http.MethodGet {
-			if key == "" {
-				return "s3:GetBucketAcl"
-			}
-			return "s3:GetObjectAcl"
-		}
-		if method == http.MethodPut {
-			if key == "" {
-				return "s3:PutBucketAcl"
-			}
-			return "s3:PutObjectAcl"
-		}
-	}
-
-	if query.Has("versioning") {
-		if method == http.MethodGet {
-			return "s3:GetBucketVersioning"
-		}
-		if method == http.MethodPut {
-			return "s3:PutBucketVersioning"
-		}
-	}
-
-	if query.Has("lifecycle") {
-		if method == http.MethodGet {
-			return "s3:GetLifecycleConfiguration"
-		}
-		if method == http.MethodPut {
-			return "s3:PutLifecycleConfiguration"
-		}
-		if method == http.MethodDelete {
-			return "s3:DeleteLifecycleConfiguration"
-		}
-	}
-
-	if query.Has("policy") {
-		if method == http.MethodGet {
-			return "s3:GetBucketPolicy"
-		}
-		if method == http.MethodPut {
-			return "s3:PutBucketPolicy"
-		}
-		if method == http.MethodDelete {
-			return "s3:DeleteBucketPolicy"
-		}
-	}
-
-	if query.Has("tagging") {
-		if method == http.MethodGet {
-			if key == "" {
-				return "s3:GetBucketTagging"
-			}
-			return "s3:GetObjectTagging"
-		}
-		if method == http.MethodPut {
-			if key == "" {
-				return "s3:PutBucketTagging"
-			}
-			return "s3:PutObjectTagging"
-		}
-		if method == http.MethodDelete {
-			if key == "" {
-				return "s3:DeleteBucketTagging"
-			}
-			return "s3:DeleteObjectTagging"
-		}
-	}
-
-	if query.Has("uploads") {
-		if method == http.MethodPost {
-			return "s3:PutObject" // Initiate multipart upload
-		}
-		if method == http.MethodGet {
-			return "s3:ListBucketMultipartUploads"
-		}
-	}
-
-	if query.Has("uploadId") {
-		if method == http.MethodPut {
-			return "s3:PutObject" // Upload part
-		}
-		if method == http.MethodPost {
-			return "s3:PutObject" // Complete multipart upload
-		}
-		if method == http.MethodDelete {
-			return "s3:AbortMultipartUpload"
-		}
-		if method == http.MethodGet {
-			return "s3:ListMultipartUploadParts"
-		}
-	}
-
-	// Check for list operations (bucket level with no key)
-	if key == "" {
-		if method == http.MethodGet {
-			if query.Has("list-type") || query.Has("prefix") || query.Has("delimiter") {
-				return "s3:ListBucket"
-			}
-			// Plain GET on bucket is also ListBucket
-			return "s3:ListBucket"
-		}
-		if method == http.MethodHead {
-			return "s3:ListBucket"
-		}
-		if method == http.MethodPut {
-			return "s3:CreateBucket"
-		}
-		if method == http.MethodDelete {
-			return "s3:DeleteBucket"
-		}
-	}
-
-	// Object-level operations
-	switch method {
-	case http.MethodGet:
-		return "s3:GetObject"
-	case http.MethodHead:
-		return "s3:GetObject"
-	case http.MethodPut:
-		// Check for copy operation
-		if _, ok := query["copy"]; ok {
-			return "s3:PutObject"
-		}
-		return "s3:PutObject"
-	case http.MethodPost:
-		return "s3:PutObject"
-	case http.MethodDelete:
-		return "s3:DeleteObject"
-	default:
-		return "s3:Unknown"
-	}
-}
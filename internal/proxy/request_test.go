@@ -2,8 +2,14 @@ package proxy
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/s3-access-control-adapter/internal/policy"
 )
 
 func TestParseS3Request(t *testing.T) {
@@ -133,6 +139,152 @@ func TestParseS3Request(t *testing.T) {
 	}
 }
 
+func TestParseS3Request_Operations(t *testing.T) {
+	tests := []struct {
+		method     string
+		path       string
+		query      string
+		copySource string
+		wantOp     string
+		wantAction string
+	}{
+		{"GET", "/b/k", "x-id=GetObject", "", "GetObject", "s3:GetObject"},
+		{"HEAD", "/b/k", "", "", "HeadObject", "s3:GetObject"},
+		{"PUT", "/b/k", "x-id=PutObject", "", "PutObject", "s3:PutObject"},
+		{"PUT", "/b/k", "", "src/key", "CopyObject", "s3:PutObject"},
+		{"PUT", "/b/k", "x-id=CopyObject", "src/key", "CopyObject", "s3:PutObject"},
+		{"PUT", "/b/k", "partNumber=2&uploadId=u&x-id=UploadPartCopy", "src/key", "UploadPartCopy", "s3:PutObject"},
+		{"POST", "/b/k", "uploads&x-id=CreateMultipartUpload", "", "CreateMultipartUpload", "s3:PutObject"},
+		{"POST", "/b/k", "uploadId=u&x-id=CompleteMultipartUpload", "", "CompleteMultipartUpload", "s3:PutObject"},
+		{"GET", "/b/k", "uploadId=u&x-id=ListParts", "", "ListParts", "s3:ListMultipartUploadParts"},
+		{"POST", "/b/k", "select&select-type=2&x-id=SelectObjectContent", "", "SelectObjectContent", "s3:GetObject"},
+		{"POST", "/b/k", "restore&x-id=RestoreObject", "", "RestoreObject", "s3:RestoreObject"},
+		{"GET", "/b/k", "retention", "", "GetObjectRetention", "s3:GetObjectRetention"},
+		{"POST", "/b", "delete&x-id=DeleteObjects", "", "DeleteObjects", "s3:DeleteObject"},
+		{"GET", "/b", "", "", "ListObjects", "s3:ListBucket"},
+		{"GET", "/b", "list-type=2&prefix=a", "", "ListObjectsV2", "s3:ListBucket"},
+		{"GET", "/b", "list-type=2&x-id=ListObjectsV2", "", "ListObjectsV2", "s3:ListBucket"},
+		{"GET", "/b", "versions", "", "ListObjectVersions", "s3:ListBucketVersions"},
+		{"HEAD", "/b", "", "", "HeadBucket", "s3:ListBucket"},
+		{"DELETE", "/b", "cors", "", "DeleteBucketCors", "s3:PutBucketCORS"},
+		{"GET", "/b", "analytics&id=a&x-id=GetBucketAnalyticsConfiguration", "", "GetBucketAnalyticsConfiguration", "s3:GetAnalyticsConfiguration"},
+		{"GET", "/b", "analytics", "", "ListBucketAnalyticsConfigurations", "s3:GetAnalyticsConfiguration"},
+		{"DELETE", "/b", "lifecycle", "", "DeleteBucketLifecycle", "s3:DeleteLifecycleConfiguration"},
+
+		// Not S3 operations the gateway knows
+		{"POST", "/b/k", "", "", "", ""},
+		{"POST", "/b", "", "", "", ""},
+		{"GET", "/b/k", "acl&x-id=GetObject", "", "", ""},
+		{"GET", "/b/k", "x-id=Bogus", "", "", ""},
+		{"DELETE", "/b/k", "restore", "", "", ""},
+		{"GET", "/b", "policyStatus&x-id=PutBucketPolicy", "", "", ""},
+
+		// x-id disagreeing with the copy source header or the listing version
+		{"PUT", "/b/k", "x-id=PutObject", "src/key", "", ""},
+		{"PUT", "/b/k", "x-id=CopyObject", "", "", ""},
+		{"PUT", "/b/k", "partNumber=2&uploadId=u&x-id=UploadPart", "src/key", "", ""},
+		{"PUT", "/b/k", "partNumber=2&uploadId=u&x-id=UploadPartCopy", "", "", ""},
+		{"GET", "/b", "list-type=2&x-id=ListObjects", "", "", ""},
+		{"GET", "/b", "x-id=ListObjectsV2", "", "", ""},
+		{"GET", "/b", "analytics&x-id=GetBucketAnalyticsConfiguration", "", "", ""},
+		{"PATCH", "/b/k", "", "", "", ""},
+		{"OPTIONS", "/b/k", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		req := &http.Request{Method: tt.method, URL: &url.URL{Path: tt.path, RawQuery: tt.query}, Header: make(http.Header)}
+		if tt.copySource != "" {
+			req.Header.Set("X-Amz-Copy-Source", tt.copySource)
+		}
+		s3req, err := ParseS3Request(req)
+		if err != nil {
+			t.Fatalf("%s %s?%s: %v", tt.method, tt.path, tt.query, err)
+		}
+		if s3req.Operation != tt.wantOp || s3req.Action != tt.wantAction {
+			t.Errorf("%s %s?%s: operation %q, action %q; want %q, %q",
+				tt.method, tt.path, tt.query, s3req.Operation, s3req.Action, tt.wantOp, tt.wantAction)
+		}
+	}
+}
+
+func TestParseS3Request_DirectoryBucketHost(t *testing.T) {
+	tests := []struct {
+		host, path string
+		wantBucket string
+		wantKey    string
+	}{
+		{"logs--usw2-az1--x-s3.gateway.internal:8080", "/2024/app.log", "logs--usw2-az1--x-s3", "2024/app.log"},
+		{"logs--usw2-az1--x-s3.gateway.internal", "/", "logs--usw2-az1--x-s3", ""},
+		{"gateway.internal", "/logs--usw2-az1--x-s3/app.log", "logs--usw2-az1--x-s3", "app.log"},
+		{"data.gateway.internal", "/bucket/key", "bucket", "key"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+tt.path, nil)
+		s3req, err := ParseS3Request(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s3req.Bucket != tt.wantBucket || s3req.Key != tt.wantKey {
+			t.Errorf("%s%s: bucket = %q, key = %q, want %q, %q", tt.host, tt.path, s3req.Bucket, s3req.Key, tt.wantBucket, tt.wantKey)
+		}
+	}
+}
+
+func TestParseS3Request_InvalidQuery(t *testing.T) {
+	u, _ := url.Parse("http://localhost/mybucket/file.txt")
+	u.RawQuery = "acl;x=1"
+	if _, err := ParseS3Request(&http.Request{Method: "GET", URL: u, Header: make(http.Header)}); err == nil {
+		t.Error("ParseS3Request() error = nil, want error for malformed query")
+	}
+}
+
+// FuzzParseS3Request checks that any request either fails to parse or
+// yields the bucket and key of its path and, if any, an action the gateway
+// knows.
+// Seeds follow the requests of the AWS S3 SDK.
+func FuzzParseS3Request(f *testing.F) {
+	f.Add("GET", "/mybucket/reports/2024/q1.csv", "x-id=GetObject")
+	f.Add("PUT", "/mybucket/uploads/photo (1).jpg", "x-id=PutObject")
+	f.Add("HEAD", "/mybucket/file.txt", "")
+	f.Add("GET", "/mybucket", "list-type=2&prefix=logs%2F&delimiter=%2F&encoding-type=url")
+	f.Add("POST", "/mybucket/big.bin", "uploads=&x-id=CreateMultipartUpload")
+	f.Add("PUT", "/mybucket/big.bin", "partNumber=1&uploadId=abc&x-id=UploadPart")
+	f.Add("POST", "/mybucket/big.bin", "uploadId=abc&x-id=CompleteMultipartUpload")
+	f.Add("DELETE", "/mybucket/big.bin", "uploadId=abc&x-id=AbortMultipartUpload")
+	f.Add("PUT", "/mybucket/file.txt", "tagging=&x-id=PutObjectTagging")
+	f.Add("GET", "/mybucket", "acl=")
+	f.Add("POST", "/mybucket", "delete=&x-id=DeleteObjects")
+	f.Add("GET", "//file.txt", "acl;x=1")
+
+	f.Fuzz(func(t *testing.T, method, path, rawQuery string) {
+		req := &http.Request{Method: method, URL: &url.URL{Path: path, RawQuery: rawQuery}, Header: make(http.Header)}
+		s3req, err := ParseS3Request(req)
+		query, queryErr := url.ParseQuery(rawQuery)
+		if (err != nil) != (queryErr != nil) {
+			t.Fatalf("%s %s?%s: error = %v, query error = %v", method, path, rawQuery, err, queryErr)
+		}
+		if err != nil {
+			return
+		}
+
+		if strings.Contains(s3req.Bucket, "/") {
+			t.Errorf("%s: bucket %q contains a slash", path, s3req.Bucket)
+		}
+		trimmed := strings.TrimPrefix(path, "/")
+		if trimmed != s3req.Bucket && trimmed != s3req.Bucket+"/"+s3req.Key {
+			t.Errorf("%s: parsed as bucket %q, key %q", path, s3req.Bucket, s3req.Key)
+		}
+		if s3req.Action != "" && !slices.Contains(policy.KnownActions, s3req.Action) {
+			t.Errorf("%s %s?%s: unknown action %q", method, path, rawQuery, s3req.Action)
+		}
+		if (s3req.Operation == "") != (s3req.Action == "") {
+			t.Errorf("%s %s?%s: operation %q, action %q", method, path, rawQuery, s3req.Operation, s3req.Action)
+		}
+		if !reflect.DeepEqual(s3req.QueryParams, query) {
+			t.Errorf("%s: query params %v, want %v", rawQuery, s3req.QueryParams, query)
+		}
+	})
+}
+
 func TestS3Request_ToARN(t *testing.T) {
 	tests := []struct {
 		bucket  string
@@ -0,0 +1,58 @@
+package proxy
+
+import "github.com/s3-access-control-adapter/internal/policy"
+
+// Route is where a request is forwarded. Empty fields are unknown.
+type Route struct {
+	Region   string
+	Endpoint string
+}
+
+// Router is implemented by backends that can report where a request would be
+// forwarded, exposed to policies as aws:RequestedRegion and gateway:Endpoint
+type Router interface {
+	Route(req *S3Request) Route
+}
+
+// Route returns the region and endpoint of the client Forward would use,
+// including the failover replica for reads while the primary is unhealthy
+func (c *S3Client) Route(req *S3Request) Route {
+	region := c.regions.regionFor(req.Bucket)
+	endpoint := c.cfg.Endpoint
+	switch req.Operation {
+	case "GetObject", "HeadObject", "ListObjects", "ListObjectsV2", "HeadBucket":
+		if _, primary := c.readClient(req.Bucket); !primary && region == c.cfg.Region && c.secondary != nil {
+			region, endpoint = c.cfg.Failover.Region, c.cfg.Failover.Endpoint
+			if region == "" {
+				region = c.cfg.Region
+			}
+		}
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return Route{Region: region, Endpoint: endpoint}
+}
+
+// Route returns the storage account endpoint; Azure regions are not known
+func (b *AzureBlobBackend) Route(req *S3Request) Route {
+	return Route{Endpoint: b.endpoint.String()}
+}
+
+// addRouteConditions sets aws:RequestedRegion and gateway:Endpoint from the
+// backend's route. Values set by hooks are never used.
+func (g *Gateway) addRouteConditions(evalCtx *policy.EvalContext, req *S3Request) {
+	delete(evalCtx.Conditions, policy.RequestedRegionKey)
+	delete(evalCtx.Conditions, policy.EndpointKey)
+	router, ok := g.backend.(Router)
+	if !ok {
+		return
+	}
+	route := router.Route(req)
+	if route.Region != "" {
+		evalCtx.Conditions[policy.RequestedRegionKey] = route.Region
+	}
+	if route.Endpoint != "" {
+		evalCtx.Conditions[policy.EndpointKey] = route.Endpoint
+	}
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestS3Client_Route(t *testing.T) {
+	cfg := &config.AWSConfig{
+		Region:       "us-east-1",
+		RegionRoutes: []config.RegionRoute{{Buckets: []string{"eu-*"}, Region: "eu-west-1"}},
+		Failover:     config.FailoverConfig{Enabled: true, Endpoint: "http://replica:9000", FailureThreshold: 1},
+	}
+	primary := s3.New(s3.Options{Region: "us-east-1"})
+	c := &S3Client{
+		client:    primary,
+		cfg:       cfg,
+		secondary: s3.New(s3.Options{Region: "us-east-1"}),
+		health:    newEndpointHealth(1),
+		regions:   newRegionRouter(aws.Config{}, cfg, primary),
+	}
+
+	tests := []struct {
+		operation, bucket string
+		failedOver        bool
+		want              Route
+	}{
+		{"GetObject", "us-data", false, Route{"us-east-1", "https://s3.us-east-1.amazonaws.com"}},
+		{"PutObject", "eu-data", false, Route{"eu-west-1", "https://s3.eu-west-1.amazonaws.com"}},
+		{"GetObject", "us-data", true, Route{"us-east-1", "http://replica:9000"}},
+		{"PutObject", "us-data", true, Route{"us-east-1", "https://s3.us-east-1.amazonaws.com"}},
+		{"GetObject", "eu-data", true, Route{"eu-west-1", "https://s3.eu-west-1.amazonaws.com"}},
+	}
+	for _, tt := range tests {
+		if tt.failedOver {
+			c.health.recordPrimary(&statusError{500})
+		} else {
+			c.health.restore()
+		}
+		if got := c.Route(&S3Request{Operation: tt.operation, Bucket: tt.bucket}); got != tt.want {
+			t.Errorf("%s %s (failed over %v) = %+v, want %+v", tt.operation, tt.bucket, tt.failedOver, got, tt.want)
+		}
+	}
+}
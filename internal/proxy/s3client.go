@@ -2,6 +2,8 @@ package proxy
 
 import (
 	"context"
+	"encoding/xml"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,7 +12,11 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/internal/reaper"
 )
 
 // S3Response represents the response from S3
@@ -25,12 +31,64 @@ type S3Response struct {
 type S3Client struct {
 	client *s3.Client
 	cfg    *config.AWSConfig
+
+	// secondary serves reads while the primary is unhealthy (optional)
+	secondary *s3.Client
+	health    *endpointHealth
+
+	regions *regionRouter
+	headers headerFilter
 }
 
 // NewS3Client creates a new S3 client
 func NewS3Client(ctx context.Context, cfg *config.AWSConfig) (*S3Client, error) {
+	awsCfg, err := loadAWSConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &S3Client{
+		client:  newSDKClient(awsCfg, cfg.Region, cfg.Endpoint, cfg.UsePathStyle),
+		cfg:     cfg,
+		headers: newHeaderFilter(cfg.StripResponseHeaders),
+	}
+
+	c.regions = newRegionRouter(awsCfg, cfg, c.client)
+
+	if cfg.Failover.Enabled {
+		region := cfg.Failover.Region
+		if region == "" {
+			region = cfg.Region
+		}
+		c.secondary = newSDKClient(awsCfg, region, cfg.Failover.Endpoint, cfg.UsePathStyle)
+		c.health = newEndpointHealth(cfg.Failover.FailureThreshold)
+		go c.probePrimary(ctx, cfg.Failover.ProbeInterval, cfg.Failover.ProbeBucket)
+	}
+
+	return c, nil
+}
+
+// NewSDKClient creates a plain SDK client from the aws section, for reads the
+// gateway makes on its own behalf such as fetching remote policies
+func NewSDKClient(ctx context.Context, cfg *config.AWSConfig) (*s3.Client, error) {
+	awsCfg, err := loadAWSConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newSDKClient(awsCfg, cfg.Region, cfg.Endpoint, cfg.UsePathStyle), nil
+}
+
+// loadAWSConfig loads SDK configuration using the configured region, HTTP
+// client settings and static credentials, if any
+func loadAWSConfig(ctx context.Context, cfg *config.AWSConfig) (aws.Config, error) {
+	httpClient, err := newSDKHTTPClient(&cfg.HTTPClient)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to build HTTP client: %w", err)
+	}
+
 	opts := []func(*awsconfig.LoadOptions) error{
 		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithHTTPClient(httpClient),
 	}
 
 	// Use static credentials if provided
@@ -42,46 +100,116 @@ func NewS3Client(ctx context.Context, cfg *config.AWSConfig) (*S3Client, error)
 
 	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
 	}
+	return awsCfg, nil
+}
+
+// newSDKClient creates an SDK client for a region and optional custom endpoint
+func newSDKClient(awsCfg aws.Config, region, endpoint string, usePathStyle bool) *s3.Client {
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.Region = region
+		o.APIOptions = append(o.APIOptions, addCorrelationHeaders)
+		// Custom endpoint for LocalStack or other S3-compatible services
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = usePathStyle
+		}
+	})
+}
 
-	s3Opts := []func(*s3.Options){}
+// Forward forwards an S3 request and returns the response
+func (c *S3Client) Forward(parent context.Context, req *S3Request) (*S3Response, error) {
+	ctx, cancel := withActionTimeout(parent, &c.cfg.Timeouts, req)
 
-	// Custom endpoint for LocalStack or other S3-compatible services
-	if cfg.Endpoint != "" {
-		s3Opts = append(s3Opts, func(o *s3.Options) {
-			o.BaseEndpoint = aws.String(cfg.Endpoint)
-			o.UsePathStyle = cfg.UsePathStyle
-		})
+	resp, err := c.forward(ctx, req)
+	if region := redirectRegion(err); region != "" {
+		resp, err = c.followRedirect(ctx, req, region)
+	}
+	if isOwnTimeout(ctx, parent, err) {
+		err = errUpstreamTimeout(req)
+	}
+	if err != nil || resp.Body == nil {
+		cancel()
+		return resp, err
 	}
 
-	client := s3.NewFromConfig(awsCfg, s3Opts...)
-
-	return &S3Client{
-		client: client,
-		cfg:    cfg,
-	}, nil
+	// The deadline also bounds streaming the body to the client
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
 }
 
-// Forward forwards an S3 request and returns the response
-func (c *S3Client) Forward(ctx context.Context, req *S3Request) (*S3Response, error) {
-	switch req.Action {
-	case "s3:GetObject":
-		return c.getObject(ctx, req)
-	case "s3:PutObject":
-		return c.putObject(ctx, req)
-	case "s3:DeleteObject":
-		return c.deleteObject(ctx, req)
-	case "s3:ListBucket":
-		return c.listObjects(ctx, req)
-	case "s3:HeadObject":
-		return c.headObject(ctx, req)
+func (c *S3Client) forward(ctx context.Context, req *S3Request) (*S3Response, error) {
+	switch req.Operation {
+	case "GetObject":
+		return c.read(ctx, req, c.getObject)
+	case "PutObject":
+		return c.write(ctx, req, c.putObject)
+	case "DeleteObject":
+		return c.write(ctx, req, c.deleteObject)
+	case "ListObjects", "ListObjectsV2":
+		return c.read(ctx, req, c.listObjects)
+	case "HeadObject":
+		return c.read(ctx, req, c.headObject)
+	case "HeadBucket":
+		return c.read(ctx, req, c.headBucket)
+	case "CreateBucket":
+		return c.write(ctx, req, c.createBucket)
+	case "DeleteBucket":
+		return c.write(ctx, req, c.deleteBucket)
 	default:
-		return nil, fmt.Errorf("unsupported action: %s", req.Action)
+		return nil, errNotImplemented(req.Operation)
 	}
 }
 
-func (c *S3Client) getObject(ctx context.Context, req *S3Request) (*S3Response, error) {
+// opFunc performs an operation against a specific upstream client
+type opFunc func(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error)
+
+// RegionFor returns the region requests for bucket are routed to
+func (c *S3Client) RegionFor(bucket string) string {
+	return c.regions.regionFor(bucket)
+}
+
+// write performs a mutating operation against the bucket's region
+func (c *S3Client) write(ctx context.Context, req *S3Request, fn opFunc) (*S3Response, error) {
+	return fn(ctx, c.regions.client(c.regions.regionFor(req.Bucket)), req)
+}
+
+// read performs an idempotent read against the current read endpoint,
+// hedging object reads when configured
+func (c *S3Client) read(ctx context.Context, req *S3Request, fn opFunc) (*S3Response, error) {
+	client, primary := c.readClient(req.Bucket)
+	call := func(ctx context.Context) (*S3Response, error) {
+		return fn(ctx, client, req)
+	}
+
+	var resp *S3Response
+	var err error
+	if c.cfg.Hedge.Enabled && (req.Operation == "GetObject" || req.Operation == "HeadObject") {
+		resp, err = hedged(ctx, req.Action, c.cfg.Hedge.Delay, call)
+	} else {
+		resp, err = call(ctx)
+	}
+
+	if primary && c.health != nil {
+		c.health.recordPrimary(err)
+	}
+	return resp, err
+}
+
+// readClient returns the client reads of bucket should use and whether it is
+// the primary. Failover only applies to buckets in the default region.
+func (c *S3Client) readClient(bucket string) (*s3.Client, bool) {
+	if region := c.regions.regionFor(bucket); region != c.cfg.Region {
+		return c.regions.client(region), false
+	}
+	if c.secondary != nil && c.health.useSecondary() {
+		return c.secondary, false
+	}
+	return c.client, true
+}
+
+func (c *S3Client) getObject(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(req.Bucket),
 		Key:    aws.String(req.Key),
@@ -98,45 +226,25 @@ func (c *S3Client) getObject(ctx context.Context, req *S3Request) (*S3Response,
 		input.IfNoneMatch = aws.String(v)
 	}
 
-	output, err := c.client.GetObject(ctx, input)
+	output, err := client.GetObject(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
-	headers := make(http.Header)
-	if output.ContentType != nil {
-		headers.Set("Content-Type", *output.ContentType)
-	}
-	if output.ContentLength != nil {
-		headers.Set("Content-Length", fmt.Sprintf("%d", *output.ContentLength))
-	}
-	if output.ETag != nil {
-		headers.Set("ETag", *output.ETag)
-	}
-	if output.LastModified != nil {
-		headers.Set("Last-Modified", output.LastModified.Format(http.TimeFormat))
-	}
-	if output.ContentEncoding != nil {
-		headers.Set("Content-Encoding", *output.ContentEncoding)
-	}
-	if output.CacheControl != nil {
-		headers.Set("Cache-Control", *output.CacheControl)
-	}
-
 	contentLength := int64(0)
 	if output.ContentLength != nil {
 		contentLength = *output.ContentLength
 	}
 
 	return &S3Response{
-		StatusCode:    http.StatusOK,
-		Headers:       headers,
+		StatusCode:    rawStatusCode(output.ResultMetadata, http.StatusOK),
+		Headers:       c.headers.responseHeaders(output.ResultMetadata),
 		Body:          output.Body,
 		ContentLength: contentLength,
 	}, nil
 }
 
-func (c *S3Client) putObject(ctx context.Context, req *S3Request) (*S3Response, error) {
+func (c *S3Client) putObject(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error) {
 	input := &s3.PutObjectInput{
 		Bucket: aws.String(req.Bucket),
 		Key:    aws.String(req.Key),
@@ -155,41 +263,42 @@ func (c *S3Client) putObject(ctx context.Context, req *S3Request) (*S3Response,
 	if v := req.Headers.Get("Cache-Control"); v != "" {
 		input.CacheControl = aws.String(v)
 	}
+	if v := req.Headers.Get("x-amz-storage-class"); v != "" {
+		input.StorageClass = types.StorageClass(v)
+	}
+	if v := req.Headers.Get("x-amz-tagging"); v != "" {
+		input.Tagging = aws.String(v)
+	}
 
-	output, err := c.client.PutObject(ctx, input)
+	output, err := client.PutObject(ctx, input, withConditionalWrite(req))
 	if err != nil {
 		return nil, err
 	}
 
-	headers := make(http.Header)
-	if output.ETag != nil {
-		headers.Set("ETag", *output.ETag)
-	}
-
 	return &S3Response{
 		StatusCode: http.StatusOK,
-		Headers:    headers,
+		Headers:    c.headers.responseHeaders(output.ResultMetadata),
 	}, nil
 }
 
-func (c *S3Client) deleteObject(ctx context.Context, req *S3Request) (*S3Response, error) {
+func (c *S3Client) deleteObject(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error) {
 	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(req.Bucket),
 		Key:    aws.String(req.Key),
 	}
 
-	_, err := c.client.DeleteObject(ctx, input)
+	output, err := client.DeleteObject(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
 	return &S3Response{
 		StatusCode: http.StatusNoContent,
-		Headers:    make(http.Header),
+		Headers:    c.headers.responseHeaders(output.ResultMetadata),
 	}, nil
 }
 
-func (c *S3Client) listObjects(ctx context.Context, req *S3Request) (*S3Response, error) {
+func (c *S3Client) listObjects(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error) {
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(req.Bucket),
 	}
@@ -200,133 +309,239 @@ func (c *S3Client) listObjects(ctx context.Context, req *S3Request) (*S3Response
 	if delimiter := req.QueryParams.Get("delimiter"); delimiter != "" {
 		input.Delimiter = aws.String(delimiter)
 	}
-	if maxKeys := req.QueryParams.Get("max-keys"); maxKeys != "" {
-		var mk int32
-		fmt.Sscanf(maxKeys, "%d", &mk)
-		input.MaxKeys = aws.Int32(mk)
+	if v := req.QueryParams.Get("max-keys"); v != "" {
+		maxKeys, err := parseMaxKeys(v)
+		if err != nil {
+			return nil, err
+		}
+		input.MaxKeys = aws.Int32(maxKeys)
 	}
 	if continuationToken := req.QueryParams.Get("continuation-token"); continuationToken != "" {
 		input.ContinuationToken = aws.String(continuationToken)
 	}
+	if startAfter := req.QueryParams.Get("start-after"); startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+	if req.QueryParams.Get("fetch-owner") == "true" {
+		input.FetchOwner = aws.Bool(true)
+	}
 
-	output, err := c.client.ListObjectsV2(ctx, input)
+	output, err := client.ListObjectsV2(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to XML response
-	body := buildListObjectsXML(req.Bucket, output)
-
-	headers := make(http.Header)
-	headers.Set("Content-Type", "application/xml")
-
-	return &S3Response{
-		StatusCode:    http.StatusOK,
-		Headers:       headers,
-		Body:          io.NopCloser(body),
-		ContentLength: int64(body.Len()),
-	}, nil
+	return listResponse(req, output)
 }
 
-func (c *S3Client) headObject(ctx context.Context, req *S3Request) (*S3Response, error) {
+func (c *S3Client) headObject(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error) {
 	input := &s3.HeadObjectInput{
 		Bucket: aws.String(req.Bucket),
 		Key:    aws.String(req.Key),
 	}
 
-	output, err := c.client.HeadObject(ctx, input)
+	output, err := client.HeadObject(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
-	headers := make(http.Header)
-	if output.ContentType != nil {
-		headers.Set("Content-Type", *output.ContentType)
+	return &S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    c.headers.responseHeaders(output.ResultMetadata),
+	}, nil
+}
+
+func (c *S3Client) headBucket(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error) {
+	output, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(req.Bucket)})
+	if err != nil {
+		return nil, err
 	}
-	if output.ContentLength != nil {
-		headers.Set("Content-Length", fmt.Sprintf("%d", *output.ContentLength))
+
+	return &S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    c.headers.responseHeaders(output.ResultMetadata),
+	}, nil
+}
+
+// maxCreateBucketBody bounds the CreateBucketConfiguration document
+const maxCreateBucketBody = 64 << 10
+
+// createBucketConfiguration is the optional CreateBucket request body
+type createBucketConfiguration struct {
+	LocationConstraint string `xml:"LocationConstraint"`
+	// Directory buckets name their availability zone instead
+	Location struct {
+		Type string `xml:"Type"`
+		Name string `xml:"Name"`
+	} `xml:"Location"`
+	Bucket struct {
+		Type           string `xml:"Type"`
+		DataRedundancy string `xml:"DataRedundancy"`
+	} `xml:"Bucket"`
+}
+
+func (c *S3Client) createBucket(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error) {
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(req.Bucket),
+	}
+
+	// Buckets are created in the region the gateway routes them to; a
+	// location constraint naming another region would strand the bucket
+	region := c.regions.regionFor(req.Bucket)
+	var conf createBucketConfiguration
+	if req.Body != nil {
+		if err := xml.NewDecoder(io.LimitReader(req.Body, maxCreateBucketBody)).Decode(&conf); err != nil && err != io.EOF {
+			return nil, backendError(errors.ErrMalformedXML)
+		}
+		if conf.LocationConstraint != "" && conf.LocationConstraint != region {
+			return nil, errInvalidArgument(fmt.Sprintf("Buckets named %s are created in %s", req.Bucket, region))
+		}
+	}
+	if isDirectoryBucket(req.Bucket) {
+		// The zone is part of the name; the bucket's region must contain it
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			Location: &types.LocationInfo{
+				Type: types.LocationType(conf.Location.Type),
+				Name: aws.String(conf.Location.Name),
+			},
+			Bucket: &types.BucketInfo{
+				Type:           types.BucketType(conf.Bucket.Type),
+				DataRedundancy: types.DataRedundancy(conf.Bucket.DataRedundancy),
+			},
+		}
+	} else if region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	if v := req.Headers.Get("x-amz-acl"); v != "" {
+		input.ACL = types.BucketCannedACL(v)
 	}
-	if output.ETag != nil {
-		headers.Set("ETag", *output.ETag)
+	if req.Headers.Get("x-amz-bucket-object-lock-enabled") == "true" {
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
 	}
-	if output.LastModified != nil {
-		headers.Set("Last-Modified", output.LastModified.Format(http.TimeFormat))
+
+	output, err := client.CreateBucket(ctx, input)
+	if err != nil {
+		return nil, err
 	}
 
+	headers := c.headers.responseHeaders(output.ResultMetadata)
+	if output.Location != nil {
+		headers.Set("Location", *output.Location)
+	}
 	return &S3Response{
 		StatusCode: http.StatusOK,
 		Headers:    headers,
 	}, nil
 }
 
-// buildListObjectsXML builds the XML response for ListObjectsV2
-func buildListObjectsXML(bucket string, output *s3.ListObjectsV2Output) *stringBuffer {
-	buf := &stringBuffer{}
-	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
-	buf.WriteString(`<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
-	buf.WriteString(fmt.Sprintf("<Name>%s</Name>", bucket))
-
-	if output.Prefix != nil {
-		buf.WriteString(fmt.Sprintf("<Prefix>%s</Prefix>", *output.Prefix))
-	} else {
-		buf.WriteString("<Prefix></Prefix>")
+func (c *S3Client) deleteBucket(ctx context.Context, client *s3.Client, req *S3Request) (*S3Response, error) {
+	input := &s3.DeleteBucketInput{
+		Bucket: aws.String(req.Bucket),
 	}
 
-	if output.MaxKeys != nil {
-		buf.WriteString(fmt.Sprintf("<MaxKeys>%d</MaxKeys>", *output.MaxKeys))
+	output, err := client.DeleteBucket(ctx, input)
+	if err != nil {
+		return nil, err
 	}
 
-	buf.WriteString(fmt.Sprintf("<IsTruncated>%t</IsTruncated>", output.IsTruncated != nil && *output.IsTruncated))
+	return &S3Response{
+		StatusCode: http.StatusNoContent,
+		Headers:    c.headers.responseHeaders(output.ResultMetadata),
+	}, nil
+}
 
-	for _, obj := range output.Contents {
-		buf.WriteString("<Contents>")
-		if obj.Key != nil {
-			buf.WriteString(fmt.Sprintf("<Key>%s</Key>", *obj.Key))
-		}
-		if obj.LastModified != nil {
-			buf.WriteString(fmt.Sprintf("<LastModified>%s</LastModified>", obj.LastModified.Format("2006-01-02T15:04:05.000Z")))
-		}
-		if obj.ETag != nil {
-			buf.WriteString(fmt.Sprintf("<ETag>%s</ETag>", *obj.ETag))
-		}
-		if obj.Size != nil {
-			buf.WriteString(fmt.Sprintf("<Size>%d</Size>", *obj.Size))
-		}
-		buf.WriteString("<StorageClass>STANDARD</StorageClass>")
-		buf.WriteString("</Contents>")
+// BucketTags returns a bucket's tags for s3:ResourceTag conditions
+func (c *S3Client) BucketTags(ctx context.Context, bucket string) (map[string]string, error) {
+	client := c.regions.client(c.regions.regionFor(bucket))
+	output, err := client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucket)})
+	var apiErr smithy.APIError
+	if stderrors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchTagSet" {
+		return map[string]string{}, nil
 	}
-
-	for _, prefix := range output.CommonPrefixes {
-		buf.WriteString("<CommonPrefixes>")
-		if prefix.Prefix != nil {
-			buf.WriteString(fmt.Sprintf("<Prefix>%s</Prefix>", *prefix.Prefix))
-		}
-		buf.WriteString("</CommonPrefixes>")
+	if err != nil {
+		return nil, err
 	}
 
-	buf.WriteString("</ListBucketResult>")
-	return buf
+	tags := make(map[string]string, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
 }
 
-// stringBuffer is a simple string buffer that implements io.Reader
-type stringBuffer struct {
-	data   []byte
-	offset int
+// ObjectTags returns an object's tags for s3:ExistingObjectTag conditions.
+// A missing object has none, so the request is decided without them and
+// the backend reports NoSuchKey if it is allowed.
+func (c *S3Client) ObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	client := c.regions.client(c.regions.regionFor(bucket))
+	output, err := client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	var apiErr smithy.APIError
+	if stderrors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey" {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
 }
 
-func (b *stringBuffer) WriteString(s string) {
-	b.data = append(b.data, []byte(s)...)
+// Buckets returns the names of the buckets owned by the gateway's AWS
+// credentials
+func (c *S3Client) Buckets(ctx context.Context) ([]string, error) {
+	output, err := c.client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+	buckets := make([]string, 0, len(output.Buckets))
+	for _, b := range output.Buckets {
+		buckets = append(buckets, aws.ToString(b.Name))
+	}
+	return buckets, nil
 }
 
-func (b *stringBuffer) Read(p []byte) (n int, err error) {
-	if b.offset >= len(b.data) {
-		return 0, io.EOF
+// MultipartUploads returns the in-progress multipart uploads of a bucket
+func (c *S3Client) MultipartUploads(ctx context.Context, bucket string) ([]reaper.Upload, error) {
+	client := c.regions.client(c.regions.regionFor(bucket))
+	var uploads []reaper.Upload
+	paginator := s3.NewListMultipartUploadsPaginator(client, &s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range page.Uploads {
+			uploads = append(uploads, reaper.Upload{
+				Key:       aws.ToString(u.Key),
+				UploadID:  aws.ToString(u.UploadId),
+				Initiated: aws.ToTime(u.Initiated),
+			})
+		}
 	}
-	n = copy(p, b.data[b.offset:])
-	b.offset += n
-	return n, nil
+	return uploads, nil
 }
 
-func (b *stringBuffer) Len() int {
-	return len(b.data)
+// AbortMultipartUpload aborts an upload, discarding its parts. An upload
+// already completed or aborted, e.g. by another replica, is not an error.
+func (c *S3Client) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	client := c.regions.client(c.regions.regionFor(bucket))
+	_, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	var apiErr smithy.APIError
+	if stderrors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchUpload" {
+		return nil
+	}
+	return err
 }
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var (
+	inFlightRequests = metrics.NewGauge("gateway_inflight_requests",
+		"Requests currently being served.")
+	shedRequestsTotal = metrics.NewCounter("gateway_shed_requests_total",
+		"Requests rejected because a concurrency limit was reached.", "scope")
+	queuedRequestsTotal = metrics.NewCounter("gateway_tenant_queued_requests_total",
+		"Requests that waited for a slot under their tenant's concurrency cap.")
+)
+
+// loadShedder caps concurrent in-flight requests globally and per tenant.
+// Requests beyond the global cap are rejected immediately, so latency stays
+// bounded under overload. Requests beyond a tenant's cap wait up to
+// queueTimeout for one of the tenant's requests to finish, first come first
+// served, and are rejected after that. A zero limit disables that cap.
+type loadShedder struct {
+	maxInFlight  int64
+	maxPerTenant int64
+	queueTimeout time.Duration
+
+	inFlight atomic.Int64
+
+	mu      sync.Mutex
+	tenants map[string]*tenantSlots
+}
+
+// tenantSlots is a tenant's in-flight count and queued requests
+type tenantSlots struct {
+	inFlight int64
+	waiters  []chan struct{}
+}
+
+func newLoadShedder(maxInFlight, maxPerTenant int, queueTimeout time.Duration) *loadShedder {
+	return &loadShedder{
+		maxInFlight:  int64(maxInFlight),
+		maxPerTenant: int64(maxPerTenant),
+		queueTimeout: queueTimeout,
+		tenants:      make(map[string]*tenantSlots),
+	}
+}
+
+// acquire admits a request against the global cap, returning false if shed
+func (s *loadShedder) acquire() bool {
+	n := s.inFlight.Add(1)
+	if s.maxInFlight > 0 && n > s.maxInFlight {
+		s.inFlight.Add(-1)
+		shedRequestsTotal.Inc("global")
+		return false
+	}
+	inFlightRequests.Inc()
+	return true
+}
+
+func (s *loadShedder) release() {
+	s.inFlight.Add(-1)
+	inFlightRequests.Dec()
+}
+
+// acquireTenant admits a request against the tenant's cap, queuing it if the
+// cap is reached. It returns false if the request was shed.
+func (s *loadShedder) acquireTenant(ctx context.Context, tenant string) bool {
+	if s.maxPerTenant <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	t := s.tenants[tenant]
+	if t == nil {
+		t = &tenantSlots{}
+		s.tenants[tenant] = t
+	}
+	if t.inFlight < s.maxPerTenant {
+		t.inFlight++
+		s.mu.Unlock()
+		return true
+	}
+	if s.queueTimeout <= 0 {
+		s.mu.Unlock()
+		shedRequestsTotal.Inc("tenant")
+		return false
+	}
+	ready := make(chan struct{})
+	t.waiters = append(t.waiters, ready)
+	s.mu.Unlock()
+	queuedRequestsTotal.Inc()
+
+	timer := time.NewTimer(s.queueTimeout)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-ready:
+		// Handed a slot as the wait ended
+		return true
+	default:
+	}
+	for i, w := range t.waiters {
+		if w == ready {
+			t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+			break
+		}
+	}
+	shedRequestsTotal.Inc("tenant")
+	return false
+}
+
+// releaseTenant frees a tenant slot, handing it to the longest queued request
+func (s *loadShedder) releaseTenant(tenant string) {
+	if s.maxPerTenant <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tenants[tenant]
+	if t == nil {
+		return
+	}
+	if len(t.waiters) > 0 {
+		close(t.waiters[0])
+		t.waiters = t.waiters[1:]
+		return
+	}
+	if t.inFlight--; t.inFlight <= 0 {
+		delete(s.tenants, tenant)
+	}
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadShedder_GlobalCap(t *testing.T) {
+	s := newLoadShedder(2, 0, 0)
+
+	if !s.acquire() || !s.acquire() {
+		t.Fatal("expected the first two requests to be admitted")
+	}
+	if s.acquire() {
+		t.Fatal("expected the third request to be shed")
+	}
+	s.release()
+	if !s.acquire() {
+		t.Fatal("expected a request to be admitted after a release")
+	}
+}
+
+func TestLoadShedder_TenantCap(t *testing.T) {
+	s := newLoadShedder(0, 1, 0)
+	ctx := context.Background()
+
+	if !s.acquireTenant(ctx, "tenant-a") {
+		t.Fatal("expected tenant-a to be admitted")
+	}
+	if s.acquireTenant(ctx, "tenant-a") {
+		t.Fatal("expected tenant-a to be shed at its cap")
+	}
+	if !s.acquireTenant(ctx, "tenant-b") {
+		t.Fatal("tenant-b must not be affected by tenant-a's load")
+	}
+	s.releaseTenant("tenant-a")
+	if !s.acquireTenant(ctx, "tenant-a") {
+		t.Fatal("expected tenant-a to be admitted after a release")
+	}
+}
+
+func TestLoadShedder_Unlimited(t *testing.T) {
+	s := newLoadShedder(0, 0, 0)
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		if !s.acquire() || !s.acquireTenant(ctx, "tenant") {
+			t.Fatal("unlimited shedder rejected a request")
+		}
+	}
+}
+
+func TestLoadShedder_TenantQueue(t *testing.T) {
+	s := newLoadShedder(0, 1, time.Minute)
+	ctx := context.Background()
+	if !s.acquireTenant(ctx, "tenant-a") {
+		t.Fatal("expected tenant-a to be admitted")
+	}
+
+	admitted := make(chan bool)
+	go func() { admitted <- s.acquireTenant(ctx, "tenant-a") }()
+	select {
+	case <-admitted:
+		t.Fatal("expected the second request to wait for a slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.releaseTenant("tenant-a")
+	if !<-admitted {
+		t.Fatal("expected the queued request to get the released slot")
+	}
+
+	// The slot was handed over, so the tenant is still at its cap
+	s.queueTimeout = 10 * time.Millisecond
+	if s.acquireTenant(ctx, "tenant-a") {
+		t.Fatal("expected a request to be shed after the queue timeout")
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	s.queueTimeout = time.Minute
+	if s.acquireTenant(cancelled, "tenant-a") {
+		t.Fatal("expected a cancelled request to stop waiting")
+	}
+	s.releaseTenant("tenant-a")
+	if !s.acquireTenant(ctx, "tenant-a") {
+		t.Fatal("expected timed out requests to leave the queue")
+	}
+}
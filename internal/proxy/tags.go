@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// maxCachedTags bounds each tag cache; it is emptied when full
+const maxCachedTags = 10000
+
+// BucketTagger returns the tags of a bucket, empty when it has none
+type BucketTagger interface {
+	BucketTags(ctx context.Context, bucket string) (map[string]string, error)
+}
+
+// ObjectTagger returns the tags of an object, empty when it has none or does
+// not exist
+type ObjectTagger interface {
+	ObjectTags(ctx context.Context, bucket, key string) (map[string]string, error)
+}
+
+// WithBucketTags provides bucket tags as s3:ResourceTag/<key> condition keys,
+// fetched from tagger when a credential's policies reference them and cached
+// for ttl
+func WithBucketTags(tagger BucketTagger, ttl time.Duration) Option {
+	return func(g *Gateway) {
+		g.bucketTags = newTagCache(ttl, func(ctx context.Context, bucket, _ string) (map[string]string, error) {
+			return tagger.BucketTags(ctx, bucket)
+		})
+	}
+}
+
+// WithObjectTags provides object tags as s3:ExistingObjectTag/<key>
+// condition keys on GetObject, HeadObject and DeleteObject, fetched from
+// tagger when a credential's policies reference them and cached for ttl
+func WithObjectTags(tagger ObjectTagger, ttl time.Duration) Option {
+	return func(g *Gateway) {
+		g.objectTags = newTagCache(ttl, tagger.ObjectTags)
+	}
+}
+
+type cachedTags struct {
+	tags    map[string]string
+	expires time.Time
+}
+
+// tagCache caches bucket or object tags. Tag changes made directly against
+// the backend take effect once the cached entry expires.
+type tagCache struct {
+	fetch func(ctx context.Context, bucket, key string) (map[string]string, error)
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedTags
+}
+
+func newTagCache(ttl time.Duration, fetch func(ctx context.Context, bucket, key string) (map[string]string, error)) *tagCache {
+	return &tagCache{fetch: fetch, ttl: ttl, now: time.Now, entries: make(map[string]cachedTags)}
+}
+
+// get returns the tags of a bucket or object, fetching them when not cached.
+// Failures are not cached.
+func (c *tagCache) get(ctx context.Context, bucket, key string) (map[string]string, error) {
+	now := c.now()
+	name := bucket + "/" + key
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.tags, nil
+	}
+
+	tags, err := c.fetch(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if len(c.entries) >= maxCachedTags {
+		c.entries = make(map[string]cachedTags)
+	}
+	c.entries[name] = cachedTags{tags: tags, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return tags, nil
+}
+
+// invalidate drops a cached entry, e.g. after the object was overwritten
+func (c *tagCache) invalidate(bucket, key string) {
+	c.mu.Lock()
+	delete(c.entries, bucket+"/"+key)
+	c.mu.Unlock()
+}
+
+// objectTagActions are the actions evaluated with s3:ExistingObjectTag keys
+var objectTagActions = map[string]bool{
+	"s3:GetObject":    true,
+	"s3:DeleteObject": true,
+}
+
+// objectTagWrites are the actions after which an object's cached tags are stale
+var objectTagWrites = map[string]bool{
+	"s3:PutObject":           true,
+	"s3:DeleteObject":        true,
+	"s3:PutObjectTagging":    true,
+	"s3:DeleteObjectTagging": true,
+}
+
+// addTagConditions sets the bucket's and object's tags as condition keys
+// when the policies reference them. Keys already present, e.g. set by hooks,
+// are replaced.
+func (g *Gateway) addTagConditions(ctx context.Context, evalCtx *policy.EvalContext, policyNames []string) error {
+	for key := range evalCtx.Conditions {
+		if strings.HasPrefix(key, policy.ResourceTagPrefix) || strings.HasPrefix(key, policy.ExistingObjectTagPrefix) {
+			delete(evalCtx.Conditions, key)
+		}
+	}
+
+	if g.bucketTags != nil && policy.ReferencesConditionKey(g.policyEngine, policyNames, policy.ResourceTagPrefix) {
+		tags, err := g.bucketTags.get(ctx, evalCtx.Bucket, "")
+		if err != nil {
+			return err
+		}
+		for k, v := range tags {
+			evalCtx.Conditions[policy.ResourceTagPrefix+k] = v
+		}
+	}
+
+	if g.objectTags != nil && evalCtx.Key != "" && objectTagActions[evalCtx.Action] &&
+		policy.ReferencesConditionKey(g.policyEngine, policyNames, policy.ExistingObjectTagPrefix) {
+		tags, err := g.objectTags.get(ctx, evalCtx.Bucket, evalCtx.Key)
+		if err != nil {
+			return err
+		}
+		for k, v := range tags {
+			evalCtx.Conditions[policy.ExistingObjectTagPrefix+k] = v
+		}
+	}
+	return nil
+}
+
+// addRequestTags sets the tags of an upload's x-amz-tagging header as
+// s3:RequestObjectTag/<key> and s3:RequestObjectTagKeys. Keys already
+// present, e.g. set by hooks, are replaced.
+func addRequestTags(evalCtx *policy.EvalContext, req *S3Request) {
+	for key := range evalCtx.Conditions {
+		if strings.HasPrefix(key, policy.RequestObjectTagPrefix) {
+			delete(evalCtx.Conditions, key)
+		}
+	}
+	if req.Action != "s3:PutObject" {
+		return
+	}
+
+	tags := parseTagging(req.Headers.Get("x-amz-tagging"))
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		evalCtx.Conditions[policy.RequestObjectTagPrefix+k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	evalCtx.MultiValues = map[string][]string{policy.RequestObjectTagKeys: keys}
+}
+
+// objectWritten drops the cached tags of an object the request changed
+func (g *Gateway) objectWritten(req *S3Request) {
+	if g.objectTags != nil && objectTagWrites[req.Action] {
+		g.objectTags.invalidate(req.Bucket, req.Key)
+	}
+}
+
+// addObjectTags adds tags to an upload's x-amz-tagging header, replacing
+// client tags with the same keys
+func addObjectTags(h http.Header, tags map[string]string) {
+	values, err := url.ParseQuery(h.Get("x-amz-tagging"))
+	if err != nil {
+		values = url.Values{}
+	}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	h.Set("x-amz-tagging", values.Encode())
+}
+
+// parseTagging decodes an x-amz-tagging header, e.g. "team=a&public=true"
+func parseTagging(header string) map[string]string {
+	values, err := url.ParseQuery(header)
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(values))
+	for k, v := range values {
+		tags[k] = v[0]
+	}
+	return tags
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTagCache(t *testing.T) {
+	calls := 0
+	var fetchErr error
+	c := newTagCache(time.Minute, func(ctx context.Context, bucket, key string) (map[string]string, error) {
+		calls++
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return map[string]string{"team": bucket + "/" + key}, nil
+	})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		tags, err := c.get(context.Background(), "analytics", "")
+		if err != nil || tags["team"] != "analytics/" {
+			t.Fatalf("get = %v, %v", tags, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("fetched %d times, want 1", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	c.get(context.Background(), "analytics", "")
+	if calls != 2 {
+		t.Errorf("expired entry not refetched: %d calls", calls)
+	}
+
+	c.get(context.Background(), "analytics", "report.csv")
+	c.invalidate("analytics", "report.csv")
+	c.get(context.Background(), "analytics", "report.csv")
+	if calls != 4 {
+		t.Errorf("invalidated entry not refetched: %d calls", calls)
+	}
+
+	fetchErr = errors.New("unavailable")
+	if _, err := c.get(context.Background(), "other", ""); err == nil {
+		t.Error("expected error")
+	}
+	fetchErr = nil
+	if tags, err := c.get(context.Background(), "other", ""); err != nil || tags["team"] != "other/" {
+		t.Errorf("failure was cached: %v, %v", tags, err)
+	}
+}
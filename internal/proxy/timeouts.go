@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/errors"
+)
+
+// actionTimeout returns the upstream deadline for a request. Metadata
+// operations and data transfers are bounded separately so a stuck List
+// fails fast while large transfers keep their longer budget.
+func actionTimeout(cfg *config.TimeoutsConfig, req *S3Request) time.Duration {
+	switch req.Action {
+	case "s3:GetObject":
+		if req.HTTPMethod == http.MethodHead {
+			return cfg.Metadata
+		}
+		return cfg.Transfer
+	case "s3:PutObject":
+		return cfg.Transfer
+	default:
+		return cfg.Metadata
+	}
+}
+
+// withActionTimeout derives the context for an upstream call. The returned
+// cancel must be called once the response body has been consumed.
+func withActionTimeout(ctx context.Context, cfg *config.TimeoutsConfig, req *S3Request) (context.Context, context.CancelFunc) {
+	if timeout := actionTimeout(cfg, req); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// errUpstreamTimeout reports an upstream call that exceeded its deadline
+func errUpstreamTimeout(req *S3Request) *BackendError {
+	return backendError(errors.ErrServiceUnavailable.WithMessage(
+		"The upstream " + req.Action + " request timed out. Please retry."))
+}
+
+// isOwnTimeout reports whether err came from ctx's own deadline rather than
+// from the caller's context
+func isOwnTimeout(ctx, parent context.Context, err error) bool {
+	return err != nil && stderrors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestActionTimeout(t *testing.T) {
+	cfg := &config.TimeoutsConfig{Metadata: time.Second, Transfer: time.Minute}
+
+	tests := []struct {
+		action string
+		method string
+		want   time.Duration
+	}{
+		{"s3:GetObject", http.MethodGet, time.Minute},
+		{"s3:GetObject", http.MethodHead, time.Second},
+		{"s3:PutObject", http.MethodPut, time.Minute},
+		{"s3:ListBucket", http.MethodGet, time.Second},
+		{"s3:DeleteObject", http.MethodDelete, time.Second},
+		{"s3:HeadObject", http.MethodHead, time.Second},
+	}
+	for _, tt := range tests {
+		req := &S3Request{Action: tt.action, HTTPMethod: tt.method}
+		if got := actionTimeout(cfg, req); got != tt.want {
+			t.Errorf("%s %s: timeout = %v, want %v", tt.method, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestS3Client_MetadataTimeout(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	client := newTestS3Client(t, upstream.URL, RedirectFollow)
+	client.cfg.Timeouts.Metadata = 50 * time.Millisecond
+
+	req := &S3Request{Bucket: "data", Action: "s3:ListBucket", Operation: "ListObjectsV2", HTTPMethod: http.MethodGet,
+		Headers: make(http.Header), QueryParams: url.Values{}}
+	_, err := client.Forward(context.Background(), req)
+
+	var backendErr *BackendError
+	if !stderrors.As(err, &backendErr) || backendErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 BackendError, got %v", err)
+	}
+}
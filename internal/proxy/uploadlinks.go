@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/auth"
+	"github.com/s3-access-control-adapter/internal/errors"
+	"github.com/s3-access-control-adapter/internal/uploadlink"
+)
+
+// UploadLinkPath prefixes the URLs of upload links on the S3 listeners; no
+// bucket name can clash with it
+const UploadLinkPath = "/_gateway/upload/"
+
+// WithUploadLinks accepts uploads through the links of store at
+// UploadLinkPath followed by the link's token
+func WithUploadLinks(store *uploadlink.Store) Option {
+	return func(g *Gateway) {
+		g.uploadLinks = store
+	}
+}
+
+// uploadLinkError refuses an upload its link does not allow
+type uploadLinkError struct {
+	code errors.ErrorCode
+}
+
+func (e *uploadLinkError) Error() string {
+	return e.code.Message
+}
+
+// serveUploadLink stores the body of a PUT to an upload link as the link's
+// object. The link is used up once the backend accepts the upload; uploads
+// that fail can be retried. Like other requests, uploads are subject to the
+// operations allowlist and load shedding.
+func (g *Gateway) serveUploadLink(w http.ResponseWriter, r *http.Request, rc *RequestContext) {
+	s3req := &S3Request{
+		Action:      "s3:PutObject",
+		Operation:   "PutObject",
+		HTTPMethod:  http.MethodPut,
+		Headers:     http.Header{},
+		QueryParams: url.Values{},
+	}
+	rc.S3 = s3req
+	if r.Method != http.MethodPut {
+		errors.WriteS3ErrorCode(w, errors.ErrMethodNotAllowed, rc.RequestID)
+		return
+	}
+	if !g.operations[s3req.Operation] {
+		g.handleError(w, rc, errors.DenyNotImplemented, nil)
+		return
+	}
+	if !g.shedder.acquire() {
+		w.Header().Set("Retry-After", shedRetryAfter)
+		g.handleError(w, rc, errors.DenyThrottled, nil)
+		return
+	}
+	defer g.shedder.release()
+
+	link, err := g.uploadLinks.Claim(strings.TrimPrefix(r.URL.Path, UploadLinkPath))
+	if stderrors.Is(err, uploadlink.ErrInUse) {
+		err = &uploadLinkError{code: errors.ErrConditionalRequestConflict.WithMessage("Another upload through this link is in progress.")}
+	}
+	if err != nil {
+		g.handleUploadLink(w, rc, err)
+		return
+	}
+	completed := false
+	defer func() {
+		if !completed {
+			g.uploadLinks.Release(link.ID)
+		}
+	}()
+
+	s3req.Bucket, s3req.Key = link.Bucket, link.Key
+	rc.Auth = &auth.AuthContext{ClientID: "upload-link:" + link.ID, TenantID: link.TenantID}
+	if reason := g.tenantDenyReason(link.TenantID); reason != "" {
+		g.handleError(w, rc, reason, nil)
+		return
+	}
+	if !g.shedder.acquireTenant(r.Context(), link.TenantID) {
+		w.Header().Set("Retry-After", shedRetryAfter)
+		g.handleError(w, rc, errors.DenyThrottled, nil)
+		return
+	}
+	defer g.shedder.releaseTenant(link.TenantID)
+	if err := checkUploadLink(&link, r); err != nil {
+		g.handleUploadLink(w, rc, err)
+		return
+	}
+	for _, h := range []string{"Content-Type", "Content-MD5"} {
+		if v := r.Header.Get(h); v != "" {
+			s3req.Headers.Set(h, v)
+		}
+	}
+	s3req.Body, s3req.ContentLength = r.Body, r.ContentLength
+	if err := g.dlp.check(r.Context(), rc, s3req); err != nil {
+		g.handleDLP(w, rc, err)
+		return
+	}
+	g.addAutoTags(rc, s3req)
+
+	resp, err := g.backend.Forward(r.Context(), s3req)
+	if err != nil {
+		log.Printf("[%s] S3 forward error: %v", rc.RequestID, err)
+		g.handleS3Error(w, rc, err)
+		return
+	}
+	if resp.StatusCode < 300 {
+		completed = true
+		if err := g.uploadLinks.Complete(link.ID); err != nil {
+			log.Printf("[%s] Failed to record use of upload link %s: %v", rc.RequestID, link.ID, err)
+		}
+		g.objectWritten(s3req)
+	}
+	g.writeResponse(w, r, s3req, resp)
+	g.logAudit(audit.NewAllowEntry(rc.RequestID, rc.ClientID(), link.TenantID, s3req.Action, link.Bucket, link.Key,
+		g.clientIP(r), r.UserAgent(), time.Since(rc.StartTime), resp.StatusCode), w, r)
+}
+
+// checkUploadLink checks an upload against the size and content type its
+// link allows
+func checkUploadLink(link *uploadlink.Link, r *http.Request) error {
+	if r.ContentLength < 0 {
+		return &uploadLinkError{code: errors.ErrMissingContentLength}
+	}
+	if r.ContentLength > link.MaxSize {
+		return &uploadLinkError{code: errors.ErrEntityTooLarge.WithMessage(
+			fmt.Sprintf("The upload link allows at most %d bytes.", link.MaxSize))}
+	}
+	if link.ContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if want, _, _ := mime.ParseMediaType(link.ContentType); err != nil || !strings.EqualFold(mediaType, want) {
+			return &uploadLinkError{code: errors.ErrAccessDenied.WithMessage(
+				fmt.Sprintf("The upload link requires Content-Type %s.", link.ContentType))}
+		}
+	}
+	return nil
+}
+
+// handleUploadLink writes the error for an upload refused by its link and
+// audits it
+func (g *Gateway) handleUploadLink(w http.ResponseWriter, rc *RequestContext, err error) {
+	var linkErr *uploadLinkError
+	if !stderrors.As(err, &linkErr) {
+		log.Printf("[%s] Upload link refused: %v", rc.RequestID, err)
+		g.handleError(w, rc, errors.DenyUploadLink, err)
+		return
+	}
+	log.Printf("[%s] Upload refused by its link: client=%s key=%s: %v", rc.RequestID, rc.ClientID(), rc.logKey(), err)
+
+	entry := audit.NewDenyEntry(rc.RequestID, rc.ClientID(), rc.TenantID(), rc.S3.Action,
+		rc.S3.Bucket, rc.S3.Key, g.clientIP(rc.Request), rc.Request.UserAgent(),
+		string(errors.DenyUploadLink), time.Since(rc.StartTime))
+	entry.ErrorMsg = err.Error()
+	defer g.logAudit(entry, w, rc.Request)
+
+	if g.denyReasonHeader {
+		w.Header().Set(DenyReasonHeader, string(errors.DenyUploadLink))
+	}
+	errors.WriteS3ErrorCode(w, linkErr.code, rc.RequestID)
+}
@@ -0,0 +1,96 @@
+package proxy_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/proxy"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+	"github.com/s3-access-control-adapter/internal/uploadlink"
+)
+
+func TestGateway_UploadLinks(t *testing.T) {
+	store, err := uploadlink.NewStore("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithUploadLinks(store))
+
+	put := func(token, contentType, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, gw.URL+proxy.UploadLinkPath+token, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	create := func(bucket string) uploadlink.Link {
+		l, err := store.Create(uploadlink.Link{TenantID: "tenant-001", Bucket: bucket, Key: "inbox/report.pdf",
+			MaxSize: 16, ContentType: "application/pdf"}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+
+	link := create("tenant-001-data")
+	if resp := put(link.Token, "application/pdf", strings.Repeat("x", 17)); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("oversized upload: status = %d, want 400", resp.StatusCode)
+	}
+	if resp := put(link.Token, "text/plain", "%PDF-1.7"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("wrong content type: status = %d, want 403", resp.StatusCode)
+	}
+	if resp := put(link.Token, "application/pdf", "%PDF-1.7"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload: status = %d", resp.StatusCode)
+	}
+	if data, ok := gw.Backend.GetObject("tenant-001-data", "inbox/report.pdf"); !ok || string(data) != "%PDF-1.7" {
+		t.Errorf("stored object = %q, %v", data, ok)
+	}
+	entry := gw.Audit.Last()
+	if entry.ClientID != "upload-link:"+link.ID || entry.Decision != "allow" || entry.Key != "inbox/report.pdf" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+
+	if resp := put(link.Token, "application/pdf", "%PDF-1.7"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("reused link: status = %d, want 403", resp.StatusCode)
+	}
+	if entry := gw.Audit.Last(); entry.DenyReason != "DENY_UPLOAD_LINK" {
+		t.Errorf("reused link: deny reason = %q", entry.DenyReason)
+	}
+	if resp := put("unknown", "application/pdf", "%PDF-1.7"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unknown token: status = %d, want 403", resp.StatusCode)
+	}
+
+	// An upload the backend rejects leaves the link usable
+	failing := create("missing-bucket")
+	if resp := put(failing.Token, "application/pdf", "%PDF-1.7"); resp.StatusCode < 300 {
+		t.Fatalf("upload to missing bucket: status = %d", resp.StatusCode)
+	}
+	if l, _ := store.Get(failing.ID); l.UsedAt != nil {
+		t.Error("failed upload used up the link")
+	}
+
+	// Links cannot upload when PutObject is not in the operations allowlist
+	readOnly := proxytest.NewGatewayWithOptions(t, []config.Credential{writerCred}, testPolicies,
+		[]string{"tenant-001-data"}, proxy.WithUploadLinks(store), proxy.WithOperations([]string{"GetObject", "ListObjectsV2"}))
+	link = create("tenant-001-data")
+	req, _ := http.NewRequest(http.MethodPut, readOnly.URL+proxy.UploadLinkPath+link.Token, strings.NewReader("%PDF-1.7"))
+	req.Header.Set("Content-Type", "application/pdf")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("upload with PutObject disabled: status = %d, want 501", resp.StatusCode)
+	}
+	if l, _ := store.Get(link.ID); l.UsedAt != nil {
+		t.Error("refused upload used up the link")
+	}
+}
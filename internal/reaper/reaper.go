@@ -0,0 +1,141 @@
+// Package reaper aborts multipart uploads left incomplete in tenant buckets.
+// The parts of an abandoned upload are stored, and billed, until the upload
+// is completed or aborted, yet never show up in object listings. Every
+// abort is recorded in the audit log.
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// ClientID is the client recorded in the audit entries of aborted uploads
+const ClientID = "gateway:upload-reaper"
+
+// Upload is an in-progress multipart upload
+type Upload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// Store lists and aborts multipart uploads
+type Store interface {
+	Buckets(ctx context.Context) ([]string, error)
+	MultipartUploads(ctx context.Context, bucket string) ([]Upload, error)
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+}
+
+// TenantOf returns the tenant a bucket belongs to, and false for buckets
+// outside every tenant's scopes, which are left alone
+type TenantOf func(bucket string) (string, bool)
+
+// Reaper aborts uploads initiated longer than maxAge ago
+type Reaper struct {
+	store    Store
+	tenantOf TenantOf
+	maxAge   time.Duration
+	logger   audit.Logger
+	now      func() time.Time
+}
+
+// New creates a reaper recording aborts to logger; a nil logger records
+// nothing
+func New(store Store, tenantOf TenantOf, maxAge time.Duration, logger audit.Logger) *Reaper {
+	return &Reaper{store: store, tenantOf: tenantOf, maxAge: maxAge, logger: logger, now: time.Now}
+}
+
+// Reap aborts the stale uploads of every tenant bucket, returning how many
+// it aborted. A bucket that fails is reported and the others still reaped.
+func (r *Reaper) Reap(ctx context.Context) (int, error) {
+	buckets, err := r.store.Buckets(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list buckets: %w", err)
+	}
+	aborted := 0
+	var errs []error
+	for _, bucket := range buckets {
+		tenantID, ok := r.tenantOf(bucket)
+		if !ok {
+			continue
+		}
+		n, err := r.reapBucket(ctx, bucket, tenantID)
+		aborted += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return aborted, errors.Join(errs...)
+}
+
+func (r *Reaper) reapBucket(ctx context.Context, bucket, tenantID string) (int, error) {
+	uploads, err := r.store.MultipartUploads(ctx, bucket)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list uploads in %s: %w", bucket, err)
+	}
+	cutoff := r.now().Add(-r.maxAge)
+	aborted := 0
+	for _, u := range uploads {
+		if !u.Initiated.Before(cutoff) {
+			continue
+		}
+		start := time.Now()
+		if err := r.store.AbortMultipartUpload(ctx, bucket, u.Key, u.UploadID); err != nil {
+			return aborted, fmt.Errorf("failed to abort upload %s of %s/%s: %w", u.UploadID, bucket, u.Key, err)
+		}
+		r.record(bucket, tenantID, u, time.Since(start))
+		aborted++
+	}
+	return aborted, nil
+}
+
+// Run reaps every interval until stop is closed
+func (r *Reaper) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		n, err := r.Reap(context.Background())
+		if n > 0 {
+			log.Printf("Aborted %d incomplete multipart upload(s) older than %s", n, r.maxAge)
+		}
+		if err != nil {
+			// Retried on the next run
+			log.Printf("Failed to reap multipart uploads: %v", err)
+		}
+	}
+}
+
+// record writes an abort to the audit log, identified by the upload ID
+func (r *Reaper) record(bucket, tenantID string, u Upload, duration time.Duration) {
+	if r.logger == nil {
+		return
+	}
+	r.logger.Log(&audit.Entry{
+		Timestamp:  r.now().UTC(),
+		RequestID:  u.UploadID,
+		ClientID:   ClientID,
+		TenantID:   tenantID,
+		Action:     "s3:AbortMultipartUpload",
+		Resource:   policy.BuildResourceARN(bucket, u.Key),
+		Bucket:     bucket,
+		Key:        u.Key,
+		Decision:   "allow",
+		DurationMs: duration.Milliseconds(),
+		StatusCode: http.StatusNoContent,
+	})
+}
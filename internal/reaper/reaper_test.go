@@ -0,0 +1,82 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+)
+
+type recordingLogger struct{ entries []*audit.Entry }
+
+func (l *recordingLogger) Log(e *audit.Entry) error { l.entries = append(l.entries, e); return nil }
+func (l *recordingLogger) Close() error             { return nil }
+
+type fakeStore struct {
+	uploads map[string][]Upload
+	failing map[string]bool // buckets whose listing fails
+	aborted []string
+}
+
+func (s *fakeStore) Buckets(ctx context.Context) ([]string, error) {
+	return []string{"shared-logs", "tenant-001-data", "tenant-002-data"}, nil
+}
+
+func (s *fakeStore) MultipartUploads(ctx context.Context, bucket string) ([]Upload, error) {
+	if s.failing[bucket] {
+		return nil, errors.New("access denied")
+	}
+	return s.uploads[bucket], nil
+}
+
+func (s *fakeStore) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	s.aborted = append(s.aborted, bucket+"/"+key+"#"+uploadID)
+	return nil
+}
+
+func TestReaper_Reap(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	store := &fakeStore{
+		uploads: map[string][]Upload{
+			"tenant-001-data": {
+				{Key: "old.bin", UploadID: "u1", Initiated: now.Add(-48 * time.Hour)},
+				{Key: "recent.bin", UploadID: "u2", Initiated: now.Add(-time.Hour)},
+			},
+			"shared-logs": {{Key: "old.log", UploadID: "u3", Initiated: now.Add(-72 * time.Hour)}},
+		},
+		failing: map[string]bool{"tenant-002-data": true},
+	}
+	tenantOf := func(bucket string) (string, bool) {
+		if strings.HasPrefix(bucket, "tenant-001-") {
+			return "tenant-001", true
+		}
+		if strings.HasPrefix(bucket, "tenant-002-") {
+			return "tenant-002", true
+		}
+		return "", false
+	}
+	logger := &recordingLogger{}
+	r := New(store, tenantOf, 24*time.Hour, logger)
+	r.now = func() time.Time { return now }
+
+	n, err := r.Reap(context.Background())
+	if n != 1 || err == nil || !strings.Contains(err.Error(), "tenant-002-data") {
+		t.Errorf("Reap = %d, %v; want 1 and the tenant-002-data error", n, err)
+	}
+	// Buckets outside every tenant's scopes are left alone
+	if want := []string{"tenant-001-data/old.bin#u1"}; !reflect.DeepEqual(store.aborted, want) {
+		t.Errorf("aborted = %v, want %v", store.aborted, want)
+	}
+	if len(logger.entries) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(logger.entries))
+	}
+	e := logger.entries[0]
+	if e.ClientID != ClientID || e.TenantID != "tenant-001" || e.Action != "s3:AbortMultipartUpload" ||
+		e.RequestID != "u1" || e.Resource != "arn:aws:s3:::tenant-001-data/old.bin" {
+		t.Errorf("unexpected audit entry: %+v", e)
+	}
+}
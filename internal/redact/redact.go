@@ -0,0 +1,76 @@
+// Package redact hides sensitive object keys in audit entries and logs.
+// Keys matching a redaction rule are replaced by a keyed hash, so entries
+// about one object can still be correlated, or removed altogether.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// Removed replaces keys redacted with mode "remove"
+const Removed = "[redacted]"
+
+// hashPrefix marks hashed keys, which are the first 16 bytes of the hash
+const hashPrefix = "redacted:"
+
+const arnPrefix = "arn:aws:s3:::"
+
+// Redactor redacts object keys. A nil Redactor leaves keys unchanged.
+type Redactor struct {
+	rules []config.KeyRedactionRule
+	key   []byte
+}
+
+// New creates a redactor for the rules in cfg, or returns nil if there are
+// none
+func New(cfg *config.RedactionConfig) *Redactor {
+	if len(cfg.Keys) == 0 {
+		return nil
+	}
+	return &Redactor{rules: cfg.Keys, key: []byte(cfg.HMACKey)}
+}
+
+// Key returns key, or its redacted form if bucket/key matches a rule
+func (r *Redactor) Key(bucket, key string) string {
+	if r == nil || key == "" {
+		return key
+	}
+	name := bucket + "/" + key
+	for _, rule := range r.rules {
+		if !policy.MatchResource(name, []string{rule.Pattern}) {
+			continue
+		}
+		if rule.Mode == "remove" {
+			return Removed
+		}
+		return hashPrefix + r.hash(name)
+	}
+	return key
+}
+
+// Resource returns an S3 ARN with its key redacted
+func (r *Redactor) Resource(arn string) string {
+	if r == nil || !strings.HasPrefix(arn, arnPrefix) {
+		return arn
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(arn, arnPrefix), "/")
+	if !ok {
+		return arn
+	}
+	return arnPrefix + bucket + "/" + r.Key(bucket, key)
+}
+
+func (r *Redactor) hash(name string) string {
+	h := sha256.New()
+	if len(r.key) > 0 {
+		h = hmac.New(sha256.New, r.key)
+	}
+	h.Write([]byte(name))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,50 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestRedactor(t *testing.T) {
+	r := New(&config.RedactionConfig{
+		HMACKey: "secret",
+		Keys: []config.KeyRedactionRule{
+			{Pattern: "*/pii/*", Mode: "hash"},
+			{Pattern: "hr-data/*", Mode: "remove"},
+		},
+	})
+
+	hashed := r.Key("tenant-001-data", "pii/alice-ssn.csv")
+	if !strings.HasPrefix(hashed, hashPrefix) || strings.Contains(hashed, "alice") {
+		t.Errorf("hashed key = %q", hashed)
+	}
+	if again := r.Key("tenant-001-data", "pii/alice-ssn.csv"); again != hashed {
+		t.Errorf("hashes differ: %q and %q", hashed, again)
+	}
+	if other := r.Key("tenant-002-data", "pii/alice-ssn.csv"); other == hashed {
+		t.Error("the same key in another bucket hashed alike")
+	}
+	if unkeyed := New(&config.RedactionConfig{Keys: r.rules}).Key("tenant-001-data", "pii/alice-ssn.csv"); unkeyed == hashed {
+		t.Error("the HMAC key did not change the hash")
+	}
+
+	if got := r.Key("hr-data", "reviews/bob.pdf"); got != Removed {
+		t.Errorf("removed key = %q", got)
+	}
+	if got := r.Key("tenant-001-data", "public/logo.png"); got != "public/logo.png" {
+		t.Errorf("unmatched key = %q", got)
+	}
+	if got := r.Resource("arn:aws:s3:::tenant-001-data/pii/alice-ssn.csv"); got != "arn:aws:s3:::tenant-001-data/"+hashed {
+		t.Errorf("resource = %q", got)
+	}
+	if got := r.Resource("arn:aws:s3:::hr-data"); got != "arn:aws:s3:::hr-data" {
+		t.Errorf("bucket resource = %q", got)
+	}
+
+	var none *Redactor
+	if got := none.Key("tenant-001-data", "pii/alice-ssn.csv"); got != "pii/alice-ssn.csv" {
+		t.Errorf("nil redactor changed the key to %q", got)
+	}
+}
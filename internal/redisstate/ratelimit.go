@@ -0,0 +1,104 @@
+// Package redisstate keeps state shared by gateway replicas in Redis, so
+// limits are enforced across the cluster rather than per instance
+package redisstate
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+// NewClient creates a client for the configured Redis
+func NewClient(cfg *config.RedisConfig) *redis.Client {
+	opts := &redis.Options{
+		Addr:         cfg.Address,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.Timeout,
+		ReadTimeout:  cfg.Timeout,
+		WriteTimeout: cfg.Timeout,
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return redis.NewClient(opts)
+}
+
+// tokenBucketScript takes a token from every bucket in KEYS, or from none
+// if one is empty. ARGV holds each bucket's rate and burst. Redis's clock is
+// used so replicas agree on refills. Returns {1} or {0, index, wait ms}.
+var tokenBucketScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local tokens = {}
+for i = 1, #KEYS do
+	local rate, burst = tonumber(ARGV[2*i-1]), tonumber(ARGV[2*i])
+	local b = redis.call('HMGET', KEYS[i], 'tokens', 'last')
+	local n, last = tonumber(b[1]), tonumber(b[2])
+	if n == nil then
+		n, last = burst, now
+	end
+	n = math.min(burst, n + math.max(0, now - last) * rate)
+	if n < 1 then
+		return {0, i - 1, math.ceil((1 - n) / rate * 1000)}
+	end
+	tokens[i] = n
+end
+for i = 1, #KEYS do
+	local rate, burst = tonumber(ARGV[2*i-1]), tonumber(ARGV[2*i])
+	redis.call('HSET', KEYS[i], 'tokens', tostring(tokens[i] - 1), 'last', tostring(now))
+	redis.call('PEXPIRE', KEYS[i], math.ceil(burst / rate * 1000) + 1000)
+end
+return {1}
+`)
+
+// RateLimiter enforces the rate limits of policy statements with token
+// buckets held in Redis
+type RateLimiter struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRateLimiter creates a rate limiter storing buckets under prefix
+func NewRateLimiter(client redis.Scripter, prefix string) *RateLimiter {
+	return &RateLimiter{client: client, prefix: prefix}
+}
+
+// Allow takes a token from the client's bucket of every limit. If any is
+// empty, none is taken and Allow returns its index and how long until it
+// refills; otherwise the index is -1.
+func (l *RateLimiter) Allow(ctx context.Context, clientID string, limits []policy.AppliedRateLimit) (int, time.Duration, error) {
+	if len(limits) == 0 {
+		return -1, 0, nil
+	}
+	keys := make([]string, len(limits))
+	args := make([]interface{}, 0, 2*len(limits))
+	for i, limit := range limits {
+		burst := limit.Burst
+		if burst == 0 {
+			burst = int(math.Ceil(limit.RequestsPerSecond))
+		}
+		// The client ID is a hash tag, keeping a request's buckets in one
+		// cluster slot. A changed limit gets a new bucket.
+		keys[i] = fmt.Sprintf("%sratelimit:{%s}:%s:%d:%g:%d",
+			l.prefix, clientID, limit.Policy, limit.Statement, limit.RequestsPerSecond, burst)
+		args = append(args, strconv.FormatFloat(limit.RequestsPerSecond, 'g', -1, 64), burst)
+	}
+
+	res, err := tokenBucketScript.Run(ctx, l.client, keys, args...).Int64Slice()
+	if err != nil {
+		return -1, 0, fmt.Errorf("failed to evaluate rate limits in redis: %w", err)
+	}
+	if len(res) == 3 && res[0] == 0 {
+		return int(res[1]), time.Duration(res[2]) * time.Millisecond, nil
+	}
+	return -1, 0, nil
+}
@@ -0,0 +1,45 @@
+package redisstate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/policy"
+)
+
+func TestRateLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	// Two replicas sharing one Redis draw from the same buckets
+	a, b := NewRateLimiter(client, "gateway:"), NewRateLimiter(client, "gateway:")
+	deletes := policy.AppliedRateLimit{Policy: "full", Statement: 1, RateLimit: config.RateLimit{RequestsPerSecond: 1, Burst: 2}}
+	writes := policy.AppliedRateLimit{Policy: "writes", RateLimit: config.RateLimit{RequestsPerSecond: 100}}
+
+	for i, l := range []*RateLimiter{a, b} {
+		if denied, _, err := l.Allow(ctx, "app", []policy.AppliedRateLimit{writes, deletes}); err != nil || denied != -1 {
+			t.Fatalf("request %d within burst: denied = %d, err = %v", i, denied, err)
+		}
+	}
+	denied, wait, err := a.Allow(ctx, "app", []policy.AppliedRateLimit{writes, deletes})
+	if err != nil || denied != 1 || wait <= 0 || wait > time.Second {
+		t.Fatalf("exhausted bucket: denied = %d, wait = %v, err = %v", denied, wait, err)
+	}
+	if denied, _, _ := b.Allow(ctx, "other", []policy.AppliedRateLimit{deletes}); denied != -1 {
+		t.Error("another client was limited")
+	}
+	if keys := mr.Keys(); len(keys) != 3 {
+		t.Errorf("keys = %v", keys)
+	}
+
+	mr.Close()
+	if _, _, err := a.Allow(ctx, "app", []policy.AppliedRateLimit{deletes}); err == nil {
+		t.Error("expected an error with Redis down")
+	}
+}
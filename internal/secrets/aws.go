@@ -0,0 +1,158 @@
+// Package secrets resolves ${aws-secrets:name} and ${ssm:/path} references in
+// configuration files from AWS Secrets Manager and SSM Parameter Store.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/s3-access-control-adapter/internal/awsjson"
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+// Reference schemes handled by this package
+const (
+	SchemeSecretsManager = "aws-secrets"
+	SchemeSSM            = "ssm"
+)
+
+// requestTimeout bounds each lookup so a missing network route fails startup
+// instead of hanging it
+const requestTimeout = 10 * time.Second
+
+// AWSResolver looks up secrets through the Secrets Manager and SSM JSON APIs,
+// caching each value for the resolver's lifetime
+type AWSResolver struct {
+	client *awsjson.Client
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewAWSResolver creates a resolver using the given AWS configuration
+func NewAWSResolver(cfg aws.Config, endpoint string) *AWSResolver {
+	return &AWSResolver{
+		client: awsjson.New(cfg, endpoint, requestTimeout),
+		cache:  make(map[string]string),
+	}
+}
+
+// Register registers both schemes with the config loader. The AWS
+// configuration is loaded from the default chain on first use, so configs
+// without secret references need no AWS access.
+func Register() {
+	var (
+		once     sync.Once
+		resolver *AWSResolver
+		loadErr  error
+	)
+	get := func() (*AWSResolver, error) {
+		once.Do(func() {
+			cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+			if err != nil {
+				loadErr = fmt.Errorf("failed to load AWS config: %w", err)
+				return
+			}
+			if cfg.Region == "" {
+				cfg.Region = "us-east-1"
+			}
+			resolver = NewAWSResolver(cfg, "")
+		})
+		return resolver, loadErr
+	}
+
+	config.RegisterResolver(SchemeSecretsManager, func(ref string) (string, error) {
+		r, err := get()
+		if err != nil {
+			return "", err
+		}
+		return r.SecretValue(ref)
+	})
+	config.RegisterResolver(SchemeSSM, func(ref string) (string, error) {
+		r, err := get()
+		if err != nil {
+			return "", err
+		}
+		return r.Parameter(ref)
+	})
+}
+
+// SecretValue returns a Secrets Manager secret string. A "#field" suffix
+// selects a field of a JSON secret, e.g. "prod/s3#secretAccessKey".
+func (r *AWSResolver) SecretValue(ref string) (string, error) {
+	name, field, hasField := strings.Cut(ref, "#")
+
+	var out struct {
+		SecretString *string
+	}
+	err := r.call(SchemeSecretsManager+":"+name, "secretsmanager", "secretsmanager.GetSecretValue",
+		map[string]interface{}{"SecretId": name}, &out)
+	if err != nil {
+		return "", err
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no string value", name)
+	}
+	if !hasField {
+		return *out.SecretString, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", name, err)
+	}
+	value, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no field %q", name, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// Parameter returns an SSM parameter value, decrypting SecureString parameters
+func (r *AWSResolver) Parameter(name string) (string, error) {
+	var out struct {
+		Parameter struct {
+			Value string
+		}
+	}
+	err := r.call(SchemeSSM+":"+name, "ssm", "AmazonSSM.GetParameter",
+		map[string]interface{}{"Name": name, "WithDecryption": true}, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.Parameter.Value, nil
+}
+
+// call makes a signed AWS JSON 1.1 API call, consulting the cache first
+func (r *AWSResolver) call(cacheKey, service, target string, input, output interface{}) error {
+	r.mu.Lock()
+	cached, ok := r.cache[cacheKey]
+	r.mu.Unlock()
+	if ok {
+		return json.Unmarshal([]byte(cached), output)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var raw json.RawMessage
+	if err := r.client.Call(ctx, service, target, input, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, output); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", service, err)
+	}
+	r.mu.Lock()
+	r.cache[cacheKey] = string(raw)
+	r.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func newTestResolver(t *testing.T, handler http.HandlerFunc) *AWSResolver {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	}
+	return NewAWSResolver(cfg, server.URL)
+}
+
+func TestAWSResolver(t *testing.T) {
+	var calls int32
+	r := newTestResolver(t, func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if !strings.Contains(req.Header.Get("Authorization"), "/eu-west-1/") {
+			t.Errorf("request not signed for region: %s", req.Header.Get("Authorization"))
+		}
+		var in map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&in)
+
+		switch req.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			switch in["SecretId"] {
+			case "plain":
+				json.NewEncoder(w).Encode(map[string]string{"SecretString": "s3cr3t"})
+			case "json":
+				json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"accessKey":"AKIA","port":9000}`})
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+			}
+		case "AmazonSSM.GetParameter":
+			if in["WithDecryption"] != true {
+				t.Error("expected WithDecryption")
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Parameter": map[string]string{"Value": "param:" + in["Name"].(string)}})
+		default:
+			t.Errorf("unexpected target %q", req.Header.Get("X-Amz-Target"))
+		}
+	})
+
+	tests := []struct {
+		name    string
+		resolve func(string) (string, error)
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{"plain secret", r.SecretValue, "plain", "s3cr3t", ""},
+		{"json field", r.SecretValue, "json#accessKey", "AKIA", ""},
+		{"json number field", r.SecretValue, "json#port", "9000", ""},
+		{"missing field", r.SecretValue, "json#nope", "", `no field "nope"`},
+		{"field of plain secret", r.SecretValue, "plain#key", "", "not a JSON object"},
+		{"missing secret", r.SecretValue, "missing", "", "ResourceNotFoundException"},
+		{"ssm parameter", r.Parameter, "/gateway/token", "param:/gateway/token", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.resolve(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The JSON secret was fetched once for three lookups
+	before := atomic.LoadInt32(&calls)
+	if _, err := r.SecretValue("json#accessKey"); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&calls) != before {
+		t.Error("expected cached value to be reused")
+	}
+}
@@ -0,0 +1,157 @@
+// Package slo tracks the gateway's own availability and latency service level
+// indicators over rolling windows and exports them with error budget burn
+// rates for alerting.
+package slo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/metrics"
+)
+
+var (
+	objectiveGauge = metrics.NewGauge("gateway_slo_objective",
+		"Target fraction of good requests", "sli")
+	ratioGauge = metrics.NewGauge("gateway_slo_sli_ratio",
+		"Fraction of good requests over the window", "sli", "window")
+	burnRateGauge = metrics.NewGauge("gateway_slo_burn_rate",
+		"Error budget burn rate over the window; 1 spends the budget exactly over the SLO period", "sli", "window")
+	windowRequestsGauge = metrics.NewGauge("gateway_slo_window_requests",
+		"Requests counted in the window", "window")
+)
+
+// SLI names used as metric labels
+const (
+	Availability = "availability"
+	Latency      = "latency"
+)
+
+// minBucketWidth is the resolution of the rolling windows
+const minBucketWidth = 10 * time.Second
+
+// Tracker counts good and bad requests in time buckets covering the longest
+// window
+type Tracker struct {
+	cfg   config.SLOConfig
+	width time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets []bucket
+}
+
+type bucket struct {
+	epoch  int64 // bucket number since the Unix epoch; stale when not current
+	total  uint64
+	errors uint64 // 5xx responses
+	slow   uint64 // responses slower than the latency threshold
+}
+
+// NewTracker creates a tracker for the objectives in cfg
+func NewTracker(cfg *config.SLOConfig) *Tracker {
+	longest := time.Duration(0)
+	for _, w := range cfg.Windows {
+		longest = max(longest, w)
+	}
+	// Keep the ring at a few thousand buckets for long windows
+	width := max(minBucketWidth, (longest / 2160).Truncate(time.Second))
+
+	objectiveGauge.Set(cfg.AvailabilityObjective, Availability)
+	objectiveGauge.Set(cfg.LatencyObjective, Latency)
+	return &Tracker{
+		cfg:     *cfg,
+		width:   width,
+		now:     time.Now,
+		buckets: make([]bucket, int(longest/width)+1),
+	}
+}
+
+// Record counts a request by its status and time to first byte
+func (t *Tracker) Record(status int, latency time.Duration) {
+	epoch := t.now().UnixNano() / int64(t.width)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &t.buckets[epoch%int64(len(t.buckets))]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+	b.total++
+	if status >= 500 {
+		b.errors++
+	}
+	if latency > t.cfg.LatencyThreshold {
+		b.slow++
+	}
+}
+
+// counts are the totals of one rolling window
+type counts struct {
+	Total, Errors, Slow uint64
+}
+
+// ratio returns the fraction of good requests, 1 for an empty window
+func ratio(bad, total uint64) float64 {
+	if total == 0 {
+		return 1
+	}
+	return 1 - float64(bad)/float64(total)
+}
+
+// window sums the buckets within d of now
+func (t *Tracker) window(d time.Duration) counts {
+	current := t.now().UnixNano() / int64(t.width)
+	n := int64(d / t.width)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var w counts
+	for epoch := current - n + 1; epoch <= current; epoch++ {
+		b := t.buckets[epoch%int64(len(t.buckets))]
+		if b.epoch == epoch {
+			w.Total += b.total
+			w.Errors += b.errors
+			w.Slow += b.slow
+		}
+	}
+	return w
+}
+
+// Update refreshes the SLI and burn rate gauges
+func (t *Tracker) Update() {
+	for _, d := range t.cfg.Windows {
+		label := d.String()
+		w := t.window(d)
+		windowRequestsGauge.Set(float64(w.Total), label)
+
+		for _, sli := range []struct {
+			name      string
+			bad       uint64
+			objective float64
+		}{
+			{Availability, w.Errors, t.cfg.AvailabilityObjective},
+			{Latency, w.Slow, t.cfg.LatencyObjective},
+		} {
+			r := ratio(sli.bad, w.Total)
+			ratioGauge.Set(r, sli.name, label)
+			burnRateGauge.Set((1-r)/(1-sli.objective), sli.name, label)
+		}
+	}
+}
+
+// Run updates the gauges every interval until stop is closed; a nil stop
+// runs for the life of the process
+func (t *Tracker) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.Update()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
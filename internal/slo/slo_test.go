@@ -0,0 +1,66 @@
+package slo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/config"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(&config.SLOConfig{
+		AvailabilityObjective: 0.99,
+		LatencyObjective:      0.9,
+		LatencyThreshold:      100 * time.Millisecond,
+		Windows:               []time.Duration{5 * time.Minute, time.Hour},
+	})
+	now := time.Date(2024, 5, 7, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	// An hour ago: 10 failures, outside the short window
+	now = now.Add(-50 * time.Minute)
+	for i := 0; i < 10; i++ {
+		tracker.Record(503, time.Millisecond)
+	}
+	// Recently: 90 fast successes, 10 slow ones
+	now = now.Add(50 * time.Minute)
+	for i := 0; i < 90; i++ {
+		tracker.Record(200, 10*time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		tracker.Record(404, time.Second)
+	}
+	tracker.Update()
+
+	tests := []struct {
+		sli, window   string
+		ratio, burn   float64
+		wantRequests  float64
+		requestWindow string
+	}{
+		{Availability, "5m0s", 1, 0, 100, "5m0s"},
+		{Latency, "5m0s", 0.9, 1, 100, "5m0s"},
+		{Availability, "1h0m0s", 100.0 / 110, (10.0 / 110) / 0.01, 110, "1h0m0s"},
+		{Latency, "1h0m0s", 100.0 / 110, (10.0 / 110) / 0.1, 110, "1h0m0s"},
+	}
+	for _, tt := range tests {
+		if got := ratioGauge.Value(tt.sli, tt.window); math.Abs(got-tt.ratio) > 1e-9 {
+			t.Errorf("%s %s ratio = %v, want %v", tt.sli, tt.window, got, tt.ratio)
+		}
+		if got := burnRateGauge.Value(tt.sli, tt.window); math.Abs(got-tt.burn) > 1e-9 {
+			t.Errorf("%s %s burn rate = %v, want %v", tt.sli, tt.window, got, tt.burn)
+		}
+		if got := windowRequestsGauge.Value(tt.requestWindow); got != tt.wantRequests {
+			t.Errorf("%s requests = %v, want %v", tt.requestWindow, got, tt.wantRequests)
+		}
+	}
+
+	// Buckets older than the longest window are not counted again
+	now = now.Add(2 * time.Hour)
+	tracker.Record(200, time.Millisecond)
+	tracker.Update()
+	if got := windowRequestsGauge.Value("1h0m0s"); got != 1 {
+		t.Errorf("requests after expiry = %v, want 1", got)
+	}
+}
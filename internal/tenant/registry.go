@@ -0,0 +1,314 @@
+// Package tenant tracks tenant lifecycle changes made at runtime through the
+// admin API. Credentials of suspended and deleted tenants are rejected;
+// tenants the registry has no record of are active.
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is a tenant's lifecycle state
+type State string
+
+const (
+	Active    State = "active"
+	Suspended State = "suspended"
+	Deleted   State = "deleted"
+)
+
+// Cleanup job statuses
+const (
+	CleanupRunning     = "running"
+	CleanupDone        = "done"
+	CleanupFailed      = "failed"
+	CleanupInterrupted = "interrupted" // the gateway restarted while it ran
+)
+
+var (
+	ErrExists   = errors.New("tenant already exists")
+	ErrNotFound = errors.New("tenant not found")
+	ErrDeleted  = errors.New("tenant is deleted")
+)
+
+// Tenant is the recorded state of a tenant
+type Tenant struct {
+	ID          string    `json:"id"`
+	State       State     `json:"state"`
+	Description string    `json:"description,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Cleanup     *Cleanup  `json:"cleanup,omitempty"`
+}
+
+// Cleanup is the progress of a deleted tenant's bucket cleanup job
+type Cleanup struct {
+	Buckets        []string   `json:"buckets"`
+	Status         string     `json:"status"`
+	DeletedObjects int        `json:"deletedObjects"`
+	Error          string     `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"startedAt"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+}
+
+// BucketCleaner deletes every object in a bucket, returning how many it deleted
+type BucketCleaner func(ctx context.Context, bucket string) (int, error)
+
+// Registry holds tenant states, optionally persisted to a JSON file so
+// suspensions survive restarts
+type Registry struct {
+	path string
+	now  func() time.Time
+
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+	jobs    sync.WaitGroup
+}
+
+// NewRegistry creates a registry persisted to path, loading its previous
+// state. An empty path keeps the state in memory.
+func NewRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path, now: time.Now, tenants: make(map[string]*Tenant)}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant state: %w", err)
+	}
+	var tenants []*Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant state %s: %w", path, err)
+	}
+	for _, t := range tenants {
+		if t.Cleanup != nil && t.Cleanup.Status == CleanupRunning {
+			t.Cleanup.Status = CleanupInterrupted
+		}
+		r.tenants[t.ID] = t
+	}
+	return r, nil
+}
+
+// State returns a tenant's state; tenants without a record are active
+func (r *Registry) State(id string) State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if t, ok := r.tenants[id]; ok {
+		return t.State
+	}
+	return Active
+}
+
+// Get returns the record of a tenant
+func (r *Registry) Get(id string) (Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, false
+	}
+	return t.copy(), true
+}
+
+// List returns all recorded tenants sorted by ID
+func (r *Registry) List() []Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenants := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t.copy())
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// Create records a new active tenant. A deleted tenant may be created again.
+func (r *Registry) Create(id, description string) (Tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tenants[id]; ok && t.State != Deleted {
+		return Tenant{}, ErrExists
+	}
+	t := &Tenant{ID: id, State: Active, Description: description, UpdatedAt: r.now()}
+	return r.put(t)
+}
+
+// Suspend rejects the tenant's credentials until it is resumed. Tenants
+// without a record, e.g. defined only in the credentials file, are recorded.
+func (r *Registry) Suspend(id string) (Tenant, error) {
+	return r.transition(id, Suspended)
+}
+
+// Resume reactivates a suspended tenant
+func (r *Registry) Resume(id string) (Tenant, error) {
+	return r.transition(id, Active)
+}
+
+func (r *Registry) transition(id string, state State) (Tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tenants[id]
+	switch {
+	case !ok:
+		t = &Tenant{ID: id}
+	case t.State == Deleted:
+		return Tenant{}, ErrDeleted
+	default:
+		t = ptr(t.copy())
+	}
+	t.State, t.UpdatedAt = state, r.now()
+	return r.put(t)
+}
+
+// Delete permanently rejects the tenant's credentials. When buckets are
+// given, clean deletes their objects in the background and the job's
+// progress is recorded on the tenant.
+func (r *Registry) Delete(id string, buckets []string, clean BucketCleaner) (Tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := &Tenant{ID: id}
+	if old, ok := r.tenants[id]; ok {
+		if old.State == Deleted {
+			return Tenant{}, ErrDeleted
+		}
+		t = ptr(old.copy())
+	}
+	t.State, t.UpdatedAt = Deleted, r.now()
+	if len(buckets) > 0 {
+		t.Cleanup = &Cleanup{Buckets: buckets, Status: CleanupRunning, StartedAt: r.now()}
+	}
+	saved, err := r.put(t)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if len(buckets) > 0 {
+		r.jobs.Add(1)
+		go r.cleanup(id, buckets, clean)
+	}
+	return saved, nil
+}
+
+// Wait blocks until running cleanup jobs finish
+func (r *Registry) Wait() {
+	r.jobs.Wait()
+}
+
+// cleanup empties a deleted tenant's buckets, recording progress after each
+func (r *Registry) cleanup(id string, buckets []string, clean BucketCleaner) {
+	defer r.jobs.Done()
+	log.Printf("Tenant %s deleted, cleaning up %d bucket(s)", id, len(buckets))
+
+	var jobErr error
+	for _, bucket := range buckets {
+		n, err := clean(context.Background(), bucket)
+		r.update(id, func(c *Cleanup) { c.DeletedObjects += n })
+		if err != nil {
+			jobErr = fmt.Errorf("bucket %s: %w", bucket, err)
+			break
+		}
+	}
+
+	r.update(id, func(c *Cleanup) {
+		finished := r.now()
+		c.FinishedAt = &finished
+		c.Status = CleanupDone
+		if jobErr != nil {
+			c.Status, c.Error = CleanupFailed, jobErr.Error()
+		}
+	})
+	if jobErr != nil {
+		log.Printf("Cleanup of tenant %s failed: %v", id, jobErr)
+	} else {
+		log.Printf("Cleanup of tenant %s finished", id)
+	}
+}
+
+// update applies fn to the tenant's cleanup job and persists it
+func (r *Registry) update(id string, fn func(*Cleanup)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tenants[id]
+	if !ok || t.Cleanup == nil {
+		return
+	}
+	t = ptr(t.copy())
+	fn(t.Cleanup)
+	if _, err := r.put(t); err != nil {
+		log.Printf("Failed to record cleanup progress of tenant %s: %v", id, err)
+	}
+}
+
+// put stores t and persists the registry, leaving the previous record in
+// place if that fails. Called with r.mu held.
+func (r *Registry) put(t *Tenant) (Tenant, error) {
+	old, existed := r.tenants[t.ID]
+	r.tenants[t.ID] = t
+	if err := r.save(); err != nil {
+		if existed {
+			r.tenants[t.ID] = old
+		} else {
+			delete(r.tenants, t.ID)
+		}
+		return Tenant{}, err
+	}
+	return t.copy(), nil
+}
+
+// save atomically writes the state file. Called with r.mu held.
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	data, err := json.MarshalIndent(tenants, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".tenants-*")
+	if err != nil {
+		return fmt.Errorf("failed to save tenant state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save tenant state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save tenant state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to save tenant state: %w", err)
+	}
+	return nil
+}
+
+// copy returns a deep copy, so records handed out are not changed by jobs
+func (t *Tenant) copy() Tenant {
+	c := *t
+	if t.Cleanup != nil {
+		cleanup := *t.Cleanup
+		cleanup.Buckets = append([]string(nil), t.Cleanup.Buckets...)
+		c.Cleanup = &cleanup
+	}
+	return c
+}
+
+func ptr(t Tenant) *Tenant {
+	return &t
+}
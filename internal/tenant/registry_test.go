@@ -0,0 +1,117 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistry_Lifecycle(t *testing.T) {
+	r, err := NewRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.State("unknown"); got != Active {
+		t.Errorf("unrecorded tenant state = %s, want active", got)
+	}
+
+	if _, err := r.Create("t1", "first"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := r.Create("t1", ""); !errors.Is(err, ErrExists) {
+		t.Errorf("duplicate create: err = %v, want ErrExists", err)
+	}
+
+	if _, err := r.Suspend("t1"); err != nil || r.State("t1") != Suspended {
+		t.Errorf("suspend: state = %s, err = %v", r.State("t1"), err)
+	}
+	if _, err := r.Resume("t1"); err != nil || r.State("t1") != Active {
+		t.Errorf("resume: state = %s, err = %v", r.State("t1"), err)
+	}
+	// Tenants defined only in credentials can be suspended too
+	if _, err := r.Suspend("t2"); err != nil || r.State("t2") != Suspended {
+		t.Errorf("suspend unrecorded: state = %s, err = %v", r.State("t2"), err)
+	}
+
+	if _, err := r.Delete("t1", nil, nil); err != nil || r.State("t1") != Deleted {
+		t.Errorf("delete: state = %s, err = %v", r.State("t1"), err)
+	}
+	if _, err := r.Resume("t1"); !errors.Is(err, ErrDeleted) {
+		t.Errorf("resume deleted: err = %v, want ErrDeleted", err)
+	}
+	if _, err := r.Create("t1", "again"); err != nil || r.State("t1") != Active {
+		t.Errorf("recreate deleted: state = %s, err = %v", r.State("t1"), err)
+	}
+
+	list := r.List()
+	if len(list) != 2 || list[0].ID != "t1" || list[1].ID != "t2" {
+		t.Errorf("List = %+v", list)
+	}
+}
+
+func TestRegistry_Cleanup(t *testing.T) {
+	r, _ := NewRegistry("")
+	var cleaned []string
+	clean := func(ctx context.Context, bucket string) (int, error) {
+		cleaned = append(cleaned, bucket)
+		if bucket == "broken" {
+			return 1, errors.New("access denied")
+		}
+		return 3, nil
+	}
+
+	tn, err := r.Delete("t1", []string{"a", "b"}, clean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tn.Cleanup == nil || tn.Cleanup.Status != CleanupRunning {
+		t.Fatalf("cleanup = %+v, want running", tn.Cleanup)
+	}
+	r.Wait()
+	tn, _ = r.Get("t1")
+	if c := tn.Cleanup; c.Status != CleanupDone || c.DeletedObjects != 6 || c.FinishedAt == nil {
+		t.Errorf("cleanup = %+v, want done with 6 objects", c)
+	}
+
+	r.Delete("t2", []string{"broken", "never"}, clean)
+	r.Wait()
+	tn, _ = r.Get("t2")
+	if c := tn.Cleanup; c.Status != CleanupFailed || c.DeletedObjects != 1 || c.Error != "bucket broken: access denied" {
+		t.Errorf("cleanup = %+v, want failed after 1 object", c)
+	}
+	if want := []string{"a", "b", "broken"}; len(cleaned) != len(want) {
+		t.Errorf("cleaned %v, want %v", cleaned, want)
+	}
+}
+
+func TestRegistry_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	r, err := NewRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Suspend("t1")
+	r.Create("t2", "kept")
+	block := make(chan struct{})
+	r.Delete("t3", []string{"b"}, func(ctx context.Context, bucket string) (int, error) {
+		<-block
+		return 0, nil
+	})
+
+	reloaded, err := NewRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.State("t1"); got != Suspended {
+		t.Errorf("t1 state = %s, want suspended", got)
+	}
+	if tn, _ := reloaded.Get("t2"); tn.Description != "kept" {
+		t.Errorf("t2 = %+v", tn)
+	}
+	if tn, _ := reloaded.Get("t3"); tn.State != Deleted || tn.Cleanup.Status != CleanupInterrupted {
+		t.Errorf("t3 = %+v, cleanup %+v; want deleted with interrupted cleanup", tn, tn.Cleanup)
+	}
+	close(block)
+	r.Wait()
+}
@@ -0,0 +1,320 @@
+// Package uploadlink holds single-use upload URLs ("dropbox links") created
+// through the admin API. A link lets whoever holds it upload one object to
+// an exact key, up to a size and with a content type, without credentials.
+// An upload claims the link while it runs; a successful upload uses it up,
+// a failed one releases it for a retry.
+package uploadlink
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/s3-access-control-adapter/internal/audit"
+)
+
+// Audit actions recorded for link lifecycle events; uploads are audited as
+// s3:PutObject
+const (
+	ActionCreate = "gateway:CreateUploadLink"
+	ActionRevoke = "gateway:RevokeUploadLink"
+)
+
+// retention is how long ended links are kept for GET /admin/upload-links
+const retention = 7 * 24 * time.Hour
+
+var (
+	ErrNotFound = errors.New("upload link not found")
+	ErrEnded    = errors.New("upload link expired, revoked or already used")
+	ErrInUse    = errors.New("an upload through this link is in progress")
+)
+
+// Link is a single-use upload URL. Token is the secret part of the URL; ID
+// identifies the link in the admin API and audit log.
+type Link struct {
+	ID          string     `json:"id"`
+	Token       string     `json:"token"`
+	TenantID    string     `json:"tenantId"`
+	Bucket      string     `json:"bucket"`
+	Key         string     `json:"key"`
+	MaxSize     int64      `json:"maxSize"`
+	ContentType string     `json:"contentType"`
+	Note        string     `json:"note,omitempty"`
+	CreatedBy   string     `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	UsedAt      *time.Time `json:"usedAt,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active reports whether the link can still be used at t
+func (l *Link) Active(t time.Time) bool {
+	return l.UsedAt == nil && l.RevokedAt == nil && t.Before(l.ExpiresAt)
+}
+
+// end returns when the link stopped being usable, or its expiry
+func (l *Link) end() time.Time {
+	switch {
+	case l.UsedAt != nil:
+		return *l.UsedAt
+	case l.RevokedAt != nil:
+		return *l.RevokedAt
+	}
+	return l.ExpiresAt
+}
+
+// Store holds links, optionally persisted to a JSON file so they survive
+// restarts. Links are only known to the replica that holds the file.
+type Store struct {
+	path   string
+	logger audit.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	links   map[string]*Link // by ID
+	tokens  map[string]string
+	claimed map[string]bool // IDs with an upload in progress
+}
+
+// NewStore creates a store persisted to path, loading its previous state,
+// and recording lifecycle events to logger. An empty path keeps links in
+// memory; a nil logger records nothing.
+func NewStore(path string, logger audit.Logger) (*Store, error) {
+	s := &Store{path: path, logger: logger, now: time.Now,
+		links: make(map[string]*Link), tokens: make(map[string]string), claimed: make(map[string]bool)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload link state: %w", err)
+	}
+	var links []*Link
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("failed to parse upload link state %s: %w", path, err)
+	}
+	for _, l := range links {
+		s.links[l.ID], s.tokens[l.Token] = l, l.ID
+	}
+	return s, nil
+}
+
+// Create records a link valid for ttl and audits it
+func (s *Store) Create(l Link, ttl time.Duration) (Link, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return Link{}, fmt.Errorf("failed to generate upload link token: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	l.ID, l.Token = uuid.NewString(), base64.RawURLEncoding.EncodeToString(token)
+	l.CreatedAt, l.ExpiresAt = now, now.Add(ttl)
+	l.UsedAt, l.RevokedAt = nil, nil
+	s.links[l.ID], s.tokens[l.Token] = &l, l.ID
+	if err := s.save(); err != nil {
+		delete(s.links, l.ID)
+		delete(s.tokens, l.Token)
+		return Link{}, err
+	}
+	s.record(ActionCreate, &l)
+	return l.copy(), nil
+}
+
+// Revoke ends a link before it is used or expires
+func (s *Store) Revoke(id string) (Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.links[id]
+	if !ok {
+		return Link{}, ErrNotFound
+	}
+	now := s.now()
+	if !l.Active(now) {
+		return Link{}, ErrEnded
+	}
+	l.RevokedAt = &now
+	if err := s.save(); err != nil {
+		l.RevokedAt = nil
+		return Link{}, err
+	}
+	s.record(ActionRevoke, l)
+	return l.copy(), nil
+}
+
+// Get returns a link by ID
+func (s *Store) Get(id string) (Link, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.links[id]
+	if !ok {
+		return Link{}, false
+	}
+	return l.copy(), true
+}
+
+// List returns all links, newest first
+func (s *Store) List() []Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	links := make([]Link, 0, len(s.links))
+	for _, l := range s.links {
+		links = append(links, l.copy())
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].CreatedAt.After(links[j].CreatedAt) })
+	return links
+}
+
+// Claim reserves the link with a token for an upload. Exactly one of
+// Complete or Release must follow.
+func (s *Store) Claim(token string) (Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.links[s.tokens[token]]
+	if !ok {
+		return Link{}, ErrNotFound
+	}
+	if !l.Active(s.now()) {
+		return Link{}, ErrEnded
+	}
+	if s.claimed[l.ID] {
+		return Link{}, ErrInUse
+	}
+	s.claimed[l.ID] = true
+	return l.copy(), nil
+}
+
+// Release gives up the claim of an upload that failed
+func (s *Store) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, id)
+}
+
+// Complete marks a claimed link used by a successful upload. The link is
+// used up in memory even when persisting fails.
+func (s *Store) Complete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, id)
+	l, ok := s.links[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := s.now()
+	l.UsedAt = &now
+	return s.save()
+}
+
+// Sweep forgets links that ended longer than the retention period ago
+func (s *Store) Sweep() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	changed := false
+	for id, l := range s.links {
+		if !s.claimed[id] && now.Sub(l.end()) > retention {
+			delete(s.links, id)
+			delete(s.tokens, l.Token)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// Run sweeps every interval until stop is closed
+func (s *Store) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := s.Sweep(); err != nil {
+			log.Printf("Failed to sweep upload links: %v", err)
+		}
+	}
+}
+
+// record writes a lifecycle event to the audit log. Called with s.mu held.
+func (s *Store) record(action string, l *Link) {
+	if s.logger == nil {
+		return
+	}
+	s.logger.Log(&audit.Entry{
+		Timestamp: s.now().UTC(),
+		RequestID: l.ID,
+		ClientID:  l.CreatedBy,
+		TenantID:  l.TenantID,
+		Action:    action,
+		Resource:  "arn:aws:s3:::" + l.Bucket + "/" + l.Key,
+		Bucket:    l.Bucket,
+		Key:       l.Key,
+		Decision:  "allow",
+	})
+}
+
+// save atomically writes the state file. Called with s.mu held.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	links := make([]*Link, 0, len(s.links))
+	for _, l := range s.links {
+		links = append(links, l)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".upload-links-*")
+	if err != nil {
+		return fmt.Errorf("failed to save upload link state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save upload link state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save upload link state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save upload link state: %w", err)
+	}
+	return nil
+}
+
+// copy returns a deep copy, so links handed out are not changed later
+func (l *Link) copy() Link {
+	c := *l
+	if l.UsedAt != nil {
+		used := *l.UsedAt
+		c.UsedAt = &used
+	}
+	if l.RevokedAt != nil {
+		revoked := *l.RevokedAt
+		c.RevokedAt = &revoked
+	}
+	return c
+}
@@ -0,0 +1,100 @@
+package uploadlink
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s3-access-control-adapter/internal/audit"
+)
+
+type recordingLogger struct{ entries []*audit.Entry }
+
+func (l *recordingLogger) Log(e *audit.Entry) error { l.entries = append(l.entries, e); return nil }
+func (l *recordingLogger) Close() error             { return nil }
+
+func TestStore_Lifecycle(t *testing.T) {
+	logger := &recordingLogger{}
+	s, err := NewStore("", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	l, err := s.Create(Link{TenantID: "tenant-001", Bucket: "inbox", Key: "partner/report.pdf", MaxSize: 1 << 20}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Token == "" || l.Token == l.ID {
+		t.Fatalf("link = %+v, want a separate secret token", l)
+	}
+	if _, err := s.Claim("wrong-token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("claim unknown token: err = %v, want ErrNotFound", err)
+	}
+
+	// A failed upload releases the link; a second concurrent upload is refused
+	if _, err := s.Claim(l.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Claim(l.Token); !errors.Is(err, ErrInUse) {
+		t.Errorf("concurrent claim: err = %v, want ErrInUse", err)
+	}
+	s.Release(l.ID)
+	if _, err := s.Claim(l.Token); err != nil {
+		t.Fatalf("claim after release: %v", err)
+	}
+	if err := s.Complete(l.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Claim(l.Token); !errors.Is(err, ErrEnded) {
+		t.Errorf("claim used link: err = %v, want ErrEnded", err)
+	}
+	if _, err := s.Revoke(l.ID); !errors.Is(err, ErrEnded) {
+		t.Errorf("revoke used link: err = %v, want ErrEnded", err)
+	}
+
+	expiring, _ := s.Create(Link{Bucket: "inbox", Key: "a"}, time.Minute)
+	revoked, _ := s.Create(Link{Bucket: "inbox", Key: "b"}, time.Hour)
+	if _, err := s.Revoke(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	for _, token := range []string{expiring.Token, revoked.Token} {
+		if _, err := s.Claim(token); !errors.Is(err, ErrEnded) {
+			t.Errorf("claim ended link: err = %v, want ErrEnded", err)
+		}
+	}
+
+	now = now.Add(retention + time.Hour)
+	s.Sweep()
+	if links := s.List(); len(links) != 0 {
+		t.Errorf("links after retention: %+v", links)
+	}
+	if len(logger.entries) != 4 || logger.entries[3].Action != ActionRevoke {
+		t.Errorf("audit entries = %d, want 3 creations and a revocation", len(logger.entries))
+	}
+}
+
+func TestStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.json")
+	s, err := NewStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := s.Create(Link{Bucket: "inbox", Key: "k"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Claim(l.Token)
+	s.Complete(l.ID)
+
+	reloaded, err := NewStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.Claim(l.Token); !errors.Is(err, ErrEnded) {
+		t.Errorf("used link after restart: err = %v, want ErrEnded", err)
+	}
+}
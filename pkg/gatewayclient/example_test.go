@@ -0,0 +1,44 @@
+package gatewayclient_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/pkg/gatewayclient"
+)
+
+func ExampleNew() {
+	client, err := gatewayclient.New(gatewayclient.Config{
+		Endpoint:        "http://localhost:8080",
+		AccessKeyID:     "AKIAEXAMPLE000000001",
+		SecretAccessKey: "example-secret",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String("tenant-001-data"),
+		Key:    aws.String("reports/today.csv"),
+		Body:   strings.NewReader("a,b\n1,2\n"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleFromEnv() {
+	cfg, err := gatewayclient.FromEnv()
+	if err != nil {
+		log.Fatalf("gateway client not configured: %v", err)
+	}
+	client, err := gatewayclient.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(client != nil)
+}
@@ -0,0 +1,109 @@
+// Package gatewayclient configures the AWS SDK for Go v2 S3 client to talk
+// to the gateway.
+//
+// The gateway serves the S3 API on its own endpoint, addressed path-style,
+// and authenticates requests signed with gateway-issued access keys rather
+// than AWS credentials. New returns an *s3.Client set up that way, so
+// services only need the endpoint and their keys:
+//
+//	client, err := gatewayclient.New(gatewayclient.Config{
+//		Endpoint:        "https://s3-gateway.internal:8080",
+//		AccessKeyID:     os.Getenv("GATEWAY_ACCESS_KEY_ID"),
+//		SecretAccessKey: os.Getenv("GATEWAY_SECRET_ACCESS_KEY"),
+//	})
+package gatewayclient
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultRegion is the signing region used when Config.Region is empty. The
+// gateway accepts any region unless auth.regions restricts them.
+const DefaultRegion = "us-east-1"
+
+// Environment variables read by FromEnv
+const (
+	EnvEndpoint        = "S3_GATEWAY_ENDPOINT"
+	EnvAccessKeyID     = "S3_GATEWAY_ACCESS_KEY_ID"
+	EnvSecretAccessKey = "S3_GATEWAY_SECRET_ACCESS_KEY"
+	EnvSessionToken    = "S3_GATEWAY_SESSION_TOKEN"
+	EnvRegion          = "S3_GATEWAY_REGION"
+)
+
+// Config describes how to reach the gateway
+type Config struct {
+	// Endpoint is the gateway's base URL, e.g. https://s3-gateway:8080
+	Endpoint string
+	// AccessKeyID and SecretAccessKey are a gateway-issued credential
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for short-lived credentials, such as those the
+	// gateway issues to CI jobs
+	SessionToken string
+	// Region to sign requests for, defaults to DefaultRegion
+	Region string
+	// HTTPClient sends the requests, defaulting to the SDK's client
+	HTTPClient s3.HTTPClient
+}
+
+// FromEnv reads a Config from the S3_GATEWAY_* environment variables
+func FromEnv() (Config, error) {
+	c := Config{
+		Endpoint:        os.Getenv(EnvEndpoint),
+		AccessKeyID:     os.Getenv(EnvAccessKeyID),
+		SecretAccessKey: os.Getenv(EnvSecretAccessKey),
+		SessionToken:    os.Getenv(EnvSessionToken),
+		Region:          os.Getenv(EnvRegion),
+	}
+	return c, c.validate()
+}
+
+func (c Config) validate() error {
+	if c.Endpoint == "" {
+		return errors.New("gateway endpoint is required")
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid gateway endpoint %q: must be an http or https URL", c.Endpoint)
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return errors.New("gateway access key ID and secret access key are required")
+	}
+	return nil
+}
+
+// Options returns S3 client options for the gateway: its endpoint, path-style
+// addressing and the static gateway credential
+func (c Config) Options() (s3.Options, error) {
+	if err := c.validate(); err != nil {
+		return s3.Options{}, err
+	}
+	region := c.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	return s3.Options{
+		Region:       region,
+		BaseEndpoint: aws.String(c.Endpoint),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(c.AccessKeyID, c.SecretAccessKey, c.SessionToken),
+		HTTPClient:   c.HTTPClient,
+	}, nil
+}
+
+// New creates an S3 client for the gateway. optFns are applied last and may
+// change any option, e.g. retries or API middleware.
+func New(c Config, optFns ...func(*s3.Options)) (*s3.Client, error) {
+	opts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	return s3.New(opts, optFns...), nil
+}
@@ -0,0 +1,113 @@
+package gatewayclient_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/s3-access-control-adapter/internal/config"
+	"github.com/s3-access-control-adapter/internal/proxy/proxytest"
+	"github.com/s3-access-control-adapter/pkg/gatewayclient"
+)
+
+func TestNew_RoundTripThroughGateway(t *testing.T) {
+	cred := config.Credential{
+		AccessKey: "AKIACLIENT0000000001",
+		SecretKey: "client-secret",
+		ClientID:  "client",
+		TenantID:  "tenant-001",
+		Policies:  []string{"full-access"},
+		Scopes:    []string{"tenant-001-*"},
+	}
+	policies := []config.Policy{{
+		Name: "full-access",
+		Statements: []config.Statement{{
+			Effect:    config.EffectAllow,
+			Actions:   []string{"s3:*"},
+			Resources: []string{"arn:aws:s3:::tenant-001-*", "arn:aws:s3:::tenant-001-*/*"},
+		}},
+	}}
+	gw := proxytest.NewGateway(t, []config.Credential{cred}, policies, "tenant-001-data")
+
+	client, err := gatewayclient.New(gatewayclient.Config{
+		Endpoint:        gw.URL,
+		AccessKeyID:     cred.AccessKey,
+		SecretAccessKey: cred.SecretKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("tenant-001-data"),
+		Key:    aws.String("hello.txt"),
+		Body:   strings.NewReader("hello"),
+	})
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("tenant-001-data"), Key: aws.String("hello.txt")})
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer out.Body.Close()
+	if body, _ := io.ReadAll(out.Body); string(body) != "hello" {
+		t.Errorf("body = %q, want hello", body)
+	}
+
+	// Buckets outside the credential's scopes are denied by the gateway
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("tenant-002-data"),
+		Key:    aws.String("hello.txt"),
+		Body:   strings.NewReader("hello"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("PutObject outside scope: err = %v, want AccessDenied", err)
+	}
+}
+
+func TestConfig_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  gatewayclient.Config
+		ok   bool
+	}{
+		{"valid", gatewayclient.Config{Endpoint: "https://gw:8080", AccessKeyID: "AK", SecretAccessKey: "SK"}, true},
+		{"no endpoint", gatewayclient.Config{AccessKeyID: "AK", SecretAccessKey: "SK"}, false},
+		{"no scheme", gatewayclient.Config{Endpoint: "gw:8080", AccessKeyID: "AK", SecretAccessKey: "SK"}, false},
+		{"no secret", gatewayclient.Config{Endpoint: "http://gw", AccessKeyID: "AK"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := tt.cfg.Options()
+			if (err == nil) != tt.ok {
+				t.Fatalf("Options() error = %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && (!opts.UsePathStyle || opts.Region != gatewayclient.DefaultRegion) {
+				t.Errorf("Options() = path style %v, region %q", opts.UsePathStyle, opts.Region)
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(gatewayclient.EnvEndpoint, "http://gw:8080")
+	t.Setenv(gatewayclient.EnvAccessKeyID, "AK")
+	t.Setenv(gatewayclient.EnvSecretAccessKey, "SK")
+	t.Setenv(gatewayclient.EnvRegion, "eu-west-1")
+	cfg, err := gatewayclient.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Endpoint != "http://gw:8080" || cfg.Region != "eu-west-1" {
+		t.Errorf("FromEnv() = %+v", cfg)
+	}
+
+	t.Setenv(gatewayclient.EnvSecretAccessKey, "")
+	if _, err := gatewayclient.FromEnv(); err == nil {
+		t.Error("FromEnv() without a secret key succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package sigv4
+
+import "sync"
+
+// maxCachedKeys bounds a KeyCache; it is emptied when full
+const maxCachedKeys = 4096
+
+// keyScope identifies a derived signing key
+type keyScope struct {
+	secretKey, date, region, service string
+}
+
+// KeyCache memoizes derived signing keys, which only change with the date, so
+// each client's key is derived about once a day instead of on every request.
+// Keys are kept for the two most recent dates seen, covering requests signed
+// either side of midnight.
+type KeyCache struct {
+	mu      sync.Mutex
+	keys    map[keyScope][]byte
+	day     string // newest date seen
+	prevDay string
+}
+
+// NewKeyCache creates an empty signing key cache
+func NewKeyCache() *KeyCache {
+	return &KeyCache{keys: make(map[keyScope][]byte)}
+}
+
+// SigningKey returns the signing key for a credential scope, deriving it on
+// first use. A nil cache derives every time.
+func (c *KeyCache) SigningKey(secretKey, date, region, service string) []byte {
+	if c == nil {
+		return SigningKey(secretKey, date, region, service)
+	}
+	scope := keyScope{secretKey, date, region, service}
+
+	c.mu.Lock()
+	key, ok := c.keys[scope]
+	c.mu.Unlock()
+	if ok {
+		return key
+	}
+
+	key = SigningKey(secretKey, date, region, service)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if date > c.day {
+		c.prevDay, c.day = c.day, date
+		c.expire()
+	} else if date != c.day && date != c.prevDay {
+		// Older than both cached dates; not worth keeping
+		return key
+	}
+	if len(c.keys) >= maxCachedKeys {
+		c.keys = make(map[keyScope][]byte)
+	}
+	c.keys[scope] = key
+	return key
+}
+
+// Len returns the number of cached keys
+func (c *KeyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.keys)
+}
+
+// expire drops keys for dates before the two most recent. Called with c.mu
+// held.
+func (c *KeyCache) expire() {
+	for scope := range c.keys {
+		if scope.date != c.day && scope.date != c.prevDay {
+			delete(c.keys, scope)
+		}
+	}
+}
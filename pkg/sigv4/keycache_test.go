@@ -0,0 +1,86 @@
+package sigv4
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestKeyCache(t *testing.T) {
+	c := NewKeyCache()
+	want := SigningKey("secret", "20240115", "us-east-1", "s3")
+
+	if got := c.SigningKey("secret", "20240115", "us-east-1", "s3"); !bytes.Equal(got, want) {
+		t.Fatalf("SigningKey = %x, want %x", got, want)
+	}
+	if got := c.SigningKey("secret", "20240115", "us-east-1", "s3"); !bytes.Equal(got, want) {
+		t.Fatalf("cached SigningKey = %x, want %x", got, want)
+	}
+	c.SigningKey("other", "20240115", "us-east-1", "s3")
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+
+	// The previous day is kept for requests signed before midnight
+	c.SigningKey("secret", "20240116", "us-east-1", "s3")
+	if c.Len() != 3 {
+		t.Errorf("after new day: Len = %d, want 3", c.Len())
+	}
+	c.SigningKey("secret", "20240117", "us-east-1", "s3")
+	if c.Len() != 2 {
+		t.Errorf("after second new day: Len = %d, want 2", c.Len())
+	}
+	if got := c.SigningKey("secret", "20240115", "us-east-1", "s3"); !bytes.Equal(got, want) || c.Len() != 2 {
+		t.Errorf("expired date: key = %x, Len = %d; want derived key and no new entry", got, c.Len())
+	}
+
+	for i := 0; i < maxCachedKeys+10; i++ {
+		c.SigningKey("secret", "20240117", fmt.Sprintf("region-%d", i), "s3")
+	}
+	if c.Len() > maxCachedKeys {
+		t.Errorf("Len = %d exceeds %d", c.Len(), maxCachedKeys)
+	}
+
+	var nilCache *KeyCache
+	if got := nilCache.SigningKey("secret", "20240115", "us-east-1", "s3"); !bytes.Equal(got, want) {
+		t.Errorf("nil cache SigningKey = %x, want %x", got, want)
+	}
+}
+
+func benchmarkVerify(b *testing.B, keys *KeyCache) {
+	now, _ := time.Parse(TimeFormat, suiteTime)
+	v := &Verifier{Now: func() time.Time { return now }, Keys: keys}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.amazonaws.com/", nil)
+	req.Header.Set("X-Amz-Date", suiteTime)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := v.Verify(req, suiteSecret); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) { benchmarkVerify(b, nil) })
+	b.Run("cached", func(b *testing.B) { benchmarkVerify(b, NewKeyCache()) })
+}
+
+func BenchmarkSigningKey(b *testing.B) {
+	b.Run("derive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			SigningKey(suiteSecret, "20150830", "us-east-1", "s3")
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := NewKeyCache()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.SigningKey(suiteSecret, "20150830", "us-east-1", "s3")
+			}
+		})
+	})
+}
@@ -0,0 +1,324 @@
+// Package sigv4 verifies AWS Signature Version 4 signed HTTP requests.
+//
+// It checks the Authorization header form of SigV4 as sent by the AWS SDKs
+// and CLI against a caller-supplied secret key. Paths are canonicalized the
+// way S3 does, without normalization or double encoding.
+package sigv4
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm is the only signing algorithm supported
+const Algorithm = "AWS4-HMAC-SHA256"
+
+// TimeFormat is the layout of X-Amz-Date
+const TimeFormat = "20060102T150405Z"
+
+// DefaultMaxSkew is how far a request's X-Amz-Date may be from the current time
+const DefaultMaxSkew = 15 * time.Minute
+
+// Verification errors. Errors returned by Verify wrap one of these.
+var (
+	ErrMissingAuthorization = errors.New("missing Authorization header")
+	ErrInvalidAuthorization = errors.New("invalid Authorization header format")
+	ErrMissingDate          = errors.New("missing X-Amz-Date header")
+	ErrInvalidDate          = errors.New("invalid X-Amz-Date format")
+	ErrRequestTimeSkewed    = errors.New("request time too skewed")
+	ErrSignatureMismatch    = errors.New("signature mismatch")
+)
+
+// Authorization holds the parsed components of a SigV4 Authorization header
+type Authorization struct {
+	AccessKey     string
+	Date          string // YYYYMMDD format
+	Region        string
+	Service       string
+	SignedHeaders []string
+	Signature     string
+}
+
+// Scope returns the credential scope, e.g. 20150830/us-east-1/s3/aws4_request
+func (a *Authorization) Scope() string {
+	return fmt.Sprintf("%s/%s/%s/aws4_request", a.Date, a.Region, a.Service)
+}
+
+// authHeaderRegex matches a whole AWS4-HMAC-SHA256 Authorization header
+var authHeaderRegex = regexp.MustCompile(
+	`^AWS4-HMAC-SHA256\s+` +
+		`Credential=([^/]+)/(\d{8})/([^/]+)/([^/]+)/aws4_request,\s*` +
+		`SignedHeaders=([^,]+),\s*` +
+		`Signature=([a-f0-9]+)$`,
+)
+
+// ParseAuthorization parses a SigV4 Authorization header
+func ParseAuthorization(header string) (*Authorization, error) {
+	if header == "" {
+		return nil, ErrMissingAuthorization
+	}
+	matches := authHeaderRegex.FindStringSubmatch(header)
+	if matches == nil {
+		return nil, ErrInvalidAuthorization
+	}
+
+	return &Authorization{
+		AccessKey:     matches[1],
+		Date:          matches[2],
+		Region:        matches[3],
+		Service:       matches[4],
+		SignedHeaders: strings.Split(matches[5], ";"),
+		Signature:     matches[6],
+	}, nil
+}
+
+// Verifier checks request signatures. The zero value uses DefaultMaxSkew and
+// the system clock, and derives the signing key for every request.
+type Verifier struct {
+	MaxSkew time.Duration
+	Now     func() time.Time
+	Keys    *KeyCache // optional signing key cache
+}
+
+// defaultVerifier backs Verify
+var defaultVerifier = &Verifier{Keys: NewKeyCache()}
+
+// Verify checks req's signature using a Verifier with default settings and a
+// shared signing key cache
+func Verify(req *http.Request, secretKey string) (*Authorization, error) {
+	return defaultVerifier.Verify(req, secretKey)
+}
+
+// Verify parses req's Authorization header and checks its date and signature
+// against secretKey. It returns the parsed header so the caller can confirm
+// the access key matches the secret. When X-Amz-Content-Sha256 is absent the
+// body is read to hash it and replaced; otherwise the header value is signed
+// as is and the body is not checked.
+func (v *Verifier) Verify(req *http.Request, secretKey string) (*Authorization, error) {
+	auth, err := ParseAuthorization(req.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+
+	amzDate := req.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		return nil, ErrMissingDate
+	}
+	requestTime, err := time.Parse(TimeFormat, amzDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDate, err)
+	}
+	if auth.Date != amzDate[:8] {
+		return nil, fmt.Errorf("%w: credential date %s does not match X-Amz-Date %s", ErrInvalidAuthorization, auth.Date, amzDate)
+	}
+
+	now, maxSkew := time.Now(), DefaultMaxSkew
+	if v.Now != nil {
+		now = v.Now()
+	}
+	if v.MaxSkew > 0 {
+		maxSkew = v.MaxSkew
+	}
+	if requestTime.Before(now.Add(-maxSkew)) || requestTime.After(now.Add(maxSkew)) {
+		return nil, fmt.Errorf("%w: request time %s is more than %s from server time", ErrRequestTimeSkewed, amzDate, maxSkew)
+	}
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		var body []byte
+		if req.Body != nil {
+			body, _ = io.ReadAll(req.Body)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		payloadHash = hashHex(body)
+	}
+
+	canonicalRequest, err := CanonicalRequest(req, auth.SignedHeaders, payloadHash)
+	if err != nil {
+		return nil, err
+	}
+	stringToSign := StringToSign(amzDate, auth.Scope(), canonicalRequest)
+	key := v.Keys.SigningKey(secretKey, auth.Date, auth.Region, auth.Service)
+	expected := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(auth.Signature)) {
+		return nil, ErrSignatureMismatch
+	}
+	return auth, nil
+}
+
+// CanonicalRequest builds the SigV4 canonical request for req
+func CanonicalRequest(req *http.Request, signedHeaders []string, payloadHash string) (string, error) {
+	canonicalURI := req.URL.Path
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalQueryString, err := canonicalQuery(req.URL.RawQuery)
+	if err != nil {
+		return "", err
+	}
+
+	lower := make([]string, len(signedHeaders))
+	for i, h := range signedHeaders {
+		lower[i] = strings.ToLower(h)
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		escapePath(canonicalURI),
+		canonicalQueryString,
+		canonicalHeaders(req, lower),
+		strings.Join(lower, ";"),
+		payloadHash,
+	}, "\n"), nil
+}
+
+// StringToSign builds the string to sign for a canonical request
+func StringToSign(amzDate, scope, canonicalRequest string) string {
+	return strings.Join([]string{
+		Algorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+}
+
+// SigningKey derives the signing key for a credential scope
+func SigningKey(secretKey, date, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), []byte(date))
+	kRegion := hmacSHA256(kDate, []byte(region))
+	kService := hmacSHA256(kRegion, []byte(service))
+	return hmacSHA256(kService, []byte("aws4_request"))
+}
+
+// canonicalHeaders creates the canonical headers string. Repeated headers
+// are folded into one comma-separated value in the order received, and each
+// value is trimmed with inner runs of spaces collapsed.
+func canonicalHeaders(req *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, h := range signedHeaders {
+		var raw []string
+		switch {
+		case h == "host":
+			raw = []string{req.Host}
+		case h == "content-length" && req.Header.Get(h) == "" && req.ContentLength >= 0:
+			// Clients signing an empty body may not have sent the header
+			raw = []string{strconv.FormatInt(req.ContentLength, 10)}
+		default:
+			raw = req.Header.Values(h)
+		}
+		values := make([]string, len(raw))
+		for i, v := range raw {
+			values[i] = trimAll(v)
+		}
+		b.WriteString(h + ":" + strings.Join(values, ",") + "\n")
+	}
+	return b.String()
+}
+
+// trimAll trims whitespace from a header value and collapses inner runs of
+// spaces, treating folded lines as a space. Inner tabs and other whitespace
+// are kept, as the SDKs sign them unchanged.
+func trimAll(v string) string {
+	lines := strings.Split(v, "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if i > 0 {
+			line = strings.TrimLeft(line, " \t")
+		}
+		lines[i] = line
+	}
+	fields := strings.FieldsFunc(strings.Join(lines, " "), func(r rune) bool { return r == ' ' })
+	return strings.TrimSpace(strings.Join(fields, " "))
+}
+
+// canonicalQuery creates the canonical query string: every name and value
+// URI-encoded per RFC 3986 (space as %20, '=' and '+' escaped, "acl" as
+// "acl="), sorted by encoded name and then encoded value. A literal '+' in
+// the raw query is a space, as clients that sign it encode '+' as %2B.
+func canonicalQuery(rawQuery string) (string, error) {
+	type pair struct{ key, value string }
+	var pairs []pair
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			return "", fmt.Errorf("invalid query parameter %q: %w", k, err)
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for query parameter %q: %w", k, err)
+		}
+		pairs = append(pairs, pair{uriEncode(key), uriEncode(value)})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].key != pairs[j].key {
+			return pairs[i].key < pairs[j].key
+		}
+		return pairs[i].value < pairs[j].value
+	})
+
+	var b strings.Builder
+	for i, p := range pairs {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(p.key + "=" + p.value)
+	}
+	return b.String(), nil
+}
+
+// escapePath URI-encodes each segment of the decoded path, as S3 clients do
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// uriEncode percent-encodes every byte except the RFC 3986 unreserved
+// characters, with uppercase hex digits
+func uriEncode(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&0xF])
+	}
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}